@@ -174,6 +174,9 @@ func assembleAccountKey(assert assertionBase) (Assertion, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := checkKeyFormat(pubk, assert.Format(), "public key"); err != nil {
+		return nil, err
+	}
 
 	// ignore extra headers for future compatibility
 	return &AccountKey{
@@ -207,7 +207,8 @@ func (aks *accountKeySuite) TestDecodeInvalidPublicKey(c *C) {
 		{"", "cannot decode public key: no data"},
 		{"==", "cannot decode public key: .*"},
 		{"stuff", "cannot decode public key: .*"},
-		{"AnNpZw==", "unsupported public key format version: 2"},
+		{"A3NpZw==", "unsupported public key format version: 3"},
+		{"AnNpZw==", "cannot decode public key: expected 32 bytes Ed25519 public key, got 3"},
 		{"AUJST0tFTg==", "cannot decode public key: .*"},
 		{spurious, "public key has spurious trailing data"},
 	}
@@ -515,6 +516,54 @@ func (aks *accountKeySuite) TestAccountKeyAddAndFind(c *C) {
 	c.Check(found.Body(), DeepEquals, []byte(aks.pubKeyBody))
 }
 
+func (aks *accountKeySuite) TestAccountKeyEd25519AddAndFind(c *C) {
+	trustedKey := testPrivKey0
+
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+	edKeyID := edKey.PublicKey().ID()
+	pubKeyBody, err := asserts.EncodePublicKey(edKey.PublicKey())
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":        "canonical",
+		"account-id":          "acc-id1",
+		"name":                "default",
+		"public-key-sha3-384": edKeyID,
+		"since":               aks.since.Format(time.RFC3339),
+	}
+	_, err = asserts.AssembleAndSignInTest(asserts.AccountKeyType, headers, pubKeyBody, trustedKey)
+	c.Assert(err, ErrorMatches, "cannot assemble assertion account-key: public key is an Ed25519 key which requires format 1 or greater")
+
+	headers["format"] = "1"
+	accKey, err := asserts.AssembleAndSignInTest(asserts.AccountKeyType, headers, pubKeyBody, trustedKey)
+	c.Assert(err, IsNil)
+
+	db := aks.openDB(c)
+
+	aks.prereqAccount(c, db)
+
+	err = db.Add(accKey)
+	c.Assert(err, IsNil)
+
+	found, err := db.Find(asserts.AccountKeyType, map[string]string{
+		"account-id":          "acc-id1",
+		"public-key-sha3-384": edKeyID,
+	})
+	c.Assert(err, IsNil)
+	c.Check(found.Format(), Equals, 1)
+	c.Check(found.(*asserts.AccountKey).PublicKeyID(), Equals, edKeyID)
+
+	// assertions signed by the key can now be checked
+	a, err := asserts.AssembleAndSignInTest(asserts.TestOnlyType, map[string]interface{}{
+		"authority-id": "acc-id1",
+		"primary-key":  "a",
+	}, nil, edKey)
+	c.Assert(err, IsNil)
+	err = db.Check(a)
+	c.Check(err, IsNil)
+}
+
 func (aks *accountKeySuite) TestPublicKeyIsValidAt(c *C) {
 	// With since and until, i.e. signing account-key expires.
 	encoded := "type: account-key\n" +
@@ -756,7 +805,8 @@ func (aks *accountKeySuite) TestAccountKeyRequestDecodeInvalidPublicKey(c *C) {
 		{"", "cannot decode public key: no data"},
 		{"==", "cannot decode public key: .*"},
 		{"stuff", "cannot decode public key: .*"},
-		{"AnNpZw==", "unsupported public key format version: 2"},
+		{"A3NpZw==", "unsupported public key format version: 3"},
+		{"AnNpZw==", "cannot decode public key: expected 32 bytes Ed25519 public key, got 3"},
 		{"AUJST0tFTg==", "cannot decode public key: .*"},
 		{spurious, "public key has spurious trailing data"},
 	}
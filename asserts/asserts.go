@@ -66,6 +66,7 @@ var (
 	SnapRevisionType    = &AssertionType{"snap-revision", []string{"snap-sha3-384"}, assembleSnapRevision, 0}
 	SystemUserType      = &AssertionType{"system-user", []string{"brand-id", "email"}, assembleSystemUser, 0}
 	ValidationType      = &AssertionType{"validation", []string{"series", "snap-id", "approved-snap-id", "approved-snap-revision"}, assembleValidation, 0}
+	ValidationSetType   = &AssertionType{"validation-set", []string{"series", "account-id", "name", "sequence"}, assembleValidationSet, 0}
 
 // ...
 )
@@ -88,6 +89,7 @@ var typeRegistry = map[string]*AssertionType{
 	SnapRevisionType.Name:    SnapRevisionType,
 	SystemUserType.Name:      SystemUserType,
 	ValidationType.Name:      ValidationType,
+	ValidationSetType.Name:   ValidationSetType,
 	// no authority
 	DeviceSessionRequestType.Name: DeviceSessionRequestType,
 	SerialRequestType.Name:        SerialRequestType,
@@ -99,6 +101,11 @@ var maxSupportedFormat = map[string]int{}
 func init() {
 	// register maxSupportedFormats while breaking initialisation loop
 	maxSupportedFormat[SnapDeclarationType.Name] = 1
+	// format 1 introduced Ed25519 keys and signatures
+	maxSupportedFormat[AccountKeyType.Name] = 1
+	maxSupportedFormat[SerialType.Name] = 1
+	maxSupportedFormat[SerialRequestType.Name] = 1
+	maxSupportedFormat[DeviceSessionRequestType.Name] = 1
 }
 
 func MockMaxSupportedFormat(assertType *AssertionType, maxFormat int) (restore func()) {
@@ -0,0 +1,172 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ExtKeypairManagerKeysEnv names the environment variable pointing the
+// reference external keypair manager helper to its key store directory.
+const ExtKeypairManagerKeysEnv = "SNAP_TEST_EXT_KEYMGR_KEYS"
+
+// PutExtKeypairManagerKey stores privKey, either a *rsa.PrivateKey or
+// an ed25519.PrivateKey, under name in the key store directory of the
+// reference external keypair manager helper.
+func PutExtKeypairManagerKey(keysDir, name string, privKey interface{}) error {
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(keysDir, 0700); err != nil {
+		return err
+	}
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return ioutil.WriteFile(filepath.Join(keysDir, name), encoded, 0600)
+}
+
+// ExtKeypairManagerHelperMain runs the reference external keypair
+// manager helper, see asserts.ExternalKeypairManager for the protocol,
+// for the operation in args. Keys are read from the directory named by
+// ExtKeypairManagerKeysEnv. It returns the exit status for the helper.
+func ExtKeypairManagerHelperMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	resp, err := extKeypairManagerHelper(os.Getenv(ExtKeypairManagerKeysEnv), args, stdin)
+	if err == nil {
+		err = json.NewEncoder(stdout).Encode(resp)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+type extKeypairManagerRequest struct {
+	KeyName   string `json:"key-name"`
+	Format    string `json:"format"`
+	Mechanism string `json:"mechanism"`
+	Hash      string `json:"hash"`
+	Digest    []byte `json:"digest"`
+	Message   []byte `json:"message"`
+}
+
+func extKeypairManagerHelper(keysDir string, args []string, stdin io.Reader) (interface{}, error) {
+	if keysDir == "" {
+		return nil, fmt.Errorf("%s is not set", ExtKeypairManagerKeysEnv)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected one operation argument")
+	}
+	var req extKeypairManagerRequest
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return nil, fmt.Errorf("cannot decode request: %v", err)
+	}
+
+	switch args[0] {
+	case "features":
+		return map[string][]string{
+			"signing":     {"RSA-PKCS", "Ed25519"},
+			"public-keys": {"DER"},
+		}, nil
+	case "key-names":
+		entries, err := ioutil.ReadDir(keysDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		return map[string][]string{"key-names": names}, nil
+	case "get-public-key":
+		if req.Format != "DER" {
+			return nil, fmt.Errorf("unsupported public key format %q", req.Format)
+		}
+		signer, err := readExtKeypairManagerKey(keysDir, req.KeyName)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"public-key": der}, nil
+	case "sign":
+		signer, err := readExtKeypairManagerKey(keysDir, req.KeyName)
+		if err != nil {
+			return nil, err
+		}
+		var sig []byte
+		switch privKey := signer.(type) {
+		case *rsa.PrivateKey:
+			if req.Mechanism != "RSA-PKCS" || req.Hash != "SHA512" {
+				return nil, fmt.Errorf("unsupported signing mechanism %q with hash %q for RSA key", req.Mechanism, req.Hash)
+			}
+			sig, err = rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA512, req.Digest)
+			if err != nil {
+				return nil, err
+			}
+		case ed25519.PrivateKey:
+			if req.Mechanism != "Ed25519" {
+				return nil, fmt.Errorf("unsupported signing mechanism %q for Ed25519 key", req.Mechanism)
+			}
+			sig = ed25519.Sign(privKey, req.Message)
+		}
+		return map[string][]byte{"signature": sig}, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", args[0])
+	}
+}
+
+func readExtKeypairManagerKey(keysDir, name string) (crypto.Signer, error) {
+	encoded, err := ioutil.ReadFile(filepath.Join(keysDir, filepath.Base(name)))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find key %q", name)
+	}
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, fmt.Errorf("cannot decode key %q", name)
+	}
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode key %q: %v", name, err)
+	}
+	switch privKey := privKey.(type) {
+	case *rsa.PrivateKey:
+		return privKey, nil
+	case ed25519.PrivateKey:
+		return privKey, nil
+	}
+	return nil, fmt.Errorf("unsupported key type for key %q", name)
+}
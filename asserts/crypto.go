@@ -31,25 +31,39 @@ import (
 	"io"
 	"time"
 
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/openpgp/packet"
 	"golang.org/x/crypto/sha3"
 )
 
+// Keys and signatures are encoded as base64 of a version byte followed
+// by version specific data, for v1 an OpenPGP packet (RSA keys and
+// signatures), for v2 raw Ed25519 keys and signatures.
 const (
 	maxEncodeLineLength = 76
 	v1                  = 0x1
+	v2                  = 0x2
 )
 
 var (
 	v1Header         = []byte{v1}
+	v2Header         = []byte{v2}
 	v1FixedTimestamp = time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
 )
 
 func encodeV1(data []byte) []byte {
+	return encodeVersioned(v1Header, data)
+}
+
+func encodeV2(data []byte) []byte {
+	return encodeVersioned(v2Header, data)
+}
+
+func encodeVersioned(header, data []byte) []byte {
 	buf := new(bytes.Buffer)
 	buf.Grow(base64.StdEncoding.EncodedLen(len(data) + 1))
 	enc := base64.NewEncoder(base64.StdEncoding, buf)
-	enc.Write(v1Header)
+	enc.Write(header)
 	enc.Write(data)
 	enc.Close()
 	flat := buf.Bytes()
@@ -75,6 +89,8 @@ func encodeV1(data []byte) []byte {
 }
 
 type keyEncoder interface {
+	// keyVersion returns the encoding format version of the key.
+	keyVersion() byte
 	keyEncode(w io.Writer) error
 }
 
@@ -84,50 +100,66 @@ func encodeKey(key keyEncoder, kind string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot encode %s: %v", kind, err)
 	}
-	return encodeV1(buf.Bytes()), nil
+	return encodeVersioned([]byte{key.keyVersion()}, buf.Bytes()), nil
 }
 
 type openpgpSigner interface {
 	sign(content []byte) (*packet.Signature, error)
 }
 
+type ed25519Signer interface {
+	signEd25519(content []byte) ([]byte, error)
+}
+
 func signContent(content []byte, privateKey PrivateKey) ([]byte, error) {
-	signer, ok := privateKey.(openpgpSigner)
-	if !ok {
-		panic(fmt.Errorf("not an internally supported PrivateKey: %T", privateKey))
-	}
+	switch signer := privateKey.(type) {
+	case openpgpSigner:
+		sig, err := signer.sign(content)
+		if err != nil {
+			return nil, err
+		}
 
-	sig, err := signer.sign(content)
-	if err != nil {
-		return nil, err
-	}
+		buf := new(bytes.Buffer)
+		err = sig.Serialize(buf)
+		if err != nil {
+			return nil, err
+		}
 
-	buf := new(bytes.Buffer)
-	err = sig.Serialize(buf)
-	if err != nil {
-		return nil, err
+		return encodeV1(buf.Bytes()), nil
+	case ed25519Signer:
+		sig, err := signer.signEd25519(content)
+		if err != nil {
+			return nil, err
+		}
+		return encodeV2(sig), nil
+	default:
+		panic(fmt.Errorf("not an internally supported PrivateKey: %T", privateKey))
 	}
-
-	return encodeV1(buf.Bytes()), nil
 }
 
-func decodeV1(b []byte, kind string) (packet.Packet, error) {
+// decodeVersioned decodes base64 data returning its format version
+// byte and the version specific data following it.
+func decodeVersioned(b []byte, kind string) (byte, []byte, error) {
 	if len(b) == 0 {
-		return nil, fmt.Errorf("cannot decode %s: no data", kind)
+		return 0, nil, fmt.Errorf("cannot decode %s: no data", kind)
 	}
 	buf := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
 	n, err := base64.StdEncoding.Decode(buf, b)
 	if err != nil {
-		return nil, fmt.Errorf("cannot decode %s: %v", kind, err)
+		return 0, nil, fmt.Errorf("cannot decode %s: %v", kind, err)
 	}
 	if n == 0 {
-		return nil, fmt.Errorf("cannot decode %s: base64 without data", kind)
+		return 0, nil, fmt.Errorf("cannot decode %s: base64 without data", kind)
 	}
 	buf = buf[:n]
-	if buf[0] != v1 {
-		return nil, fmt.Errorf("unsupported %s format version: %d", kind, buf[0])
+	if buf[0] != v1 && buf[0] != v2 {
+		return 0, nil, fmt.Errorf("unsupported %s format version: %d", kind, buf[0])
 	}
-	rd := bytes.NewReader(buf[1:])
+	return buf[0], buf[1:], nil
+}
+
+func decodeOpenPGPPacket(data []byte, kind string) (packet.Packet, error) {
+	rd := bytes.NewReader(data)
 	pkt, err := packet.Read(rd)
 	if err != nil {
 		return nil, fmt.Errorf("cannot decode %s: %v", kind, err)
@@ -138,8 +170,34 @@ func decodeV1(b []byte, kind string) (packet.Packet, error) {
 	return pkt, nil
 }
 
-func decodeSignature(signature []byte) (*packet.Signature, error) {
-	pkt, err := decodeV1(signature, "signature")
+// signature is a decoded assertion signature, either an
+// openpgpSignature or an ed25519Signature.
+type signature interface {
+	isSignature()
+}
+
+type openpgpSignature struct {
+	*packet.Signature
+}
+
+func (openpgpSignature) isSignature() {}
+
+type ed25519Signature []byte
+
+func (ed25519Signature) isSignature() {}
+
+func decodeSignature(encoded []byte) (signature, error) {
+	version, data, err := decodeVersioned(encoded, "signature")
+	if err != nil {
+		return nil, err
+	}
+	if version == v2 {
+		if len(data) != ed25519.SignatureSize {
+			return nil, fmt.Errorf("cannot decode signature: expected %d bytes Ed25519 signature, got %d", ed25519.SignatureSize, len(data))
+		}
+		return ed25519Signature(data), nil
+	}
+	pkt, err := decodeOpenPGPPacket(data, "signature")
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +205,7 @@ func decodeSignature(signature []byte) (*packet.Signature, error) {
 	if !ok {
 		return nil, fmt.Errorf("expected signature, got instead: %T", pkt)
 	}
-	return sig, nil
+	return openpgpSignature{sig}, nil
 }
 
 // PublicKey is the public part of a cryptographic private/public key pair.
@@ -156,7 +214,7 @@ type PublicKey interface {
 	ID() string
 
 	// verify verifies signature is valid for content using the key.
-	verify(content []byte, sig *packet.Signature) error
+	verify(content []byte, sig signature) error
 
 	keyEncoder
 }
@@ -170,10 +228,18 @@ func (opgPubKey *openpgpPubKey) ID() string {
 	return opgPubKey.sha3_384
 }
 
-func (opgPubKey *openpgpPubKey) verify(content []byte, sig *packet.Signature) error {
-	h := sig.Hash.New()
+func (opgPubKey *openpgpPubKey) verify(content []byte, sig signature) error {
+	opgSig, ok := sig.(openpgpSignature)
+	if !ok {
+		return fmt.Errorf("cannot verify a non OpenPGP signature with an OpenPGP key")
+	}
+	h := opgSig.Hash.New()
 	h.Write(content)
-	return opgPubKey.pubKey.VerifySignature(h, sig)
+	return opgPubKey.pubKey.VerifySignature(h, opgSig.Signature)
+}
+
+func (opgPubKey openpgpPubKey) keyVersion() byte {
+	return v1
 }
 
 func (opgPubKey openpgpPubKey) keyEncode(w io.Writer) error {
@@ -194,6 +260,78 @@ func newOpenPGPPubKey(intPubKey *packet.PublicKey) *openpgpPubKey {
 	return &openpgpPubKey{pubKey: intPubKey, sha3_384: sha3_384}
 }
 
+type ed25519PubKey struct {
+	pubKey   ed25519.PublicKey
+	sha3_384 string
+}
+
+func (edPubKey *ed25519PubKey) ID() string {
+	return edPubKey.sha3_384
+}
+
+func (edPubKey *ed25519PubKey) verify(content []byte, sig signature) error {
+	edSig, ok := sig.(ed25519Signature)
+	if !ok {
+		return fmt.Errorf("cannot verify a non Ed25519 signature with an Ed25519 key")
+	}
+	if !ed25519.Verify(edPubKey.pubKey, content, edSig) {
+		return fmt.Errorf("Ed25519 verification failure")
+	}
+	return nil
+}
+
+func (edPubKey *ed25519PubKey) keyVersion() byte {
+	return v2
+}
+
+func (edPubKey *ed25519PubKey) keyEncode(w io.Writer) error {
+	_, err := w.Write(edPubKey.pubKey)
+	return err
+}
+
+// Ed25519PublicKey returns a database useable public key out of ed25519.PublicKey.
+func Ed25519PublicKey(pubKey ed25519.PublicKey) PublicKey {
+	h := sha3.New384()
+	h.Write(v2Header)
+	h.Write(pubKey)
+	sha3_384, err := EncodeDigest(crypto.SHA3_384, h.Sum(nil))
+	if err != nil {
+		panic("internal error: cannot compute public key sha3-384")
+	}
+	return &ed25519PubKey{pubKey: pubKey, sha3_384: sha3_384}
+}
+
+// MinFormatForKey returns the minimum format iteration of an
+// assertion carrying or signed by the given public key.
+// Ed25519 keys were introduced with format 1 of the relevant
+// assertion types.
+func MinFormatForKey(pubKey PublicKey) int {
+	if _, ok := pubKey.(*ed25519PubKey); ok {
+		return 1
+	}
+	return 0
+}
+
+// checkSignatureFormat checks that an assertion with the given format
+// can carry the encoded signature, Ed25519 signatures require format 1
+// or greater. Malformed signatures are left to signature verification.
+func checkSignatureFormat(encodedSig []byte, format int) error {
+	version, _, err := decodeVersioned(encodedSig, "signature")
+	if err == nil && version == v2 && format < 1 {
+		return fmt.Errorf("Ed25519 signature requires format 1 or greater")
+	}
+	return nil
+}
+
+// checkKeyFormat checks that an assertion with the given format
+// can carry or be signed by pubKey.
+func checkKeyFormat(pubKey PublicKey, format int, what string) error {
+	if minFormat := MinFormatForKey(pubKey); format < minFormat {
+		return fmt.Errorf("%s is an Ed25519 key which requires format %d or greater", what, minFormat)
+	}
+	return nil
+}
+
 // RSAPublicKey returns a database useable public key out of rsa.PublicKey.
 func RSAPublicKey(pubKey *rsa.PublicKey) PublicKey {
 	intPubKey := packet.NewRSAPublicKey(v1FixedTimestamp, pubKey)
@@ -202,7 +340,17 @@ func RSAPublicKey(pubKey *rsa.PublicKey) PublicKey {
 
 // DecodePublicKey deserializes a public key.
 func DecodePublicKey(pubKey []byte) (PublicKey, error) {
-	pkt, err := decodeV1(pubKey, "public key")
+	version, data, err := decodeVersioned(pubKey, "public key")
+	if err != nil {
+		return nil, err
+	}
+	if version == v2 {
+		if len(data) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("cannot decode public key: expected %d bytes Ed25519 public key, got %d", ed25519.PublicKeySize, len(data))
+		}
+		return Ed25519PublicKey(ed25519.PublicKey(data)), nil
+	}
+	pkt, err := decodeOpenPGPPacket(data, "public key")
 	if err != nil {
 		return nil, err
 	}
@@ -238,6 +386,10 @@ func (opgPrivK openpgpPrivateKey) PublicKey() PublicKey {
 	return newOpenPGPPubKey(&opgPrivK.privk.PublicKey)
 }
 
+func (opgPrivK openpgpPrivateKey) keyVersion() byte {
+	return v1
+}
+
 func (opgPrivK openpgpPrivateKey) keyEncode(w io.Writer) error {
 	return opgPrivK.privk.Serialize(w)
 }
@@ -265,7 +417,17 @@ func (opgPrivK openpgpPrivateKey) sign(content []byte) (*packet.Signature, error
 }
 
 func decodePrivateKey(privKey []byte) (PrivateKey, error) {
-	pkt, err := decodeV1(privKey, "private key")
+	version, data, err := decodeVersioned(privKey, "private key")
+	if err != nil {
+		return nil, err
+	}
+	if version == v2 {
+		if len(data) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("cannot decode private key: expected %d bytes Ed25519 private key, got %d", ed25519.PrivateKeySize, len(data))
+		}
+		return Ed25519PrivateKey(ed25519.PrivateKey(data)), nil
+	}
+	pkt, err := decodeOpenPGPPacket(data, "private key")
 	if err != nil {
 		return nil, err
 	}
@@ -294,6 +456,41 @@ func GenerateKey() (PrivateKey, error) {
 	return RSAPrivateKey(priv), nil
 }
 
+type ed25519PrivateKey struct {
+	privk ed25519.PrivateKey
+}
+
+func (edPrivK ed25519PrivateKey) PublicKey() PublicKey {
+	return Ed25519PublicKey(edPrivK.privk.Public().(ed25519.PublicKey))
+}
+
+func (edPrivK ed25519PrivateKey) keyVersion() byte {
+	return v2
+}
+
+func (edPrivK ed25519PrivateKey) keyEncode(w io.Writer) error {
+	_, err := w.Write(edPrivK.privk)
+	return err
+}
+
+func (edPrivK ed25519PrivateKey) signEd25519(content []byte) ([]byte, error) {
+	return ed25519.Sign(edPrivK.privk, content), nil
+}
+
+// Ed25519PrivateKey returns a PrivateKey for database use out of a ed25519.PrivateKey.
+func Ed25519PrivateKey(privk ed25519.PrivateKey) PrivateKey {
+	return ed25519PrivateKey{privk}
+}
+
+// GenerateEd25519Key generates an Ed25519 private/public key pair.
+func GenerateEd25519Key() (PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return Ed25519PrivateKey(priv), nil
+}
+
 func encodePrivateKey(privKey PrivateKey) ([]byte, error) {
 	return encodeKey(privKey, "private key")
 }
@@ -359,6 +556,10 @@ func (expk *extPGPPrivateKey) PublicKey() PublicKey {
 	return expk.pubKey
 }
 
+func (expk *extPGPPrivateKey) keyVersion() byte {
+	return v1
+}
+
 func (expk *extPGPPrivateKey) keyEncode(w io.Writer) error {
 	return fmt.Errorf("cannot access external private key to encode it")
 }
@@ -389,7 +590,7 @@ func (expk *extPGPPrivateKey) sign(content []byte) (*packet.Signature, error) {
 		return nil, fmt.Errorf(badSig + "expected SHA512 digest")
 	}
 
-	err = expk.pubKey.verify(content, sig)
+	err = expk.pubKey.verify(content, openpgpSignature{sig})
 	if err != nil {
 		return nil, fmt.Errorf(badSig+"it does not verify: %v", err)
 	}
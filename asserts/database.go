@@ -45,6 +45,16 @@ type Backstore interface {
 	Search(assertType *AssertionType, headers map[string]string, foundCb func(Assertion), maxFormat int) error
 }
 
+// A RevertingBackstore is a Backstore that can also revert the
+// storing of an assertion, see Database.AddMany.
+type RevertingBackstore interface {
+	Backstore
+	// PutReverting stores an assertion like Put, returning also a
+	// function that reverts what is stored under its primary key
+	// headers to how it was before.
+	PutReverting(assertType *AssertionType, assert Assertion) (revert func() error, err error)
+}
+
 type nullBackstore struct{}
 
 func (nbs nullBackstore) Put(t *AssertionType, a Assertion) error {
@@ -222,6 +232,24 @@ func OpenDatabase(cfg *DatabaseConfig) (*Database, error) {
 	}, nil
 }
 
+// WithStackedBackstore returns a new database that adds assertions
+// only to the given backstore but finds them in it and in the backstores
+// of the original database, and cross-checks against all of them.
+// This is useful to check a set of assertions without adding them to
+// the original database.
+func (db *Database) WithStackedBackstore(backstore Backstore) *Database {
+	backstores := make([]Backstore, 0, len(db.backstores)+1)
+	backstores = append(backstores, db.backstores...)
+	backstores = append(backstores, backstore)
+	return &Database{
+		bs:         backstore,
+		keypairMgr: db.keypairMgr,
+		trusted:    db.trusted,
+		backstores: backstores,
+		checkers:   db.checkers,
+	}
+}
+
 // ImportKey stores the given private/public key pair.
 func (db *Database) ImportKey(privKey PrivateKey) error {
 	return db.keypairMgr.Put(privKey)
@@ -329,6 +357,43 @@ func (db *Database) Check(assert Assertion) error {
 // Add persists the assertion after ensuring it is properly signed and consistent with all the stored knowledge.
 // It will return an error when trying to add an older revision of the assertion than the one currently stored.
 func (db *Database) Add(assert Assertion) error {
+	if err := db.checkForAdd(assert); err != nil {
+		return err
+	}
+	return db.bs.Put(assert.Type(), assert)
+}
+
+// AddMany adds the given assertions in order to the database after
+// checking each like Add. Either all of them are added or none: if
+// adding one fails, the ones already added are reverted and the error
+// is returned. It needs a RevertingBackstore.
+func (db *Database) AddMany(assertions []Assertion) error {
+	rbs, ok := db.bs.(RevertingBackstore)
+	if !ok {
+		return fmt.Errorf("cannot add many assertions atomically with a backstore that cannot revert them")
+	}
+	reverts := make([]func() error, 0, len(assertions))
+	for _, a := range assertions {
+		err := db.checkForAdd(a)
+		var revert func() error
+		if err == nil {
+			revert, err = rbs.PutReverting(a.Type(), a)
+		}
+		if err != nil {
+			err = fmt.Errorf("cannot add %s: %v", a.Ref(), err)
+			for i := len(reverts) - 1; i >= 0; i-- {
+				if revertErr := reverts[i](); revertErr != nil {
+					return fmt.Errorf("%v (and cannot revert the assertions already added: %v)", err, revertErr)
+				}
+			}
+			return err
+		}
+		reverts = append(reverts, revert)
+	}
+	return nil
+}
+
+func (db *Database) checkForAdd(assert Assertion) error {
 	ref := assert.Ref()
 
 	if len(ref.PrimaryKey) == 0 {
@@ -361,7 +426,7 @@ func (db *Database) Add(assert Assertion) error {
 		return fmt.Errorf("cannot add %q assertion with primary key clashing with a trusted assertion: %v", ref.Type.Name, ref.PrimaryKey)
 	}
 
-	return db.bs.Put(ref.Type, assert)
+	return nil
 }
 
 func searchMatch(assert Assertion, expectedHeaders map[string]string) bool {
@@ -28,6 +28,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -184,6 +185,33 @@ func (dbs *databaseSuite) TestPublicKey(c *C) {
 	c.Check(encHash, DeepEquals, testPrivKey1SHA3_384)
 }
 
+func (dbs *databaseSuite) TestPublicKeyEd25519(c *C) {
+	pk, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+	keyID := pk.PublicKey().ID()
+	err = dbs.db.ImportKey(pk)
+	c.Assert(err, IsNil)
+
+	pubk, err := dbs.db.PublicKey(keyID)
+	c.Assert(err, IsNil)
+	c.Check(pubk.ID(), Equals, keyID)
+
+	encoded, err := asserts.EncodePublicKey(pubk)
+	c.Assert(err, IsNil)
+	data, err := base64.StdEncoding.DecodeString(string(encoded))
+	c.Assert(err, IsNil)
+	c.Check(data[0], Equals, uint8(2)) // v2
+	c.Check(data, HasLen, 1+32)
+	// hash of blob content == hash of key
+	h384 := sha3.Sum384(data)
+	encHash := base64.RawURLEncoding.EncodeToString(h384[:])
+	c.Check(encHash, Equals, keyID)
+
+	decoded, err := asserts.DecodePublicKey(encoded)
+	c.Assert(err, IsNil)
+	c.Check(decoded.ID(), Equals, keyID)
+}
+
 func (dbs *databaseSuite) TestPublicKeyNotFound(c *C) {
 	pk := testPrivKey1
 	keyID := pk.PublicKey().ID()
@@ -359,6 +387,41 @@ func (safs *signAddFindSuite) TestSign(c *C) {
 	c.Check(err, IsNil)
 }
 
+func (safs *signAddFindSuite) TestSignEd25519(c *C) {
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+	err = safs.signingDB.ImportKey(edKey)
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "a",
+	}
+	a1, err := safs.signingDB.Sign(asserts.TestOnlyType, headers, nil, edKey.PublicKey().ID())
+	c.Assert(err, IsNil)
+
+	_, encSig := a1.Signature()
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encSig)))
+	c.Assert(err, IsNil)
+	c.Check(data[0], Equals, uint8(2)) // v2
+	c.Check(data, HasLen, 1+64)
+
+	cfg := &asserts.DatabaseConfig{
+		Trusted: []asserts.Assertion{
+			asserts.BootstrapAccountForTest("canonical"),
+			asserts.BootstrapAccountKeyForTest("canonical", edKey.PublicKey()),
+		},
+	}
+	db, err := asserts.OpenDatabase(cfg)
+	c.Assert(err, IsNil)
+
+	err = db.Check(a1)
+	c.Check(err, IsNil)
+
+	err = asserts.SignatureCheck(a1, testPrivKey0.PublicKey())
+	c.Check(err, ErrorMatches, "failed signature verification: cannot verify a non OpenPGP signature with an OpenPGP key")
+}
+
 func (safs *signAddFindSuite) TestSignEmptyKeyID(c *C) {
 	headers := map[string]interface{}{
 		"authority-id": "canonical",
@@ -867,6 +930,132 @@ func (safs *signAddFindSuite) TestFindMaxFormat(c *C) {
 	c.Check(err, ErrorMatches, `cannot find "test-only" assertions for format 3 higher than supported format 1`)
 }
 
+func (safs *signAddFindSuite) TestWithStackedBackstore(c *C) {
+	headers := map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "one",
+	}
+	a1, err := safs.signingDB.Sign(asserts.TestOnlyType, headers, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+
+	err = safs.db.Add(a1)
+	c.Assert(err, IsNil)
+
+	headers = map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "two",
+	}
+	a2, err := safs.signingDB.Sign(asserts.TestOnlyType, headers, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+
+	bs := asserts.NewMemoryBackstore()
+	stacked := safs.db.WithStackedBackstore(bs)
+
+	err = stacked.Add(a2)
+	c.Assert(err, IsNil)
+
+	// both are found in the stacked database
+	_, err = stacked.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "one",
+	})
+	c.Check(err, IsNil)
+	_, err = stacked.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "two",
+	})
+	c.Check(err, IsNil)
+
+	// the original database is untouched
+	_, err = safs.db.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "two",
+	})
+	c.Check(err, Equals, asserts.ErrNotFound)
+
+	// trusted assertions are still found
+	_, err = stacked.Find(asserts.AccountType, map[string]string{
+		"account-id": "canonical",
+	})
+	c.Check(err, IsNil)
+}
+
+func (safs *signAddFindSuite) testAddMany(c *C, db *asserts.Database) {
+	headers := map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "a",
+	}
+	a0, err := safs.signingDB.Sign(asserts.TestOnlyType, headers, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+	err = db.Add(a0)
+	c.Assert(err, IsNil)
+
+	headers["revision"] = "1"
+	a1, err := safs.signingDB.Sign(asserts.TestOnlyType, headers, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+	b, err := safs.signingDB.Sign(asserts.TestOnlyType, map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "b",
+	}, nil, safs.signingKeyID)
+	c.Assert(err, IsNil)
+
+	// signed with an untrusted key
+	err = safs.signingDB.ImportKey(testPrivKey1)
+	c.Assert(err, IsNil)
+	bad, err := safs.signingDB.Sign(asserts.TestOnlyType, map[string]interface{}{
+		"authority-id": "canonical",
+		"primary-key":  "c",
+	}, nil, testPrivKey1.PublicKey().ID())
+	c.Assert(err, IsNil)
+
+	err = db.AddMany([]asserts.Assertion{a1, b, bad})
+	c.Assert(err, ErrorMatches, `cannot add test-only \(c\): no matching public key .*`)
+
+	// the assertions added before the failure were reverted
+	a, err := db.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "a",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 0)
+	_, err = db.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "b",
+	})
+	c.Check(err, Equals, asserts.ErrNotFound)
+
+	err = db.AddMany([]asserts.Assertion{a1, b})
+	c.Assert(err, IsNil)
+	a, err = db.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "a",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 1)
+	_, err = db.Find(asserts.TestOnlyType, map[string]string{
+		"primary-key": "b",
+	})
+	c.Check(err, IsNil)
+}
+
+func (safs *signAddFindSuite) TestAddManyFilesystemBackstore(c *C) {
+	safs.testAddMany(c, safs.db)
+}
+
+func (safs *signAddFindSuite) TestAddManyMemoryBackstore(c *C) {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted: []asserts.Assertion{
+			asserts.BootstrapAccountForTest("canonical"),
+			asserts.BootstrapAccountKeyForTest("canonical", testPrivKey0.PublicKey()),
+		},
+	})
+	c.Assert(err, IsNil)
+	safs.testAddMany(c, db)
+}
+
+func (safs *signAddFindSuite) TestAddManyNeedsRevertingBackstore(c *C) {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{})
+	c.Assert(err, IsNil)
+
+	err = db.AddMany(nil)
+	c.Check(err, ErrorMatches, "cannot add many assertions atomically with a backstore that cannot revert them")
+}
+
 type revisionErrorSuite struct{}
 
 func (res *revisionErrorSuite) TestErrorText(c *C) {
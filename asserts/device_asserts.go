@@ -277,6 +277,12 @@ func assembleSerial(assert assertionBase) (Assertion, error) {
 	if keyID != pubKey.ID() {
 		return nil, fmt.Errorf("device key does not match provided key id")
 	}
+	if err := checkKeyFormat(pubKey, assert.Format(), "device key"); err != nil {
+		return nil, err
+	}
+	if err := checkSignatureFormat(assert.signature, assert.Format()); err != nil {
+		return nil, err
+	}
 
 	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
 	if err != nil {
@@ -355,6 +361,9 @@ func assembleSerialRequest(assert assertionBase) (Assertion, error) {
 	if pubKey.ID() != assert.SignKeyID() {
 		return nil, fmt.Errorf("device key does not match included signing key id")
 	}
+	if err := checkKeyFormat(pubKey, assert.Format(), "device key"); err != nil {
+		return nil, err
+	}
 
 	// ignore extra headers and non-empty body for future compatibility
 	return &SerialRequest{
@@ -412,6 +421,10 @@ func assembleDeviceSessionRequest(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	if err := checkSignatureFormat(assert.signature, assert.Format()); err != nil {
+		return nil, err
+	}
+
 	// ignore extra headers and non-empty body for future compatibility
 	return &DeviceSessionRequest{
 		assertionBase: assert,
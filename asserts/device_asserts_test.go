@@ -442,6 +442,118 @@ func (ss *serialSuite) TestDeviceSessionRequest(c *C) {
 	c.Check(sessReq2.Timestamp().Equal(ts), Equals, true)
 }
 
+func (ss *serialSuite) TestSerialEd25519DeviceKey(c *C) {
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+	encodedPubKey, err := asserts.EncodePublicKey(edKey.PublicKey())
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":        "brand-id1",
+		"brand-id":            "brand-id1",
+		"model":               "baz-3000",
+		"serial":              "2700",
+		"device-key":          string(encodedPubKey),
+		"device-key-sha3-384": edKey.PublicKey().ID(),
+		"timestamp":           ss.ts.Format(time.RFC3339),
+	}
+	_, err = asserts.AssembleAndSignInTest(asserts.SerialType, headers, nil, testPrivKey0)
+	c.Check(err, ErrorMatches, "cannot assemble assertion serial: device key is an Ed25519 key which requires format 1 or greater")
+
+	headers["format"] = "1"
+	a, err := asserts.AssembleAndSignInTest(asserts.SerialType, headers, nil, testPrivKey0)
+	c.Assert(err, IsNil)
+
+	// roundtrip
+	a, err = asserts.Decode(asserts.Encode(a))
+	c.Assert(err, IsNil)
+	serial := a.(*asserts.Serial)
+	c.Check(serial.Format(), Equals, 1)
+	c.Check(serial.DeviceKey().ID(), Equals, edKey.PublicKey().ID())
+}
+
+func (ss *serialSuite) TestSerialEd25519Signature(c *C) {
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":        "brand-id1",
+		"brand-id":            "brand-id1",
+		"model":               "baz-3000",
+		"serial":              "2700",
+		"device-key":          ss.encodedDevKey,
+		"device-key-sha3-384": ss.deviceKey.PublicKey().ID(),
+		"timestamp":           ss.ts.Format(time.RFC3339),
+	}
+	_, err = asserts.AssembleAndSignInTest(asserts.SerialType, headers, nil, edKey)
+	c.Check(err, ErrorMatches, "cannot assemble assertion serial: Ed25519 signature requires format 1 or greater")
+
+	headers["format"] = "1"
+	a, err := asserts.AssembleAndSignInTest(asserts.SerialType, headers, nil, edKey)
+	c.Assert(err, IsNil)
+	err = asserts.SignatureCheck(a, edKey.PublicKey())
+	c.Check(err, IsNil)
+}
+
+func (ss *serialSuite) TestSerialRequestEd25519DeviceKey(c *C) {
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+	encodedPubKey, err := asserts.EncodePublicKey(edKey.PublicKey())
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"brand-id":   "brand-id1",
+		"model":      "baz-3000",
+		"device-key": string(encodedPubKey),
+		"request-id": "REQID",
+	}
+	_, err = asserts.SignWithoutAuthority(asserts.SerialRequestType, headers, nil, edKey)
+	c.Check(err, ErrorMatches, "cannot assemble assertion serial-request: device key is an Ed25519 key which requires format 1 or greater")
+
+	headers["format"] = "1"
+	sreq, err := asserts.SignWithoutAuthority(asserts.SerialRequestType, headers, nil, edKey)
+	c.Assert(err, IsNil)
+
+	// roundtrip
+	a, err := asserts.Decode(asserts.Encode(sreq))
+	c.Assert(err, IsNil)
+	sreq2 := a.(*asserts.SerialRequest)
+	c.Check(sreq2.Format(), Equals, 1)
+	err = asserts.SignatureCheck(sreq2, sreq2.DeviceKey())
+	c.Check(err, IsNil)
+}
+
+func (ss *serialSuite) TestDeviceSessionRequestEd25519(c *C) {
+	edKey, err := asserts.GenerateEd25519Key()
+	c.Assert(err, IsNil)
+
+	ts := time.Now().UTC().Round(time.Second)
+	headers := map[string]interface{}{
+		"brand-id":  "brand-id1",
+		"model":     "baz-3000",
+		"serial":    "99990",
+		"nonce":     "NONCE",
+		"timestamp": ts.Format(time.RFC3339),
+	}
+	_, err = asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, headers, nil, edKey)
+	c.Check(err, ErrorMatches, "cannot assemble assertion device-session-request: Ed25519 signature requires format 1 or greater")
+
+	headers["format"] = "1"
+	sessReq, err := asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, headers, nil, edKey)
+	c.Assert(err, IsNil)
+
+	// roundtrip
+	a, err := asserts.Decode(asserts.Encode(sessReq))
+	c.Assert(err, IsNil)
+	c.Check(a.Format(), Equals, 1)
+
+	// standalone signature check
+	err = asserts.SignatureCheck(a, edKey.PublicKey())
+	c.Check(err, IsNil)
+	err = asserts.SignatureCheck(a, ss.deviceKey.PublicKey())
+	c.Check(err, ErrorMatches, "failed signature verification: cannot verify a non OpenPGP signature with an OpenPGP key")
+}
+
 func (ss *serialSuite) TestDeviceSessionRequestDecodeInvalid(c *C) {
 	tsLine := "timestamp: " + time.Now().Format(time.RFC3339) + "\n"
 	encoded := "type: device-session-request\n" +
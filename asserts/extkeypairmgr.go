@@ -0,0 +1,345 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// ExternalKeypairManager is a key pair manager that delegates to an
+// external signer helper program, for example one fronting an HSM via
+// PKCS#11. Private key material never leaves the helper.
+//
+// The helper is invoked once per operation as "HELPER OPERATION", it is
+// given a JSON request object on its standard input and must print a
+// JSON response object on its standard output and exit with status 0.
+// On failure it should exit with a non-zero status and describe the
+// problem on its standard error. Binary values are carried as base64
+// encoded JSON strings. The operations are:
+//
+// features, with request {}, responds with {"signing": [MECHANISM...],
+// "public-keys": [FORMAT...]}. The helper must support the "DER" public
+// key format and at least one of the "RSA-PKCS" and "Ed25519" signing
+// mechanisms.
+//
+// key-names, with request {}, responds with {"key-names": [NAME...]}.
+//
+// get-public-key, with request {"key-name": NAME, "format": "DER"},
+// responds with {"public-key": PUBKEY} where PUBKEY is the PKIX, ASN.1
+// DER encoded public key. Only RSA keys of at least 4096 bits and
+// Ed25519 keys are usable.
+//
+// sign, with request {"key-name": NAME, "mechanism": "RSA-PKCS",
+// "hash": "SHA512", "digest": DIGEST}, responds with {"signature": SIG}
+// where SIG is the RSASSA-PKCS1-v1_5 signature of the SHA512 DIGEST.
+// For Ed25519 keys the request is instead {"key-name": NAME,
+// "mechanism": "Ed25519", "message": MESSAGE} and SIG is the Ed25519
+// signature of the MESSAGE itself.
+type ExternalKeypairManager struct {
+	keyMgrPath string
+	signing    map[string]bool
+	cache      map[string]PrivateKey
+}
+
+// NewExternalKeypairManager creates a new key pair manager using the external signer helper at keyMgrPath.
+func NewExternalKeypairManager(keyMgrPath string) (*ExternalKeypairManager, error) {
+	em := &ExternalKeypairManager{
+		keyMgrPath: keyMgrPath,
+		cache:      make(map[string]PrivateKey),
+	}
+	if err := em.checkFeatures(); err != nil {
+		return nil, err
+	}
+	return em, nil
+}
+
+func (em *ExternalKeypairManager) keyMgr(op string, req interface{}, resp interface{}) error {
+	if req == nil {
+		req = struct{}{}
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(em.keyMgrPath, op)
+	var outBuf bytes.Buffer
+	var errBuf bytes.Buffer
+	cmd.Stdin = bytes.NewBuffer(in)
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("external keypair manager %q %s failed: %v (%q)", em.keyMgrPath, op, err, errBuf.Bytes())
+	}
+	if err := json.Unmarshal(outBuf.Bytes(), resp); err != nil {
+		return fmt.Errorf("cannot decode external keypair manager %q %s response: %v", em.keyMgrPath, op, err)
+	}
+	return nil
+}
+
+func (em *ExternalKeypairManager) checkFeatures() error {
+	var feats struct {
+		Signing    []string `json:"signing"`
+		PublicKeys []string `json:"public-keys"`
+	}
+	if err := em.keyMgr("features", nil, &feats); err != nil {
+		return err
+	}
+	em.signing = make(map[string]bool, len(feats.Signing))
+	for _, mech := range feats.Signing {
+		if mech == "RSA-PKCS" || mech == "Ed25519" {
+			em.signing[mech] = true
+		}
+	}
+	if len(em.signing) == 0 {
+		return fmt.Errorf("external keypair manager %q signing mechanisms are unsupported: %s", em.keyMgrPath, strings.Join(feats.Signing, ", "))
+	}
+	for _, format := range feats.PublicKeys {
+		if format == "DER" {
+			return nil
+		}
+	}
+	return fmt.Errorf("external keypair manager %q does not support public keys export in DER format", em.keyMgrPath)
+}
+
+func (em *ExternalKeypairManager) keyNames() ([]string, error) {
+	var resp struct {
+		KeyNames []string `json:"key-names"`
+	}
+	if err := em.keyMgr("key-names", nil, &resp); err != nil {
+		return nil, fmt.Errorf("cannot get all external keypair manager key names: %v", err)
+	}
+	return resp.KeyNames, nil
+}
+
+func (em *ExternalKeypairManager) loadKey(name string) (PrivateKey, error) {
+	if privKey, ok := em.cache[name]; ok {
+		return privKey, nil
+	}
+	req := map[string]string{
+		"key-name": name,
+		"format":   "DER",
+	}
+	var resp struct {
+		PublicKey []byte `json:"public-key"`
+	}
+	if err := em.keyMgr("get-public-key", req, &resp); err != nil {
+		return nil, fmt.Errorf("cannot get external keypair manager key %q public key: %v", name, err)
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode external key %q: %v", name, err)
+	}
+	var privKey PrivateKey
+	switch pubKey := pubKey.(type) {
+	case *rsa.PublicKey:
+		if !em.signing["RSA-PKCS"] {
+			return nil, fmt.Errorf("external keypair manager cannot sign with RSA key %q", name)
+		}
+		if bitLen := pubKey.N.BitLen(); bitLen < 4096 {
+			return nil, fmt.Errorf("external key %q: signing needs at least a 4096 bits key, got %d", name, bitLen)
+		}
+		signer := &extRSASigner{
+			pubKey: pubKey,
+			doSign: func(digest []byte) ([]byte, error) {
+				return em.sign(name, "RSA-PKCS", map[string]interface{}{
+					"hash":   "SHA512",
+					"digest": digest,
+				})
+			},
+		}
+		privKey = &extRSAPrivateKey{
+			openpgpPrivateKey: openpgpPrivateKey{packet.NewSignerPrivateKey(v1FixedTimestamp, signer)},
+		}
+	case ed25519.PublicKey:
+		if !em.signing["Ed25519"] {
+			return nil, fmt.Errorf("external keypair manager cannot sign with Ed25519 key %q", name)
+		}
+		privKey = &extEd25519PrivateKey{
+			pubKey: Ed25519PublicKey(pubKey),
+			doSign: func(content []byte) ([]byte, error) {
+				return em.sign(name, "Ed25519", map[string]interface{}{
+					"message": content,
+				})
+			},
+		}
+	default:
+		return nil, fmt.Errorf("cannot use external key %q: unsupported key type %T", name, pubKey)
+	}
+	em.cache[name] = privKey
+	return privKey, nil
+}
+
+func (em *ExternalKeypairManager) sign(name, mechanism string, req map[string]interface{}) ([]byte, error) {
+	req["key-name"] = name
+	req["mechanism"] = mechanism
+	var resp struct {
+		Signature []byte `json:"signature"`
+	}
+	if err := em.keyMgr("sign", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// Walk iterates over all the keys of the external keypair manager calling the provided callback with their names until this returns an error. The fingerprint argument is always empty.
+func (em *ExternalKeypairManager) Walk(consider func(privk PrivateKey, fingerprint string, uid string) error) error {
+	names, err := em.keyNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		privKey, err := em.loadKey(name)
+		if err != nil {
+			return err
+		}
+		if err := consider(privKey, "", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (em *ExternalKeypairManager) Put(privKey PrivateKey) error {
+	return fmt.Errorf("cannot import private key into external keypair manager")
+}
+
+func (em *ExternalKeypairManager) Get(keyID string) (PrivateKey, error) {
+	stop := errors.New("stop marker")
+	var hit PrivateKey
+	match := func(privk PrivateKey, fpr string, uid string) error {
+		if privk.PublicKey().ID() == keyID {
+			hit = privk
+			return stop
+		}
+		return nil
+	}
+	err := em.Walk(match)
+	if err == stop {
+		return hit, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("cannot find external key %q", keyID)
+}
+
+// GetByName looks up a private key by name and returns it.
+func (em *ExternalKeypairManager) GetByName(name string) (PrivateKey, error) {
+	names, err := em.keyNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, cand := range names {
+		if cand == name {
+			return em.loadKey(name)
+		}
+	}
+	return nil, fmt.Errorf("cannot find external key named %q", name)
+}
+
+// Export returns the encoded text of the named public key.
+func (em *ExternalKeypairManager) Export(name string) ([]byte, error) {
+	privKey, err := em.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return EncodePublicKey(privKey.PublicKey())
+}
+
+// extRSASigner is a crypto.Signer producing RSA PKCS#1 v1.5 signatures
+// of SHA512 digests through an external keypair manager.
+type extRSASigner struct {
+	pubKey *rsa.PublicKey
+	doSign func(digest []byte) ([]byte, error)
+}
+
+func (signer *extRSASigner) Public() crypto.PublicKey {
+	return signer.pubKey
+}
+
+func (signer *extRSASigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA512 {
+		return nil, fmt.Errorf("cannot sign with external key: expected SHA512 digest")
+	}
+	return signer.doSign(digest)
+}
+
+type extRSAPrivateKey struct {
+	openpgpPrivateKey
+}
+
+func (expk *extRSAPrivateKey) keyEncode(w io.Writer) error {
+	return fmt.Errorf("cannot access external private key to encode it")
+}
+
+func (expk *extRSAPrivateKey) sign(content []byte) (*packet.Signature, error) {
+	sig, err := expk.openpgpPrivateKey.sign(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := expk.PublicKey().verify(content, openpgpSignature{sig}); err != nil {
+		return nil, fmt.Errorf("bad external keypair manager produced signature: it does not verify: %v", err)
+	}
+	return sig, nil
+}
+
+type extEd25519PrivateKey struct {
+	pubKey PublicKey
+	doSign func(content []byte) ([]byte, error)
+}
+
+func (expk *extEd25519PrivateKey) PublicKey() PublicKey {
+	return expk.pubKey
+}
+
+func (expk *extEd25519PrivateKey) keyVersion() byte {
+	return v2
+}
+
+func (expk *extEd25519PrivateKey) keyEncode(w io.Writer) error {
+	return fmt.Errorf("cannot access external private key to encode it")
+}
+
+func (expk *extEd25519PrivateKey) signEd25519(content []byte) ([]byte, error) {
+	sig, err := expk.doSign(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("bad external keypair manager produced signature: expected %d bytes, got %d", ed25519.SignatureSize, len(sig))
+	}
+	if err := expk.pubKey.verify(content, ed25519Signature(sig)); err != nil {
+		return nil, fmt.Errorf("bad external keypair manager produced signature: it does not verify: %v", err)
+	}
+	return sig, nil
+}
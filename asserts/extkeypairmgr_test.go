@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+func init() {
+	// the test binary doubles as the reference external keypair
+	// manager helper
+	if os.Getenv(assertstest.ExtKeypairManagerKeysEnv) != "" {
+		os.Exit(assertstest.ExtKeypairManagerHelperMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+}
+
+type extKeypairMgrSuite struct {
+	keysDir string
+	rsaKey  asserts.PrivateKey
+	edKey   ed25519.PrivateKey
+}
+
+var _ = Suite(&extKeypairMgrSuite{})
+
+func (ekms *extKeypairMgrSuite) SetUpTest(c *C) {
+	ekms.keysDir = c.MkDir()
+	os.Setenv(assertstest.ExtKeypairManagerKeysEnv, ekms.keysDir)
+
+	var rsaPrivKey *rsa.PrivateKey
+	ekms.rsaKey, rsaPrivKey = assertstest.ReadPrivKey(assertstest.DevKey)
+	err := assertstest.PutExtKeypairManagerKey(ekms.keysDir, "default", rsaPrivKey)
+	c.Assert(err, IsNil)
+
+	_, ekms.edKey, err = ed25519.GenerateKey(rand.Reader)
+	c.Assert(err, IsNil)
+	err = assertstest.PutExtKeypairManagerKey(ekms.keysDir, "ed-key", ekms.edKey)
+	c.Assert(err, IsNil)
+}
+
+func (ekms *extKeypairMgrSuite) TearDownTest(c *C) {
+	os.Unsetenv(assertstest.ExtKeypairManagerKeysEnv)
+}
+
+func (ekms *extKeypairMgrSuite) newManager(c *C) *asserts.ExternalKeypairManager {
+	em, err := asserts.NewExternalKeypairManager(os.Args[0])
+	c.Assert(err, IsNil)
+	return em
+}
+
+func (ekms *extKeypairMgrSuite) TestNewFailure(c *C) {
+	_, err := asserts.NewExternalKeypairManager("/bin/false")
+	c.Check(err, ErrorMatches, `external keypair manager "/bin/false" features failed: .*`)
+}
+
+func (ekms *extKeypairMgrSuite) TestWalk(c *C) {
+	em := ekms.newManager(c)
+
+	edKeyID := asserts.Ed25519PrivateKey(ekms.edKey).PublicKey().ID()
+	found := map[string]string{}
+	err := em.Walk(func(privk asserts.PrivateKey, fpr string, uid string) error {
+		c.Check(fpr, Equals, "")
+		found[uid] = privk.PublicKey().ID()
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(found, DeepEquals, map[string]string{
+		"default": assertstest.DevKeyID,
+		"ed-key":  edKeyID,
+	})
+}
+
+func (ekms *extKeypairMgrSuite) TestGetAndGetByName(c *C) {
+	em := ekms.newManager(c)
+
+	privKey, err := em.Get(assertstest.DevKeyID)
+	c.Assert(err, IsNil)
+	c.Check(privKey.PublicKey().ID(), Equals, assertstest.DevKeyID)
+
+	privKey, err = em.GetByName("default")
+	c.Assert(err, IsNil)
+	c.Check(privKey.PublicKey().ID(), Equals, assertstest.DevKeyID)
+
+	_, err = em.Get("ffffffffffffffff")
+	c.Check(err, ErrorMatches, `cannot find external key "ffffffffffffffff"`)
+	_, err = em.GetByName("missing")
+	c.Check(err, ErrorMatches, `cannot find external key named "missing"`)
+
+	err = em.Put(ekms.rsaKey)
+	c.Check(err, ErrorMatches, "cannot import private key into external keypair manager")
+}
+
+func (ekms *extKeypairMgrSuite) TestExport(c *C) {
+	em := ekms.newManager(c)
+
+	encoded, err := em.Export("default")
+	c.Assert(err, IsNil)
+	expected, err := asserts.EncodePublicKey(ekms.rsaKey.PublicKey())
+	c.Assert(err, IsNil)
+	c.Check(encoded, DeepEquals, expected)
+
+	encoded, err = em.Export("ed-key")
+	c.Assert(err, IsNil)
+	expected, err = asserts.EncodePublicKey(asserts.Ed25519PrivateKey(ekms.edKey).PublicKey())
+	c.Assert(err, IsNil)
+	c.Check(encoded, DeepEquals, expected)
+}
+
+func (ekms *extKeypairMgrSuite) TestKeyTooShort(c *C) {
+	pk, err := rsa.GenerateKey(rand.Reader, 1024)
+	c.Assert(err, IsNil)
+	err = assertstest.PutExtKeypairManagerKey(ekms.keysDir, "short", pk)
+	c.Assert(err, IsNil)
+
+	em := ekms.newManager(c)
+	_, err = em.GetByName("short")
+	c.Check(err, ErrorMatches, `external key "short": signing needs at least a 4096 bits key, got 1024`)
+}
+
+func (ekms *extKeypairMgrSuite) TestUseInSigning(c *C) {
+	em := ekms.newManager(c)
+
+	store := assertstest.NewStoreStack("trusted", testPrivKey0, testPrivKey1)
+	devAcct := assertstest.NewAccount(store, "devel1", map[string]interface{}{
+		"account-id": "dev1-id",
+	}, "")
+	devAccKey := assertstest.NewAccountKey(store, devAcct, nil, ekms.rsaKey.PublicKey(), "")
+	edPubKey := asserts.Ed25519PrivateKey(ekms.edKey).PublicKey()
+	devEdAccKey := assertstest.NewAccountKey(store, devAcct, map[string]interface{}{
+		"name":   "ed-key",
+		"format": "1",
+	}, edPubKey, "")
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: em,
+	})
+	c.Assert(err, IsNil)
+
+	checkDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   store.Trusted,
+	})
+	c.Assert(err, IsNil)
+	for _, a := range []asserts.Assertion{store.StoreAccountKey(""), devAcct, devAccKey, devEdAccKey} {
+		err = checkDB.Add(a)
+		c.Assert(err, IsNil)
+	}
+
+	for _, keyID := range []string{assertstest.DevKeyID, edPubKey.ID()} {
+		headers := map[string]interface{}{
+			"authority-id":  "dev1-id",
+			"snap-sha3-384": blobSHA3_384,
+			"snap-id":       "snap-id-1",
+			"grade":         "devel",
+			"snap-size":     "1025",
+			"timestamp":     time.Now().Format(time.RFC3339),
+		}
+		snapBuild, err := signDB.Sign(asserts.SnapBuildType, headers, nil, keyID)
+		c.Assert(err, IsNil)
+
+		err = checkDB.Check(snapBuild)
+		c.Check(err, IsNil)
+	}
+}
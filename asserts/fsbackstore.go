@@ -139,6 +139,60 @@ func (fsbs *filesystemBackstore) Put(assertType *AssertionType, assert Assertion
 	fsbs.mu.Lock()
 	defer fsbs.mu.Unlock()
 
+	_, err := fsbs.put(assertType, assert)
+	return err
+}
+
+var _ RevertingBackstore = (*filesystemBackstore)(nil)
+
+func (fsbs *filesystemBackstore) PutReverting(assertType *AssertionType, assert Assertion) (revert func() error, err error) {
+	fsbs.mu.Lock()
+	defer fsbs.mu.Unlock()
+
+	// what was stored before under the same path, if anything
+	prev, err := readEntry(fsbs.top, assertType.Name, fsbs.diskPrimaryPath(assertType, assert))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("broken assertion storage, cannot read assertion: %v", err)
+	}
+	diskPrimaryPath, err := fsbs.put(assertType, assert)
+	if err != nil {
+		return nil, err
+	}
+	revert = func() error {
+		fsbs.mu.Lock()
+		defer fsbs.mu.Unlock()
+
+		var err error
+		if prev != nil {
+			err = atomicWriteEntry(prev, false, fsbs.top, assertType.Name, diskPrimaryPath)
+		} else {
+			err = os.Remove(filepath.Join(fsbs.top, assertType.Name, diskPrimaryPath))
+		}
+		if err != nil {
+			return fmt.Errorf("broken assertion storage, cannot revert assertion: %v", err)
+		}
+		return nil
+	}
+	return revert, nil
+}
+
+func (fsbs *filesystemBackstore) diskPrimaryPath(assertType *AssertionType, assert Assertion) string {
+	primaryPath := make([]string, len(assertType.PrimaryKey))
+	for i, k := range assertType.PrimaryKey {
+		primaryPath[i] = assert.HeaderString(k)
+	}
+
+	formatnum := assert.Format()
+	activeFn := "active"
+	if formatnum > 0 {
+		activeFn = fmt.Sprintf("active.%d", formatnum)
+	}
+	return filepath.Join(diskPrimaryPathComps(primaryPath, activeFn)...)
+}
+
+// put stores assert returning the path where it was stored, it must be
+// called with the lock held.
+func (fsbs *filesystemBackstore) put(assertType *AssertionType, assert Assertion) (diskPrimaryPath string, err error) {
 	primaryPath := make([]string, len(assertType.PrimaryKey))
 	for i, k := range assertType.PrimaryKey {
 		primaryPath[i] = assert.HeaderString(k)
@@ -149,23 +203,18 @@ func (fsbs *filesystemBackstore) Put(assertType *AssertionType, assert Assertion
 		curRev := curAssert.Revision()
 		rev := assert.Revision()
 		if curRev >= rev {
-			return &RevisionError{Current: curRev, Used: rev}
+			return "", &RevisionError{Current: curRev, Used: rev}
 		}
 	} else if err != ErrNotFound {
-		return err
+		return "", err
 	}
 
-	formatnum := assert.Format()
-	activeFn := "active"
-	if formatnum > 0 {
-		activeFn = fmt.Sprintf("active.%d", formatnum)
-	}
-	diskPrimaryPath := filepath.Join(diskPrimaryPathComps(primaryPath, activeFn)...)
+	diskPrimaryPath = fsbs.diskPrimaryPath(assertType, assert)
 	err = atomicWriteEntry(Encode(assert), false, fsbs.top, assertType.Name, diskPrimaryPath)
 	if err != nil {
-		return fmt.Errorf("broken assertion storage, cannot write assertion: %v", err)
+		return "", fmt.Errorf("broken assertion storage, cannot write assertion: %v", err)
 	}
-	return nil
+	return diskPrimaryPath, nil
 }
 
 func (fsbs *filesystemBackstore) Get(assertType *AssertionType, key []string, maxFormat int) (Assertion, error) {
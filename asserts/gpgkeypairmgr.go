@@ -23,6 +23,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -32,25 +34,45 @@ import (
 	"github.com/snapcore/snapd/osutil"
 )
 
-func ensureGPGHomeDirectory() (string, error) {
+func realUserIDs() (uid, gid int, homedir string, err error) {
 	real, err := osutil.RealUser()
 	if err != nil {
-		return "", err
+		return -1, -1, "", err
+	}
+
+	uid, err = strconv.Atoi(real.Uid)
+	if err != nil {
+		return -1, -1, "", err
 	}
 
-	uid, err := strconv.Atoi(real.Uid)
+	gid, err = strconv.Atoi(real.Gid)
+	if err != nil {
+		return -1, -1, "", err
+	}
+	return uid, gid, real.HomeDir, nil
+}
+
+func gpgHomeDirectory() (string, error) {
+	homedir := os.Getenv("SNAP_GNUPG_HOME")
+	if homedir != "" {
+		return homedir, nil
+	}
+	_, _, realHome, err := realUserIDs()
 	if err != nil {
 		return "", err
 	}
+	return filepath.Join(realHome, ".snap", "gnupg"), nil
+}
 
-	gid, err := strconv.Atoi(real.Gid)
+func ensureGPGHomeDirectory() (string, error) {
+	uid, gid, _, err := realUserIDs()
 	if err != nil {
 		return "", err
 	}
 
-	homedir := os.Getenv("SNAP_GNUPG_HOME")
-	if homedir == "" {
-		homedir = filepath.Join(real.HomeDir, ".snap", "gnupg")
+	homedir, err := gpgHomeDirectory()
+	if err != nil {
+		return "", err
 	}
 
 	if err := osutil.MkdirAllChown(homedir, 0700, uid, gid); err != nil {
@@ -160,8 +182,15 @@ func (gkm *GPGKeypairManager) retrieve(fpr string) (PrivateKey, error) {
 	return privKey, nil
 }
 
-// Walk iterates over all the RSA private keys in the local GPG setup calling the provided callback until this returns an error
+// Walk iterates over all the RSA private keys in the local GPG setup, and then over the Ed25519 keys kept alongside it, calling the provided callback until this returns an error. Ed25519 keys are passed an empty fingerprint.
 func (gkm *GPGKeypairManager) Walk(consider func(privk PrivateKey, fingerprint string, uid string) error) error {
+	if err := gkm.walkGPG(consider); err != nil {
+		return err
+	}
+	return gkm.walkEd25519(consider)
+}
+
+func (gkm *GPGKeypairManager) walkGPG(consider func(privk PrivateKey, fingerprint string, uid string) error) error {
 	// see GPG source doc/DETAILS
 	out, err := gkm.gpg(nil, "--batch", "--list-secret-keys", "--fingerprint", "--with-colons", "--fixed-list-mode")
 	if err != nil {
@@ -305,6 +334,136 @@ func (gkm *GPGKeypairManager) GetByName(name string) (PrivateKey, error) {
 	return keyInfo.privKey, nil
 }
 
+// GnuPG cannot hold Ed25519 keys in a form usable for signing
+// assertions, they are kept instead under this directory of the GnuPG
+// home, encrypted by GnuPG with a passphrase in files named after the
+// keys, next to their public keys in files with the pubKeySuffix.
+const (
+	ed25519KeysDir = "ed25519-keys"
+	pubKeySuffix   = ".pub"
+)
+
+func ed25519KeysDirectory() (string, error) {
+	homedir, err := gpgHomeDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homedir, ed25519KeysDir), nil
+}
+
+func (gkm *GPGKeypairManager) walkEd25519(consider func(privk PrivateKey, fingerprint string, uid string) error) error {
+	dir, err := ed25519KeysDirectory()
+	if err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), pubKeySuffix)
+		if !entry.Mode().IsRegular() || name == entry.Name() || !IsValidAccountKeyName(name) {
+			continue
+		}
+		encoded, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		pubKey, err := DecodePublicKey(encoded)
+		if err != nil {
+			return fmt.Errorf("cannot decode Ed25519 key %q: %v", name, err)
+		}
+		if _, ok := pubKey.(*ed25519PubKey); !ok {
+			return fmt.Errorf("cannot load Ed25519 key %q: not an Ed25519 key", name)
+		}
+		keyPath := filepath.Join(dir, name)
+		privKey := &gpgEd25519PrivateKey{
+			pubKey: pubKey,
+			decrypt: func() ([]byte, error) {
+				return gkm.decrypt(keyPath)
+			},
+		}
+		err = consider(privKey, "", name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// passphraseFromInputArgs returns the options for GnuPG to read the
+// passphrase from the first line of its input. GnuPG 2 needs asking
+// for loopback pinentry for that, otherwise its agent still prompts
+// for the passphrase.
+func (gkm *GPGKeypairManager) passphraseFromInputArgs() ([]string, error) {
+	args := []string{"--batch", "--passphrase-fd", "0"}
+	out, err := gkm.gpg(nil, "--version")
+	if err != nil {
+		return nil, err
+	}
+	// the first line is like "gpg (GnuPG) 2.1.11"
+	firstLine := strings.SplitN(string(out), "\n", 2)[0]
+	fields := strings.Fields(firstLine)
+	if len(fields) != 0 && !strings.HasPrefix(fields[len(fields)-1], "1.") {
+		args = append(args, "--pinentry-mode", "loopback")
+	}
+	return args, nil
+}
+
+func (gkm *GPGKeypairManager) decrypt(path string) ([]byte, error) {
+	out, err := gkm.gpg(nil, "--decrypt", path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt using GPG: %v", err)
+	}
+	return out, nil
+}
+
+// gpgEd25519PrivateKey is an Ed25519 private key kept encrypted by
+// GnuPG, decrypt returns it decrypted and encoded, as needed for
+// signing.
+type gpgEd25519PrivateKey struct {
+	pubKey  PublicKey
+	decrypt func() ([]byte, error)
+}
+
+func (gpgk *gpgEd25519PrivateKey) PublicKey() PublicKey {
+	return gpgk.pubKey
+}
+
+func (gpgk *gpgEd25519PrivateKey) keyVersion() byte {
+	return v2
+}
+
+func (gpgk *gpgEd25519PrivateKey) keyEncode(w io.Writer) error {
+	return fmt.Errorf("cannot access GPG encrypted private key to encode it")
+}
+
+func (gpgk *gpgEd25519PrivateKey) signEd25519(content []byte) ([]byte, error) {
+	encoded, err := gpgk.decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	badKey := "bad GPG decrypted private key: "
+
+	privKey, err := decodePrivateKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf(badKey+"%v", err)
+	}
+	edPrivK, ok := privKey.(ed25519PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf(badKey + "not an Ed25519 key")
+	}
+	if edPrivK.PublicKey().ID() != gpgk.pubKey.ID() {
+		return nil, fmt.Errorf(badKey+"expected key %s", gpgk.pubKey.ID())
+	}
+
+	return edPrivK.signEd25519(content)
+}
+
 var generateTemplate = `
 Key-Type: RSA
 Key-Length: 4096
@@ -336,6 +495,59 @@ func (gkm *GPGKeypairManager) Generate(passphrase string, name string) error {
 	return nil
 }
 
+// GenerateEd25519 creates a new Ed25519 key with the given passphrase
+// and name. The key is kept alongside the GnuPG setup, encrypted by
+// GnuPG with the passphrase, which is then needed to sign with it.
+func (gkm *GPGKeypairManager) GenerateEd25519(passphrase string, name string) error {
+	if passphrase == "" || strings.ContainsAny(passphrase, "\r\n") {
+		return fmt.Errorf("cannot protect Ed25519 key: passphrase must be non-empty and a single line")
+	}
+	_, err := gkm.findByName(name)
+	if err == nil {
+		return fmt.Errorf("key named %q already exists", name)
+	}
+	privKey, err := GenerateEd25519Key()
+	if err != nil {
+		return err
+	}
+	encoded, err := encodePrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	encodedPubKey, err := EncodePublicKey(privKey.PublicKey())
+	if err != nil {
+		return err
+	}
+	// GnuPG reads the passphrase from the first line of the input,
+	// the rest is what gets encrypted
+	input := append([]byte(passphrase+"\n"), encoded...)
+	passphraseArgs, err := gkm.passphraseFromInputArgs()
+	if err != nil {
+		return err
+	}
+	args := append(passphraseArgs, "--symmetric", "--cipher-algo", "AES256", "--output", "-")
+	encrypted, err := gkm.gpg(input, args...)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt using GPG: %v", err)
+	}
+	uid, gid, _, err := realUserIDs()
+	if err != nil {
+		return err
+	}
+	dir, err := ed25519KeysDirectory()
+	if err != nil {
+		return err
+	}
+	if err := osutil.MkdirAllChown(dir, 0700, uid, gid); err != nil {
+		return err
+	}
+	keyPath := filepath.Join(dir, name)
+	if err := osutil.AtomicWriteFileChown(keyPath, encrypted, 0600, 0, uid, gid); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFileChown(keyPath+pubKeySuffix, encodedPubKey, 0644, 0, uid, gid)
+}
+
 // Export returns the encoded text of the named public key.
 func (gkm *GPGKeypairManager) Export(name string) ([]byte, error) {
 	keyInfo, err := gkm.findByName(name)
@@ -351,6 +563,18 @@ func (gkm *GPGKeypairManager) Delete(name string) error {
 	if err != nil {
 		return err
 	}
+	if keyInfo.fingerprint == "" {
+		// Ed25519 key kept alongside GnuPG
+		dir, err := ed25519KeysDirectory()
+		if err != nil {
+			return err
+		}
+		keyPath := filepath.Join(dir, name)
+		if err := os.Remove(keyPath + pubKeySuffix); err != nil {
+			return err
+		}
+		return os.Remove(keyPath)
+	}
 	_, err = gkm.gpg(nil, "--batch", "--delete-secret-and-public-key", "0x"+keyInfo.fingerprint)
 	if err != nil {
 		return err
@@ -25,7 +25,10 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -35,6 +38,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
 )
 
 type gpgKeypairMgrSuite struct {
@@ -329,3 +333,199 @@ Preferences: SHA512
 		c.Check(parameters, Equals, baseParameters+test.extraParameters)
 	}
 }
+
+// mockGPGDecrypt makes GnuPG decrypt using the given passphrase
+// instead of prompting for it.
+func mockGPGDecrypt(passphrase string) (restore func()) {
+	return asserts.MockRunGPG(func(prev asserts.GPGRunner, input []byte, args ...string) ([]byte, error) {
+		if len(args) == 2 && args[0] == "--decrypt" {
+			return prev([]byte(passphrase+"\n"), "--batch", "--passphrase-fd", "0", "--decrypt", args[1])
+		}
+		return prev(input, args...)
+	})
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerateEd25519(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+
+	err := gpgKeypairMgr.GenerateEd25519("passphrase", "ed-key")
+	c.Assert(err, IsNil)
+
+	keyPath := filepath.Join(gkms.homedir, "ed25519-keys", "ed-key")
+	info, err := os.Stat(keyPath)
+	c.Assert(err, IsNil)
+	c.Check(info.Mode().Perm(), Equals, os.FileMode(0600)) // secret
+	// and not stored in clear
+	encrypted, err := ioutil.ReadFile(keyPath)
+	c.Assert(err, IsNil)
+	_, err = asserts.DecodePrivateKeyInTest(encrypted)
+	c.Check(err, NotNil)
+
+	privKey, err := gpgKeypairMgr.GetByName("ed-key")
+	c.Assert(err, IsNil)
+	keyID := privKey.PublicKey().ID()
+
+	got, err := gkms.keypairMgr.Get(keyID)
+	c.Assert(err, IsNil)
+	c.Check(got.PublicKey().ID(), Equals, keyID)
+
+	var names []string
+	err = gpgKeypairMgr.Walk(func(privk asserts.PrivateKey, fpr string, uid string) error {
+		names = append(names, uid)
+		if uid == "ed-key" {
+			c.Check(fpr, Equals, "")
+		}
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{" (test)", "ed-key"})
+
+	exported, err := gpgKeypairMgr.Export("ed-key")
+	c.Assert(err, IsNil)
+	pubKey, err := asserts.DecodePublicKey(exported)
+	c.Assert(err, IsNil)
+	c.Check(pubKey.ID(), Equals, keyID)
+
+	err = gpgKeypairMgr.GenerateEd25519("passphrase", "ed-key")
+	c.Check(err, ErrorMatches, `key named "ed-key" already exists`)
+
+	err = gpgKeypairMgr.Delete("ed-key")
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(keyPath), Equals, false)
+	c.Check(osutil.FileExists(keyPath+".pub"), Equals, false)
+	_, err = gpgKeypairMgr.GetByName("ed-key")
+	c.Check(err, ErrorMatches, `cannot find key named "ed-key" in GPG keyring`)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerateEd25519PinentryLoopback(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+
+	for _, t := range []struct {
+		version  string
+		loopback bool
+	}{
+		{"gpg (GnuPG) 1.4.20\nCopyright (C) 2015 Free Software Foundation, Inc.\n", false},
+		{"gpg (GnuPG) 2.1.11\nlibgcrypt 1.6.5\n", true},
+		{"gpg (GnuPG) 2.2.4\nlibgcrypt 1.8.1\n", true},
+	} {
+		var symmetricArgs []string
+		restore := asserts.MockRunGPG(func(prev asserts.GPGRunner, input []byte, args ...string) ([]byte, error) {
+			if len(args) == 1 && args[0] == "--version" {
+				return []byte(t.version), nil
+			}
+			for _, arg := range args {
+				if arg == "--symmetric" {
+					symmetricArgs = args
+					return []byte("encrypted"), nil
+				}
+			}
+			return prev(input, args...)
+		})
+		err := gpgKeypairMgr.GenerateEd25519("passphrase", "ed-key")
+		restore()
+		c.Assert(err, IsNil)
+
+		args := strings.Join(symmetricArgs, " ")
+		c.Check(args, testutil.Contains, "--batch --passphrase-fd 0")
+		if t.loopback {
+			c.Check(args, testutil.Contains, "--pinentry-mode loopback")
+		} else {
+			c.Check(args, Not(testutil.Contains), "--pinentry-mode")
+		}
+
+		c.Assert(gpgKeypairMgr.Delete("ed-key"), IsNil)
+	}
+}
+
+func (gkms *gpgKeypairMgrSuite) TestGenerateEd25519NeedsPassphrase(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+
+	for _, passphrase := range []string{"", "two\nlines"} {
+		err := gpgKeypairMgr.GenerateEd25519(passphrase, "ed-key")
+		c.Check(err, ErrorMatches, `cannot protect Ed25519 key: passphrase must be non-empty and a single line`)
+	}
+	c.Check(osutil.FileExists(filepath.Join(gkms.homedir, "ed25519-keys")), Equals, false)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestWalkDoesNotCreateEd25519KeysDirectory(c *C) {
+	err := gkms.keypairMgr.(*asserts.GPGKeypairManager).Walk(func(asserts.PrivateKey, string, string) error {
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(gkms.homedir, "ed25519-keys")), Equals, false)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestUseEd25519InSigning(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+	err := gpgKeypairMgr.GenerateEd25519("passphrase", "ed-key")
+	c.Assert(err, IsNil)
+	restore := mockGPGDecrypt("passphrase")
+	defer restore()
+	edKey, err := gpgKeypairMgr.GetByName("ed-key")
+	c.Assert(err, IsNil)
+
+	store := assertstest.NewStoreStack("trusted", testPrivKey0, testPrivKey1)
+	devAcct := assertstest.NewAccount(store, "devel1", map[string]interface{}{
+		"account-id": "dev1-id",
+	}, "")
+	devAccKey := assertstest.NewAccountKey(store, devAcct, map[string]interface{}{
+		"format": "1",
+	}, edKey.PublicKey(), "")
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: gkms.keypairMgr,
+	})
+	c.Assert(err, IsNil)
+
+	checkDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   store.Trusted,
+	})
+	c.Assert(err, IsNil)
+	err = checkDB.Add(store.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = checkDB.Add(devAcct)
+	c.Assert(err, IsNil)
+	err = checkDB.Add(devAccKey)
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":  "dev1-id",
+		"snap-sha3-384": blobSHA3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "devel",
+		"snap-size":     "1025",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	snapBuild, err := signDB.Sign(asserts.SnapBuildType, headers, nil, edKey.PublicKey().ID())
+	c.Assert(err, IsNil)
+
+	err = checkDB.Check(snapBuild)
+	c.Check(err, IsNil)
+}
+
+func (gkms *gpgKeypairMgrSuite) TestUseEd25519InSigningWrongPassphrase(c *C) {
+	gpgKeypairMgr := gkms.keypairMgr.(*asserts.GPGKeypairManager)
+	err := gpgKeypairMgr.GenerateEd25519("passphrase", "ed-key")
+	c.Assert(err, IsNil)
+	edKey, err := gpgKeypairMgr.GetByName("ed-key")
+	c.Assert(err, IsNil)
+	restore := mockGPGDecrypt("wrong")
+	defer restore()
+
+	signDB, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		KeypairManager: gkms.keypairMgr,
+	})
+	c.Assert(err, IsNil)
+
+	headers := map[string]interface{}{
+		"authority-id":  "dev1-id",
+		"snap-sha3-384": blobSHA3_384,
+		"snap-id":       "snap-id-1",
+		"grade":         "devel",
+		"snap-size":     "1025",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
+	_, err = signDB.Sign(asserts.SnapBuildType, headers, nil, edKey.PublicKey().ID())
+	c.Check(err, ErrorMatches, `(?s)cannot sign assertion: cannot decrypt using GPG: .*`)
+}
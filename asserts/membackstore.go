@@ -80,6 +80,18 @@ func (leaf memBSLeaf) put(assertType *AssertionType, key []string, assert Assert
 	return nil
 }
 
+// formats returns the assertions by format stored under the given
+// key, if any.
+func (br memBSBranch) formats(key []string) map[int]Assertion {
+	switch down := br[key[0]].(type) {
+	case memBSBranch:
+		return down.formats(key[1:])
+	case memBSLeaf:
+		return down[key[1]]
+	}
+	return nil
+}
+
 func (br memBSBranch) get(key []string, maxFormat int) (Assertion, error) {
 	key0 := key[0]
 	down := br[key0]
@@ -138,20 +150,51 @@ func NewMemoryBackstore() Backstore {
 	}
 }
 
-func (mbs *memoryBackstore) Put(assertType *AssertionType, assert Assertion) error {
-	mbs.mu.Lock()
-	defer mbs.mu.Unlock()
-
+func putInternalKey(assertType *AssertionType, assert Assertion) []string {
 	internalKey := make([]string, 1+len(assertType.PrimaryKey))
 	internalKey[0] = assertType.Name
 	for i, name := range assertType.PrimaryKey {
 		internalKey[1+i] = assert.HeaderString(name)
 	}
+	return internalKey
+}
+
+func (mbs *memoryBackstore) Put(assertType *AssertionType, assert Assertion) error {
+	mbs.mu.Lock()
+	defer mbs.mu.Unlock()
 
+	internalKey := putInternalKey(assertType, assert)
 	err := mbs.top.put(assertType, internalKey, assert)
 	return err
 }
 
+var _ RevertingBackstore = (*memoryBackstore)(nil)
+
+func (mbs *memoryBackstore) PutReverting(assertType *AssertionType, assert Assertion) (revert func() error, err error) {
+	mbs.mu.Lock()
+	defer mbs.mu.Unlock()
+
+	internalKey := putInternalKey(assertType, assert)
+	formatnum := assert.Format()
+	prev := mbs.top.formats(internalKey)[formatnum]
+	if err := mbs.top.put(assertType, internalKey, assert); err != nil {
+		return nil, err
+	}
+	revert = func() error {
+		mbs.mu.Lock()
+		defer mbs.mu.Unlock()
+
+		formats := mbs.top.formats(internalKey)
+		if prev != nil {
+			formats[formatnum] = prev
+		} else {
+			delete(formats, formatnum)
+		}
+		return nil
+	}
+	return revert, nil
+}
+
 func (mbs *memoryBackstore) Get(assertType *AssertionType, key []string, maxFormat int) (Assertion, error) {
 	mbs.mu.RLock()
 	defer mbs.mu.RUnlock()
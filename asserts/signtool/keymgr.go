@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package signtool
+
+import (
+	"os"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+// KeypairManager is the common interface of the key pair managers
+// usable for signing by name from the command line, that is the
+// GPG and the external ones.
+type KeypairManager interface {
+	asserts.KeypairManager
+
+	// GetByName looks up a private key by name and returns it.
+	GetByName(name string) (asserts.PrivateKey, error)
+	// Export returns the encoded text of the named public key.
+	Export(name string) ([]byte, error)
+	// Walk iterates over all the keys calling the provided
+	// callback until this returns an error.
+	Walk(consider func(privk asserts.PrivateKey, fingerprint string, uid string) error) error
+}
+
+// GetKeypairManager returns the key pair manager to use for signing,
+// an external one using the helper named by $SNAP_EXT_KEYMGR if set,
+// otherwise the one backed by the local GnuPG setup.
+func GetKeypairManager() (KeypairManager, error) {
+	keyMgrPath := os.Getenv("SNAP_EXT_KEYMGR")
+	if keyMgrPath != "" {
+		return asserts.NewExternalKeypairManager(keyMgrPath)
+	}
+	return asserts.NewGPGKeypairManager(), nil
+}
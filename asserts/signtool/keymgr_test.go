@@ -0,0 +1,50 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package signtool_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
+)
+
+type keymgrSuite struct{}
+
+var _ = Suite(&keymgrSuite{})
+
+func (s *keymgrSuite) TearDownTest(c *C) {
+	os.Unsetenv("SNAP_EXT_KEYMGR")
+}
+
+func (s *keymgrSuite) TestGetKeypairManagerGPG(c *C) {
+	os.Unsetenv("SNAP_EXT_KEYMGR")
+	keypairMgr, err := signtool.GetKeypairManager()
+	c.Assert(err, IsNil)
+	c.Check(keypairMgr, FitsTypeOf, &asserts.GPGKeypairManager{})
+}
+
+func (s *keymgrSuite) TestGetKeypairManagerExternal(c *C) {
+	os.Setenv("SNAP_EXT_KEYMGR", "/bin/false")
+	_, err := signtool.GetKeypairManager()
+	c.Check(err, ErrorMatches, `external keypair manager "/bin/false" features failed: .*`)
+}
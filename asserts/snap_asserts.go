@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"crypto"
 	"fmt"
+	"regexp"
 	"time"
 
 	_ "golang.org/x/crypto/sha3" // expected for digests
@@ -37,6 +38,7 @@ import (
 type SnapDeclaration struct {
 	assertionBase
 	refreshControl []string
+	autoAliases    []string
 	plugRules      map[string]*PlugRule
 	slotRules      map[string]*SlotRule
 	timestamp      time.Time
@@ -72,6 +74,11 @@ func (snapdcl *SnapDeclaration) RefreshControl() []string {
 	return snapdcl.refreshControl
 }
 
+// AutoAliases returns the aliases declared in the snap's snap.yaml that are granted to be enabled automatically.
+func (snapdcl *SnapDeclaration) AutoAliases() []string {
+	return snapdcl.autoAliases
+}
+
 // PlugRule returns the plug-side rule about the given interface if one was included in the plugs stanza of the declaration, otherwise it returns nil.
 func (snapdcl *SnapDeclaration) PlugRule(interfaceName string) *PlugRule {
 	return snapdcl.plugRules[interfaceName]
@@ -110,6 +117,9 @@ func (snapdcl *SnapDeclaration) Prerequisites() []*Ref {
 	}
 }
 
+// validAlias matches the alias names accepted in snap.yaml.
+var validAlias = regexp.MustCompile("^[a-zA-Z0-9][-_.a-zA-Z0-9]*$")
+
 func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 	_, err := checkExistsString(assert.headers, "snap-name")
 	if err != nil {
@@ -135,6 +145,11 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	autoAliases, err := checkStringListInMap(assert.headers, "auto-aliases", `"auto-aliases" header`, validAlias)
+	if err != nil {
+		return nil, err
+	}
+
 	plugs, err := checkMap(assert.headers, "plugs")
 	if err != nil {
 		return nil, err
@@ -168,6 +183,7 @@ func assembleSnapDeclaration(assert assertionBase) (Assertion, error) {
 	return &SnapDeclaration{
 		assertionBase:  assert,
 		refreshControl: refControl,
+		autoAliases:    autoAliases,
 		plugRules:      plugRules,
 		slotRules:      slotRules,
 		timestamp:      timestamp,
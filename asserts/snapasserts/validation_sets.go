@@ -0,0 +1,308 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapasserts
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ValidationSetKey returns the key identifying a validation set
+// independently of its sequence, as account-id/name.
+func ValidationSetKey(accountID, name string) string {
+	return accountID + "/" + name
+}
+
+func validationSetRef(vs *asserts.ValidationSet) string {
+	return fmt.Sprintf("%s=%d", ValidationSetKey(vs.AccountID(), vs.Name()), vs.Sequence())
+}
+
+// SnapConstraints holds what a combination of validation sets
+// requires from a snap.
+type SnapConstraints struct {
+	Name   string
+	SnapID string
+	// Presence is the strictest presence constraint of the sets.
+	Presence asserts.Presence
+	// Revision is the revision the snap must be at, unset if any
+	// revision will do.
+	Revision snap.Revision
+	// Sets lists the validation sets constraining the snap as
+	// account-id/name=sequence.
+	Sets []string
+}
+
+// ValidationSets combines validation-set assertions and checks snaps
+// against them.
+type ValidationSets struct {
+	sets  map[string]*asserts.ValidationSet
+	snaps map[string]*snapConstraintsBySet
+}
+
+type snapConstraintsBySet struct {
+	name   string
+	snapID string
+	bySet  map[string]*asserts.ValidationSetSnap
+}
+
+// NewValidationSets returns a new empty ValidationSets.
+func NewValidationSets() *ValidationSets {
+	return &ValidationSets{
+		sets:  make(map[string]*asserts.ValidationSet),
+		snaps: make(map[string]*snapConstraintsBySet),
+	}
+}
+
+// Add adds the given validation set, replacing any other sequence of
+// the same set.
+func (v *ValidationSets) Add(vs *asserts.ValidationSet) {
+	key := ValidationSetKey(vs.AccountID(), vs.Name())
+	if old := v.sets[key]; old != nil {
+		for _, sn := range old.Snaps() {
+			delete(v.snaps[sn.Name].bySet, key)
+		}
+	}
+	v.sets[key] = vs
+	for _, sn := range vs.Snaps() {
+		cstrs := v.snaps[sn.Name]
+		if cstrs == nil {
+			cstrs = &snapConstraintsBySet{
+				name:   sn.Name,
+				snapID: sn.SnapID,
+				bySet:  make(map[string]*asserts.ValidationSetSnap),
+			}
+			v.snaps[sn.Name] = cstrs
+		}
+		cstrs.bySet[key] = sn
+	}
+}
+
+// Sets returns the validation sets that were added, sorted by key.
+func (v *ValidationSets) Sets() []*asserts.ValidationSet {
+	keys := make([]string, 0, len(v.sets))
+	for key := range v.sets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sets := make([]*asserts.ValidationSet, len(keys))
+	for i, key := range keys {
+		sets[i] = v.sets[key]
+	}
+	return sets
+}
+
+func (v *ValidationSets) setRefs(keys []string) []string {
+	refs := make([]string, len(keys))
+	for i, key := range keys {
+		refs[i] = validationSetRef(v.sets[key])
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+func (cstrs *snapConstraintsBySet) sortedKeys() []string {
+	keys := make([]string, 0, len(cstrs.bySet))
+	for key := range cstrs.bySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Conflict returns an error describing the first incompatibility
+// between the added validation sets, if any: a snap both required or
+// optional and invalid, or constrained to different revisions.
+func (v *ValidationSets) Conflict() error {
+	names := make([]string, 0, len(v.snaps))
+	for name := range v.snaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cstrs := v.snaps[name]
+		var invalid, valid []string
+		revisions := make(map[int][]string)
+		for _, key := range cstrs.sortedKeys() {
+			sn := cstrs.bySet[key]
+			if sn.Presence == asserts.PresenceInvalid {
+				invalid = append(invalid, key)
+				continue
+			}
+			valid = append(valid, key)
+			if sn.Revision != 0 {
+				revisions[sn.Revision] = append(revisions[sn.Revision], key)
+			}
+		}
+		if len(invalid) > 0 && len(valid) > 0 {
+			return fmt.Errorf("validation sets are in conflict: snap %q is invalid in %s but allowed in %s", name, strings.Join(v.setRefs(invalid), ", "), strings.Join(v.setRefs(valid), ", "))
+		}
+		if len(revisions) > 1 {
+			var revs []int
+			for rev := range revisions {
+				revs = append(revs, rev)
+			}
+			sort.Ints(revs)
+			var desc []string
+			for _, rev := range revs {
+				desc = append(desc, fmt.Sprintf("revision %d in %s", rev, strings.Join(v.setRefs(revisions[rev]), ", ")))
+			}
+			return fmt.Errorf("validation sets are in conflict: snap %q is required at %s", name, strings.Join(desc, " and "))
+		}
+	}
+	return nil
+}
+
+// Constraints returns what the added validation sets require from
+// the snap with the given name, or nil if none of them mentions it.
+// It assumes the sets are not in conflict, see Conflict.
+func (v *ValidationSets) Constraints(name string) *SnapConstraints {
+	cstrs := v.snaps[name]
+	if cstrs == nil || len(cstrs.bySet) == 0 {
+		return nil
+	}
+	res := &SnapConstraints{
+		Name:     cstrs.name,
+		SnapID:   cstrs.snapID,
+		Presence: asserts.PresenceOptional,
+	}
+	keys := cstrs.sortedKeys()
+	for _, key := range keys {
+		sn := cstrs.bySet[key]
+		switch sn.Presence {
+		case asserts.PresenceInvalid:
+			res.Presence = asserts.PresenceInvalid
+		case asserts.PresenceRequired:
+			if res.Presence != asserts.PresenceInvalid {
+				res.Presence = asserts.PresenceRequired
+			}
+		}
+		if sn.Revision != 0 {
+			res.Revision = snap.R(sn.Revision)
+		}
+	}
+	res.Sets = v.setRefs(keys)
+	return res
+}
+
+// InstalledSnap holds the details of an installed snap relevant to
+// checking it against validation sets.
+type InstalledSnap struct {
+	Name     string
+	SnapID   string
+	Revision snap.Revision
+}
+
+// ValidationSetsValidationError describes how installed snaps fail
+// to comply with validation sets. Its fields map snap names to the
+// validation sets, as account-id/name=sequence, they break.
+type ValidationSetsValidationError struct {
+	// MissingSnaps are required snaps that are not installed.
+	MissingSnaps map[string][]string
+	// InvalidSnaps are invalid snaps that are installed.
+	InvalidSnaps map[string][]string
+	// WrongRevisionSnaps are snaps installed at a revision other
+	// than the required one, which is given too.
+	WrongRevisionSnaps map[string]*WrongRevision
+}
+
+// WrongRevision describes a snap installed at the wrong revision.
+type WrongRevision struct {
+	Installed snap.Revision
+	Required  snap.Revision
+	Sets      []string
+}
+
+func sortedSnapNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *ValidationSetsValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("validation sets assertions are not met:")
+	for _, name := range sortedSnapNames(e.MissingSnaps) {
+		fmt.Fprintf(&buf, "\n- missing required snap %q (required by %s)", name, strings.Join(e.MissingSnaps[name], ", "))
+	}
+	for _, name := range sortedSnapNames(e.InvalidSnaps) {
+		fmt.Fprintf(&buf, "\n- invalid snap %q is installed (invalid for %s)", name, strings.Join(e.InvalidSnaps[name], ", "))
+	}
+	names := make([]string, 0, len(e.WrongRevisionSnaps))
+	for name := range e.WrongRevisionSnaps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		wrong := e.WrongRevisionSnaps[name]
+		fmt.Fprintf(&buf, "\n- snap %q is at revision %s instead of %s (required by %s)", name, wrong.Installed, wrong.Required, strings.Join(wrong.Sets, ", "))
+	}
+	return buf.String()
+}
+
+// CheckInstalledSnaps checks the given installed snaps against the
+// added validation sets, returning a *ValidationSetsValidationError
+// if they do not comply.
+func (v *ValidationSets) CheckInstalledSnaps(installed []*InstalledSnap) error {
+	byName := make(map[string]*InstalledSnap, len(installed))
+	for _, sn := range installed {
+		byName[sn.Name] = sn
+	}
+
+	verr := &ValidationSetsValidationError{
+		MissingSnaps:       make(map[string][]string),
+		InvalidSnaps:       make(map[string][]string),
+		WrongRevisionSnaps: make(map[string]*WrongRevision),
+	}
+	failed := false
+	for name := range v.snaps {
+		cstrs := v.Constraints(name)
+		if cstrs == nil {
+			continue
+		}
+		sn := byName[name]
+		switch {
+		case sn == nil && cstrs.Presence == asserts.PresenceRequired:
+			verr.MissingSnaps[name] = cstrs.Sets
+			failed = true
+		case sn != nil && cstrs.Presence == asserts.PresenceInvalid:
+			verr.InvalidSnaps[name] = cstrs.Sets
+			failed = true
+		case sn != nil && !cstrs.Revision.Unset() && sn.Revision != cstrs.Revision:
+			verr.WrongRevisionSnaps[name] = &WrongRevision{
+				Installed: sn.Revision,
+				Required:  cstrs.Revision,
+				Sets:      cstrs.Sets,
+			}
+			failed = true
+		}
+	}
+	if failed {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapasserts_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/snap"
+)
+
+type validationSetsSuite struct {
+	storeSigning *assertstest.StoreStack
+}
+
+var _ = Suite(&validationSetsSuite{})
+
+func (s *validationSetsSuite) SetUpSuite(c *C) {
+	rootPrivKey, _ := assertstest.GenerateKey(1024)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	s.storeSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+}
+
+func (s *validationSetsSuite) mockValidationSet(c *C, name string, sequence string, snaps ...interface{}) *asserts.ValidationSet {
+	a, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"authority-id": "can0nical",
+		"account-id":   "can0nical",
+		"series":       "16",
+		"name":         name,
+		"sequence":     sequence,
+		"snaps":        snaps,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.ValidationSet)
+}
+
+func snapEntry(name, presence, revision string) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":     name,
+		"id":       name + "-id",
+		"presence": presence,
+	}
+	if revision != "" {
+		m["revision"] = revision
+	}
+	return m
+}
+
+func (s *validationSetsSuite) TestConstraints(c *C) {
+	vs1 := s.mockValidationSet(c, "one", "1",
+		snapEntry("foo", "optional", ""),
+		snapEntry("bar", "required", "3"),
+	)
+	vs2 := s.mockValidationSet(c, "two", "2",
+		snapEntry("foo", "required", ""),
+		snapEntry("baz", "invalid", ""),
+	)
+
+	sets := snapasserts.NewValidationSets()
+	sets.Add(vs1)
+	sets.Add(vs2)
+	c.Check(sets.Conflict(), IsNil)
+	c.Check(sets.Sets(), DeepEquals, []*asserts.ValidationSet{vs1, vs2})
+
+	c.Check(sets.Constraints("foo"), DeepEquals, &snapasserts.SnapConstraints{
+		Name:     "foo",
+		SnapID:   "foo-id",
+		Presence: asserts.PresenceRequired,
+		Sets:     []string{"can0nical/one=1", "can0nical/two=2"},
+	})
+	c.Check(sets.Constraints("bar"), DeepEquals, &snapasserts.SnapConstraints{
+		Name:     "bar",
+		SnapID:   "bar-id",
+		Presence: asserts.PresenceRequired,
+		Revision: snap.R(3),
+		Sets:     []string{"can0nical/one=1"},
+	})
+	c.Check(sets.Constraints("baz").Presence, Equals, asserts.PresenceInvalid)
+	c.Check(sets.Constraints("other"), IsNil)
+
+	// a new sequence replaces the old one
+	sets.Add(s.mockValidationSet(c, "one", "2", snapEntry("foo", "optional", "")))
+	c.Check(sets.Constraints("bar"), IsNil)
+	c.Check(sets.Constraints("foo").Sets, DeepEquals, []string{"can0nical/one=2", "can0nical/two=2"})
+}
+
+func (s *validationSetsSuite) TestConflict(c *C) {
+	sets := snapasserts.NewValidationSets()
+	sets.Add(s.mockValidationSet(c, "one", "1", snapEntry("foo", "required", "")))
+	sets.Add(s.mockValidationSet(c, "two", "1", snapEntry("foo", "invalid", "")))
+	c.Check(sets.Conflict(), ErrorMatches, `validation sets are in conflict: snap "foo" is invalid in can0nical/two=1 but allowed in can0nical/one=1`)
+
+	sets = snapasserts.NewValidationSets()
+	sets.Add(s.mockValidationSet(c, "one", "1", snapEntry("foo", "required", "1")))
+	sets.Add(s.mockValidationSet(c, "two", "1", snapEntry("foo", "optional", "2")))
+	c.Check(sets.Conflict(), ErrorMatches, `validation sets are in conflict: snap "foo" is required at revision 1 in can0nical/one=1 and revision 2 in can0nical/two=1`)
+}
+
+func (s *validationSetsSuite) TestCheckInstalledSnaps(c *C) {
+	sets := snapasserts.NewValidationSets()
+	sets.Add(s.mockValidationSet(c, "one", "1",
+		snapEntry("foo", "required", ""),
+		snapEntry("bar", "optional", "3"),
+		snapEntry("baz", "invalid", ""),
+	))
+
+	err := sets.CheckInstalledSnaps([]*snapasserts.InstalledSnap{
+		{Name: "foo", SnapID: "foo-id", Revision: snap.R(1)},
+		{Name: "bar", SnapID: "bar-id", Revision: snap.R(3)},
+		{Name: "other", SnapID: "other-id", Revision: snap.R(5)},
+	})
+	c.Check(err, IsNil)
+
+	// optional snaps can be missing
+	err = sets.CheckInstalledSnaps([]*snapasserts.InstalledSnap{
+		{Name: "foo", SnapID: "foo-id", Revision: snap.R(1)},
+	})
+	c.Check(err, IsNil)
+
+	err = sets.CheckInstalledSnaps([]*snapasserts.InstalledSnap{
+		{Name: "bar", SnapID: "bar-id", Revision: snap.R(4)},
+		{Name: "baz", SnapID: "baz-id", Revision: snap.R(1)},
+	})
+	c.Assert(err, FitsTypeOf, &snapasserts.ValidationSetsValidationError{})
+	verr := err.(*snapasserts.ValidationSetsValidationError)
+	c.Check(verr.MissingSnaps, DeepEquals, map[string][]string{"foo": {"can0nical/one=1"}})
+	c.Check(verr.InvalidSnaps, DeepEquals, map[string][]string{"baz": {"can0nical/one=1"}})
+	c.Check(verr.WrongRevisionSnaps, DeepEquals, map[string]*snapasserts.WrongRevision{
+		"bar": {Installed: snap.R(4), Required: snap.R(3), Sets: []string{"can0nical/one=1"}},
+	})
+	c.Check(err, ErrorMatches, `validation sets assertions are not met:
+- missing required snap "foo" \(required by can0nical/one=1\)
+- invalid snap "baz" is installed \(invalid for can0nical/one=1\)
+- snap "bar" is at revision 4 instead of 3 \(required by can0nical/one=1\)`)
+}
@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Presence represents a presence constraint of a snap in a
+// validation-set assertion.
+type Presence string
+
+const (
+	// PresenceRequired means the snap must be installed.
+	PresenceRequired Presence = "required"
+	// PresenceOptional means the snap may or may not be installed.
+	PresenceOptional Presence = "optional"
+	// PresenceInvalid means the snap must not be installed.
+	PresenceInvalid Presence = "invalid"
+)
+
+func (p Presence) valid() bool {
+	switch p {
+	case PresenceRequired, PresenceOptional, PresenceInvalid:
+		return true
+	}
+	return false
+}
+
+// ValidationSetSnap holds the details about a snap constrained by a
+// validation-set assertion.
+type ValidationSetSnap struct {
+	Name   string
+	SnapID string
+
+	Presence Presence
+
+	// Revision is the revision the snap must be at if installed,
+	// 0 if any revision will do.
+	Revision int
+}
+
+// ValidationSet holds a validation-set assertion, which declares a
+// set of snaps that must be, may be or must not be installed,
+// possibly at given revisions, as one signed unit.
+type ValidationSet struct {
+	assertionBase
+	sequence  int
+	snaps     []*ValidationSetSnap
+	timestamp time.Time
+}
+
+// Series returns the series for which the validation set holds.
+func (vs *ValidationSet) Series() string {
+	return vs.HeaderString("series")
+}
+
+// AccountID returns the identifier of the account that issued the
+// validation set.
+func (vs *ValidationSet) AccountID() string {
+	return vs.HeaderString("account-id")
+}
+
+// Name returns the name of the validation set.
+func (vs *ValidationSet) Name() string {
+	return vs.HeaderString("name")
+}
+
+// Sequence returns the sequence number of this revision of the
+// validation set, later ones supersede earlier ones.
+func (vs *ValidationSet) Sequence() int {
+	return vs.sequence
+}
+
+// Snaps returns the snaps constrained by the validation set.
+func (vs *ValidationSet) Snaps() []*ValidationSetSnap {
+	return vs.snaps
+}
+
+// Timestamp returns the time when the validation set was issued.
+func (vs *ValidationSet) Timestamp() time.Time {
+	return vs.timestamp
+}
+
+// Implement further consistency checks.
+func (vs *ValidationSet) checkConsistency(db RODatabase, acck *AccountKey) error {
+	if !db.IsTrustedAccount(vs.AuthorityID()) {
+		_, err := db.Find(AccountType, map[string]string{
+			"account-id": vs.AccountID(),
+		})
+		if err == ErrNotFound {
+			return fmt.Errorf("validation-set assertion %q does not have a matching account assertion for %q", vs.Name(), vs.AccountID())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sanity
+var _ consistencyChecker = (*ValidationSet)(nil)
+
+// Prerequisites returns references to this validation set's prerequisite assertions.
+func (vs *ValidationSet) Prerequisites() []*Ref {
+	return []*Ref{
+		{Type: AccountType, PrimaryKey: []string{vs.AccountID()}},
+	}
+}
+
+var (
+	validValidationSetName = regexp.MustCompile("^[a-z0-9](?:-?[a-z0-9])*$")
+	// same as snap.ValidateName
+	validValidationSetSnapName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+)
+
+func checkValidationSetSnap(snap map[string]interface{}) (*ValidationSetSnap, error) {
+	name, err := checkStringMatches(snap, "name", validValidationSetSnapName)
+	if err != nil {
+		return nil, err
+	}
+	snapID, err := checkNotEmptyString(snap, "id")
+	if err != nil {
+		return nil, err
+	}
+	presence, err := checkOptionalString(snap, "presence")
+	if err != nil {
+		return nil, err
+	}
+	if presence == "" {
+		presence = string(PresenceRequired)
+	}
+	if !Presence(presence).valid() {
+		return nil, fmt.Errorf(`"presence" header must be one of required, optional or invalid: %q`, presence)
+	}
+	revision, err := checkIntWithDefault(snap, "revision", 0)
+	if err != nil {
+		return nil, err
+	}
+	if revision < 0 {
+		return nil, fmt.Errorf(`"revision" header must be >=1: %d`, revision)
+	}
+	if revision != 0 && Presence(presence) == PresenceInvalid {
+		return nil, fmt.Errorf("cannot specify a revision for an invalid snap")
+	}
+	return &ValidationSetSnap{
+		Name:     name,
+		SnapID:   snapID,
+		Presence: Presence(presence),
+		Revision: revision,
+	}, nil
+}
+
+func checkValidationSetSnaps(headers map[string]interface{}) ([]*ValidationSetSnap, error) {
+	value, ok := headers["snaps"]
+	if !ok {
+		return nil, fmt.Errorf(`"snaps" header is mandatory`)
+	}
+	lst, ok := value.([]interface{})
+	if !ok || len(lst) == 0 {
+		return nil, fmt.Errorf(`"snaps" header must be a non-empty list of maps`)
+	}
+	snaps := make([]*ValidationSetSnap, len(lst))
+	seenNames := make(map[string]bool, len(lst))
+	seenIDs := make(map[string]bool, len(lst))
+	for i, v := range lst {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"snaps" header must be a non-empty list of maps`)
+		}
+		snap, err := checkValidationSetSnap(m)
+		if err != nil {
+			return nil, fmt.Errorf("cannot accept snap %d in validation set: %v", i+1, err)
+		}
+		if seenNames[snap.Name] || seenIDs[snap.SnapID] {
+			return nil, fmt.Errorf("cannot list the same snap %q multiple times in a validation set", snap.Name)
+		}
+		seenNames[snap.Name] = true
+		seenIDs[snap.SnapID] = true
+		snaps[i] = snap
+	}
+	return snaps, nil
+}
+
+func assembleValidationSet(assert assertionBase) (Assertion, error) {
+	accountID := assert.HeaderString("account-id")
+	if accountID != assert.AuthorityID() {
+		return nil, fmt.Errorf("authority-id and account-id must match, validation-set assertions are expected to be signed by the issuer account: %q != %q", assert.AuthorityID(), accountID)
+	}
+
+	if _, err := checkStringMatches(assert.headers, "name", validValidationSetName); err != nil {
+		return nil, err
+	}
+
+	sequence, err := checkInt(assert.headers, "sequence")
+	if err != nil {
+		return nil, err
+	}
+	if sequence < 1 {
+		return nil, fmt.Errorf(`"sequence" header must be >=1: %d`, sequence)
+	}
+
+	snaps, err := checkValidationSetSnaps(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValidationSet{
+		assertionBase: assert,
+		sequence:      sequence,
+		snaps:         snaps,
+		timestamp:     timestamp,
+	}, nil
+}
@@ -0,0 +1,180 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+)
+
+type validationSetSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&validationSetSuite{})
+
+func (vss *validationSetSuite) SetUpSuite(c *C) {
+	vss.ts = time.Now().Truncate(time.Second).UTC()
+	vss.tsLine = "timestamp: " + vss.ts.Format(time.RFC3339) + "\n"
+}
+
+const validationSetSnaps = "snaps:\n" +
+	"  -\n" +
+	"    name: foo\n" +
+	"    id: snap-id-1\n" +
+	"    revision: 7\n" +
+	"  -\n" +
+	"    name: bar\n" +
+	"    id: snap-id-2\n" +
+	"    presence: optional\n" +
+	"  -\n" +
+	"    name: baz\n" +
+	"    id: snap-id-3\n" +
+	"    presence: invalid\n"
+
+func (vss *validationSetSuite) makeValidEncoded() string {
+	return "type: validation-set\n" +
+		"authority-id: dev-id1\n" +
+		"series: 16\n" +
+		"account-id: dev-id1\n" +
+		"name: base-set\n" +
+		"sequence: 2\n" +
+		validationSetSnaps +
+		vss.tsLine +
+		"sign-key-sha3-384: Jv8_JiHiIzJVcO9M55pPdqSDWUvuhfDIBJUS-3VW7F_idjix7Ffn5qMxB21ZQuij" +
+		"\n\n" +
+		"AXNpZw=="
+}
+
+func (vss *validationSetSuite) makeHeaders(overrides map[string]interface{}) map[string]interface{} {
+	headers := map[string]interface{}{
+		"authority-id": "dev-id1",
+		"series":       "16",
+		"account-id":   "dev-id1",
+		"name":         "base-set",
+		"sequence":     "1",
+		"snaps": []interface{}{
+			map[string]interface{}{
+				"name":     "foo",
+				"id":       "snap-id-1",
+				"revision": "7",
+			},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}
+	for k, v := range overrides {
+		headers[k] = v
+	}
+	return headers
+}
+
+func (vss *validationSetSuite) TestDecodeOK(c *C) {
+	encoded := vss.makeValidEncoded()
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.ValidationSetType)
+	vs := a.(*asserts.ValidationSet)
+	c.Check(vs.AuthorityID(), Equals, "dev-id1")
+	c.Check(vs.Timestamp(), Equals, vss.ts)
+	c.Check(vs.Series(), Equals, "16")
+	c.Check(vs.AccountID(), Equals, "dev-id1")
+	c.Check(vs.Name(), Equals, "base-set")
+	c.Check(vs.Sequence(), Equals, 2)
+	c.Check(vs.Snaps(), DeepEquals, []*asserts.ValidationSetSnap{
+		{Name: "foo", SnapID: "snap-id-1", Presence: asserts.PresenceRequired, Revision: 7},
+		{Name: "bar", SnapID: "snap-id-2", Presence: asserts.PresenceOptional},
+		{Name: "baz", SnapID: "snap-id-3", Presence: asserts.PresenceInvalid},
+	})
+	c.Check(vs.Prerequisites(), DeepEquals, []*asserts.Ref{
+		{Type: asserts.AccountType, PrimaryKey: []string{"dev-id1"}},
+	})
+}
+
+const validationSetErrPrefix = "assertion validation-set: "
+
+func (vss *validationSetSuite) TestDecodeInvalid(c *C) {
+	encoded := vss.makeValidEncoded()
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"series: 16\n", "", `"series" header is mandatory`},
+		{"account-id: dev-id1\n", "", `"account-id" header is mandatory`},
+		{"account-id: dev-id1\n", "account-id: other\n", `authority-id and account-id must match, validation-set assertions are expected to be signed by the issuer account: "dev-id1" != "other"`},
+		{"name: base-set\n", "", `"name" header is mandatory`},
+		{"name: base-set\n", "name: Base_Set\n", `"name" header contains invalid characters: "Base_Set"`},
+		{"sequence: 2\n", "", `"sequence" header is mandatory`},
+		{"sequence: 2\n", "sequence: z\n", `"sequence" header is not an integer: z`},
+		{"sequence: 2\n", "sequence: 0\n", `"sequence" header must be >=1: 0`},
+		{validationSetSnaps, "", `"snaps" header is mandatory`},
+		{validationSetSnaps, "snaps: foo\n", `"snaps" header must be a non-empty list of maps`},
+		{validationSetSnaps, "snaps:\n  - foo\n", `"snaps" header must be a non-empty list of maps`},
+		{"    name: foo\n", "", `cannot accept snap 1 in validation set: "name" header is mandatory`},
+		{"    name: foo\n", "    name: Foo\n", `cannot accept snap 1 in validation set: "name" header contains invalid characters: "Foo"`},
+		{"    id: snap-id-1\n", "", `cannot accept snap 1 in validation set: "id" header is mandatory`},
+		{"    revision: 7\n", "    revision: z\n", `cannot accept snap 1 in validation set: "revision" header is not an integer: z`},
+		{"    revision: 7\n", "    revision: -1\n", `cannot accept snap 1 in validation set: "revision" header must be >=1: -1`},
+		{"    presence: optional\n", "    presence: maybe\n", `cannot accept snap 2 in validation set: "presence" header must be one of required, optional or invalid: "maybe"`},
+		{"    presence: invalid\n", "    presence: invalid\n    revision: 1\n", `cannot accept snap 3 in validation set: cannot specify a revision for an invalid snap`},
+		{"    name: bar\n", "    name: foo\n", `cannot list the same snap "foo" multiple times in a validation set`},
+		{"    id: snap-id-2\n", "    id: snap-id-1\n", `cannot list the same snap "bar" multiple times in a validation set`},
+		{vss.tsLine, "", `"timestamp" header is mandatory`},
+		{vss.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, validationSetErrPrefix+test.expectedErr)
+	}
+}
+
+func (vss *validationSetSuite) TestValidationSetCheck(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+	devDB := setup3rdPartySigning(c, "dev-id1", storeDB, db)
+
+	vs, err := devDB.Sign(asserts.ValidationSetType, vss.makeHeaders(nil), nil, "")
+	c.Assert(err, IsNil)
+
+	err = db.Check(vs)
+	c.Assert(err, IsNil)
+}
+
+func (vss *validationSetSuite) TestValidationSetCheckUntrustedAuthority(c *C) {
+	storeDB, db := makeStoreAndCheckDB(c)
+	otherDB := setup3rdPartySigning(c, "other", storeDB, db)
+
+	headers := vss.makeHeaders(map[string]interface{}{
+		"authority-id": "other",
+		"account-id":   "other",
+	})
+	vs, err := otherDB.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, IsNil)
+
+	err = db.Check(vs)
+	c.Assert(err, IsNil)
+
+	headers = vss.makeHeaders(nil)
+	_, err = otherDB.Sign(asserts.ValidationSetType, headers, nil, "")
+	c.Assert(err, NotNil)
+}
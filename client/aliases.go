@@ -0,0 +1,83 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// AliasStatus describes an alias of a snap app.
+type AliasStatus struct {
+	App     string `json:"app"`
+	Command string `json:"command"`
+	// Status is one of "manual", "auto" or "disabled".
+	Status string `json:"status"`
+}
+
+// Aliases returns the aliases of the installed snaps, indexed by snap
+// name and then by alias name.
+func (client *Client) Aliases() (map[string]map[string]AliasStatus, error) {
+	var aliases map[string]map[string]AliasStatus
+	_, err := client.doSync("GET", "/v2/aliases", nil, nil, nil, &aliases)
+	if err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+type aliasAction struct {
+	Action string `json:"action"`
+	Snap   string `json:"snap,omitempty"`
+	App    string `json:"app,omitempty"`
+	Alias  string `json:"alias"`
+}
+
+// Alias enables the alias for the given app of the snap.
+func (client *Client) Alias(snapName, app, alias string) (changeID string, err error) {
+	return client.aliasAction(&aliasAction{
+		Action: "alias",
+		Snap:   snapName,
+		App:    app,
+		Alias:  alias,
+	})
+}
+
+// Unalias disables the given alias.
+func (client *Client) Unalias(alias string) (changeID string, err error) {
+	return client.aliasAction(&aliasAction{
+		Action: "unalias",
+		Alias:  alias,
+	})
+}
+
+func (client *Client) aliasAction(action *aliasAction) (changeID string, err error) {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal alias action: %v", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	return client.doAsync("POST", "/v2/aliases", nil, headers, bytes.NewBuffer(data))
+}
@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientAliases(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"foo": {"foo": {"app": "foo", "command": "foo", "status": "auto"}, "bar": {"app": "bar", "command": "foo.bar", "status": "manual"}}}}`
+
+	aliases, err := cs.cli.Aliases()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aliases")
+	c.Check(aliases, check.DeepEquals, map[string]map[string]client.AliasStatus{
+		"foo": {
+			"foo": {App: "foo", Command: "foo", Status: "auto"},
+			"bar": {App: "bar", Command: "foo.bar", Status: "manual"},
+		},
+	})
+}
+
+func (cs *clientSuite) TestClientAlias(c *check.C) {
+	cs.status = http.StatusAccepted
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "d"}`
+
+	changeID, err := cs.cli.Alias("foo", "bar", "baz")
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "d")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aliases")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "alias",
+		"snap":   "foo",
+		"app":    "bar",
+		"alias":  "baz",
+	})
+}
+
+func (cs *clientSuite) TestClientUnalias(c *check.C) {
+	cs.status = http.StatusAccepted
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "d"}`
+
+	changeID, err := cs.cli.Unalias("baz")
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "d")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/aliases")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "unalias",
+		"alias":  "baz",
+	})
+}
@@ -0,0 +1,197 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// AppOptions represent the options of the Apps call.
+type AppOptions struct {
+	// If Service is true, only return apps that are services
+	// (app.IsService() is true); otherwise, return all apps.
+	Service bool
+}
+
+// Apps returns information about all matching apps. Each name can be
+// either a snap or a snap.app. If names is empty, list all (that
+// satisfy opts).
+func (client *Client) Apps(names []string, opts AppOptions) ([]*AppInfo, error) {
+	q := make(url.Values)
+	if len(names) > 0 {
+		q.Add("names", strings.Join(names, ","))
+	}
+	if opts.Service {
+		q.Add("select", "service")
+	}
+
+	var appInfos []*AppInfo
+	_, err := client.doSync("GET", "/v2/apps", q, nil, nil, &appInfos)
+
+	return appInfos, err
+}
+
+// LogOptions represent the options of the Logs call.
+type LogOptions struct {
+	N      int  // The maximum number of log lines to retrieve initially. If <0, no limit.
+	Follow bool // Whether to continue returning new lines as they appear
+}
+
+// A Log holds the information of a single syslog entry
+type Log struct {
+	Timestamp string `json:"timestamp"` // Timestamp of the event, in RFC3339 format to µs precision.
+	Message   string `json:"message"`   // The log message itself
+	SID       string `json:"sid"`       // The syslog identifier
+	PID       string `json:"pid"`       // The process identifier
+}
+
+func (l Log) String() string {
+	return fmt.Sprintf("%s %s[%s]: %s", l.Timestamp, l.SID, l.PID, l.Message)
+}
+
+// Logs asks for the logs of a series of services, by name.
+func (client *Client) Logs(names []string, opts LogOptions) (<-chan Log, error) {
+	query := url.Values{}
+	if len(names) > 0 {
+		query.Set("names", strings.Join(names, ","))
+	}
+	if opts.N < 0 {
+		query.Set("n", "all")
+	} else {
+		query.Set("n", strconv.Itoa(opts.N))
+	}
+	if opts.Follow {
+		query.Set("follow", strconv.FormatBool(opts.Follow))
+	}
+
+	rsp, err := client.raw("GET", "/v2/logs", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != 200 {
+		defer rsp.Body.Close()
+		return nil, parseError(rsp)
+	}
+
+	ch := make(chan Log, 20)
+	go func() {
+		// logs come in application/json-seq, described in RFC7464: it's
+		// a series of <RS><arbitrary, valid JSON><LF>. Decoders are
+		// expected to skip invalid or truncated or empty records.
+		scanner := bufio.NewScanner(rsp.Body)
+		for scanner.Scan() {
+			buf := scanner.Bytes() // the scanner prunes the ending LF
+			if len(buf) < 1 {
+				// truncated record? skip
+				continue
+			}
+			idx := bytes.IndexByte(buf, 0x1E) // find the initial RS
+			if idx < 0 {
+				// no RS? skip
+				continue
+			}
+			buf = buf[idx+1:] // drop the initial RS
+			var log Log
+			if err := json.Unmarshal(buf, &log); err != nil {
+				// truncated/corrupted/binary record? skip
+				continue
+			}
+			ch <- log
+		}
+		close(ch)
+		rsp.Body.Close()
+	}()
+
+	return ch, nil
+}
+
+// ErrNoNames is returned by Start, Stop, or Restart, when the given
+// list of things on which to operate is empty.
+var ErrNoNames = fmt.Errorf(`"names" must not be empty`)
+
+type appInstruction struct {
+	Action  string   `json:"action"`
+	Names   []string `json:"names"`
+	Enable  bool     `json:"enable,omitempty"`
+	Disable bool     `json:"disable,omitempty"`
+}
+
+// StartOptions represent the different options of the Start call.
+type StartOptions struct {
+	// Enable, as well as starting, the listed services. A
+	// disabled service does not start on boot.
+	Enable bool
+}
+
+// Start services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are started, or snap.service which are individual
+// services to start; it shouldn't be empty.
+func (client *Client) Start(names []string, opts StartOptions) (changeID string, err error) {
+	return client.doApps("start", names, appInstruction{Enable: opts.Enable})
+}
+
+// StopOptions represent the different options of the Stop call.
+type StopOptions struct {
+	// Disable, as well as stopping, the listed services. A
+	// service that is not disabled starts on boot.
+	Disable bool
+}
+
+// Stop services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are stopped, or snap.service which are individual
+// services to stop; it shouldn't be empty.
+func (client *Client) Stop(names []string, opts StopOptions) (changeID string, err error) {
+	return client.doApps("stop", names, appInstruction{Disable: opts.Disable})
+}
+
+// Restart services.
+//
+// It takes a list of names that can be snaps, of which all their
+// services are restarted, or snap.service which are individual
+// services to restart; it shouldn't be empty.
+func (client *Client) Restart(names []string) (changeID string, err error) {
+	return client.doApps("restart", names, appInstruction{})
+}
+
+func (client *Client) doApps(action string, names []string, inst appInstruction) (changeID string, err error) {
+	if len(names) == 0 {
+		return "", ErrNoNames
+	}
+	inst.Action = action
+	inst.Names = names
+
+	buf, err := json.Marshal(&inst)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal %s instruction: %v", action, err)
+	}
+
+	return client.doAsync("POST", "/v2/apps", nil, nil, bytes.NewReader(buf))
+}
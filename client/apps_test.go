@@ -0,0 +1,141 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientApps(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"snap": "foo", "name": "bar", "daemon": "simple", "enabled": true, "active": true}, {"snap": "foo", "name": "baz"}]}`
+
+	apps, err := cs.cli.Apps([]string{"foo"}, client.AppOptions{Service: true})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/apps")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"names": {"foo"}, "select": {"service"}})
+	c.Check(apps, check.DeepEquals, []*client.AppInfo{
+		{Snap: "foo", Name: "bar", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "foo", Name: "baz"},
+	})
+	c.Check(apps[0].IsService(), check.Equals, true)
+	c.Check(apps[1].IsService(), check.Equals, false)
+}
+
+func (cs *clientSuite) TestClientAppsNoNames(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+
+	_, err := cs.cli.Apps(nil, client.AppOptions{})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
+
+func (cs *clientSuite) TestClientLogs(c *check.C) {
+	cs.rsp = "" +
+		"\x1e" + `{"timestamp": "2016-11-02T10:16:05.000000Z", "message": "hello", "sid": "foo", "pid": "42"}` + "\n" +
+		"\x1e" + `not json` + "\n" +
+		"no RS" + "\n" +
+		"\x1e" + `{"timestamp": "2016-11-02T10:16:06.000000Z", "message": "world", "sid": "foo", "pid": "42"}` + "\n"
+
+	ch, err := cs.cli.Logs([]string{"foo.bar"}, client.LogOptions{N: -1, Follow: true})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/logs")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"names": {"foo.bar"}, "n": {"all"}, "follow": {"true"}})
+
+	var logs []client.Log
+	for l := range ch {
+		logs = append(logs, l)
+	}
+	c.Check(logs, check.DeepEquals, []client.Log{
+		{Timestamp: "2016-11-02T10:16:05.000000Z", Message: "hello", SID: "foo", PID: "42"},
+		{Timestamp: "2016-11-02T10:16:06.000000Z", Message: "world", SID: "foo", PID: "42"},
+	})
+	c.Check(logs[0].String(), check.Equals, "2016-11-02T10:16:05.000000Z foo[42]: hello")
+}
+
+func (cs *clientSuite) TestClientLogsError(c *check.C) {
+	cs.status = http.StatusNotFound
+	cs.header = http.Header{"Content-Type": {"application/json"}}
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "snap \"foo\" not found"}}`
+
+	_, err := cs.cli.Logs([]string{"foo"}, client.LogOptions{N: 10})
+	c.Assert(err, check.ErrorMatches, `snap "foo" not found`)
+	c.Check(cs.req.URL.Query().Get("n"), check.Equals, "10")
+}
+
+func (cs *clientSuite) testClientAppsOp(c *check.C, op func() (string, error), expected map[string]interface{}) {
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "24"}`
+
+	changeID, err := op()
+	c.Assert(err, check.IsNil)
+	c.Check(changeID, check.Equals, "24")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/apps")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, expected)
+}
+
+func (cs *clientSuite) TestClientStart(c *check.C) {
+	cs.testClientAppsOp(c, func() (string, error) {
+		return cs.cli.Start([]string{"foo", "bar.baz"}, client.StartOptions{Enable: true})
+	}, map[string]interface{}{
+		"action": "start",
+		"names":  []interface{}{"foo", "bar.baz"},
+		"enable": true,
+	})
+}
+
+func (cs *clientSuite) TestClientStop(c *check.C) {
+	cs.testClientAppsOp(c, func() (string, error) {
+		return cs.cli.Stop([]string{"foo"}, client.StopOptions{Disable: true})
+	}, map[string]interface{}{
+		"action":  "stop",
+		"names":   []interface{}{"foo"},
+		"disable": true,
+	})
+}
+
+func (cs *clientSuite) TestClientRestart(c *check.C) {
+	cs.testClientAppsOp(c, func() (string, error) {
+		return cs.cli.Restart([]string{"foo"})
+	}, map[string]interface{}{
+		"action": "restart",
+		"names":  []interface{}{"foo"},
+	})
+}
+
+func (cs *clientSuite) TestClientAppsOpNoNames(c *check.C) {
+	_, err := cs.cli.Start(nil, client.StartOptions{})
+	c.Check(err, check.Equals, client.ErrNoNames)
+	_, err = cs.cli.Stop(nil, client.StopOptions{})
+	c.Check(err, check.Equals, client.ErrNoNames)
+	_, err = cs.cli.Restart(nil)
+	c.Check(err, check.Equals, client.ErrNoNames)
+	c.Check(cs.req, check.IsNil)
+}
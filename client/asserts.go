@@ -43,6 +43,29 @@ func (client *Client) Ack(b []byte) error {
 	return nil
 }
 
+// AssertionRef identifies an assertion by type and primary key.
+type AssertionRef struct {
+	Type       string   `json:"type"`
+	PrimaryKey []string `json:"primary-key"`
+}
+
+// AckBundle tries to add a bundle of assertions, such as the output of
+// "snap known --remote --bundle", to the system assertion database.
+// Either all the new assertions in the bundle are added or none is;
+// on success the references of the ones that were added are returned,
+// prerequisites first.
+func (client *Client) AckBundle(b []byte) ([]AssertionRef, error) {
+	var rsp struct {
+		Added []AssertionRef `json:"added"`
+	}
+	q := url.Values{"bundle": []string{"true"}}
+	if _, err := client.doSync("POST", "/v2/assertions", q, nil, bytes.NewReader(b), &rsp); err != nil {
+		return nil, err
+	}
+
+	return rsp.Added, nil
+}
+
 // Known queries assertions with type assertTypeName and matching assertion headers.
 func (client *Client) Known(assertTypeName string, headers map[string]string) ([]asserts.Assertion, error) {
 	path := fmt.Sprintf("/v2/assertions/%s", assertTypeName)
@@ -28,6 +28,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
 )
 
 func (cs *clientSuite) TestClientAssert(c *C) {
@@ -45,6 +46,29 @@ func (cs *clientSuite) TestClientAssert(c *C) {
 	c.Check(cs.req.URL.Path, Equals, "/v2/assertions")
 }
 
+func (cs *clientSuite) TestClientAckBundle(c *C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"added": [
+			{"type": "account-key", "primary-key": ["key-id"]},
+			{"type": "account", "primary-key": ["acct-id"]}
+		]}
+	}`
+	a := []byte("Assertion.")
+	added, err := cs.cli.AckBundle(a)
+	c.Assert(err, IsNil)
+	c.Check(added, DeepEquals, []client.AssertionRef{
+		{Type: "account-key", PrimaryKey: []string{"key-id"}},
+		{Type: "account", PrimaryKey: []string{"acct-id"}},
+	})
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, IsNil)
+	c.Check(body, DeepEquals, a)
+	c.Check(cs.req.Method, Equals, "POST")
+	c.Check(cs.req.URL.Path, Equals, "/v2/assertions")
+	c.Check(cs.req.URL.Query().Get("bundle"), Equals, "true")
+}
+
 func (cs *clientSuite) TestClientAssertsCallsEndpoint(c *C) {
 	_, _ = cs.cli.Known("snap-revision", nil)
 	c.Check(cs.req.Method, Equals, "GET")
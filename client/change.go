@@ -36,9 +36,13 @@ type Change struct {
 	Tasks   []*Task `json:"tasks,omitempty"`
 	Ready   bool    `json:"ready"`
 	Err     string  `json:"err,omitempty"`
+	// ErrKind is the kind of the error, if it is of a known one
+	ErrKind string `json:"err-kind,omitempty"`
 
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
+	// AtTime is when the change is scheduled to run, if it was scheduled
+	AtTime time.Time `json:"at-time,omitempty"`
 
 	data map[string]*json.RawMessage
 }
@@ -63,6 +67,11 @@ type Task struct {
 	Log      []string     `json:"log,omitempty"`
 	Progress TaskProgress `json:"progress"`
 
+	// Retries is how many times the task was retried after failing,
+	// with LastError the error the last of those attempts failed with.
+	Retries   int    `json:"retries,omitempty"`
+	LastError string `json:"last-error,omitempty"`
+
 	SpawnTime time.Time `json:"spawn-time,omitempty"`
 	ReadyTime time.Time `json:"ready-time,omitempty"`
 }
@@ -134,6 +143,11 @@ const (
 type ChangesOptions struct {
 	SnapName string // if empty, no filtering by name is done
 	Selector ChangeSelector
+	// History includes the changes that were pruned from the state,
+	// as recorded in the audit log.
+	History bool
+	// Since leaves out the changes that were ready before it.
+	Since time.Time
 }
 
 func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
@@ -145,6 +159,9 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 		if opts.SnapName != "" {
 			query.Set("for", opts.SnapName)
 		}
+		if opts.History || !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339))
+		}
 	}
 
 	var chgds []changeAndData
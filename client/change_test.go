@@ -24,6 +24,7 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"io/ioutil"
+	"net/url"
 	"time"
 )
 
@@ -111,6 +112,23 @@ func (cs *clientSuite) TestClientChangeError(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientChangeErrorKind(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Error",
+  "ready": true,
+  "err": "error message",
+  "err-kind": "hook-timeout"
+}}`
+
+	chg, err := cs.cli.Change("uno")
+	c.Assert(err, check.IsNil)
+	c.Check(chg.Err, check.Equals, "error message")
+	c.Check(chg.ErrKind, check.Equals, client.ErrorKindHookTimeout)
+}
+
 func (cs *clientSuite) TestClientChangesString(c *check.C) {
 	for k, v := range map[client.ChangeSelector]string{
 		client.ChangesAll:        "all",
@@ -160,6 +178,25 @@ func (cs *clientSuite) TestClientChanges(c *check.C) {
 
 }
 
+func (cs *clientSuite) TestClientChangesHistory(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+
+	_, err := cs.cli.Changes(&client.ChangesOptions{Selector: client.ChangesAll, History: true})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"all"},
+		"since":  []string{"0001-01-01T00:00:00Z"},
+	})
+
+	since := time.Date(2017, 4, 5, 6, 7, 8, 0, time.UTC)
+	_, err = cs.cli.Changes(&client.ChangesOptions{Selector: client.ChangesAll, Since: since})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"all"},
+		"since":  []string{"2017-04-05T06:07:08Z"},
+	})
+}
+
 func (cs *clientSuite) TestClientChangesData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": [{
   "id":   "uno",
@@ -245,25 +245,32 @@ func (client *Client) doSync(method, path string, query url.Values, headers map[
 }
 
 func (client *Client) doAsync(method, path string, query url.Values, headers map[string]string, body io.Reader) (changeID string, err error) {
+	_, changeID, err = client.doAsyncFull(method, path, query, headers, body)
+	return changeID, err
+}
+
+// doAsyncFull is like doAsync but also returns the (possibly empty)
+// result that accompanies the change reference.
+func (client *Client) doAsyncFull(method, path string, query url.Values, headers map[string]string, body io.Reader) (result json.RawMessage, changeID string, err error) {
 	var rsp response
 
 	if err := client.do(method, path, query, headers, body, &rsp); err != nil {
-		return "", err
+		return nil, "", err
 	}
 	if err := rsp.err(); err != nil {
-		return "", err
+		return nil, "", err
 	}
 	if rsp.Type != "async" {
-		return "", fmt.Errorf("expected async response for %q on %q, got %q", method, path, rsp.Type)
+		return nil, "", fmt.Errorf("expected async response for %q on %q, got %q", method, path, rsp.Type)
 	}
 	if rsp.StatusCode != http.StatusAccepted {
-		return "", fmt.Errorf("operation not accepted")
+		return nil, "", fmt.Errorf("operation not accepted")
 	}
 	if rsp.Change == "" {
-		return "", fmt.Errorf("async response without change reference")
+		return nil, "", fmt.Errorf("async response without change reference")
 	}
 
-	return rsp.Change, nil
+	return rsp.Result, rsp.Change, nil
 }
 
 type ServerVersion struct {
@@ -303,8 +310,9 @@ type response struct {
 
 // Error is the real value of response.Result when an error occurs.
 type Error struct {
-	Kind    string `json:"kind"`
-	Message string `json:"message"`
+	Kind    string      `json:"kind"`
+	Message string      `json:"message"`
+	Value   interface{} `json:"value"`
 
 	StatusCode int
 }
@@ -320,6 +328,12 @@ const (
 	ErrorKindTermsNotAccepted  = "terms-not-accepted"
 	ErrorKindNoPaymentMethods  = "no-payment-methods"
 	ErrorKindPaymentDeclined   = "payment-declined"
+
+	ErrorKindHookTimeout = "hook-timeout"
+
+	ErrorKindChangeConflict = "change-conflict"
+
+	ErrorKindSnapNotFound = "snap-not-found"
 )
 
 // IsTwoFactorError returns whether the given error is due to problems
@@ -333,6 +347,39 @@ func IsTwoFactorError(err error) bool {
 	return e.Kind == ErrorKindTwoFactorFailed || e.Kind == ErrorKindTwoFactorRequired
 }
 
+// IsSnapNotFound returns whether the given error is due to the
+// requested snap not being found.
+func IsSnapNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e != nil && e.Kind == ErrorKindSnapNotFound
+}
+
+// wrapError prefixes the message of err with the given context,
+// keeping its kind if it is an *Error.
+func wrapError(err error, format string, v ...interface{}) error {
+	msg := fmt.Sprintf(format, v...)
+	e, ok := err.(*Error)
+	if !ok || e == nil {
+		return fmt.Errorf("%s: %s", msg, err)
+	}
+	wrapped := *e
+	wrapped.Message = fmt.Sprintf("%s: %s", msg, e.Message)
+	return &wrapped
+}
+
+// ConflictingChange returns the ID of the change in progress that
+// prevented the operation that failed with the given error, or an
+// empty string if the error is not due to such a conflict.
+func ConflictingChange(err error) string {
+	e, ok := err.(*Error)
+	if !ok || e == nil || e.Kind != ErrorKindChangeConflict {
+		return ""
+	}
+	value, _ := e.Value.(map[string]interface{})
+	changeID, _ := value["change-id"].(string)
+	return changeID
+}
+
 // OSRelease contains information about the system extracted from /etc/os-release.
 type OSRelease struct {
 	ID        string `json:"id"`
@@ -346,6 +393,16 @@ type SysInfo struct {
 	OSRelease OSRelease `json:"os-release"`
 	OnClassic bool      `json:"on-classic"`
 	Managed   bool      `json:"managed"`
+
+	Refresh RefreshInfo `json:"refresh,omitempty"`
+}
+
+// RefreshInfo holds the auto-refresh schedule and the times of the last
+// and next auto-refresh, if known.
+type RefreshInfo struct {
+	Schedule string `json:"schedule"`
+	Last     string `json:"last,omitempty"`
+	Next     string `json:"next,omitempty"`
 }
 
 func (rsp *response) err() error {
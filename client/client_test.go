@@ -156,7 +156,8 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
                      {"series": "16",
                       "version": "2",
                       "os-release": {"id": "ubuntu", "version-id": "16.04"},
-                      "on-classic": true}}`
+                      "on-classic": true,
+                      "refresh": {"schedule": "00:00-04:59", "last": "2017-03-01T02:30:00Z"}}}`
 	sysInfo, err := cs.cli.SysInfo()
 	c.Check(err, IsNil)
 	c.Check(sysInfo, DeepEquals, &client.SysInfo{
@@ -167,6 +168,10 @@ func (cs *clientSuite) TestClientSysInfo(c *C) {
 			VersionID: "16.04",
 		},
 		OnClassic: true,
+		Refresh: client.RefreshInfo{
+			Schedule: "00:00-04:59",
+			Last:     "2017-03-01T02:30:00Z",
+		},
 	})
 }
 
@@ -262,6 +267,30 @@ func (cs *clientSuite) TestClientReportsOpErrorStr(c *C) {
 	c.Check(err, ErrorMatches, `.*server error: "Bad Request"`)
 }
 
+func (cs *clientSuite) TestClientReportsChangeConflict(c *C) {
+	cs.rsp = `{
+		"result": {
+			"message": "snap \"foo\" has changes in progress",
+			"kind": "change-conflict",
+			"value": {"change-kind": "install-snap", "change-id": "42"}
+		},
+		"status": "Conflict",
+		"status-code": 409,
+		"type": "error"
+	}`
+	_, err := cs.cli.Install("foo", nil)
+	c.Check(err, ErrorMatches, `snap "foo" has changes in progress`)
+	c.Check(client.ConflictingChange(err), Equals, "42")
+}
+
+func (cs *clientSuite) TestConflictingChange(c *C) {
+	c.Check(client.ConflictingChange(&client.Error{Kind: client.ErrorKindChangeConflict, Value: map[string]interface{}{"change-id": "1"}}), Equals, "1")
+	c.Check(client.ConflictingChange(&client.Error{Kind: client.ErrorKindChangeConflict}), Equals, "")
+	c.Check(client.ConflictingChange(&client.Error{Kind: "some other kind"}), Equals, "")
+	c.Check(client.ConflictingChange(errors.New("test")), Equals, "")
+	c.Check(client.ConflictingChange(nil), Equals, "")
+}
+
 func (cs *clientSuite) TestClientReportsBadType(c *C) {
 	cs.rsp = `{"type": "what"}`
 	_, err := cs.cli.SysInfo()
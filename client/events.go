@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of events.
+const (
+	EventChangeStatus = "change-status"
+	EventTaskStatus   = "task-status"
+	EventTaskProgress = "task-progress"
+)
+
+// An Event is a transition of a change or of one of its tasks.
+type Event struct {
+	Type string
+	Time time.Time
+
+	ChangeID   string
+	ChangeKind string
+	// Status is the status of the change for change-status events,
+	// and of the task otherwise.
+	Status string
+	// Ready is whether the change is ready, for change-status events.
+	Ready bool
+
+	TaskID   string
+	TaskKind string
+	Summary  string
+	// Log is the last entry in the log of the task.
+	Log      string
+	Progress TaskProgress
+}
+
+type eventJSON struct {
+	Timestamp int64  `json:"timestamp"`
+	Type      string `json:"type"`
+	Metadata  struct {
+		ChangeID   string       `json:"change-id"`
+		ChangeKind string       `json:"change-kind"`
+		Status     string       `json:"status"`
+		Ready      bool         `json:"ready"`
+		TaskID     string       `json:"task-id"`
+		TaskKind   string       `json:"task-kind"`
+		Summary    string       `json:"summary"`
+		Log        string       `json:"log"`
+		Progress   TaskProgress `json:"progress"`
+	} `json:"metadata"`
+}
+
+// EventsOptions selects the events to stream.
+type EventsOptions struct {
+	// Types selects events by their type.
+	Types []string
+	// ChangeID selects the events about a change and its tasks.
+	ChangeID string
+	// ChangeKinds selects the events about changes of the given kinds
+	// and their tasks.
+	ChangeKinds []string
+}
+
+// An EventStream is a stream of events from the server.
+type EventStream struct {
+	events chan Event
+	body   io.Closer
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Events returns the channel the events are sent to. It is closed
+// when the stream ends, either because the server went away or
+// dropped the stream for not keeping up, or because of Close.
+func (es *EventStream) Events() <-chan Event {
+	return es.events
+}
+
+// Close stops the stream.
+func (es *EventStream) Close() error {
+	var err error
+	es.once.Do(func() {
+		close(es.done)
+		err = es.body.Close()
+	})
+	return err
+}
+
+// Events streams the transitions of changes and tasks selected by opts
+// as they happen.
+func (client *Client) Events(opts *EventsOptions) (*EventStream, error) {
+	query := url.Values{}
+	if opts != nil {
+		if len(opts.Types) > 0 {
+			query.Set("types", strings.Join(opts.Types, ","))
+		}
+		if opts.ChangeID != "" {
+			query.Set("change", opts.ChangeID)
+		}
+		if len(opts.ChangeKinds) > 0 {
+			query.Set("change-kinds", strings.Join(opts.ChangeKinds, ","))
+		}
+	}
+
+	rsp, err := client.raw("GET", "/v2/events", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != 200 {
+		defer rsp.Body.Close()
+		return nil, parseError(rsp)
+	}
+
+	es := &EventStream{
+		events: make(chan Event, 20),
+		body:   rsp.Body,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(es.events)
+		defer es.Close()
+		// events come in application/json-seq, as described in
+		// RFC7464; invalid or truncated records are skipped
+		scanner := bufio.NewScanner(rsp.Body)
+		for scanner.Scan() {
+			buf := scanner.Bytes()
+			idx := bytes.IndexByte(buf, 0x1E)
+			if idx < 0 {
+				continue
+			}
+			var ev eventJSON
+			if err := json.Unmarshal(buf[idx+1:], &ev); err != nil {
+				continue
+			}
+			md := &ev.Metadata
+			select {
+			case es.events <- Event{
+				Type:       ev.Type,
+				Time:       time.Unix(0, ev.Timestamp),
+				ChangeID:   md.ChangeID,
+				ChangeKind: md.ChangeKind,
+				Status:     md.Status,
+				Ready:      md.Ready,
+				TaskID:     md.TaskID,
+				TaskKind:   md.TaskKind,
+				Summary:    md.Summary,
+				Log:        md.Log,
+				Progress:   md.Progress,
+			}:
+			case <-es.done:
+				return
+			}
+		}
+	}()
+
+	return es, nil
+}
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientEvents(c *check.C) {
+	cs.rsp = "" +
+		"\x1e" + `{"timestamp": 1000000000, "type": "task-progress", "resource": "/v2/changes/42", "metadata": {"change-id": "42", "change-kind": "install-snap", "task-id": "7", "task-kind": "download-snap", "summary": "Download foo", "status": "Doing", "log": "some log", "progress": {"label": "foo", "done": 1, "total": 2}}}` + "\n" +
+		"\x1e" + `not json` + "\n" +
+		"no RS" + "\n" +
+		"\x1e" + `{"timestamp": 2000000000, "type": "change-status", "resource": "/v2/changes/42", "metadata": {"change-id": "42", "change-kind": "install-snap", "status": "Done", "ready": true}}` + "\n"
+
+	es, err := cs.cli.Events(&client.EventsOptions{
+		Types:       []string{client.EventChangeStatus, client.EventTaskProgress},
+		ChangeID:    "42",
+		ChangeKinds: []string{"install-snap", "refresh-snap"},
+	})
+	c.Assert(err, check.IsNil)
+	defer es.Close()
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/events")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"types":        {"change-status,task-progress"},
+		"change":       {"42"},
+		"change-kinds": {"install-snap,refresh-snap"},
+	})
+
+	var events []client.Event
+	for ev := range es.Events() {
+		events = append(events, ev)
+	}
+	c.Check(events, check.DeepEquals, []client.Event{{
+		Type:       client.EventTaskProgress,
+		Time:       time.Unix(1, 0),
+		ChangeID:   "42",
+		ChangeKind: "install-snap",
+		Status:     "Doing",
+		TaskID:     "7",
+		TaskKind:   "download-snap",
+		Summary:    "Download foo",
+		Log:        "some log",
+		Progress:   client.TaskProgress{Label: "foo", Done: 1, Total: 2},
+	}, {
+		Type:       client.EventChangeStatus,
+		Time:       time.Unix(2, 0),
+		ChangeID:   "42",
+		ChangeKind: "install-snap",
+		Status:     "Done",
+		Ready:      true,
+	}})
+}
+
+func (cs *clientSuite) TestClientEventsClose(c *check.C) {
+	cs.rsp = "\x1e" + `{"type": "task-status"}` + "\n" + "\x1e" + `{"type": "task-status"}` + "\n"
+
+	es, err := cs.cli.Events(nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+	c.Assert(es.Close(), check.IsNil)
+
+	// the stream ends without anybody reading it
+	for range es.Events() {
+	}
+}
+
+func (cs *clientSuite) TestClientEventsError(c *check.C) {
+	cs.status = http.StatusNotFound
+	cs.header = http.Header{"Content-Type": {"application/json"}}
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "not found"}}`
+
+	_, err := cs.cli.Events(nil)
+	c.Assert(err, check.ErrorMatches, `not found`)
+}
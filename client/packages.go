@@ -47,16 +47,37 @@ type Snap struct {
 	Confinement   string        `json:"confinement"`
 	Private       bool          `json:"private"`
 	DevMode       bool          `json:"devmode"`
+	JailMode      bool          `json:"jailmode"`
 	TryMode       bool          `json:"trymode"`
 	Apps          []AppInfo     `json:"apps"`
 	Broken        string        `json:"broken"`
 
+	// TrackingChannel is the channel an installed snap follows.
+	TrackingChannel string `json:"tracking-channel,omitempty"`
+
+	Publisher string `json:"publisher,omitempty"`
+	License   string `json:"license,omitempty"`
+	Contact   string `json:"contact,omitempty"`
+
 	Prices      map[string]float64 `json:"prices"`
 	Screenshots []Screenshot       `json:"screenshots"`
+
+	Channels map[string]*snap.ChannelSnapInfo `json:"channels,omitempty"`
+	Tracks   []string                         `json:"tracks,omitempty"`
 }
 
+// AppInfo describes a single snap application.
 type AppInfo struct {
-	Name string `json:"name"`
+	Snap    string `json:"snap,omitempty"`
+	Name    string `json:"name"`
+	Daemon  string `json:"daemon,omitempty"`
+	Enabled bool   `json:"enabled,omitempty"`
+	Active  bool   `json:"active,omitempty"`
+}
+
+// IsService returns true if the application is a background daemon.
+func (a *AppInfo) IsService() bool {
+	return a != nil && a.Daemon != ""
 }
 
 type Screenshot struct {
@@ -158,13 +179,17 @@ func (client *Client) FindOne(name string) (*Snap, *ResultInfo, error) {
 	q := url.Values{}
 	q.Set("name", name)
 
-	snaps, ri, err := client.snapsFromPath("/v2/find", q)
+	var snaps []*Snap
+	ri, err := client.doSync("GET", "/v2/find", q, nil, nil, &snaps)
 	if err != nil {
-		return nil, nil, fmt.Errorf("cannot find snap %q: %s", name, err)
+		return nil, nil, wrapError(err, "cannot find snap %q", name)
 	}
 
 	if len(snaps) == 0 {
-		return nil, nil, fmt.Errorf("cannot find snap %q", name)
+		return nil, nil, &Error{
+			Kind:    ErrorKindSnapNotFound,
+			Message: fmt.Sprintf("cannot find snap %q", name),
+		}
 	}
 
 	return snaps[0], ri, nil
@@ -186,7 +211,7 @@ func (client *Client) Snap(name string) (*Snap, *ResultInfo, error) {
 	path := fmt.Sprintf("/v2/snaps/%s", name)
 	ri, err := client.doSync("GET", path, nil, nil, nil, &snap)
 	if err != nil {
-		return nil, nil, fmt.Errorf("cannot retrieve snap %q: %s", name, err)
+		return nil, nil, wrapError(err, "cannot retrieve snap %q", name)
 	}
 	return snap, ri, nil
 }
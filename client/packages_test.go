@@ -27,6 +27,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 func (cs *clientSuite) TestClientSnapsCallsEndpoint(c *check.C) {
@@ -140,6 +141,25 @@ func (cs *clientSuite) TestClientFindOne(c *check.C) {
 	c.Check(cs.req.URL.RawQuery, check.Equals, "name=foo")
 }
 
+func (cs *clientSuite) TestClientFindOneNotFound(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+	_, _, err := cs.cli.FindOne("foo")
+	c.Check(err, check.ErrorMatches, `cannot find snap "foo"`)
+	c.Check(client.IsSnapNotFound(err), check.Equals, true)
+}
+
+func (cs *clientSuite) TestClientSnapErrorKeepsKind(c *check.C) {
+	cs.rsp = `{"type": "error", "status-code": 404, "result": {"message": "not there", "kind": "snap-not-found"}}`
+	_, _, err := cs.cli.Snap("foo")
+	c.Check(err, check.ErrorMatches, `cannot retrieve snap "foo": not there`)
+	c.Check(client.IsSnapNotFound(err), check.Equals, true)
+
+	cs.rsp = `{"type": "error", "status-code": 500, "result": {"message": "boom"}}`
+	_, _, err = cs.cli.Snap("foo")
+	c.Check(err, check.ErrorMatches, `cannot retrieve snap "foo": boom`)
+	c.Check(client.IsSnapNotFound(err), check.Equals, false)
+}
+
 const (
 	pkgName = "chatroom"
 )
@@ -167,6 +187,12 @@ func (cs *clientSuite) TestClientSnap(c *check.C) {
 			"private": true,
 			"devmode": true,
 			"trymode": true,
+			"tracking-channel": "beta",
+			"publisher": "Ogra",
+			"license": "GPL-3.0",
+			"contact": "mailto:ogra@example.com",
+			"channels": {"stable": {"revision": "8", "version": "0.1-8", "channel": "stable", "epoch": "0", "confinement": "strict", "size": 6930947}},
+			"tracks": ["latest"],
                         "screenshots": [
                             {"url":"http://example.com/shot1.png", "width":640, "height":480},
                             {"url":"http://example.com/shot2.png"}
@@ -198,5 +224,13 @@ func (cs *clientSuite) TestClientSnap(c *check.C) {
 			{URL: "http://example.com/shot1.png", Width: 640, Height: 480},
 			{URL: "http://example.com/shot2.png"},
 		},
+		TrackingChannel: "beta",
+		Publisher:       "Ogra",
+		License:         "GPL-3.0",
+		Contact:         "mailto:ogra@example.com",
+		Channels: map[string]*snap.ChannelSnapInfo{
+			"stable": {Revision: snap.R(8), Version: "0.1-8", Channel: "stable", Epoch: "0", Confinement: snap.StrictConfinement, Size: 6930947},
+		},
+		Tracks: []string{"latest"},
 	})
 }
@@ -28,6 +28,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 )
 
 type SnapOptions struct {
@@ -37,6 +38,11 @@ type SnapOptions struct {
 	JailMode         bool   `json:"jailmode,omitempty"`
 	Dangerous        bool   `json:"dangerous,omitempty"`
 	IgnoreValidation bool   `json:"ignore-validation,omitempty"`
+	// At schedules the change to run at the given time instead of now.
+	At *time.Time `json:"at,omitempty"`
+	// WaitForChange is the ID of a change the new change waits for
+	// before starting. Conflicts with that change are ignored.
+	WaitForChange string `json:"wait-for-change,omitempty"`
 }
 
 type actionData struct {
@@ -47,8 +53,11 @@ type actionData struct {
 }
 
 type multiActionData struct {
-	Action string   `json:"action"`
-	Snaps  []string `json:"snaps,omitempty"`
+	Action string     `json:"action"`
+	Snaps  []string   `json:"snaps,omitempty"`
+	At     *time.Time `json:"at,omitempty"`
+
+	WaitForChange string `json:"wait-for-change,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -93,6 +102,12 @@ func (client *Client) Revert(name string, options *SnapOptions) (changeID string
 	return client.doSnapAction("revert", name, options)
 }
 
+// Switch moves the snap to track the channel given in the options,
+// without refreshing it.
+func (client *Client) Switch(name string, options *SnapOptions) (changeID string, err error) {
+	return client.doSnapAction("switch", name, options)
+}
+
 func (client *Client) doSnapAction(actionName string, snapName string, options *SnapOptions) (changeID string, err error) {
 	action := actionData{
 		Action:      actionName,
@@ -112,13 +127,18 @@ func (client *Client) doSnapAction(actionName string, snapName string, options *
 }
 
 func (client *Client) doMultiSnapAction(actionName string, snaps []string, options *SnapOptions) (changeID string, err error) {
-	if options != nil {
-		return "", fmt.Errorf("cannot use options for multi-action") // (yet)
-	}
 	action := multiActionData{
 		Action: actionName,
 		Snaps:  snaps,
 	}
+	if options != nil {
+		// only scheduling and waiting are supported for multi-action (yet)
+		if *options != (SnapOptions{At: options.At, WaitForChange: options.WaitForChange}) {
+			return "", fmt.Errorf("cannot use options for multi-action")
+		}
+		action.At = options.At
+		action.WaitForChange = options.WaitForChange
+	}
 	data, err := json.Marshal(&action)
 	if err != nil {
 		return "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
@@ -29,6 +29,7 @@ import (
 	"mime/multipart"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -47,6 +48,7 @@ var ops = []struct {
 	{(*client.Client).Revert, "revert"},
 	{(*client.Client).Enable, "enable"},
 	{(*client.Client).Disable, "disable"},
+	{(*client.Client).Switch, "switch"},
 }
 
 var multiOps = []struct {
@@ -172,6 +174,65 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientMultiOpSnapAt(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	id, err := cs.cli.RefreshMany([]string{pkgName}, &client.SnapOptions{At: &at})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "d728")
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action": "refresh",
+		"snaps":  []interface{}{pkgName},
+		"at":     "2030-01-02T03:04:05Z",
+	})
+}
+
+func (cs *clientSuite) TestClientOpSnapWaitForChange(c *check.C) {
+	cs.rsp = `{
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	_, err := cs.cli.RefreshMany([]string{pkgName}, &client.SnapOptions{WaitForChange: "42"})
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody := make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]interface{}{
+		"action":          "refresh",
+		"snaps":           []interface{}{pkgName},
+		"wait-for-change": "42",
+	})
+
+	_, err = cs.cli.Install(pkgName, &client.SnapOptions{WaitForChange: "42"})
+	c.Assert(err, check.IsNil)
+	body, err = ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	jsonBody = make(map[string]interface{})
+	err = json.Unmarshal(body, &jsonBody)
+	c.Assert(err, check.IsNil)
+	c.Check(jsonBody["wait-for-change"], check.Equals, "42")
+}
+
+func (cs *clientSuite) TestClientMultiOpSnapOtherOptions(c *check.C) {
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err := cs.cli.RefreshMany(nil, &client.SnapOptions{At: &at, DevMode: true})
+	c.Check(err, check.ErrorMatches, "cannot use options for multi-action")
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.rsp = `{
 		"change": "66b3",
@@ -36,8 +36,19 @@ type SnapCtlOptions struct {
 }
 
 type snapctlOutput struct {
-	Stdout string `json:"stdout"`
-	Stderr string `json:"stderr"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit-code,omitempty"`
+}
+
+// UnsuccessfulError is returned by RunSnapctl, along with the output,
+// for a command that ran but finished unsuccessfully.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("unsuccessful with exit code: %d", e.ExitCode)
 }
 
 // RunSnapctl requests a snapctl run for the given options.
@@ -53,5 +64,9 @@ func (client *Client) RunSnapctl(options *SnapCtlOptions) (stdout, stderr []byte
 		return nil, nil, err
 	}
 
-	return []byte(output.Stdout), []byte(output.Stderr), nil
+	if output.ExitCode != 0 {
+		err = &UnsuccessfulError{ExitCode: output.ExitCode}
+	}
+
+	return []byte(output.Stdout), []byte(output.Stderr), err
 }
@@ -66,3 +66,25 @@ func (cs *clientSuite) TestClientRunSnapctl(c *check.C) {
 		"args":       []interface{}{"foo", "bar"},
 	})
 }
+
+func (cs *clientSuite) TestClientRunSnapctlUnsuccessful(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"stdout": "",
+			"stderr": "test stderr",
+			"exit-code": 3
+		}
+	}`
+
+	options := &client.SnapCtlOptions{
+		Args: []string{"is-connected", "plug"},
+	}
+
+	stdout, stderr, err := cs.cli.RunSnapctl(options)
+	c.Assert(err, check.FitsTypeOf, &client.UnsuccessfulError{})
+	c.Check(err.(*client.UnsuccessfulError).ExitCode, check.Equals, 3)
+	c.Check(string(stdout), check.Equals, "")
+	c.Check(string(stderr), check.Equals, "test stderr")
+}
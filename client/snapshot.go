@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// A Snapshot is a collection of archives with a simple metadata json file
+// (and hashsums of everything).
+type Snapshot struct {
+	// SetID is the ID of the snapshot set (a snapshot set is the result
+	// of a single "snap save" call, and so spans several snaps)
+	SetID uint64 `json:"set"`
+	// Time is the time the snapshot was taken
+	Time time.Time `json:"time"`
+	// Snap is the instance name of the snap
+	Snap string `json:"snap"`
+	// Revision is the revision of the snap that was snapshotted
+	Revision snap.Revision `json:"revision"`
+	// Version is the version of the snap that was snapshotted
+	Version string `json:"version"`
+	// Size is the (approximate) size of the snapshot, in bytes
+	Size int64 `json:"size,omitempty"`
+	// SHA3_384 holds the checksums of the archives in the snapshot,
+	// keyed by their name
+	SHA3_384 map[string]string `json:"sha3-384"`
+	// Broken is set if the snapshot could not be read, and says why
+	Broken string `json:"broken,omitempty"`
+	// Auto is set if the snapshot was taken automatically, e.g.
+	// before removing the snap
+	Auto bool `json:"auto,omitempty"`
+}
+
+// IsValid checks whether the snapshot is missing information that
+// should be there for a snapshot that's just been opened.
+func (sh *Snapshot) IsValid() bool {
+	return !(sh == nil || sh.SetID == 0 || sh.Snap == "" || sh.Revision.Unset() || len(sh.SHA3_384) == 0 || sh.Time.IsZero())
+}
+
+// A SnapshotSet is a set of snapshots taken together.
+type SnapshotSet struct {
+	ID        uint64      `json:"id"`
+	Snapshots []*Snapshot `json:"snapshots"`
+}
+
+// Time returns the earliest time of the snapshots in the set, ignoring
+// those that don't have one (e.g. because they're broken).
+func (ss SnapshotSet) Time() time.Time {
+	var mint time.Time
+	for _, sh := range ss.Snapshots {
+		if sh.Time.IsZero() {
+			continue
+		}
+		if mint.IsZero() || sh.Time.Before(mint) {
+			mint = sh.Time
+		}
+	}
+	return mint
+}
+
+// Size returns the sum of the sizes of the snapshots in the set.
+func (ss SnapshotSet) Size() int64 {
+	var sum int64
+	for _, sh := range ss.Snapshots {
+		sum += sh.Size
+	}
+	return sum
+}
+
+// SnapshotSets lists the snapshot sets in the system that belong to the
+// given set (if non-zero) and are for the given snaps (if non-empty).
+func (client *Client) SnapshotSets(setID uint64, snapNames []string) ([]SnapshotSet, error) {
+	q := make(url.Values)
+	if setID > 0 {
+		q.Add("set", strconv.FormatUint(setID, 10))
+	}
+	if len(snapNames) > 0 {
+		q.Add("snaps", strings.Join(snapNames, ","))
+	}
+
+	var snapshotSets []SnapshotSet
+	_, err := client.doSync("GET", "/v2/snapshots", q, nil, nil, &snapshotSets)
+	return snapshotSets, err
+}
+
+// SnapshotMany takes snapshots of the data of the given snaps (or all
+// installed snaps, if snapNames is empty), for the given users (or all
+// users, if usernames is empty).
+func (client *Client) SnapshotMany(snapNames []string, usernames []string) (setID uint64, changeID string, err error) {
+	action := snapshotSaveData{
+		Action: "snapshot",
+		Snaps:  snapNames,
+		Users:  usernames,
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return 0, "", fmt.Errorf("cannot marshal snapshot action: %s", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	result, changeID, err := client.doAsyncFull("POST", "/v2/snaps", nil, headers, bytes.NewBuffer(data))
+	if err != nil {
+		return 0, "", err
+	}
+	var x struct {
+		SetID uint64 `json:"set-id"`
+	}
+	if err := json.Unmarshal(result, &x); err != nil {
+		return 0, "", err
+	}
+
+	return x.SetID, changeID, nil
+}
+
+type snapshotSaveData struct {
+	Action string   `json:"action"`
+	Snaps  []string `json:"snaps,omitempty"`
+	Users  []string `json:"users,omitempty"`
+}
+
+type snapshotAction struct {
+	SetID  uint64   `json:"set"`
+	Action string   `json:"action"`
+	Snaps  []string `json:"snaps,omitempty"`
+	Users  []string `json:"users,omitempty"`
+}
+
+// ForgetSnapshots permanently removes the snapshot set, limited to the
+// given snaps (if non-empty).
+func (client *Client) ForgetSnapshots(setID uint64, snapNames []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "forget",
+		Snaps:  snapNames,
+	})
+}
+
+// CheckSnapshots verifies the archive checksums in the given snapshot
+// set, limited to the given snaps and users (if non-empty).
+func (client *Client) CheckSnapshots(setID uint64, snapNames []string, usernames []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "check",
+		Snaps:  snapNames,
+		Users:  usernames,
+	})
+}
+
+// RestoreSnapshots extracts the given snapshot set, limited to the given
+// snaps and users (if non-empty).
+func (client *Client) RestoreSnapshots(setID uint64, snapNames []string, usernames []string) (changeID string, err error) {
+	return client.snapshotAction(&snapshotAction{
+		SetID:  setID,
+		Action: "restore",
+		Snaps:  snapNames,
+		Users:  usernames,
+	})
+}
+
+func (client *Client) snapshotAction(action *snapshotAction) (changeID string, err error) {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal snapshot action: %v", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	return client.doAsync("POST", "/v2/snapshots", nil, headers, bytes.NewBuffer(data))
+}
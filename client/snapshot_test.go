@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (cs *clientSuite) TestClientSnapshotSets(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"id": 1, "snapshots": [{"set": 1, "snap": "foo", "revision": "7", "version": "1.0", "time": "2017-04-01T10:30:00Z", "size": 42, "sha3-384": {"archive.tgz": "abc"}}, {"set": 1, "snap": "bar", "broken": "oops"}]}]}`
+
+	sets, err := cs.cli.SnapshotSets(1, []string{"foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{"set": {"1"}, "snaps": {"foo,bar"}})
+
+	when := time.Date(2017, 4, 1, 10, 30, 0, 0, time.UTC)
+	c.Assert(sets, check.HasLen, 1)
+	c.Check(sets[0].ID, check.Equals, uint64(1))
+	c.Assert(sets[0].Snapshots, check.HasLen, 2)
+	c.Check(sets[0].Snapshots[0], check.DeepEquals, &client.Snapshot{
+		SetID:    1,
+		Snap:     "foo",
+		Revision: snap.R(7),
+		Version:  "1.0",
+		Time:     when,
+		Size:     42,
+		SHA3_384: map[string]string{"archive.tgz": "abc"},
+	})
+	c.Check(sets[0].Snapshots[0].IsValid(), check.Equals, true)
+	c.Check(sets[0].Snapshots[1].Broken, check.Equals, "oops")
+	c.Check(sets[0].Snapshots[1].IsValid(), check.Equals, false)
+	c.Check(sets[0].Time().Equal(when), check.Equals, true)
+	c.Check(sets[0].Size(), check.Equals, int64(42))
+}
+
+func (cs *clientSuite) TestClientSnapshotSetsNoFilter(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": []}`
+
+	_, err := cs.cli.SnapshotSets(0, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.URL.RawQuery, check.Equals, "")
+}
+
+func (cs *clientSuite) TestClientSnapshotMany(c *check.C) {
+	cs.status = http.StatusAccepted
+	cs.rsp = `{"type": "async", "status-code": 202, "result": {"set-id": 42}, "change": "d"}`
+
+	setID, changeID, err := cs.cli.SnapshotMany([]string{"foo", "bar"}, []string{"me"})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(42))
+	c.Check(changeID, check.Equals, "d")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "snapshot",
+		"snaps":  []interface{}{"foo", "bar"},
+		"users":  []interface{}{"me"},
+	})
+}
+
+func (cs *clientSuite) TestClientSnapshotActions(c *check.C) {
+	cs.status = http.StatusAccepted
+	cs.rsp = `{"type": "async", "status-code": 202, "change": "d"}`
+
+	for action, f := range map[string]func() (string, error){
+		"check": func() (string, error) {
+			return cs.cli.CheckSnapshots(42, []string{"foo"}, []string{"me"})
+		},
+		"restore": func() (string, error) {
+			return cs.cli.RestoreSnapshots(42, []string{"foo"}, []string{"me"})
+		},
+		"forget": func() (string, error) {
+			return cs.cli.ForgetSnapshots(42, []string{"foo"})
+		},
+	} {
+		changeID, err := f()
+		c.Assert(err, check.IsNil, check.Commentf(action))
+		c.Check(changeID, check.Equals, "d")
+		c.Check(cs.req.Method, check.Equals, "POST")
+		c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+
+		expected := map[string]interface{}{
+			"set":    42.0,
+			"action": action,
+			"snaps":  []interface{}{"foo"},
+		}
+		if action != "forget" {
+			expected["users"] = []interface{}{"me"}
+		}
+		var body map[string]interface{}
+		c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+		c.Check(body, check.DeepEquals, expected, check.Commentf(action))
+	}
+}
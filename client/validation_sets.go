@@ -0,0 +1,114 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ValidationSetResult describes a tracked validation set.
+type ValidationSetResult struct {
+	AccountID string `json:"account-id"`
+	Name      string `json:"name"`
+	// Mode is either "monitor" or "enforce".
+	Mode string `json:"mode"`
+	// PinnedAt is the sequence the set is pinned at, 0 if it is not.
+	PinnedAt int `json:"pinned-at,omitempty"`
+	// Sequence is the sequence of the set in use.
+	Sequence int `json:"sequence"`
+	// Valid is whether the installed snaps comply with the set.
+	Valid bool `json:"valid"`
+}
+
+// ValidationSetApplyOptions holds the options for tracking a validation set.
+type ValidationSetApplyOptions struct {
+	// Mode is either "monitor" or "enforce".
+	Mode string `json:"mode"`
+	// Sequence pins the set at the given sequence, 0 means the
+	// latest known one.
+	Sequence int `json:"sequence,omitempty"`
+}
+
+// ValidationSets returns the tracked validation sets.
+func (client *Client) ValidationSets() ([]*ValidationSetResult, error) {
+	var sets []*ValidationSetResult
+	_, err := client.doSync("GET", "/v2/validation-sets", nil, nil, nil, &sets)
+	if err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+func validationSetPath(accountID, name string) string {
+	return "/v2/validation-sets/" + accountID + "/" + name
+}
+
+// ValidationSet returns the given tracked validation set.
+func (client *Client) ValidationSet(accountID, name string) (*ValidationSetResult, error) {
+	var set ValidationSetResult
+	_, err := client.doSync("GET", validationSetPath(accountID, name), nil, nil, nil, &set)
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+type validationSetAction struct {
+	Action string `json:"action"`
+	*ValidationSetApplyOptions
+}
+
+func (client *Client) validationSetAction(accountID, name string, action *validationSetAction, v interface{}) error {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return fmt.Errorf("cannot marshal validation set action: %v", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	_, err = client.doSync("POST", validationSetPath(accountID, name), nil, headers, bytes.NewBuffer(data), v)
+	return err
+}
+
+// ApplyValidationSet starts tracking the given validation set, or
+// changes how it is tracked.
+func (client *Client) ApplyValidationSet(accountID, name string, opts *ValidationSetApplyOptions) (*ValidationSetResult, error) {
+	if opts == nil {
+		opts = &ValidationSetApplyOptions{}
+	}
+	var set ValidationSetResult
+	err := client.validationSetAction(accountID, name, &validationSetAction{
+		Action:                    "apply",
+		ValidationSetApplyOptions: opts,
+	}, &set)
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ForgetValidationSet stops tracking the given validation set.
+func (client *Client) ForgetValidationSet(accountID, name string) error {
+	return client.validationSetAction(accountID, name, &validationSetAction{Action: "forget"}, nil)
+}
@@ -0,0 +1,94 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientValidationSets(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{"account-id": "acc", "name": "one", "mode": "enforce", "pinned-at": 2, "sequence": 2, "valid": true}, {"account-id": "acc", "name": "two", "mode": "monitor", "sequence": 5}]}`
+
+	sets, err := cs.cli.ValidationSets()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets")
+	c.Check(sets, check.DeepEquals, []*client.ValidationSetResult{
+		{AccountID: "acc", Name: "one", Mode: "enforce", PinnedAt: 2, Sequence: 2, Valid: true},
+		{AccountID: "acc", Name: "two", Mode: "monitor", Sequence: 5},
+	})
+}
+
+func (cs *clientSuite) TestClientValidationSet(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"account-id": "acc", "name": "one", "mode": "monitor", "sequence": 3, "valid": true}}`
+
+	set, err := cs.cli.ValidationSet("acc", "one")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/one")
+	c.Check(set, check.DeepEquals, &client.ValidationSetResult{
+		AccountID: "acc", Name: "one", Mode: "monitor", Sequence: 3, Valid: true,
+	})
+}
+
+func (cs *clientSuite) TestClientApplyValidationSet(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"account-id": "acc", "name": "one", "mode": "enforce", "pinned-at": 3, "sequence": 3, "valid": true}}`
+
+	set, err := cs.cli.ApplyValidationSet("acc", "one", &client.ValidationSetApplyOptions{Mode: "enforce", Sequence: 3})
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/one")
+	c.Check(set.PinnedAt, check.Equals, 3)
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action":   "apply",
+		"mode":     "enforce",
+		"sequence": float64(3),
+	})
+}
+
+func (cs *clientSuite) TestClientForgetValidationSet(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+
+	err := cs.cli.ForgetValidationSet("acc", "one")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/acc/one")
+
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "forget",
+	})
+}
+
+func (cs *clientSuite) TestClientValidationSetError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "validation set acc/one is not tracked"}}`
+
+	err := cs.cli.ForgetValidationSet("acc", "one")
+	c.Assert(err, check.ErrorMatches, "validation set acc/one is not tracked")
+}
@@ -23,12 +23,15 @@ import (
 	"fmt"
 	"io/ioutil"
 
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/i18n"
 
 	"github.com/jessevdk/go-flags"
 )
 
 type cmdAck struct {
+	Bundle bool `long:"bundle"`
+
 	AckOptions struct {
 		AssertionFile string
 	} `positional-args:"true" required:"true"`
@@ -44,12 +47,19 @@ will replace.
 To succeed the assertion must be valid, its signature verified with a known
 public key and the assertion consistent with and its prerequisite in the
 database.
+
+With --bundle the file is taken to be a bundle of assertions, such as the
+output of "snap known --remote --bundle", that are added together: either
+all of the assertions not already known are added or none is. The added
+assertions are reported.
 `)
 
 func init() {
 	addCommand("ack", shortAckHelp, longAckHelp, func() flags.Commander {
 		return &cmdAck{}
-	}, nil, []argDesc{{
+	}, map[string]string{
+		"bundle": i18n.G("Add all the assertions in the file or none of them"),
+	}, []argDesc{{
 		name: i18n.G("<assertion file>"),
 		desc: i18n.G("Assertion file"),
 	}})
@@ -64,11 +74,41 @@ func ackFile(assertFile string) error {
 	return Client().Ack(assertData)
 }
 
+func ackBundle(assertFile string) error {
+	assertData, err := ioutil.ReadFile(assertFile)
+	if err != nil {
+		return err
+	}
+
+	added, err := Client().AckBundle(assertData)
+	if err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		fmt.Fprintln(Stdout, i18n.G("No new assertions were added."))
+		return nil
+	}
+	for _, r := range added {
+		at := asserts.Type(r.Type)
+		if at == nil {
+			fmt.Fprintf(Stdout, i18n.G("Added %s %v\n"), r.Type, r.PrimaryKey)
+			continue
+		}
+		ref := &asserts.Ref{Type: at, PrimaryKey: r.PrimaryKey}
+		fmt.Fprintf(Stdout, i18n.G("Added %s\n"), ref)
+	}
+	return nil
+}
+
 func (x *cmdAck) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
-	if err := ackFile(x.AckOptions.AssertionFile); err != nil {
+	ack := ackFile
+	if x.Bundle {
+		ack = ackBundle
+	}
+	if err := ack(x.AckOptions.AssertionFile); err != nil {
 		return fmt.Errorf("cannot assert: %v", err)
 	}
 	return nil
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockAckServer(c *check.C, bundle bool, result string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/assertions")
+			if bundle {
+				c.Check(r.URL.Query().Get("bundle"), check.Equals, "true")
+			} else {
+				c.Check(r.URL.RawQuery, check.Equals, "")
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			c.Check(err, check.IsNil)
+			c.Check(string(body), check.Equals, "assertions")
+			fmt.Fprintf(w, `{"type": "sync", "result": %s}`, result)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+		n++
+	})
+}
+
+func (s *SnapSuite) writeAssertFile(c *check.C) string {
+	fn := filepath.Join(c.MkDir(), "bundle.assert")
+	err := ioutil.WriteFile(fn, []byte("assertions"), 0644)
+	c.Assert(err, check.IsNil)
+	return fn
+}
+
+func (s *SnapSuite) TestAck(c *check.C) {
+	s.mockAckServer(c, false, `{}`)
+
+	rest, err := snap.Parser().ParseArgs([]string{"ack", s.writeAssertFile(c)})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestAckBundle(c *check.C) {
+	s.mockAckServer(c, true, `{"added": [
+		{"type": "account", "primary-key": ["my-brand"]},
+		{"type": "model", "primary-key": ["16", "my-brand", "pi99"]}
+	]}`)
+
+	rest, err := snap.Parser().ParseArgs([]string{"ack", "--bundle", s.writeAssertFile(c)})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `Added account (my-brand)
+Added model (pi99; series:16 brand-id:my-brand)
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestAckBundleNothingAdded(c *check.C) {
+	s.mockAckServer(c, true, `{"added": []}`)
+
+	_, err := snap.Parser().ParseArgs([]string{"ack", "--bundle", s.writeAssertFile(c)})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "No new assertions were added.\n")
+}
+
+func (s *SnapSuite) TestAckBundleError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type": "error", "result": {"message": "assert failed: cannot add account (my-brand): boom"}, "status-code": 400}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"ack", "--bundle", s.writeAssertFile(c)})
+	c.Assert(err, check.ErrorMatches, `cannot assert: assert failed: cannot add account \(my-brand\): boom`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+var (
+	shortAliasHelp = i18n.G("Set up a manual alias")
+	longAliasHelp  = i18n.G(`
+The alias command makes the given app of a snap available also under the
+given alias name, as an alternative to its <snap>.<app> command.
+`)
+	shortUnaliasHelp = i18n.G("Remove a manual alias, or disable an automatic one")
+	longUnaliasHelp  = i18n.G(`
+The unalias command removes the given alias. Automatic aliases granted to
+a snap are disabled instead, and stay so across refreshes.
+`)
+	shortAliasesHelp = i18n.G("List aliases in the system")
+	longAliasesHelp  = i18n.G(`
+The aliases command lists all aliases available in the system and their
+status, optionally limited to those of the given snap.
+`)
+)
+
+type cmdAlias struct {
+	Positional struct {
+		SnapApp string `positional-arg-name:"<snap.app>" required:"yes"`
+		Alias   string `positional-arg-name:"<alias>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type cmdUnalias struct {
+	Positional struct {
+		Alias string `positional-arg-name:"<alias>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type cmdAliases struct {
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("alias", shortAliasHelp, longAliasHelp, func() flags.Commander { return &cmdAlias{} }, nil, nil)
+	addCommand("unalias", shortUnaliasHelp, longUnaliasHelp, func() flags.Commander { return &cmdUnalias{} }, nil, nil)
+	addCommand("aliases", shortAliasesHelp, longAliasesHelp, func() flags.Commander { return &cmdAliases{} }, nil, nil)
+}
+
+// splitSnapApp splits a <snap>.<app> command into its snap and app
+// names; a bare snap name refers to the app of the same name.
+func splitSnapApp(snapApp string) (snapName, app string) {
+	if i := strings.IndexByte(snapApp, '.'); i >= 0 {
+		return snapApp[:i], snapApp[i+1:]
+	}
+	return snapApp, snapApp
+}
+
+func (x *cmdAlias) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapName, app := splitSnapApp(x.Positional.SnapApp)
+
+	cli := Client()
+	changeID, err := cli.Alias(snapName, app, x.Positional.Alias)
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Added alias %q for %s.\n"), x.Positional.Alias, x.Positional.SnapApp)
+	return nil
+}
+
+func (x *cmdUnalias) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	changeID, err := cli.Unalias(x.Positional.Alias)
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Removed alias %q.\n"), x.Positional.Alias)
+	return nil
+}
+
+type aliasInfo struct {
+	command string
+	alias   string
+	notes   string
+}
+
+type byCommandAndAlias []aliasInfo
+
+func (a byCommandAndAlias) Len() int      { return len(a) }
+func (a byCommandAndAlias) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byCommandAndAlias) Less(i, j int) bool {
+	if a[i].command != a[j].command {
+		return a[i].command < a[j].command
+	}
+	return a[i].alias < a[j].alias
+}
+
+func (x *cmdAliases) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	allStatuses, err := Client().Aliases()
+	if err != nil {
+		return err
+	}
+
+	var infos []aliasInfo
+	for snapName, aliases := range allStatuses {
+		if x.Positional.Snap != "" && snapName != x.Positional.Snap {
+			continue
+		}
+		for alias, status := range aliases {
+			notes := "-"
+			switch status.Status {
+			case "manual":
+				notes = i18n.G("manual")
+			case "disabled":
+				notes = i18n.G("disabled")
+			}
+			infos = append(infos, aliasInfo{
+				command: status.Command,
+				alias:   alias,
+				notes:   notes,
+			})
+		}
+	}
+
+	if len(infos) == 0 {
+		if x.Positional.Snap != "" {
+			fmt.Fprintf(Stderr, i18n.G("No aliases are currently defined for snap %q.\n"), x.Positional.Snap)
+		} else {
+			fmt.Fprintln(Stderr, i18n.G("No aliases are currently defined."))
+		}
+		return nil
+	}
+
+	sort.Sort(byCommandAndAlias(infos))
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Command\tAlias\tNotes"))
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", info.command, info.alias, info.notes)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+)
+
+const aliasesJSON = `{"type": "sync", "status-code": 200, "result": {
+  "foo": {"foo": {"app": "foo", "command": "foo", "status": "auto"}, "baz": {"app": "bar", "command": "foo.bar", "status": "manual"}},
+  "bar": {"b": {"app": "app", "command": "bar.app", "status": "disabled"}}
+}}`
+
+func (s *SnapSuite) testAliasOp(c *check.C, args []string, expectedBody map[string]interface{}, summary string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/aliases")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, expectedBody)
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	s.stdout.Reset()
+	rest, err := snap.Parser().ParseArgs(args)
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, summary)
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *SnapSuite) TestAlias(c *check.C) {
+	s.testAliasOp(c, []string{"alias", "foo.bar", "baz"}, map[string]interface{}{
+		"action": "alias", "snap": "foo", "app": "bar", "alias": "baz",
+	}, "Added alias \"baz\" for foo.bar.\n")
+	s.testAliasOp(c, []string{"alias", "foo", "f"}, map[string]interface{}{
+		"action": "alias", "snap": "foo", "app": "foo", "alias": "f",
+	}, "Added alias \"f\" for foo.\n")
+}
+
+func (s *SnapSuite) TestUnalias(c *check.C) {
+	s.testAliasOp(c, []string{"unalias", "baz"}, map[string]interface{}{
+		"action": "unalias", "alias": "baz",
+	}, "Removed alias \"baz\".\n")
+}
+
+func (s *SnapSuite) TestAliasMissingArgs(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"alias", "foo.bar"})
+	c.Check(err, check.ErrorMatches, "the required argument `<alias>` was not provided")
+}
+
+func (s *SnapSuite) TestAliases(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/aliases")
+		fmt.Fprintln(w, aliasesJSON)
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"aliases"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `Command  Alias  Notes
+bar.app  b      disabled
+foo      foo    -
+foo.bar  baz    manual
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestAliasesForSnap(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, aliasesJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"aliases", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `Command  Alias  Notes
+bar.app  b      disabled
+`)
+}
+
+func (s *SnapSuite) TestAliasesNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, aliasesJSON)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"aliases", "other"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No aliases are currently defined for snap \"other\".\n")
+}
+
+func (s *SnapSuite) TestResolveApp(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	c.Assert(os.MkdirAll(dirs.SnapBinariesDir, 0755), check.IsNil)
+	c.Assert(os.Symlink("/usr/bin/snap", filepath.Join(dirs.SnapBinariesDir, "foo.bar")), check.IsNil)
+	c.Assert(os.Symlink("foo.bar", filepath.Join(dirs.SnapBinariesDir, "baz")), check.IsNil)
+
+	c.Check(snap.ResolveApp("foo.bar"), check.Equals, "foo.bar")
+	c.Check(snap.ResolveApp("baz"), check.Equals, "foo.bar")
+	c.Check(snap.ResolveApp("missing"), check.Equals, "missing")
+}
@@ -34,11 +34,18 @@ import (
 var shortChangesHelp = i18n.G("List system changes")
 var shortChangeHelp = i18n.G("List a change's tasks")
 var longChangesHelp = i18n.G(`
-The changes command displays a summary of the recent system changes performed.`)
+The changes command displays a summary of the recent system changes performed.
+
+With --all, older changes recorded in the audit log are included as well.`)
 var longChangeHelp = i18n.G(`
-The change command displays a summary of tasks associated to an individual change.`)
+The change command displays a summary of tasks associated to an individual change.
+
+Tasks being retried after failing show which attempt at them is under way.`)
+var shortTasksHelp = i18n.G("List a change's tasks (same as change)")
 
 type cmdChanges struct {
+	All        bool   `long:"all"`
+	Since      string `long:"since"`
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -51,8 +58,12 @@ type cmdChange struct {
 }
 
 func init() {
-	addCommand("changes", shortChangesHelp, longChangesHelp, func() flags.Commander { return &cmdChanges{} }, nil, nil)
+	addCommand("changes", shortChangesHelp, longChangesHelp, func() flags.Commander { return &cmdChanges{} }, map[string]string{
+		"all":   i18n.G("Include the changes in the audit log"),
+		"since": i18n.G("Only list changes that were ready at or after the given time (RFC3339), implies --all"),
+	}, nil)
 	addCommand("change", shortChangeHelp, longChangeHelp, func() flags.Commander { return &cmdChange{} }, nil, nil)
+	addCommand("tasks", shortTasksHelp, longChangeHelp, func() flags.Commander { return &cmdChange{} }, nil, nil)
 }
 
 type changesByTime []*client.Change
@@ -81,6 +92,15 @@ func (c *cmdChanges) Execute(args []string) error {
 	opts := client.ChangesOptions{
 		SnapName: c.Positional.Snap,
 		Selector: client.ChangesAll,
+		History:  c.All,
+	}
+	if c.Since != "" {
+		since, err := time.Parse(time.RFC3339, c.Since)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot parse --since time %q: expected RFC3339 format, like %q"), c.Since, time.RFC3339)
+		}
+		opts.Since = since
+		opts.History = true
 	}
 
 	cli := Client()
@@ -133,6 +153,9 @@ func (c *cmdChange) Execute([]string) error {
 		if t.Status == "Doing" && t.Progress.Total > 1 {
 			summary = fmt.Sprintf("%s (%.2f%%)", summary, float64(t.Progress.Done)/float64(t.Progress.Total)*100.0)
 		}
+		if t.Status == "Doing" && t.Retries > 0 {
+			summary = fmt.Sprintf(i18n.G("%s (attempt %d, last error: %s)"), summary, t.Retries+1, t.LastError)
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Status, spawnTime, readyTime, summary)
 	}
 
@@ -31,6 +31,7 @@ import (
 )
 
 type cmdCreateKey struct {
+	KeyType    string `long:"type" choice:"rsa" choice:"ed25519" default:"rsa"`
 	Positional struct {
 		KeyName string
 	} `positional-args:"true"`
@@ -42,7 +43,9 @@ func init() {
 		i18n.G("Create a cryptographic key pair that can be used for signing assertions."),
 		func() flags.Commander {
 			return &cmdCreateKey{}
-		}, nil, []argDesc{{
+		}, map[string]string{
+			"type": i18n.G("Type of key to create; Ed25519 keys need a passphrase"),
+		}, []argDesc{{
 			name: i18n.G("<key-name>"),
 			desc: i18n.G("Name of key to create; defaults to 'default'"),
 		}})
@@ -63,13 +66,13 @@ func (x *cmdCreateKey) Execute(args []string) error {
 	}
 
 	fmt.Fprint(Stdout, i18n.G("Passphrase: "))
-	passphrase, err := terminal.ReadPassword(0)
+	passphrase, err := terminal.ReadPassword(Terminal)
 	fmt.Fprint(Stdout, "\n")
 	if err != nil {
 		return err
 	}
 	fmt.Fprint(Stdout, i18n.G("Confirm passphrase: "))
-	confirmPassphrase, err := terminal.ReadPassword(0)
+	confirmPassphrase, err := terminal.ReadPassword(Terminal)
 	fmt.Fprint(Stdout, "\n")
 	if err != nil {
 		return err
@@ -82,5 +85,8 @@ func (x *cmdCreateKey) Execute(args []string) error {
 	}
 
 	manager := asserts.NewGPGKeypairManager()
+	if x.KeyType == "ed25519" {
+		return manager.GenerateEd25519(string(passphrase), keyName)
+	}
 	return manager.Generate(string(passphrase), keyName)
 }
@@ -20,8 +20,12 @@
 package main_test
 
 import (
+	"fmt"
+	"os"
+
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
 	snap "github.com/snapcore/snapd/cmd/snap"
 )
 
@@ -32,3 +36,26 @@ func (s *SnapSuite) TestCreateKeyInvalidCharacters(c *C) {
 	c.Check(s.Stdout(), Equals, "")
 	c.Check(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestCreateKeyEd25519(c *C) {
+	os.Setenv("SNAP_GNUPG_HOME", c.MkDir())
+	defer os.Unsetenv("SNAP_GNUPG_HOME")
+
+	fmt.Fprint(s.term, "passphrase\npassphrase\n")
+	_, err := snap.Parser().ParseArgs([]string{"create-key", "--type=ed25519", "ed-key"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Passphrase: \nConfirm passphrase: \n")
+	c.Check(s.Stderr(), Equals, "")
+
+	privKey, err := asserts.NewGPGKeypairManager().GetByName("ed-key")
+	c.Assert(err, IsNil)
+	encoded, err := asserts.EncodePublicKey(privKey.PublicKey())
+	c.Assert(err, IsNil)
+	pubKey, err := asserts.DecodePublicKey(encoded)
+	c.Assert(err, IsNil)
+	c.Check(pubKey.ID(), Equals, privKey.PublicKey().ID())
+
+	fmt.Fprint(s.term, "passphrase\npassphrase\n")
+	_, err = snap.Parser().ParseArgs([]string{"create-key", "--type=ed25519", "ed-key"})
+	c.Check(err, ErrorMatches, `key named "ed-key" already exists`)
+}
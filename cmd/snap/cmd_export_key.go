@@ -26,6 +26,7 @@ import (
 	"github.com/jessevdk/go-flags"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
 	"github.com/snapcore/snapd/i18n"
 )
 
@@ -61,7 +62,10 @@ func (x *cmdExportKey) Execute(args []string) error {
 		keyName = "default"
 	}
 
-	manager := asserts.NewGPGKeypairManager()
+	manager, err := signtool.GetKeypairManager()
+	if err != nil {
+		return err
+	}
 	if x.Account != "" {
 		privKey, err := manager.GetByName(keyName)
 		if err != nil {
@@ -0,0 +1,336 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
+)
+
+var shortInfoHelp = i18n.G("Show detailed information about a snap")
+var longInfoHelp = i18n.G(`
+The info command shows detailed information about the given snaps.
+
+The snaps can be specified by name, in which case what is known about the
+installed snap is shown together with what the store offers in each of its
+channels, or by the path to a snap file or an unpacked snap directory.
+`)
+
+type infoCmd struct {
+	Positional struct {
+		Snaps []string `positional-arg-name:"<snap>" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func init() {
+	addCommand("info", shortInfoHelp, longInfoHelp, func() flags.Commander { return &infoCmd{} }, nil, nil)
+}
+
+// channelRisks are the risk levels of each track, from the most to the
+// least stable.
+var channelRisks = []string{"stable", "candidate", "beta", "edge"}
+
+// looksLikePath tells whether the given argument names a snap file or
+// directory rather than a snap.
+func looksLikePath(arg string) bool {
+	return strings.ContainsRune(arg, '/') || strings.HasSuffix(arg, ".snap")
+}
+
+// appCommand returns the command that runs the given app of the snap.
+func appCommand(snapName, app string) string {
+	if app == snapName {
+		return snapName
+	}
+	return snapName + "." + app
+}
+
+func printDescription(w io.Writer, descr string) {
+	fmt.Fprintln(w, "description: |")
+	for _, line := range strings.Split(strings.TrimSpace(descr), "\n") {
+		fmt.Fprintf(w, "  %s\n", strings.TrimRight(line, " \t"))
+	}
+}
+
+func printCommands(w io.Writer, commands []string) {
+	if len(commands) == 0 {
+		return
+	}
+	sort.Strings(commands)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  - %s\n", cmd)
+	}
+}
+
+func printPath(w io.Writer, path string) error {
+	snapf, err := snap.Open(path)
+	if err != nil {
+		return err
+	}
+	info, err := snap.ReadInfoFromSnapFile(snapf, nil)
+	if err != nil {
+		return err
+	}
+
+	notes := &Notes{DevMode: info.Confinement == snap.DevmodeConfinement}
+
+	fmt.Fprintf(w, "path:\t%q\n", path)
+	fmt.Fprintf(w, "name:\t%s\n", info.Name())
+	fmt.Fprintf(w, "summary:\t%s\n", info.Summary())
+	fmt.Fprintf(w, "version:\t%s %s\n", info.Version, notes)
+	printDescription(w, info.Description())
+
+	var commands []string
+	var services []*snap.AppInfo
+	for _, app := range info.Apps {
+		if app.Daemon != "" {
+			services = append(services, app)
+		} else {
+			commands = append(commands, appCommand(info.Name(), app.Name))
+		}
+	}
+	printCommands(w, commands)
+	if len(services) > 0 {
+		sort.Sort(byAppName(services))
+		fmt.Fprintln(w, "services:")
+		for _, svc := range services {
+			fmt.Fprintf(w, "  %s:\t%s\n", appCommand(info.Name(), svc.Name), svc.Daemon)
+		}
+	}
+
+	return nil
+}
+
+type byAppName []*snap.AppInfo
+
+func (a byAppName) Len() int           { return len(a) }
+func (a byAppName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func printServices(w io.Writer, cli *client.Client, local *client.Snap) error {
+	hasServices := false
+	for _, app := range local.Apps {
+		if app.IsService() {
+			hasServices = true
+			break
+		}
+	}
+	if !hasServices {
+		return nil
+	}
+
+	services, err := cli.Apps([]string{local.Name}, client.AppOptions{Service: true})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "services:")
+	for _, svc := range services {
+		startup := i18n.G("disabled")
+		if svc.Enabled {
+			startup = i18n.G("enabled")
+		}
+		current := i18n.G("inactive")
+		if svc.Active {
+			current = i18n.G("active")
+		}
+		fmt.Fprintf(w, "  %s:\t%s, %s, %s\n", appCommand(svc.Snap, svc.Name), svc.Daemon, startup, current)
+	}
+
+	return nil
+}
+
+func fmtChannelSnapInfo(ch *snap.ChannelSnapInfo) string {
+	notes := &Notes{DevMode: ch.Confinement == snap.DevmodeConfinement}
+	return fmt.Sprintf("%s (%s) %s %s", ch.Version, ch.Revision, fmtSize(ch.Size), notes)
+}
+
+func printChannels(w io.Writer, remote *client.Snap) {
+	if len(remote.Channels) == 0 {
+		return
+	}
+	tracks := remote.Tracks
+	if len(tracks) == 0 {
+		tracks = []string{"latest"}
+	}
+
+	fmt.Fprintln(w, "channels:")
+	for _, track := range tracks {
+		// closed channels follow the next more stable open one
+		open := false
+		for _, risk := range channelRisks {
+			name := risk
+			if track != "latest" {
+				name = track + "/" + risk
+			}
+			desc := "--"
+			if ch := remote.Channels[name]; ch != nil {
+				desc = fmtChannelSnapInfo(ch)
+				open = true
+			} else if open {
+				desc = "↑"
+			}
+			fmt.Fprintf(w, "  %s:\t%s\n", name, desc)
+		}
+	}
+}
+
+func printSnap(w io.Writer, cli *client.Client, local, remote *client.Snap) error {
+	both := local
+	if both == nil {
+		both = remote
+	}
+
+	publisher := both.Developer
+	if remote != nil {
+		if remote.Publisher != "" {
+			publisher = remote.Publisher
+		} else if remote.Developer != "" {
+			publisher = remote.Developer
+		}
+	}
+
+	fmt.Fprintf(w, "name:\t%s\n", both.Name)
+	fmt.Fprintf(w, "summary:\t%s\n", both.Summary)
+	if publisher != "" {
+		fmt.Fprintf(w, "publisher:\t%s\n", publisher)
+	}
+	if remote != nil && remote.License != "" {
+		fmt.Fprintf(w, "license:\t%s\n", remote.License)
+	}
+	if remote != nil && remote.Contact != "" {
+		fmt.Fprintf(w, "contact:\t%s\n", remote.Contact)
+	}
+	printDescription(w, both.Description)
+
+	if local != nil {
+		var commands []string
+		for _, app := range local.Apps {
+			if !app.IsService() {
+				commands = append(commands, appCommand(local.Name, app.Name))
+			}
+		}
+		printCommands(w, commands)
+		if err := printServices(w, cli, local); err != nil {
+			return err
+		}
+
+		notes := &Notes{
+			Private:  local.Private,
+			DevMode:  local.DevMode,
+			JailMode: local.JailMode,
+			TryMode:  local.TryMode,
+			Disabled: local.Status == client.StatusInstalled,
+			Broken:   local.Broken != "",
+		}
+		if local.TrackingChannel != "" {
+			fmt.Fprintf(w, "tracking:\t%s\n", local.TrackingChannel)
+		}
+		fmt.Fprintf(w, "installed:\t%s (%s) %s %s\n", local.Version, local.Revision, fmtSize(local.InstalledSize), notes)
+		if !local.InstallDate.IsZero() {
+			fmt.Fprintf(w, "refreshed:\t%s\n", local.InstallDate.Format(time.RFC3339))
+		}
+	}
+
+	if remote != nil {
+		printChannels(w, remote)
+	}
+
+	return nil
+}
+
+func (x *infoCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+
+	w := tabWriter()
+	defer w.Flush()
+
+	var notFound []string
+	printed := 0
+	for _, arg := range x.Positional.Snaps {
+		if looksLikePath(arg) {
+			if printed > 0 {
+				fmt.Fprintln(w, "---")
+			}
+			if err := printPath(w, arg); err != nil {
+				return fmt.Errorf(i18n.G("cannot read snap %q: %v"), arg, err)
+			}
+			printed++
+			continue
+		}
+
+		remote, _, remoteErr := cli.FindOne(arg)
+		local, _, localErr := cli.Snap(arg)
+		if remoteErr != nil {
+			remote = nil
+		}
+		if localErr != nil {
+			local = nil
+		}
+		// errors other than the snap not being there are reported;
+		// they only fail the command when there is nothing to show
+		var otherErrs []error
+		for _, err := range []error{localErr, remoteErr} {
+			if err != nil && !client.IsSnapNotFound(err) {
+				otherErrs = append(otherErrs, err)
+			}
+		}
+		if local == nil && remote == nil {
+			if len(otherErrs) > 0 {
+				w.Flush()
+				return otherErrs[0]
+			}
+			notFound = append(notFound, arg)
+			continue
+		}
+		for _, err := range otherErrs {
+			fmt.Fprintf(Stderr, i18n.G("WARNING: %v\n"), err)
+		}
+
+		if printed > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if err := printSnap(w, cli, local, remote); err != nil {
+			return err
+		}
+		printed++
+	}
+
+	if len(notFound) > 0 {
+		w.Flush()
+		// TRANSLATORS: %s is a comma-separated list of quoted snap names
+		return fmt.Errorf(i18n.G("no snap found for %s"), quotedSnapNames(notFound))
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+const mockInfoRemoteJSON = `{"type": "sync", "status-code": 200, "result": [{
+  "name": "hello", "summary": "The 'hello-world' of snaps", "description": "This is a simple hello world example.\nIt says hello.",
+  "developer": "canonical", "publisher": "Canonical", "license": "GPL-3.0", "contact": "mailto:snappy-devel@lists.ubuntu.com",
+  "status": "available", "type": "app", "version": "6.3", "revision": "27",
+  "tracks": ["latest", "7.0"],
+  "channels": {
+    "stable": {"revision": "26", "version": "6.1", "channel": "stable", "confinement": "strict", "size": 20000},
+    "beta": {"revision": "27", "version": "6.3", "channel": "beta", "confinement": "strict", "size": 20480},
+    "7.0/edge": {"revision": "28", "version": "7.0~pre1", "channel": "7.0/edge", "confinement": "devmode", "size": 20500}
+  }
+}]}`
+
+const mockInfoLocalJSON = `{"type": "sync", "status-code": 200, "result": {
+  "name": "hello", "summary": "The 'hello-world' of snaps", "description": "This is a simple hello world example.\nIt says hello.",
+  "developer": "canonical", "status": "active", "type": "app", "version": "6.1", "revision": "26",
+  "installed-size": 20000, "install-date": "2017-04-01T10:30:00Z", "tracking-channel": "stable", "devmode": true,
+  "apps": [{"name": "hello"}, {"name": "universe"}, {"name": "svc", "daemon": "simple"}]
+}}`
+
+const mockInfoServicesJSON = `{"type": "sync", "status-code": 200, "result": [
+  {"snap": "hello", "name": "svc", "daemon": "simple", "enabled": true, "active": true}
+]}`
+
+func (s *SnapSuite) TestInfoInstalledAndInStore(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/find")
+			c.Check(r.URL.Query().Get("name"), check.Equals, "hello")
+			fmt.Fprintln(w, mockInfoRemoteJSON)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/hello")
+			fmt.Fprintln(w, mockInfoLocalJSON)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query().Get("names"), check.Equals, "hello")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "service")
+			fmt.Fprintln(w, mockInfoServicesJSON)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `name:       hello
+summary:    The 'hello-world' of snaps
+publisher:  Canonical
+license:    GPL-3.0
+contact:    mailto:snappy-devel@lists.ubuntu.com
+description: |
+  This is a simple hello world example.
+  It says hello.
+commands:
+  - hello
+  - hello.universe
+services:
+  hello.svc:  simple, enabled, active
+tracking:     stable
+installed:    6.1 (26) 20.0kB devmode
+refreshed:    2017-04-01T10:30:00Z
+channels:
+  stable:         6.1 (26) 20.0kB -
+  candidate:      ↑
+  beta:           6.3 (27) 20.5kB -
+  edge:           ↑
+  7.0/stable:     --
+  7.0/candidate:  --
+  7.0/beta:       --
+  7.0/edge:       7.0~pre1 (28) 20.5kB devmode
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 3)
+}
+
+func (s *SnapSuite) TestInfoNotFound(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 404, "result": {"message": "not found", "kind": "snap-not-found"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"info", "missing"})
+	c.Check(err, check.ErrorMatches, `no snap found for "missing"`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestInfoError(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, `{"type": "error", "status-code": 500, "result": {"message": "no network"}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Check(err, check.ErrorMatches, `cannot retrieve snap "hello": no network`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestInfoStoreErrorInstalled(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/find":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintln(w, `{"type": "error", "status-code": 500, "result": {"message": "no network"}}`)
+		case "/v2/snaps/hello":
+			fmt.Fprintln(w, mockInfoLocalJSON)
+		case "/v2/apps":
+			fmt.Fprintln(w, mockInfoServicesJSON)
+		default:
+			c.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"info", "hello"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Matches, `(?s)name: +hello\n.*installed: +6.1 \(26\).*`)
+	c.Check(s.Stderr(), check.Equals, `WARNING: cannot find snap "hello": no network
+`)
+}
+
+func (s *SnapSuite) TestInfoPath(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to %s", r.URL.Path)
+	})
+
+	snapDir := filepath.Join(c.MkDir(), "hello")
+	c.Assert(os.MkdirAll(filepath.Join(snapDir, "meta"), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(snapDir, "meta", "snap.yaml"), []byte(`name: hello
+version: 1.0
+summary: Say hello
+description: Says hello.
+confinement: devmode
+apps:
+  hello:
+    command: bin/hello
+  svc:
+    command: bin/svc
+    daemon: forking
+`), 0644), check.IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"info", snapDir})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, fmt.Sprintf(`path:     %q
+name:     hello
+summary:  Say hello
+version:  1.0 devmode
+description: |
+  Says hello.
+commands:
+  - hello
+services:
+  hello.svc:  forking
+`, snapDir))
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
 	"github.com/snapcore/snapd/i18n"
 
 	"github.com/jessevdk/go-flags"
@@ -54,6 +55,11 @@ func (x *cmdKeys) Execute(args []string) error {
 		return ErrExtraArgs
 	}
 
+	manager, err := signtool.GetKeypairManager()
+	if err != nil {
+		return err
+	}
+
 	w := tabWriter()
 	if !x.JSON {
 		fmt.Fprintln(w, i18n.G("Name\tSHA3-384"))
@@ -61,7 +67,6 @@ func (x *cmdKeys) Execute(args []string) error {
 	}
 	keys := []Key{}
 
-	manager := asserts.NewGPGKeypairManager()
 	display := func(privk asserts.PrivateKey, fpr string, uid string) error {
 		key := Key{
 			Name:     uid,
@@ -74,7 +79,7 @@ func (x *cmdKeys) Execute(args []string) error {
 		}
 		return nil
 	}
-	err := manager.Walk(display)
+	err = manager.Walk(display)
 	if err != nil {
 		return err
 	}
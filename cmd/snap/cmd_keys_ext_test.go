@@ -0,0 +1,112 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"os"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func init() {
+	// the test binary doubles as the reference external keypair
+	// manager helper
+	if os.Getenv(assertstest.ExtKeypairManagerKeysEnv) != "" {
+		os.Exit(assertstest.ExtKeypairManagerHelperMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+}
+
+type SnapExtKeysSuite struct {
+	BaseSnapSuite
+
+	pubKey asserts.PublicKey
+}
+
+var _ = Suite(&SnapExtKeysSuite{})
+
+func (s *SnapExtKeysSuite) SetUpTest(c *C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	keysDir := c.MkDir()
+	privKey, rsaPrivKey := assertstest.ReadPrivKey(assertstest.DevKey)
+	err := assertstest.PutExtKeypairManagerKey(keysDir, "default", rsaPrivKey)
+	c.Assert(err, IsNil)
+	s.pubKey = privKey.PublicKey()
+
+	os.Setenv(assertstest.ExtKeypairManagerKeysEnv, keysDir)
+	os.Setenv("SNAP_EXT_KEYMGR", os.Args[0])
+}
+
+func (s *SnapExtKeysSuite) TearDownTest(c *C) {
+	os.Unsetenv(assertstest.ExtKeypairManagerKeysEnv)
+	os.Unsetenv("SNAP_EXT_KEYMGR")
+	s.BaseSnapSuite.TearDownTest(c)
+}
+
+func (s *SnapExtKeysSuite) TestKeys(c *C) {
+	rest, err := snap.Parser().ParseArgs([]string{"keys", "--json"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	var obtained []snap.Key
+	err = json.Unmarshal(s.stdout.Bytes(), &obtained)
+	c.Assert(err, IsNil)
+	c.Check(obtained, DeepEquals, []snap.Key{{
+		Name:     "default",
+		Sha3_384: s.pubKey.ID(),
+	}})
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapExtKeysSuite) TestExportKey(c *C) {
+	rest, err := snap.Parser().ParseArgs([]string{"export-key"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	pubKey, err := asserts.DecodePublicKey(s.stdout.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(pubKey.ID(), Equals, s.pubKey.ID())
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapExtKeysSuite) TestSign(c *C) {
+	s.stdin.Write(statement)
+
+	rest, err := snap.Parser().ParseArgs([]string{"sign"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+
+	a, err := asserts.Decode(s.stdout.Bytes())
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.SnapBuildType)
+	c.Check(a.SignKeyID(), Equals, s.pubKey.ID())
+	err = asserts.SignatureCheck(a, s.pubKey)
+	c.Check(err, IsNil)
+}
+
+func (s *SnapExtKeysSuite) TestSignUnknownKey(c *C) {
+	s.stdin.Write(statement)
+
+	_, err := snap.Parser().ParseArgs([]string{"sign", "-k", "another"})
+	c.Assert(err, ErrorMatches, `cannot find external key named "another"`)
+}
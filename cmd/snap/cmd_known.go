@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/store"
@@ -38,6 +39,7 @@ type cmdKnown struct {
 	} `positional-args:"true" required:"true"`
 
 	Remote bool `long:"remote"`
+	Bundle bool `long:"bundle"`
 }
 
 var shortKnownHelp = i18n.G("Shows known assertions of the provided type")
@@ -45,12 +47,21 @@ var longKnownHelp = i18n.G(`
 The known command shows known assertions of the provided type.
 If header=value pairs are provided after the assertion type, the assertions
 shown must also have the specified headers matching the provided values.
+
+With --remote the assertion is fetched from the store together with all
+the assertions it depends on, prerequisites first. Adding --bundle also
+checks that the result can be added as a whole with "snap ack --bundle" to
+a device that only trusts the default root assertions, for example one
+without network access.
 `)
 
 func init() {
 	addCommand("known", shortKnownHelp, longKnownHelp, func() flags.Commander {
 		return &cmdKnown{}
-	}, nil, []argDesc{
+	}, map[string]string{
+		"remote": i18n.G("Fetch the assertion and its prerequisites from the store"),
+		"bundle": i18n.G("Check that the fetched assertions can be acked together (requires --remote)"),
+	}, []argDesc{
 		{
 			name: i18n.G("<assertion type>"),
 			desc: i18n.G("Assertion type name"),
@@ -65,7 +76,12 @@ var nl = []byte{'\n'}
 
 var storeNew = store.New
 
-func downloadAssertion(typeName string, headers map[string]string) ([]asserts.Assertion, error) {
+// downloadAssertion fetches the assertion of the given type and
+// primary key headers from the store together with its prerequisites,
+// returning them with prerequisites first. If bundle is set the
+// assertions are also checked, in order, against a database
+// containing only the default trusted assertions.
+func downloadAssertion(typeName string, headers map[string]string, bundle bool) ([]asserts.Assertion, error) {
 	var user *auth.UserState
 
 	// FIXME: set auth context
@@ -84,19 +100,43 @@ func downloadAssertion(typeName string, headers map[string]string) ([]asserts.As
 		primaryKeys[i] = pk
 	}
 
-	sto := storeNew(nil, authContext)
-	as, err := sto.Assertion(at, primaryKeys, user)
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   sysdb.Trusted(),
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return []asserts.Assertion{as}, nil
+	sto := storeNew(nil, authContext)
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		return sto.Assertion(ref.Type, ref.PrimaryKey, user)
+	}
+	var assertions []asserts.Assertion
+	save := func(a asserts.Assertion) error {
+		if bundle {
+			if err := db.Add(a); err != nil {
+				return fmt.Errorf("cannot bundle %v: %v", a.Ref(), err)
+			}
+		}
+		assertions = append(assertions, a)
+		return nil
+	}
+	f := asserts.NewFetcher(db, retrieve, save)
+	if err := f.Fetch(&asserts.Ref{Type: at, PrimaryKey: primaryKeys}); err != nil {
+		return nil, err
+	}
+
+	return assertions, nil
 }
 
 func (x *cmdKnown) Execute(args []string) error {
 	if len(args) > 0 {
 		return ErrExtraArgs
 	}
+	if x.Bundle && !x.Remote {
+		return fmt.Errorf(i18n.G("--bundle requires --remote"))
+	}
 
 	// TODO: share this kind of parsing once it's clearer how often is used in snap
 	headers := map[string]string{}
@@ -111,7 +151,7 @@ func (x *cmdKnown) Execute(args []string) error {
 	var assertions []asserts.Assertion
 	var err error
 	if x.Remote {
-		assertions, err = downloadAssertion(x.KnownOptions.AssertTypeName, headers)
+		assertions, err = downloadAssertion(x.KnownOptions.AssertTypeName, headers, x.Bundle)
 	} else {
 		assertions, err = Client().Known(x.KnownOptions.AssertTypeName, headers)
 	}
@@ -20,37 +20,70 @@
 package main_test
 
 import (
-	"fmt"
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
+	"time"
 
 	"gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/sysdb"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/store"
 
 	snap "github.com/snapcore/snapd/cmd/snap"
 )
 
-// acquire example data via:
-// curl  -H "accept: application/x.ubuntu.assertion" https://assertions.ubuntu.com/v1/assertions/model/16/canonical/pi2
-const mockModelAssertion = `type: model
-authority-id: canonical
-series: 16
-brand-id: canonical
-model: pi99
-architecture: armhf
-gadget: pi99
-kernel: pi99-kernel
-timestamp: 2016-08-31T00:00:00.0Z
-sign-key-sha3-384: 9tydnLa6MTJ-jaQTFUXEwHl1yRx7ZS4K5cyFDhYDcPzhS7uyEkDxdUjg9g08BtNn
-
-AcLorsomethingthatlooksvaguelylikeasignature==
-`
-
-func (s *SnapSuite) TestKnownRemote(c *check.C) {
-	var server *httptest.Server
+type knownRemoteSuite struct {
+	BaseSnapSuite
+
+	storeSigning *assertstest.StoreStack
+	brandSigning *assertstest.SigningDB
+	assertions   map[string]asserts.Assertion
+	requested    []string
+}
+
+var _ = check.Suite(&knownRemoteSuite{})
+
+func (s *knownRemoteSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	rootPrivKey, _ := assertstest.GenerateKey(752)
+	storePrivKey, _ := assertstest.GenerateKey(752)
+	s.storeSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	s.AddCleanup(sysdb.InjectTrusted(s.storeSigning.Trusted))
+
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	s.brandSigning = assertstest.NewSigningDB("my-brand", brandPrivKey)
+	brandAcct := assertstest.NewAccount(s.storeSigning, "my-brand", map[string]interface{}{
+		"account-id": "my-brand",
+	}, "")
+	brandKey := assertstest.NewAccountKey(s.storeSigning, brandAcct, nil, brandPrivKey.PublicKey(), "")
+
+	s.assertions = make(map[string]asserts.Assertion)
+	s.requested = nil
+	for _, a := range []asserts.Assertion{s.storeSigning.StoreAccountKey(""), brandAcct, brandKey} {
+		s.addAssertion(a)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		s.requested = append(s.requested, r.URL.Path)
+		a := s.assertions[r.URL.Path]
+		if a == nil {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(404)
+			w.Write([]byte(`{"status": 404}`))
+			return
+		}
+		w.Header().Set("Content-Type", asserts.MediaType)
+		w.Write(asserts.Encode(a))
+	}))
+	s.AddCleanup(server.Close)
 
 	restorer := snap.MockStoreNew(func(cfg *store.Config, auth auth.AuthContext) *store.Store {
 		if cfg == nil {
@@ -61,29 +94,94 @@ func (s *SnapSuite) TestKnownRemote(c *check.C) {
 		cfg.AssertionsURI = serverURL
 		return store.New(cfg, auth)
 	})
-	defer restorer()
-
-	n := 0
-	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch n {
-		case 0:
-			c.Check(r.Method, check.Equals, "GET")
-			c.Check(r.URL.Path, check.Equals, "/assertions/model/16/canonical/pi99")
-			fmt.Fprintln(w, mockModelAssertion)
-		default:
-			c.Fatalf("expected to get 1 requests, now on %d", n+1)
-		}
+	s.AddCleanup(restorer)
+}
 
-		n++
-	}))
+func (s *knownRemoteSuite) addAssertion(a asserts.Assertion) {
+	ref := a.Ref()
+	p := path.Join("/assertions", ref.Type.Name, path.Join(ref.PrimaryKey...))
+	s.assertions[p] = a
+}
+
+func (s *knownRemoteSuite) makeModel(c *check.C, timestamp time.Time) asserts.Assertion {
+	model, err := s.brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "pi99",
+		"architecture": "armhf",
+		"gadget":       "pi99",
+		"kernel":       "pi99-kernel",
+		"timestamp":    timestamp.Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	s.addAssertion(model)
+	return model
+}
+
+func (s *knownRemoteSuite) expectedOutput(model asserts.Assertion) string {
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	for _, p := range []string{
+		"/assertions/account-key/" + s.storeSigning.KeyID,
+		"/assertions/account/my-brand",
+		"/assertions/account-key/" + s.brandSigning.KeyID,
+	} {
+		enc.Encode(s.assertions[p])
+	}
+	enc.Encode(model)
+	return buf.String()
+}
+
+func (s *knownRemoteSuite) TestKnownRemote(c *check.C) {
+	model := s.makeModel(c, time.Now())
+
+	rest, err := snap.Parser().ParseArgs([]string{"known", "--remote", "model", "series=16", "brand-id=my-brand", "model=pi99"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.requested, check.DeepEquals, []string{
+		"/assertions/model/16/my-brand/pi99",
+		"/assertions/account-key/" + s.brandSigning.KeyID,
+		"/assertions/account/my-brand",
+		"/assertions/account-key/" + s.storeSigning.KeyID,
+	})
+	c.Check(s.Stdout(), check.Equals, s.expectedOutput(model))
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *knownRemoteSuite) TestKnownRemoteBundle(c *check.C) {
+	model := s.makeModel(c, time.Now())
 
-	rest, err := snap.Parser().ParseArgs([]string{"known", "--remote", "model", "series=16", "brand-id=canonical", "model=pi99"})
+	rest, err := snap.Parser().ParseArgs([]string{"known", "--remote", "--bundle", "model", "series=16", "brand-id=my-brand", "model=pi99"})
 	c.Assert(err, check.IsNil)
 	c.Assert(rest, check.DeepEquals, []string{})
-	c.Check(s.Stdout(), check.Equals, mockModelAssertion)
+	c.Check(s.Stdout(), check.Equals, s.expectedOutput(model))
 	c.Check(s.Stderr(), check.Equals, "")
 }
 
+func (s *knownRemoteSuite) TestKnownRemoteBundleNotImportable(c *check.C) {
+	// the model predates the brand key
+	s.makeModel(c, time.Now().AddDate(-1, 0, 0))
+
+	// without --bundle the assertions are just fetched
+	_, err := snap.Parser().ParseArgs([]string{"known", "--remote", "model", "series=16", "brand-id=my-brand", "model=pi99"})
+	c.Assert(err, check.IsNil)
+	s.stdout.Reset()
+
+	_, err = snap.Parser().ParseArgs([]string{"known", "--remote", "--bundle", "model", "series=16", "brand-id=my-brand", "model=pi99"})
+	c.Assert(err, check.ErrorMatches, `cannot bundle model \(pi99; series:16 brand-id:my-brand\): model assertion timestamp outside of signing key validity.*`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
+
+func (s *knownRemoteSuite) TestKnownRemoteNotFound(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"known", "--remote", "model", "series=16", "brand-id=my-brand", "model=pi99"})
+	c.Assert(err, check.ErrorMatches, `.*model.*not found`)
+}
+
+func (s *SnapSuite) TestKnownBundleRequiresRemote(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"known", "--bundle", "model", "series=16", "brand-id=canonical", "model=pi99"})
+	c.Assert(err, check.ErrorMatches, `--bundle requires --remote`)
+}
+
 func (s *SnapSuite) TestKnownRemoteMissingPrimaryKey(c *check.C) {
 	_, err := snap.Parser().ParseArgs([]string{"known", "--remote", "model", "series=16", "brand-id=canonical"})
 	c.Assert(err, check.ErrorMatches, `missing primary header "model" to query remote assertion`)
@@ -175,11 +175,13 @@ func runSnapConfine(info *snap.Info, securityTag, snapApp, command, hook string,
 		logger.Noticef("WARNING: cannot create user data directory: %s", err)
 	}
 
-	cmd := []string{
-		filepath.Join(dirs.LibExecDir, "snap-confine"),
-		securityTag,
-		filepath.Join(dirs.LibExecDir, "snap-exec"),
+	cmd := []string{filepath.Join(dirs.LibExecDir, "snap-confine")}
+	if info.Base != "" {
+		// have snap-confine set up the base instead of core as the
+		// root filesystem of the snap
+		cmd = append(cmd, "--base", info.Base)
 	}
+	cmd = append(cmd, securityTag, filepath.Join(dirs.LibExecDir, "snap-exec"))
 
 	if command != "" {
 		cmd = append(cmd, "--command="+command)
@@ -133,6 +133,35 @@ func (s *SnapSuite) TestSnapRunAppWithCommandIntegration(c *check.C) {
 	c.Check(execEnv, testutil.Contains, "SNAP_REVISION=42")
 }
 
+func (s *SnapSuite) TestSnapRunAppWithBaseIntegration(c *check.C) {
+	// mock installed snap
+	dirs.SetRootDir(c.MkDir())
+	defer func() { dirs.SetRootDir("/") }()
+
+	si := snaptest.MockSnap(c, string(mockYaml)+"base: some-base\n", string(mockContents), &snap.SideInfo{
+		Revision: snap.R(42),
+	})
+	err := os.Symlink(si.MountDir(), filepath.Join(si.MountDir(), "../current"))
+	c.Assert(err, check.IsNil)
+
+	// redirect exec
+	execArgs := []string{}
+	restorer := snaprun.MockSyscallExec(func(arg0 string, args []string, envv []string) error {
+		execArgs = args
+		return nil
+	})
+	defer restorer()
+
+	err = snaprun.SnapRunApp("snapname.app", "", []string{"arg1"})
+	c.Assert(err, check.IsNil)
+	c.Check(execArgs, check.DeepEquals, []string{
+		filepath.Join(dirs.LibExecDir, "snap-confine"),
+		"--base", "some-base",
+		"snap.snapname.app",
+		filepath.Join(dirs.LibExecDir, "snap-exec"),
+		"snapname.app", "arg1"})
+}
+
 func (s *SnapSuite) TestSnapRunCreateDataDirs(c *check.C) {
 	info, err := snap.InfoFromSnapYaml(mockYaml)
 	c.Assert(err, check.IsNil)
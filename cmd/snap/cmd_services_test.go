@@ -0,0 +1,230 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type appOpSuite struct {
+	BaseSnapSuite
+
+	restoreAll func()
+}
+
+var _ = check.Suite(&appOpSuite{})
+
+func (s *appOpSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	restoreClientRetry := client.MockDoRetry(time.Millisecond, 10*time.Millisecond)
+	restorePollTime := snap.MockPollTime(time.Millisecond)
+	s.restoreAll = func() {
+		restoreClientRetry()
+		restorePollTime()
+	}
+}
+
+func (s *appOpSuite) TearDownTest(c *check.C) {
+	s.restoreAll()
+	s.BaseSnapSuite.TearDownTest(c)
+}
+
+func (s *appOpSuite) expectedBody(op string, names, extra []string) map[string]interface{} {
+	inames := make([]interface{}, len(names))
+	for i, name := range names {
+		inames[i] = name
+	}
+	expectedBody := map[string]interface{}{
+		"action": op,
+		"names":  inames,
+	}
+	for _, x := range extra {
+		expectedBody[x] = true
+	}
+	return expectedBody
+}
+
+func (s *appOpSuite) args(op string, names, extra []string) []string {
+	args := []string{op}
+	for _, x := range extra {
+		args = append(args, "--"+x)
+	}
+	args = append(args, names...)
+	return args
+}
+
+func (s *appOpSuite) testOp(c *check.C, op, summary string, names, extra []string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query(), check.HasLen, 0)
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, s.expectedBody(op, names, extra))
+			c.Check(r.Method, check.Equals, "POST")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type":"async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs(s.args(op, names, extra))
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, summary+"\n")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *appOpSuite) TestAppOps(c *check.C) {
+	extras := []string{"enable", "disable"}
+	summaries := []string{"Started.", "Stopped.", "Restarted."}
+	for i, op := range []string{"start", "stop", "restart"} {
+		s.stdout.Reset()
+		s.testOp(c, op, summaries[i], []string{"foo"}, nil)
+		if i < 2 {
+			s.stdout.Reset()
+			s.testOp(c, op, summaries[i], []string{"foo", "bar.baz"}, []string{extras[i]})
+		}
+	}
+}
+
+func (s *appOpSuite) TestAppOpsBadFlags(c *check.C) {
+	for _, args := range [][]string{
+		{"start", "--disable", "foo"},
+		{"stop", "--enable", "foo"},
+		{"restart", "--enable", "foo"},
+	} {
+		_, err := snap.Parser().ParseArgs(args)
+		c.Check(err, check.ErrorMatches, "unknown flag.*")
+	}
+}
+
+func (s *appOpSuite) TestAppStatus(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query(), check.DeepEquals, url.Values{"select": {"service"}})
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+{"snap": "foo", "name": "bar", "daemon": "simple", "enabled": true, "active": true},
+{"snap": "foo", "name": "baz", "daemon": "forking"}
+]}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"services"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Service  Startup   Current
+foo.bar  enabled   active
+foo.baz  disabled  inactive
+`)
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *appOpSuite) TestAppStatusNoServices(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query(), check.DeepEquals, url.Values{"select": {"service"}, "names": {"foo"}})
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"services", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "There are no services provided by installed snaps.\n")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *appOpSuite) TestLogs(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/logs")
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Query(), check.DeepEquals, url.Values{"names": {"foo"}, "n": {"10"}})
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, "\x1e"+`{"timestamp": "2016-11-02T10:16:05.000000Z", "message": "hello", "sid": "foo", "pid": "42"}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"logs", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "2016-11-02T10:16:05.000000Z foo[42]: hello\n")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *appOpSuite) TestLogsN(c *check.C) {
+	for _, t := range []struct {
+		args []string
+		n    string
+	}{
+		{[]string{"logs", "-n=all", "foo"}, "all"},
+		{[]string{"logs", "-n", "3", "-f", "foo"}, "3"},
+	} {
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			c.Check(r.URL.Query().Get("n"), check.Equals, t.n)
+			fmt.Fprint(w, "")
+		})
+		_, err := snap.Parser().ParseArgs(t.args)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *appOpSuite) TestLogsBadN(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+	_, err := snap.Parser().ParseArgs([]string{"logs", "-n=potato", "foo"})
+	c.Assert(err, check.ErrorMatches, `invalid argument for flag "-n": expected a non-negative integer, or "all"`)
+}
@@ -25,7 +25,6 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
-	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/signtool"
 	"github.com/snapcore/snapd/i18n"
 )
@@ -55,7 +54,10 @@ func (x *cmdSign) Execute(args []string) error {
 		return fmt.Errorf(i18n.G("cannot read assertion input: %v"), err)
 	}
 
-	keypairMgr := asserts.NewGPGKeypairManager()
+	keypairMgr, err := signtool.GetKeypairManager()
+	if err != nil {
+		return err
+	}
 	privKey, err := keypairMgr.GetByName(x.KeyName)
 	if err != nil {
 		return err
@@ -44,20 +44,96 @@ func lastLogStr(logs []string) string {
 var (
 	maxGoneTime = 5 * time.Second
 	pollTime    = 100 * time.Millisecond
+	// maxEventsSilence is how long to wait for events about a change
+	// before checking on it anyway
+	maxEventsSilence = 10 * time.Second
 )
 
-func wait(client *client.Client, id string) (*client.Change, error) {
+// changeError returns the error a failed change finished with, with a
+// hint for the kinds of error that warrant one.
+func changeError(chg *client.Change) error {
+	if chg.ErrKind == client.ErrorKindHookTimeout {
+		return fmt.Errorf(i18n.G("%s\n\nA hook of the snap took too long and was stopped; what it output is in\nthe log of the change, see 'snap change %s'."), chg.Err, chg.ID)
+	}
+	return errors.New(chg.Err)
+}
+
+// followEvents is whether wait follows changes through the events
+// about them, rather than polling them.
+var followEvents = true
+
+// waitNext returns once there might be something new about the change
+// being followed, showing its progress meanwhile. It returns false if
+// events stopped coming.
+func waitNext(events <-chan client.Event, show func(t *client.Task)) bool {
+	if events == nil {
+		// note this very purposely is not a ticker; we want
+		// to sleep 100ms between calls, not call once every
+		// 100ms.
+		time.Sleep(pollTime)
+		return true
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			if ev.Type != client.EventTaskProgress {
+				return true
+			}
+			show(&client.Task{
+				ID:       ev.TaskID,
+				Summary:  ev.Summary,
+				Status:   ev.Status,
+				Log:      []string{ev.Log},
+				Progress: ev.Progress,
+			})
+		case <-time.After(maxEventsSilence):
+			// make sure nothing was missed
+			return true
+		}
+	}
+}
+
+func wait(cli *client.Client, id string) (*client.Change, error) {
 	pb := progress.NewTextProgress()
 	defer func() {
 		pb.Finished()
 	}()
 
+	// the events about the change avoid polling it, if the server
+	// can stream them
+	var events <-chan client.Event
+	if followEvents {
+		if es, err := cli.Events(&client.EventsOptions{ChangeID: id}); err == nil {
+			defer es.Close()
+			events = es.Events()
+		}
+	}
+
 	tMax := time.Time{}
 
 	var lastID string
 	lastLog := map[string]string{}
+	show := func(t *client.Task) {
+		switch {
+		case t.Progress.Total == 1:
+			pb.Spin(t.Summary)
+			nowLog := lastLogStr(t.Log)
+			if lastLog[t.ID] != nowLog {
+				pb.Notify(nowLog)
+				lastLog[t.ID] = nowLog
+			}
+		case t.ID == lastID:
+			pb.Set(float64(t.Progress.Done))
+		default:
+			pb.Start(t.Progress.Label, float64(t.Progress.Total))
+			lastID = t.ID
+		}
+	}
 	for {
-		chg, err := client.Change(id)
+		chg, err := cli.Change(id)
 		if err != nil {
 			// an error here means the server most likely went away
 			// XXX: it actually can be a bunch of other things; fix client to expose it better
@@ -69,6 +145,8 @@ func wait(client *client.Client, id string) (*client.Change, error) {
 				return nil, err
 			}
 			pb.Spin(i18n.G("Waiting for server to restart"))
+			// the events went away with the server
+			events = nil
 			time.Sleep(pollTime)
 			continue
 		}
@@ -78,23 +156,10 @@ func wait(client *client.Client, id string) (*client.Change, error) {
 		}
 
 		for _, t := range chg.Tasks {
-			switch {
-			case t.Status != "Doing":
-				continue
-			case t.Progress.Total == 1:
-				pb.Spin(t.Summary)
-				nowLog := lastLogStr(t.Log)
-				if lastLog[t.ID] != nowLog {
-					pb.Notify(nowLog)
-					lastLog[t.ID] = nowLog
-				}
-			case t.ID == lastID:
-				pb.Set(float64(t.Progress.Done))
-			default:
-				pb.Start(t.Progress.Label, float64(t.Progress.Total))
-				lastID = t.ID
+			if t.Status == "Doing" {
+				show(t)
+				break
 			}
-			break
 		}
 
 		if chg.Ready {
@@ -103,16 +168,15 @@ func wait(client *client.Client, id string) (*client.Change, error) {
 			}
 
 			if chg.Err != "" {
-				return chg, errors.New(chg.Err)
+				return chg, changeError(chg)
 			}
 
 			return nil, fmt.Errorf(i18n.G("change finished in status %q with no error message"), chg.Status)
 		}
 
-		// note this very purposely is not a ticker; we want
-		// to sleep 100ms between calls, not call once every
-		// 100ms.
-		time.Sleep(pollTime)
+		if !waitNext(events, show) {
+			events = nil
+		}
 	}
 }
 
@@ -139,6 +203,10 @@ removed.
 
 var longRefreshHelp = i18n.G(`
 The refresh command refreshes (updates) the named snap.
+
+With --at the refresh is scheduled for the given time instead. Until it
+has run, the snaps being refreshed are considered as having changes in
+progress: installing, refreshing or removing them fails in the meantime.
 `)
 
 var longTryHelp = i18n.G(`
@@ -448,18 +516,34 @@ type cmdRefresh struct {
 
 	Revision         string `long:"revision"`
 	List             bool   `long:"list"`
+	Time             bool   `long:"time"`
 	IgnoreValidation bool   `long:"ignore-validation"`
+	At               string `long:"at"`
 	Positional       struct {
 		Snaps []string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
 }
 
+// showScheduled reports a refresh that was scheduled instead of waiting
+// for it to happen.
+func showScheduled(snaps []string, changeID string, at time.Time) error {
+	what := i18n.G("all snaps")
+	if len(snaps) > 0 {
+		what = strings.Join(snaps, ", ")
+	}
+	fmt.Fprintf(Stdout, i18n.G("Refresh of %s scheduled for %s (change %s)\n"), what, at.Format(time.RFC3339), changeID)
+	return nil
+}
+
 func refreshMany(snaps []string, opts *client.SnapOptions) error {
 	cli := Client()
 	changeID, err := cli.RefreshMany(snaps, opts)
 	if err != nil {
 		return err
 	}
+	if opts != nil && opts.At != nil {
+		return showScheduled(snaps, changeID, *opts.At)
+	}
 
 	chg, err := wait(cli, changeID)
 	if err != nil {
@@ -486,6 +570,9 @@ func refreshOne(name string, opts *client.SnapOptions) error {
 	if err != nil {
 		return err
 	}
+	if opts.At != nil {
+		return showScheduled([]string{name}, changeID, *opts.At)
+	}
 
 	if _, err := wait(cli, changeID); err != nil {
 		return err
@@ -524,6 +611,27 @@ func listRefresh() error {
 	return nil
 }
 
+func showRefreshTimes() error {
+	cli := Client()
+	sysinfo, err := cli.SysInfo()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "schedule: %s\n", sysinfo.Refresh.Schedule)
+	if sysinfo.Refresh.Last != "" {
+		fmt.Fprintf(Stdout, "last: %s\n", sysinfo.Refresh.Last)
+	} else {
+		fmt.Fprintf(Stdout, "last: n/a\n")
+	}
+	if sysinfo.Refresh.Next != "" {
+		fmt.Fprintf(Stdout, "next: %s\n", sysinfo.Refresh.Next)
+	} else {
+		fmt.Fprintf(Stdout, "next: n/a\n")
+	}
+	return nil
+}
+
 func (x *cmdRefresh) Execute([]string) error {
 	if err := x.setChannelFromCommandline(); err != nil {
 		return err
@@ -539,6 +647,21 @@ func (x *cmdRefresh) Execute([]string) error {
 
 		return listRefresh()
 	}
+	if x.Time {
+		if x.asksForMode() || x.asksForChannel() || len(x.Positional.Snaps) > 0 {
+			return errors.New(i18n.G("--time does not take mode, channel flags nor snap names"))
+		}
+
+		return showRefreshTimes()
+	}
+	var at *time.Time
+	if x.At != "" {
+		t, err := time.Parse(time.RFC3339, x.At)
+		if err != nil {
+			return fmt.Errorf(i18n.G("cannot parse --at time %q: expected RFC3339 format, like %q"), x.At, time.RFC3339)
+		}
+		at = &t
+	}
 	if len(x.Positional.Snaps) == 1 {
 		opts := &client.SnapOptions{
 			Channel:          x.Channel,
@@ -546,6 +669,7 @@ func (x *cmdRefresh) Execute([]string) error {
 			JailMode:         x.JailMode,
 			IgnoreValidation: x.IgnoreValidation,
 			Revision:         x.Revision,
+			At:               at,
 		}
 		return refreshOne(x.Positional.Snaps[0], opts)
 	}
@@ -558,7 +682,11 @@ func (x *cmdRefresh) Execute([]string) error {
 		return errors.New(i18n.G("a single snap name must be specified when ignoring validation"))
 	}
 
-	return refreshMany(x.Positional.Snaps, nil)
+	var opts *client.SnapOptions
+	if at != nil {
+		opts = &client.SnapOptions{At: at}
+	}
+	return refreshMany(x.Positional.Snaps, opts)
 }
 
 type cmdTry struct {
@@ -719,6 +847,51 @@ func (x *cmdRevert) Execute(args []string) error {
 	return nil
 }
 
+type cmdSwitch struct {
+	channelMixin
+
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortSwitchHelp = i18n.G("Switches snap to a different channel")
+var longSwitchHelp = i18n.G(`
+The switch command switches the given snap to a different channel without
+doing a refresh. The next refresh of the snap, manual or automatic, will
+follow the new channel.
+
+Channels are given as <risk> for the default track, or as <track>/<risk>.
+`)
+
+func (x *cmdSwitch) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if err := x.setChannelFromCommandline(); err != nil {
+		return err
+	}
+
+	name := x.Positional.Snap
+	channel := x.Channel
+	if channel == "" {
+		return fmt.Errorf(i18n.G("missing --channel=<channel-name> parameter"))
+	}
+
+	cli := Client()
+	changeID, err := cli.Switch(name, &client.SnapOptions{Channel: channel})
+	if err != nil {
+		return err
+	}
+
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("%q switched to the %q channel\n"), name, channel)
+	return nil
+}
+
 func init() {
 	addCommand("remove", shortRemoveHelp, longRemoveHelp, func() flags.Commander { return &cmdRemove{} },
 		map[string]string{"revision": i18n.G("Remove only the given revision")}, nil)
@@ -732,11 +905,14 @@ func init() {
 		channelDescs.also(modeDescs).also(map[string]string{
 			"revision":          i18n.G("Refresh to the given revision"),
 			"list":              i18n.G("Show available snaps for refresh"),
+			"time":              i18n.G("Show auto refresh information"),
 			"ignore-validation": i18n.G("Ignore validation by other snaps blocking the refresh"),
+			"at":                i18n.G("Schedule the refresh for the given time (RFC3339) instead of doing it now"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, modeDescs, nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, nil, nil)
 	addCommand("disable", shortDisableHelp, longDisableHelp, func() flags.Commander { return &cmdDisable{} }, nil, nil)
+	addCommand("switch", shortSwitchHelp, longSwitchHelp, func() flags.Commander { return &cmdSwitch{} }, channelDescs, nil)
 	addCommand("revert", shortRevertHelp, longRevertHelp, func() flags.Commander { return &cmdRevert{} }, modeDescs.also(map[string]string{
 		"revision": "Revert to the given revision",
 	}), nil)
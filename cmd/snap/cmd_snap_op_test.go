@@ -166,6 +166,21 @@ func (s *SnapOpSuite) TestWaitRecovers(c *check.C) {
 	c.Check(string(buf), check.Matches, "(?ms).*Waiting for server to restart.*")
 }
 
+func (s *SnapOpSuite) TestWaitHookTimeout(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "ready": true, "status": "Error", "err": "cannot perform the following tasks:\n- Run configure hook (hook \"configure\" timed out after 10m0s)", "err-kind": "hook-timeout"}}`)
+	})
+
+	cli := snap.Client()
+	_, err := snap.Wait(cli, "42")
+	c.Assert(err, check.NotNil)
+	c.Check(err.Error(), check.Equals, `cannot perform the following tasks:
+- Run configure hook (hook "configure" timed out after 10m0s)
+
+A hook of the snap took too long and was stopped; what it output is in
+the log of the change, see 'snap change 42'.`)
+}
+
 func (s *SnapOpSuite) TestInstall(c *check.C) {
 	s.srv.checker = func(r *http.Request) {
 		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
@@ -331,6 +346,50 @@ func (s *SnapSuite) TestRefreshListErr(c *check.C) {
 	c.Check(err, check.ErrorMatches, "--list does not take .* flags")
 }
 
+func (s *SnapSuite) TestRefreshTime(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "GET")
+			c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"schedule": "00:00-04:59,05:00-10:59", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00"}}}`)
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `schedule: 00:00-04:59,05:00-10:59
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestRefreshTimeNeverRefreshed(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"schedule": "00:00-04:59"}}}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--time"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `schedule: 00:00-04:59
+last: n/a
+next: n/a
+`)
+}
+
+func (s *SnapSuite) TestRefreshTimeErr(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--time", "foo"})
+	c.Check(err, check.ErrorMatches, "--time does not take .* snap names")
+}
+
 func (s *SnapOpSuite) TestRefreshOne(c *check.C) {
 	s.RedirectClientToTestServer(s.srv.handle)
 	s.srv.checker = func(r *http.Request) {
@@ -344,6 +403,45 @@ func (s *SnapOpSuite) TestRefreshOne(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *SnapOpSuite) TestRefreshOneAt(c *check.C) {
+	s.RedirectClientToTestServer(s.srv.handle)
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/one")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "refresh",
+			"at":     "2030-01-02T03:04:05Z",
+		})
+	}
+	rest, err := snap.Parser().ParseArgs([]string{"refresh", "--at", "2030-01-02T03:04:05Z", "one"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, "Refresh of one scheduled for 2030-01-02T03:04:05Z (change 42)\n")
+	// only the POST, no waiting for the change
+	c.Check(s.srv.n, check.Equals, 1)
+}
+
+func (s *SnapOpSuite) TestRefreshAllAt(c *check.C) {
+	s.RedirectClientToTestServer(s.srv.handle)
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action": "refresh",
+			"at":     "2030-01-02T03:04:05Z",
+		})
+	}
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--at", "2030-01-02T03:04:05Z"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "Refresh of all snaps scheduled for 2030-01-02T03:04:05Z (change 42)\n")
+	c.Check(s.srv.n, check.Equals, 1)
+}
+
+func (s *SnapOpSuite) TestRefreshAtBadTime(c *check.C) {
+	_, err := snap.Parser().ParseArgs([]string{"refresh", "--at", "tomorrow", "one"})
+	c.Check(err, check.ErrorMatches, `cannot parse --at time "tomorrow": expected RFC3339 format, .*`)
+}
+
 func (s *SnapOpSuite) TestRefreshOneSwitchChannel(c *check.C) {
 	s.RedirectClientToTestServer(s.srv.handle)
 	s.srv.checker = func(r *http.Request) {
@@ -506,6 +604,47 @@ func (s *SnapOpSuite) TestInstallFromChannel(c *check.C) {
 	c.Check(s.srv.n, check.Equals, s.srv.total)
 }
 
+func (s *SnapOpSuite) TestSwitch(c *check.C) {
+	s.srv.total = 3
+	s.srv.checker = func(r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "switch",
+			"channel": "1.0/beta",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	rest, err := snap.Parser().ParseArgs([]string{"switch", "--channel=1.0/beta", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `"foo" switched to the "1.0/beta" channel`+"\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	// ensure that the fake server api was actually hit
+	c.Check(s.srv.n, check.Equals, s.srv.total)
+}
+
+func (s *SnapOpSuite) TestSwitchShortcut(c *check.C) {
+	s.srv.total = 3
+	s.srv.checker = func(r *http.Request) {
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+			"action":  "switch",
+			"channel": "edge",
+		})
+	}
+
+	s.RedirectClientToTestServer(s.srv.handle)
+	_, err := snap.Parser().ParseArgs([]string{"switch", "--edge", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, `"foo" switched to the "edge" channel`+"\n")
+}
+
+func (s *SnapOpSuite) TestSwitchNoChannel(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	_, err := snap.Parser().ParseArgs([]string{"switch", "foo"})
+	c.Assert(err, check.ErrorMatches, `missing --channel=<channel-name> parameter`)
+}
+
 func (s *SnapOpSuite) TestEnable(c *check.C) {
 	s.srv.total = 3
 	s.srv.checker = func(r *http.Request) {
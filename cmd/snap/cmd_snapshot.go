@@ -0,0 +1,311 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var (
+	shortSavedHelp = i18n.G("List currently stored snapshots")
+	longSavedHelp  = i18n.G(`
+The saved command displays a list of snapshots that have been created
+previously with the 'save' command, or automatically before the removal
+of a snap.
+`)
+	shortSaveHelp = i18n.G("Save a snapshot of the current data")
+	longSaveHelp  = i18n.G(`
+The save command creates a snapshot of the current user and system data
+of the given snaps, or of all installed snaps if none are given. By default the data of all users is saved; --users limits
+that to the given comma-separated list of users.
+`)
+	shortForgetHelp = i18n.G("Delete a snapshot")
+	longForgetHelp  = i18n.G(`
+The forget command deletes a snapshot set, or the snapshots of the given
+snaps within it. This operation can not be undone.
+`)
+	shortCheckHelp = i18n.G("Check a snapshot")
+	longCheckHelp  = i18n.G(`
+The check-snapshot command verifies the checksums of the data in a
+snapshot set, optionally limited to the given snaps and users.
+`)
+	shortRestoreHelp = i18n.G("Restore a snapshot")
+	longRestoreHelp  = i18n.G(`
+The restore command replaces the current data of the given snaps (or of
+all the snaps in the snapshot set, if none are given) with the data in
+the snapshot set, optionally limited to the given users.
+
+The data of the snapshotted revision is restored as that of the current
+revision of each snap.
+`)
+)
+
+type snapshotPositional struct {
+	ID    string   `positional-arg-name:"<id>"`
+	Snaps []string `positional-arg-name:"<snap>"`
+}
+
+func (p *snapshotPositional) setID() (uint64, error) {
+	if p.ID == "" {
+		return 0, errors.New(i18n.G("missing snapshot set ID"))
+	}
+	setID, err := strconv.ParseUint(p.ID, 10, 64)
+	if err != nil || setID == 0 {
+		return 0, fmt.Errorf(i18n.G("invalid snapshot set ID %q"), p.ID)
+	}
+	return setID, nil
+}
+
+func quotedSnapNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func splitUsers(users string) []string {
+	if users == "" {
+		return nil
+	}
+	return strings.Split(users, ",")
+}
+
+// fmtSize formats a size in bytes using SI units.
+func fmtSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
+
+var timeNow = time.Now
+
+// fmtAge formats the time since the given time in its largest unit.
+func fmtAge(t time.Time) string {
+	d := timeNow().Sub(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+func printSnapshotSets(sets []client.SnapshotSet) {
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Set\tSnap\tAge\tVersion\tRev\tSize\tNotes"))
+	for _, set := range sets {
+		for _, sh := range set.Snapshots {
+			notes := "-"
+			switch {
+			case sh.Broken != "":
+				notes = "broken: " + sh.Broken
+			case sh.Auto:
+				notes = "auto"
+			}
+			// broken snapshots might be missing any of these
+			age, version, rev := "-", "-", "-"
+			if !sh.Time.IsZero() {
+				age = fmtAge(sh.Time)
+			}
+			if sh.Version != "" {
+				version = sh.Version
+			}
+			if !sh.Revision.Unset() {
+				rev = sh.Revision.String()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", set.ID, sh.Snap, age, version, rev, fmtSize(sh.Size), notes)
+		}
+	}
+}
+
+type savedCmd struct {
+	ID         uint64 `long:"id"`
+	Positional struct {
+		Snaps []string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func (x *savedCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	sets, err := Client().SnapshotSets(x.ID, x.Positional.Snaps)
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No snapshots found."))
+		return nil
+	}
+
+	printSnapshotSets(sets)
+	return nil
+}
+
+type saveCmd struct {
+	Users      string `long:"users"`
+	Positional struct {
+		Snaps []string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes"`
+}
+
+func (x *saveCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	setID, changeID, err := cli.SnapshotMany(x.Positional.Snaps, splitUsers(x.Users))
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	sets, err := cli.SnapshotSets(setID, x.Positional.Snaps)
+	if err != nil {
+		return err
+	}
+	printSnapshotSets(sets)
+	return nil
+}
+
+type forgetCmd struct {
+	Positional snapshotPositional `positional-args:"yes"`
+}
+
+func (x *forgetCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	setID, err := x.Positional.setID()
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	changeID, err := cli.ForgetSnapshots(setID, x.Positional.Snaps)
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	if len(x.Positional.Snaps) > 0 {
+		// TRANSLATORS: the first %s is a comma-separated list of quoted snap names
+		fmt.Fprintf(Stdout, i18n.G("Snapshots of snaps %s in set #%d forgotten.\n"), quotedSnapNames(x.Positional.Snaps), setID)
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Snapshot set #%d forgotten.\n"), setID)
+	}
+	return nil
+}
+
+type checkSnapshotCmd struct {
+	Users      string             `long:"users"`
+	Positional snapshotPositional `positional-args:"yes"`
+}
+
+func (x *checkSnapshotCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	setID, err := x.Positional.setID()
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	changeID, err := cli.CheckSnapshots(setID, x.Positional.Snaps, splitUsers(x.Users))
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Snapshot set #%d verified successfully.\n"), setID)
+	return nil
+}
+
+type restoreCmd struct {
+	Users      string             `long:"users"`
+	Positional snapshotPositional `positional-args:"yes"`
+}
+
+func (x *restoreCmd) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	setID, err := x.Positional.setID()
+	if err != nil {
+		return err
+	}
+
+	cli := Client()
+	changeID, err := cli.RestoreSnapshots(setID, x.Positional.Snaps, splitUsers(x.Users))
+	if err != nil {
+		return err
+	}
+	if _, err := wait(cli, changeID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Restored snapshot set #%d.\n"), setID)
+	return nil
+}
+
+func init() {
+	usersDesc := i18n.G("Only consider the data of the given comma-separated users")
+
+	addCommand("saved", shortSavedHelp, longSavedHelp, func() flags.Commander { return &savedCmd{} },
+		map[string]string{"id": i18n.G("Show only the given snapshot set")}, nil)
+	addCommand("save", shortSaveHelp, longSaveHelp, func() flags.Commander { return &saveCmd{} },
+		map[string]string{"users": usersDesc}, nil)
+	addCommand("forget", shortForgetHelp, longForgetHelp, func() flags.Commander { return &forgetCmd{} }, nil, nil)
+	addCommand("check-snapshot", shortCheckHelp, longCheckHelp, func() flags.Commander { return &checkSnapshotCmd{} },
+		map[string]string{"users": usersDesc}, nil)
+	addCommand("restore", shortRestoreHelp, longRestoreHelp, func() flags.Commander { return &restoreCmd{} },
+		map[string]string{"users": usersDesc}, nil)
+}
@@ -0,0 +1,184 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+type snapshotSuite struct {
+	BaseSnapSuite
+
+	restoreAll func()
+}
+
+var _ = check.Suite(&snapshotSuite{})
+
+const snapshotSetsJSON = `{"type": "sync", "status-code": 200, "result": [{"id": 1, "snapshots": [
+  {"set": 1, "snap": "bar", "revision": "3", "version": "2.0", "time": "2017-04-01T09:30:00Z", "size": 1300000, "sha3-384": {"archive.tgz": "abc"}, "auto": true},
+  {"set": 1, "snap": "foo", "revision": "7", "version": "1.0", "time": "2017-04-01T10:29:30Z", "size": 42, "sha3-384": {"archive.tgz": "abc"}}
+]}, {"id": 2, "snapshots": [{"set": 2, "snap": "baz", "broken": "oops"}]}]}`
+
+func (s *snapshotSuite) SetUpTest(c *check.C) {
+	s.BaseSnapSuite.SetUpTest(c)
+
+	restoreClientRetry := client.MockDoRetry(time.Millisecond, 10*time.Millisecond)
+	restorePollTime := snap.MockPollTime(time.Millisecond)
+	restoreTimeNow := snap.MockTimeNow(func() time.Time {
+		return time.Date(2017, 4, 1, 10, 30, 0, 0, time.UTC)
+	})
+	s.restoreAll = func() {
+		restoreClientRetry()
+		restorePollTime()
+		restoreTimeNow()
+	}
+}
+
+func (s *snapshotSuite) TearDownTest(c *check.C) {
+	s.restoreAll()
+	s.BaseSnapSuite.TearDownTest(c)
+}
+
+func (s *snapshotSuite) TestSaved(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+		c.Check(r.URL.Query().Get("set"), check.Equals, "")
+		c.Check(r.URL.Query().Get("snaps"), check.Equals, "foo,bar")
+		fmt.Fprintln(w, snapshotSetsJSON)
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"saved", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `Set  Snap  Age  Version  Rev  Size   Notes
+1    bar   1h   2.0      3    1.3MB  auto
+1    foo   30s  1.0      7    42B    -
+2    baz   -    -        -    0B     broken: oops
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *snapshotSuite) TestSavedNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("set"), check.Equals, "3")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": []}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"saved", "--id=3"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No snapshots found.\n")
+}
+
+func (s *snapshotSuite) TestSave(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action": "snapshot",
+				"snaps":  []interface{}{"foo", "bar"},
+				"users":  []interface{}{"me", "you"},
+			})
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "result": {"set-id": 1}, "change": "42"}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+			c.Check(r.URL.Query().Get("set"), check.Equals, "1")
+			fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": [{"id": 1, "snapshots": [{"set": 1, "snap": "foo", "revision": "7", "version": "1.0", "time": "2017-04-01T10:29:30Z", "size": 42}]}]}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"save", "--users=me,you", "foo", "bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, `Set  Snap  Age  Version  Rev  Size  Notes
+1    foo   30s  1.0      7    42B   -
+`)
+	c.Check(n, check.Equals, 3)
+}
+
+func (s *snapshotSuite) testSnapshotOp(c *check.C, args []string, expectedBody map[string]interface{}, summary string) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snapshots")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, expectedBody)
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprintln(w, `{"type": "async", "status-code": 202, "change": "42"}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	s.stdout.Reset()
+	rest, err := snap.Parser().ParseArgs(args)
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, summary)
+	c.Check(n, check.Equals, 2)
+}
+
+func (s *snapshotSuite) TestSnapshotOps(c *check.C) {
+	s.testSnapshotOp(c, []string{"check-snapshot", "--users=me", "4", "foo"}, map[string]interface{}{
+		"set": 4.0, "action": "check", "snaps": []interface{}{"foo"}, "users": []interface{}{"me"},
+	}, "Snapshot set #4 verified successfully.\n")
+	s.testSnapshotOp(c, []string{"restore", "4"}, map[string]interface{}{
+		"set": 4.0, "action": "restore",
+	}, "Restored snapshot set #4.\n")
+	s.testSnapshotOp(c, []string{"forget", "4"}, map[string]interface{}{
+		"set": 4.0, "action": "forget",
+	}, "Snapshot set #4 forgotten.\n")
+	s.testSnapshotOp(c, []string{"forget", "4", "foo", "bar"}, map[string]interface{}{
+		"set": 4.0, "action": "forget", "snaps": []interface{}{"foo", "bar"},
+	}, "Snapshots of snaps \"foo\", \"bar\" in set #4 forgotten.\n")
+}
+
+func (s *snapshotSuite) TestSnapshotOpsBadID(c *check.C) {
+	s.RedirectClientToTestServer(nil)
+	for _, cmd := range []string{"check-snapshot", "restore", "forget"} {
+		_, err := snap.Parser().ParseArgs([]string{cmd})
+		c.Check(err, check.ErrorMatches, "missing snapshot set ID")
+		_, err = snap.Parser().ParseArgs([]string{cmd, "foo"})
+		c.Check(err, check.ErrorMatches, `invalid snapshot set ID "foo"`)
+	}
+}
@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+var (
+	shortValidateHelp = i18n.G("List or apply validation sets")
+	longValidateHelp  = i18n.G(`
+The validate command lists the validation sets tracked by the system and
+whether the installed snaps comply with them.
+
+With --monitor or --enforce it starts tracking the given validation set,
+optionally pinned at the given sequence, in the given mode: monitored
+sets are only reported on, while enforced sets make the system refuse to
+install, refresh or remove snaps in ways that would break them. With
+--forget it stops tracking the given validation set.
+
+Validation sets are given as <account-id>/<name>[=<sequence>].
+`)
+)
+
+type cmdValidate struct {
+	Monitor    bool `long:"monitor"`
+	Enforce    bool `long:"enforce"`
+	Forget     bool `long:"forget"`
+	Positional struct {
+		ValidationSet string `positional-arg-name:"<validation-set>"`
+	} `positional-args:"yes"`
+}
+
+func init() {
+	addCommand("validate", shortValidateHelp, longValidateHelp, func() flags.Commander { return &cmdValidate{} }, map[string]string{
+		"monitor": i18n.G("Monitor the given validation set"),
+		"enforce": i18n.G("Enforce the given validation set"),
+		"forget":  i18n.G("Stop tracking the given validation set"),
+	}, []argDesc{{
+		name: i18n.G("<validation-set>"),
+		desc: i18n.G("Validation set, as <account-id>/<name>[=<sequence>]"),
+	}})
+}
+
+// splitValidationSet splits <account-id>/<name>[=<sequence>] into its
+// parts, sequence being 0 if not given.
+func splitValidationSet(arg string) (accountID, name string, sequence int, err error) {
+	seq := ""
+	if i := strings.IndexByte(arg, '='); i >= 0 {
+		arg, seq = arg[:i], arg[i+1:]
+	}
+	parts := strings.Split(arg, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", 0, fmt.Errorf(i18n.G("cannot parse validation set %q: expected <account-id>/<name>[=<sequence>]"), arg)
+	}
+	if seq != "" {
+		sequence, err = strconv.Atoi(seq)
+		if err != nil || sequence <= 0 {
+			return "", "", 0, fmt.Errorf(i18n.G("cannot parse validation set sequence %q: expected a positive number"), seq)
+		}
+	}
+	return parts[0], parts[1], sequence, nil
+}
+
+func validationSetStatus(set *client.ValidationSetResult) string {
+	if set.Valid {
+		return i18n.G("valid")
+	}
+	return i18n.G("invalid")
+}
+
+func (x *cmdValidate) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	var mode string
+	nmodes := 0
+	if x.Monitor {
+		mode = "monitor"
+		nmodes++
+	}
+	if x.Enforce {
+		mode = "enforce"
+		nmodes++
+	}
+	if x.Forget {
+		nmodes++
+	}
+	if nmodes > 1 {
+		return errors.New(i18n.G("cannot use --monitor, --enforce and --forget together"))
+	}
+
+	cli := Client()
+
+	if x.Positional.ValidationSet == "" {
+		if nmodes != 0 {
+			return errors.New(i18n.G("a validation set must be given"))
+		}
+		sets, err := cli.ValidationSets()
+		if err != nil {
+			return err
+		}
+		if len(sets) == 0 {
+			fmt.Fprintln(Stderr, i18n.G("No validation sets are being tracked."))
+			return nil
+		}
+		w := tabWriter()
+		defer w.Flush()
+		fmt.Fprintln(w, i18n.G("Validation\tMode\tSeq\tCurrent"))
+		for _, set := range sets {
+			fmt.Fprintf(w, "%s/%s\t%s\t%d\t%s\n", set.AccountID, set.Name, set.Mode, set.Sequence, validationSetStatus(set))
+		}
+		return nil
+	}
+
+	accountID, name, sequence, err := splitValidationSet(x.Positional.ValidationSet)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case x.Forget:
+		if sequence != 0 {
+			return errors.New(i18n.G("cannot specify a sequence when forgetting a validation set"))
+		}
+		if err := cli.ForgetValidationSet(accountID, name); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, i18n.G("Stopped tracking validation set %s/%s.\n"), accountID, name)
+	case mode != "":
+		set, err := cli.ApplyValidationSet(accountID, name, &client.ValidationSetApplyOptions{
+			Mode:     mode,
+			Sequence: sequence,
+		})
+		if err != nil {
+			return err
+		}
+		// TRANSLATORS: %s/%s=%d is the validation set, then come its mode and whether it is valid
+		fmt.Fprintf(Stdout, i18n.G("Validation set %s/%s=%d is tracked in %s mode and is %s.\n"), accountID, name, set.Sequence, set.Mode, validationSetStatus(set))
+	default:
+		if sequence != 0 {
+			return errors.New(i18n.G("cannot specify a sequence without --monitor or --enforce"))
+		}
+		set, err := cli.ValidationSet(accountID, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(Stdout, validationSetStatus(set))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestValidateList(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets")
+		fmt.Fprintln(w, `{"type": "sync", "result": [
+  {"account-id": "acc", "name": "one", "mode": "enforce", "pinned-at": 2, "sequence": 2, "valid": true},
+  {"account-id": "acc", "name": "two", "mode": "monitor", "sequence": 5}
+]}`)
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"validate"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stdout(), check.Equals, ""+
+		"Validation  Mode     Seq  Current\n"+
+		"acc/one     enforce  2    valid\n"+
+		"acc/two     monitor  5    invalid\n")
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(n, check.Equals, 1)
+}
+
+func (s *SnapSuite) TestValidateListNone(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"validate"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "No validation sets are being tracked.\n")
+}
+
+func (s *SnapSuite) TestValidateOne(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/acc/one")
+		fmt.Fprintln(w, `{"type": "sync", "result": {"account-id": "acc", "name": "one", "mode": "monitor", "sequence": 3}}`)
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"validate", "acc/one"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "invalid\n")
+}
+
+func (s *SnapSuite) TestValidateApply(c *check.C) {
+	for _, t := range []struct {
+		args []string
+		body map[string]interface{}
+		out  string
+	}{
+		{[]string{"validate", "--monitor", "acc/one"},
+			map[string]interface{}{"action": "apply", "mode": "monitor"},
+			"Validation set acc/one=3 is tracked in monitor mode and is valid.\n"},
+		{[]string{"validate", "--enforce", "acc/one=3"},
+			map[string]interface{}{"action": "apply", "mode": "enforce", "sequence": float64(3)},
+			"Validation set acc/one=3 is tracked in enforce mode and is valid.\n"},
+		{[]string{"validate", "--forget", "acc/one"},
+			map[string]interface{}{"action": "forget"},
+			"Stopped tracking validation set acc/one.\n"},
+	} {
+		s.stdout.Reset()
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/acc/one")
+			body := DecodedRequestBody(c, r)
+			c.Check(body, check.DeepEquals, t.body)
+			if body["action"] == "forget" {
+				fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+				return
+			}
+			fmt.Fprintf(w, `{"type": "sync", "result": {"account-id": "acc", "name": "one", "mode": %q, "sequence": 3, "valid": true}}`, body["mode"])
+		})
+
+		_, err := snap.Parser().ParseArgs(t.args)
+		c.Assert(err, check.IsNil, check.Commentf("%v", t.args))
+		c.Check(s.Stdout(), check.Equals, t.out, check.Commentf("%v", t.args))
+	}
+}
+
+func (s *SnapSuite) TestValidateErrors(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request: %v", r)
+	})
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"validate", "--monitor", "--enforce", "acc/one"}, `cannot use --monitor, --enforce and --forget together`},
+		{[]string{"validate", "--monitor"}, `a validation set must be given`},
+		{[]string{"validate", "--monitor", "acc"}, `cannot parse validation set "acc": expected <account-id>/<name>\[=<sequence>\]`},
+		{[]string{"validate", "--monitor", "acc/one=x"}, `cannot parse validation set sequence "x": expected a positive number`},
+		{[]string{"validate", "--forget", "acc/one=1"}, `cannot specify a sequence when forgetting a validation set`},
+		{[]string{"validate", "acc/one=1"}, `cannot specify a sequence without --monitor or --enforce`},
+	} {
+		_, err := snap.Parser().ParseArgs(t.args)
+		c.Check(err, check.ErrorMatches, t.err, check.Commentf("%v", t.args))
+	}
+}
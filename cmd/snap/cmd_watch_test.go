@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestWatchFollowsEvents(c *check.C) {
+	restore := snap.MockFollowEvents(true)
+	defer restore()
+	// the events are enough to know when to look again
+	restore = snap.MockPollTime(time.Hour)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/events")
+			c.Check(r.URL.Query().Get("change"), check.Equals, "42")
+			w.Header().Set("Content-Type", "application/json-seq")
+			fmt.Fprintln(w, "\x1e"+`{"type": "task-progress", "metadata": {"change-id": "42", "task-id": "1", "status": "Doing", "progress": {"label": "foo", "done": 1, "total": 2}}}`)
+			fmt.Fprintln(w, "\x1e"+`{"type": "change-status", "metadata": {"change-id": "42", "status": "Done", "ready": true}}`)
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "status": "Doing"}}`)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser().ParseArgs([]string{"watch", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 3)
+}
+
+func (s *SnapSuite) TestWatchPollsWithoutEvents(c *check.C) {
+	restore := snap.MockFollowEvents(true)
+	defer restore()
+	restore = snap.MockPollTime(time.Millisecond)
+	defer restore()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "GET")
+		switch n {
+		case 0:
+			// no events from this server
+			c.Check(r.URL.Path, check.Equals, "/v2/events")
+			w.WriteHeader(404)
+			fmt.Fprintln(w, `{"type": "error", "status-code": 404, "result": {"message": "not found"}}`)
+		case 1, 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "status": "Doing"}}`)
+		case 3:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "ready": true, "status": "Error", "err": "boom"}}`)
+		default:
+			c.Fatalf("expected to get 4 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"watch", "42"})
+	c.Assert(err, check.ErrorMatches, "boom")
+	c.Check(n, check.Equals, 4)
+}
+
+func (s *SnapSuite) TestWatchChecksChangeWhenEventsAreSilent(c *check.C) {
+	restore := snap.MockFollowEvents(true)
+	defer restore()
+	restore = snap.MockPollTime(time.Hour)
+	defer restore()
+	restore = snap.MockMaxEventsSilence(time.Millisecond)
+	defer restore()
+
+	n := 0
+	done := make(chan struct{})
+	defer close(done)
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/events")
+			w.Header().Set("Content-Type", "application/json-seq")
+			w.(http.Flusher).Flush()
+			n++
+			// keep the stream open without events
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+			return
+		case 1:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "status": "Doing"}}`)
+		case 2:
+			c.Check(r.URL.Path, check.Equals, "/v2/changes/42")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"id": "42", "ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("expected to get 3 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	_, err := snap.Parser().ParseArgs([]string{"watch", "42"})
+	c.Assert(err, check.IsNil)
+	c.Check(n, check.Equals, 3)
+}
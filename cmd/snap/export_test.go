@@ -34,6 +34,7 @@ var (
 	SnapRunApp         = snapRunApp
 	SnapRunHook        = snapRunHook
 	Wait               = wait
+	ResolveApp         = resolveApp
 )
 
 func MockPollTime(d time.Duration) (restore func()) {
@@ -52,6 +53,22 @@ func MockMaxGoneTime(d time.Duration) (restore func()) {
 	}
 }
 
+func MockMaxEventsSilence(d time.Duration) (restore func()) {
+	d0 := maxEventsSilence
+	maxEventsSilence = d
+	return func() {
+		maxEventsSilence = d0
+	}
+}
+
+func MockFollowEvents(follow bool) (restore func()) {
+	old := followEvents
+	followEvents = follow
+	return func() {
+		followEvents = old
+	}
+}
+
 func MockSyscallExec(f func(string, []string, []string) error) (restore func()) {
 	syscallExecOrig := syscallExec
 	syscallExec = f
@@ -85,3 +102,11 @@ func MockMountInfoPath(newMountInfoPath string) (restore func()) {
 }
 
 var AutoImportCandidates = autoImportCandidates
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
@@ -230,6 +230,7 @@ func main() {
 	// magic \o/
 	snapApp := filepath.Base(os.Args[0])
 	if osutil.IsSymlink(filepath.Join(dirs.SnapBinariesDir, snapApp)) {
+		snapApp = resolveApp(snapApp)
 		cmd := &cmdRun{}
 		args := []string{snapApp}
 		args = append(args, os.Args[1:]...)
@@ -257,6 +258,16 @@ func main() {
 	}
 }
 
+// resolveApp resolves an alias to the app wrapper it points to; aliases
+// are symlinks in the binaries directory to the bare name of a wrapper.
+func resolveApp(snapApp string) string {
+	target, err := os.Readlink(filepath.Join(dirs.SnapBinariesDir, snapApp))
+	if err != nil || strings.ContainsRune(target, '/') {
+		return snapApp
+	}
+	return target
+}
+
 type exitStatus struct {
 	code int
 }
@@ -71,6 +71,8 @@ func (s *BaseSnapSuite) SetUpTest(c *C) {
 	snap.Terminal = int(s.term.Fd())
 	s.AuthFile = filepath.Join(c.MkDir(), "json")
 	os.Setenv(TestAuthFileEnvKey, s.AuthFile)
+	// most tests serve the changes they wait on for polling
+	s.AddCleanup(snap.MockFollowEvents(false))
 }
 
 func (s *BaseSnapSuite) TearDownTest(c *C) {
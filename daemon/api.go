@@ -48,17 +48,21 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auditstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var api = []*Command{
@@ -82,6 +86,12 @@ var api = []*Command{
 	readyToBuyCmd,
 	snapctlCmd,
 	usersCmd,
+	appsCmd,
+	logsCmd,
+	snapshotCmd,
+	aliasesCmd,
+	validationSetsCmd,
+	validationSetCmd,
 }
 
 var (
@@ -160,8 +170,9 @@ var (
 	}
 
 	eventsCmd = &Command{
-		Path: "/v2/events",
-		GET:  getEvents,
+		Path:   "/v2/events",
+		UserOK: true,
+		GET:    getEvents,
 	}
 
 	stateChangeCmd = &Command{
@@ -206,6 +217,45 @@ var (
 		UserOK: false,
 		GET:    getUsers,
 	}
+
+	appsCmd = &Command{
+		Path:   "/v2/apps",
+		UserOK: true,
+		GET:    getAppsInfo,
+		POST:   postApps,
+	}
+
+	logsCmd = &Command{
+		Path: "/v2/logs",
+		GET:  getLogs,
+	}
+
+	snapshotCmd = &Command{
+		Path:   "/v2/snapshots",
+		UserOK: true,
+		GET:    listSnapshots,
+		POST:   changeSnapshots,
+	}
+
+	aliasesCmd = &Command{
+		Path:   "/v2/aliases",
+		UserOK: true,
+		GET:    getAliases,
+		POST:   changeAliases,
+	}
+
+	validationSetsCmd = &Command{
+		Path:   "/v2/validation-sets",
+		UserOK: true,
+		GET:    listValidationSets,
+	}
+
+	validationSetCmd = &Command{
+		Path:   "/v2/validation-sets/{account}/{name}",
+		UserOK: true,
+		GET:    getValidationSet,
+		POST:   changeValidationSet,
+	}
 )
 
 func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -215,18 +265,24 @@ func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
 func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	st := c.d.overlord.State()
 	st.Lock()
+	defer st.Unlock()
 	users, err := auth.Users(st)
-	st.Unlock()
 	if err != nil && err != state.ErrNoState {
 		return InternalError("cannot get user auth data: %s", err)
 	}
 
+	refreshInfo, err := refreshInfo(st)
+	if err != nil {
+		return InternalError("cannot get refresh information: %s", err)
+	}
+
 	m := map[string]interface{}{
 		"series":     release.Series,
 		"version":    c.d.Version,
 		"os-release": release.ReleaseInfo,
 		"on-classic": release.OnClassic,
 		"managed":    len(users) > 0,
+		"refresh":    refreshInfo,
 	}
 
 	// TODO: set the store-id here from the model information
@@ -237,6 +293,35 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(m, nil)
 }
 
+// refreshInfo returns the auto-refresh schedule together with the times
+// of the last and next auto-refresh, as far as they are known.
+// Note that the state must be locked by the caller.
+func refreshInfo(st *state.State) (map[string]interface{}, error) {
+	schedule, err := snapstate.RefreshSchedule(st)
+	if err != nil {
+		return nil, err
+	}
+	last, err := snapstate.LastRefresh(st)
+	if err != nil {
+		return nil, err
+	}
+	next, err := snapstate.NextRefresh(st)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{
+		"schedule": schedule,
+	}
+	if !last.IsZero() {
+		info["last"] = last.Format(time.RFC3339)
+	}
+	if !next.IsZero() {
+		info["next"] = next.Format(time.RFC3339)
+	}
+	return info, nil
+}
+
 // userResponseData contains the data releated to user creation/login/query
 type userResponseData struct {
 	ID       int      `json:"id,omitempty"`
@@ -407,7 +492,7 @@ func getSnapInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	localSnap, active, err := localSnapInfo(c.d.overlord.State(), name)
 	if err != nil {
 		if err == errNoSnap {
-			return NotFound("cannot find %q snap", name)
+			return SnapNotFound("cannot find %q snap", name)
 		}
 
 		return InternalError("%v", err)
@@ -528,6 +613,9 @@ func findOne(c *Command, r *http.Request, user *auth.UserState, name string) Res
 
 	theStore := getStore(c)
 	snapInfo, err := theStore.Snap(name, "", false, snap.R(0), user)
+	if err == store.ErrSnapNotFound {
+		return SnapNotFound("%v", err)
+	}
 	if err != nil {
 		return InternalError("%v", err)
 	}
@@ -682,6 +770,14 @@ type snapInstruction struct {
 	LeaveOld bool         `json:"temp-dropped-leave-old"`
 	License  *licenseData `json:"license"`
 	Snaps    []string     `json:"snaps"`
+	Users    []string     `json:"users"`
+	// At schedules the change for later. The snaps of the change
+	// are considered as having changes in progress until it has
+	// run, other operations on them fail with a change-conflict
+	// error in the meantime.
+	At time.Time `json:"at"`
+	// WaitForChange is the ID of a change the new change waits for.
+	WaitForChange string `json:"wait-for-change"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -699,6 +795,8 @@ var (
 	snapstateRemoveMany        = snapstate.RemoveMany
 
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
+
+	auditstateEntries = auditstate.Entries
 )
 
 func ensureStateSoonImpl(st *state.State) {
@@ -712,7 +810,7 @@ var errNothingToInstall = errors.New("nothing to install")
 const oldDefaultSnapCoreName = "ubuntu-core"
 const defaultCoreSnapName = "core"
 
-func ensureUbuntuCore(st *state.State, targetSnap string, userID int) (*state.TaskSet, error) {
+func ensureUbuntuCore(st *state.State, targetSnap string, userID int, ignoreChangeID string) (*state.TaskSet, error) {
 	if targetSnap == defaultCoreSnapName || targetSnap == oldDefaultSnapCoreName {
 		return nil, errNothingToInstall
 	}
@@ -722,11 +820,11 @@ func ensureUbuntuCore(st *state.State, targetSnap string, userID int) (*state.Ta
 		return nil, err
 	}
 
-	return snapstateInstall(st, defaultCoreSnapName, "stable", snap.R(0), userID, snapstate.Flags{})
+	return snapstateInstall(st, defaultCoreSnapName, "stable", snap.R(0), userID, snapstate.Flags{IgnoreConflictsWith: ignoreChangeID})
 }
 
-func withEnsureUbuntuCore(st *state.State, targetSnap string, userID int, install func() (*state.TaskSet, error)) ([]*state.TaskSet, error) {
-	ubuCoreTs, err := ensureUbuntuCore(st, targetSnap, userID)
+func withEnsureUbuntuCore(st *state.State, targetSnap string, userID int, ignoreChangeID string, install func() (*state.TaskSet, error)) ([]*state.TaskSet, error) {
+	ubuCoreTs, err := ensureUbuntuCore(st, targetSnap, userID, ignoreChangeID)
 	if err != nil && err != errNothingToInstall {
 		return nil, err
 	}
@@ -784,7 +882,8 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (msg string, updated
 		return "", nil, nil, err
 	}
 
-	updated, tasksets, err = snapstateUpdateMany(st, inst.Snaps, inst.userID)
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	updated, tasksets, err = snapstateUpdateMany(st, inst.Snaps, inst.userID, flags)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -805,7 +904,8 @@ func snapUpdateMany(inst *snapInstruction, st *state.State) (msg string, updated
 }
 
 func snapInstallMany(inst *snapInstruction, st *state.State) (msg string, installed []string, tasksets []*state.TaskSet, err error) {
-	installed, tasksets, err = snapstateInstallMany(st, inst.Snaps, inst.userID)
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	installed, tasksets, err = snapstateInstallMany(st, inst.Snaps, inst.userID, flags)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -829,10 +929,11 @@ func snapInstall(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	if err != nil {
 		return "", nil, err
 	}
+	flags.IgnoreConflictsWith = inst.WaitForChange
 
 	logger.Noticef("Installing snap %q revision %s", inst.Snaps[0], inst.Revision)
 
-	tsets, err := withEnsureUbuntuCore(st, inst.Snaps[0], inst.userID,
+	tsets, err := withEnsureUbuntuCore(st, inst.Snaps[0], inst.userID, inst.WaitForChange,
 		func() (*state.TaskSet, error) {
 			return snapstateInstall(st, inst.Snaps[0], inst.Channel, inst.Revision, inst.userID, flags)
 		},
@@ -854,6 +955,7 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	if err != nil {
 		return "", nil, err
 	}
+	flags.IgnoreConflictsWith = inst.WaitForChange
 	if inst.IgnoreValidation {
 		flags.IgnoreValidation = true
 	}
@@ -877,7 +979,8 @@ func snapUpdate(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 }
 
 func snapRemoveMany(inst *snapInstruction, st *state.State) (msg string, removed []string, tasksets []*state.TaskSet, err error) {
-	removed, tasksets, err = snapstateRemoveMany(st, inst.Snaps)
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	removed, tasksets, err = snapstateRemoveMany(st, inst.Snaps, flags)
 	if err != nil {
 		return "", nil, nil, err
 	}
@@ -897,7 +1000,8 @@ func snapRemoveMany(inst *snapInstruction, st *state.State) (msg string, removed
 }
 
 func snapRemove(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
-	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision)
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	ts, err := snapstate.Remove(st, inst.Snaps[0], inst.Revision, flags)
 	if err != nil {
 		return "", nil, err
 	}
@@ -913,6 +1017,7 @@ func snapRevert(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	if err != nil {
 		return "", nil, err
 	}
+	flags.IgnoreConflictsWith = inst.WaitForChange
 
 	if inst.Revision.Unset() {
 		ts, err = snapstate.Revert(st, inst.Snaps[0], flags)
@@ -931,7 +1036,8 @@ func snapEnable(inst *snapInstruction, st *state.State) (string, []*state.TaskSe
 	if !inst.Revision.Unset() {
 		return "", nil, errors.New("enable takes no revision")
 	}
-	ts, err := snapstate.Enable(st, inst.Snaps[0])
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	ts, err := snapstate.Enable(st, inst.Snaps[0], flags)
 	if err != nil {
 		return "", nil, err
 	}
@@ -944,7 +1050,8 @@ func snapDisable(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	if !inst.Revision.Unset() {
 		return "", nil, errors.New("disable takes no revision")
 	}
-	ts, err := snapstate.Disable(st, inst.Snaps[0])
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	ts, err := snapstate.Disable(st, inst.Snaps[0], flags)
 	if err != nil {
 		return "", nil, err
 	}
@@ -953,6 +1060,23 @@ func snapDisable(inst *snapInstruction, st *state.State) (string, []*state.TaskS
 	return msg, []*state.TaskSet{ts}, nil
 }
 
+func snapSwitch(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+	if !inst.Revision.Unset() {
+		return "", nil, errors.New("switch takes no revision")
+	}
+	if inst.Channel == "" {
+		return "", nil, errors.New("switch needs a channel")
+	}
+	flags := snapstate.Flags{IgnoreConflictsWith: inst.WaitForChange}
+	ts, err := snapstate.Switch(st, inst.Snaps[0], inst.Channel, flags)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msg := fmt.Sprintf(i18n.G("Switch %q snap to %s"), inst.Snaps[0], inst.Channel)
+	return msg, []*state.TaskSet{ts}, nil
+}
+
 type snapActionFunc func(*snapInstruction, *state.State) (string, []*state.TaskSet, error)
 
 var snapInstructionDispTable = map[string]snapActionFunc{
@@ -962,6 +1086,7 @@ var snapInstructionDispTable = map[string]snapActionFunc{
 	"revert":  snapRevert,
 	"enable":  snapEnable,
 	"disable": snapDisable,
+	"switch":  snapSwitch,
 }
 
 func (inst *snapInstruction) dispatch() snapActionFunc {
@@ -998,21 +1123,91 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 	if impl == nil {
 		return BadRequest("unknown action %s", inst.Action)
 	}
+	if err := inst.validateAt(); err != nil {
+		return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps[0], err)
+	}
+	waitChg, err := changeToWaitFor(state, inst.WaitForChange)
+	if err != nil {
+		return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps[0], err)
+	}
 
 	msg, tsets, err := impl(&inst, state)
 	if err != nil {
-		return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps[0], err)
+		return changeErrorResponse(BadRequest, err, "cannot %s %q: %v", inst.Action, inst.Snaps[0], err)
 	}
 
-	chg := newChange(state, inst.Action+"-snap", msg, tsets, inst.Snaps)
+	chg := newChange(state, r, inst.Action+"-snap", msg, tsets, inst.Snaps)
+	if !inst.At.IsZero() {
+		chg.At(inst.At)
+	}
+	if waitChg != nil {
+		chg.WaitFor(waitChg)
+	}
 
 	ensureStateSoon(state)
 
 	return AsyncResponse(nil, &Meta{Change: chg.ID()})
 }
 
-func newChange(st *state.State, kind, summary string, tsets []*state.TaskSet, snapNames []string) *state.Change {
+// validateAt checks that the time the instruction schedules its change
+// at, if any, is not in the past.
+func (inst *snapInstruction) validateAt() error {
+	if !inst.At.IsZero() && inst.At.Before(time.Now()) {
+		return fmt.Errorf("cannot schedule a change in the past (%s)", inst.At.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// changeToWaitFor returns the change with the given ID, that a new
+// change is asked to wait for, or nil if the ID is empty.
+func changeToWaitFor(st *state.State, id string) (*state.Change, error) {
+	if id == "" {
+		return nil, nil
+	}
+	chg := st.Change(id)
+	if chg == nil {
+		return nil, fmt.Errorf("cannot find change %q to wait for", id)
+	}
+	return chg, nil
+}
+
+// changeErrorResponse returns the error response for building a change
+// that failed with err. Conflicts with a change in progress get a
+// Conflict response with the blocking change in its value, other
+// errors use the given responder.
+func changeErrorResponse(responder errorResponder, err error, format string, v ...interface{}) Response {
+	conflictErr, ok := err.(*state.ChangeConflictError)
+	if !ok {
+		return responder(format, v...)
+	}
+	return &resp{
+		Type: ResponseTypeError,
+		Result: &errorResult{
+			Message: fmt.Sprintf(format, v...),
+			Kind:    errorKindChangeConflict,
+			Value: map[string]interface{}{
+				"change-kind": conflictErr.ChangeKind,
+				"change-id":   conflictErr.ChangeID,
+			},
+		},
+		Status: http.StatusConflict,
+	}
+}
+
+// newRequestChange is like st.NewChange but also records on the change,
+// for the audit log, the uid of the user that made the request r. The
+// state must be kept locked from creating the change to making it
+// ready, so that it is never audited without the uid.
+func newRequestChange(st *state.State, r *http.Request, kind, summary string) *state.Change {
 	chg := st.NewChange(kind, summary)
+	if uid, err := ucrednetGetUID(r.RemoteAddr); err == nil {
+		auditstate.SetUID(chg, uid)
+	}
+	return chg
+}
+
+func newChange(st *state.State, r *http.Request, kind, summary string, tsets []*state.TaskSet, snapNames []string) *state.Change {
+	chg := newRequestChange(st, r, kind, summary)
 	for _, ts := range tsets {
 		chg.AddAll(ts)
 	}
@@ -1048,7 +1243,7 @@ func trySnap(c *Command, r *http.Request, user *auth.UserState, trydir string, f
 	}
 
 	msg := fmt.Sprintf(i18n.G("Try %q snap from %s"), info.Name(), trydir)
-	chg := newChange(st, "try-snap", msg, []*state.TaskSet{tsets}, []string{info.Name()})
+	chg := newChange(st, r, "try-snap", msg, []*state.TaskSet{tsets}, []string{info.Name()})
 	chg.Set("api-data", map[string]string{"snap-name": info.Name()})
 
 	ensureStateSoon(st)
@@ -1093,10 +1288,23 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		inst.userID = user.ID
 	}
 
+	if inst.Action == "snapshot" {
+		if !inst.At.IsZero() {
+			return BadRequest("cannot schedule a snapshot")
+		}
+		return snapshotMany(r, &inst, st)
+	}
+	if err := inst.validateAt(); err != nil {
+		return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps, err)
+	}
+	waitChg, err := changeToWaitFor(st, inst.WaitForChange)
+	if err != nil {
+		return BadRequest("cannot %s %q: %v", inst.Action, inst.Snaps, err)
+	}
+
 	var msg string
 	var affected []string
 	var tsets []*state.TaskSet
-	var err error
 	switch inst.Action {
 	case "refresh":
 		msg, affected, tsets, err = snapUpdateMany(&inst, st)
@@ -1108,15 +1316,21 @@ func snapsOp(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("unsupported multi-snap operation %q", inst.Action)
 	}
 	if err != nil {
-		return InternalError("cannot %s %q: %v", inst.Action, inst.Snaps, err)
+		return changeErrorResponse(InternalError, err, "cannot %s %q: %v", inst.Action, inst.Snaps, err)
 	}
 
 	var chg *state.Change
 	if len(tsets) == 0 {
-		chg = st.NewChange(inst.Action+"-snap", msg)
+		chg = newRequestChange(st, r, inst.Action+"-snap", msg)
 		chg.SetStatus(state.DoneStatus)
 	} else {
-		chg = newChange(st, inst.Action+"-snap", msg, tsets, affected)
+		chg = newChange(st, r, inst.Action+"-snap", msg, tsets, affected)
+		if !inst.At.IsZero() {
+			chg.At(inst.At)
+		}
+		if waitChg != nil {
+			chg.WaitFor(waitChg)
+		}
 		ensureStateSoon(st)
 	}
 	chg.Set("api-data", map[string]interface{}{"snap-names": affected})
@@ -1265,7 +1479,7 @@ out:
 		userID = user.ID
 	}
 
-	tsets, err := withEnsureUbuntuCore(st, snapName, userID,
+	tsets, err := withEnsureUbuntuCore(st, snapName, userID, "",
 		func() (*state.TaskSet, error) {
 			return snapstateInstallPath(st, sideInfo, tempPath, "", flags)
 		},
@@ -1274,7 +1488,7 @@ out:
 		return InternalError("cannot install snap file: %v", err)
 	}
 
-	chg := newChange(st, "install-snap", msg, tsets, []string{snapName})
+	chg := newChange(st, r, "install-snap", msg, tsets, []string{snapName})
 	chg.Set("api-data", map[string]string{"snap-name": snapName})
 
 	ensureStateSoon(st)
@@ -1373,7 +1587,7 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	taskset := configstate.Configure(st, snapName, patchValues)
 
 	summary := fmt.Sprintf("Change configuration of %q snap", snapName)
-	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
+	change := newChange(st, r, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
 
 	st.EnsureBefore(0)
 
@@ -1413,6 +1627,8 @@ type interfaceAction struct {
 	Action string     `json:"action"`
 	Plugs  []plugJSON `json:"plugs,omitempty"`
 	Slots  []slotJSON `json:"slots,omitempty"`
+	// WaitForChange is the ID of a change the new change waits for.
+	WaitForChange string `json:"wait-for-change,omitempty"`
 }
 
 // changeInterfaces controls the interfaces system.
@@ -1443,12 +1659,16 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 
 	var summary string
 	var taskset *state.TaskSet
-	var err error
 
 	state := c.d.overlord.State()
 	state.Lock()
 	defer state.Unlock()
 
+	waitChg, err := changeToWaitFor(state, a.WaitForChange)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
 	switch a.Action {
 	case "connect":
 		summary = fmt.Sprintf("Connect %s:%s to %s:%s", a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap, a.Slots[0].Name)
@@ -1458,19 +1678,37 @@ func changeInterfaces(c *Command, r *http.Request, user *auth.UserState) Respons
 		taskset, err = ifacestate.Disconnect(state, a.Plugs[0].Snap, a.Plugs[0].Name, a.Slots[0].Snap, a.Slots[0].Name)
 	}
 	if err != nil {
-		return BadRequest("%v", err)
+		return changeErrorResponse(BadRequest, err, "%v", err)
 	}
 
-	change := state.NewChange(a.Action+"-snap", summary)
+	change := newRequestChange(state, r, a.Action+"-snap", summary)
 	change.Set("snap-names", []string{a.Plugs[0].Snap, a.Slots[0].Snap})
 	change.AddAll(taskset)
+	if waitChg != nil {
+		change.WaitFor(waitChg)
+	}
 
 	state.EnsureBefore(0)
 
 	return AsyncResponse(nil, &Meta{Change: change.ID()})
 }
 
+// assertionRef identifies an added assertion by type and primary key.
+type assertionRef struct {
+	Type       string   `json:"type"`
+	PrimaryKey []string `json:"primary-key"`
+}
+
 func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
+	bundle := false
+	if s := r.URL.Query().Get("bundle"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return BadRequest("invalid value for bundle: %q: %v", s, err)
+		}
+		bundle = b
+	}
+
 	batch := assertstate.NewBatch()
 	_, err := batch.AddStream(r.Body)
 	if err != nil {
@@ -1481,6 +1719,19 @@ func doAssert(c *Command, r *http.Request, user *auth.UserState) Response {
 	state.Lock()
 	defer state.Unlock()
 
+	if bundle {
+		// all or nothing, reporting what was effectively added
+		refs, err := batch.CommitAtomically(state)
+		if err != nil {
+			return BadRequest("assert failed: %v", err)
+		}
+		added := make([]assertionRef, len(refs))
+		for i, ref := range refs {
+			added[i] = assertionRef{Type: ref.Type.Name, PrimaryKey: ref.PrimaryKey}
+		}
+		return SyncResponse(map[string]interface{}{"added": added}, nil)
+	}
+
 	if err := batch.Commit(state); err != nil {
 		return BadRequest("assert failed: %v", err)
 	}
@@ -1529,9 +1780,11 @@ type changeInfo struct {
 	Tasks   []*taskInfo `json:"tasks,omitempty"`
 	Ready   bool        `json:"ready"`
 	Err     string      `json:"err,omitempty"`
+	ErrKind errorKind   `json:"err-kind,omitempty"`
 
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
+	AtTime    *time.Time `json:"at-time,omitempty"`
 
 	Data map[string]*json.RawMessage `json:"data,omitempty"`
 }
@@ -1544,6 +1797,9 @@ type taskInfo struct {
 	Log      []string         `json:"log,omitempty"`
 	Progress taskInfoProgress `json:"progress"`
 
+	Retries   int    `json:"retries,omitempty"`
+	LastError string `json:"last-error,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time,omitempty"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 }
@@ -1569,6 +1825,13 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	if !readyTime.IsZero() {
 		chgInfo.ReadyTime = &readyTime
 	}
+	if atTime := chg.AtTime(); !atTime.IsZero() {
+		chgInfo.AtTime = &atTime
+	}
+	if chg.IsScheduled() {
+		// waiting for its time rather than for its turn
+		chgInfo.Status = "Scheduled"
+	}
 	if err := chg.Err(); err != nil {
 		chgInfo.Err = err.Error()
 	}
@@ -1578,6 +1841,11 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	for j, t := range tasks {
 		label, done, total := t.Progress()
 
+		var kind string
+		if t.Status() == state.ErrorStatus && t.Get("error-kind", &kind) == nil && kind == hookstate.ErrorKindHookTimeout {
+			chgInfo.ErrKind = errorKindHookTimeout
+		}
+
 		taskInfo := &taskInfo{
 			ID:      t.ID(),
 			Kind:    t.Kind(),
@@ -1589,6 +1857,8 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 				Done:  done,
 				Total: total,
 			},
+			Retries:   t.Retries(),
+			LastError: t.LastError(),
 			SpawnTime: t.SpawnTime(),
 		}
 		readyTime := t.ReadyTime()
@@ -1638,7 +1908,8 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("select should be one of: all,in-progress,ready")
 	}
 
-	if wantedName := query.Get("for"); wantedName != "" {
+	wantedName := query.Get("for")
+	if wantedName != "" {
 		outerFilter := filter
 		filter = func(chg *state.Change) bool {
 			if !outerFilter(chg) {
@@ -1651,13 +1922,28 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 				return false
 			}
 
-			for _, snapName := range snapNames {
-				if snapName == wantedName {
-					return true
-				}
-			}
+			return listContains(snapNames, wantedName)
+		}
+	}
+
+	// with since, changes that were ready before then are left out,
+	// and those no longer in the state are looked up in the audit log
+	var since time.Time
+	withHistory := false
+	if s := query.Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return BadRequest("cannot parse since %q: expected RFC3339 format", s)
+		}
+		withHistory = qselect != "in-progress"
 
-			return false
+		outerFilter := filter
+		filter = func(chg *state.Change) bool {
+			if !outerFilter(chg) {
+				return false
+			}
+			return !chg.Status().Ready() || !chg.ReadyTime().Before(since)
 		}
 	}
 
@@ -1672,9 +1958,50 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 		}
 		chgInfos = append(chgInfos, change2changeInfo(chg))
 	}
+
+	if withHistory {
+		entries, err := auditstateEntries(since)
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		for _, entry := range entries {
+			if state.Change(entry.ID) != nil {
+				// still around, already listed
+				continue
+			}
+			if wantedName != "" && !listContains(entry.SnapNames, wantedName) {
+				continue
+			}
+			chgInfos = append(chgInfos, auditEntry2changeInfo(entry))
+		}
+	}
+
 	return SyncResponse(chgInfos, nil)
 }
 
+func listContains(list []string, str string) bool {
+	for _, el := range list {
+		if el == str {
+			return true
+		}
+	}
+	return false
+}
+
+func auditEntry2changeInfo(entry *auditstate.Entry) *changeInfo {
+	readyTime := entry.ReadyTime
+	return &changeInfo{
+		ID:        entry.ID,
+		Kind:      entry.Kind,
+		Summary:   entry.Summary,
+		Status:    entry.Status,
+		Ready:     true,
+		Err:       entry.Err,
+		SpawnTime: entry.SpawnTime,
+		ReadyTime: &readyTime,
+	}
+}
+
 func abortChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
@@ -2056,26 +2383,82 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 		return BadRequest("snapctl cannot run without args")
 	}
 
-	// Right now snapctl is only used for hooks. If at some point it grows
-	// beyond that, this probably shouldn't go straight to the HookManager.
-	context, _ := c.d.overlord.HookManager().Context(snapctlOptions.ContextID)
+	// Hooks have a context, apps get an ephemeral one for their snap.
+	var context *hookstate.Context
+	if snapctlOptions.ContextID != "" {
+		context, _ = c.d.overlord.HookManager().Context(snapctlOptions.ContextID)
+	} else if snapName, err := snapctlSnapName(r.RemoteAddr); err == nil {
+		context, err = hookstate.NewEphemeralContext(c.d.overlord.State(), snapName)
+		if err != nil {
+			return InternalError("cannot run snapctl: %v", err)
+		}
+	}
+
 	stdout, stderr, err := ctlcmd.Run(context, snapctlOptions.Args)
+	exitCode := 0
 	if err != nil {
 		if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 			stdout = []byte(e.Error())
+		} else if e, ok := err.(*ctlcmd.UnsuccessfulError); ok {
+			exitCode = e.ExitCode
 		} else {
 			return BadRequest("error running snapctl: %s", err)
 		}
 	}
 
-	result := map[string]string{
+	if err == nil && context != nil && context.IsEphemeral() {
+		// there is no hook to finish, so apply the changes right away
+		context.Lock()
+		err = context.Done()
+		context.Unlock()
+		if err != nil {
+			return InternalError("cannot run snapctl: %v", err)
+		}
+		ensureStateSoon(context.State())
+	}
+
+	result := map[string]interface{}{
 		"stdout": string(stdout),
 		"stderr": string(stderr),
 	}
+	if exitCode != 0 {
+		result["exit-code"] = exitCode
+	}
 
 	return SyncResponse(result, nil)
 }
 
+// snapctlSnapName returns the name of the snap the process that sent a
+// request belongs to, as told by its security label.
+func snapctlSnapName(remoteAddr string) (string, error) {
+	pid, err := ucrednetGetPID(remoteAddr)
+	if err != nil {
+		return "", err
+	}
+	label, err := procSecurityLabel(pid)
+	if err != nil {
+		return "", err
+	}
+	// snap.<name>.<app> or snap.<name>.hook.<hook>, with the mode
+	// after a space, e.g. "snap.foo.app (enforce)"
+	if i := strings.IndexByte(label, ' '); i >= 0 {
+		label = label[:i]
+	}
+	parts := strings.Split(label, ".")
+	if len(parts) < 3 || parts[0] != "snap" || parts[1] == "" {
+		return "", fmt.Errorf("process %d does not belong to a snap", pid)
+	}
+	return parts[1], nil
+}
+
+var procSecurityLabel = func(pid int32) (string, error) {
+	label, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/attr/current", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(label)), nil
+}
+
 func getUsers(c *Command, r *http.Request, user *auth.UserState) Response {
 	uid, err := postCreateUserUcrednetGetUID(r.RemoteAddr)
 	if err != nil {
@@ -2103,3 +2486,112 @@ func getUsers(c *Command, r *http.Request, user *auth.UserState) Response {
 	}
 	return SyncResponse(resp, nil)
 }
+
+func getAppsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+
+	opts := appInfoOptions{}
+	switch sel := query.Get("select"); sel {
+	case "":
+		// nothing to do
+	case "service":
+		opts.service = true
+	default:
+		return BadRequest("invalid select parameter: %q", sel)
+	}
+
+	appInfos, rsp := appInfosFor(c.d.overlord.State(), splitQS(query.Get("names")), opts)
+	if rsp != nil {
+		return rsp
+	}
+
+	apps, err := mapApps(appInfos)
+	if err != nil {
+		return InternalError("cannot get status of services: %v", err)
+	}
+
+	return SyncResponse(apps, nil)
+}
+
+func postApps(c *Command, r *http.Request, user *auth.UserState) Response {
+	var inst servicestate.Instruction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("cannot decode request body into service operation: %v", err)
+	}
+	if len(inst.Names) == 0 {
+		// on POST, don't allow empty to mean all
+		return BadRequest("cannot perform operation on services without a list of services to operate on")
+	}
+
+	st := c.d.overlord.State()
+	appInfos, rsp := appInfosFor(st, inst.Names, appInfoOptions{service: true})
+	if rsp != nil {
+		return rsp
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	ts, err := servicestate.Control(st, appInfos, &inst)
+	if err != nil {
+		return BadRequest("cannot %s services: %v", inst.Action, err)
+	}
+
+	var snapNames []string
+	seen := make(map[string]bool)
+	for _, app := range appInfos {
+		if name := app.Snap.Name(); !seen[name] {
+			seen[name] = true
+			snapNames = append(snapNames, name)
+		}
+	}
+
+	summary := fmt.Sprintf(i18n.G("Running service command %q for %s"), inst.Action, quotedNames(inst.Names))
+	chg := newChange(st, r, "service-control", summary, []*state.TaskSet{ts}, snapNames)
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: chg.ID()})
+}
+
+func getLogs(c *Command, r *http.Request, user *auth.UserState) Response {
+	query := r.URL.Query()
+
+	n := "10"
+	if s := query.Get("n"); s != "" {
+		if s != "all" {
+			m, err := strconv.Atoi(s)
+			if err != nil || m < 0 {
+				return BadRequest("invalid value for n: %q", s)
+			}
+		}
+		n = s
+	}
+
+	follow := false
+	if s := query.Get("follow"); s != "" {
+		f, err := strconv.ParseBool(s)
+		if err != nil {
+			return BadRequest("invalid value for follow: %q: %v", s, err)
+		}
+		follow = f
+	}
+
+	// only services have logs for now
+	appInfos, rsp := appInfosFor(c.d.overlord.State(), splitQS(query.Get("names")), appInfoOptions{service: true})
+	if rsp != nil {
+		return rsp
+	}
+	if len(appInfos) == 0 {
+		return NotFound("no matching services")
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &progress.NullProgress{})
+	reader, err := sysd.LogReader(serviceNames(appInfos), n, follow)
+	if err != nil {
+		return InternalError("cannot get logs: %v", err)
+	}
+
+	return &journalLineReaderSeqResponse{ReadCloser: reader}
+}
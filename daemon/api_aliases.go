@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// aliasStatus describes an alias of a snap.
+type aliasStatus struct {
+	App     string `json:"app"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+}
+
+// aliasCommand returns the command that runs the given app.
+func aliasCommand(snapName, app string) string {
+	if app == snapName {
+		return snapName
+	}
+	return snapName + "." + app
+}
+
+func getAliases(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return InternalError("cannot list local snaps: %v", err)
+	}
+
+	res := make(map[string]map[string]aliasStatus)
+	for snapName, snapst := range snapStates {
+		if len(snapst.Aliases) == 0 {
+			continue
+		}
+		aliases := make(map[string]aliasStatus, len(snapst.Aliases))
+		for alias, target := range snapst.Aliases {
+			status := "manual"
+			switch {
+			case target.Disabled:
+				status = "disabled"
+			case target.Auto:
+				status = "auto"
+			}
+			aliases[alias] = aliasStatus{
+				App:     target.App,
+				Command: aliasCommand(snapName, target.App),
+				Status:  status,
+			}
+		}
+		res[snapName] = aliases
+	}
+
+	return SyncResponse(res, nil)
+}
+
+// aliasAction is used to request enabling or disabling an alias.
+type aliasAction struct {
+	Action string `json:"action"`
+	Snap   string `json:"snap,omitempty"`
+	App    string `json:"app,omitempty"`
+	Alias  string `json:"alias"`
+}
+
+func changeAliases(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a aliasAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into an alias action: %v", err)
+	}
+	if a.Alias == "" {
+		return BadRequest("at least an alias name is required")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var summary string
+	var snapName string
+	var taskset *state.TaskSet
+	var err error
+
+	switch a.Action {
+	case "alias":
+		if a.Snap == "" || a.App == "" {
+			return BadRequest("snap and app are required to enable an alias")
+		}
+		snapName = a.Snap
+		summary = fmt.Sprintf("Enable alias %q for %s", a.Alias, aliasCommand(a.Snap, a.App))
+		taskset, err = snapstate.Alias(st, a.Snap, a.App, a.Alias)
+	case "unalias":
+		summary = fmt.Sprintf("Disable alias %q", a.Alias)
+		taskset, err = snapstate.Unalias(st, a.Alias)
+		if err == nil {
+			snapsup, err1 := snapstate.TaskSnapSetup(taskset.Tasks()[0])
+			if err1 != nil {
+				return InternalError("%v", err1)
+			}
+			snapName = snapsup.InstanceName()
+		}
+	default:
+		return BadRequest("unsupported alias action: %q", a.Action)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	change := newRequestChange(st, r, a.Action, summary)
+	change.Set("snap-names", []string{snapName})
+	change.AddAll(taskset)
+
+	ensureStateSoon(st)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
+}
@@ -0,0 +1,170 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type aliasesSuite struct {
+	apiBaseSuite
+}
+
+var _ = check.Suite(&aliasesSuite{})
+
+func (s *aliasesSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+	// restored by apiBaseSuite.TearDownTest
+	ensureStateSoon = func(*state.State) {}
+}
+
+const aliasSnapYaml = `
+apps:
+ cmd1:
+  command: cmd1
+ cmd2:
+  command: cmd2
+`
+
+func (s *aliasesSuite) postAliases(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/aliases", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	return aliasesCmd.POST(aliasesCmd, req, nil).(*resp)
+}
+
+func (s *aliasesSuite) TestAliasesCmd(c *check.C) {
+	c.Check(aliasesCmd.Path, check.Equals, "/v2/aliases")
+	c.Check(aliasesCmd.UserOK, check.Equals, true)
+	c.Check(aliasesCmd.PUT, check.IsNil)
+	c.Check(aliasesCmd.DELETE, check.IsNil)
+}
+
+func (s *aliasesSuite) TestGetAliases(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "alias-snap", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+		Active:   true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"cmd":    {App: "cmd1"},
+			"alias1": {App: "alias-snap", Auto: true},
+			"alias2": {App: "cmd2", Auto: true, Disabled: true},
+		},
+	})
+	snapstate.Set(st, "other-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "other-snap", Revision: snap.R(2)}},
+		Current:  snap.R(2),
+		Active:   true,
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/aliases", nil)
+	c.Assert(err, check.IsNil)
+	rsp := aliasesCmd.GET(aliasesCmd, req, nil).(*resp)
+
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, map[string]map[string]aliasStatus{
+		"alias-snap": {
+			"cmd":    {App: "cmd1", Command: "alias-snap.cmd1", Status: "manual"},
+			"alias1": {App: "alias-snap", Command: "alias-snap", Status: "auto"},
+			"alias2": {App: "cmd2", Command: "alias-snap.cmd2", Status: "disabled"},
+		},
+	})
+}
+
+func (s *aliasesSuite) TestAlias(c *check.C) {
+	s.mkInstalledInState(c, s.d, "alias-snap", "bar", "v1", snap.R(11), true, aliasSnapYaml)
+
+	rsp := s.postAliases(c, `{"action": "alias", "snap": "alias-snap", "app": "cmd1", "alias": "cmd"}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "alias")
+	c.Check(chg.Summary(), check.Equals, `Enable alias "cmd" for alias-snap.cmd1`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"alias-snap"})
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), check.Equals, "alias")
+}
+
+func (s *aliasesSuite) TestUnalias(c *check.C) {
+	st := s.d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{{RealName: "alias-snap", Revision: snap.R(11)}},
+		Current:  snap.R(11),
+		Active:   true,
+		Aliases: map[string]*snapstate.AliasTarget{
+			"cmd": {App: "cmd1"},
+		},
+	})
+	st.Unlock()
+
+	rsp := s.postAliases(c, `{"action": "unalias", "alias": "cmd"}`)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "unalias")
+	c.Check(chg.Summary(), check.Equals, `Disable alias "cmd"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"alias-snap"})
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), check.Equals, "unalias")
+}
+
+func (s *aliasesSuite) TestChangeAliasesErrors(c *check.C) {
+	s.mkInstalledInState(c, s.d, "alias-snap", "bar", "v1", snap.R(11), true, aliasSnapYaml)
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "alias"`, `cannot decode request body into an alias action: .*`},
+		{`{"action": "alias", "snap": "alias-snap", "app": "cmd1"}`, `at least an alias name is required`},
+		{`{"action": "alias", "snap": "alias-snap", "alias": "cmd"}`, `snap and app are required to enable an alias`},
+		{`{"action": "potato", "alias": "cmd"}`, `unsupported alias action: "potato"`},
+		{`{"action": "alias", "snap": "alias-snap", "app": "no-app", "alias": "cmd"}`, `cannot find app "no-app" in "alias-snap"`},
+		{`{"action": "unalias", "alias": "cmd"}`, `cannot find enabled alias "cmd"`},
+	} {
+		rsp := s.postAliases(c, t.body)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeError, check.Commentf(t.body))
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
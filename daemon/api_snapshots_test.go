@@ -0,0 +1,211 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type snapshotSuite struct {
+	apiBaseSuite
+
+	restore []func()
+}
+
+var _ = check.Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+	// restored by apiBaseSuite.TearDownTest
+	ensureStateSoon = func(*state.State) {}
+
+	oldList, oldCheck, oldForget, oldRestore, oldSave := snapshotList, snapshotCheck, snapshotForget, snapshotRestore, snapshotSave
+	s.restore = append(s.restore, func() {
+		snapshotList, snapshotCheck, snapshotForget, snapshotRestore, snapshotSave = oldList, oldCheck, oldForget, oldRestore, oldSave
+	})
+}
+
+func (s *snapshotSuite) TearDownTest(c *check.C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	s.apiBaseSuite.TearDownTest(c)
+}
+
+func (s *snapshotSuite) TestSnapshotCmd(c *check.C) {
+	c.Check(snapshotCmd.Path, check.Equals, "/v2/snapshots")
+	c.Check(snapshotCmd.UserOK, check.Equals, true)
+	c.Check(snapshotCmd.PUT, check.IsNil)
+	c.Check(snapshotCmd.DELETE, check.IsNil)
+}
+
+func (s *snapshotSuite) TestListSnapshots(c *check.C) {
+	snapshots := []client.SnapshotSet{
+		{ID: 1, Snapshots: []*client.Snapshot{{SetID: 1, Snap: "foo", Revision: snap.R(7)}}},
+	}
+	var gotSetID uint64
+	var gotSnaps []string
+	snapshotList = func(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+		gotSetID, gotSnaps = setID, snapNames
+		return snapshots, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=1&snaps=foo,bar", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.DeepEquals, snapshots)
+	c.Check(gotSetID, check.Equals, uint64(1))
+	c.Check(gotSnaps, check.DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *snapshotSuite) TestListSnapshotsErrors(c *check.C) {
+	snapshotList = func(uint64, []string) ([]client.SnapshotSet, error) {
+		return nil, errors.New("no")
+	}
+
+	req, err := http.NewRequest("GET", "/v2/snapshots?set=x", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `'set', if given, must be a positive base 10 number; got "x"`)
+
+	req, err = http.NewRequest("GET", "/v2/snapshots", nil)
+	c.Assert(err, check.IsNil)
+	rsp = listSnapshots(snapshotCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusInternalServerError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "no")
+}
+
+func (s *snapshotSuite) postSnapshots(c *check.C, body string) *resp {
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	return changeSnapshots(snapshotCmd, req, nil).(*resp)
+}
+
+func (s *snapshotSuite) TestChangeSnapshots(c *check.C) {
+	type call struct {
+		action string
+		setID  uint64
+		snaps  []string
+		users  []string
+	}
+	var calls []call
+	mock := func(action string) func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return func(st *state.State, setID uint64, snapNames []string, users []string) ([]string, *state.TaskSet, error) {
+			calls = append(calls, call{action, setID, snapNames, users})
+			return []string{"foo"}, state.NewTaskSet(st.NewTask(action+"-snapshot", "...")), nil
+		}
+	}
+	snapshotCheck = mock("check")
+	snapshotRestore = mock("restore")
+	snapshotForget = func(st *state.State, setID uint64, snapNames []string) ([]string, *state.TaskSet, error) {
+		return mock("forget")(st, setID, snapNames, nil)
+	}
+
+	for _, t := range []struct {
+		body    string
+		summary string
+		call    call
+	}{
+		{`{"set": 42, "action": "check"}`, `Check of snapshot set #42`, call{"check", 42, nil, nil}},
+		{`{"set": 42, "action": "restore", "snaps": ["foo"], "users": ["bar"]}`, `Restore of snapshot set #42 of snaps "foo" for users "bar"`, call{"restore", 42, []string{"foo"}, []string{"bar"}}},
+		{`{"set": 42, "action": "forget", "snaps": ["foo"]}`, `Forget of snapshot set #42 of snaps "foo"`, call{"forget", 42, []string{"foo"}, nil}},
+	} {
+		calls = nil
+		rsp := s.postSnapshots(c, t.body)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeAsync, check.Commentf(t.body))
+		c.Check(calls, check.DeepEquals, []call{t.call})
+
+		st := s.d.overlord.State()
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Assert(chg, check.NotNil)
+		c.Check(chg.Kind(), check.Equals, t.call.action+"-snapshot")
+		c.Check(chg.Summary(), check.Equals, t.summary)
+		var names []string
+		c.Check(chg.Get("snap-names", &names), check.IsNil)
+		c.Check(names, check.DeepEquals, []string{"foo"})
+		st.Unlock()
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotsErrors(c *check.C) {
+	snapshotCheck = func(*state.State, uint64, []string, []string) ([]string, *state.TaskSet, error) {
+		return nil, nil, errors.New(`snapshot set #42 not found`)
+	}
+
+	for body, msg := range map[string]string{
+		`{"set": 42`:                                      `cannot decode request body into snapshot operation: unexpected EOF`,
+		`{"set": 42, "action": "check"}{}`:                `extra content found after snapshot operation`,
+		`{"action": "check"}`:                             `snapshot operation requires snapshot set ID`,
+		`{"set": 42}`:                                     `snapshot operation requires action`,
+		`{"set": 42, "action": "frob"}`:                   `unknown snapshot operation "frob"`,
+		`{"set": 42, "action": "forget", "users": ["x"]}`: `snapshot "forget" operation cannot specify users`,
+		`{"set": 42, "action": "check"}`:                  `snapshot set #42 not found`,
+	} {
+		rsp := s.postSnapshots(c, body)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, msg, check.Commentf(body))
+	}
+}
+
+func (s *snapshotSuite) TestSnapshotMany(c *check.C) {
+	snapshotSave = func(st *state.State, snapNames []string, users []string) (uint64, []string, *state.TaskSet, error) {
+		c.Check(snapNames, check.DeepEquals, []string{"foo", "bar"})
+		c.Check(users, check.DeepEquals, []string{"me"})
+		t := st.NewTask("save-snapshot", "...")
+		return 7, snapNames, state.NewTaskSet(t), nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "snapshot", "snaps": ["foo", "bar"], "users": ["me"]}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	snapsCmd.POST(snapsCmd, req, nil).ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusAccepted)
+
+	var body map[string]interface{}
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &body), check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{"set-id": 7.0})
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(body["change"].(string))
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "save-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Snapshot snaps "foo", "bar"`)
+}
@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -39,6 +40,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"gopkg.in/check.v1"
 	"gopkg.in/macaroon.v1"
 
@@ -49,8 +51,10 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auditstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -59,6 +63,7 @@ import (
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -123,6 +128,11 @@ func (s *apiBaseSuite) Assertion(*asserts.AssertionType, []string, *auth.UserSta
 	panic("Assertion not expected to be called")
 }
 
+func (s *apiBaseSuite) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, _ int, _ *auth.UserState) (asserts.Assertion, error) {
+	// only assertions already in the system database are used
+	return nil, &store.AssertionNotFoundError{Ref: &asserts.Ref{Type: assertType, PrimaryKey: sequenceKey}}
+}
+
 func (s *apiBaseSuite) muxVars(*http.Request) map[string]string {
 	return s.vars
 }
@@ -171,6 +181,7 @@ func (s *apiBaseSuite) TearDownTest(c *check.C) {
 	snapstateCoreInfo = snapstate.CoreInfo
 	snapstateInstallPath = snapstate.InstallPath
 	assertstateRefreshSnapDeclarations = assertstate.RefreshSnapDeclarations
+	auditstateEntries = auditstate.Entries
 	unsafeReadSnapInfo = unsafeReadSnapInfoImpl
 	ensureStateSoon = ensureStateSoonImpl
 	dirs.SetRootDir("")
@@ -287,24 +298,26 @@ func (s *apiSuite) TestSnapInfoOneIntegration(c *check.C) {
 		Type:   ResponseTypeSync,
 		Status: http.StatusOK,
 		Result: map[string]interface{}{
-			"id":          "funky-snap-id",
-			"name":        "foo",
-			"revision":    snap.R(10),
-			"version":     "v1",
-			"channel":     "stable",
-			"summary":     "summary",
-			"description": "description",
-			"developer":   "bar",
-			"status":      "active",
-			"icon":        "/v2/icons/foo/icon",
-			"type":        string(snap.TypeApp),
-			"resource":    "/v2/snaps/foo",
-			"private":     false,
-			"devmode":     false,
-			"confinement": snap.StrictConfinement,
-			"trymode":     false,
-			"apps":        []appJSON{},
-			"broken":      "",
+			"id":               "funky-snap-id",
+			"name":             "foo",
+			"revision":         snap.R(10),
+			"version":          "v1",
+			"channel":          "stable",
+			"summary":          "summary",
+			"description":      "description",
+			"developer":        "bar",
+			"status":           "active",
+			"icon":             "/v2/icons/foo/icon",
+			"type":             string(snap.TypeApp),
+			"resource":         "/v2/snaps/foo",
+			"private":          false,
+			"devmode":          false,
+			"confinement":      snap.StrictConfinement,
+			"trymode":          false,
+			"jailmode":         false,
+			"tracking-channel": "",
+			"apps":             []appJSON{},
+			"broken":           "",
 		},
 		Meta: meta,
 	}
@@ -354,7 +367,9 @@ func (s *apiSuite) TestSnapInfoNoneFound(c *check.C) {
 
 	req, err := http.NewRequest("GET", "/v2/snaps/gfoo", nil)
 	c.Assert(err, check.IsNil)
-	c.Check(getSnapInfo(snapCmd, req, nil).(*resp).Status, check.Equals, http.StatusNotFound)
+	rsp := getSnapInfo(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindSnapNotFound)
 }
 
 func (s *apiSuite) TestSnapInfoIgnoresRemoteErrors(c *check.C) {
@@ -427,6 +442,8 @@ func (s *apiSuite) TestListIncludesAll(c *check.C) {
 		"storeUserInfo",
 		"postCreateUserUcrednetGetUID",
 		"ensureStateSoon",
+		"procSecurityLabel",
+		"auditstateEntries",
 	}
 	c.Check(found, check.Equals, len(api)+len(exceptions),
 		check.Commentf(`At a glance it looks like you've not added all the Commands defined in api to the api list. If that is not the case, please add the exception to the "exceptions" list in this test.`))
@@ -482,6 +499,9 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 		},
 		"on-classic": true,
 		"managed":    false,
+		"refresh": map[string]interface{}{
+			"schedule": snapstate.DefaultRefreshSchedule,
+		},
 	}
 	var rsp resp
 	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
@@ -490,6 +510,35 @@ func (s *apiSuite) TestSysInfo(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, expected)
 }
 
+func (s *apiSuite) TestSysInfoRefresh(c *check.C) {
+	d := s.daemon(c)
+
+	last := time.Date(2017, 3, 1, 2, 30, 0, 0, time.UTC)
+	next := time.Date(2017, 3, 1, 9, 15, 0, 0, time.UTC)
+
+	st := d.overlord.State()
+	st.Lock()
+	tr := configstate.NewTransaction(st)
+	c.Assert(tr.Set("core", "refresh.schedule", "mon-fri,03:00-05:00"), check.IsNil)
+	tr.Commit()
+	st.Set("last-refresh", last)
+	st.Set("next-refresh", next)
+	st.Unlock()
+
+	rec := httptest.NewRecorder()
+	sysInfoCmd.GET(sysInfoCmd, nil, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	result := rsp.Result.(map[string]interface{})
+	c.Check(result["refresh"], check.DeepEquals, map[string]interface{}{
+		"schedule": "mon-fri,03:00-05:00",
+		"last":     "2017-03-01T02:30:00Z",
+		"next":     "2017-03-01T09:15:00Z",
+	})
+}
+
 func (s *apiSuite) makeMyAppsServer(statusCode int, data string) *httptest.Server {
 	mockMyAppsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(statusCode)
@@ -1143,6 +1192,60 @@ func (s *apiSuite) TestFindOne(c *check.C) {
 	c.Check(snaps[0]["name"], check.Equals, "store")
 }
 
+func (s *apiSuite) TestFindOneNotFound(c *check.C) {
+	s.daemon(c)
+
+	s.err = store.ErrSnapNotFound
+
+	req, err := http.NewRequest("GET", "/v2/find?name=foo", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Kind, check.Equals, errorKindSnapNotFound)
+}
+
+func (s *apiSuite) TestFindOneDetails(c *check.C) {
+	s.daemon(c)
+
+	s.rsnaps = []*snap.Info{{
+		SideInfo: snap.SideInfo{
+			RealName:  "store",
+			Developer: "foo",
+		},
+		Publisher: "Foo Inc.",
+		License:   "MIT",
+		Contact:   "mailto:foo@example.com",
+		Tracks:    []string{"latest"},
+		Channels: map[string]*snap.ChannelSnapInfo{
+			"stable": {Revision: snap.R(2), Version: "1.0", Channel: "stable", Size: 42},
+		},
+	}}
+
+	req, err := http.NewRequest("GET", "/v2/find?name=store", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := searchStore(findCmd, req, nil).(*resp)
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["publisher"], check.Equals, "Foo Inc.")
+	c.Check(snaps[0]["license"], check.Equals, "MIT")
+	c.Check(snaps[0]["contact"], check.Equals, "mailto:foo@example.com")
+	c.Check(snaps[0]["tracks"], check.DeepEquals, []interface{}{"latest"})
+	c.Check(snaps[0]["channels"], check.DeepEquals, map[string]interface{}{
+		"stable": map[string]interface{}{
+			"revision":    "2",
+			"version":     "1.0",
+			"channel":     "stable",
+			"epoch":       "",
+			"confinement": "",
+			"size":        42.0,
+		},
+	})
+}
+
 func (s *apiSuite) TestFindRefreshNotQ(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/find?select=refresh&q=foo", nil)
 	c.Assert(err, check.IsNil)
@@ -1479,6 +1582,218 @@ func (s *apiSuite) TestPostSnap(c *check.C) {
 	c.Check(soon, check.Equals, 1)
 }
 
+func (s *apiSuite) TestPostSnapAt(c *check.C) {
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+	ensureStateSoon = func(st *state.State) {}
+
+	s.vars = map[string]string{"name": "foo"}
+
+	snapInstructionDispTable["refresh"] = func(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+		t := st.NewTask("fake-refresh", "Refreshing foo")
+		return "Refresh foo", []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+	defer func() {
+		snapInstructionDispTable["refresh"] = snapUpdate
+	}()
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "refresh", "at": %q}`, at.Format(time.RFC3339)))
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.AtTime().Equal(at), check.Equals, true)
+	c.Check(chg.IsScheduled(), check.Equals, true)
+	st.Unlock()
+
+	s.vars = map[string]string{"id": chg.ID()}
+	req, err = http.NewRequest("GET", "/v2/change/"+chg.ID(), nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+
+	chgInfo := rsp.Result.(*changeInfo)
+	c.Check(chgInfo.Status, check.Equals, "Scheduled")
+	c.Assert(chgInfo.AtTime, check.NotNil)
+	c.Check(chgInfo.AtTime.Equal(at), check.Equals, true)
+}
+
+func (s *apiSuite) TestPostSnapAtInThePast(c *check.C) {
+	s.daemon(c)
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "at": "2016-01-02T03:04:05Z"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot refresh "foo": cannot schedule a change in the past (2016-01-02T03:04:05Z)`)
+}
+
+func (s *apiSuite) mockLinkSnapChange(c *check.C, st *state.State, name string) *state.Change {
+	chg := st.NewChange("install-snap", "...")
+	t := st.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: name}})
+	chg.AddTask(t)
+	return chg
+}
+
+func (s *apiSuite) TestPostSnapChangeConflict(c *check.C) {
+	d := s.daemon(c)
+	s.vars = map[string]string{"name": "foo"}
+
+	snapInstructionDispTable["refresh"] = func(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+		if err := snapstate.CheckChangeConflict(st, "foo", nil); err != nil {
+			return "", nil, err
+		}
+		return "Refresh foo", []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-refresh", "..."))}, nil
+	}
+	defer func() {
+		snapInstructionDispTable["refresh"] = snapUpdate
+	}()
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := s.mockLinkSnapChange(c, st, "foo")
+	st.Unlock()
+
+	buf := bytes.NewBufferString(`{"action": "refresh"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusConflict)
+	c.Check(rsp.Result, check.DeepEquals, &errorResult{
+		Message: `cannot refresh "foo": snap "foo" has changes in progress`,
+		Kind:    errorKindChangeConflict,
+		Value: map[string]interface{}{
+			"change-kind": "install-snap",
+			"change-id":   chg.ID(),
+		},
+	})
+}
+
+func (s *apiSuite) TestPostSnapWaitForChange(c *check.C) {
+	d := s.daemon(c)
+	ensureStateSoon = func(st *state.State) {}
+	s.vars = map[string]string{"name": "foo"}
+
+	snapInstructionDispTable["refresh"] = func(inst *snapInstruction, st *state.State) (string, []*state.TaskSet, error) {
+		if err := snapstate.CheckChangeConflictIgnoringChange(st, "foo", nil, inst.WaitForChange); err != nil {
+			return "", nil, err
+		}
+		return "Refresh foo", []*state.TaskSet{state.NewTaskSet(st.NewTask("fake-refresh", "..."))}, nil
+	}
+	defer func() {
+		snapInstructionDispTable["refresh"] = snapUpdate
+	}()
+
+	st := d.overlord.State()
+	st.Lock()
+	chg1 := s.mockLinkSnapChange(c, st, "foo")
+	st.Unlock()
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "refresh", "wait-for-change": %q}`, chg1.ID()))
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.WaitChanges(), check.DeepEquals, []*state.Change{chg1})
+
+	// conflicts are only ignored by the operations of the change
+	c.Check(snapstate.CheckChangeConflict(st, "foo", nil), check.NotNil)
+}
+
+func (s *apiSuite) TestPostSnapWaitForChangeFlags(c *check.C) {
+	d := s.daemon(c)
+	ensureStateSoon = func(st *state.State) {}
+	s.vars = map[string]string{"name": "foo"}
+
+	var flags snapstate.Flags
+	snapstateUpdate = func(st *state.State, name, channel string, revision snap.Revision, userID int, f snapstate.Flags) (*state.TaskSet, error) {
+		flags = f
+		return state.NewTaskSet(st.NewTask("fake-refresh", "...")), nil
+	}
+	defer func() { snapstateUpdate = snapstate.Update }()
+	assertstateRefreshSnapDeclarations = func(*state.State, int) error { return nil }
+
+	st := d.overlord.State()
+	st.Lock()
+	chg1 := s.mockLinkSnapChange(c, st, "foo")
+	st.Unlock()
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "refresh", "wait-for-change": %q}`, chg1.ID()))
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(flags.IgnoreConflictsWith, check.Equals, chg1.ID())
+}
+
+func (s *apiSuite) TestPostSnapsOpWaitForChangeFlags(c *check.C) {
+	var flags snapstate.Flags
+	snapstateRemoveMany = func(s *state.State, names []string, f snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		flags = f
+		t := s.NewTask("fake-remove", "...")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+	defer func() { snapstateRemoveMany = snapstate.RemoveMany }()
+	ensureStateSoon = func(st *state.State) {}
+
+	d := s.daemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	chg1 := s.mockLinkSnapChange(c, st, "foo")
+	st.Unlock()
+
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "remove", "snaps": ["foo"], "wait-for-change": %q}`, chg1.ID()))
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(flags.IgnoreConflictsWith, check.Equals, chg1.ID())
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.WaitChanges(), check.DeepEquals, []*state.Change{chg1})
+}
+
+func (s *apiSuite) TestPostSnapWaitForUnknownChange(c *check.C) {
+	s.daemon(c)
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "wait-for-change": "42"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot refresh "foo": cannot find change "42" to wait for`)
+}
+
 func (s *apiSuite) TestPostSnapSetsUser(c *check.C) {
 	d := s.daemon(c)
 	ensureStateSoon = func(st *state.State) {}
@@ -1528,6 +1843,7 @@ func (s *apiSuite) TestPostSnapDispatch(c *check.C) {
 		{"revert", snapRevert},
 		{"enable", snapEnable},
 		{"disable", snapDisable},
+		{"switch", snapSwitch},
 		{"xyzzy", nil},
 	}
 
@@ -1552,6 +1868,53 @@ func (s *apiSuite) TestPostSnapEnableDisableRevision(c *check.C) {
 	}
 }
 
+func (s *apiSuite) TestPostSnapSwitch(c *check.C) {
+	d := s.daemon(c)
+	ensureStateSoon = func(*state.State) {}
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	s.vars = map[string]string{"name": "foo"}
+
+	buf := bytes.NewBufferString(`{"action": "switch", "channel": "1.0/beta"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/foo", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postSnap(snapCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "switch-snap")
+	c.Check(chg.Summary(), check.Equals, `Switch "foo" snap to 1.0/beta`)
+	c.Assert(chg.Tasks(), check.HasLen, 1)
+	c.Check(chg.Tasks()[0].Kind(), check.Equals, "switch-snap-channel")
+}
+
+func (s *apiSuite) TestPostSnapSwitchErrors(c *check.C) {
+	d := s.daemon(c)
+	s.mkInstalledInState(c, d, "foo", "bar", "v1", snap.R(10), true, "")
+	s.vars = map[string]string{"name": "foo"}
+
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "switch", "channel": "beta", "revision": "42"}`, `cannot switch "foo": switch takes no revision`},
+		{`{"action": "switch"}`, `cannot switch "foo": switch needs a channel`},
+		{`{"action": "switch", "channel": "1.0/potato"}`, `cannot switch "foo": invalid risk in channel name: "1.0/potato"`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps/foo", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+
+		rsp := postSnap(snapCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.err)
+	}
+}
+
 var sideLoadBodyWithoutDevMode = "" +
 	"----hello--\r\n" +
 	"Content-Disposition: form-data; name=\"snap\"; filename=\"x\"\r\n" +
@@ -2353,7 +2716,7 @@ func (s *apiSuite) TestRefreshIgnoreValidation(c *check.C) {
 }
 
 func (s *apiSuite) TestPostSnapsOp(c *check.C) {
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		t := s.NewTask("fake-refresh-all", "Refreshing everything")
 		return []string{"fake1", "fake2"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2382,6 +2745,35 @@ func (s *apiSuite) TestPostSnapsOp(c *check.C) {
 	c.Check(apiData["snap-names"], check.DeepEquals, []interface{}{"fake1", "fake2"})
 }
 
+func (s *apiSuite) TestPostSnapsOpAt(c *check.C) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		t := s.NewTask("fake-refresh-all", "Refreshing everything")
+		return []string{"fake1"}, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+	ensureStateSoon = func(st *state.State) {}
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"action": "refresh", "at": %q}`, at.Format(time.RFC3339)))
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, ok := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Check(chg.AtTime().Equal(at), check.Equals, true)
+	c.Check(chg.IsScheduled(), check.Equals, true)
+}
+
 func (s *apiSuite) TestRefreshAll(c *check.C) {
 	refreshSnapDecls := false
 	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
@@ -2389,7 +2781,7 @@ func (s *apiSuite) TestRefreshAll(c *check.C) {
 		return assertstate.RefreshSnapDeclarations(s, userID)
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 0)
 		t := s.NewTask("fake-refresh-all", "Refreshing everything")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2406,24 +2798,54 @@ func (s *apiSuite) TestRefreshAll(c *check.C) {
 	c.Check(refreshSnapDecls, check.Equals, true)
 }
 
-func (s *apiSuite) TestRefreshMany(c *check.C) {
-	refreshSnapDecls := false
+func (s *apiSuite) TestRefreshAllNothingRecordsRequester(c *check.C) {
 	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
-		refreshSnapDecls = true
 		return nil
 	}
-
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
-		c.Check(names, check.HasLen, 2)
-		t := s.NewTask("fake-refresh-2", "Refreshing two")
-		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		return nil, nil, nil
 	}
 
 	d := s.daemon(c)
-	inst := &snapInstruction{Action: "refresh", Snaps: []string{"foo", "bar"}}
+	buf := bytes.NewBufferString(`{"action": "refresh"}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "uid=1000;pid=100;socket=;"
+
+	rsp := postSnaps(snapsCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
 	st := d.overlord.State()
 	st.Lock()
-	summary, updates, _, err := snapUpdateMany(inst, st)
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	// the change is ready already, and carries the uid for auditing
+	c.Check(chg.Status(), check.Equals, state.DoneStatus)
+	var uid uint32
+	c.Assert(chg.Get("uid", &uid), check.IsNil)
+	c.Check(uid, check.Equals, uint32(1000))
+}
+
+func (s *apiSuite) TestRefreshMany(c *check.C) {
+	refreshSnapDecls := false
+	assertstateRefreshSnapDeclarations = func(s *state.State, userID int) error {
+		refreshSnapDecls = true
+		return nil
+	}
+
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
+		c.Check(names, check.HasLen, 2)
+		t := s.NewTask("fake-refresh-2", "Refreshing two")
+		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
+	}
+
+	d := s.daemon(c)
+	inst := &snapInstruction{Action: "refresh", Snaps: []string{"foo", "bar"}}
+	st := d.overlord.State()
+	st.Lock()
+	summary, updates, _, err := snapUpdateMany(inst, st)
 	st.Unlock()
 	c.Assert(err, check.IsNil)
 	c.Check(summary, check.Equals, `Refresh snaps "foo", "bar"`)
@@ -2438,7 +2860,7 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 		return nil
 	}
 
-	snapstateUpdateMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateUpdateMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 1)
 		t := s.NewTask("fake-refresh-1", "Refreshing one")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2457,7 +2879,7 @@ func (s *apiSuite) TestRefreshMany1(c *check.C) {
 }
 
 func (s *apiSuite) TestInstallMany(c *check.C) {
-	snapstateInstallMany = func(s *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+	snapstateInstallMany = func(s *state.State, names []string, userID int, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
 		t := s.NewTask("fake-install-2", "Install two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2475,7 +2897,7 @@ func (s *apiSuite) TestInstallMany(c *check.C) {
 }
 
 func (s *apiSuite) TestRemoveMany(c *check.C) {
-	snapstateRemoveMany = func(s *state.State, names []string) ([]string, []*state.TaskSet, error) {
+	snapstateRemoveMany = func(s *state.State, names []string, flags snapstate.Flags) ([]string, []*state.TaskSet, error) {
 		c.Check(names, check.HasLen, 2)
 		t := s.NewTask("fake-remove-2", "Remove two")
 		return names, []*state.TaskSet{state.NewTaskSet(t)}, nil
@@ -2849,6 +3271,37 @@ func (s *apiSuite) TestConnectPlugSuccess(c *check.C) {
 	c.Check(slot.Connections[0], check.DeepEquals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
 }
 
+func (s *apiSuite) TestConnectPlugWaitForChange(c *check.C) {
+	d := s.daemon(c)
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	st := d.overlord.State()
+	st.Lock()
+	chg1 := st.NewChange("install-snap", "...")
+	chg1.AddTask(st.NewTask("fake-install", "..."))
+	st.Unlock()
+
+	action := &interfaceAction{
+		Action:        "connect",
+		Plugs:         []plugJSON{{Snap: "consumer", Name: "plug"}},
+		Slots:         []slotJSON{{Snap: "producer", Name: "slot"}},
+		WaitForChange: chg1.ID(),
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/v2/interfaces", bytes.NewBuffer(text))
+	c.Assert(err, check.IsNil)
+	rsp := changeInterfaces(interfacesCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.WaitChanges(), check.DeepEquals, []*state.Change{chg1})
+}
+
 func (s *apiSuite) TestConnectPlugFailureInterfaceMismatch(c *check.C) {
 	d := s.daemon(c)
 
@@ -3330,6 +3783,91 @@ func (s *apiSuite) TestAssertStreamOK(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *apiSuite) TestAssertBundle(c *check.C) {
+	// Setup
+	restore := sysdb.InjectTrusted(s.storeSigning.Trusted)
+	defer restore()
+	d := s.daemon(c)
+	st := d.overlord.State()
+
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	err := enc.Encode(s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, check.IsNil)
+	err = enc.Encode(acct)
+	c.Assert(err, check.IsNil)
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions?bundle=true", buf)
+	c.Assert(err, check.IsNil)
+	rsp := doAssert(assertsCmd, req, nil).(*resp)
+	// Verify (external)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"added": []assertionRef{
+			{Type: "account-key", PrimaryKey: []string{s.storeSigning.StoreAccountKey("").PublicKeyID()}},
+			{Type: "account", PrimaryKey: []string{acct.AccountID()}},
+		},
+	})
+	// Verify (internal)
+	st.Lock()
+	defer st.Unlock()
+	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
+		"account-id": acct.AccountID(),
+	})
+	c.Check(err, check.IsNil)
+}
+
+func (s *apiSuite) TestAssertBundleError(c *check.C) {
+	// Setup
+	restore := sysdb.InjectTrusted(s.storeSigning.Trusted)
+	defer restore()
+	d := s.daemon(c)
+	st := d.overlord.State()
+
+	acct := assertstest.NewAccount(s.storeSigning, "developer1", nil, "")
+	snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "snap-id-1",
+		"snap-name":    "foo",
+		"publisher-id": "unknown-id",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	for _, a := range []asserts.Assertion{s.storeSigning.StoreAccountKey(""), acct, snapDecl} {
+		err := enc.Encode(a)
+		c.Assert(err, check.IsNil)
+	}
+
+	// Execute
+	req, err := http.NewRequest("POST", "/v2/assertions?bundle=true", buf)
+	c.Assert(err, check.IsNil)
+	rsp := doAssert(assertsCmd, req, nil).(*resp)
+	// Verify (external)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, "assert failed: cannot find account \\(unknown-id\\).*")
+	// Verify (internal): nothing was added
+	st.Lock()
+	defer st.Unlock()
+	_, err = assertstate.DB(st).Find(asserts.AccountType, map[string]string{
+		"account-id": acct.AccountID(),
+	})
+	c.Check(err, check.Equals, asserts.ErrNotFound)
+}
+
+func (s *apiSuite) TestAssertBundleInvalidValue(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/assertions?bundle=maybe", nil)
+	c.Assert(err, check.IsNil)
+	rsp := doAssert(assertsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Matches, `invalid value for bundle: "maybe": .*`)
+}
+
 func (s *apiSuite) TestAssertInvalid(c *check.C) {
 	// Setup
 	buf := bytes.NewBufferString("blargh")
@@ -3493,6 +4031,183 @@ func (s *apiSuite) TestGetEvents(c *check.C) {
 	c.Assert(d.hub.SubscriberCount(), check.Equals, 1)
 }
 
+func (s *apiSuite) TestGetEventsStream(c *check.C) {
+	d := s.daemon(c)
+	eventsCmd.d = d
+
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("install-snap", "Install foo")
+	t := st.NewTask("download-snap", "Download foo")
+	chg.AddTask(t)
+	other := st.NewChange("remove-snap", "Remove bar")
+	ot := st.NewTask("unlink-snap", "Unlink bar")
+	other.AddTask(ot)
+	st.Unlock()
+
+	ts := httptest.NewServer(http.HandlerFunc(eventsCmd.GET(eventsCmd, nil, nil).ServeHTTP))
+	defer ts.Close()
+
+	rsp, err := http.Get(ts.URL + "?change=" + chg.ID())
+	c.Assert(err, check.IsNil)
+	defer rsp.Body.Close()
+	c.Check(rsp.StatusCode, check.Equals, 200)
+	c.Check(rsp.Header.Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Check(d.hub.SubscriberCount(), check.Equals, 1)
+
+	st.Lock()
+	ot.SetStatus(state.DoingStatus)
+	t.SetStatus(state.DoingStatus)
+	t.Logf("some log")
+	t.SetProgress("foo", 1, 2)
+	// too soon after the previous one
+	t.SetProgress("foo", 2, 3)
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	type event struct {
+		Type     string                 `json:"type"`
+		Resource string                 `json:"resource"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	r := bufio.NewReader(rsp.Body)
+	var events []event
+	for i := 0; i < 5; i++ {
+		line, err := r.ReadBytes('\n')
+		c.Assert(err, check.IsNil)
+		c.Assert(line[0], check.Equals, byte(0x1E))
+		var ev event
+		c.Assert(json.Unmarshal(line[1:], &ev), check.IsNil)
+		c.Check(ev.Resource, check.Equals, "/v2/changes/"+chg.ID())
+		c.Check(ev.Metadata["change-id"], check.Equals, chg.ID())
+		c.Check(ev.Metadata["change-kind"], check.Equals, "install-snap")
+		events = append(events, ev)
+	}
+
+	c.Check(events[0].Type, check.Equals, "task-status")
+	c.Check(events[0].Metadata["status"], check.Equals, "Doing")
+	c.Check(events[0].Metadata["task-id"], check.Equals, t.ID())
+	c.Check(events[0].Metadata["task-kind"], check.Equals, "download-snap")
+	c.Check(events[0].Metadata["summary"], check.Equals, "Download foo")
+	c.Check(events[1].Type, check.Equals, "change-status")
+	c.Check(events[1].Metadata["status"], check.Equals, "Doing")
+	c.Check(events[1].Metadata["ready"], check.Equals, false)
+	c.Check(events[2].Type, check.Equals, "task-progress")
+	c.Check(events[2].Metadata["progress"], check.DeepEquals, map[string]interface{}{
+		"label": "foo",
+		"done":  1.0,
+		"total": 2.0,
+	})
+	c.Check(events[2].Metadata["log"], check.Matches, ".* INFO some log")
+	c.Check(events[3].Type, check.Equals, "task-status")
+	c.Check(events[3].Metadata["status"], check.Equals, "Done")
+	c.Check(events[4].Type, check.Equals, "change-status")
+	c.Check(events[4].Metadata["status"], check.Equals, "Done")
+	c.Check(events[4].Metadata["ready"], check.Equals, true)
+}
+
+func (s *apiSuite) TestGetEventsStreamDropsSlowClients(c *check.C) {
+	oldEventQueueSize := eventQueueSize
+	eventQueueSize = 1
+	defer func() { eventQueueSize = oldEventQueueSize }()
+
+	d := s.daemon(c)
+	eventsCmd.d = d
+
+	req, err := http.NewRequest("GET", "/v2/events", nil)
+	c.Assert(err, check.IsNil)
+	// a recorder doesn't notice the client going away, so this only
+	// returns once the hub drops the subscriber
+	done := make(chan struct{})
+	go func() {
+		eventsCmd.GET(eventsCmd, req, nil).ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	st := d.overlord.State()
+	for d.hub.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	st.Lock()
+	for i := 0; i < 100; i++ {
+		st.NewTask("foo", "...").SetStatus(state.DoingStatus)
+	}
+	st.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatal("the events stream didn't stop")
+	}
+	c.Check(d.hub.SubscriberCount(), check.Equals, 0)
+}
+
+func (s *apiSuite) TestGetEventsWebsocket(c *check.C) {
+	d := s.daemon(c)
+	eventsCmd.d = d
+
+	ts := httptest.NewServer(http.HandlerFunc(eventsCmd.GET(eventsCmd, nil, nil).ServeHTTP))
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	c.Check(d.hub.SubscriberCount(), check.Equals, 1)
+
+	st := d.overlord.State()
+	st.Lock()
+	t := st.NewTask("foo", "...")
+	t.SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var ev struct {
+		Type     string                 `json:"type"`
+		Metadata map[string]interface{} `json:"metadata"`
+	}
+	c.Assert(conn.ReadJSON(&ev), check.IsNil)
+	c.Check(ev.Type, check.Equals, "task-status")
+	c.Check(ev.Metadata["task-id"], check.Equals, t.ID())
+}
+
+func (s *apiSuite) TestGetEventsWebsocketDropsSlowClients(c *check.C) {
+	oldEventQueueSize := eventQueueSize
+	eventQueueSize = 1
+	oldEventWriteTimeout := eventWriteTimeout
+	eventWriteTimeout = 10 * time.Millisecond
+	defer func() {
+		eventQueueSize = oldEventQueueSize
+		eventWriteTimeout = oldEventWriteTimeout
+	}()
+
+	d := s.daemon(c)
+	eventsCmd.d = d
+
+	ts := httptest.NewServer(http.HandlerFunc(eventsCmd.GET(eventsCmd, nil, nil).ServeHTTP))
+	defer ts.Close()
+
+	// a client that never reads
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	c.Check(d.hub.SubscriberCount(), check.Equals, 1)
+
+	// publishing doesn't wait on the client, whether the events pile
+	// up in its queue or writing them to it times out
+	st := d.overlord.State()
+	summary := strings.Repeat("x", 1024*1024)
+	st.Lock()
+	for i := 0; i < 20; i++ {
+		st.NewTask("foo", summary).SetStatus(state.DoingStatus)
+	}
+	st.Unlock()
+
+	for i := 0; i < 500 && d.hub.SubscriberCount() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Check(d.hub.SubscriberCount(), check.Equals, 0)
+}
+
 func setupChanges(st *state.State) []string {
 	chg1 := st.NewChange("install", "install...")
 	chg1.Set("snap-names", []string{"funky-snap-name"})
@@ -3646,6 +4361,121 @@ func (s *apiSuite) TestStateChangesForSnapName(c *check.C) {
 	c.Assert(err, check.IsNil)
 }
 
+func (s *apiSuite) TestStateChangesSinceWithHistory(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	// Setup
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	since := time.Date(2016, 04, 20, 0, 0, 0, 0, time.UTC)
+	auditstateEntries = func(t time.Time) ([]*auditstate.Entry, error) {
+		c.Check(t.Equal(since), check.Equals, true)
+		return []*auditstate.Entry{{
+			ID:        "99",
+			Kind:      "refresh-snap",
+			Summary:   "Refresh foo",
+			Status:    "Error",
+			SnapNames: []string{"foo"},
+			SpawnTime: time.Date(2016, 04, 20, 1, 0, 0, 0, time.UTC),
+			ReadyTime: time.Date(2016, 04, 20, 2, 0, 0, 0, time.UTC),
+			Err:       "cannot perform the following tasks:\n- Refresh foo (boom)",
+		}, {
+			// still in the state
+			ID:     ids[1],
+			Kind:   "remove",
+			Status: "Error",
+		}}, nil
+	}
+
+	// Execute
+	req, err := http.NewRequest("GET", "/v2/changes?select=all&since=2016-04-20T00:00:00Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	// Verify
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	res := rsp.Result.([]*changeInfo)
+	c.Assert(res, check.HasLen, 3)
+	byID := make(map[string]*changeInfo)
+	for _, chgInfo := range res {
+		byID[chgInfo.ID] = chgInfo
+	}
+	c.Check(byID[ids[0]], check.NotNil)
+	c.Check(byID[ids[1]].Tasks, check.HasLen, 1)
+	readyTime := time.Date(2016, 04, 20, 2, 0, 0, 0, time.UTC)
+	c.Check(byID["99"], check.DeepEquals, &changeInfo{
+		ID:        "99",
+		Kind:      "refresh-snap",
+		Summary:   "Refresh foo",
+		Status:    "Error",
+		Ready:     true,
+		Err:       "cannot perform the following tasks:\n- Refresh foo (boom)",
+		SpawnTime: time.Date(2016, 04, 20, 1, 0, 0, 0, time.UTC),
+		ReadyTime: &readyTime,
+	})
+
+	// filtering by snap name applies to the history too
+	req, err = http.NewRequest("GET", "/v2/changes?select=all&since=2016-04-20T00:00:00Z&for=foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getChanges(stateChangesCmd, req, nil).(*resp)
+	res = rsp.Result.([]*changeInfo)
+	c.Assert(res, check.HasLen, 1)
+	c.Check(res[0].ID, check.Equals, "99")
+}
+
+func (s *apiSuite) TestStateChangesSinceLeavesOutOlderReady(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	ids := setupChanges(st)
+	st.Unlock()
+
+	auditstateEntries = func(time.Time) ([]*auditstate.Entry, error) {
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=all&since=2016-04-21T02:00:00Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	res := rsp.Result.([]*changeInfo)
+	// the remove change was ready before, the install one is in progress
+	c.Assert(res, check.HasLen, 1)
+	c.Check(res[0].ID, check.Equals, ids[0])
+}
+
+func (s *apiSuite) TestStateChangesSinceInProgressNoHistory(c *check.C) {
+	newTestDaemon(c)
+	auditstateEntries = func(time.Time) ([]*auditstate.Entry, error) {
+		c.Fatalf("unexpected look up of the audit log")
+		return nil, nil
+	}
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=in-progress&since=2016-04-21T02:00:00Z", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+}
+
+func (s *apiSuite) TestStateChangesSinceBad(c *check.C) {
+	newTestDaemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=all&since=yesterday", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChanges(stateChangesCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `cannot parse since "yesterday": expected RFC3339 format`)
+}
+
 func (s *apiSuite) TestStateChange(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -3708,6 +4538,29 @@ func (s *apiSuite) TestStateChange(c *check.C) {
 	})
 }
 
+func (s *apiSuite) TestStateChangeHookTimeout(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+	st.Lock()
+	chg := st.NewChange("configure-snap", "...")
+	t := st.NewTask("run-hook", "...")
+	t.Errorf(`hook "configure" timed out after 10m0s`)
+	t.Set("error-kind", hookstate.ErrorKindHookTimeout)
+	t.SetStatus(state.ErrorStatus)
+	chg.AddTask(t)
+	st.Unlock()
+	s.vars = map[string]string{"id": chg.ID()}
+
+	req, err := http.NewRequest("GET", "/v2/change/"+chg.ID(), nil)
+	c.Assert(err, check.IsNil)
+	rsp := getChange(stateChangeCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+
+	chgInfo := rsp.Result.(*changeInfo)
+	c.Check(chgInfo.Err, check.Matches, `(?s).*hook "configure" timed out after 10m0s.*`)
+	c.Check(chgInfo.ErrKind, check.Equals, errorKindHookTimeout)
+}
+
 func (s *apiSuite) TestStateChangeAbort(c *check.C) {
 	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
 	defer restore()
@@ -4511,3 +5364,363 @@ func (s *postCreateUserSuite) TestSysinfoIsManaged(c *check.C) {
 	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
 	c.Check(rsp.Result.(map[string]interface{})["managed"], check.Equals, true)
 }
+
+type appSuite struct {
+	apiBaseSuite
+
+	sysctlArgs  [][]string
+	jctlSvcs    [][]string
+	jctlNs      []string
+	jctlFollows []bool
+	jctlRCs     []io.ReadCloser
+	jctlErrs    []error
+
+	restoreSystemctl  func()
+	restoreJournalctl func()
+}
+
+var _ = check.Suite(&appSuite{})
+
+func (s *appSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.sysctlArgs = nil
+	s.jctlSvcs = nil
+	s.jctlNs = nil
+	s.jctlFollows = nil
+	s.jctlRCs = nil
+	s.jctlErrs = nil
+	oldSystemctl := systemd.SystemctlCmd
+	oldJournalctl := systemd.JournalctlCmd
+	systemd.SystemctlCmd = s.systemctl
+	systemd.JournalctlCmd = s.journalctl
+	s.restoreSystemctl = func() { systemd.SystemctlCmd = oldSystemctl }
+	s.restoreJournalctl = func() { systemd.JournalctlCmd = oldJournalctl }
+	s.daemon(c)
+	s.mkInstalledInState(c, s.d, "snap-a", "bar", "v1", snap.R(1), true, `apps: {svc1: {daemon: simple}, svc2: {daemon: simple}, svc3: {daemon: simple}, cmd1: {}}`)
+	s.mkInstalledInState(c, s.d, "snap-b", "bar", "v1", snap.R(1), true, `apps: {svc3: {daemon: simple}, cmd2: {}}`)
+	s.mkInstalledInState(c, s.d, "snap-c", "bar", "v1", snap.R(1), true, `apps: {cmd3: {}}`)
+}
+
+func (s *appSuite) TearDownTest(c *check.C) {
+	s.restoreSystemctl()
+	s.restoreJournalctl()
+	s.apiBaseSuite.TearDownTest(c)
+}
+
+func (s *appSuite) systemctl(args ...string) (buf []byte, err error) {
+	s.sysctlArgs = append(s.sysctlArgs, args)
+
+	if args[0] != "show" {
+		panic(fmt.Sprintf("unexpected systemctl call: %v", args))
+	}
+
+	// svc3 of any snap is disabled and inactive, all others are enabled and active
+	if strings.HasSuffix(args[2], ".svc3.service") {
+		return []byte("Id=" + args[2] + "\nLoadState=loaded\nActiveState=inactive\nSubState=dead\nUnitFileState=disabled\n"), nil
+	}
+	return []byte("Id=" + args[2] + "\nLoadState=loaded\nActiveState=active\nSubState=running\nUnitFileState=enabled\n"), nil
+}
+
+func (s *appSuite) journalctl(svcs []string, n string, follow bool) (rc io.ReadCloser, err error) {
+	s.jctlSvcs = append(s.jctlSvcs, svcs)
+	s.jctlNs = append(s.jctlNs, n)
+	s.jctlFollows = append(s.jctlFollows, follow)
+
+	if len(s.jctlErrs) > 0 {
+		err, s.jctlErrs = s.jctlErrs[0], s.jctlErrs[1:]
+	}
+	if len(s.jctlRCs) > 0 {
+		rc, s.jctlRCs = s.jctlRCs[0], s.jctlRCs[1:]
+	}
+
+	return rc, err
+}
+
+func (s *appSuite) TestGetAppsInfo(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/apps", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []appJSON{
+		{Snap: "snap-a", Name: "cmd1"},
+		{Snap: "snap-a", Name: "svc1", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-a", Name: "svc2", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-a", Name: "svc3", Daemon: "simple"},
+		{Snap: "snap-b", Name: "cmd2"},
+		{Snap: "snap-b", Name: "svc3", Daemon: "simple"},
+		{Snap: "snap-c", Name: "cmd3"},
+	})
+}
+
+func (s *appSuite) TestGetAppsInfoNames(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-a.svc1,snap-b&select=service", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []appJSON{
+		{Snap: "snap-a", Name: "svc1", Daemon: "simple", Enabled: true, Active: true},
+		{Snap: "snap-b", Name: "svc3", Daemon: "simple"},
+	})
+	c.Check(s.sysctlArgs, check.DeepEquals, [][]string{
+		{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState", "snap.snap-a.svc1.service"},
+		{"show", "--property=Id,LoadState,ActiveState,SubState,UnitFileState", "snap.snap-b.svc3.service"},
+	})
+}
+
+func (s *appSuite) TestGetAppsInfoBadSelect(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/apps?select=potato", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getAppsInfo(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeError)
+}
+
+func (s *appSuite) TestAppInfosForErrors(c *check.C) {
+	st := s.d.overlord.State()
+	for _, t := range []struct {
+		names []string
+		error string
+	}{
+		{[]string{"foo"}, `snap "foo" not found`},
+		{[]string{"foo.bar"}, `snap "foo" not found`},
+		{[]string{"snap-a.potato"}, `snap "snap-a" has no service "potato"`},
+		{[]string{"snap-a.cmd1"}, `snap "snap-a" has no service "cmd1"`},
+		{[]string{"snap-c"}, `snap "snap-c" has no services`},
+	} {
+		apps, rsp := appInfosFor(st, t.names, appInfoOptions{service: true})
+		c.Check(apps, check.IsNil)
+		c.Assert(rsp, check.NotNil)
+		c.Check(rsp.(*resp).Status, check.Equals, http.StatusNotFound)
+		c.Check(rsp.(*resp).Result.(*errorResult).Message, check.Equals, t.error)
+	}
+}
+
+func (s *appSuite) TestPostApps(c *check.C) {
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+	}
+
+	buf := bytes.NewBufferString(`{"action": "start", "names": ["snap-a.svc1", "snap-b"], "enable": true}`)
+	req, err := http.NewRequest("POST", "/v2/apps", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postApps(appsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusAccepted)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	c.Check(soon, check.Equals, 1)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "service-control")
+	c.Check(chg.Summary(), check.Equals, `Running service command "start" for "snap-a.svc1", "snap-b"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"snap-a", "snap-b"})
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	c.Check(tasks[0].Summary(), check.Equals, `Run service command "start" for services "svc1" of snap "snap-a"`)
+	c.Check(tasks[1].Summary(), check.Equals, `Run service command "start" for services "svc3" of snap "snap-b"`)
+}
+
+func (s *appSuite) mockSecurityLabel(label string) (restore func()) {
+	old := procSecurityLabel
+	procSecurityLabel = func(pid int32) (string, error) {
+		if pid != 100 {
+			return "", fmt.Errorf("no such process %d", pid)
+		}
+		return label, nil
+	}
+	return func() { procSecurityLabel = old }
+}
+
+func (s *appSuite) runSnapctl(c *check.C, args string) *resp {
+	buf := bytes.NewBufferString(fmt.Sprintf(`{"args": %s}`, args))
+	req, err := http.NewRequest("POST", "/v2/snapctl", buf)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "uid=1000;pid=100;"
+
+	return runSnapctl(snapctlCmd, req, nil).(*resp)
+}
+
+func (s *appSuite) TestSnapctlFromApp(c *check.C) {
+	restore := s.mockSecurityLabel("snap.snap-a.cmd1 (enforce)")
+	defer restore()
+	soon := 0
+	ensureStateSoon = func(st *state.State) {
+		soon++
+	}
+
+	rsp := s.runSnapctl(c, `["restart", "snap-a.svc1"]`)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(soon, check.Equals, 1)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Kind(), check.Equals, "service-control")
+	c.Check(chgs[0].Summary(), check.Equals, `Running service command "restart" for snap "snap-a"`)
+	tasks := chgs[0].Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Summary(), check.Equals, `Run service command "restart" for services "svc1" of snap "snap-a"`)
+}
+
+func (s *appSuite) TestSnapctlFromAppOtherSnap(c *check.C) {
+	restore := s.mockSecurityLabel("snap.snap-b.hook.configure (enforce)")
+	defer restore()
+
+	rsp := s.runSnapctl(c, `["restart", "snap-a.svc1"]`)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, `error running snapctl: cannot restart "snap-a.svc1": not a service of snap "snap-b"`)
+}
+
+func (s *appSuite) TestSnapctlFromAppExitCode(c *check.C) {
+	restore := s.mockSecurityLabel("snap.snap-d.cmd (enforce)")
+	defer restore()
+	s.mkInstalledInState(c, s.d, "snap-d", "bar", "v1", snap.R(1), true, `plugs: {myplug: {interface: network}}
+apps: {cmd: {}}`)
+
+	rsp := s.runSnapctl(c, `["is-connected", "myplug"]`)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.DeepEquals, map[string]interface{}{
+		"stdout":    "",
+		"stderr":    "",
+		"exit-code": 1,
+	})
+}
+
+func (s *appSuite) TestSnapctlSnapName(c *check.C) {
+	for _, t := range []struct {
+		label    string
+		snapName string
+		err      string
+	}{
+		{"snap.snap-a.cmd1 (enforce)", "snap-a", ""},
+		{"snap.snap-a.hook.configure (complain)", "snap-a", ""},
+		{"snap.snap-a.cmd1", "snap-a", ""},
+		{"unconfined", "", "process 100 does not belong to a snap"},
+		{"/usr/bin/foo (enforce)", "", "process 100 does not belong to a snap"},
+	} {
+		restore := s.mockSecurityLabel(t.label)
+		snapName, err := snapctlSnapName("uid=1000;pid=100;")
+		restore()
+		if t.err != "" {
+			c.Check(err, check.ErrorMatches, t.err, check.Commentf(t.label))
+			continue
+		}
+		c.Check(err, check.IsNil, check.Commentf(t.label))
+		c.Check(snapName, check.Equals, t.snapName)
+	}
+
+	_, err := snapctlSnapName("uid=1000;")
+	c.Check(err, check.Equals, errNoPID)
+}
+
+func (s *appSuite) TestPostAppsErrors(c *check.C) {
+	for _, t := range []struct {
+		body   string
+		status int
+		error  string
+	}{
+		{`{"action": "start"}`, http.StatusBadRequest, `cannot perform operation on services without a list of services to operate on`},
+		{`{"action": "start", "names": ["snap-c"]}`, http.StatusNotFound, `snap "snap-c" has no services`},
+		{`{"action": "potato", "names": ["snap-a"]}`, http.StatusBadRequest, `cannot potato services: unknown action "potato"`},
+		{`{"action": "restart", "names": ["snap-a"], "disable": true}`, http.StatusBadRequest, `cannot restart services: restart does not support the disable option`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/apps", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+
+		rsp := postApps(appsCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, t.status, check.Commentf(t.body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Equals, t.error)
+	}
+}
+
+func (s *appSuite) TestLogs(c *check.C) {
+	s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(`
+{"MESSAGE": "hello1", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "42"}
+{"MESSAGE": "hello2", "SYSLOG_IDENTIFIER": "xyzzy", "_PID": "42", "__REALTIME_TIMESTAMP": "44"}
+`))}
+
+	req, err := http.NewRequest("GET", "/v2/logs?names=snap-a.svc2&n=42&follow=false", nil)
+	c.Assert(err, check.IsNil)
+
+	rec := httptest.NewRecorder()
+	getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+
+	c.Check(s.jctlSvcs, check.DeepEquals, [][]string{{"snap.snap-a.svc2.service"}})
+	c.Check(s.jctlNs, check.DeepEquals, []string{"42"})
+	c.Check(s.jctlFollows, check.DeepEquals, []bool{false})
+
+	c.Check(rec.Code, check.Equals, http.StatusOK)
+	c.Check(rec.HeaderMap.Get("Content-Type"), check.Equals, "application/json-seq")
+	c.Check(rec.Body.String(), check.Equals, ""+
+		"\x1e"+`{"timestamp":"1970-01-01T00:00:00.000042Z","message":"hello1","sid":"xyzzy","pid":"42"}`+"\n"+
+		"\x1e"+`{"timestamp":"1970-01-01T00:00:00.000044Z","message":"hello2","sid":"xyzzy","pid":"42"}`+"\n")
+}
+
+func (s *appSuite) TestLogsN(c *check.C) {
+	type T struct {
+		in  string
+		out string
+	}
+
+	for _, t := range []T{
+		{in: "", out: "10"},
+		{in: "0", out: "0"},
+		{in: "20", out: "20"},
+		{in: "all", out: "all"},
+	} {
+		s.jctlRCs = []io.ReadCloser{ioutil.NopCloser(strings.NewReader(""))}
+		s.jctlNs = nil
+
+		req, err := http.NewRequest("GET", "/v2/logs?n="+t.in, nil)
+		c.Assert(err, check.IsNil)
+
+		rec := httptest.NewRecorder()
+		getLogs(logsCmd, req, nil).ServeHTTP(rec, req)
+
+		c.Check(rec.Code, check.Equals, http.StatusOK)
+		c.Check(s.jctlNs, check.DeepEquals, []string{t.out})
+	}
+}
+
+func (s *appSuite) TestLogsBadRequest(c *check.C) {
+	for _, query := range []string{"n=hello", "n=-1", "follow=potato"} {
+		req, err := http.NewRequest("GET", "/v2/logs?"+query, nil)
+		c.Assert(err, check.IsNil)
+
+		rsp := getLogs(logsCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(query))
+	}
+}
+
+func (s *appSuite) TestLogsNoServices(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/logs?names=snap-c", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(s.jctlSvcs, check.HasLen, 0)
+}
+
+func (s *appSuite) TestLogsFailure(c *check.C) {
+	s.jctlErrs = []error{errors.New("oops")}
+
+	req, err := http.NewRequest("GET", "/v2/logs", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getLogs(logsCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusInternalServerError)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "cannot get logs: oops")
+}
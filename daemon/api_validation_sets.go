@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	assertstateApplyValidationSet  = assertstate.ApplyValidationSet
+	assertstateForgetValidationSet = assertstate.ForgetValidationSet
+)
+
+// validationSetResult describes a tracked validation set and whether
+// the system complies with it.
+type validationSetResult struct {
+	AccountID string `json:"account-id"`
+	Name      string `json:"name"`
+	Mode      string `json:"mode"`
+	PinnedAt  int    `json:"pinned-at,omitempty"`
+	Sequence  int    `json:"sequence"`
+	Valid     bool   `json:"valid"`
+}
+
+func validationSetResultFor(st *state.State, tr *assertstate.ValidationSetTracking) (*validationSetResult, error) {
+	vs, err := assertstate.ValidationSetAssertion(st, tr.AccountID, tr.Name, tr.Current)
+	if err != nil {
+		return nil, err
+	}
+	installed, err := assertstate.InstalledSnaps(st)
+	if err != nil {
+		return nil, err
+	}
+	sets := snapasserts.NewValidationSets()
+	sets.Add(vs)
+	return &validationSetResult{
+		AccountID: tr.AccountID,
+		Name:      tr.Name,
+		Mode:      string(tr.Mode),
+		PinnedAt:  tr.PinnedAt,
+		Sequence:  tr.Current,
+		Valid:     sets.CheckInstalledSnaps(installed) == nil,
+	}, nil
+}
+
+func listValidationSets(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	sets, err := assertstate.ValidationSets(st)
+	if err != nil {
+		return InternalError("cannot list validation sets: %v", err)
+	}
+	keys := make([]string, 0, len(sets))
+	for key := range sets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := make([]*validationSetResult, len(keys))
+	for i, key := range keys {
+		res, err := validationSetResultFor(st, sets[key])
+		if err != nil {
+			return InternalError("cannot check validation set %s: %v", key, err)
+		}
+		results[i] = res
+	}
+	return SyncResponse(results, nil)
+}
+
+func getValidationSet(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	accountID := vars["account"]
+	name := vars["name"]
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var tr assertstate.ValidationSetTracking
+	err := assertstate.GetValidationSet(st, accountID, name, &tr)
+	if err == state.ErrNoState {
+		return NotFound("validation set %s is not tracked", snapasserts.ValidationSetKey(accountID, name))
+	}
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	res, err := validationSetResultFor(st, &tr)
+	if err != nil {
+		return InternalError("cannot check validation set %s: %v", snapasserts.ValidationSetKey(accountID, name), err)
+	}
+	return SyncResponse(res, nil)
+}
+
+// validationSetAction is used to request tracking or forgetting a
+// validation set.
+type validationSetAction struct {
+	Action   string `json:"action"`
+	Mode     string `json:"mode,omitempty"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+func changeValidationSet(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	accountID := vars["account"]
+	name := vars["name"]
+
+	var action validationSetAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&action); err != nil {
+		return BadRequest("cannot decode request body into validation set action: %v", err)
+	}
+	if action.Sequence < 0 {
+		return BadRequest("validation set sequence cannot be negative")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	switch action.Action {
+	case "apply":
+		var userID int
+		if user != nil {
+			userID = user.ID
+		}
+		tr, err := assertstateApplyValidationSet(st, accountID, name, action.Sequence, assertstate.ValidationSetMode(action.Mode), userID)
+		if err != nil {
+			return BadRequest("%v", err)
+		}
+		res, err := validationSetResultFor(st, tr)
+		if err != nil {
+			return InternalError("cannot check validation set %s: %v", snapasserts.ValidationSetKey(accountID, name), err)
+		}
+		return SyncResponse(res, nil)
+	case "forget":
+		if err := assertstateForgetValidationSet(st, accountID, name); err != nil {
+			return BadRequest("%v", err)
+		}
+		return SyncResponse(nil, nil)
+	default:
+		return BadRequest("unsupported validation set action: %q", action.Action)
+	}
+}
@@ -0,0 +1,207 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type validationSetsSuite struct {
+	apiBaseSuite
+
+	restore []func()
+}
+
+var _ = check.Suite(&validationSetsSuite{})
+
+func (s *validationSetsSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.restore = append(s.restore, sysdb.InjectTrusted(s.storeSigning.Trusted))
+	d := s.daemon(c)
+	assertAdd(d.overlord.State(), s.storeSigning.StoreAccountKey(""))
+
+	oldApply, oldForget := assertstateApplyValidationSet, assertstateForgetValidationSet
+	s.restore = append(s.restore, func() {
+		assertstateApplyValidationSet, assertstateForgetValidationSet = oldApply, oldForget
+	})
+}
+
+func (s *validationSetsSuite) TearDownTest(c *check.C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	s.restore = nil
+	s.apiBaseSuite.TearDownTest(c)
+}
+
+func (s *validationSetsSuite) TestValidationSetsCmds(c *check.C) {
+	c.Check(validationSetsCmd.Path, check.Equals, "/v2/validation-sets")
+	c.Check(validationSetsCmd.UserOK, check.Equals, true)
+	c.Check(validationSetsCmd.POST, check.IsNil)
+
+	c.Check(validationSetCmd.Path, check.Equals, "/v2/validation-sets/{account}/{name}")
+	c.Check(validationSetCmd.UserOK, check.Equals, true)
+	c.Check(validationSetCmd.PUT, check.IsNil)
+	c.Check(validationSetCmd.DELETE, check.IsNil)
+}
+
+// mockValidationSet adds a validation-set by the store authority that
+// requires foo at revision 3 and monitors it.
+func (s *validationSetsSuite) mockValidationSet(c *check.C, name string) {
+	st := s.d.overlord.State()
+	a, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"authority-id": "can0nical",
+		"account-id":   "can0nical",
+		"series":       "16",
+		"name":         name,
+		"sequence":     "2",
+		"snaps": []interface{}{
+			map[string]interface{}{"name": "foo", "id": "foo-id", "revision": "3"},
+		},
+		"timestamp": time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+	assertAdd(st, a)
+
+	st.Lock()
+	defer st.Unlock()
+	_, err = assertstate.ApplyValidationSet(st, "can0nical", name, 0, assertstate.Monitor, 0)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *validationSetsSuite) TestListValidationSets(c *check.C) {
+	s.mockValidationSet(c, "one")
+	s.mockValidationSet(c, "two")
+
+	st := s.d.overlord.State()
+	st.Lock()
+	snapstate.Set(st, "foo", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "foo", SnapID: "foo-id", Revision: snap.R(3)}},
+		Current:  snap.R(3),
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/validation-sets", nil)
+	c.Assert(err, check.IsNil)
+	rsp := listValidationSets(validationSetsCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.DeepEquals, []*validationSetResult{
+		{AccountID: "can0nical", Name: "one", Mode: "monitor", Sequence: 2, Valid: true},
+		{AccountID: "can0nical", Name: "two", Mode: "monitor", Sequence: 2, Valid: true},
+	})
+}
+
+func (s *validationSetsSuite) TestGetValidationSet(c *check.C) {
+	s.mockValidationSet(c, "one")
+
+	s.vars = map[string]string{"account": "can0nical", "name": "one"}
+	req, err := http.NewRequest("GET", "/v2/validation-sets/can0nical/one", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	// foo is not installed
+	c.Check(rsp.Result, check.DeepEquals, &validationSetResult{
+		AccountID: "can0nical", Name: "one", Mode: "monitor", Sequence: 2, Valid: false,
+	})
+
+	s.vars = map[string]string{"account": "can0nical", "name": "other"}
+	rsp = getValidationSet(validationSetCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "validation set can0nical/other is not tracked")
+}
+
+func (s *validationSetsSuite) postValidationSet(c *check.C, body string, user *auth.UserState) *resp {
+	s.vars = map[string]string{"account": "can0nical", "name": "one"}
+	req, err := http.NewRequest("POST", "/v2/validation-sets/can0nical/one", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	return changeValidationSet(validationSetCmd, req, user).(*resp)
+}
+
+func (s *validationSetsSuite) TestApplyValidationSet(c *check.C) {
+	s.mockValidationSet(c, "one")
+
+	var gotSeq, gotUserID int
+	var gotMode assertstate.ValidationSetMode
+	assertstateApplyValidationSet = func(st *state.State, accountID, name string, sequence int, mode assertstate.ValidationSetMode, userID int) (*assertstate.ValidationSetTracking, error) {
+		c.Check(accountID, check.Equals, "can0nical")
+		c.Check(name, check.Equals, "one")
+		gotSeq, gotMode, gotUserID = sequence, mode, userID
+		return &assertstate.ValidationSetTracking{AccountID: accountID, Name: name, Mode: mode, PinnedAt: sequence, Current: 2}, nil
+	}
+
+	rsp := s.postValidationSet(c, `{"action": "apply", "mode": "enforce", "sequence": 2}`, &auth.UserState{ID: 42})
+	c.Assert(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(gotSeq, check.Equals, 2)
+	c.Check(gotMode, check.Equals, assertstate.Enforce)
+	c.Check(gotUserID, check.Equals, 42)
+	c.Check(rsp.Result, check.DeepEquals, &validationSetResult{
+		AccountID: "can0nical", Name: "one", Mode: "enforce", PinnedAt: 2, Sequence: 2, Valid: false,
+	})
+
+	assertstateApplyValidationSet = func(*state.State, string, string, int, assertstate.ValidationSetMode, int) (*assertstate.ValidationSetTracking, error) {
+		return nil, errors.New("boom")
+	}
+	rsp = s.postValidationSet(c, `{"action": "apply", "mode": "enforce"}`, nil)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+	c.Check(rsp.Result.(*errorResult).Message, check.Equals, "boom")
+}
+
+func (s *validationSetsSuite) TestForgetValidationSet(c *check.C) {
+	called := false
+	assertstateForgetValidationSet = func(st *state.State, accountID, name string) error {
+		c.Check(accountID, check.Equals, "can0nical")
+		c.Check(name, check.Equals, "one")
+		called = true
+		return nil
+	}
+
+	rsp := s.postValidationSet(c, `{"action": "forget"}`, nil)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(called, check.Equals, true)
+}
+
+func (s *validationSetsSuite) TestChangeValidationSetErrors(c *check.C) {
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "apply"`, `cannot decode request body into validation set action: .*`},
+		{`{"action": "apply", "sequence": -1}`, `validation set sequence cannot be negative`},
+		{`{"action": "frobble"}`, `unsupported validation set action: "frobble"`},
+	} {
+		rsp := s.postValidationSet(c, t.body, nil)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(t.body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err, check.Commentf(t.body))
+	}
+}
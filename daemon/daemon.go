@@ -289,10 +289,12 @@ func New() (*Daemon, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Daemon{
+	d := &Daemon{
 		overlord: ovld,
 		hub:      notifications.NewHub(),
 		// TODO: Decide when this should be disabled by default.
 		enableInternalInterfaceActions: true,
-	}, nil
+	}
+	publishStateEvents(ovld.State(), d.hub)
+	return d, nil
 }
@@ -113,6 +113,39 @@ func (s *daemonSuite) TestCommandMethodDispatch(c *check.C) {
 	c.Check(rec.Code, check.Equals, http.StatusMethodNotAllowed)
 }
 
+func (s *daemonSuite) TestCommandRecordsRequesterOfChanges(c *check.C) {
+	d := newTestDaemon(c)
+	st := d.overlord.State()
+
+	var chg *state.Change
+	cmd := &Command{d: d, UserOK: true}
+	cmd.POST = func(c *Command, r *http.Request, user *auth.UserState) Response {
+		st.Lock()
+		defer st.Unlock()
+		chg = newRequestChange(st, r, "foo", "...")
+		// the change can be ready, and audited, right away
+		chg.SetStatus(state.DoneStatus)
+		var uid uint32
+		if err := chg.Get("uid", &uid); err != nil {
+			return InternalError("no uid: %v", err)
+		}
+		return AsyncResponse(nil, &Meta{Change: chg.ID()})
+	}
+
+	req, err := http.NewRequest("POST", "", nil)
+	c.Assert(err, check.IsNil)
+	req.RemoteAddr = "uid=0;pid=100;" + req.RemoteAddr
+	rec := httptest.NewRecorder()
+	cmd.ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, http.StatusAccepted)
+
+	st.Lock()
+	defer st.Unlock()
+	var uid uint32
+	c.Assert(chg.Get("uid", &uid), check.IsNil)
+	c.Check(uid, check.Equals, uint32(0))
+}
+
 func (s *daemonSuite) TestGuestAccess(c *check.C) {
 	get := &http.Request{Method: "GET"}
 	put := &http.Request{Method: "PUT"}
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"time"
+
+	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// progressEventInterval is the minimum interval between the
+// task-progress events published for a task, which would otherwise be
+// one per chunk downloaded.
+var progressEventInterval = 200 * time.Millisecond
+
+// stateEventPublisher publishes the status and progress transitions
+// of changes and tasks to the notifications hub.
+type stateEventPublisher struct {
+	hub *notifications.Hub
+	// lastProgress is when the last task-progress event of each task
+	// doing something was published; only used with the state locked
+	lastProgress map[string]time.Time
+}
+
+func publishStateEvents(st *state.State, hub *notifications.Hub) {
+	p := &stateEventPublisher{
+		hub:          hub,
+		lastProgress: make(map[string]time.Time),
+	}
+	st.Lock()
+	defer st.Unlock()
+	st.AddChangeStatusHandler(p.changeStatusChanged)
+	st.AddTaskStatusHandler(p.taskStatusChanged)
+	st.AddTaskProgressHandler(p.taskProgressChanged)
+}
+
+func (p *stateEventPublisher) publish(typ string, chg *state.Change, metadata map[string]interface{}) {
+	n := &notifications.Notification{
+		Timestamp: time.Now().UnixNano(),
+		Type:      typ,
+		Metadata:  metadata,
+	}
+	if chg != nil {
+		n.Resource = "/v2/changes/" + chg.ID()
+		metadata["change-id"] = chg.ID()
+		metadata["change-kind"] = chg.Kind()
+	}
+	p.hub.Publish(n)
+}
+
+func (p *stateEventPublisher) changeStatusChanged(chg *state.Change, old, new state.Status) {
+	p.publish("change-status", chg, map[string]interface{}{
+		"status": new.String(),
+		"ready":  new.Ready(),
+	})
+}
+
+func taskMetadata(t *state.Task) map[string]interface{} {
+	label, done, total := t.Progress()
+	metadata := map[string]interface{}{
+		"task-id":   t.ID(),
+		"task-kind": t.Kind(),
+		"summary":   t.Summary(),
+		"status":    t.Status().String(),
+		"progress": taskInfoProgress{
+			Label: label,
+			Done:  done,
+			Total: total,
+		},
+	}
+	if log := t.Log(); len(log) > 0 {
+		metadata["log"] = log[len(log)-1]
+	}
+	return metadata
+}
+
+func (p *stateEventPublisher) taskStatusChanged(t *state.Task, old, new state.Status) {
+	if new != state.DoingStatus && new != state.UndoingStatus {
+		delete(p.lastProgress, t.ID())
+	}
+	p.publish("task-status", t.Change(), taskMetadata(t))
+}
+
+func (p *stateEventPublisher) taskProgressChanged(t *state.Task) {
+	_, done, total := t.Progress()
+	now := time.Now()
+	if done < total && now.Sub(p.lastProgress[t.ID()]) < progressEventInterval {
+		return
+	}
+	p.lastProgress[t.ID()] = now
+	p.publish("task-progress", t.Change(), taskMetadata(t))
+}
@@ -20,18 +20,26 @@
 package daemon
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/notifications"
+	"github.com/snapcore/snapd/systemd"
 )
 
 // ResponseType is the response type
@@ -127,6 +135,12 @@ const (
 	errorKindTermsNotAccepted  = errorKind("terms-not-accepted")
 	errorKindNoPaymentMethods  = errorKind("no-payment-methods")
 	errorKindPaymentDeclined   = errorKind("payment-declined")
+
+	errorKindHookTimeout = errorKind("hook-timeout")
+
+	errorKindChangeConflict = errorKind("change-conflict")
+
+	errorKindSnapNotFound = errorKind("snap-not-found")
 )
 
 type errorValue interface{}
@@ -182,6 +196,18 @@ func makeErrorResponder(status int) errorResponder {
 	}
 }
 
+// SnapNotFound is an error responder used when a snap cannot be found.
+func SnapNotFound(format string, v ...interface{}) Response {
+	return &resp{
+		Type: ResponseTypeError,
+		Result: &errorResult{
+			Message: fmt.Sprintf(format, v...),
+			Kind:    errorKindSnapNotFound,
+		},
+		Status: http.StatusNotFound,
+	}
+}
+
 // A FileResponse 's ServeHTTP method serves the file
 type FileResponse string
 
@@ -229,12 +255,19 @@ type eventResponse struct {
 }
 
 // EventResponse returns a response whose ServerHTTP method creates a websocket
-// connection used to communicate operation and logging notifications.
+// connection used to communicate operation and logging notifications, or
+// streams them as an RFC7464 json sequence if the request doesn't ask for a
+// websocket.
 func EventResponse(hub *notifications.Hub) Response {
 	return &eventResponse{h: hub}
 }
 
 func (e eventResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		e.serveSeq(w, r)
+		return
+	}
+
 	upgrader := websocket.Upgrader{}
 
 	c, err := upgrader.Upgrade(w, r, nil)
@@ -243,8 +276,175 @@ func (e eventResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s := notifications.NewSubscriber(c, r)
+	conn := newEventQueue()
+	s := notifications.NewSubscriber(conn, r)
+	e.h.Subscribe(s)
+	go func() {
+		defer c.Close()
+		defer e.h.Unsubscribe(s)
+		for {
+			select {
+			case data := <-conn.queue:
+				c.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+				if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
+					return
+				}
+			case <-conn.closed:
+				// unsubscribed for not keeping up
+				return
+			}
+		}
+	}()
+}
+
+// eventQueueSize is how many notifications can be waiting to be
+// streamed to a client before it's considered too slow and dropped.
+var eventQueueSize = 100
+
+// eventWriteTimeout is how long writing a notification to a websocket
+// client can take before the client is dropped.
+var eventWriteTimeout = 10 * time.Second
+
+var errEventQueueFull = errors.New("event queue full")
+
+// eventQueue queues the notifications for a client, so that publishing
+// them, which happens with the state locked, doesn't wait on the client.
+type eventQueue struct {
+	queue  chan []byte
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		queue:  make(chan []byte, eventQueueSize),
+		closed: make(chan struct{}),
+	}
+}
+
+func (eq *eventQueue) WriteMessage(messageType int, data []byte) error {
+	select {
+	case eq.queue <- data:
+		return nil
+	default:
+		return errEventQueueFull
+	}
+}
+
+func (eq *eventQueue) Close() error {
+	eq.once.Do(func() { close(eq.closed) })
+	return nil
+}
+
+func (e eventResponse) serveSeq(w http.ResponseWriter, r *http.Request) {
+	conn := newEventQueue()
+	s := notifications.NewSubscriber(conn, r)
 	e.h.Subscribe(s)
+	defer e.h.Unsubscribe(s)
+
+	w.Header().Set("Content-Type", "application/json-seq")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	var gone <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		gone = notifier.CloseNotify()
+	}
+
+	for {
+		select {
+		case data := <-conn.queue:
+			// RFC7464 record separator
+			if _, err := w.Write([]byte{0x1E}); err != nil {
+				return
+			}
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-conn.closed:
+			// unsubscribed for not keeping up
+			return
+		case <-gone:
+			return
+		}
+	}
+}
+
+// logJSON contains the json for a systemd.Log entry
+type logJSON struct {
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+	SID       string `json:"sid"`
+	PID       string `json:"pid"`
+}
+
+// journalLineReaderSeqResponse reads journald log entries in json
+// format, one per line, and serves them as an RFC7464 json sequence.
+type journalLineReaderSeqResponse struct {
+	io.ReadCloser
+}
+
+func (rr *journalLineReaderSeqResponse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json-seq")
+	w.WriteHeader(http.StatusOK)
+
+	var once sync.Once
+	closeReader := func() { once.Do(func() { rr.Close() }) }
+	defer closeReader()
+
+	flusher, _ := w.(http.Flusher)
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		done := make(chan struct{})
+		defer close(done)
+		closed := notifier.CloseNotify()
+		go func() {
+			select {
+			case <-closed:
+				// the client went away; unblock the reader
+				closeReader()
+			case <-done:
+			}
+		}()
+	}
+
+	buf := bufio.NewReader(rr)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := buf.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 && line[0] == '{' {
+			var log systemd.Log
+			if err := json.Unmarshal(line, &log); err != nil {
+				logger.Noticef("cannot decode systemd log entry: %v", err)
+				return
+			}
+			// RFC7464 record separator
+			if _, err := w.Write([]byte{0x1E}); err != nil {
+				return
+			}
+			if err := enc.Encode(logJSON{
+				Timestamp: log.Timestamp(),
+				Message:   log.Message(),
+				SID:       log.SID(),
+				PID:       log.PID(),
+			}); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				logger.Noticef("cannot read systemd log: %v", err)
+			}
+			return
+		}
+	}
 }
 
 // errorResponder is a callable that produces an error Response.
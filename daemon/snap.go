@@ -24,11 +24,16 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var errNoSnap = errors.New("no snap installed")
@@ -110,7 +115,160 @@ func allLocalSnapInfos(st *state.State) ([]aboutSnap, error) {
 
 // appJSON contains the json for snap.AppInfo
 type appJSON struct {
-	Name string `json:"name"`
+	Snap    string `json:"snap,omitempty"`
+	Name    string `json:"name"`
+	Daemon  string `json:"daemon,omitempty"`
+	Enabled bool   `json:"enabled,omitempty"`
+	Active  bool   `json:"active,omitempty"`
+}
+
+type byAppName []*snap.AppInfo
+
+func (a byAppName) Len() int      { return len(a) }
+func (a byAppName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool {
+	iName := a[i].Snap.InstanceName()
+	jName := a[j].Snap.InstanceName()
+	if iName == jName {
+		return a[i].Name < a[j].Name
+	}
+	return iName < jName
+}
+
+type appInfoOptions struct {
+	service bool
+}
+
+func (opts appInfoOptions) String() string {
+	if opts.service {
+		return "service"
+	}
+
+	return "app"
+}
+
+// appInfosFor returns a sorted list of the apps described by names.
+//
+// If names is empty, all apps of the wanted kind are returned (which
+// could be an empty list). An element of names can be a snap name, in
+// which case all apps of the wanted kind from that snap are included (and
+// it's an error if the snap has none), or snap.app, in which case that app
+// is included (and it's an error if the snap and app don't both exist, or
+// if the app is not of the wanted kind).
+//
+// On error an appropriate error Response is returned; a nil Response means
+// no error.
+func appInfosFor(st *state.State, names []string, opts appInfoOptions) ([]*snap.AppInfo, Response) {
+	snapNames := make(map[string]bool)
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[name] = true
+		name, _ = snap.SplitSnapApp(name)
+		snapNames[name] = true
+	}
+
+	snaps, err := allLocalSnapInfos(st)
+	if err != nil {
+		return nil, InternalError("cannot list local snaps! %v", err)
+	}
+
+	installed := make(map[string]bool)
+	found := make(map[string]bool)
+	appInfos := make([]*snap.AppInfo, 0, len(requested))
+	for _, snp := range snaps {
+		snapName := snp.info.InstanceName()
+		if len(requested) != 0 && !snapNames[snapName] {
+			continue
+		}
+		installed[snapName] = true
+
+		includeAll := len(requested) == 0 || requested[snapName]
+		for _, app := range snp.info.Apps {
+			appName := snapName + "." + app.Name
+			if !includeAll && !requested[appName] {
+				continue
+			}
+			if opts.service && app.Daemon == "" {
+				continue
+			}
+			appInfos = append(appInfos, app)
+			found[snapName] = true
+			found[appName] = true
+		}
+	}
+
+	for k := range requested {
+		if found[k] {
+			continue
+		}
+		snapName, appName := snap.SplitSnapApp(k)
+		if !installed[snapName] {
+			return nil, NotFound("snap %q not found", snapName)
+		}
+		if snapName == k {
+			return nil, NotFound("snap %q has no %ss", snapName, opts)
+		}
+		return nil, NotFound("snap %q has no %s %q", snapName, opts, appName)
+	}
+
+	sort.Sort(byAppName(appInfos))
+
+	return appInfos, nil
+}
+
+// mapApps returns the json for the given apps, including the current
+// status of any services among them.
+func mapApps(apps []*snap.AppInfo) ([]appJSON, error) {
+	sysd := systemd.New(dirs.GlobalRootDir, &progress.NullProgress{})
+
+	out := make([]appJSON, len(apps))
+	for i, app := range apps {
+		out[i] = appJSON{
+			Snap: app.Snap.InstanceName(),
+			Name: app.Name,
+		}
+		if app.Daemon == "" {
+			continue
+		}
+
+		// TODO: look into making a single call to Status for all services
+		status, err := sysd.ServiceStatus(filepath.Base(app.ServiceFile()))
+		if err != nil {
+			return nil, err
+		}
+		out[i].Daemon = app.Daemon
+		out[i].Enabled = status.UnitFileState == "enabled"
+		out[i].Active = status.ActiveState == "active"
+	}
+
+	return out, nil
+}
+
+// serviceNames returns the systemd unit names of the given apps.
+func serviceNames(apps []*snap.AppInfo) []string {
+	names := make([]string, 0, len(apps))
+	for _, app := range apps {
+		if app.Daemon == "" {
+			continue
+		}
+		names = append(names, filepath.Base(app.ServiceFile()))
+	}
+	return names
+}
+
+// splitQS takes a string with a comma-separated list, and returns a
+// slice of the non-empty, whitespace-trimmed elements.
+func splitQS(qs string) []string {
+	qsl := strings.Split(qs, ",")
+	split := make([]string, 0, len(qsl))
+	for _, elem := range qsl {
+		elem = strings.TrimSpace(elem)
+		if len(elem) > 0 {
+			split = append(split, elem)
+		}
+	}
+
+	return split
 }
 
 // screenshotJSON contains the json for snap.ScreenshotInfo
@@ -129,30 +287,33 @@ func mapLocal(localSnap *snap.Info, snapst *snapstate.SnapState) map[string]inte
 	apps := make([]appJSON, 0, len(localSnap.Apps))
 	for _, app := range localSnap.Apps {
 		apps = append(apps, appJSON{
-			Name: app.Name,
+			Name:   app.Name,
+			Daemon: app.Daemon,
 		})
 	}
 
 	return map[string]interface{}{
-		"description":    localSnap.Description(),
-		"developer":      localSnap.Developer,
-		"icon":           snapIcon(localSnap),
-		"id":             localSnap.SnapID,
-		"install-date":   snapDate(localSnap),
-		"installed-size": localSnap.Size,
-		"name":           localSnap.Name(),
-		"revision":       localSnap.Revision,
-		"status":         status,
-		"summary":        localSnap.Summary(),
-		"type":           string(localSnap.Type),
-		"version":        localSnap.Version,
-		"channel":        localSnap.Channel,
-		"confinement":    localSnap.Confinement,
-		"devmode":        snapst.DevMode,
-		"trymode":        snapst.TryMode,
-		"private":        localSnap.Private,
-		"apps":           apps,
-		"broken":         localSnap.Broken,
+		"description":      localSnap.Description(),
+		"developer":        localSnap.Developer,
+		"icon":             snapIcon(localSnap),
+		"id":               localSnap.SnapID,
+		"install-date":     snapDate(localSnap),
+		"installed-size":   localSnap.Size,
+		"name":             localSnap.InstanceName(),
+		"revision":         localSnap.Revision,
+		"status":           status,
+		"summary":          localSnap.Summary(),
+		"type":             string(localSnap.Type),
+		"version":          localSnap.Version,
+		"channel":          localSnap.Channel,
+		"confinement":      localSnap.Confinement,
+		"devmode":          snapst.DevMode,
+		"jailmode":         snapst.JailMode,
+		"trymode":          snapst.TryMode,
+		"tracking-channel": snapst.Channel,
+		"private":          localSnap.Private,
+		"apps":             apps,
+		"broken":           localSnap.Broken,
 	}
 }
 
@@ -200,5 +361,21 @@ func mapRemote(remoteSnap *snap.Info) map[string]interface{} {
 	if len(remoteSnap.Prices) > 0 {
 		result["prices"] = remoteSnap.Prices
 	}
+
+	if remoteSnap.Publisher != "" {
+		result["publisher"] = remoteSnap.Publisher
+	}
+	if remoteSnap.License != "" {
+		result["license"] = remoteSnap.License
+	}
+	if remoteSnap.Contact != "" {
+		result["contact"] = remoteSnap.Contact
+	}
+
+	if len(remoteSnap.Channels) > 0 {
+		result["channels"] = remoteSnap.Channels
+		result["tracks"] = remoteSnap.Tracks
+	}
+
 	return result
 }
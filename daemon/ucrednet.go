@@ -29,6 +29,7 @@ import (
 )
 
 var errNoUID = errors.New("no uid found")
+var errNoPID = errors.New("no pid found")
 
 const ucrednetNobody = uint32((1 << 32) - 1)
 
@@ -46,22 +47,44 @@ func ucrednetGetUID(remoteAddr string) (uint32, error) {
 	return uint32(uid), nil
 }
 
+func ucrednetGetPID(remoteAddr string) (int32, error) {
+	idx := strings.Index(remoteAddr, ";pid=")
+	if idx < 0 {
+		return 0, errNoPID
+	}
+	pidStr := remoteAddr[idx+5:]
+	if end := strings.IndexByte(pidStr, ';'); end >= 0 {
+		pidStr = pidStr[:end]
+	}
+	if pidStr == "" {
+		return 0, errNoPID
+	}
+
+	pid, err := strconv.ParseInt(pidStr, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return int32(pid), nil
+}
+
 type ucrednetAddr struct {
 	net.Addr
 	uid string
+	pid string
 }
 
 func (wa *ucrednetAddr) String() string {
-	return fmt.Sprintf("uid=%s;%s", wa.uid, wa.Addr)
+	return fmt.Sprintf("uid=%s;pid=%s;%s", wa.uid, wa.pid, wa.Addr)
 }
 
 type ucrednetConn struct {
 	net.Conn
 	uid string
+	pid string
 }
 
 func (wc *ucrednetConn) RemoteAddr() net.Addr {
-	return &ucrednetAddr{wc.Conn.RemoteAddr(), wc.uid}
+	return &ucrednetAddr{wc.Conn.RemoteAddr(), wc.uid, wc.pid}
 }
 
 type ucrednetListener struct{ net.Listener }
@@ -74,7 +97,7 @@ func (wl *ucrednetListener) Accept() (net.Conn, error) {
 		return nil, err
 	}
 
-	uid := ""
+	uid, pid := "", ""
 	if ucon, ok := con.(*net.UnixConn); ok {
 		f, err := ucon.File()
 		if err != nil {
@@ -87,7 +110,8 @@ func (wl *ucrednetListener) Accept() (net.Conn, error) {
 		}
 
 		uid = strconv.FormatUint(uint64(ucred.Uid), 10)
+		pid = strconv.FormatInt(int64(ucred.Pid), 10)
 	}
 
-	return &ucrednetConn{con, uid}, err
+	return &ucrednetConn{con, uid, pid}, err
 }
@@ -54,7 +54,11 @@ var (
 	SnapTrustedAccountKey string
 	SnapAssertsSpoolDir   string
 
-	SnapStateFile string
+	SnapStateFile        string
+	SnapStateJournalFile string
+	SnapAuditLogFile     string
+
+	SnapshotsDir string
 
 	SnapBinariesDir     string
 	SnapServicesDir     string
@@ -126,6 +130,10 @@ func SetRootDir(rootdir string) {
 	SnapAssertsSpoolDir = filepath.Join(rootdir, "run/snapd/auto-import")
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
+	SnapStateJournalFile = filepath.Join(rootdir, snappyDir, "state.journal")
+	SnapAuditLogFile = filepath.Join(rootdir, snappyDir, "audit.log")
+
+	SnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
 
 	SnapSeedDir = filepath.Join(rootdir, snappyDir, "seed")
 	SnapDeviceDir = filepath.Join(rootdir, snappyDir, "device")
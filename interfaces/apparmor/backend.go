@@ -66,7 +66,7 @@ func (b *Backend) Name() string {
 // This method should be called after changing plug, slots, connections between
 // them or application present in the snap.
 func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 	// Get the snippets that apply to this snap
 	snippets, err := repo.SecuritySnippetsForSnap(snapName, interfaces.SecurityAppArmor)
 	if err != nil {
@@ -77,7 +77,7 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, r
 	if err != nil {
 		return fmt.Errorf("cannot obtain expected security files for snap %q: %s", snapName, err)
 	}
-	glob := interfaces.SecurityTagGlob(snapInfo.Name())
+	glob := interfaces.SecurityTagGlob(snapInfo.InstanceName())
 	dir := dirs.SnapAppArmorDir
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("cannot create directory for apparmor profiles %q: %s", dir, err)
@@ -309,6 +309,7 @@ type combineSnippetsScenario struct {
 
 const commonPrefix = `
 @{SNAP_NAME}="samba"
+@{SNAP_INSTANCE_NAME}="samba"
 @{SNAP_REVISION}="1"
 @{INSTALL_DIR}="/snap"`
 
@@ -55,6 +55,11 @@ import (
 //
 // The Classic flag switches the layout of the mount namespace so that there's
 // no "chroot" to the core snap.
+//
+// Snaps declaring a base get that base snap, instead of the core snap, as
+// the root filesystem of the "strict" and "devmode" layouts; Base carries its
+// name. snap-confine is handed the base with --base and builds the root
+// filesystem from it, the mount profiles don't mention it.
 type ConfinementOptions struct {
 	// DevMode flag switches confinement to non-enforcing mode.
 	DevMode bool
@@ -62,6 +67,19 @@ type ConfinementOptions struct {
 	JailMode bool
 	// Classic flag switches the core snap "chroot" off.
 	Classic bool
+	// Base is the name of the base snap used as the root filesystem,
+	// empty for the core snap.
+	Base string
+}
+
+// SnapConfinementOptions returns the confinement options for the given
+// snap under the given confinement type.
+func SnapConfinementOptions(snapInfo *snap.Info, confinement snap.ConfinementType) ConfinementOptions {
+	return ConfinementOptions{
+		DevMode: confinement == snap.DevmodeConfinement,
+		Classic: confinement == snap.ClassicConfinement,
+		Base:    snapInfo.Base,
+	}
 }
 
 // SecurityBackend abstracts interactions between the interface system and the
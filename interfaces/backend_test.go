@@ -0,0 +1,39 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package interfaces_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	. "github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/snap"
+)
+
+type BackendSuite struct{}
+
+var _ = Suite(&BackendSuite{})
+
+func (s *BackendSuite) TestSnapConfinementOptions(c *C) {
+	info := &snap.Info{Base: "some-base"}
+	c.Check(SnapConfinementOptions(info, snap.StrictConfinement), Equals, ConfinementOptions{Base: "some-base"})
+	c.Check(SnapConfinementOptions(info, snap.DevmodeConfinement), Equals, ConfinementOptions{DevMode: true, Base: "some-base"})
+	c.Check(SnapConfinementOptions(info, snap.ClassicConfinement), Equals, ConfinementOptions{Classic: true, Base: "some-base"})
+	c.Check(SnapConfinementOptions(&snap.Info{}, snap.StrictConfinement), Equals, ConfinementOptions{})
+}
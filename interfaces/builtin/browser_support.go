@@ -63,7 +63,7 @@ const browserSupportConnectedPlugAppArmorWithoutSandbox = `
 # away this dangerous access frivolously. We may conditionally deny this in the
 # future. If the kernel has https://lkml.org/lkml/2016/5/26/354 we could also
 # allow this.
-deny ptrace (trace) peer=snap.@{SNAP_NAME}.**,
+deny ptrace (trace) peer=snap.@{SNAP_INSTANCE_NAME}.**,
 `
 
 const browserSupportConnectedPlugAppArmorWithSandbox = `
@@ -138,18 +138,18 @@ unix (bind)
 
 # Policy needed only when using the chrome/chromium setuid sandbox
 capability sys_ptrace,
-ptrace (trace) peer=snap.@{SNAP_NAME}.**,
-unix (receive, send) peer=(label=snap.@{SNAP_NAME}.**),
+ptrace (trace) peer=snap.@{SNAP_INSTANCE_NAME}.**,
+unix (receive, send) peer=(label=snap.@{SNAP_INSTANCE_NAME}.**),
 
 # If this were going to be allowed to all snaps, then for all the following
 # rules we would want to wrap in a 'browser_sandbox' profile, but a limitation
 # in AppArmor profile transitions prevents this.
 #
-# @{INSTALL_DIR}/@{SNAP_NAME}/@{SNAP_REVISION}/opt/google/chrome{,-beta,-unstable}/chrome-sandbox cx -> browser_sandbox,
+# @{INSTALL_DIR}/@{SNAP_INSTANCE_NAME}/@{SNAP_REVISION}/opt/google/chrome{,-beta,-unstable}/chrome-sandbox cx -> browser_sandbox,
 # profile browser_sandbox {
 #   ...
 #   # This rule needs to work but generates a parser error
-#   @{INSTALL_DIR}/@{SNAP_NAME}/@{SNAP_REVISION}/opt/google/chrome/chrome px -> snap.@{SNAP_NAME}.@{SNAP_APP},
+#   @{INSTALL_DIR}/@{SNAP_INSTANCE_NAME}/@{SNAP_REVISION}/opt/google/chrome/chrome px -> snap.@{SNAP_INSTANCE_NAME}.@{SNAP_APP},
 #   ...
 # }
 
@@ -75,6 +75,12 @@ func (iface *ContentInterface) SanitizePlug(plug *interfaces.Plug) error {
 	if !cleanSubPath(target) {
 		return fmt.Errorf("content interface target path is not clean: %q", target)
 	}
+	if provider, ok := plug.Attrs["default-provider"]; ok {
+		name, ok := provider.(string)
+		if !ok || snap.ValidateName(strings.SplitN(name, ":", 2)[0]) != nil {
+			return fmt.Errorf("content plug default-provider is not a valid snap name: %v", provider)
+		}
+	}
 
 	return nil
 }
@@ -20,6 +20,8 @@
 package builtin_test
 
 import (
+	"fmt"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
@@ -139,6 +141,27 @@ plugs:
 	c.Assert(err, ErrorMatches, "content interface target path is not clean:.*")
 }
 
+func (s *ContentSuite) TestSanitizePlugDefaultProvider(c *C) {
+	const mockSnapYaml = `name: content-plug-snap
+version: 1.0
+plugs:
+ content-plug:
+  interface: content
+  target: import
+  default-provider: %s
+`
+	for _, provider := range []string{"provider-snap", "provider-snap:slot"} {
+		info := snaptest.MockInfo(c, fmt.Sprintf(mockSnapYaml, provider), nil)
+		plug := &interfaces.Plug{PlugInfo: info.Plugs["content-plug"]}
+		c.Check(s.iface.SanitizePlug(plug), IsNil)
+	}
+	for _, provider := range []string{"provider.snap", "[1, 2]"} {
+		info := snaptest.MockInfo(c, fmt.Sprintf(mockSnapYaml, provider), nil)
+		plug := &interfaces.Plug{PlugInfo: info.Plugs["content-plug"]}
+		c.Check(s.iface.SanitizePlug(plug), ErrorMatches, "content plug default-provider is not a valid snap name: .*")
+	}
+}
+
 func (s *ContentSuite) TestResolveSpecialVariable(c *C) {
 	info := snaptest.MockInfo(c, "name: name", &snap.SideInfo{Revision: snap.R(42)})
 	c.Check(builtin.ResolveSpecialVariable("foo", info), Equals, "/snap/name/42/foo")
@@ -34,7 +34,7 @@ type Plug struct {
 
 // Ref returns reference to a plug
 func (plug *Plug) Ref() PlugRef {
-	return PlugRef{Snap: plug.Snap.Name(), Name: plug.Name}
+	return PlugRef{Snap: plug.Snap.InstanceName(), Name: plug.Name}
 }
 
 // PlugRef is a reference to a plug.
@@ -51,7 +51,7 @@ type Slot struct {
 
 // Ref returns reference to a slot
 func (slot *Slot) Ref() SlotRef {
-	return SlotRef{Snap: slot.Snap.Name(), Name: slot.Name}
+	return SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name}
 }
 
 // SlotRef is a reference to a slot.
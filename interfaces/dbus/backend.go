@@ -50,9 +50,9 @@ func (b *Backend) Name() string {
 //
 // DBus has no concept of a complain mode so confinment type is ignored.
 func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 	// Get the snippets that apply to this snap
-	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.Name(), interfaces.SecurityDBus)
+	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.InstanceName(), interfaces.SecurityDBus)
 	if err != nil {
 		return fmt.Errorf("cannot obtain DBus security snippets for snap %q: %s", snapName, err)
 	}
@@ -123,7 +123,7 @@ func (r *Repository) AddPlug(plug *Plug) error {
 	defer r.m.Unlock()
 
 	// Reject snaps with invalid names
-	if err := snap.ValidateName(plug.Snap.Name()); err != nil {
+	if err := snap.ValidateInstanceName(plug.Snap.InstanceName()); err != nil {
 		return err
 	}
 	// Reject plug with invalid names
@@ -138,13 +138,13 @@ func (r *Repository) AddPlug(plug *Plug) error {
 	if err := i.SanitizePlug(plug); err != nil {
 		return fmt.Errorf("cannot add plug: %v", err)
 	}
-	if _, ok := r.plugs[plug.Snap.Name()][plug.Name]; ok {
-		return fmt.Errorf("cannot add plug, snap %q already has plug %q", plug.Snap.Name(), plug.Name)
+	if _, ok := r.plugs[plug.Snap.InstanceName()][plug.Name]; ok {
+		return fmt.Errorf("cannot add plug, snap %q already has plug %q", plug.Snap.InstanceName(), plug.Name)
 	}
-	if r.plugs[plug.Snap.Name()] == nil {
-		r.plugs[plug.Snap.Name()] = make(map[string]*Plug)
+	if r.plugs[plug.Snap.InstanceName()] == nil {
+		r.plugs[plug.Snap.InstanceName()] = make(map[string]*Plug)
 	}
-	r.plugs[plug.Snap.Name()][plug.Name] = plug
+	r.plugs[plug.Snap.InstanceName()][plug.Name] = plug
 	return nil
 }
 
@@ -217,7 +217,7 @@ func (r *Repository) AddSlot(slot *Slot) error {
 	defer r.m.Unlock()
 
 	// Reject snaps with invalid names
-	if err := snap.ValidateName(slot.Snap.Name()); err != nil {
+	if err := snap.ValidateInstanceName(slot.Snap.InstanceName()); err != nil {
 		return err
 	}
 	// Reject plug with invalid names
@@ -232,13 +232,13 @@ func (r *Repository) AddSlot(slot *Slot) error {
 	if err := i.SanitizeSlot(slot); err != nil {
 		return fmt.Errorf("cannot add slot: %v", err)
 	}
-	if _, ok := r.slots[slot.Snap.Name()][slot.Name]; ok {
-		return fmt.Errorf("cannot add slot, snap %q already has slot %q", slot.Snap.Name(), slot.Name)
+	if _, ok := r.slots[slot.Snap.InstanceName()][slot.Name]; ok {
+		return fmt.Errorf("cannot add slot, snap %q already has slot %q", slot.Snap.InstanceName(), slot.Name)
 	}
-	if r.slots[slot.Snap.Name()] == nil {
-		r.slots[slot.Snap.Name()] = make(map[string]*Slot)
+	if r.slots[slot.Snap.InstanceName()] == nil {
+		r.slots[slot.Snap.InstanceName()] = make(map[string]*Slot)
 	}
-	r.slots[slot.Snap.Name()][slot.Name] = slot
+	r.slots[slot.Snap.InstanceName()][slot.Name] = slot
 	return nil
 }
 
@@ -372,8 +372,8 @@ func (r *Repository) Connect(ref ConnRef) error {
 	}
 	r.slotPlugs[slot][plug] = true
 	r.plugSlots[plug][slot] = true
-	slot.Connections = append(slot.Connections, PlugRef{plug.Snap.Name(), plug.Name})
-	plug.Connections = append(plug.Connections, SlotRef{slot.Snap.Name(), slot.Name})
+	slot.Connections = append(slot.Connections, PlugRef{plug.Snap.InstanceName(), plug.Name})
+	plug.Connections = append(plug.Connections, SlotRef{slot.Snap.InstanceName(), slot.Name})
 	return nil
 }
 
@@ -485,7 +485,7 @@ func (r *Repository) disconnect(plug *Plug, slot *Slot) {
 		delete(r.plugSlots, plug)
 	}
 	for i, plugRef := range slot.Connections {
-		if plugRef.Snap == plug.Snap.Name() && plugRef.Name == plug.Name {
+		if plugRef.Snap == plug.Snap.InstanceName() && plugRef.Name == plug.Name {
 			slot.Connections[i] = slot.Connections[len(slot.Connections)-1]
 			slot.Connections = slot.Connections[:len(slot.Connections)-1]
 			if len(slot.Connections) == 0 {
@@ -495,7 +495,7 @@ func (r *Repository) disconnect(plug *Plug, slot *Slot) {
 		}
 	}
 	for i, slotRef := range plug.Connections {
-		if slotRef.Snap == slot.Snap.Name() && slotRef.Name == slot.Name {
+		if slotRef.Snap == slot.Snap.InstanceName() && slotRef.Name == slot.Name {
 			plug.Connections[i] = plug.Connections[len(plug.Connections)-1]
 			plug.Connections = plug.Connections[:len(plug.Connections)-1]
 			if len(plug.Connections) == 0 {
@@ -659,7 +659,7 @@ func (r *Repository) AddSnap(snapInfo *snap.Info) error {
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 
 	if r.plugs[snapName] != nil || r.slots[snapName] != nil {
 		return fmt.Errorf("cannot register interfaces for snap %q more than once", snapName)
@@ -772,7 +772,7 @@ func (r *Repository) DisconnectSnap(snapName string) ([]string, error) {
 
 	result := make([]string, 0, len(seen))
 	for info := range seen {
-		result = append(result, info.Name())
+		result = append(result, info.InstanceName())
 	}
 	sort.Strings(result)
 	return result, nil
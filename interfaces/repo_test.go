@@ -1253,6 +1253,26 @@ func (s *AddRemoveSuite) TestAddSnapErrorsOnExistingSnapSlots(c *C) {
 	c.Assert(err, ErrorMatches, `cannot register interfaces for snap "producer" more than once`)
 }
 
+func (s *AddRemoveSuite) TestAddSnapParallelInstances(c *C) {
+	_, err := s.addSnap(c, testConsumerYaml)
+	c.Assert(err, IsNil)
+	instance := snaptest.MockInfo(c, testConsumerYaml, nil)
+	instance.InstanceKey = "blue"
+	err = s.repo.AddSnap(instance)
+	c.Assert(err, IsNil)
+
+	// each instance has its own plugs
+	plug := s.repo.Plug("consumer", "iface")
+	c.Assert(plug, Not(IsNil))
+	instancePlug := s.repo.Plug("consumer_blue", "iface")
+	c.Assert(instancePlug, Not(IsNil))
+	c.Check(instancePlug.Ref(), Equals, PlugRef{Snap: "consumer_blue", Name: "iface"})
+
+	s.repo.RemoveSnap("consumer_blue")
+	c.Check(s.repo.Plug("consumer_blue", "iface"), IsNil)
+	c.Check(s.repo.Plug("consumer", "iface"), Not(IsNil))
+}
+
 func (s AddRemoveSuite) TestRemoveRemovesPlugs(c *C) {
 	_, err := s.addSnap(c, testConsumerYaml)
 	c.Assert(err, IsNil)
@@ -58,9 +58,9 @@ func (b *Backend) Name() string {
 // This method should be called after changing plug, slots, connections between
 // them or application present in the snap.
 func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 	// Get the snippets that apply to this snap
-	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.Name(), interfaces.SecuritySecComp)
+	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.InstanceName(), interfaces.SecuritySecComp)
 	if err != nil {
 		return fmt.Errorf("cannot obtain security snippets for snap %q: %s", snapName, err)
 	}
@@ -64,8 +64,8 @@ func disableRemovedServices(systemd sysd.Systemd, dir, glob string, content map[
 }
 
 func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
-	snapName := snapInfo.Name()
-	rawSnippets, err := repo.SecuritySnippetsForSnap(snapInfo.Name(), interfaces.SecuritySystemd)
+	snapName := snapInfo.InstanceName()
+	rawSnippets, err := repo.SecuritySnippetsForSnap(snapInfo.InstanceName(), interfaces.SecuritySystemd)
 	if err != nil {
 		return fmt.Errorf("cannot obtain systemd security snippets for snap %q: %s", snapName, err)
 	}
@@ -58,8 +58,8 @@ func snapRulesFilePath(snapName string) string {
 //
 // If the method fails it should be re-tried (with a sensible strategy) by the caller.
 func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
-	snapName := snapInfo.Name()
-	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.Name(), interfaces.SecurityUDev)
+	snapName := snapInfo.InstanceName()
+	snippets, err := repo.SecuritySnippetsForSnap(snapInfo.InstanceName(), interfaces.SecurityUDev)
 	if err != nil {
 		return fmt.Errorf("cannot obtain udev security snippets for snap %q: %s", snapName, err)
 	}
@@ -72,7 +72,7 @@ func (b *Backend) Setup(snapInfo *snap.Info, confinement snap.ConfinementType, r
 		return fmt.Errorf("cannot create directory for udev rules %q: %s", dir, err)
 	}
 
-	rulesFilePath := snapRulesFilePath(snapInfo.Name())
+	rulesFilePath := snapRulesFilePath(snapInfo.InstanceName())
 
 	if len(content) == 0 {
 		// Make sure that the rules file gets removed when we don't have any
@@ -162,7 +162,7 @@ func (b *Backend) combineSnippets(snapInfo *snap.Info, snippets map[string][][]b
 		}
 	}
 
-	nonePrefix := snap.NoneSecurityTag(snapInfo.Name(), "")
+	nonePrefix := snap.NoneSecurityTag(snapInfo.InstanceName(), "")
 	for securityTag, slotSnippets := range snippets {
 		if !strings.HasPrefix(securityTag, nonePrefix) {
 			continue
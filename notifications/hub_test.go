@@ -124,6 +124,47 @@ func (s *HubSuite) TestPublishFilteredNotifications(c *C) {
 	c.Assert(conn2.message, HasLen, 0)
 }
 
+func (s *HubSuite) TestPublishFilteredByChange(c *C) {
+	conn1 := &fakeConn{}
+	conn2 := &fakeConn{}
+	sub1 := &Subscriber{uuid: "sub1", types: []string{"task-status"}, change: "42", conn: conn1}
+	sub2 := &Subscriber{uuid: "sub2", changeKinds: []string{"install-snap", "refresh-snap"}, conn: conn2}
+	s.h.Subscribe(sub1)
+	s.h.Subscribe(sub2)
+
+	s.h.Publish(&Notification{Type: "task-status", Metadata: map[string]interface{}{
+		"change-id":   "42",
+		"change-kind": "remove-snap",
+	}})
+	c.Check(conn1.message, Not(HasLen), 0)
+	c.Check(conn2.message, HasLen, 0)
+
+	conn1.message = nil
+
+	s.h.Publish(&Notification{Type: "change-status", Metadata: map[string]interface{}{
+		"change-id":   "42",
+		"change-kind": "refresh-snap",
+	}})
+	c.Check(conn1.message, HasLen, 0)
+	c.Check(conn2.message, Not(HasLen), 0)
+
+	conn2.message = nil
+
+	s.h.Publish(&Notification{Type: "task-status", Metadata: map[string]interface{}{
+		"change-id":   "4",
+		"change-kind": "install-snap",
+	}})
+	c.Check(conn1.message, HasLen, 0)
+	c.Check(conn2.message, Not(HasLen), 0)
+
+	conn2.message = nil
+
+	// notifications about anything else only go to unfiltered subscribers
+	s.h.Publish(&Notification{Type: "task-status"})
+	c.Check(conn1.message, HasLen, 0)
+	c.Check(conn2.message, HasLen, 0)
+}
+
 func (s *HubSuite) TestPublishUnsubscribesOnFailedNotify(c *C) {
 	sub1 := &Subscriber{uuid: "sub1", conn: &fakeConn{}}
 	sub2 := &Subscriber{uuid: "sub2", conn: &fakeConn{err: errors.New("fail")}}
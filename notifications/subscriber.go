@@ -31,10 +31,12 @@ import (
 
 // A Subscriber is interested in receiving notifications
 type Subscriber struct {
-	uuid     string
-	conn     websocketConnection
-	types    []string
-	resource string
+	uuid        string
+	conn        websocketConnection
+	types       []string
+	resource    string
+	change      string
+	changeKinds []string
 }
 
 // Subscribers is a collection of subscribers
@@ -46,8 +48,8 @@ type websocketConnection interface {
 }
 
 // NewSubscriber returns a new subscriber containing the given websocket
-// connection and type/resource filters set from the query string params in the
-// supplied http request
+// connection and type/resource/change filters set from the query string params
+// in the supplied http request
 func NewSubscriber(c websocketConnection, r *http.Request) *Subscriber {
 	s := &Subscriber{
 		uuid: strutil.MakeRandomString(16),
@@ -61,6 +63,12 @@ func NewSubscriber(c websocketConnection, r *http.Request) *Subscriber {
 	if len(q["resource"]) > 0 {
 		s.resource = q["resource"][0]
 	}
+	if len(q["change"]) > 0 {
+		s.change = q["change"][0]
+	}
+	if len(q["change-kinds"]) > 0 {
+		s.changeKinds = strings.Split(q["change-kinds"][0], ",")
+	}
 
 	return s
 }
@@ -86,15 +94,30 @@ func (s *Subscriber) canAccept(n *Notification) bool {
 		return strings.HasSuffix(n.Resource, s.resource)
 	}
 
-	if len(s.types) > 0 {
-		for _, t := range s.types {
-			if t == n.Type {
-				return true
-			}
-		}
+	if len(s.types) > 0 && !contains(s.types, n.Type) {
+		return false
+	}
 
+	// notifications about changes and their tasks carry the change
+	// id and kind in their metadata
+	if s.change != "" && n.Metadata["change-id"] != s.change {
 		return false
 	}
+	if len(s.changeKinds) > 0 {
+		kind, _ := n.Metadata["change-kind"].(string)
+		if !contains(s.changeKinds, kind) {
+			return false
+		}
+	}
 
 	return true
 }
+
+func contains(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
@@ -54,3 +54,12 @@ func (s *SubscriberSuite) TestNewSubscriber(c *C) {
 		c.Assert(sub.resource, DeepEquals, tt.resource)
 	}
 }
+
+func (s *SubscriberSuite) TestNewSubscriberChangeFilters(c *C) {
+	req, err := http.NewRequest("GET", "/events?change=42&change-kinds=install-snap,refresh-snap", nil)
+	c.Assert(err, IsNil)
+
+	sub := NewSubscriber(&fakeConn{}, req)
+	c.Check(sub.change, Equals, "42")
+	c.Check(sub.changeKinds, DeepEquals, []string{"install-snap", "refresh-snap"})
+}
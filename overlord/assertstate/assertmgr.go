@@ -26,12 +26,14 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -46,6 +48,7 @@ import (
 // nothing in it violates existing assertions, or misses required
 // ones.
 type AssertManager struct {
+	state  *state.State
 	runner *state.TaskRunner
 }
 
@@ -64,12 +67,55 @@ func Manager(s *state.State) (*AssertManager, error) {
 	ReplaceDB(s, db)
 	s.Unlock()
 
-	return &AssertManager{runner: runner}, nil
+	return &AssertManager{state: s, runner: runner}, nil
 }
 
+// refreshAssertionsInterval is how often the assertions of the
+// installed snaps are re-fetched to pick up revocations and updates.
+var refreshAssertionsInterval = 24 * time.Hour
+
 // Ensure implements StateManager.Ensure.
 func (m *AssertManager) Ensure() error {
 	m.runner.Ensure()
+	return m.ensureAssertionsRefreshed()
+}
+
+// ensureAssertionsRefreshed re-fetches the snap declarations of the
+// installed snaps and their prerequisites periodically, once the
+// device can talk to the store.
+func (m *AssertManager) ensureAssertionsRefreshed() error {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	if snapstate.CanAutoRefresh == nil {
+		return nil
+	}
+	if ok, err := snapstate.CanAutoRefresh(st); err != nil || !ok {
+		return err
+	}
+
+	var lastRefresh time.Time
+	if err := st.Get("last-assertions-refresh", &lastRefresh); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if lastRefresh.IsZero() {
+		// start counting from now, refreshes of snaps re-fetch
+		// the assertions anyway
+		st.Set("last-assertions-refresh", time.Now())
+		return nil
+	}
+	if time.Since(lastRefresh) < refreshAssertionsInterval {
+		return nil
+	}
+
+	// record the attempt even if it fails, so that a failing store
+	// isn't hammered
+	st.Set("last-assertions-refresh", time.Now())
+	if err := RefreshSnapDeclarations(st, 0); err != nil {
+		logger.Noticef("Cannot refresh assertions: %v", err)
+		return err
+	}
 	return nil
 }
 
@@ -167,8 +213,7 @@ func (b *Batch) AddStream(r io.Reader) ([]*asserts.Ref, error) {
 	return refs, nil
 }
 
-// Commit adds the batch of assertions to the system assertion database.
-func (b *Batch) Commit(st *state.State) error {
+func (b *Batch) fetch(st *state.State) (*fetcher, error) {
 	db := cachedDB(st)
 	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
 		a, err := b.bs.Get(ref.Type, ref.PrimaryKey, ref.Type.MaxSupportedFormat())
@@ -186,9 +231,18 @@ func (b *Batch) Commit(st *state.State) error {
 	f := newFetcher(st, retrieve)
 	for _, ref := range b.refs {
 		if err := f.Fetch(ref); err != nil {
-			return err
+			return nil, err
 		}
 	}
+	return f, nil
+}
+
+// Commit adds the batch of assertions to the system assertion database.
+func (b *Batch) Commit(st *state.State) error {
+	f, err := b.fetch(st)
+	if err != nil {
+		return err
+	}
 
 	// TODO: trigger w. caller a global sanity check if something is revoked
 	// (but try to save as much possible still),
@@ -196,6 +250,45 @@ func (b *Batch) Commit(st *state.State) error {
 	return f.commit()
 }
 
+// CommitAtomically adds the batch of assertions to the system assertion database only if all of them can be added.
+// It returns references to the assertions effectively added, leaving out those the database already had with the same or a newer revision.
+func (b *Batch) CommitAtomically(st *state.State) ([]*asserts.Ref, error) {
+	f, err := b.fetch(st)
+	if err != nil {
+		return nil, err
+	}
+
+	db := cachedDB(st)
+	// check everything first without touching the system database
+	scratch := db.WithStackedBackstore(asserts.NewMemoryBackstore())
+	var toAdd []asserts.Assertion
+	for _, a := range f.fetched {
+		ref := a.Ref()
+		cur, err := ref.Resolve(db.Find)
+		if err == nil && cur.Revision() >= a.Revision() {
+			// system db has already the same or newer
+			continue
+		}
+		if err != nil && err != asserts.ErrNotFound {
+			return nil, err
+		}
+		if err := scratch.Add(a); err != nil {
+			return nil, fmt.Errorf("cannot add %s: %v", ref, err)
+		}
+		toAdd = append(toAdd, a)
+	}
+
+	// anything going wrong still is reverted
+	if err := db.AddMany(toAdd); err != nil {
+		return nil, err
+	}
+	refs := make([]*asserts.Ref, 0, len(toAdd))
+	for _, a := range toAdd {
+		refs = append(refs, a.Ref())
+	}
+	return refs, nil
+}
+
 // TODO: snapstate also has this, move to auth, or change a bit the approach now that we have AuthContext in the store?
 func userFromUserID(st *state.State, userID int) (*auth.UserState, error) {
 	if userID == 0 {
@@ -334,7 +427,14 @@ func doValidateSnap(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
-// RefreshSnapDeclarations refetches all the current snap declarations and their prerequisites.
+// ReevaluateConnections allows to hook re-evaluating the established
+// interface connections against the current assertions, returning the
+// task sets that disconnect those no longer allowed.
+var ReevaluateConnections func(st *state.State) ([]*state.TaskSet, error)
+
+// RefreshSnapDeclarations refetches all the current snap declarations and their prerequisites,
+// i.e. the accounts and keys of their publishers, and then disconnects the interface connections
+// the updated rules no longer allow.
 func RefreshSnapDeclarations(s *state.State, userID int) error {
 	snapStates, err := snapstate.All(s)
 	if err != nil {
@@ -355,7 +455,33 @@ func RefreshSnapDeclarations(s *state.State, userID int) error {
 		}
 		return nil
 	}
-	return doFetch(s, userID, fetching)
+	if err := doFetch(s, userID, fetching); err != nil {
+		return err
+	}
+	return reevaluateConnections(s)
+}
+
+// reevaluateConnections creates a change disconnecting the interface
+// connections that are no longer allowed, unless one is in progress.
+func reevaluateConnections(s *state.State) error {
+	if ReevaluateConnections == nil {
+		return nil
+	}
+	for _, chg := range s.Changes() {
+		if chg.Kind() == "reevaluate-connections" && !chg.Status().Ready() {
+			return nil
+		}
+	}
+	tasksets, err := ReevaluateConnections(s)
+	if err != nil || len(tasksets) == 0 {
+		return err
+	}
+	chg := s.NewChange("reevaluate-connections", i18n.G("Disconnect interfaces no longer allowed by assertions"))
+	for _, ts := range tasksets {
+		chg.AddAll(ts)
+	}
+	s.EnsureBefore(0)
+	return nil
 }
 
 type refreshControlError struct {
@@ -475,6 +601,26 @@ func ValidateRefreshes(s *state.State, snapInfos []*snap.Info, userID int) (vali
 func init() {
 	// hook validation of refreshes into snapstate logic
 	snapstate.ValidateRefreshes = ValidateRefreshes
+	// hook retrieving auto-aliases into snapstate logic
+	snapstate.AutoAliases = AutoAliases
+	// hook enforcing validation sets into snapstate logic
+	snapstate.EnforcedValidationSets = EnforcedValidationSets
+	// hook refreshing assertions into snapstate auto-refreshes
+	snapstate.RefreshSnapDeclarations = RefreshSnapDeclarations
+}
+
+// AutoAliases returns the aliases of the snap that its snap-declaration
+// grants to be enabled automatically.
+func AutoAliases(s *state.State, info *snap.Info) ([]string, error) {
+	if info.SnapID == "" {
+		// without declaration
+		return nil, nil
+	}
+	decl, err := SnapDeclaration(s, info.SnapID)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot find snap-declaration for installed snap %q: %v", info.Name(), err)
+	}
+	return decl.AutoAliases(), nil
 }
 
 // BaseDeclaration returns the base-declaration assertion with policies governing all snaps.
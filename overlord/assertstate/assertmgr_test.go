@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -82,6 +83,30 @@ func (sto *fakeStore) Assertion(assertType *asserts.AssertionType, key []string,
 	return a, nil
 }
 
+func (sto *fakeStore) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, _ *auth.UserState) (asserts.Assertion, error) {
+	sto.pokeStateLock()
+	if sequence > 0 {
+		key := append([]string{}, sequenceKey...)
+		return sto.Assertion(assertType, append(key, strconv.Itoa(sequence)), nil)
+	}
+	headers := make(map[string]string, len(sequenceKey))
+	for i, k := range sequenceKey {
+		headers[assertType.PrimaryKey[i]] = k
+	}
+	as, err := sto.db.FindMany(assertType, headers)
+	if err != nil {
+		return nil, &store.AssertionNotFoundError{Ref: &asserts.Ref{Type: assertType, PrimaryKey: sequenceKey}}
+	}
+	var latest *asserts.ValidationSet
+	for _, a := range as {
+		vs := a.(*asserts.ValidationSet)
+		if latest == nil || vs.Sequence() > latest.Sequence() {
+			latest = vs
+		}
+	}
+	return latest, nil
+}
+
 func (*fakeStore) Snap(string, string, bool, snap.Revision, *auth.UserState) (*snap.Info, error) {
 	panic("fakeStore.Snap not expected")
 }
@@ -144,6 +169,8 @@ func (s *assertMgrSuite) SetUpTest(c *C) {
 }
 
 func (s *assertMgrSuite) TearDownTest(c *C) {
+	assertstate.ReevaluateConnections = nil
+	snapstate.CanAutoRefresh = nil
 	s.restore()
 }
 
@@ -266,6 +293,151 @@ func (s *assertMgrSuite) TestBatchAddStreamReturnsEffectivelyAddedRefs(c *C) {
 	c.Check(devAcct.(*asserts.Account).Username(), Equals, "developer1")
 }
 
+func (s *assertMgrSuite) TestBatchCommitAtomically(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	b := &bytes.Buffer{}
+	enc := asserts.NewEncoder(b)
+	// wrong order is ok
+	err := enc.Encode(s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = enc.Encode(s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	stream := b.Bytes()
+
+	batch := assertstate.NewBatch()
+	_, err = batch.AddStream(bytes.NewReader(stream))
+	c.Assert(err, IsNil)
+
+	added, err := batch.CommitAtomically(s.state)
+	c.Assert(err, IsNil)
+	// prerequisites come first
+	c.Check(added, DeepEquals, []*asserts.Ref{
+		{Type: asserts.AccountKeyType, PrimaryKey: []string{s.storeSigning.StoreAccountKey("").PublicKeyID()}},
+		{Type: asserts.AccountType, PrimaryKey: []string{s.dev1Acct.AccountID()}},
+	})
+
+	db := assertstate.DB(s.state)
+	_, err = db.Find(asserts.AccountType, map[string]string{
+		"account-id": s.dev1Acct.AccountID(),
+	})
+	c.Assert(err, IsNil)
+
+	// again, nothing new is added
+	batch = assertstate.NewBatch()
+	_, err = batch.AddStream(bytes.NewReader(stream))
+	c.Assert(err, IsNil)
+	added, err = batch.CommitAtomically(s.state)
+	c.Assert(err, IsNil)
+	c.Check(added, HasLen, 0)
+}
+
+func (s *assertMgrSuite) TestBatchCommitAtomicallyAllOrNothing(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "snap-id-1",
+		"snap-name":    "foo",
+		"publisher-id": s.dev1Acct.AccountID(),
+		// before the signing key validity
+		"timestamp": "2000-01-01T00:00:00Z",
+	}
+	snapDecl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, headers, nil, "")
+	c.Assert(err, IsNil)
+
+	batch := assertstate.NewBatch()
+	for _, a := range []asserts.Assertion{s.storeSigning.StoreAccountKey(""), s.dev1Acct, snapDecl} {
+		err := batch.Add(a)
+		c.Assert(err, IsNil)
+	}
+
+	added, err := batch.CommitAtomically(s.state)
+	c.Assert(err, ErrorMatches, `cannot add snap-declaration \(snap-id-1; series:16\): .*`)
+	c.Check(added, IsNil)
+
+	// nothing was added
+	db := assertstate.DB(s.state)
+	_, err = db.Find(asserts.AccountType, map[string]string{
+		"account-id": s.dev1Acct.AccountID(),
+	})
+	c.Check(err, Equals, asserts.ErrNotFound)
+}
+
+// failingBackstore wraps a RevertingBackstore making the failAt-th
+// PutReverting fail.
+type failingBackstore struct {
+	asserts.RevertingBackstore
+	failAt int
+	puts   int
+}
+
+func (fbs *failingBackstore) PutReverting(assertType *asserts.AssertionType, assert asserts.Assertion) (func() error, error) {
+	fbs.puts++
+	if fbs.puts == fbs.failAt {
+		return nil, fmt.Errorf("boom")
+	}
+	return fbs.RevertingBackstore.PutReverting(assertType, assert)
+}
+
+func (s *assertMgrSuite) TestBatchCommitAtomicallyFailurePartway(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	bs := &failingBackstore{
+		RevertingBackstore: asserts.NewMemoryBackstore().(asserts.RevertingBackstore),
+	}
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: bs,
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	assertstate.ReplaceDB(s.state, db)
+
+	storeKey := s.storeSigning.StoreAccountKey("")
+	err = assertstate.Add(s.state, storeKey)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+
+	// a new revision of the account and a new account key for it
+	dev1AcctRev1 := assertstest.NewAccount(s.storeSigning, "developer1", map[string]interface{}{
+		"account-id": s.dev1Acct.AccountID(),
+		"revision":   "1",
+	}, "")
+	dev1AcctKey, err := s.storeSigning.Find(asserts.AccountKeyType, map[string]string{
+		"account-id":          s.dev1Acct.AccountID(),
+		"public-key-sha3-384": s.dev1Signing.KeyID,
+	})
+	c.Assert(err, IsNil)
+
+	batch := assertstate.NewBatch()
+	for _, a := range []asserts.Assertion{dev1AcctRev1, dev1AcctKey} {
+		err := batch.Add(a)
+		c.Assert(err, IsNil)
+	}
+
+	// the second addition fails after the first went through
+	bs.failAt = 2
+	added, err := batch.CommitAtomically(s.state)
+	c.Assert(err, ErrorMatches, `cannot add account-key \(.*\): boom`)
+	c.Check(added, IsNil)
+
+	// the first one was reverted
+	a, err := db.Find(asserts.AccountType, map[string]string{
+		"account-id": s.dev1Acct.AccountID(),
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.Revision(), Equals, 0)
+	_, err = db.Find(asserts.AccountKeyType, map[string]string{
+		"account-id":          s.dev1Acct.AccountID(),
+		"public-key-sha3-384": s.dev1Signing.KeyID,
+	})
+	c.Check(err, Equals, asserts.ErrNotFound)
+}
+
 func (s *assertMgrSuite) TestBatchAddUnsupported(c *C) {
 	batch := assertstate.NewBatch()
 
@@ -690,6 +862,122 @@ func (s *assertMgrSuite) TestRefreshSnapDeclarations(c *C) {
 	c.Check(a.(*asserts.SnapDeclaration).Revision(), Equals, 1)
 }
 
+func (s *assertMgrSuite) TestRefreshSnapDeclarationsReevaluatesConnections(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapDeclFoo := s.snapDecl(c, "foo", nil)
+	s.stateFromDecl(snapDeclFoo, snap.R(7))
+
+	reevaluated := 0
+	assertstate.ReevaluateConnections = func(st *state.State) ([]*state.TaskSet, error) {
+		reevaluated++
+		c.Check(st, Equals, s.state)
+		// the refreshed declaration is there already
+		_, err := assertstate.SnapDeclaration(st, "foo-id")
+		c.Check(err, IsNil)
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("disconnect", "..."))}, nil
+	}
+
+	err := assertstate.RefreshSnapDeclarations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(reevaluated, Equals, 1)
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "reevaluate-connections")
+	c.Check(chgs[0].Tasks(), HasLen, 1)
+
+	// nothing new while the change is in progress
+	err = assertstate.RefreshSnapDeclarations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(reevaluated, Equals, 1)
+	c.Check(s.state.Changes(), HasLen, 1)
+
+	// no change if everything is still allowed
+	chgs[0].SetStatus(state.DoneStatus)
+	assertstate.ReevaluateConnections = func(st *state.State) ([]*state.TaskSet, error) {
+		reevaluated++
+		return nil, nil
+	}
+	err = assertstate.RefreshSnapDeclarations(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(reevaluated, Equals, 2)
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *assertMgrSuite) TestEnsureRefreshesAssertions(c *C) {
+	s.state.Lock()
+	snapDeclFoo := s.snapDecl(c, "foo", nil)
+	s.stateFromDecl(snapDeclFoo, snap.R(7))
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+	s.state.Unlock()
+
+	snapDeclRevision := func() int {
+		s.state.Lock()
+		defer s.state.Unlock()
+		decl, err := assertstate.SnapDeclaration(s.state, "foo-id")
+		c.Assert(err, IsNil)
+		return decl.Revision()
+	}
+	newSnapDecl := func(revision string) {
+		decl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+			"series":       "16",
+			"snap-id":      "foo-id",
+			"snap-name":    "foo",
+			"publisher-id": s.dev1Acct.AccountID(),
+			"timestamp":    time.Now().Format(time.RFC3339),
+			"revision":     revision,
+		}, nil, "")
+		c.Assert(err, IsNil)
+		err = s.storeSigning.Add(decl)
+		c.Assert(err, IsNil)
+	}
+	newSnapDecl("1")
+
+	// not before the device can talk to the store
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(snapDeclRevision(), Equals, 0)
+
+	snapstate.CanAutoRefresh = func(*state.State) (bool, error) { return true, nil }
+
+	// the first time only starts counting
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(snapDeclRevision(), Equals, 0)
+	s.state.Lock()
+	var lastRefresh time.Time
+	c.Assert(s.state.Get("last-assertions-refresh", &lastRefresh), IsNil)
+	s.state.Unlock()
+	c.Check(time.Since(lastRefresh) < time.Minute, Equals, true)
+
+	s.state.Lock()
+	s.state.Set("last-assertions-refresh", time.Now().Add(-25*time.Hour))
+	s.state.Unlock()
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(snapDeclRevision(), Equals, 1)
+
+	// not again until the interval has passed
+	newSnapDecl("2")
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(snapDeclRevision(), Equals, 1)
+
+	s.state.Lock()
+	s.state.Set("last-assertions-refresh", time.Now().Add(-25*time.Hour))
+	s.state.Unlock()
+	err = s.mgr.Ensure()
+	c.Assert(err, IsNil)
+	c.Check(snapDeclRevision(), Equals, 2)
+}
+
 func (s *assertMgrSuite) TestValidateRefreshesNothing(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -928,3 +1216,43 @@ func (s *assertMgrSuite) TestSnapDeclaration(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(snapDecl.SnapName(), Equals, "foo")
 }
+
+func (s *assertMgrSuite) TestAutoAliases(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	headers := map[string]interface{}{
+		"series":       "16",
+		"snap-id":      "foo-id",
+		"snap-name":    "foo",
+		"publisher-id": s.dev1Acct.AccountID(),
+		"auto-aliases": []interface{}{"alias1", "alias2"},
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	snapDeclFoo, err := s.storeSigning.Sign(asserts.SnapDeclarationType, headers, nil, "")
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, snapDeclFoo)
+	c.Assert(err, IsNil)
+
+	aliases, err := assertstate.AutoAliases(s.state, &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "foo", SnapID: "foo-id"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(aliases, DeepEquals, []string{"alias1", "alias2"})
+
+	// no declaration for local snaps
+	aliases, err = assertstate.AutoAliases(s.state, &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "local"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(aliases, HasLen, 0)
+
+	_, err = assertstate.AutoAliases(s.state, &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "bar", SnapID: "bar-id"},
+	})
+	c.Check(err, ErrorMatches, `internal error: cannot find snap-declaration for installed snap "bar": assertion not found`)
+}
@@ -0,0 +1,270 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/store"
+)
+
+// ValidationSetMode is the mode a validation set is tracked in.
+type ValidationSetMode string
+
+const (
+	// Monitor mode only reports whether the system complies with the
+	// validation set.
+	Monitor ValidationSetMode = "monitor"
+	// Enforce mode refuses operations that would break the
+	// validation set.
+	Enforce ValidationSetMode = "enforce"
+)
+
+// ValidationSetTracking holds the tracking state of a validation set.
+type ValidationSetTracking struct {
+	AccountID string            `json:"account-id"`
+	Name      string            `json:"name"`
+	Mode      ValidationSetMode `json:"mode"`
+	// PinnedAt is the sequence the set was pinned at, 0 if it
+	// follows the latest known sequence when applied.
+	PinnedAt int `json:"pinned-at,omitempty"`
+	// Current is the sequence of the set in use.
+	Current int `json:"current"`
+}
+
+// ValidationSets returns the tracked validation sets keyed by
+// account-id/name.
+func ValidationSets(st *state.State) (map[string]*ValidationSetTracking, error) {
+	var sets map[string]*ValidationSetTracking
+	err := st.Get("validation-sets", &sets)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	if sets == nil {
+		sets = make(map[string]*ValidationSetTracking)
+	}
+	return sets, nil
+}
+
+// GetValidationSet retrieves the tracking state of the given
+// validation set, returning state.ErrNoState if it is not tracked.
+func GetValidationSet(st *state.State, accountID, name string, tr *ValidationSetTracking) error {
+	sets, err := ValidationSets(st)
+	if err != nil {
+		return err
+	}
+	cur := sets[snapasserts.ValidationSetKey(accountID, name)]
+	if cur == nil {
+		return state.ErrNoState
+	}
+	*tr = *cur
+	return nil
+}
+
+// ForgetValidationSet stops tracking the given validation set.
+func ForgetValidationSet(st *state.State, accountID, name string) error {
+	sets, err := ValidationSets(st)
+	if err != nil {
+		return err
+	}
+	key := snapasserts.ValidationSetKey(accountID, name)
+	if sets[key] == nil {
+		return fmt.Errorf("validation set %s is not tracked", key)
+	}
+	delete(sets, key)
+	st.Set("validation-sets", sets)
+	return nil
+}
+
+// ValidationSetAssertion returns the validation-set assertion with the
+// given sequence from the system assertion database, or the one with
+// the latest sequence if sequence is 0.
+func ValidationSetAssertion(st *state.State, accountID, name string, sequence int) (*asserts.ValidationSet, error) {
+	headers := map[string]string{
+		"series":     release.Series,
+		"account-id": accountID,
+		"name":       name,
+	}
+	if sequence > 0 {
+		headers["sequence"] = strconv.Itoa(sequence)
+	}
+	as, err := DB(st).FindMany(asserts.ValidationSetType, headers)
+	if err != nil {
+		return nil, err
+	}
+	var latest *asserts.ValidationSet
+	for _, a := range as {
+		vs := a.(*asserts.ValidationSet)
+		if latest == nil || vs.Sequence() > latest.Sequence() {
+			latest = vs
+		}
+	}
+	return latest, nil
+}
+
+// fetchValidationSet fetches the validation-set assertion with the
+// given sequence and its prerequisites from the store unless it is
+// already in the system assertion database. If sequence is 0 the
+// latest sequence is fetched from the store, falling back to the latest
+// one in the database if the store doesn't know the validation set.
+func fetchValidationSet(st *state.State, accountID, name string, sequence, userID int) (*asserts.ValidationSet, error) {
+	if sequence > 0 {
+		vs, err := ValidationSetAssertion(st, accountID, name, sequence)
+		if err != asserts.ErrNotFound {
+			return vs, err
+		}
+	}
+
+	user, err := userFromUserID(st, userID)
+	if err != nil {
+		return nil, err
+	}
+	sto := snapstate.Store(st)
+	sequenceKey := []string{release.Series, accountID, name}
+	var latest *asserts.ValidationSet
+	err = doFetch(st, userID, func(f asserts.Fetcher) error {
+		a, err := sto.SeqFormingAssertion(asserts.ValidationSetType, sequenceKey, sequence, user)
+		if err != nil {
+			return err
+		}
+		vs, ok := a.(*asserts.ValidationSet)
+		if !ok {
+			return fmt.Errorf("internal error: store returned %s assertion instead of a validation set", a.Type().Name)
+		}
+		latest = vs
+		return f.Save(vs)
+	})
+	if _, ok := err.(*store.AssertionNotFoundError); ok && sequence == 0 {
+		vs, err := ValidationSetAssertion(st, accountID, name, 0)
+		if err == asserts.ErrNotFound {
+			return nil, fmt.Errorf("cannot find validation set %s", snapasserts.ValidationSetKey(accountID, name))
+		}
+		return vs, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ValidationSetAssertion(st, accountID, name, latest.Sequence())
+}
+
+// InstalledSnaps returns the installed snaps in the form needed to
+// check them against validation sets.
+func InstalledSnaps(st *state.State) ([]*snapasserts.InstalledSnap, error) {
+	snapStates, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	installed := make([]*snapasserts.InstalledSnap, 0, len(snapStates))
+	for name, snapst := range snapStates {
+		si := snapst.CurrentSideInfo()
+		installed = append(installed, &snapasserts.InstalledSnap{
+			Name:     name,
+			SnapID:   si.SnapID,
+			Revision: si.Revision,
+		})
+	}
+	return installed, nil
+}
+
+// trackedValidationSets returns the combination of the tracked
+// validation sets accepted by filter.
+func trackedValidationSets(st *state.State, filter func(tr *ValidationSetTracking) bool) (*snapasserts.ValidationSets, error) {
+	sets, err := ValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	valsets := snapasserts.NewValidationSets()
+	for key, tr := range sets {
+		if !filter(tr) {
+			continue
+		}
+		vs, err := ValidationSetAssertion(st, tr.AccountID, tr.Name, tr.Current)
+		if err != nil {
+			return nil, fmt.Errorf("internal error: cannot find validation set %s=%d: %v", key, tr.Current, err)
+		}
+		valsets.Add(vs)
+	}
+	return valsets, nil
+}
+
+// EnforcedValidationSets returns the combination of the validation
+// sets tracked in enforce mode.
+func EnforcedValidationSets(st *state.State) (*snapasserts.ValidationSets, error) {
+	return trackedValidationSets(st, func(tr *ValidationSetTracking) bool {
+		return tr.Mode == Enforce
+	})
+}
+
+// ApplyValidationSet starts tracking the given validation set in the
+// given mode, at the given sequence or at the latest known one if
+// sequence is 0, fetching it from the store if needed. Enforcing a set
+// requires the installed snaps to comply with it and the other
+// enforced sets, which must also not be in conflict with it.
+func ApplyValidationSet(st *state.State, accountID, name string, sequence int, mode ValidationSetMode, userID int) (*ValidationSetTracking, error) {
+	if mode != Monitor && mode != Enforce {
+		return nil, fmt.Errorf("invalid validation set mode %q", mode)
+	}
+	vs, err := fetchValidationSet(st, accountID, name, sequence, userID)
+	if err != nil {
+		return nil, err
+	}
+	key := snapasserts.ValidationSetKey(accountID, name)
+
+	if mode == Enforce {
+		valsets, err := trackedValidationSets(st, func(tr *ValidationSetTracking) bool {
+			return tr.Mode == Enforce && snapasserts.ValidationSetKey(tr.AccountID, tr.Name) != key
+		})
+		if err != nil {
+			return nil, err
+		}
+		valsets.Add(vs)
+		if err := valsets.Conflict(); err != nil {
+			return nil, err
+		}
+		installed, err := InstalledSnaps(st)
+		if err != nil {
+			return nil, err
+		}
+		if err := valsets.CheckInstalledSnaps(installed); err != nil {
+			return nil, fmt.Errorf("cannot enforce validation set %s: %v", key, err)
+		}
+	}
+
+	sets, err := ValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	tr := &ValidationSetTracking{
+		AccountID: accountID,
+		Name:      name,
+		Mode:      mode,
+		PinnedAt:  sequence,
+		Current:   vs.Sequence(),
+	}
+	sets[key] = tr
+	st.Set("validation-sets", sets)
+	return tr, nil
+}
@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package assertstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *assertMgrSuite) validationSet(c *C, name, sequence string, snaps ...interface{}) *asserts.ValidationSet {
+	a, err := s.dev1Signing.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"authority-id": s.dev1Acct.AccountID(),
+		"account-id":   s.dev1Acct.AccountID(),
+		"series":       "16",
+		"name":         name,
+		"sequence":     sequence,
+		"snaps":        snaps,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return a.(*asserts.ValidationSet)
+}
+
+func (s *assertMgrSuite) prereqsForValidationSets(c *C) {
+	err := assertstate.Add(s.state, s.storeSigning.StoreAccountKey(""))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.dev1Acct)
+	c.Assert(err, IsNil)
+	dev1AcctKey, err := s.storeSigning.Find(asserts.AccountKeyType, map[string]string{
+		"account-id":          s.dev1Acct.AccountID(),
+		"public-key-sha3-384": s.dev1Signing.KeyID,
+	})
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, dev1AcctKey)
+	c.Assert(err, IsNil)
+}
+
+func requiredSnap(name, revision string) map[string]interface{} {
+	m := map[string]interface{}{
+		"name": name,
+		"id":   name + "-id",
+	}
+	if revision != "" {
+		m["revision"] = revision
+	}
+	return m
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetMonitor(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.prereqsForValidationSets(c)
+	err := assertstate.Add(s.state, s.validationSet(c, "base-set", "1", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.validationSet(c, "base-set", "2", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+
+	// monitoring does not need the snaps to be installed
+	tr, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.Monitor, 0)
+	c.Assert(err, IsNil)
+	c.Check(tr, DeepEquals, &assertstate.ValidationSetTracking{
+		AccountID: s.dev1Acct.AccountID(),
+		Name:      "base-set",
+		Mode:      assertstate.Monitor,
+		Current:   2,
+	})
+
+	var got assertstate.ValidationSetTracking
+	err = assertstate.GetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", &got)
+	c.Assert(err, IsNil)
+	c.Check(&got, DeepEquals, tr)
+
+	// nothing is enforced
+	enforced, err := assertstate.EnforcedValidationSets(s.state)
+	c.Assert(err, IsNil)
+	c.Check(enforced.Sets(), HasLen, 0)
+
+	err = assertstate.ForgetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Assert(err, IsNil)
+	err = assertstate.GetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", &got)
+	c.Check(err, Equals, state.ErrNoState)
+
+	err = assertstate.ForgetValidationSet(s.state, s.dev1Acct.AccountID(), "base-set")
+	c.Check(err, ErrorMatches, `validation set .*/base-set is not tracked`)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetFetches(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// unknown to both the store and the system
+	_, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.Monitor, 0)
+	c.Assert(err, ErrorMatches, `cannot find validation set .*/base-set`)
+
+	err = s.storeSigning.Add(s.validationSet(c, "base-set", "3", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+
+	tr, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 3, assertstate.Monitor, 0)
+	c.Assert(err, IsNil)
+	c.Check(tr.PinnedAt, Equals, 3)
+	c.Check(tr.Current, Equals, 3)
+
+	vs, err := assertstate.ValidationSetAssertion(s.state, s.dev1Acct.AccountID(), "base-set", 0)
+	c.Assert(err, IsNil)
+	c.Check(vs.Sequence(), Equals, 3)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetFetchesLatest(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.prereqsForValidationSets(c)
+	err := assertstate.Add(s.state, s.validationSet(c, "base-set", "1", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(s.validationSet(c, "base-set", "2", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+	err = s.storeSigning.Add(s.validationSet(c, "base-set", "10", requiredSnap("foo", "")))
+	c.Assert(err, IsNil)
+
+	// without a sequence the latest one is fetched from the store,
+	// even if an older one was acknowledged
+	tr, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.Monitor, 0)
+	c.Assert(err, IsNil)
+	c.Check(tr.PinnedAt, Equals, 0)
+	c.Check(tr.Current, Equals, 10)
+
+	vs, err := assertstate.ValidationSetAssertion(s.state, s.dev1Acct.AccountID(), "base-set", 0)
+	c.Assert(err, IsNil)
+	c.Check(vs.Sequence(), Equals, 10)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetEnforce(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.prereqsForValidationSets(c)
+	err := assertstate.Add(s.state, s.validationSet(c, "base-set", "1", requiredSnap("foo", "3")))
+	c.Assert(err, IsNil)
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.Enforce, 0)
+	c.Assert(err, ErrorMatches, `(?s)cannot enforce validation set .*/base-set: validation sets assertions are not met:\n- missing required snap "foo" .*`)
+
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", SnapID: "foo-id", Revision: snap.R(3)},
+		},
+		Current: snap.R(3),
+	})
+
+	tr, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, assertstate.Enforce, 0)
+	c.Assert(err, IsNil)
+	c.Check(tr.Mode, Equals, assertstate.Enforce)
+
+	enforced, err := assertstate.EnforcedValidationSets(s.state)
+	c.Assert(err, IsNil)
+	c.Check(enforced.Sets(), HasLen, 1)
+	cstrs := enforced.Constraints("foo")
+	c.Assert(cstrs, NotNil)
+	c.Check(cstrs.Revision, Equals, snap.R(3))
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetEnforceConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.prereqsForValidationSets(c)
+	err := assertstate.Add(s.state, s.validationSet(c, "one", "1", requiredSnap("foo", "3")))
+	c.Assert(err, IsNil)
+	err = assertstate.Add(s.state, s.validationSet(c, "two", "1", requiredSnap("foo", "4")))
+	c.Assert(err, IsNil)
+	snapstate.Set(s.state, "foo", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "foo", SnapID: "foo-id", Revision: snap.R(3)},
+		},
+		Current: snap.R(3),
+	})
+
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "one", 0, assertstate.Enforce, 0)
+	c.Assert(err, IsNil)
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "two", 0, assertstate.Enforce, 0)
+	c.Assert(err, NotNil)
+
+	// monitoring is fine though
+	_, err = assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "two", 0, assertstate.Monitor, 0)
+	c.Assert(err, IsNil)
+}
+
+func (s *assertMgrSuite) TestApplyValidationSetInvalidMode(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := assertstate.ApplyValidationSet(s.state, s.dev1Acct.AccountID(), "base-set", 0, "foo", 0)
+	c.Assert(err, ErrorMatches, `invalid validation set mode "foo"`)
+}
@@ -0,0 +1,231 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package auditstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	// maxLogSize is the size above which the audit log is rotated.
+	maxLogSize int64 = 1024 * 1024
+	// logBackups is how many rotated audit logs are kept around.
+	logBackups = 3
+)
+
+// logMu serializes the access to the audit log files.
+var logMu sync.Mutex
+
+// TaskError is the error of a task of an audited change.
+type TaskError struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Summary string `json:"summary"`
+	Error   string `json:"error"`
+}
+
+// Entry is the record of a ready change in the audit log.
+type Entry struct {
+	ID         string      `json:"id"`
+	Kind       string      `json:"kind"`
+	Summary    string      `json:"summary"`
+	Status     string      `json:"status"`
+	UID        *uint32     `json:"uid,omitempty"`
+	SnapNames  []string    `json:"snap-names,omitempty"`
+	SpawnTime  time.Time   `json:"spawn-time"`
+	ReadyTime  time.Time   `json:"ready-time"`
+	Err        string      `json:"err,omitempty"`
+	TaskErrors []TaskError `json:"task-errors,omitempty"`
+}
+
+func entryFromChange(chg *state.Change) (*Entry, error) {
+	entry := &Entry{
+		ID:        chg.ID(),
+		Kind:      chg.Kind(),
+		Summary:   chg.Summary(),
+		Status:    chg.Status().String(),
+		SpawnTime: chg.SpawnTime(),
+		ReadyTime: chg.ReadyTime(),
+	}
+
+	var uid uint32
+	err := chg.Get("uid", &uid)
+	if err == nil {
+		entry.UID = &uid
+	} else if err != state.ErrNoState {
+		return nil, err
+	}
+	err = chg.Get("snap-names", &entry.SnapNames)
+	if err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+
+	if err := chg.Err(); err != nil {
+		entry.Err = err.Error()
+	}
+	for _, t := range chg.Tasks() {
+		if t.Status() != state.ErrorStatus {
+			continue
+		}
+		for _, msg := range t.Log() {
+			// log entries look like "<time> ERROR <message>"
+			i := strings.Index(msg, " ERROR ")
+			if i < 0 || strings.Contains(msg[:i], " ") {
+				continue
+			}
+			entry.TaskErrors = append(entry.TaskErrors, TaskError{
+				ID:      t.ID(),
+				Kind:    t.Kind(),
+				Summary: t.Summary(),
+				Error:   msg[i+len(" ERROR "):],
+			})
+		}
+	}
+
+	return entry, nil
+}
+
+func backupName(n int) string {
+	return fmt.Sprintf("%s.%d", dirs.SnapAuditLogFile, n)
+}
+
+// rotateIfNeeded rotates the audit log if writing extra more bytes to
+// it would take it over maxLogSize, dropping the oldest backup.
+func rotateIfNeeded(extra int64) error {
+	fi, err := os.Stat(dirs.SnapAuditLogFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 || fi.Size()+extra <= maxLogSize {
+		return nil
+	}
+	for n := logBackups - 1; n > 0; n-- {
+		if err := os.Rename(backupName(n), backupName(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if logBackups == 0 {
+		return os.Remove(dirs.SnapAuditLogFile)
+	}
+	return os.Rename(dirs.SnapAuditLogFile, backupName(1))
+}
+
+func appendEntries(entries []*Entry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapAuditLogFile), 0755); err != nil {
+		return err
+	}
+	if err := rotateIfNeeded(int64(buf.Len())); err != nil {
+		return fmt.Errorf("cannot rotate audit log: %v", err)
+	}
+	f, err := os.OpenFile(dirs.SnapAuditLogFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %v", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write audit log: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write audit log: %v", err)
+	}
+	return f.Close()
+}
+
+func readEntries(fn string, since time.Time) ([]*Entry, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*Entry
+	dec := json.NewDecoder(f)
+	for {
+		var entry Entry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// most likely an entry cut short by a crash
+			logger.Noticef("Cannot read past entry %d of audit log %q: %v", len(entries), fn, err)
+			break
+		}
+		if entry.ReadyTime.Before(since) {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// Entries returns the entries in the audit log, including the rotated
+// away ones, of the changes that became ready at or after since. They
+// are returned oldest first.
+func Entries(since time.Time) ([]*Entry, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	var entries []*Entry
+	for n := logBackups; n >= 0; n-- {
+		fn := dirs.SnapAuditLogFile
+		if n > 0 {
+			fn = backupName(n)
+		}
+		more, err := readEntries(fn, since)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read audit log: %v", err)
+		}
+		entries = append(entries, more...)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package auditstate implements the manager keeping an append-only log
+// of the changes done to the system, which outlives their pruning from
+// the state.
+package auditstate
+
+import (
+	"sort"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// AuditManager records the changes that are ready in the audit log.
+type AuditManager struct {
+	state *state.State
+}
+
+// Manager returns a new audit manager.
+func Manager(st *state.State) (*AuditManager, error) {
+	return &AuditManager{state: st}, nil
+}
+
+// SetUID records on the change the uid of the user that requested it.
+func SetUID(chg *state.Change, uid uint32) {
+	chg.Set("uid", uid)
+}
+
+type changesByReadyTime []*state.Change
+
+func (s changesByReadyTime) Len() int { return len(s) }
+func (s changesByReadyTime) Less(i, j int) bool {
+	return s[i].ReadyTime().Before(s[j].ReadyTime())
+}
+func (s changesByReadyTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Ensure implements StateManager.Ensure.
+func (m *AuditManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	var chgs []*state.Change
+	for _, chg := range m.state.Changes() {
+		if !chg.Status().Ready() {
+			continue
+		}
+		var audited bool
+		if err := chg.Get("audited", &audited); err != nil && err != state.ErrNoState {
+			return err
+		}
+		if !audited {
+			chgs = append(chgs, chg)
+		}
+	}
+	if len(chgs) == 0 {
+		return nil
+	}
+	sort.Stable(changesByReadyTime(chgs))
+
+	entries := make([]*Entry, len(chgs))
+	for i, chg := range chgs {
+		entry, err := entryFromChange(chg)
+		if err != nil {
+			return err
+		}
+		entries[i] = entry
+	}
+	if err := appendEntries(entries); err != nil {
+		return err
+	}
+	for _, chg := range chgs {
+		chg.Set("audited", true)
+	}
+
+	return nil
+}
+
+// Wait implements StateManager.Wait.
+func (m *AuditManager) Wait() {}
+
+// Stop implements StateManager.Stop.
+func (m *AuditManager) Stop() {}
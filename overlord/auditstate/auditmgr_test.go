@@ -0,0 +1,246 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package auditstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auditstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type auditSuite struct {
+	state *state.State
+	mgr   *auditstate.AuditManager
+}
+
+var _ = Suite(&auditSuite{})
+
+func (s *auditSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	mgr, err := auditstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.mgr = mgr
+}
+
+func (s *auditSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *auditSuite) addChange(kind string, status state.Status) *state.Change {
+	chg := s.state.NewChange(kind, kind+"...")
+	t := s.state.NewTask(kind+"-task", "task of "+kind)
+	chg.AddTask(t)
+	t.SetStatus(status)
+	return chg
+}
+
+func (s *auditSuite) TestEnsureRecordsReadyChanges(c *C) {
+	s.state.Lock()
+	done := s.addChange("install", state.DoneStatus)
+	done.Set("snap-names", []string{"foo"})
+	auditstate.SetUID(done, 1000)
+	failed := s.addChange("remove", state.DoStatus)
+	failed.Tasks()[0].Errorf("boom")
+	failed.Tasks()[0].SetStatus(state.ErrorStatus)
+	s.addChange("refresh", state.DoingStatus)
+	s.state.Unlock()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	entries, err := auditstate.Entries(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	byKind := make(map[string]*auditstate.Entry)
+	for _, entry := range entries {
+		byKind[entry.Kind] = entry
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	entry := byKind["install"]
+	c.Assert(entry, NotNil)
+	c.Check(entry.ID, Equals, done.ID())
+	c.Check(entry.Summary, Equals, "install...")
+	c.Check(entry.Status, Equals, "Done")
+	c.Assert(entry.UID, NotNil)
+	c.Check(*entry.UID, Equals, uint32(1000))
+	c.Check(entry.SnapNames, DeepEquals, []string{"foo"})
+	c.Check(entry.SpawnTime.Equal(done.SpawnTime()), Equals, true)
+	c.Check(entry.ReadyTime.Equal(done.ReadyTime()), Equals, true)
+	c.Check(entry.Err, Equals, "")
+	c.Check(entry.TaskErrors, HasLen, 0)
+
+	entry = byKind["remove"]
+	c.Assert(entry, NotNil)
+	c.Check(entry.Status, Equals, "Error")
+	c.Check(entry.UID, IsNil)
+	c.Check(entry.Err, Equals, "cannot perform the following tasks:\n- task of remove (boom)")
+	c.Check(entry.TaskErrors, DeepEquals, []auditstate.TaskError{{
+		ID:      failed.Tasks()[0].ID(),
+		Kind:    "remove-task",
+		Summary: "task of remove",
+		Error:   "boom",
+	}})
+}
+
+func (s *auditSuite) TestEnsureRecordsOnce(c *C) {
+	s.state.Lock()
+	s.addChange("install", state.DoneStatus)
+	s.state.Unlock()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	entries, err := auditstate.Entries(time.Time{})
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+
+	// a change becoming ready later gets recorded then
+	s.state.Lock()
+	s.addChange("remove", state.UndoneStatus)
+	s.state.Unlock()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	entries, err = auditstate.Entries(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Kind, Equals, "install")
+	c.Check(entries[1].Kind, Equals, "remove")
+	c.Check(entries[1].Status, Equals, "Undone")
+}
+
+func (s *auditSuite) TestEnsureNothingReady(c *C) {
+	s.state.Lock()
+	s.addChange("install", state.DoStatus)
+	s.state.Unlock()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	c.Check(osutil.FileExists(dirs.SnapAuditLogFile), Equals, false)
+}
+
+func (s *auditSuite) TestEntriesSince(c *C) {
+	restore := state.MockTime(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+	s.state.Lock()
+	s.addChange("install", state.DoneStatus)
+	s.state.Unlock()
+	restore()
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	restore = state.MockTime(time.Date(2017, 1, 3, 0, 0, 0, 0, time.UTC))
+	s.state.Lock()
+	s.addChange("remove", state.DoneStatus)
+	s.state.Unlock()
+	restore()
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	entries, err := auditstate.Entries(time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Kind, Equals, "remove")
+
+	entries, err = auditstate.Entries(time.Date(2017, 1, 3, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 1)
+
+	entries, err = auditstate.Entries(time.Date(2017, 1, 4, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+}
+
+func (s *auditSuite) TestRotation(c *C) {
+	// about two entries per log
+	restore := auditstate.MockLogLimits(400, 2)
+	defer restore()
+
+	for i := 0; i < 10; i++ {
+		s.state.Lock()
+		s.addChange(fmt.Sprintf("kind%d", i), state.DoneStatus)
+		s.state.Unlock()
+		c.Assert(s.mgr.Ensure(), IsNil)
+	}
+
+	for _, fn := range []string{dirs.SnapAuditLogFile, dirs.SnapAuditLogFile + ".1", dirs.SnapAuditLogFile + ".2"} {
+		fi, err := os.Stat(fn)
+		c.Assert(err, IsNil)
+		c.Check(fi.Size() <= 400, Equals, true, Commentf(fn))
+	}
+	_, err := os.Stat(dirs.SnapAuditLogFile + ".3")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	entries, err := auditstate.Entries(time.Time{})
+	c.Assert(err, IsNil)
+	// the oldest ones were rotated away
+	c.Assert(len(entries) < 10, Equals, true)
+	c.Assert(len(entries) >= 3, Equals, true)
+	for i, entry := range entries {
+		c.Check(entry.Kind, Equals, fmt.Sprintf("kind%d", 10-len(entries)+i))
+	}
+}
+
+func (s *auditSuite) TestEntriesCutShort(c *C) {
+	s.state.Lock()
+	s.addChange("install", state.DoneStatus)
+	s.state.Unlock()
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	f, err := os.OpenFile(dirs.SnapAuditLogFile, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(`{"id": "2", "kind": "rem`))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	entries, err := auditstate.Entries(time.Time{})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Kind, Equals, "install")
+}
+
+func (s *auditSuite) TestLogPermissions(c *C) {
+	s.state.Lock()
+	s.addChange("install", state.DoneStatus)
+	s.state.Unlock()
+	c.Assert(s.mgr.Ensure(), IsNil)
+
+	fi, err := os.Stat(dirs.SnapAuditLogFile)
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Check(filepath.Dir(dirs.SnapAuditLogFile), Equals, filepath.Dir(dirs.SnapStateFile))
+
+	data, err := ioutil.ReadFile(dirs.SnapAuditLogFile)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Matches, `{"id":"\d+","kind":"install",.*}\n`)
+}
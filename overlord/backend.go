@@ -20,20 +20,65 @@
 package overlord
 
 import (
+	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
 )
 
 type overlordStateBackend struct {
 	path           string
 	ensureBefore   func(d time.Duration)
 	requestRestart func(t state.RestartType)
+
+	// store keeps the deltas to the state file, but only once the
+	// state is at a patch level at which it can be checkpointed
+	// incrementally
+	store       statejournal.Store
+	incremental bool
 }
 
 func (osb *overlordStateBackend) Checkpoint(data []byte) error {
-	return osutil.AtomicWriteFile(osb.path, data, 0600, 0)
+	if err := osutil.AtomicWriteFile(osb.path, data, 0600, 0); err != nil {
+		return err
+	}
+	if osb.incremental {
+		return osb.store.Reset(data)
+	}
+	return nil
+}
+
+func (osb *overlordStateBackend) CheckpointDelta(delta *state.Delta) error {
+	if !osb.incremental {
+		return state.ErrFullCheckpoint
+	}
+	return osb.store.Commit(delta)
+}
+
+func (osb *overlordStateBackend) StartIncremental(data []byte) error {
+	osb.incremental = true
+	if err := osb.Checkpoint(data); err != nil {
+		osb.incremental = false
+		return err
+	}
+	return nil
+}
+
+// openStore has the backend carry on checkpointing the state
+// incrementally on top of the state file.
+func (osb *overlordStateBackend) openStore() error {
+	snapshot, err := ioutil.ReadFile(osb.path)
+	if err != nil {
+		return fmt.Errorf("cannot read the state file: %s", err)
+	}
+	if err := osb.store.Open(snapshot); err != nil {
+		return fmt.Errorf("cannot open the state store: %v", err)
+	}
+	osb.incremental = true
+	return nil
 }
 
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
@@ -0,0 +1,151 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
+)
+
+type backendSuite struct {
+	statePath   string
+	journalPath string
+}
+
+var _ = Suite(&backendSuite{})
+
+func (s *backendSuite) SetUpTest(c *C) {
+	dir := c.MkDir()
+	s.statePath = filepath.Join(dir, "state.json")
+	s.journalPath = filepath.Join(dir, "state.journal")
+}
+
+type fakeStore struct {
+	resets  []string
+	commits []*state.Delta
+}
+
+func (fs *fakeStore) Replay(snapshot []byte) ([]byte, error) {
+	return snapshot, nil
+}
+
+func (fs *fakeStore) Open(snapshot []byte) error {
+	return nil
+}
+
+func (fs *fakeStore) Reset(snapshot []byte) error {
+	fs.resets = append(fs.resets, string(snapshot))
+	return nil
+}
+
+func (fs *fakeStore) Commit(delta *state.Delta) error {
+	fs.commits = append(fs.commits, delta)
+	return nil
+}
+
+func (s *backendSuite) TestCheckpointNotIncremental(c *C) {
+	store := &fakeStore{}
+	b := overlord.NewStateBackend(s.statePath, store)
+
+	c.Assert(b.Checkpoint([]byte(`{"data":{}}`)), IsNil)
+	content, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `{"data":{}}`)
+
+	c.Check(b.CheckpointDelta(&state.Delta{}), Equals, state.ErrFullCheckpoint)
+	c.Check(store.resets, HasLen, 0)
+	c.Check(store.commits, HasLen, 0)
+}
+
+func (s *backendSuite) TestStartIncremental(c *C) {
+	store := &fakeStore{}
+	b := overlord.NewStateBackend(s.statePath, store)
+
+	c.Assert(b.StartIncremental([]byte(`{"data":{}}`)), IsNil)
+	content, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, `{"data":{}}`)
+	c.Check(store.resets, DeepEquals, []string{`{"data":{}}`})
+
+	delta := &state.Delta{LastChangeId: 1}
+	c.Assert(b.CheckpointDelta(delta), IsNil)
+	c.Check(store.commits, DeepEquals, []*state.Delta{delta})
+
+	// full checkpoints reset the store
+	c.Assert(b.Checkpoint([]byte(`{"data":{"foo":1}}`)), IsNil)
+	c.Check(store.resets, DeepEquals, []string{`{"data":{}}`, `{"data":{"foo":1}}`})
+}
+
+// setUpBig sets up a state with many changes and tasks, as devices end
+// up with, checkpointed in full.
+func setUpBig(b state.Backend) (*state.State, []*state.Task) {
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+	config := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		config[fmt.Sprintf("key%d", i)] = "some reasonably long configuration value"
+	}
+	st.Set("config", config)
+	var tasks []*state.Task
+	for i := 0; i < 50; i++ {
+		chg := st.NewChange("refresh", fmt.Sprintf("refresh %d", i))
+		for j := 0; j < 10; j++ {
+			t := st.NewTask("task", "some task")
+			t.Set("snap-setup", config)
+			t.Logf("some log")
+			chg.AddTask(t)
+			tasks = append(tasks, t)
+		}
+	}
+	return st, tasks
+}
+
+func benchmarkCheckpoint(c *C, st *state.State, tasks []*state.Task) {
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		st.Lock()
+		tasks[i%len(tasks)].SetStatus(state.DoingStatus)
+		st.Unlock()
+	}
+}
+
+func (s *backendSuite) BenchmarkCheckpointFull(c *C) {
+	b := overlord.NewStateBackend(s.statePath, statejournal.New(s.journalPath))
+	st, tasks := setUpBig(b)
+	benchmarkCheckpoint(c, st, tasks)
+}
+
+func (s *backendSuite) BenchmarkCheckpointIncremental(c *C) {
+	b := overlord.NewStateBackend(s.statePath, statejournal.New(s.journalPath))
+	st, tasks := setUpBig(b)
+	st.Lock()
+	err := st.StartIncrementalCheckpoints()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	benchmarkCheckpoint(c, st, tasks)
+}
@@ -19,7 +19,12 @@
 
 package configstate
 
-import "github.com/snapcore/snapd/overlord/hookstate"
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/timeutil"
+)
 
 // configureHandler is the handler for the configure hook.
 type configureHandler struct {
@@ -71,6 +76,37 @@ func (h *configureHandler) Before() error {
 		}
 	}
 
+	if h.context.SnapName() == "core" {
+		return validateCoreConfig(transaction)
+	}
+
+	return nil
+}
+
+// validateCoreConfig checks the options of the core snap that snapd
+// itself consumes.
+func validateCoreConfig(transaction *Transaction) error {
+	var refreshSchedule string
+	if err := transaction.GetMaybe("core", "refresh.schedule", &refreshSchedule); err != nil {
+		return err
+	}
+	if refreshSchedule != "" {
+		if _, err := timeutil.ParseSchedule(refreshSchedule); err != nil {
+			return fmt.Errorf("invalid refresh.schedule: %v", err)
+		}
+	}
+
+	var refreshRetain interface{}
+	if err := transaction.GetMaybe("core", "refresh.retain", &refreshRetain); err != nil {
+		return err
+	}
+	if refreshRetain != nil {
+		n, ok := refreshRetain.(float64)
+		if !ok || n != float64(int(n)) || n < 2 || n > 20 {
+			return fmt.Errorf("invalid refresh.retain: must be a number between 2 and 20")
+		}
+	}
+
 	return nil
 }
 
@@ -69,3 +69,69 @@ func (s *configureHandlerSuite) TestBeforeInitializesTransaction(c *C) {
 	c.Check(transaction.Get("test-snap", "foo", &value), IsNil)
 	c.Check(value, Equals, "bar")
 }
+
+func (s *configureHandlerSuite) TestBeforeValidatesRefreshSchedule(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "core", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, setup, hooktest.NewMockHandler())
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		schedule string
+		err      string
+	}{
+		{"mon-fri,02:00-04:00", ""},
+		{"potato", `invalid refresh.schedule: cannot parse "potato": .*`},
+	} {
+		context.Lock()
+		context.Set("patch", map[string]interface{}{
+			"refresh.schedule": t.schedule,
+		})
+		context.Unlock()
+
+		err := configstate.NewConfigureHandler(context).Before()
+		if t.err == "" {
+			c.Check(err, IsNil)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (s *configureHandlerSuite) TestBeforeValidatesRefreshRetain(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "core", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, setup, hooktest.NewMockHandler())
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		retain interface{}
+		err    string
+	}{
+		{2, ""},
+		{20, ""},
+		{1, `invalid refresh.retain: must be a number between 2 and 20`},
+		{21, `invalid refresh.retain: must be a number between 2 and 20`},
+		{2.5, `invalid refresh.retain: must be a number between 2 and 20`},
+		{"3", `invalid refresh.retain: must be a number between 2 and 20`},
+	} {
+		context.Lock()
+		context.Set("patch", map[string]interface{}{
+			"refresh.retain": t.retain,
+		})
+		context.Unlock()
+
+		err := configstate.NewConfigureHandler(context).Before()
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%v", t.retain))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%v", t.retain))
+		}
+	}
+}
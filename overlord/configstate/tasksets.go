@@ -30,6 +30,9 @@ import (
 
 func init() {
 	snapstate.Configure = Configure
+	snapstate.NewConfig = func(st *state.State) snapstate.Config {
+		return NewTransaction(st)
+	}
 }
 
 // Configure returns a taskset to apply the given configuration patch.
@@ -33,6 +33,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -392,12 +393,26 @@ func (m *DeviceManager) doGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
 		return nil
 	}
 
-	keyPair, err := rsa.GenerateKey(rand.Reader, keyLength)
+	keyType, err := deviceKeyType(st)
+	if err != nil {
+		return err
+	}
+
+	var privKey asserts.PrivateKey
+	switch keyType {
+	case "", "rsa":
+		var keyPair *rsa.PrivateKey
+		keyPair, err = rsa.GenerateKey(rand.Reader, keyLength)
+		privKey = asserts.RSAPrivateKey(keyPair)
+	case "ed25519":
+		privKey, err = asserts.GenerateEd25519Key()
+	default:
+		return fmt.Errorf("cannot generate device key pair: unsupported key type %q", keyType)
+	}
 	if err != nil {
 		return fmt.Errorf("cannot generate device key pair: %v", err)
 	}
 
-	privKey := asserts.RSAPrivateKey(keyPair)
 	err = m.keypairMgr.Put(privKey)
 	if err != nil {
 		return fmt.Errorf("cannot store device key pair: %v", err)
@@ -412,6 +427,28 @@ func (m *DeviceManager) doGenerateDeviceKey(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// deviceKeyType returns the type of device key the gadget asked for
+// via device-service.key-type, RSA keys are the default as Ed25519
+// keys need format 1 serial-requests which not every device service
+// accepts yet.
+func deviceKeyType(st *state.State) (string, error) {
+	gadgetInfo, err := snapstate.GadgetInfo(st)
+	if err == state.ErrNoState {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot find gadget snap and its name: %v", err)
+	}
+
+	tr := configstate.NewTransaction(st)
+	var keyType string
+	err = tr.GetMaybe(gadgetInfo.Name(), "device-service.key-type", &keyType)
+	if err != nil {
+		return "", err
+	}
+	return keyType, nil
+}
+
 func (m *DeviceManager) keyPair() (asserts.PrivateKey, error) {
 	device, err := auth.Device(m.state)
 	if err != nil {
@@ -487,6 +524,7 @@ func prepareSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *aut
 	if cfg.proposedSerial != "" {
 		headers["serial"] = cfg.proposedSerial
 	}
+	setFormatForKey(headers, privKey.PublicKey())
 
 	serialReq, err := asserts.SignWithoutAuthority(asserts.SerialRequestType, headers, cfg.body, privKey)
 	if err != nil {
@@ -496,6 +534,15 @@ func prepareSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *aut
 	return string(asserts.Encode(serialReq)), nil
 }
 
+// setFormatForKey sets the format header needed by assertions signed
+// by or carrying the given key, Ed25519 device keys need a newer
+// format than RSA ones.
+func setFormatForKey(headers map[string]interface{}, pubKey asserts.PublicKey) {
+	if format := asserts.MinFormatForKey(pubKey); format > 0 {
+		headers["format"] = strconv.Itoa(format)
+	}
+}
+
 var errPoll = errors.New("serial-request accepted, poll later")
 
 func submitSerialRequest(t *state.Task, serialRequest string, client *http.Client, cfg *serialRequestConfig) (*asserts.Serial, error) {
@@ -818,13 +865,15 @@ func (m *DeviceManager) DeviceSessionRequest(nonce string) (*asserts.DeviceSessi
 		return nil, nil, err
 	}
 
-	a, err := asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, map[string]interface{}{
+	headers := map[string]interface{}{
 		"brand-id":  serial.BrandID(),
 		"model":     serial.Model(),
 		"serial":    serial.Serial(),
 		"nonce":     nonce,
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	}, nil, privKey)
+	}
+	setFormatForKey(headers, privKey.PublicKey())
+	a, err := asserts.SignWithoutAuthority(asserts.DeviceSessionRequestType, headers, nil, privKey)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -934,6 +983,31 @@ func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags sn
 	return nil
 }
 
+// canAutoRefresh returns whether the device is ready for auto-refreshes:
+// on core devices that means seeded and registered.
+func canAutoRefresh(st *state.State) (bool, error) {
+	// seeding is not done on classic (yet)
+	if release.OnClassic {
+		return true, nil
+	}
+
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	if err != nil && err != state.ErrNoState {
+		return false, err
+	}
+	if !seeded {
+		return false, nil
+	}
+
+	device, err := auth.Device(st)
+	if err != nil {
+		return false, err
+	}
+	return device.Serial != "", nil
+}
+
 func init() {
 	snapstate.AddCheckSnapCallback(checkGadgetOrKernel)
+	snapstate.CanAutoRefresh = canAutoRefresh
 }
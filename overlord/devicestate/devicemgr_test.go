@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -42,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
@@ -90,6 +92,10 @@ func (sto *fakeStore) Assertion(assertType *asserts.AssertionType, key []string,
 	return a, nil
 }
 
+func (*fakeStore) SeqFormingAssertion(*asserts.AssertionType, []string, int, *auth.UserState) (asserts.Assertion, error) {
+	panic("fakeStore.SeqFormingAssertion not expected")
+}
+
 func (*fakeStore) Snap(string, string, bool, snap.Revision, *auth.UserState) (*snap.Info, error) {
 	panic("fakeStore.Snap not expected")
 }
@@ -210,14 +216,18 @@ func (s *deviceMgrSuite) mockServer(c *C, reqID string) *httptest.Server {
 				// use proposed serial
 				serialStr = serialReq.Serial()
 			}
-			serial, err := s.storeSigning.Sign(asserts.SerialType, map[string]interface{}{
+			headers := map[string]interface{}{
 				"brand-id":            "canonical",
 				"model":               "pc",
 				"serial":              serialStr,
 				"device-key":          serialReq.HeaderString("device-key"),
 				"device-key-sha3-384": serialReq.SignKeyID(),
 				"timestamp":           time.Now().Format(time.RFC3339),
-			}, serialReq.Body(), "")
+			}
+			if format := serialReq.Format(); format > 0 {
+				headers["format"] = strconv.Itoa(format)
+			}
+			serial, err := s.storeSigning.Sign(asserts.SerialType, headers, serialReq.Body(), "")
 			c.Assert(err, IsNil)
 			w.Header().Set("Content-Type", asserts.MediaType)
 			w.WriteHeader(http.StatusOK)
@@ -320,6 +330,77 @@ version: gadget
 	c.Check(privKey, NotNil)
 
 	c.Check(device.KeyID, Equals, privKey.PublicKey().ID())
+
+	// by default the device key is an RSA key, using format 0
+	c.Check(serial.Format(), Equals, 0)
+	c.Check(asserts.MinFormatForKey(privKey.PublicKey()), Equals, 0)
+}
+
+func (s *deviceMgrSuite) TestFullDeviceRegistrationHappyEd25519(c *C) {
+	mockServer := s.mockServer(c, "REQID-1")
+	defer mockServer.Close()
+
+	mockRequestIDURL := mockServer.URL + "/identity/api/v1/request-id"
+	r2 := devicestate.MockRequestIDURL(mockRequestIDURL)
+	defer r2()
+
+	mockSerialRequestURL := mockServer.URL + "/identity/api/v1/devices"
+	r3 := devicestate.MockSerialRequestURL(mockSerialRequestURL)
+	defer r3()
+
+	// setup state as will be done by first-boot
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupGadget(c, `
+name: gadget
+type: gadget
+version: gadget
+`, "")
+
+	// the gadget asks for an Ed25519 device key
+	tr := configstate.NewTransaction(s.state)
+	tr.Set("gadget", "device-service.key-type", "ed25519")
+	tr.Commit()
+
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+	})
+
+	// runs the whole device registration process
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	device, err := auth.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "9999")
+
+	a, err := s.db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "pc",
+		"serial":   "9999",
+	})
+	c.Assert(err, IsNil)
+	serial := a.(*asserts.Serial)
+
+	privKey, err := s.mgr.KeypairManager().Get(serial.DeviceKey().ID())
+	c.Assert(err, IsNil)
+	c.Check(device.KeyID, Equals, privKey.PublicKey().ID())
+
+	// the device key is an Ed25519 key, needing format 1
+	c.Check(serial.Format(), Equals, 1)
+	c.Check(asserts.MinFormatForKey(privKey.PublicKey()), Equals, 1)
+
+	// which is used for device-session-request as well
+	s.state.Unlock()
+	sessReq, _, err := s.mgr.DeviceSessionRequest("NONCE-1")
+	s.state.Lock()
+	c.Assert(err, IsNil)
+	c.Check(sessReq.Format(), Equals, 1)
+	err = asserts.SignatureCheck(sessReq, privKey.PublicKey())
+	c.Check(err, IsNil)
 }
 
 func (s *deviceMgrSuite) TestDoRequestSerialIdempotentAfterAddSerial(c *C) {
@@ -1134,3 +1215,40 @@ name: krnl`, nil)
 	err = devicestate.CheckGadgetOrKernel(s.state, krnlKernelInfo, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 }
+
+func (s *deviceMgrSuite) TestCanAutoRefreshOnClassic(c *C) {
+	release.OnClassic = true
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ok, err := devicestate.CanAutoRefresh(s.state)
+	c.Assert(err, IsNil)
+	c.Check(ok, Equals, true)
+}
+
+func (s *deviceMgrSuite) TestCanAutoRefreshOnCore(c *C) {
+	release.OnClassic = false
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	canAutoRefresh := func() bool {
+		ok, err := devicestate.CanAutoRefresh(s.state)
+		c.Assert(err, IsNil)
+		return ok
+	}
+
+	// not seeded, no serial
+	c.Check(canAutoRefresh(), Equals, false)
+
+	// seeded, no serial
+	s.state.Set("seeded", true)
+	c.Check(canAutoRefresh(), Equals, false)
+
+	// seeded, with serial
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand:  "canonical",
+		Model:  "pc",
+		Serial: "8989",
+	})
+	c.Check(canAutoRefresh(), Equals, true)
+}
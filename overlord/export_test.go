@@ -23,6 +23,8 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
 	"github.com/snapcore/snapd/store"
 )
 
@@ -65,3 +67,14 @@ func MockStoreNew(new func(*store.Config, auth.AuthContext) *store.Store) (resto
 		storeNew = store.New
 	}
 }
+
+// NewStateBackend returns the backend the overlord checkpoints the state
+// with, to the state file at path and, once incremental, to store.
+func NewStateBackend(path string, store statejournal.Store) state.IncrementalBackend {
+	return &overlordStateBackend{
+		path:           path,
+		ensureBefore:   func(time.Duration) {},
+		requestRestart: func(state.RestartType) {},
+		store:          store,
+	}
+}
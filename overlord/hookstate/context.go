@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -37,6 +38,12 @@ type Context struct {
 	setup   *HookSetup
 	id      string
 	handler Handler
+	timeout time.Duration
+
+	// state and data are what an ephemeral context, having no
+	// task, uses instead of it
+	state *state.State
+	data  map[string]*json.RawMessage
 
 	cache  map[interface{}]interface{}
 	onDone []func() error
@@ -63,6 +70,35 @@ func NewContext(task *state.Task, setup *HookSetup, handler Handler) (*Context,
 	}, nil
 }
 
+// NewEphemeralContext returns a new context for the given snap that
+// is not tied to any hook, for use by the apps of the snap.
+func NewEphemeralContext(st *state.State, snapName string) (*Context, error) {
+	idBytes := make([]byte, 32)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate context ID: %s", err)
+	}
+
+	return &Context{
+		state: st,
+		setup: &HookSetup{Snap: snapName},
+		id:    base64.URLEncoding.EncodeToString(idBytes),
+		data:  make(map[string]*json.RawMessage),
+		cache: make(map[interface{}]interface{}),
+	}, nil
+}
+
+// IsEphemeral returns whether the context is not tied to a hook.
+func (c *Context) IsEphemeral() bool {
+	return c.task == nil
+}
+
+// Task returns the task of the hook of the context, and false for an
+// ephemeral context.
+func (c *Context) Task() (*state.Task, bool) {
+	return c.task, c.task != nil
+}
+
 // SnapName returns the name of the snap containing the hook.
 func (c *Context) SnapName() string {
 	return c.setup.Snap
@@ -92,14 +128,14 @@ func (c *Context) Handler() Handler {
 // and OnDone/Done).
 func (c *Context) Lock() {
 	c.mutex.Lock()
-	c.task.State().Lock()
+	c.State().Lock()
 	atomic.AddInt32(&c.mutexChecker, 1)
 }
 
 // Unlock releases the lock for this context.
 func (c *Context) Unlock() {
 	atomic.AddInt32(&c.mutexChecker, -1)
-	c.task.State().Unlock()
+	c.State().Unlock()
 	c.mutex.Unlock()
 }
 
@@ -122,7 +158,9 @@ func (c *Context) Set(key string, value interface{}) {
 	c.writing()
 
 	var data map[string]*json.RawMessage
-	if err := c.task.Get("hook-context", &data); err != nil && err != state.ErrNoState {
+	if c.task == nil {
+		data = c.data
+	} else if err := c.task.Get("hook-context", &data); err != nil && err != state.ErrNoState {
 		panic(fmt.Sprintf("internal error: cannot unmarshal context: %v", err))
 	}
 	if data == nil {
@@ -136,6 +174,10 @@ func (c *Context) Set(key string, value interface{}) {
 	raw := json.RawMessage(marshalledValue)
 	data[key] = &raw
 
+	if c.task == nil {
+		c.data = data
+		return
+	}
 	c.task.Set("hook-context", data)
 }
 
@@ -146,7 +188,9 @@ func (c *Context) Get(key string, value interface{}) error {
 	c.reading()
 
 	var data map[string]*json.RawMessage
-	if err := c.task.Get("hook-context", &data); err != nil {
+	if c.task == nil {
+		data = c.data
+	} else if err := c.task.Get("hook-context", &data); err != nil {
 		return err
 	}
 
@@ -165,6 +209,9 @@ func (c *Context) Get(key string, value interface{}) error {
 
 // State returns the state contained within the context
 func (c *Context) State() *state.State {
+	if c.task == nil {
+		return c.state
+	}
 	return c.task.State()
 }
 
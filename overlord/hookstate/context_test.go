@@ -133,3 +133,37 @@ func (s *contextSuite) TestDone(c *C) {
 	s.context.Done()
 	c.Check(called, Equals, true, Commentf("Expected finalizer to be called"))
 }
+
+func (s *contextSuite) TestEphemeralContext(c *C) {
+	st := state.New(nil)
+	context, err := NewEphemeralContext(st, "test-snap")
+	c.Assert(err, IsNil)
+
+	c.Check(context.IsEphemeral(), Equals, true)
+	c.Check(context.SnapName(), Equals, "test-snap")
+	c.Check(context.HookName(), Equals, "")
+	c.Check(context.State(), Equals, st)
+	c.Check(context.ID(), Not(Equals), "")
+	task, ok := context.Task()
+	c.Check(task, IsNil)
+	c.Check(ok, Equals, false)
+
+	context.Lock()
+	defer context.Unlock()
+
+	var output string
+	c.Check(context.Get("foo", &output), Equals, state.ErrNoState)
+	context.Set("foo", "bar")
+	c.Check(context.Get("foo", &output), IsNil)
+	c.Check(output, Equals, "bar")
+
+	// nothing ends up in the state
+	c.Check(st.Tasks(), HasLen, 0)
+}
+
+func (s *contextSuite) TestTask(c *C) {
+	c.Check(s.context.IsEphemeral(), Equals, false)
+	task, ok := s.context.Task()
+	c.Check(task, Equals, s.task)
+	c.Check(ok, Equals, true)
+}
@@ -0,0 +1,269 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// interfaceHookTarget returns whether the hook of the context is an
+// interface hook, and if so which side of the connection, "plug" or
+// "slot", and the name of the plug or slot it is about.
+func interfaceHookTarget(context *hookstate.Context) (side, name string, ok bool) {
+	hookName := context.HookName()
+	for _, prefix := range []string{"prepare-", "connect-"} {
+		for _, side := range []string{"plug", "slot"} {
+			if strings.HasPrefix(hookName, prefix+side+"-") {
+				return side, hookName[len(prefix+side+"-"):], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// attrsTask returns the task holding the attributes of the connection
+// the running interface hook is about. The context must be locked.
+func attrsTask(context *hookstate.Context) (*state.Task, error) {
+	var id string
+	if err := context.Get("attrs-task", &id); err != nil {
+		return nil, fmt.Errorf("internal error: cannot find the connection of hook %q: %v", context.HookName(), err)
+	}
+	task := context.State().Task(id)
+	if task == nil {
+		return nil, fmt.Errorf("internal error: cannot find the connection of hook %q", context.HookName())
+	}
+	return task, nil
+}
+
+// staticAttrs returns the attributes of the named plug or slot as
+// declared by the current revision of the given snap. The state must
+// be locked.
+func staticAttrs(st *state.State, snapName, side, name string) (map[string]interface{}, error) {
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return nil, err
+	}
+	if side == "plug" {
+		if plug, ok := info.Plugs[name]; ok {
+			return plug.Attrs, nil
+		}
+	} else {
+		if slot, ok := info.Slots[name]; ok {
+			return slot.Attrs, nil
+		}
+	}
+	return nil, fmt.Errorf("snap %q has no %s %q", snapName, side, name)
+}
+
+func mergeAttrs(static, dynamic map[string]interface{}) map[string]interface{} {
+	attrs := make(map[string]interface{}, len(static)+len(dynamic))
+	for k, v := range static {
+		attrs[k] = v
+	}
+	for k, v := range dynamic {
+		attrs[k] = v
+	}
+	return attrs
+}
+
+// connectionAttrs returns the attributes of the given side, "plug" or
+// "slot", of the connection of the named plug or slot of the snap of
+// the context, both the static ones and the ones set by the hooks. An
+// empty side means the side of the named plug or slot itself. The
+// context must be locked.
+func connectionAttrs(context *hookstate.Context, name, side string) (map[string]interface{}, error) {
+	if _, _, ok := interfaceHookTarget(context); ok {
+		return hookConnectionAttrs(context, name, side)
+	}
+	return connectedAttrs(context, name, side)
+}
+
+// hookConnectionAttrs returns the attributes of the connection the
+// running interface hook is about.
+func hookConnectionAttrs(context *hookstate.Context, name, side string) (map[string]interface{}, error) {
+	hookSide, hookTarget, _ := interfaceHookTarget(context)
+	if name != hookTarget {
+		return nil, fmt.Errorf("unknown %s %q", hookSide, name)
+	}
+	if side == "" {
+		side = hookSide
+	}
+
+	task, err := attrsTask(context)
+	if err != nil {
+		return nil, err
+	}
+	var dynamic map[string]interface{}
+	if err := task.Get(side+"-dynamic", &dynamic); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+
+	snapName := context.SnapName()
+	if side != hookSide {
+		// the other end of the connection
+		var plugRef interfaces.PlugRef
+		var slotRef interfaces.SlotRef
+		if side == "plug" {
+			err = task.Get("plug", &plugRef)
+			snapName, name = plugRef.Snap, plugRef.Name
+		} else {
+			err = task.Get("slot", &slotRef)
+			snapName, name = slotRef.Snap, slotRef.Name
+		}
+		if err != nil {
+			return nil, fmt.Errorf("internal error: cannot find the %s of the connection of hook %q: %v", side, context.HookName(), err)
+		}
+	}
+
+	static, err := staticAttrs(context.State(), snapName, side, name)
+	if err != nil {
+		return nil, err
+	}
+	return mergeAttrs(static, dynamic), nil
+}
+
+// snapPlugOrSlot returns whether the named plug or slot of the snap of
+// the context is a "plug" or a "slot". The context must be locked.
+func snapPlugOrSlot(context *hookstate.Context, name string) (string, error) {
+	info, err := snapstate.CurrentInfo(context.State(), context.SnapName())
+	if err != nil {
+		return "", err
+	}
+	if _, ok := info.Plugs[name]; ok {
+		return "plug", nil
+	}
+	if _, ok := info.Slots[name]; ok {
+		return "slot", nil
+	}
+	return "", fmt.Errorf("snap %q has no plug or slot named %q", context.SnapName(), name)
+}
+
+// snapConnections returns the connections of the named plug or slot of
+// the snap of the context. The context must be locked.
+func snapConnections(context *hookstate.Context, name, ownSide string) ([]*ifacestate.ConnInfo, error) {
+	conns, err := ifacestate.Connected(context.State(), context.SnapName(), name)
+	if err != nil {
+		return nil, err
+	}
+	// a plug and a slot of a snap may share a name
+	snapConns := conns[:0]
+	for _, conn := range conns {
+		if (ownSide == "plug" && conn.Plug.Snap == context.SnapName() && conn.Plug.Name == name) ||
+			(ownSide == "slot" && conn.Slot.Snap == context.SnapName() && conn.Slot.Name == name) {
+			snapConns = append(snapConns, conn)
+		}
+	}
+	return snapConns, nil
+}
+
+// connectedAttrs returns the attributes of the established connection
+// of the named plug or slot, outside of interface hooks.
+func connectedAttrs(context *hookstate.Context, name, side string) (map[string]interface{}, error) {
+	ownSide, err := snapPlugOrSlot(context, name)
+	if err != nil {
+		return nil, err
+	}
+	if side == "" {
+		side = ownSide
+	}
+	conns, err := snapConnections(context, name, ownSide)
+	if err != nil {
+		return nil, err
+	}
+
+	if side == ownSide {
+		static, err := staticAttrs(context.State(), context.SnapName(), side, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(conns) != 1 {
+			return mergeAttrs(static, nil), nil
+		}
+		if side == "plug" {
+			return mergeAttrs(static, conns[0].PlugDynamic), nil
+		}
+		return mergeAttrs(static, conns[0].SlotDynamic), nil
+	}
+
+	switch len(conns) {
+	case 0:
+		return nil, fmt.Errorf("%s %q is not connected", ownSide, name)
+	case 1:
+		// ok
+	default:
+		return nil, fmt.Errorf("cannot choose between the %d connections of %s %q", len(conns), ownSide, name)
+	}
+	conn := conns[0]
+	if side == "plug" {
+		static, err := staticAttrs(context.State(), conn.Plug.Snap, side, conn.Plug.Name)
+		if err != nil {
+			return nil, err
+		}
+		return mergeAttrs(static, conn.PlugDynamic), nil
+	}
+	static, err := staticAttrs(context.State(), conn.Slot.Snap, side, conn.Slot.Name)
+	if err != nil {
+		return nil, err
+	}
+	return mergeAttrs(static, conn.SlotDynamic), nil
+}
+
+// setConnectionAttrs sets attributes of the plug or slot the running
+// prepare hook is about. The context must be locked.
+func setConnectionAttrs(context *hookstate.Context, name string, values map[string]interface{}) error {
+	side, hookTarget, ok := interfaceHookTarget(context)
+	if !ok || !strings.HasPrefix(context.HookName(), "prepare-") {
+		return fmt.Errorf("interface attributes can only be set during the execution of prepare hooks")
+	}
+	if name != hookTarget {
+		return fmt.Errorf("unknown %s %q", side, name)
+	}
+
+	static, err := staticAttrs(context.State(), context.SnapName(), side, name)
+	if err != nil {
+		return err
+	}
+	task, err := attrsTask(context)
+	if err != nil {
+		return err
+	}
+	var dynamic map[string]interface{}
+	if err := task.Get(side+"-dynamic", &dynamic); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if dynamic == nil {
+		dynamic = make(map[string]interface{})
+	}
+	for k, v := range values {
+		if _, ok := static[k]; ok {
+			return fmt.Errorf("cannot change attribute %q as it was statically specified in the snap details", k)
+		}
+		dynamic[k] = v
+	}
+	task.Set(side+"-dynamic", dynamic)
+	return nil
+}
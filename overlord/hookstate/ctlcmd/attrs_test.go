@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type attrsSuite struct {
+	state       *state.State
+	connectTask *state.Task
+}
+
+var _ = Suite(&attrsSuite{})
+
+const attrsSnapYaml = `name: test-snap
+version: 1
+plugs:
+  plug:
+    interface: content
+    target: /some/target
+hooks:
+  prepare-plug-plug:
+  connect-plug-plug:
+`
+
+const attrsProducerYaml = `name: producer
+version: 1
+slots:
+  slot:
+    interface: content
+    read: /src
+`
+
+func (s *attrsSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, attrsSnapYaml, "", si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+	})
+	si = &snap.SideInfo{RealName: "producer", Revision: snap.R(2)}
+	snaptest.MockSnap(c, attrsProducerYaml, "", si)
+	snapstate.Set(s.state, "producer", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(2),
+	})
+
+	s.connectTask = s.state.NewTask("connect", "")
+	s.connectTask.Set("plug", interfaces.PlugRef{Snap: "test-snap", Name: "plug"})
+	s.connectTask.Set("slot", interfaces.SlotRef{Snap: "producer", Name: "slot"})
+	s.connectTask.Set("slot-dynamic", map[string]interface{}{"slot-dynamic": "slot-value"})
+	s.connectTask.Set("plug-dynamic", map[string]interface{}{"dynamic": "value"})
+	chg := s.state.NewChange("connect", "")
+	chg.AddTask(s.connectTask)
+}
+
+func (s *attrsSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *attrsSuite) hookContext(c *C, hookName string) *hookstate.Context {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: hookName}
+	task := hookstate.HookTask(s.state, "", setup, map[string]interface{}{"attrs-task": s.connectTask.ID()})
+	context, err := hookstate.NewContext(task, setup, hooktest.NewMockHandler())
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *attrsSuite) TestGetAttrs(c *C) {
+	context := s.hookContext(c, "connect-plug-plug")
+
+	stdout, stderr, err := ctlcmd.Run(context, []string{"get", ":plug", "target"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "/some/target\n")
+	c.Check(string(stderr), Equals, "")
+
+	stdout, _, err = ctlcmd.Run(context, []string{"get", ":plug", "dynamic"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "value\n")
+
+	stdout, _, err = ctlcmd.Run(context, []string{"get", ":plug"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `{
+	"dynamic": "value",
+	"target": "/some/target"
+}
+`)
+}
+
+func (s *attrsSuite) TestGetAttrsErrors(c *C) {
+	context := s.hookContext(c, "connect-plug-plug")
+	_, _, err := ctlcmd.Run(context, []string{"get", ":other", "target"})
+	c.Check(err, ErrorMatches, `unknown plug "other"`)
+
+	_, _, err = ctlcmd.Run(context, []string{"get", "--plug", "--slot", ":plug", "target"})
+	c.Check(err, ErrorMatches, "cannot use --plug and --slot together")
+
+	_, _, err = ctlcmd.Run(context, []string{"get", "--slot", "target"})
+	c.Check(err, ErrorMatches, `cannot use --plug or --slot without :<plug\|slot> argument`)
+
+	context = s.hookContext(c, "configure")
+	_, _, err = ctlcmd.Run(context, []string{"get", ":other", "target"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has no plug or slot named "other"`)
+
+	_, _, err = ctlcmd.Run(context, []string{"get", "--slot", ":plug", "read"})
+	c.Check(err, ErrorMatches, `plug "plug" is not connected`)
+}
+
+func (s *attrsSuite) TestGetAttrsOtherSide(c *C) {
+	context := s.hookContext(c, "connect-plug-plug")
+
+	stdout, _, err := ctlcmd.Run(context, []string{"get", "--slot", ":plug"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, `{
+	"read": "/src",
+	"slot-dynamic": "slot-value"
+}
+`)
+
+	stdout, _, err = ctlcmd.Run(context, []string{"get", "--plug", ":plug", "target"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "/some/target\n")
+}
+
+func (s *attrsSuite) TestGetAttrsOutsideHooks(c *C) {
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"test-snap:plug producer:slot": map[string]interface{}{
+			"interface":    "content",
+			"plug-dynamic": map[string]interface{}{"dynamic": "connected-value"},
+		},
+	})
+	s.state.Unlock()
+
+	ephemeral, err := hookstate.NewEphemeralContext(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	for _, context := range []*hookstate.Context{s.hookContext(c, "configure"), ephemeral} {
+		stdout, _, err := ctlcmd.Run(context, []string{"get", ":plug"})
+		c.Assert(err, IsNil)
+		c.Check(string(stdout), Equals, `{
+	"dynamic": "connected-value",
+	"target": "/some/target"
+}
+`)
+
+		stdout, _, err = ctlcmd.Run(context, []string{"get", "--slot", ":plug", "read"})
+		c.Assert(err, IsNil)
+		c.Check(string(stdout), Equals, "/src\n")
+	}
+}
+
+func (s *attrsSuite) TestSetAttrs(c *C) {
+	context := s.hookContext(c, "prepare-plug-plug")
+
+	stdout, stderr, err := ctlcmd.Run(context, []string{"set", ":plug", "foo=bar", "num=42"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	var dynamic map[string]interface{}
+	c.Assert(s.connectTask.Get("plug-dynamic", &dynamic), IsNil)
+	c.Check(dynamic, DeepEquals, map[string]interface{}{
+		"dynamic": "value",
+		"foo":     "bar",
+		"num":     float64(42),
+	})
+}
+
+func (s *attrsSuite) TestSetAttrsErrors(c *C) {
+	context := s.hookContext(c, "connect-plug-plug")
+	_, _, err := ctlcmd.Run(context, []string{"set", ":plug", "foo=bar"})
+	c.Check(err, ErrorMatches, "interface attributes can only be set during the execution of prepare hooks")
+
+	context = s.hookContext(c, "prepare-plug-plug")
+	_, _, err = ctlcmd.Run(context, []string{"set", ":plug", "target=/other"})
+	c.Check(err, ErrorMatches, `cannot change attribute "target" as it was statically specified in the snap details`)
+
+	_, _, err = ctlcmd.Run(context, []string{"set", ":plug", "foo"})
+	c.Check(err, ErrorMatches, `invalid parameter: "foo" \(want key=value\)`)
+}
@@ -22,6 +22,7 @@ package ctlcmd
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/configstate"
@@ -36,6 +37,9 @@ type getCommand struct {
 
 	Document bool `short:"d" description:"always return document, even with single key"`
 	Typed    bool `short:"t" description:"strict typing with nulls and quoted strings"`
+
+	ForcePlugSide bool `long:"plug" description:"return attribute values from the plug side of the connection"`
+	ForceSlotSide bool `long:"slot" description:"return attribute values from the slot side of the connection"`
 }
 
 var shortGetHelp = i18n.G("Prints configuration options")
@@ -57,6 +61,18 @@ Nested values may be retrieved via a dotted path:
 
     $ snapctl get author.name
     frank
+
+The attributes of a plug or slot of the snap may be retrieved by
+prefixing its name with a colon, those of the other end of its connection
+with --plug or --slot:
+
+    $ snapctl get :myplug path
+    /some/path
+    $ snapctl get --slot :myplug path
+    /other/path
+
+During the execution of interface hooks these are the attributes of the
+connection being made, otherwise those of the established connection.
 `)
 
 func init() {
@@ -73,6 +89,17 @@ func (c *getCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot use -d and -t together")
 	}
 
+	if c.ForcePlugSide && c.ForceSlotSide {
+		return fmt.Errorf("cannot use --plug and --slot together")
+	}
+
+	if strings.HasPrefix(c.Positional.Keys[0], ":") {
+		return c.getInterfaceAttrs(c.Positional.Keys[0][1:], c.Positional.Keys[1:])
+	}
+	if c.ForcePlugSide || c.ForceSlotSide {
+		return fmt.Errorf("cannot use --plug or --slot without :<plug|slot> argument")
+	}
+
 	patch := make(map[string]interface{})
 	context.Lock()
 	transaction := configstate.ContextTransaction(context)
@@ -92,9 +119,46 @@ func (c *getCommand) Execute(args []string) error {
 		}
 	}
 
-	var confToPrint interface{} = patch
-	if !c.Document && len(c.Positional.Keys) == 1 {
-		confToPrint = patch[c.Positional.Keys[0]]
+	return c.printValues(c.Positional.Keys, patch)
+}
+
+func (c *getCommand) getInterfaceAttrs(name string, keys []string) error {
+	var side string
+	if c.ForcePlugSide {
+		side = "plug"
+	} else if c.ForceSlotSide {
+		side = "slot"
+	}
+
+	context := c.context()
+	context.Lock()
+	attrs, err := connectionAttrs(context, name, side)
+	context.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		// all of them
+		c.Document = true
+		return c.printValues(keys, attrs)
+	}
+
+	values := make(map[string]interface{})
+	for _, key := range keys {
+		if value, ok := attrs[key]; ok {
+			values[key] = value
+		}
+	}
+	return c.printValues(keys, values)
+}
+
+// printValues prints the values of the given keys, either as a
+// document or, for a single key, on its own.
+func (c *getCommand) printValues(keys []string, values map[string]interface{}) error {
+	var confToPrint interface{} = values
+	if !c.Document && len(keys) == 1 {
+		confToPrint = values[keys[0]]
 	}
 
 	if c.Typed && confToPrint == nil {
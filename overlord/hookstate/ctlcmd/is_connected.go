@@ -0,0 +1,82 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+// UnsuccessfulError carries the exit code a command is to finish
+// with, without it being an error in running it.
+type UnsuccessfulError struct {
+	ExitCode int
+}
+
+func (e *UnsuccessfulError) Error() string {
+	return fmt.Sprintf("unsuccessful with exit code: %d", e.ExitCode)
+}
+
+type isConnectedCommand struct {
+	baseCommand
+
+	Positional struct {
+		PlugOrSlot string `positional-arg-name:"<plug|slot>" description:"plug or slot of the snap"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortIsConnectedHelp = i18n.G("Returns success if the given plug or slot is connected")
+var longIsConnectedHelp = i18n.G(`
+The is-connected command returns success if the given plug or slot of the
+current snap is connected, and fails with exit code 1 otherwise.
+
+    $ snapctl is-connected myplug && echo connected
+    connected
+`)
+
+func init() {
+	addCommand("is-connected", shortIsConnectedHelp, longIsConnectedHelp, func() command { return &isConnectedCommand{} })
+}
+
+func (c *isConnectedCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf("cannot check connection status without a context")
+	}
+
+	name := c.Positional.PlugOrSlot
+
+	context.Lock()
+	defer context.Unlock()
+
+	side, err := snapPlugOrSlot(context, name)
+	if err != nil {
+		return err
+	}
+	conns, err := snapConnections(context, name, side)
+	if err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		return &UnsuccessfulError{ExitCode: 1}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type isConnectedSuite struct {
+	state   *state.State
+	context *hookstate.Context
+}
+
+var _ = Suite(&isConnectedSuite{})
+
+const isConnectedSnapYaml = `name: test-snap
+version: 1
+plugs:
+  plug1:
+    interface: network
+  plug2:
+    interface: home
+slots:
+  slot1:
+    interface: x11
+`
+
+func (s *isConnectedSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, isConnectedSnapYaml, "", si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+	})
+	s.state.Set("conns", map[string]interface{}{
+		"test-snap:plug1 core:network":   map[string]interface{}{"interface": "network"},
+		"other-snap:x11 test-snap:slot1": map[string]interface{}{"interface": "x11"},
+	})
+
+	var err error
+	s.context, err = hookstate.NewEphemeralContext(s.state, "test-snap")
+	c.Assert(err, IsNil)
+}
+
+func (s *isConnectedSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *isConnectedSuite) TestIsConnected(c *C) {
+	for _, name := range []string{"plug1", "slot1"} {
+		stdout, stderr, err := ctlcmd.Run(s.context, []string{"is-connected", name})
+		c.Check(err, IsNil, Commentf(name))
+		c.Check(string(stdout), Equals, "")
+		c.Check(string(stderr), Equals, "")
+	}
+}
+
+func (s *isConnectedSuite) TestIsNotConnected(c *C) {
+	_, _, err := ctlcmd.Run(s.context, []string{"is-connected", "plug2"})
+	c.Assert(err, FitsTypeOf, &ctlcmd.UnsuccessfulError{})
+	c.Check(err.(*ctlcmd.UnsuccessfulError).ExitCode, Equals, 1)
+}
+
+func (s *isConnectedSuite) TestIsConnectedErrors(c *C) {
+	_, _, err := ctlcmd.Run(s.context, []string{"is-connected", "foo"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has no plug or slot named "foo"`)
+
+	_, _, err = ctlcmd.Run(nil, []string{"is-connected", "plug1"})
+	c.Check(err, ErrorMatches, "cannot check connection status without a context")
+}
@@ -0,0 +1,202 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type servicesPositional struct {
+	ServiceNames []string `positional-arg-name:"<service>" required:"1"`
+}
+
+type startCommand struct {
+	baseCommand
+
+	Positional servicesPositional `positional-args:"yes" required:"yes"`
+	Enable     bool               `long:"enable" description:"as well as starting the services, arrange for them to start on boot"`
+}
+
+type stopCommand struct {
+	baseCommand
+
+	Positional servicesPositional `positional-args:"yes" required:"yes"`
+	Disable    bool               `long:"disable" description:"as well as stopping the services, arrange for them not to start on boot"`
+}
+
+type restartCommand struct {
+	baseCommand
+
+	Positional servicesPositional `positional-args:"yes" required:"yes"`
+}
+
+var (
+	shortStartHelp   = i18n.G("Start services")
+	shortStopHelp    = i18n.G("Stop services")
+	shortRestartHelp = i18n.G("Restart services")
+)
+
+var longServicesHelp = i18n.G(`
+The %[1]s command %[1]ss the given services of the current snap, given
+as <snap>.<app>, or all of them when given just the name of the snap.
+
+When run from a hook the services are %[1]sed as part of the same change
+after the hook completes and before the rest of the change carries on,
+otherwise right away.
+`)
+
+func init() {
+	addCommand("start", shortStartHelp, fmt.Sprintf(longServicesHelp, "start"), func() command { return &startCommand{} })
+	addCommand("stop", shortStopHelp, fmt.Sprintf(longServicesHelp, "stop"), func() command { return &stopCommand{} })
+	addCommand("restart", shortRestartHelp, fmt.Sprintf(longServicesHelp, "restart"), func() command { return &restartCommand{} })
+}
+
+func (c *startCommand) Execute(args []string) error {
+	inst := &servicestate.Instruction{Action: "start", Names: c.Positional.ServiceNames, Enable: c.Enable}
+	return runServiceCommand(c.context(), inst)
+}
+
+func (c *stopCommand) Execute(args []string) error {
+	inst := &servicestate.Instruction{Action: "stop", Names: c.Positional.ServiceNames, Disable: c.Disable}
+	return runServiceCommand(c.context(), inst)
+}
+
+func (c *restartCommand) Execute(args []string) error {
+	inst := &servicestate.Instruction{Action: "restart", Names: c.Positional.ServiceNames}
+	return runServiceCommand(c.context(), inst)
+}
+
+// snapServices returns the services of the snap of the context the
+// given names refer to. The context must be locked.
+func snapServices(context *hookstate.Context, action string, names []string) ([]*snap.AppInfo, error) {
+	info, err := snapstate.CurrentInfo(context.State(), context.SnapName())
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []*snap.AppInfo
+	seen := make(map[string]bool)
+	add := func(app *snap.AppInfo) {
+		if !seen[app.Name] {
+			seen[app.Name] = true
+			apps = append(apps, app)
+		}
+	}
+	for _, name := range names {
+		snapName, appName := name, ""
+		if i := strings.IndexByte(name, '.'); i >= 0 {
+			snapName, appName = name[:i], name[i+1:]
+		}
+		if snapName != context.SnapName() {
+			return nil, fmt.Errorf("cannot %s %q: not a service of snap %q", action, name, context.SnapName())
+		}
+		if appName == "" {
+			for _, app := range info.Apps {
+				if app.Daemon != "" {
+					add(app)
+				}
+			}
+			continue
+		}
+		app, ok := info.Apps[appName]
+		if !ok || app.Daemon == "" {
+			return nil, fmt.Errorf("cannot %s %q: not a service of snap %q", action, name, context.SnapName())
+		}
+		add(app)
+	}
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("cannot %s: snap %q has no services", action, context.SnapName())
+	}
+	return apps, nil
+}
+
+// runServiceCommand queues the given service instruction, as part of
+// the change of the running hook if any, or as a change of its own.
+func runServiceCommand(context *hookstate.Context, inst *servicestate.Instruction) error {
+	if context == nil {
+		return fmt.Errorf("cannot %s services without a context", inst.Action)
+	}
+
+	context.Lock()
+	defer context.Unlock()
+	st := context.State()
+
+	apps, err := snapServices(context, inst.Action, inst.Names)
+	if err != nil {
+		return err
+	}
+
+	task, ok := context.Task()
+	if !ok {
+		ts, err := servicestate.Control(st, apps, inst)
+		if err != nil {
+			return err
+		}
+		summary := fmt.Sprintf(i18n.G("Running service command %q for snap %q"), inst.Action, context.SnapName())
+		chg := st.NewChange("service-control", summary)
+		chg.AddAll(ts)
+		return nil
+	}
+
+	chg := task.Change()
+	ts, err := servicestate.ControlInChange(st, apps, inst, chg.ID())
+	if err != nil {
+		return err
+	}
+	var queued []string
+	if err := task.Get("queued-service-tasks", &queued); err != nil && err != state.ErrNoState {
+		return err
+	}
+	queuedByID := make(map[string]bool, len(queued))
+	for _, id := range queued {
+		queuedByID[id] = true
+	}
+	// run after the hook and whatever was queued by it before, and
+	// before the rest of its change
+	for _, halt := range task.HaltTasks() {
+		if queuedByID[halt.ID()] {
+			for _, t := range ts.Tasks() {
+				t.WaitFor(halt)
+			}
+		} else {
+			halt.WaitAll(ts)
+		}
+	}
+	ts.WaitFor(task)
+	for _, lane := range task.Lanes() {
+		if lane != 0 {
+			ts.JoinLane(lane)
+		}
+	}
+	chg.AddAll(ts)
+	for _, t := range ts.Tasks() {
+		queued = append(queued, t.ID())
+	}
+	task.Set("queued-service-tasks", queued)
+	return nil
+}
@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type servicesSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&servicesSuite{})
+
+const servicesSnapYaml = `name: test-snap
+version: 1
+apps:
+  svc1:
+    daemon: simple
+  svc2:
+    daemon: forking
+  cmd:
+`
+
+func (s *servicesSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, servicesSnapYaml, "", si)
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  snap.R(1),
+	})
+}
+
+func (s *servicesSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *servicesSuite) serviceActions(c *C, tasks []*state.Task) []map[string]interface{} {
+	var actions []map[string]interface{}
+	for _, t := range tasks {
+		if t.Kind() != "service-control" {
+			continue
+		}
+		var action map[string]interface{}
+		c.Assert(t.Get("service-action", &action), IsNil)
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+func (s *servicesSuite) TestServicesFromHook(c *C) {
+	s.state.Lock()
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	task := hookstate.HookTask(s.state, "", setup, nil)
+	chg := s.state.NewChange("configure-snap", "")
+	chg.AddTask(task)
+	// the change of the hook is not a conflict
+	link := s.state.NewTask("link-snap", "")
+	link.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap"}})
+	link.WaitFor(task)
+	chg.AddTask(link)
+	s.state.Unlock()
+
+	context, err := hookstate.NewContext(task, setup, hooktest.NewMockHandler())
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"restart", "test-snap.svc1"})
+	c.Assert(err, IsNil)
+	_, _, err = ctlcmd.Run(context, []string{"stop", "test-snap.svc2"})
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 4)
+	restart, stop := tasks[2], tasks[3]
+	c.Check(restart.Kind(), Equals, "service-control")
+	c.Check(stop.Kind(), Equals, "service-control")
+	// the queued tasks run in order after the hook and before the
+	// rest of the change
+	c.Check(restart.WaitTasks(), DeepEquals, []*state.Task{task})
+	c.Check(stop.WaitTasks(), DeepEquals, []*state.Task{restart, task})
+	c.Check(link.WaitTasks(), DeepEquals, []*state.Task{task, restart, stop})
+	c.Check(s.serviceActions(c, tasks), DeepEquals, []map[string]interface{}{{
+		"snap-name": "test-snap",
+		"action":    "restart",
+		"apps":      []interface{}{"svc1"},
+	}, {
+		"snap-name": "test-snap",
+		"action":    "stop",
+		"apps":      []interface{}{"svc2"},
+	}})
+}
+
+func (s *servicesSuite) TestServicesFromApp(c *C) {
+	context, err := hookstate.NewEphemeralContext(s.state, "test-snap")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"stop", "--disable", "test-snap"})
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "service-control")
+	c.Check(chgs[0].Summary(), Equals, `Running service command "stop" for snap "test-snap"`)
+	c.Check(s.serviceActions(c, chgs[0].Tasks()), DeepEquals, []map[string]interface{}{{
+		"snap-name": "test-snap",
+		"action":    "stop",
+		"apps":      []interface{}{"svc1", "svc2"},
+		"disable":   true,
+	}})
+}
+
+func (s *servicesSuite) TestServicesErrors(c *C) {
+	context, err := hookstate.NewEphemeralContext(s.state, "test-snap")
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"start", "other-snap.svc"}, `cannot start "other-snap.svc": not a service of snap "test-snap"`},
+		{[]string{"start", "test-snap.cmd"}, `cannot start "test-snap.cmd": not a service of snap "test-snap"`},
+		{[]string{"stop", "test-snap.nope"}, `cannot stop "test-snap.nope": not a service of snap "test-snap"`},
+		{[]string{"restart", "--enable", "test-snap"}, `unknown flag .enable.`},
+	} {
+		_, _, err := ctlcmd.Run(context, t.args)
+		c.Check(err, ErrorMatches, t.err, Commentf("%v", t.args))
+	}
+
+	_, _, err = ctlcmd.Run(nil, []string{"start", "test-snap"})
+	c.Check(err, ErrorMatches, "cannot start services without a context")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+}
@@ -48,6 +48,12 @@ returns successfully.
 Nested values may be modified via a dotted path:
 
     $ snapctl set author.name=frank
+
+During the execution of prepare-plug and prepare-slot hooks, attributes of
+the plug or slot being connected may be set by prefixing its name with a
+colon:
+
+    $ snapctl set :myplug path=/some/path
 `)
 
 func init() {
@@ -60,6 +66,10 @@ func (s *setCommand) Execute(args []string) error {
 		return fmt.Errorf("cannot set without a context")
 	}
 
+	if strings.HasPrefix(s.Positional.ConfValues[0], ":") {
+		return s.setInterfaceAttrs(s.Positional.ConfValues[0][1:], s.Positional.ConfValues[1:])
+	}
+
 	context.Lock()
 	transaction := configstate.ContextTransaction(context)
 	context.Unlock()
@@ -82,3 +92,24 @@ func (s *setCommand) Execute(args []string) error {
 
 	return nil
 }
+
+func (s *setCommand) setInterfaceAttrs(name string, attrValues []string) error {
+	values := make(map[string]interface{}, len(attrValues))
+	for _, attrValue := range attrValues {
+		parts := strings.SplitN(attrValue, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), attrValue)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(parts[1]), &value); err != nil {
+			// Not valid JSON-- just save the string as-is.
+			value = parts[1]
+		}
+		values[parts[0]] = value
+	}
+
+	context := s.context()
+	context.Lock()
+	defer context.Unlock()
+	return setConnectionAttrs(context, name, values)
+}
@@ -0,0 +1,37 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hookstate
+
+import (
+	"time"
+)
+
+// MockHookTimeouts sets the default timeout of hooks and how long they
+// are given to exit once asked to terminate.
+func MockHookTimeouts(timeout, killWait time.Duration) (restore func()) {
+	oldTimeout := defaultHookTimeout
+	oldKillWait := hookKillWait
+	defaultHookTimeout = timeout
+	hookKillWait = killWait
+	return func() {
+		defaultHookTimeout = oldTimeout
+		hookKillWait = oldKillWait
+	}
+}
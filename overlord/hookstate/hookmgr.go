@@ -28,9 +28,12 @@ import (
 	"os/exec"
 	"regexp"
 	"sync"
+	"syscall"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -70,6 +73,10 @@ type HookSetup struct {
 	Revision snap.Revision `json:"revision"`
 	Hook     string        `json:"hook"`
 	Optional bool          `json:"optional,omitempty"`
+
+	// IgnoreError makes a failure of the hook be logged instead of
+	// failing the task.
+	IgnoreError bool `json:"ignore-error,omitempty"`
 }
 
 // Manager returns a new HookManager.
@@ -82,7 +89,8 @@ func Manager(s *state.State) (*HookManager, error) {
 		contexts:   make(map[string]*Context),
 	}
 
-	runner.AddHandler("run-hook", manager.doRunHook, nil)
+	runner.AddHandler("run-hook", manager.doRunHook, manager.undoRunHook)
+	registerSnapHooks(manager)
 
 	return manager, nil
 }
@@ -171,7 +179,8 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 		return fmt.Errorf("cannot read %q snap details: %v", hooksup.Snap, err)
 	}
 
-	hookExists := info.Hooks[hooksup.Hook] != nil
+	hookInfo := info.Hooks[hooksup.Hook]
+	hookExists := hookInfo != nil
 	if !hookExists && !hooksup.Optional {
 		return fmt.Errorf("snap %q has no %q hook", hooksup.Snap, hooksup.Hook)
 	}
@@ -180,6 +189,9 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	if err != nil {
 		return err
 	}
+	if hookExists {
+		context.timeout = hookTimeout(hookInfo)
+	}
 
 	// Obtain a handler for this hook. The repository returns a list since it's
 	// possible for regular expressions to overlap, but multiple handlers is an
@@ -213,12 +225,37 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	if hookExists {
 		output, err := runHook(context, tomb)
 		if err != nil {
-			err = osutil.OutputErr(output, err)
+			_, timedOut := err.(*HookTimeoutError)
+			if timedOut {
+				// keep what the hook had to say before being stopped
+				if len(output) > 0 {
+					task.State().Lock()
+					task.Logf("%s", bytes.TrimSpace(output))
+					task.State().Unlock()
+				}
+			} else {
+				err = osutil.OutputErr(output, err)
+			}
 			if handlerErr := context.Handler().Error(err); handlerErr != nil {
 				return handlerErr
 			}
 
-			return err
+			if !hooksup.IgnoreError {
+				if timedOut {
+					task.State().Lock()
+					task.Set("error-kind", ErrorKindHookTimeout)
+					task.State().Unlock()
+				}
+				return err
+			}
+			task.State().Lock()
+			task.Errorf("ignoring failure in hook %q: %v", hooksup.Hook, err)
+			task.State().Unlock()
+		} else if len(output) > 0 {
+			// keep what the hook had to say around
+			task.State().Lock()
+			task.Logf("%s", bytes.TrimSpace(output))
+			task.State().Unlock()
 		}
 	}
 
@@ -235,8 +272,54 @@ func (m *HookManager) doRunHook(task *state.Task, tomb *tomb.Tomb) error {
 	return nil
 }
 
+// undoRunHook does nothing, hooks are not undone. Having it keeps the
+// tasks around a hook being undone in order, as the hook is then only
+// undone once the tasks waiting for it were.
+func (m *HookManager) undoRunHook(task *state.Task, tomb *tomb.Tomb) error {
+	return nil
+}
+
+// defaultHookTimeout is how long a hook that does not declare a
+// timeout of its own may run before being stopped. It can be changed
+// with the SNAPD_HOOK_TIMEOUT environment variable.
+var defaultHookTimeout = 10 * time.Minute
+
+// hookKillWait is how long a hook that timed out or was aborted is given
+// to exit once asked to terminate, before being killed.
+var hookKillWait = 5 * time.Second
+
+// ErrorKindHookTimeout is the "error-kind" set on a run-hook task that
+// failed because its hook timed out.
+const ErrorKindHookTimeout = "hook-timeout"
+
+// HookTimeoutError is returned when a hook ran for longer than it was
+// allowed to and was stopped.
+type HookTimeoutError struct {
+	Hook    string
+	Timeout time.Duration
+}
+
+func (e *HookTimeoutError) Error() string {
+	return fmt.Sprintf("hook %q timed out after %s", e.Hook, e.Timeout)
+}
+
+// hookTimeout returns how long the given hook may run.
+func hookTimeout(hookInfo *snap.HookInfo) time.Duration {
+	if hookInfo.Timeout > 0 {
+		return time.Duration(hookInfo.Timeout)
+	}
+	if s := os.Getenv("SNAPD_HOOK_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err == nil && timeout > 0 {
+			return timeout
+		}
+		logger.Noticef("ignoring invalid SNAPD_HOOK_TIMEOUT value %q", s)
+	}
+	return defaultHookTimeout
+}
+
 func runHookImpl(c *Context, tomb *tomb.Tomb) ([]byte, error) {
-	return runHookAndWait(c.SnapName(), c.SnapRevision(), c.HookName(), c.ID(), tomb)
+	return runHookAndWait(c.SnapName(), c.SnapRevision(), c.HookName(), c.ID(), c.timeout, tomb)
 }
 
 var runHook = runHookImpl
@@ -250,9 +333,13 @@ func MockRunHook(hookInvoke func(c *Context, tomb *tomb.Tomb) ([]byte, error)) (
 	}
 }
 
-func runHookAndWait(snapName string, revision snap.Revision, hookName, hookContext string, tomb *tomb.Tomb) ([]byte, error) {
+func runHookAndWait(snapName string, revision snap.Revision, hookName, hookContext string, timeout time.Duration, tomb *tomb.Tomb) ([]byte, error) {
 	command := exec.Command("snap", "run", "--hook", hookName, "-r", revision.String(), snapName)
 
+	// Run the hook in its own process group, so that all of it can be
+	// stopped if it times out.
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
 	// Make sure the hook has its context defined so it can communicate via the
 	// REST API.
 	command.Env = append(os.Environ(), fmt.Sprintf("SNAP_CONTEXT=%s", hookContext))
@@ -276,16 +363,48 @@ func runHookAndWait(snapName string, revision snap.Revision, hookName, hookConte
 		close(hookCompleted)
 	}()
 
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timedOut = timer.C
+	}
+
 	select {
 	// Hook completed; it may or may not have been successful.
 	case <-hookCompleted:
 		return buffer.Bytes(), hookError
 
+	// Hook ran for too long.
+	case <-timedOut:
+		killHook(command, hookCompleted)
+		return buffer.Bytes(), &HookTimeoutError{Hook: hookName, Timeout: timeout}
+
 	// Hook was aborted.
 	case <-tomb.Dying():
-		if err := command.Process.Kill(); err != nil {
-			return nil, fmt.Errorf("cannot abort hook %q: %s", hookName, err)
-		}
+		killHook(command, hookCompleted)
 		return nil, fmt.Errorf("hook %q aborted", hookName)
 	}
 }
+
+// killHook stops a hook that is still running, first asking it to
+// terminate and then, if it does not do so in time, killing it. It
+// returns once the hook is gone.
+func killHook(command *exec.Cmd, hookCompleted <-chan struct{}) {
+	// a negative pid signals the whole process group
+	pgid := -command.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+		logger.Noticef("cannot terminate hook process group %d: %v", -pgid, err)
+	}
+
+	select {
+	case <-hookCompleted:
+		return
+	case <-time.After(hookKillWait):
+	}
+
+	if err := syscall.Kill(pgid, syscall.SIGKILL); err != nil {
+		logger.Noticef("cannot kill hook process group %d: %v", -pgid, err)
+	}
+	<-hookCompleted
+}
@@ -21,12 +21,17 @@ package hookstate_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -243,6 +248,26 @@ func (s *hookManagerSuite) TestHookTaskCanKillHook(c *C) {
 	checkTaskLogContains(c, s.task, `.*hook "configure" aborted.*`)
 }
 
+func (s *hookManagerSuite) TestHookTaskStopKillsHookProcessGroup(c *C) {
+	restore := hookstate.MockHookTimeouts(time.Minute, 100*time.Millisecond)
+	defer restore()
+	marker := filepath.Join(c.MkDir(), "terminated")
+	// the hook leaves a child behind that notes being terminated
+	s.command = testutil.MockCommand(c, "snap", fmt.Sprintf(`sh -c 'trap "touch %s; exit" TERM; while true; do sleep 0.1; done' &
+wait`, marker))
+
+	s.manager.Ensure()
+	// give the hook time to start its child
+	time.Sleep(200 * time.Millisecond)
+	s.manager.Stop()
+
+	c.Check(osutil.FileExists(marker), Equals, true)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	checkTaskLogContains(c, s.task, `.*hook "configure" aborted.*`)
+}
+
 func (s *hookManagerSuite) TestHookTaskCorrectlyIncludesContext(c *C) {
 	// Force the snap command to exit with a failure and print to stderr so we
 	// can catch and verify it.
@@ -431,3 +456,144 @@ func checkTaskLogContains(c *C, task *state.Task, pattern string) {
 
 	c.Check(found, Equals, true, Commentf("Expected to find regex %q in task log: %v", pattern, task.Log()))
 }
+
+func (s *hookManagerSuite) TestHookTaskLogsOutput(c *C) {
+	s.command = testutil.MockCommand(c, "snap", "echo 'migrated all the data'")
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	checkTaskLogContains(c, s.task, ".*migrated all the data")
+}
+
+func (s *hookManagerSuite) TestHookTaskIgnoreError(c *C) {
+	s.command = testutil.MockCommand(
+		c, "snap", ">&2 echo 'hook failed at user request'; exit 1")
+
+	hooksup := &hookstate.HookSetup{
+		Snap:        "test-snap",
+		Hook:        "configure",
+		Revision:    snap.R(1),
+		IgnoreError: true,
+	}
+	s.state.Lock()
+	s.task.Set("hook-setup", hooksup)
+	s.state.Unlock()
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.mockHandler.ErrorCalled, Equals, true)
+	c.Check(s.task.Status(), Equals, state.DoneStatus)
+	c.Check(s.change.Status(), Equals, state.DoneStatus)
+	checkTaskLogContains(c, s.task, `.*ignoring failure in hook "configure":.*failed at user request.*`)
+}
+
+func (s *hookManagerSuite) TestHookTaskTimeout(c *C) {
+	restore := hookstate.MockHookTimeouts(100*time.Millisecond, time.Minute)
+	defer restore()
+	s.command = testutil.MockCommand(c, "snap", `echo 'working on it'
+trap 'echo terminated; exit 1' TERM
+while true; do sleep 0.1; done`)
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.mockHandler.ErrorCalled, Equals, true)
+	c.Check(s.mockHandler.Err, FitsTypeOf, &hookstate.HookTimeoutError{})
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	c.Check(s.change.Status(), Equals, state.ErrorStatus)
+	checkTaskLogContains(c, s.task, `(?s)working on it.*terminated`)
+	checkTaskLogContains(c, s.task, `.*hook "configure" timed out after 100ms`)
+	var kind string
+	c.Assert(s.task.Get("error-kind", &kind), IsNil)
+	c.Check(kind, Equals, hookstate.ErrorKindHookTimeout)
+}
+
+func (s *hookManagerSuite) TestHookTaskTimeoutKillsHook(c *C) {
+	restore := hookstate.MockHookTimeouts(100*time.Millisecond, 100*time.Millisecond)
+	defer restore()
+	s.command = testutil.MockCommand(c, "snap", `trap 'echo ignoring' TERM
+while true; do sleep 0.1; done`)
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	checkTaskLogContains(c, s.task, `ignoring`)
+	checkTaskLogContains(c, s.task, `.*hook "configure" timed out after 100ms`)
+}
+
+func (s *hookManagerSuite) TestHookTaskTimeoutFromSnapYaml(c *C) {
+	restore := hookstate.MockHookTimeouts(time.Minute, 100*time.Millisecond)
+	defer restore()
+	s.command = testutil.MockCommand(c, "snap", "while true; do sleep 0.1; done")
+
+	sideInfo := &snap.SideInfo{RealName: "test-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1.0
+hooks:
+    configure:
+        timeout: 200ms
+`, snapContents, sideInfo)
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	checkTaskLogContains(c, s.task, `.*hook "configure" timed out after 200ms`)
+}
+
+func (s *hookManagerSuite) TestHookTaskTimeoutFromEnvironment(c *C) {
+	restore := hookstate.MockHookTimeouts(time.Minute, 100*time.Millisecond)
+	defer restore()
+	os.Setenv("SNAPD_HOOK_TIMEOUT", "150ms")
+	defer os.Unsetenv("SNAPD_HOOK_TIMEOUT")
+	s.command = testutil.MockCommand(c, "snap", "while true; do sleep 0.1; done")
+
+	s.manager.Ensure()
+	s.manager.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.ErrorStatus)
+	checkTaskLogContains(c, s.task, `.*hook "configure" timed out after 150ms`)
+}
+
+func (s *hookManagerSuite) TestSnapHooksHaveHandlers(c *C) {
+	for _, hook := range []string{"install", "pre-refresh", "post-refresh", "remove"} {
+		s.state.Lock()
+		task := hookstate.HookTask(s.state, "test summary", &hookstate.HookSetup{
+			Snap:     "test-snap",
+			Hook:     hook,
+			Optional: true,
+		}, nil)
+		chg := s.state.NewChange("kind", "summary")
+		chg.AddTask(task)
+		s.state.Unlock()
+
+		s.manager.Ensure()
+		s.manager.Wait()
+
+		s.state.Lock()
+		c.Check(task.Status(), Equals, state.DoneStatus, Commentf("hook %q: %v", hook, task.Log()))
+		s.state.Unlock()
+	}
+}
@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hookstate
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func init() {
+	snapstate.SetupInstallHook = SetupInstallHook
+	snapstate.SetupPreRefreshHook = SetupPreRefreshHook
+	snapstate.SetupPostRefreshHook = SetupPostRefreshHook
+	snapstate.SetupRemoveHook = SetupRemoveHook
+}
+
+// SetupInstallHook returns a task to run the install hook of the
+// given snap, if present.
+func SetupInstallHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "install",
+		Optional: true,
+	}
+	summary := fmt.Sprintf(i18n.G("Run install hook of %q snap if present"), snapName)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+// SetupPreRefreshHook returns a task to run the pre-refresh hook of
+// the currently installed revision of the given snap, if present.
+func SetupPreRefreshHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "pre-refresh",
+		Optional: true,
+	}
+	summary := fmt.Sprintf(i18n.G("Run pre-refresh hook of %q snap if present"), snapName)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+// SetupPostRefreshHook returns a task to run the post-refresh hook of
+// the new revision of the given snap, if present.
+func SetupPostRefreshHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:     snapName,
+		Hook:     "post-refresh",
+		Optional: true,
+	}
+	summary := fmt.Sprintf(i18n.G("Run post-refresh hook of %q snap if present"), snapName)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+// SetupRemoveHook returns a task to run the remove hook of the given
+// snap, if present. A failing remove hook does not prevent the
+// removal of the snap.
+func SetupRemoveHook(st *state.State, snapName string) *state.Task {
+	hooksup := &HookSetup{
+		Snap:        snapName,
+		Hook:        "remove",
+		Optional:    true,
+		IgnoreError: true,
+	}
+	summary := fmt.Sprintf(i18n.G("Run remove hook of %q snap if present"), snapName)
+	return HookTask(st, summary, hooksup, nil)
+}
+
+// snapHookHandler is the handler of the hooks run around the
+// lifecycle of a snap and its interface connections, which need no
+// special treatment.
+type snapHookHandler struct{}
+
+func newSnapHookHandler(context *Context) Handler {
+	return snapHookHandler{}
+}
+
+// Before is called just before the hook runs.
+func (h snapHookHandler) Before() error {
+	return nil
+}
+
+// Done is called upon successful completion of the hook.
+func (h snapHookHandler) Done() error {
+	return nil
+}
+
+// Error is called if the hook encounters an error while running.
+func (h snapHookHandler) Error(err error) error {
+	return nil
+}
+
+func registerSnapHooks(m *HookManager) {
+	m.Register(regexp.MustCompile("^install$"), newSnapHookHandler)
+	m.Register(regexp.MustCompile("^pre-refresh$"), newSnapHookHandler)
+	m.Register(regexp.MustCompile("^post-refresh$"), newSnapHookHandler)
+	m.Register(regexp.MustCompile("^remove$"), newSnapHookHandler)
+	m.Register(regexp.MustCompile("^(prepare|connect)-(plug|slot)-[-a-z0-9]+$"), newSnapHookHandler)
+}
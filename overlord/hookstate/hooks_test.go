@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package hookstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type snapHooksSuite struct {
+	state *state.State
+}
+
+var _ = Suite(&snapHooksSuite{})
+
+func (s *snapHooksSuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+}
+
+func (s *snapHooksSuite) TestSetupHooks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		setup   func(st *state.State, snapName string) *state.Task
+		hook    string
+		summary string
+		ignore  bool
+	}{
+		{hookstate.SetupInstallHook, "install", `Run install hook of "some-snap" snap if present`, false},
+		{hookstate.SetupPreRefreshHook, "pre-refresh", `Run pre-refresh hook of "some-snap" snap if present`, false},
+		{hookstate.SetupPostRefreshHook, "post-refresh", `Run post-refresh hook of "some-snap" snap if present`, false},
+		{hookstate.SetupRemoveHook, "remove", `Run remove hook of "some-snap" snap if present`, true},
+	} {
+		task := t.setup(s.state, "some-snap")
+		c.Check(task.Kind(), Equals, "run-hook")
+		c.Check(task.Summary(), Equals, t.summary)
+
+		var hooksup hookstate.HookSetup
+		c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup, DeepEquals, hookstate.HookSetup{
+			Snap:        "some-snap",
+			Hook:        t.hook,
+			Optional:    true,
+			IgnoreError: t.ignore,
+		})
+	}
+}
@@ -76,7 +76,7 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, tomb *tomb.Tomb) er
 		return err
 	}
 
-	snapInfo, err := snap.ReadInfo(snapsup.Name(), snapsup.SideInfo)
+	snapInfo, err := snap.ReadInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
 		return err
 	}
@@ -92,7 +92,7 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, tomb *tomb.Tomb) er
 
 func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb, snapInfo *snap.Info, confinement snap.ConfinementType) error {
 	snap.AddImplicitSlots(snapInfo)
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 
 	// The snap may have been updated so perform the following operation to
 	// ensure that we are always working on the correct state:
@@ -145,7 +145,7 @@ func (m *InterfaceManager) doRemoveProfiles(task *state.Task, tomb *tomb.Tomb) e
 	if err != nil {
 		return err
 	}
-	snapName := snapSetup.Name()
+	snapName := snapSetup.InstanceName()
 
 	return m.removeProfilesForSnap(task, tomb, snapName)
 }
@@ -187,7 +187,7 @@ func (m *InterfaceManager) undoSetupProfiles(task *state.Task, tomb *tomb.Tomb)
 	if err != nil {
 		return err
 	}
-	snapName := snapsup.Name()
+	snapName := snapsup.InstanceName()
 
 	// Get the name from SnapSetup and use it to find the current SideInfo
 	// about the snap, if there is one.
@@ -226,7 +226,7 @@ func (m *InterfaceManager) doDiscardConns(task *state.Task, _ *tomb.Tomb) error
 		return err
 	}
 
-	snapName := snapSetup.Name()
+	snapName := snapSetup.InstanceName()
 
 	var snapst snapstate.SnapState
 	err = snapstate.Get(st, snapName, &snapst)
@@ -340,15 +340,35 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		BaseDeclaration:     baseDecl,
 	}
 
-	err = ic.Check()
+	var auto bool
+	if err := task.Get("auto", &auto); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if auto {
+		err = ic.CheckAutoConnect()
+	} else {
+		err = ic.Check()
+	}
 	if err != nil {
 		return err
 	}
 
+	// attributes set by the prepare hooks, if any
+	var plugDynamic, slotDynamic map[string]interface{}
+	if err := task.Get("plug-dynamic", &plugDynamic); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if err := task.Get("slot-dynamic", &slotDynamic); err != nil && err != state.ErrNoState {
+		return err
+	}
+
 	err = m.repo.Connect(connRef)
 	if err != nil {
 		return err
 	}
+	// remember what was actually connected for undo
+	task.Set("plug", connRef.PlugRef)
+	task.Set("slot", connRef.SlotRef)
 
 	var plugSnapst snapstate.SnapState
 	if err := snapstate.Get(st, connRef.PlugRef.Snap, &plugSnapst); err != nil {
@@ -379,12 +399,68 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	conns[connRef.ID()] = connState{Interface: plug.Interface}
+	conns[connRef.ID()] = connState{
+		Interface:   plug.Interface,
+		Auto:        auto,
+		PlugDynamic: plugDynamic,
+		SlotDynamic: slotDynamic,
+	}
 	setConns(st, conns)
 
 	return nil
 }
 
+// undoConnect undoes a connection, when one of the hooks run after it
+// failed for instance.
+func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	if err := m.repo.Disconnect(plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name); err != nil {
+		return err
+	}
+	for _, snapName := range []string{plugRef.Snap, slotRef.Snap} {
+		if err := m.setupSnapSecurityByName(task, snapName); err != nil {
+			return err
+		}
+	}
+
+	delete(conns, connID(&plugRef, &slotRef))
+	setConns(st, conns)
+	return nil
+}
+
+// setupSnapSecurityByName sets up the security of the current revision
+// of the named snap.
+func (m *InterfaceManager) setupSnapSecurityByName(task *state.Task, snapName string) error {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(task.State(), snapName, &snapst); err != nil {
+		return err
+	}
+	snapInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+	var confinement snap.ConfinementType
+	if snapst.DevModeAllowed() {
+		confinement = snap.DevmodeConfinement
+	} else {
+		confinement = snap.StrictConfinement
+	}
+	return setupSnapSecurity(task, snapInfo, confinement, m.repo)
+}
+
 func snapNamesFromConns(conns []interfaces.ConnRef) []string {
 	m := make(map[string]bool)
 	for _, conn := range conns {
@@ -21,6 +21,7 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/snapcore/snapd/asserts"
@@ -107,7 +108,7 @@ func (m *InterfaceManager) reloadConnections(snapName string) error {
 
 func setupSnapSecurity(task *state.Task, snapInfo *snap.Info, confinement snap.ConfinementType, repo *interfaces.Repository) error {
 	st := task.State()
-	snapName := snapInfo.Name()
+	snapName := snapInfo.InstanceName()
 
 	for _, backend := range backends.All {
 		st.Unlock()
@@ -138,6 +139,9 @@ func removeSnapSecurity(task *state.Task, snapName string) error {
 type connState struct {
 	Auto      bool   `json:"auto,omitempty"`
 	Interface string `json:"interface,omitempty"`
+	// attributes set by the interface hooks of the snaps
+	PlugDynamic map[string]interface{} `json:"plug-dynamic,omitempty"`
+	SlotDynamic map[string]interface{} `json:"slot-dynamic,omitempty"`
 }
 
 func connID(plug *interfaces.PlugRef, slot *interfaces.SlotRef) string {
@@ -190,14 +194,15 @@ func (c *autoConnectChecker) snapDeclaration(snapID string) (*asserts.SnapDeclar
 	return snapDecl, nil
 }
 
-func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot) bool {
+// connectCandidate returns the policy candidate for connecting the
+// given plug and slot, with the declarations of their snaps.
+func (c *autoConnectChecker) connectCandidate(plug *snap.PlugInfo, slot *snap.SlotInfo) (*policy.ConnectCandidate, error) {
 	var plugDecl *asserts.SnapDeclaration
 	if plug.Snap.SnapID != "" {
 		var err error
 		plugDecl, err = c.snapDeclaration(plug.Snap.SnapID)
 		if err != nil {
-			logger.Noticef("error: cannot find snap declaration for %q: %v", plug.Snap.Name(), err)
-			return false
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap.Name(), err)
 		}
 	}
 
@@ -206,20 +211,27 @@ func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot)
 		var err error
 		slotDecl, err = c.snapDeclaration(slot.Snap.SnapID)
 		if err != nil {
-			logger.Noticef("error: cannot find snap declaration for %q: %v", slot.Snap.Name(), err)
-			return false
+			return nil, fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap.Name(), err)
 		}
 	}
 
-	// check the connection against the declarations' rules
-	ic := policy.ConnectCandidate{
-		Plug:                plug.PlugInfo,
+	return &policy.ConnectCandidate{
+		Plug:                plug,
 		PlugSnapDeclaration: plugDecl,
-		Slot:                slot.SlotInfo,
+		Slot:                slot,
 		SlotSnapDeclaration: slotDecl,
 		BaseDeclaration:     c.baseDecl,
+	}, nil
+}
+
+func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot) bool {
+	ic, err := c.connectCandidate(plug.PlugInfo, slot.SlotInfo)
+	if err != nil {
+		logger.Noticef("error: %v", err)
+		return false
 	}
 
+	// check the connection against the declarations' rules
 	return ic.CheckAutoConnect() == nil
 }
 
@@ -250,19 +262,70 @@ func (m *InterfaceManager) autoConnect(task *state.Task, snapName string, blackl
 		slot := candidates[0]
 		connRef := interfaces.ConnRef{
 			PlugRef: interfaces.PlugRef{Snap: snapName, Name: plug.Name},
-			SlotRef: interfaces.SlotRef{Snap: slot.Snap.Name(), Name: slot.Name},
+			SlotRef: interfaces.SlotRef{Snap: slot.Snap.InstanceName(), Name: slot.Name},
+		}
+		key := connID(&connRef.PlugRef, &connRef.SlotRef)
+		if _, ok := conns[key]; !ok && hasInterfaceHooks(plug, slot) && canInjectTasks(task) {
+			// let the hooks have their say, the connection is
+			// made by a task of its own
+			connect := newConnectTask(task.State(), connRef.PlugRef, connRef.SlotRef)
+			connect.Set("auto", true)
+			injectConnectTasks(task, connectTasks(task.State(), connect, plug.Snap, plug.Name, slot.Snap, slot.Name))
+			continue
 		}
 		if err := m.repo.Connect(connRef); err != nil {
 			task.Logf("cannot auto connect %s:%s to %s:%s: %s",
-				snapName, plug.Name, slot.Snap.Name(), slot.Name, err)
+				snapName, plug.Name, slot.Snap.InstanceName(), slot.Name, err)
 		}
-		key := fmt.Sprintf("%s:%s %s:%s", snapName, plug.Name, slot.Snap.Name(), slot.Name)
 		conns[key] = connState{Interface: plug.Interface, Auto: true}
 	}
 	task.State().Set("conns", conns)
 	return nil
 }
 
+// hasInterfaceHooks returns whether the snaps of the plug or the slot
+// have any hook to run when connecting them.
+func hasInterfaceHooks(plug *interfaces.Plug, slot *interfaces.Slot) bool {
+	for _, hookName := range []string{"prepare-plug-" + plug.Name, "connect-plug-" + plug.Name} {
+		if plug.Snap.Hooks[hookName] != nil {
+			return true
+		}
+	}
+	for _, hookName := range []string{"prepare-slot-" + slot.Name, "connect-slot-" + slot.Name} {
+		if slot.Snap.Hooks[hookName] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// canInjectTasks returns whether tasks can be added to the change of
+// the given task while it runs.
+func canInjectTasks(task *state.Task) bool {
+	return task.Change() != nil && task.Status() == state.DoingStatus
+}
+
+// injectConnectTasks adds the tasks to the change of the given task,
+// making them wait for the snap to be made available in the same
+// change, as hooks can only run for the current revision of a snap.
+func injectConnectTasks(task *state.Task, ts *state.TaskSet) {
+	chg := task.Change()
+	after := task
+	var setupTaskID string
+	if err := task.Get("snap-setup-task", &setupTaskID); err == nil {
+		for _, t := range chg.Tasks() {
+			var id string
+			if t.Kind() == "link-snap" && t.Get("snap-setup-task", &id) == nil && id == setupTaskID {
+				after = t
+				break
+			}
+		}
+	}
+	ts.WaitFor(after)
+	chg.AddAll(ts)
+	task.State().EnsureBefore(0)
+}
+
 func getPlugAndSlotRefs(task *state.Task) (interfaces.PlugRef, interfaces.SlotRef, error) {
 	var plugRef interfaces.PlugRef
 	var slotRef interfaces.SlotRef
@@ -320,7 +383,82 @@ func CheckInterfaces(st *state.State, snapInfo *snap.Info) error {
 	return ic.Check()
 }
 
+// ReevaluateConnections checks the established connections against the
+// current snap declarations and base declaration, and returns the task
+// sets disconnecting the ones they no longer allow. Connections of snaps
+// with changes in progress are left for a later evaluation.
+// Note that the state must be locked by the caller.
+func ReevaluateConnections(st *state.State) ([]*state.TaskSet, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	checker, err := newAutoConnectChecker(st)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasksets []*state.TaskSet
+	for _, id := range ids {
+		plugRef, slotRef, err := parseConnID(id)
+		if err != nil {
+			return nil, err
+		}
+		plugInfo, err := snapstate.CurrentInfo(st, plugRef.Snap)
+		if err != nil {
+			continue
+		}
+		slotInfo, err := snapstate.CurrentInfo(st, slotRef.Snap)
+		if err != nil {
+			continue
+		}
+		snap.AddImplicitSlots(slotInfo)
+		plug := plugInfo.Plugs[plugRef.Name]
+		slot := slotInfo.Slots[slotRef.Name]
+		if plug == nil || slot == nil {
+			continue
+		}
+
+		ic, err := checker.connectCandidate(plug, slot)
+		if err != nil {
+			return nil, err
+		}
+		if conns[id].Auto {
+			err = ic.CheckAutoConnect()
+		} else {
+			err = ic.Check()
+		}
+		if err == nil {
+			continue
+		}
+
+		if err := snapstate.CheckChangeConflict(st, plugRef.Snap, nil); err != nil {
+			logger.Noticef("Cannot disconnect %s yet: %v", id, err)
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(st, slotRef.Snap, nil); err != nil {
+			logger.Noticef("Cannot disconnect %s yet: %v", id, err)
+			continue
+		}
+		logger.Noticef("Disconnecting %s as it is no longer allowed: %v", id, err)
+		ts, err := Disconnect(st, plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		tasksets = append(tasksets, ts)
+	}
+	return tasksets, nil
+}
+
 func init() {
+	// hook re-evaluating connections into the refresh of assertions
+	assertstate.ReevaluateConnections = ReevaluateConnections
 	// hook interface checks into snapstate installation logic
 	snapstate.AddCheckSnapCallback(func(st *state.State, snapInfo, _ *snap.Info, _ snapstate.Flags) error {
 		return CheckInterfaces(st, snapInfo)
@@ -23,12 +23,15 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
-
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 // InterfaceManager is responsible for the maintenance of interfaces in
@@ -58,7 +61,7 @@ func Manager(s *state.State, extra []interfaces.Interface) (*InterfaceManager, e
 		return len(running) != 0
 	})
 
-	runner.AddHandler("connect", m.doConnect, nil)
+	runner.AddHandler("connect", m.doConnect, m.undoConnect)
 	runner.AddHandler("disconnect", m.doDisconnect, nil)
 	runner.AddHandler("setup-profiles", m.doSetupProfiles, m.undoSetupProfiles)
 	runner.AddHandler("remove-profiles", m.doRemoveProfiles, m.doSetupProfiles)
@@ -68,16 +71,64 @@ func Manager(s *state.State, extra []interfaces.Interface) (*InterfaceManager, e
 
 // Connect returns a set of tasks for connecting an interface.
 //
+// The prepare-plug-<plug>, prepare-slot-<slot>, connect-slot-<slot> and
+// connect-plug-<plug> hooks of the snaps involved are run around the
+// connection, in that order.
 func Connect(s *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
 	// TODO: Store the intent-to-connect in the state so that we automatically
 	// try to reconnect on reboot (reconnection can fail or can connect with
 	// different parameters so we cannot store the actual connection details).
+	task := newConnectTask(s, interfaces.PlugRef{Snap: plugSnap, Name: plugName}, interfaces.SlotRef{Snap: slotSnap, Name: slotName})
+
+	// the snaps not being there is reported by the connect task
+	plugInfo, _ := snapstate.CurrentInfo(s, plugSnap)
+	slotInfo, _ := snapstate.CurrentInfo(s, slotSnap)
+	return connectTasks(s, task, plugInfo, plugName, slotInfo, slotName), nil
+}
+
+func newConnectTask(s *state.State, plugRef interfaces.PlugRef, slotRef interfaces.SlotRef) *state.Task {
 	summary := fmt.Sprintf(i18n.G("Connect %s:%s to %s:%s"),
-		plugSnap, plugName, slotSnap, slotName)
+		plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name)
 	task := s.NewTask("connect", summary)
-	task.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
-	task.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
-	return state.NewTaskSet(task), nil
+	task.Set("slot", slotRef)
+	task.Set("plug", plugRef)
+	return task
+}
+
+// connectTasks returns a task set with the given connect task and the
+// tasks running the interface hooks that the plug and slot snaps have,
+// if any. The hooks can read and change the attributes of the
+// connection through the connect task.
+func connectTasks(s *state.State, connect *state.Task, plugInfo *snap.Info, plugName string, slotInfo *snap.Info, slotName string) *state.TaskSet {
+	ts := state.NewTaskSet()
+	var prev *state.Task
+	addTask := func(t *state.Task) {
+		if prev != nil {
+			t.WaitFor(prev)
+		}
+		ts.AddTask(t)
+		prev = t
+	}
+	addHook := func(info *snap.Info, hookName string) {
+		if info == nil || info.Hooks[hookName] == nil {
+			return
+		}
+		hooksup := &hookstate.HookSetup{
+			Snap:     info.InstanceName(),
+			Hook:     hookName,
+			Optional: true,
+		}
+		summary := fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hookName, info.InstanceName())
+		contextData := map[string]interface{}{"attrs-task": connect.ID()}
+		addTask(hookstate.HookTask(s, summary, hooksup, contextData))
+	}
+
+	addHook(plugInfo, "prepare-plug-"+plugName)
+	addHook(slotInfo, "prepare-slot-"+slotName)
+	addTask(connect)
+	addHook(slotInfo, "connect-slot-"+slotName)
+	addHook(plugInfo, "connect-plug-"+plugName)
+	return ts
 }
 
 // Disconnect returns a set of tasks for  disconnecting an interface.
@@ -92,6 +143,74 @@ func Disconnect(s *state.State, plugSnap, plugName, slotSnap, slotName string) (
 	return state.NewTaskSet(task), nil
 }
 
+func init() {
+	// operating on the snaps of a connection being made or undone
+	// conflicts with it
+	state.RegisterAffectedFunc("connect", connectionAffectedSnaps)
+	state.RegisterAffectedFunc("disconnect", connectionAffectedSnaps)
+}
+
+// connectionAffectedSnaps returns the names of the snaps of the plug and
+// slot a connect or disconnect task operates on.
+func connectionAffectedSnaps(task *state.Task) ([]string, error) {
+	var plugRef interfaces.PlugRef
+	var slotRef interfaces.SlotRef
+	if err := task.Get("plug", &plugRef); err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain plug from task: %s", task.Summary())
+	}
+	if err := task.Get("slot", &slotRef); err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain slot from task: %s", task.Summary())
+	}
+	return []string{plugRef.Snap, slotRef.Snap}, nil
+}
+
+// ConnInfo describes an established connection.
+type ConnInfo struct {
+	Plug      interfaces.PlugRef
+	Slot      interfaces.SlotRef
+	Interface string
+	// PlugDynamic and SlotDynamic hold the attributes set by the
+	// interface hooks of the snaps when connecting.
+	PlugDynamic map[string]interface{}
+	SlotDynamic map[string]interface{}
+}
+
+// Connected returns the established connections of the named plug or
+// slot of the given snap, sorted by plug then slot.
+// Note that the state must be locked by the caller.
+func Connected(st *state.State, snapName, plugOrSlot string) ([]*ConnInfo, error) {
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var connected []*ConnInfo
+	for _, id := range ids {
+		plugRef, slotRef, err := parseConnID(id)
+		if err != nil {
+			return nil, err
+		}
+		if (plugRef.Snap != snapName || plugRef.Name != plugOrSlot) && (slotRef.Snap != snapName || slotRef.Name != plugOrSlot) {
+			continue
+		}
+		conn := conns[id]
+		connected = append(connected, &ConnInfo{
+			Plug:        *plugRef,
+			Slot:        *slotRef,
+			Interface:   conn.Interface,
+			PlugDynamic: conn.PlugDynamic,
+			SlotDynamic: conn.SlotDynamic,
+		})
+	}
+	return connected, nil
+}
+
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
 	m.runner.Ensure()
@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -127,6 +128,33 @@ func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	c.Assert(slot.Name, Equals, "slot")
 }
 
+func (s *interfaceManagerSuite) TestConnectDisconnectTasksConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("connect", "...")
+	chg.AddAll(ts)
+
+	for _, name := range []string{"consumer", "producer"} {
+		task, err := s.state.ConflictingTask(name)
+		c.Assert(err, IsNil)
+		c.Check(task, Equals, ts.Tasks()[0])
+	}
+	task, err := s.state.ConflictingTask("other")
+	c.Assert(err, IsNil)
+	c.Check(task, IsNil)
+
+	ts, err = ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	s.state.NewChange("disconnect", "...").AddAll(ts)
+
+	task, err = s.state.ConflictingTask("producer", chg.ID())
+	c.Assert(err, IsNil)
+	c.Check(task, Equals, ts.Tasks()[0])
+}
+
 func (s *interfaceManagerSuite) TestEnsureProcessesConnectTask(c *C) {
 	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
 	s.mockSnap(c, consumerYaml)
@@ -1526,3 +1554,278 @@ func (s *interfaceManagerSuite) TestUndoSetupProfilesOnRefresh(c *C) {
 	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Revision, Equals, snapInfo.Revision)
 	c.Check(s.secBackend.SetupCalls[0].Confinement, Equals, snap.StrictConfinement)
 }
+
+var consumerWithHooksYaml = `
+name: consumer
+version: 1
+plugs:
+ plug:
+  interface: test
+hooks:
+ prepare-plug-plug:
+ connect-plug-plug:
+`
+
+var producerWithHooksYaml = `
+name: producer
+version: 1
+slots:
+ slot:
+  interface: test
+hooks:
+ prepare-slot-slot:
+ connect-slot-slot:
+`
+
+func (s *interfaceManagerSuite) TestConnectTaskWithHooks(c *C) {
+	s.mockSnap(c, consumerWithHooksYaml)
+	s.mockSnap(c, producerWithHooksYaml)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+
+	tasks := ts.Tasks()
+	c.Assert(tasks, HasLen, 5)
+	connect := tasks[2]
+	c.Check(connect.Kind(), Equals, "connect")
+
+	expected := []struct {
+		snap, hook string
+	}{
+		{"consumer", "prepare-plug-plug"},
+		{"producer", "prepare-slot-slot"},
+		{"", ""},
+		{"producer", "connect-slot-slot"},
+		{"consumer", "connect-plug-plug"},
+	}
+	for i, t := range tasks {
+		if i > 0 {
+			c.Check(t.WaitTasks(), DeepEquals, []*state.Task{tasks[i-1]})
+		}
+		if t == connect {
+			continue
+		}
+		c.Check(t.Kind(), Equals, "run-hook")
+		var hooksup hookstate.HookSetup
+		c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+		c.Check(hooksup.Snap, Equals, expected[i].snap)
+		c.Check(hooksup.Hook, Equals, expected[i].hook)
+		var hookContext map[string]interface{}
+		c.Assert(t.Get("hook-context", &hookContext), IsNil)
+		c.Check(hookContext["attrs-task"], Equals, connect.ID())
+	}
+}
+
+func (s *interfaceManagerSuite) TestConnectTaskStoresDynamicAttrs(c *C) {
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	// as a prepare-plug hook would have done
+	ts.Tasks()[0].Set("plug-dynamic", map[string]interface{}{"path": "/some/path"})
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface":    "test",
+			"plug-dynamic": map[string]interface{}{"path": "/some/path"},
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) TestUndoConnect(c *C) {
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	change := s.state.NewChange("kind", "summary")
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+	change.AddAll(ts)
+	// Add a dummy task just to hold the change not ready, like a
+	// connect hook would.
+	dummy := s.state.NewTask("dummy", "")
+	dummy.WaitAll(ts)
+	change.AddTask(dummy)
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	c.Assert(ts.Tasks()[0].Status(), Equals, state.DoneStatus)
+	change.Abort()
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(ts.Tasks()[0].Status(), Equals, state.UndoneStatus)
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+
+	repo := mgr.Repository()
+	plug := repo.Plug("consumer", "plug")
+	c.Assert(plug, NotNil)
+	c.Check(plug.Connections, HasLen, 0)
+}
+
+// The setup-profiles task leaves auto-connections involving interface hooks
+// to connect tasks of their own.
+func (s *interfaceManagerSuite) TestDoSetupSnapSecurityAutoConnectsWithHooks(c *C) {
+	s.mockSnap(c, osSnapYaml)
+	mgr := s.manager(c)
+
+	snapInfo := s.mockSnap(c, sampleSnapYaml+`hooks:
+ connect-plug-network:
+`)
+
+	change := s.addSetupSnapSecurityChange(c, &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: snapInfo.Name(),
+			Revision: snapInfo.Revision,
+		},
+	})
+	mgr.Ensure()
+	mgr.Wait()
+
+	s.state.Lock()
+	c.Assert(change.Tasks(), HasLen, 3)
+	setupProfiles, connect, hook := change.Tasks()[0], change.Tasks()[1], change.Tasks()[2]
+	c.Check(setupProfiles.Status(), Equals, state.DoneStatus)
+	c.Check(connect.Kind(), Equals, "connect")
+	c.Check(connect.WaitTasks(), DeepEquals, []*state.Task{setupProfiles})
+	var auto bool
+	c.Assert(connect.Get("auto", &auto), IsNil)
+	c.Check(auto, Equals, true)
+	c.Check(hook.Kind(), Equals, "run-hook")
+	c.Check(hook.WaitTasks(), DeepEquals, []*state.Task{connect, setupProfiles})
+
+	// not connected yet
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	s.state.Unlock()
+
+	mgr.Ensure()
+	mgr.Wait()
+	mgr.Stop()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(connect.Status(), Equals, state.DoneStatus)
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"snap:network ubuntu-core:network": map[string]interface{}{
+			"interface": "network", "auto": true,
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) mockReevaluationSetup(c *C, consumerPublisher, producerPublisher string) {
+	s.mockIface(c, &interfaces.TestInterface{InterfaceName: "test"})
+	s.mockSnapDecl(c, "consumer", consumerPublisher, nil)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnapDecl(c, "producer", producerPublisher, nil)
+	s.mockSnap(c, producerYaml)
+
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+}
+
+const sameSlotPublisherBaseDecl = `
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      plug-publisher-id:
+        - $SLOT_PUBLISHER_ID
+`
+
+func (s *interfaceManagerSuite) TestReevaluateConnectionsDisconnectsDisallowed(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(sameSlotPublisherBaseDecl))
+	defer restore()
+	s.mockReevaluationSetup(c, "consumer-publisher", "producer-publisher")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tasksets, err := ifacestate.ReevaluateConnections(s.state)
+	c.Assert(err, IsNil)
+	c.Assert(tasksets, HasLen, 1)
+	task := tasksets[0].Tasks()[0]
+	c.Check(task.Kind(), Equals, "disconnect")
+	var plug interfaces.PlugRef
+	c.Assert(task.Get("plug", &plug), IsNil)
+	c.Check(plug, Equals, interfaces.PlugRef{Snap: "consumer", Name: "plug"})
+	var slot interfaces.SlotRef
+	c.Assert(task.Get("slot", &slot), IsNil)
+	c.Check(slot, Equals, interfaces.SlotRef{Snap: "producer", Name: "slot"})
+}
+
+func (s *interfaceManagerSuite) TestReevaluateConnectionsKeepsAllowed(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(sameSlotPublisherBaseDecl))
+	defer restore()
+	s.mockReevaluationSetup(c, "one-publisher", "one-publisher")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tasksets, err := ifacestate.ReevaluateConnections(s.state)
+	c.Assert(err, IsNil)
+	c.Check(tasksets, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestReevaluateConnectionsSkipsConflicting(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(sameSlotPublisherBaseDecl))
+	defer restore()
+	s.mockReevaluationSetup(c, "consumer-publisher", "producer-publisher")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("refresh", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "producer"}})
+	chg.AddTask(t)
+
+	tasksets, err := ifacestate.ReevaluateConnections(s.state)
+	c.Assert(err, IsNil)
+	c.Check(tasksets, HasLen, 0)
+}
@@ -21,8 +21,9 @@
 package overlord
 
 import (
+	"bytes"
 	"fmt"
-	"os"
+	"io/ioutil"
 	"path/filepath"
 	"sync"
 	"time"
@@ -34,14 +35,18 @@ import (
 	"github.com/snapcore/snapd/osutil"
 
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auditstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
 	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
 	"github.com/snapcore/snapd/store"
 )
 
@@ -65,16 +70,25 @@ type Overlord struct {
 	// restarts
 	restartHandler func(t state.RestartType)
 	// managers
-	snapMgr   *snapstate.SnapManager
-	assertMgr *assertstate.AssertManager
-	ifaceMgr  *ifacestate.InterfaceManager
-	hookMgr   *hookstate.HookManager
-	configMgr *configstate.ConfigManager
-	deviceMgr *devicestate.DeviceManager
+	snapMgr    *snapstate.SnapManager
+	assertMgr  *assertstate.AssertManager
+	ifaceMgr   *ifacestate.InterfaceManager
+	hookMgr    *hookstate.HookManager
+	configMgr  *configstate.ConfigManager
+	deviceMgr  *devicestate.DeviceManager
+	serviceMgr *servicestate.ServiceManager
+	shotMgr    *snapshotstate.SnapshotManager
+	auditMgr   *auditstate.AuditManager
 }
 
 var storeNew = store.New
 
+// newStateStore returns the store that keeps the deltas the state is
+// checkpointed to on top of the state file.
+var newStateStore = func(path string) statejournal.Store {
+	return statejournal.New(path)
+}
+
 // New creates a new Overlord with all its state managers.
 func New() (*Overlord, error) {
 	o := &Overlord{
@@ -85,6 +99,7 @@ func New() (*Overlord, error) {
 		path:           dirs.SnapStateFile,
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
+		store:          newStateStore(dirs.SnapStateJournalFile),
 	}
 	s, err := loadState(backend)
 	if err != nil {
@@ -134,6 +149,28 @@ func New() (*Overlord, error) {
 	o.deviceMgr = deviceMgr
 	o.stateEng.AddManager(o.deviceMgr)
 
+	serviceMgr, err := servicestate.Manager(s)
+	if err != nil {
+		return nil, err
+	}
+	o.serviceMgr = serviceMgr
+	o.stateEng.AddManager(o.serviceMgr)
+
+	shotMgr, err := snapshotstate.Manager(s)
+	if err != nil {
+		return nil, err
+	}
+	o.shotMgr = shotMgr
+	o.stateEng.AddManager(o.shotMgr)
+
+	// last, so that it sees the changes made ready by the others
+	auditMgr, err := auditstate.Manager(s)
+	if err != nil {
+		return nil, err
+	}
+	o.auditMgr = auditMgr
+	o.stateEng.AddManager(o.auditMgr)
+
 	// setting up the store
 	authContext := auth.NewAuthContext(s, o.deviceMgr)
 	sto := storeNew(nil, authContext)
@@ -144,7 +181,7 @@ func New() (*Overlord, error) {
 	return o, nil
 }
 
-func loadState(backend state.Backend) (*state.State, error) {
+func loadState(backend *overlordStateBackend) (*state.State, error) {
 	if !osutil.FileExists(dirs.SnapStateFile) {
 		// fail fast, mostly interesting for tests, this dir is setup
 		// by the snapd package
@@ -154,16 +191,20 @@ func loadState(backend state.Backend) (*state.State, error) {
 		}
 		s := state.New(backend)
 		patch.Init(s)
-		return s, nil
+		return s, backend.openStore()
 	}
 
-	r, err := os.Open(dirs.SnapStateFile)
+	snapshot, err := ioutil.ReadFile(dirs.SnapStateFile)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read the state file: %s", err)
 	}
-	defer r.Close()
+	// the state is checkpointed to the store on top of the state file
+	data, err := backend.store.Replay(snapshot)
+	if err != nil {
+		return nil, err
+	}
 
-	s, err := state.ReadState(backend, r)
+	s, err := state.ReadState(backend, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
@@ -173,7 +214,11 @@ func loadState(backend state.Backend) (*state.State, error) {
 	if err != nil {
 		return nil, err
 	}
-	return s, nil
+	if backend.incremental {
+		// patching moved to incremental checkpoints
+		return s, nil
+	}
+	return s, backend.openStore()
 }
 
 func (o *Overlord) ensureTimerSetup() {
@@ -328,3 +373,21 @@ func (o *Overlord) HookManager() *hookstate.HookManager {
 func (o *Overlord) DeviceManager() *devicestate.DeviceManager {
 	return o.deviceMgr
 }
+
+// ServiceManager returns the service manager responsible for controlling
+// the services of snaps under the overlord.
+func (o *Overlord) ServiceManager() *servicestate.ServiceManager {
+	return o.serviceMgr
+}
+
+// SnapshotManager returns the snapshot manager responsible for snapshots
+// of snap data under the overlord.
+func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
+	return o.shotMgr
+}
+
+// AuditManager returns the manager keeping the audit log of the changes
+// done under the overlord.
+func (o *Overlord) AuditManager() *auditstate.AuditManager {
+	return o.auditMgr
+}
@@ -33,6 +33,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/patch"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -51,6 +52,7 @@ func (ovs *overlordSuite) SetUpTest(c *C) {
 	tmpdir := c.MkDir()
 	dirs.SetRootDir(tmpdir)
 	dirs.SnapStateFile = filepath.Join(tmpdir, "test.json")
+	dirs.SnapStateJournalFile = filepath.Join(tmpdir, "test.journal")
 }
 
 func (ovs *overlordSuite) TearDownTest(c *C) {
@@ -69,6 +71,9 @@ func (ovs *overlordSuite) TestNew(c *C) {
 	c.Check(o.AssertManager(), NotNil)
 	c.Check(o.InterfaceManager(), NotNil)
 	c.Check(o.DeviceManager(), NotNil)
+	c.Check(o.ServiceManager(), NotNil)
+	c.Check(o.SnapshotManager(), NotNil)
+	c.Check(o.AuditManager(), NotNil)
 
 	s := o.State()
 	c.Check(s, NotNil)
@@ -110,6 +115,52 @@ func (ovs *overlordSuite) TestNewWithGoodState(c *C) {
 	c.Check(got, DeepEquals, expected)
 }
 
+func (ovs *overlordSuite) TestNewMigratesToIncrementalCheckpoints(c *C) {
+	fakeState := []byte(`{"data":{"patch-level":6,"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`)
+	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
+	c.Assert(err, IsNil)
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	// the state file is the full checkpoint the journal is started on
+	c.Check(osutil.FileExists(dirs.SnapStateJournalFile), Equals, true)
+	before, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(before), testutil.Contains, `"some":"data"`)
+
+	// and it is at the new patch level on its own, for older snapd
+	// versions ignoring the journal to refuse it
+	var onDisk struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	c.Assert(json.Unmarshal(before, &onDisk), IsNil)
+	c.Check(onDisk.Data["patch-level"], Equals, float64(7))
+
+	s := o.State()
+	s.Lock()
+	s.Set("mark", 1)
+	s.Unlock()
+
+	after, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(after), Equals, string(before))
+	journal, err := ioutil.ReadFile(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	c.Check(string(journal), testutil.Contains, `"mark":1`)
+
+	o, err = overlord.New()
+	c.Assert(err, IsNil)
+	s = o.State()
+	s.Lock()
+	defer s.Unlock()
+	var level, mark int
+	c.Assert(s.Get("patch-level", &level), IsNil)
+	c.Check(level, Equals, patch.Level)
+	c.Assert(s.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 1)
+}
+
 func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	fakeState := []byte(``)
 	err := ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600)
@@ -387,9 +438,24 @@ func (ovs *overlordSuite) TestCheckpoint(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(st.Mode(), Equals, os.FileMode(0600))
 
-	content, err := ioutil.ReadFile(dirs.SnapStateFile)
+	// the modification went to the journal
+	st, err = os.Stat(dirs.SnapStateJournalFile)
+	c.Assert(err, IsNil)
+	c.Assert(st.Mode(), Equals, os.FileMode(0600))
+
+	content, err := ioutil.ReadFile(dirs.SnapStateJournalFile)
 	c.Assert(err, IsNil)
 	c.Check(string(content), testutil.Contains, `"mark":1`)
+
+	// and it's there when loading the state again
+	o, err = overlord.New()
+	c.Assert(err, IsNil)
+	s = o.State()
+	s.Lock()
+	defer s.Unlock()
+	var mark int
+	c.Assert(s.Get("mark", &mark), IsNil)
+	c.Check(mark, Equals, 1)
 }
 
 type runnerManager struct {
@@ -27,7 +27,7 @@ import (
 )
 
 // Level is the current implemented patch level of the state format and content.
-var Level = 6
+var Level = 7
 
 // patches maps from patch level L to the function that moves from L-1 to L.
 var patches = make(map[int]func(s *state.State) error)
@@ -0,0 +1,40 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package patch
+
+import (
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+func init() {
+	patches[7] = patch7
+}
+
+// patch7:
+//  - move to checkpointing the state incrementally: the state file
+//    becomes the last full checkpoint of the state, with the entries
+//    modified since then kept in the state store, so older snapd
+//    versions must refuse to go on with just the state file
+func patch7(st *state.State) error {
+	// the last full checkpoint must be at the new level already,
+	// for older snapd versions to refuse it
+	st.Set("patch-level", 7)
+	return st.StartIncrementalCheckpoints()
+}
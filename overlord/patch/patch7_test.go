@@ -0,0 +1,102 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package patch_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/patch"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type patch7Suite struct{}
+
+var _ = Suite(&patch7Suite{})
+
+func (s *patch7Suite) TestPatch7(c *C) {
+	restorer := patch.MockLevel(7)
+	defer restorer()
+
+	st := state.New(nil)
+	st.Lock()
+	st.Set("patch-level", 6)
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	c.Assert(patch.Apply(st), IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	var level int
+	c.Assert(st.Get("patch-level", &level), IsNil)
+	c.Check(level, Equals, 7)
+	var foo string
+	c.Assert(st.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+}
+
+type incrementalBackend struct {
+	started     []byte
+	checkpoints int
+	deltas      []*state.Delta
+}
+
+func (b *incrementalBackend) Checkpoint(data []byte) error {
+	b.checkpoints++
+	return nil
+}
+
+func (b *incrementalBackend) CheckpointDelta(delta *state.Delta) error {
+	b.deltas = append(b.deltas, delta)
+	return nil
+}
+
+func (b *incrementalBackend) StartIncremental(data []byte) error {
+	b.started = data
+	return nil
+}
+
+func (b *incrementalBackend) EnsureBefore(d time.Duration)       {}
+func (b *incrementalBackend) RequestRestart(t state.RestartType) {}
+
+func (s *patch7Suite) TestPatch7StartsIncrementalCheckpoints(c *C) {
+	restorer := patch.MockLevel(7)
+	defer restorer()
+
+	b := &incrementalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("patch-level", 6)
+	st.Set("foo", "bar")
+	st.Unlock()
+	c.Assert(b.checkpoints, Equals, 1)
+
+	c.Assert(patch.Apply(st), IsNil)
+
+	// the state got checkpointed at the new patch level for the
+	// store to start on top of
+	c.Check(string(b.started), Matches, `.*"foo":"bar".*`)
+	c.Check(string(b.started), Matches, `.*"patch-level":7.*`)
+	c.Check(b.checkpoints, Equals, 1)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(string(b.deltas[0].Data["patch-level"]), Equals, "7")
+}
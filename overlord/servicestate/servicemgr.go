@@ -0,0 +1,146 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package servicestate implements the manager and state aspects responsible
+// for controlling the services of installed snaps.
+package servicestate
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/timeout"
+)
+
+// ServiceManager is responsible for starting, stopping and restarting
+// the services of installed snaps on request.
+type ServiceManager struct {
+	state  *state.State
+	runner *state.TaskRunner
+}
+
+// Manager returns a new service manager.
+func Manager(s *state.State) (*ServiceManager, error) {
+	runner := state.NewTaskRunner(s)
+
+	m := &ServiceManager{state: s, runner: runner}
+
+	runner.AddHandler("service-control", m.doServiceControl, nil)
+
+	return m, nil
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *ServiceManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait implements StateManager.Wait.
+func (m *ServiceManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop implements StateManager.Stop.
+func (m *ServiceManager) Stop() {
+	m.runner.Stop()
+}
+
+// taskReporter forwards systemd progress notifications to the task log.
+type taskReporter struct {
+	task *state.Task
+}
+
+func (r *taskReporter) Notify(msg string) {
+	st := r.task.State()
+	st.Lock()
+	defer st.Unlock()
+	r.task.Logf("%s", msg)
+}
+
+func stopTimeout(app *snap.AppInfo) time.Duration {
+	tout := app.StopTimeout
+	if tout == 0 {
+		tout = timeout.DefaultTimeout
+	}
+	return time.Duration(tout)
+}
+
+func (m *ServiceManager) doServiceControl(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	var sa serviceAction
+	err := t.Get("service-action", &sa)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	info, err := snapstate.CurrentInfo(st, sa.SnapName)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &taskReporter{task: t})
+
+	for _, appName := range sa.Apps {
+		app := info.Apps[appName]
+		if app == nil || app.Daemon == "" {
+			return fmt.Errorf("cannot %s %s.%s: no such service", sa.Action, sa.SnapName, appName)
+		}
+		serviceName := filepath.Base(app.ServiceFile())
+
+		switch sa.Action {
+		case "start":
+			if sa.Enable {
+				if err := sysd.Enable(serviceName); err != nil {
+					return err
+				}
+			}
+			if err := sysd.Start(serviceName); err != nil {
+				return err
+			}
+		case "stop":
+			if sa.Disable {
+				if err := sysd.Disable(serviceName); err != nil {
+					return err
+				}
+			}
+			if err := sysd.Stop(serviceName, stopTimeout(app)); err != nil {
+				return err
+			}
+		case "restart":
+			if err := sysd.Restart(serviceName, stopTimeout(app)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown action %q", sa.Action)
+		}
+	}
+
+	return nil
+}
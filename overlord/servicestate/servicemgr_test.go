@@ -0,0 +1,276 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/systemd"
+)
+
+func TestServiceManager(t *testing.T) { TestingT(t) }
+
+type serviceMgrSuite struct {
+	state *state.State
+	mgr   *servicestate.ServiceManager
+	info  *snap.Info
+
+	sysdCalls        [][]string
+	restoreSystemctl func()
+}
+
+var _ = Suite(&serviceMgrSuite{})
+
+const servicesSnapYaml = `name: test-snap
+version: 1.0
+apps:
+  svc1:
+    command: bin/svc1
+    daemon: simple
+  svc2:
+    command: bin/svc2
+    daemon: forking
+  tool:
+    command: bin/tool
+`
+
+func (s *serviceMgrSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.state = state.New(nil)
+	mgr, err := servicestate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.mgr = mgr
+
+	s.sysdCalls = nil
+	oldSystemctl := systemd.SystemctlCmd
+	systemd.SystemctlCmd = func(args ...string) ([]byte, error) {
+		s.sysdCalls = append(s.sysdCalls, args)
+		if len(args) > 0 && args[0] == "show" {
+			return []byte("ActiveState=inactive\n"), nil
+		}
+		return nil, nil
+	}
+	s.restoreSystemctl = func() { systemd.SystemctlCmd = oldSystemctl }
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(7)}
+	s.info = snaptest.MockSnap(c, servicesSnapYaml, "", si)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	s.state.Unlock()
+}
+
+func (s *serviceMgrSuite) TearDownTest(c *C) {
+	s.restoreSystemctl()
+	dirs.SetRootDir("")
+}
+
+func (s *serviceMgrSuite) settle() {
+	for i := 0; i < 50; i++ {
+		s.mgr.Ensure()
+		s.mgr.Wait()
+	}
+}
+
+func (s *serviceMgrSuite) apps(names ...string) []*snap.AppInfo {
+	apps := make([]*snap.AppInfo, len(names))
+	for i, name := range names {
+		apps[i] = s.info.Apps[name]
+	}
+	return apps
+}
+
+func (s *serviceMgrSuite) runControl(c *C, inst *servicestate.Instruction, names ...string) *state.Change {
+	s.state.Lock()
+	ts, err := servicestate.Control(s.state, s.apps(names...), inst)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("service-control", "...")
+	chg.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle()
+
+	return chg
+}
+
+func (s *serviceMgrSuite) TestControlTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := servicestate.Control(s.state, s.apps("svc2", "svc1"), &servicestate.Instruction{Action: "start"})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	t := ts.Tasks()[0]
+	c.Check(t.Kind(), Equals, "service-control")
+	c.Check(t.Summary(), Equals, `Run service command "start" for services "svc1", "svc2" of snap "test-snap"`)
+}
+
+func (s *serviceMgrSuite) TestControlErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, t := range []struct {
+		inst  servicestate.Instruction
+		apps  []string
+		error string
+	}{
+		{servicestate.Instruction{Action: "frobnicate"}, []string{"svc1"}, `unknown action "frobnicate"`},
+		{servicestate.Instruction{Action: "stop", Enable: true}, []string{"svc1"}, `stop does not support the enable option`},
+		{servicestate.Instruction{Action: "start", Disable: true}, []string{"svc1"}, `start does not support the disable option`},
+		{servicestate.Instruction{Action: "start"}, []string{"tool"}, `cannot start test-snap.tool: not a service`},
+		{servicestate.Instruction{Action: "start"}, nil, `cannot start: no services given`},
+	} {
+		_, err := servicestate.Control(s.state, s.apps(t.apps...), &t.inst)
+		c.Check(err, ErrorMatches, t.error)
+	}
+}
+
+func (s *serviceMgrSuite) TestControlConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap"}})
+	chg.AddTask(t)
+
+	_, err := servicestate.Control(s.state, s.apps("svc1"), &servicestate.Instruction{Action: "stop"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has changes in progress`)
+}
+
+func (s *serviceMgrSuite) TestControlInChangeNoConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap"}})
+	chg.AddTask(t)
+
+	ts, err := servicestate.ControlInChange(s.state, s.apps("svc1"), &servicestate.Instruction{Action: "stop"}, chg.ID())
+	c.Assert(err, IsNil)
+	c.Check(ts.Tasks(), HasLen, 1)
+
+	other := s.state.NewChange("other", "...")
+	_, err = servicestate.ControlInChange(s.state, s.apps("svc1"), &servicestate.Instruction{Action: "stop"}, other.ID())
+	c.Check(err, ErrorMatches, `snap "test-snap" has changes in progress`)
+}
+
+func (s *serviceMgrSuite) TestControlParallelInstance(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	info := snaptest.MockInfo(c, servicesSnapYaml, &snap.SideInfo{Revision: snap.R(7)})
+	info.InstanceKey = "foo"
+
+	_, err := servicestate.Control(s.state, []*snap.AppInfo{info.Apps["tool"]}, &servicestate.Instruction{Action: "start"})
+	c.Check(err, ErrorMatches, `cannot start test-snap_foo.tool: not a service`)
+
+	// a change to the instance conflicts, one to the snap doesn't
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap"}, InstanceKey: "foo"})
+	chg.AddTask(t)
+
+	_, err = servicestate.Control(s.state, []*snap.AppInfo{info.Apps["svc1"]}, &servicestate.Instruction{Action: "start"})
+	c.Check(err, ErrorMatches, `snap "test-snap_foo" has changes in progress`)
+	_, err = servicestate.Control(s.state, s.apps("svc1"), &servicestate.Instruction{Action: "start"})
+	c.Check(err, IsNil)
+
+	chg.SetStatus(state.DoneStatus)
+	ts, err := servicestate.Control(s.state, []*snap.AppInfo{info.Apps["svc1"]}, &servicestate.Instruction{Action: "start"})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Run service command "start" for services "svc1" of snap "test-snap_foo"`)
+}
+
+func (s *serviceMgrSuite) TestControlTasksConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := servicestate.Control(s.state, s.apps("svc1"), &servicestate.Instruction{Action: "restart"})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("service-control", "...")
+	chg.AddAll(ts)
+
+	err = snapstate.CheckChangeConflict(s.state, "test-snap", nil)
+	c.Check(err, ErrorMatches, `snap "test-snap" has changes in progress`)
+	c.Check(snapstate.CheckChangeConflict(s.state, "other-snap", nil), IsNil)
+
+	_, err = servicestate.Control(s.state, s.apps("svc2"), &servicestate.Instruction{Action: "stop"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has changes in progress`)
+
+	chg.SetStatus(state.DoneStatus)
+	c.Check(snapstate.CheckChangeConflict(s.state, "test-snap", nil), IsNil)
+}
+
+func (s *serviceMgrSuite) TestStartEnable(c *C) {
+	chg := s.runControl(c, &servicestate.Instruction{Action: "start", Enable: true}, "svc1")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.sysdCalls, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", "snap.test-snap.svc1.service"},
+		{"start", "snap.test-snap.svc1.service"},
+	})
+}
+
+func (s *serviceMgrSuite) TestStopDisable(c *C) {
+	chg := s.runControl(c, &servicestate.Instruction{Action: "stop", Disable: true}, "svc1")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.sysdCalls, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "disable", "snap.test-snap.svc1.service"},
+		{"stop", "snap.test-snap.svc1.service"},
+		{"show", "--property=ActiveState", "snap.test-snap.svc1.service"},
+	})
+}
+
+func (s *serviceMgrSuite) TestRestart(c *C) {
+	chg := s.runControl(c, &servicestate.Instruction{Action: "restart"}, "svc1", "svc2")
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	c.Check(s.sysdCalls, DeepEquals, [][]string{
+		{"stop", "snap.test-snap.svc1.service"},
+		{"show", "--property=ActiveState", "snap.test-snap.svc1.service"},
+		{"start", "snap.test-snap.svc1.service"},
+		{"stop", "snap.test-snap.svc2.service"},
+		{"show", "--property=ActiveState", "snap.test-snap.svc2.service"},
+		{"start", "snap.test-snap.svc2.service"},
+	})
+}
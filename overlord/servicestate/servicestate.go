@@ -0,0 +1,153 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Instruction holds the details of a request to control services.
+type Instruction struct {
+	Action string   `json:"action"`
+	Names  []string `json:"names"`
+
+	// Enable makes a start also enable the services at boot.
+	Enable bool `json:"enable,omitempty"`
+	// Disable makes a stop also disable the services at boot.
+	Disable bool `json:"disable,omitempty"`
+}
+
+// Validate checks that the instruction is one that can be carried out.
+func (inst *Instruction) Validate() error {
+	switch inst.Action {
+	case "start", "stop", "restart":
+		// ok
+	default:
+		return fmt.Errorf("unknown action %q", inst.Action)
+	}
+	if inst.Enable && inst.Action != "start" {
+		return fmt.Errorf("%s does not support the enable option", inst.Action)
+	}
+	if inst.Disable && inst.Action != "stop" {
+		return fmt.Errorf("%s does not support the disable option", inst.Action)
+	}
+	return nil
+}
+
+// serviceAction is what a service-control task carries out on the
+// services of a single snap.
+type serviceAction struct {
+	SnapName string   `json:"snap-name"`
+	Action   string   `json:"action"`
+	Apps     []string `json:"apps"`
+	Enable   bool     `json:"enable,omitempty"`
+	Disable  bool     `json:"disable,omitempty"`
+}
+
+func init() {
+	// removing or refreshing a snap while its services are being
+	// controlled conflicts with it
+	state.RegisterAffectedFunc("service-control", serviceControlAffectedSnaps)
+}
+
+// serviceControlAffectedSnaps returns the name of the snap whose
+// services a service-control task operates on.
+func serviceControlAffectedSnaps(task *state.Task) ([]string, error) {
+	var sa serviceAction
+	if err := task.Get("service-action", &sa); err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain service action from task: %s", task.Summary())
+	}
+	return []string{sa.SnapName}, nil
+}
+
+// quotedNames formats a slice of names to a quoted list of comma
+// separated names, e.g. `"app1", "app2"`
+func quotedNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Control returns a set of tasks carrying out the given instruction
+// on the given services, one task per affected snap.
+// Note that the state must be locked by the caller.
+func Control(st *state.State, appInfos []*snap.AppInfo, inst *Instruction) (*state.TaskSet, error) {
+	return ControlInChange(st, appInfos, inst, "")
+}
+
+// ControlInChange is like Control but for tasks to be added to the
+// change with the given ID, whose own tasks are then not considered
+// as conflicting.
+func ControlInChange(st *state.State, appInfos []*snap.AppInfo, inst *Instruction, changeID string) (*state.TaskSet, error) {
+	if err := inst.Validate(); err != nil {
+		return nil, err
+	}
+
+	appsBySnap := make(map[string][]string)
+	for _, app := range appInfos {
+		if app.Daemon == "" {
+			return nil, fmt.Errorf("cannot %s %s.%s: not a service", inst.Action, app.Snap.InstanceName(), app.Name)
+		}
+		snapName := app.Snap.InstanceName()
+		appsBySnap[snapName] = append(appsBySnap[snapName], app.Name)
+	}
+	if len(appsBySnap) == 0 {
+		return nil, fmt.Errorf("cannot %s: no services given", inst.Action)
+	}
+
+	snapNames := make([]string, 0, len(appsBySnap))
+	for snapName := range appsBySnap {
+		snapNames = append(snapNames, snapName)
+	}
+	sort.Strings(snapNames)
+
+	ts := state.NewTaskSet()
+	for _, snapName := range snapNames {
+		if err := snapstate.CheckChangeConflictIgnoringChange(st, snapName, nil, changeID); err != nil {
+			return nil, err
+		}
+
+		apps := appsBySnap[snapName]
+		sort.Strings(apps)
+
+		summary := fmt.Sprintf(i18n.G("Run service command %q for services %s of snap %q"), inst.Action, quotedNames(apps), snapName)
+		t := st.NewTask("service-control", summary)
+		t.Set("service-action", &serviceAction{
+			SnapName: snapName,
+			Action:   inst.Action,
+			Apps:     apps,
+			Enable:   inst.Enable,
+			Disable:  inst.Disable,
+		})
+		ts.AddTask(t)
+	}
+
+	return ts, nil
+}
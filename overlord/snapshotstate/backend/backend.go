@@ -0,0 +1,304 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package backend implements the low-level primitives to archive, verify
+// and restore the data of snaps.
+package backend
+
+import (
+	"archive/zip"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	_ "golang.org/x/crypto/sha3" // for crypto.SHA3_384
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	archiveName  = "archive.tgz"
+	metadataName = "meta.json"
+	metaHashName = "meta.sha3_384"
+
+	userArchivePrefix = "user/"
+	userArchiveSuffix = ".tgz"
+)
+
+var (
+	timeNow = time.Now
+
+	dirNames = (*os.File).Readdirnames
+)
+
+// Filename of the given snapshot's archive.
+func Filename(snapshot *client.Snapshot) string {
+	return filepath.Join(dirs.SnapshotsDir, fmt.Sprintf("%d_%s_%s_%s.zip", snapshot.SetID, snapshot.Snap, snapshot.Version, snapshot.Revision))
+}
+
+// Iter loops over all snapshots in the snapshots directory, calling f
+// with a Reader for each of them. Snapshots that can't be read are passed
+// on with their Broken field set. Iteration stops at the first error f
+// returns, and Iter returns that error.
+func Iter(f func(*Reader) error) error {
+	dir, err := os.Open(dirs.SnapshotsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// no dir -> no snapshots
+			return nil
+		}
+		return fmt.Errorf("cannot open snapshots directory: %v", err)
+	}
+	defer dir.Close()
+
+	names, err := dirNames(dir, -1)
+	if err != nil {
+		return fmt.Errorf("cannot read snapshots directory: %v", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if filepath.Ext(name) != ".zip" {
+			continue
+		}
+		rsh, openErr := Open(filepath.Join(dirs.SnapshotsDir, name))
+		if openErr != nil && rsh == nil {
+			// the file went away, or similar; nothing to report on
+			continue
+		}
+		err := f(rsh)
+		rsh.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List valid snapshots sets, optionally limited to the given set and
+// snaps.
+func List(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	setshots := map[uint64][]*client.Snapshot{}
+	err := Iter(func(reader *Reader) error {
+		if setID == 0 || reader.SetID == setID {
+			if len(snapNames) == 0 || listContains(snapNames, reader.Snap) {
+				// copy, as the reader is closed after this
+				snapshot := reader.Snapshot
+				setshots[reader.SetID] = append(setshots[reader.SetID], &snapshot)
+			}
+		}
+		return nil
+	})
+
+	sets := make([]client.SnapshotSet, 0, len(setshots))
+	for id, shots := range setshots {
+		sort.Sort(bySnap(shots))
+		sets = append(sets, client.SnapshotSet{ID: id, Snapshots: shots})
+	}
+	sort.Sort(byID(sets))
+
+	return sets, err
+}
+
+// Flags change the behaviour of Save.
+type Flags struct {
+	// Auto marks the snapshot as taken automatically, as opposed to
+	// on request of the user.
+	Auto bool
+}
+
+// Save a snapshot of the data of the given snap, for the given users (or
+// all users, if none are given).
+func Save(id uint64, si *snap.Info, usernames []string, flags *Flags) (*client.Snapshot, error) {
+	if flags == nil {
+		flags = &Flags{}
+	}
+	snapshot := &client.Snapshot{
+		SetID:    id,
+		Snap:     si.InstanceName(),
+		Revision: si.Revision,
+		Version:  si.Version,
+		Time:     timeNow(),
+		SHA3_384: make(map[string]string),
+		Auto:     flags.Auto,
+	}
+
+	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	filename := Filename(snapshot)
+	tmp := filename + ".~"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		f.Close()
+		if !committed {
+			os.Remove(tmp)
+		}
+	}()
+
+	w := zip.NewWriter(f)
+	defer w.Close() // note this does not close the file itself
+
+	if err := addDirToZip(snapshot, w, archiveName, filepath.Dir(si.DataDir()), si.Revision.String(), "common"); err != nil {
+		return nil, err
+	}
+
+	homes, err := usersHomes(usernames)
+	if err != nil {
+		return nil, err
+	}
+	for _, username := range sortedKeys(homes) {
+		entry := userArchivePrefix + username + userArchiveSuffix
+		parent := filepath.Dir(si.UserDataDir(homes[username]))
+		if err := addDirToZip(snapshot, w, entry, parent, si.Revision.String(), "common"); err != nil {
+			return nil, err
+		}
+	}
+
+	metaWriter, err := w.Create(metadataName)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	enc := json.NewEncoder(io.MultiWriter(metaWriter, hasher))
+	if err := enc.Encode(snapshot); err != nil {
+		return nil, err
+	}
+
+	hashWriter, err := w.Create(metaHashName)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(hashWriter, "%x\n", hasher.Sum(nil))
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		return nil, err
+	}
+	committed = true
+
+	return snapshot, nil
+}
+
+// addDirToZip adds to the zip, as a compressed tarball under the given
+// entry name, those of the given subdirectories of parent that exist.
+// The snapshot's size and checksums are updated accordingly.
+func addDirToZip(snapshot *client.Snapshot, w *zip.Writer, entry, parent string, subdirs ...string) error {
+	var present []string
+	for _, subdir := range subdirs {
+		if osutil.IsDirectory(filepath.Join(parent, subdir)) {
+			present = append(present, subdir)
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+
+	archiveWriter, err := w.CreateHeader(&zip.FileHeader{
+		Name: entry,
+		// the tarball is already compressed
+		Method: zip.Store,
+	})
+	if err != nil {
+		return err
+	}
+
+	hasher := crypto.SHA3_384.New()
+	sz := &sizer{}
+	if err := writeTarball(io.MultiWriter(archiveWriter, hasher, sz), parent, present); err != nil {
+		return fmt.Errorf("cannot archive %q: %v", entry, err)
+	}
+
+	snapshot.SHA3_384[entry] = hex.EncodeToString(hasher.Sum(nil))
+	snapshot.Size += sz.size
+
+	return nil
+}
+
+type sizer struct {
+	size int64
+}
+
+func (sz *sizer) Write(data []byte) (n int, err error) {
+	n = len(data)
+	sz.size += int64(n)
+	return n, nil
+}
+
+func listContains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type byID []client.SnapshotSet
+
+func (ss byID) Len() int           { return len(ss) }
+func (ss byID) Less(i, j int) bool { return ss[i].ID < ss[j].ID }
+func (ss byID) Swap(i, j int)      { ss[i], ss[j] = ss[j], ss[i] }
+
+type bySnap []*client.Snapshot
+
+func (ss bySnap) Len() int           { return len(ss) }
+func (ss bySnap) Less(i, j int) bool { return ss[i].Snap < ss[j].Snap }
+func (ss bySnap) Swap(i, j int)      { ss[i], ss[j] = ss[j], ss[i] }
+
+// isUserArchive returns the username of the user whose data a zip entry
+// holds, if it is a user archive.
+func isUserArchive(entry string) (username string, ok bool) {
+	if !strings.HasPrefix(entry, userArchivePrefix) || !strings.HasSuffix(entry, userArchiveSuffix) {
+		return "", false
+	}
+	return entry[len(userArchivePrefix) : len(entry)-len(userArchiveSuffix)], true
+}
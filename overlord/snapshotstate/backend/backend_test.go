@@ -0,0 +1,407 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type snapshotSuite struct {
+	root    string
+	restore []func()
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	dirs.SetRootDir(s.root)
+
+	s.restore = []func(){
+		backend.MockTimeNow(func() time.Time { return time.Date(2017, 4, 1, 10, 30, 0, 0, time.UTC) }),
+		// never try to chown things
+		backend.MockGeteuid(func() int { return 1000 }),
+	}
+
+	for _, dir := range []string{
+		"var/snap/hello-snap/42/foo",
+		"var/snap/hello-snap/common/bar",
+		"home/user1/snap/hello-snap/42",
+		"home/user1/snap/hello-snap/common",
+		"home/user2/snap/hello-snap/42",
+	} {
+		c.Assert(os.MkdirAll(filepath.Join(s.root, dir), 0755), IsNil)
+	}
+	for fn, content := range map[string]string{
+		"var/snap/hello-snap/42/foo/data":         "system data\n",
+		"var/snap/hello-snap/common/bar/data":     "common data\n",
+		"home/user1/snap/hello-snap/42/data":      "user1 data\n",
+		"home/user1/snap/hello-snap/common/data":  "user1 common data\n",
+		"home/user2/snap/hello-snap/42/data":      "user2 data\n",
+		"var/snap/hello-snap/42/foo/private-data": "sekrit\n",
+	} {
+		c.Assert(ioutil.WriteFile(filepath.Join(s.root, fn), []byte(content), 0644), IsNil)
+	}
+	c.Assert(os.Chmod(filepath.Join(s.root, "var/snap/hello-snap/42/foo/private-data"), 0600), IsNil)
+	c.Assert(os.Symlink("data", filepath.Join(s.root, "home/user2/snap/hello-snap/42/link")), IsNil)
+}
+
+func (s *snapshotSuite) TearDownTest(c *C) {
+	for _, restore := range s.restore {
+		restore()
+	}
+	dirs.SetRootDir("")
+}
+
+func (s *snapshotSuite) info(rev int) *snap.Info {
+	return &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(rev)},
+		Version:  "v1.33",
+	}
+}
+
+func (s *snapshotSuite) TestRestoreRoundtripInstance(c *C) {
+	for fn, content := range map[string]string{
+		"var/snap/hello-snap_blue/42/data":          "blue system data\n",
+		"home/user1/snap/hello-snap_blue/42/data":   "blue user1 data\n",
+		"home/user1/snap/hello-snap_blue/common/cd": "blue user1 common data\n",
+	} {
+		c.Assert(os.MkdirAll(filepath.Dir(filepath.Join(s.root, fn)), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(s.root, fn), []byte(content), 0644), IsNil)
+	}
+	info := s.info(42)
+	info.InstanceKey = "blue"
+
+	shw, err := backend.Save(12, info, []string{"user1"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(shw.Snap, Equals, "hello-snap_blue")
+	// a snapshot of the snap itself in the same set does not clash
+	plain, err := backend.Save(12, s.info(42), []string{"user1"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(backend.Filename(plain), Not(Equals), backend.Filename(shw))
+
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "var/snap/hello-snap_blue")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/user1/snap/hello-snap_blue")), IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+	c.Check(shr.Snap, Equals, "hello-snap_blue")
+
+	rs, err := shr.Restore(snap.R(42), []string{"user1"}, c.Logf)
+	c.Assert(err, IsNil)
+	rs.Cleanup()
+
+	for fn, content := range map[string]string{
+		"var/snap/hello-snap_blue/42/data":          "blue system data\n",
+		"home/user1/snap/hello-snap_blue/42/data":   "blue user1 data\n",
+		"home/user1/snap/hello-snap_blue/common/cd": "blue user1 common data\n",
+		// the data of the snap itself is left alone
+		"var/snap/hello-snap/42/foo/data":    "system data\n",
+		"home/user1/snap/hello-snap/42/data": "user1 data\n",
+	} {
+		c.Check(readFile(c, filepath.Join(s.root, fn)), Equals, content, Commentf(fn))
+	}
+}
+
+func readFile(c *C, fn string) string {
+	content, err := ioutil.ReadFile(fn)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *snapshotSuite) TestAllHomes(c *C) {
+	c.Assert(os.MkdirAll(filepath.Join(s.root, "root"), 0700), IsNil)
+
+	homes, err := backend.AllHomes()
+	c.Assert(err, IsNil)
+	c.Check(homes, DeepEquals, map[string]string{
+		"user1": filepath.Join(s.root, "home/user1"),
+		"user2": filepath.Join(s.root, "home/user2"),
+		"root":  filepath.Join(s.root, "root"),
+	})
+}
+
+func (s *snapshotSuite) TestIterNoDir(c *C) {
+	called := false
+	err := backend.Iter(func(*backend.Reader) error {
+		called = true
+		return nil
+	})
+	c.Check(err, IsNil)
+	c.Check(called, Equals, false)
+}
+
+func (s *snapshotSuite) TestSaveOpenCheck(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(shw.SetID, Equals, uint64(12))
+	c.Check(shw.Snap, Equals, "hello-snap")
+	c.Check(shw.Revision, Equals, snap.R(42))
+	c.Check(shw.Version, Equals, "v1.33")
+	c.Check(shw.Time.Equal(time.Date(2017, 4, 1, 10, 30, 0, 0, time.UTC)), Equals, true)
+	c.Check(shw.Size > 0, Equals, true)
+
+	var entries []string
+	for entry := range shw.SHA3_384 {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	c.Check(entries, DeepEquals, []string{"archive.tgz", "user/user1.tgz", "user/user2.tgz"})
+
+	fn := backend.Filename(shw)
+	c.Check(fn, Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
+
+	shr, err := backend.Open(fn)
+	c.Assert(err, IsNil)
+	defer shr.Close()
+	c.Check(shr.Broken, Equals, "")
+	c.Check(shr.SetID, Equals, shw.SetID)
+	c.Check(shr.SHA3_384, DeepEquals, shw.SHA3_384)
+	c.Check(shr.Size, Equals, shw.Size)
+	c.Check(shr.Auto, Equals, false)
+
+	c.Check(shr.Check(nil), IsNil)
+	c.Check(shr.Check([]string{"user2"}), IsNil)
+}
+
+func (s *snapshotSuite) TestSaveAuto(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, &backend.Flags{Auto: true})
+	c.Assert(err, IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+	c.Check(shr.Auto, Equals, true)
+}
+
+func (s *snapshotSuite) TestSaveUsers(c *C) {
+	shw, err := backend.Save(12, s.info(42), []string{"user2"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(shw.SHA3_384, HasLen, 2)
+	c.Check(shw.SHA3_384["user/user2.tgz"], Not(Equals), "")
+
+	_, err = backend.Save(13, s.info(42), []string{"nobody"}, nil)
+	c.Check(err, ErrorMatches, `cannot find home directory of user "nobody"`)
+}
+
+func (s *snapshotSuite) TestCheckDetectsMismatch(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, nil)
+	c.Assert(err, IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+
+	shr.SHA3_384["user/user1.tgz"] = "deadbeef"
+	c.Check(shr.Check(nil), ErrorMatches, `snapshot entry "user/user1.tgz" expected hash \(deadbee…\) does not match actual \(.*\)`)
+	// not checked if not asked for
+	c.Check(shr.Check([]string{"user2"}), IsNil)
+
+	shr.SHA3_384["user/user3.tgz"] = "deadbeef"
+	c.Check(shr.Check(nil), ErrorMatches, `snapshot entry "user/user3.tgz" is missing`)
+}
+
+func (s *snapshotSuite) TestOpenBroken(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), IsNil)
+	fn := filepath.Join(dirs.SnapshotsDir, "7_some-snap_1.0_2.zip")
+	c.Assert(ioutil.WriteFile(fn, []byte("not a zip"), 0600), IsNil)
+
+	shr, err := backend.Open(fn)
+	c.Assert(err, NotNil)
+	c.Assert(shr, NotNil)
+	defer shr.Close()
+	c.Check(shr.Broken, Equals, err.Error())
+	c.Check(shr.SetID, Equals, uint64(7))
+	c.Check(shr.Snap, Equals, "some-snap")
+}
+
+func (s *snapshotSuite) TestIterAndList(c *C) {
+	for _, id := range []uint64{3, 1, 2} {
+		_, err := backend.Save(id, s.info(42), nil, nil)
+		c.Assert(err, IsNil)
+	}
+	other := &snap.Info{
+		SideInfo: snap.SideInfo{RealName: "another-snap", Revision: snap.R(1)},
+		Version:  "1.0",
+	}
+	_, err := backend.Save(2, other, nil, nil)
+	c.Assert(err, IsNil)
+
+	var seen []string
+	err = backend.Iter(func(r *backend.Reader) error {
+		seen = append(seen, filepath.Base(r.Name()))
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Check(seen, DeepEquals, []string{
+		"1_hello-snap_v1.33_42.zip",
+		"2_another-snap_1.0_1.zip",
+		"2_hello-snap_v1.33_42.zip",
+		"3_hello-snap_v1.33_42.zip",
+	})
+
+	setIDsAndSnaps := func(sets []client.SnapshotSet) map[uint64][]string {
+		m := make(map[uint64][]string)
+		for _, set := range sets {
+			for _, sh := range set.Snapshots {
+				m[set.ID] = append(m[set.ID], sh.Snap)
+			}
+		}
+		return m
+	}
+
+	sets, err := backend.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 3)
+	c.Check(sets[0].ID, Equals, uint64(1))
+	c.Check(sets[2].ID, Equals, uint64(3))
+	c.Check(setIDsAndSnaps(sets), DeepEquals, map[uint64][]string{
+		1: {"hello-snap"},
+		2: {"another-snap", "hello-snap"},
+		3: {"hello-snap"},
+	})
+
+	sets, err = backend.List(2, nil)
+	c.Assert(err, IsNil)
+	c.Check(setIDsAndSnaps(sets), DeepEquals, map[uint64][]string{
+		2: {"another-snap", "hello-snap"},
+	})
+
+	sets, err = backend.List(0, []string{"another-snap"})
+	c.Assert(err, IsNil)
+	c.Check(setIDsAndSnaps(sets), DeepEquals, map[uint64][]string{
+		2: {"another-snap"},
+	})
+}
+
+func (s *snapshotSuite) TestRestoreRoundtrip(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, nil)
+	c.Assert(err, IsNil)
+
+	// the data goes away
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "var/snap/hello-snap")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/user1/snap")), IsNil)
+	c.Assert(os.RemoveAll(filepath.Join(s.root, "home/user2/snap")), IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+
+	rs, err := shr.Restore(snap.R(42), nil, c.Logf)
+	c.Assert(err, IsNil)
+	rs.Cleanup()
+
+	for fn, content := range map[string]string{
+		"var/snap/hello-snap/42/foo/data":        "system data\n",
+		"var/snap/hello-snap/common/bar/data":    "common data\n",
+		"home/user1/snap/hello-snap/42/data":     "user1 data\n",
+		"home/user1/snap/hello-snap/common/data": "user1 common data\n",
+		"home/user2/snap/hello-snap/42/data":     "user2 data\n",
+	} {
+		c.Check(readFile(c, filepath.Join(s.root, fn)), Equals, content, Commentf(fn))
+	}
+
+	fi, err := os.Stat(filepath.Join(s.root, "var/snap/hello-snap/42/foo/private-data"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0600))
+
+	target, err := os.Readlink(filepath.Join(s.root, "home/user2/snap/hello-snap/42/link"))
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "data")
+}
+
+func (s *snapshotSuite) TestRestoreToOtherRevisionAndRevert(c *C) {
+	shw, err := backend.Save(12, s.info(42), []string{"user1"}, nil)
+	c.Assert(err, IsNil)
+
+	// the snap got refreshed, and the data changed
+	c.Assert(os.Rename(filepath.Join(s.root, "var/snap/hello-snap/42"), filepath.Join(s.root, "var/snap/hello-snap/43")), IsNil)
+	commonData := filepath.Join(s.root, "var/snap/hello-snap/common/bar/data")
+	c.Assert(ioutil.WriteFile(commonData, []byte("new common data\n"), 0644), IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+
+	rs, err := shr.Restore(snap.R(43), []string{"user1"}, c.Logf)
+	c.Assert(err, IsNil)
+
+	c.Check(readFile(c, filepath.Join(s.root, "var/snap/hello-snap/43/foo/data")), Equals, "system data\n")
+	c.Check(readFile(c, commonData), Equals, "common data\n")
+	c.Check(readFile(c, filepath.Join(s.root, "var/snap/hello-snap/common"+backend.OldSuffix, "bar/data")), Equals, "new common data\n")
+
+	rs.Revert()
+
+	c.Check(readFile(c, commonData), Equals, "new common data\n")
+	c.Check(osutil.FileExists(filepath.Join(s.root, "var/snap/hello-snap/common"+backend.OldSuffix)), Equals, false)
+	c.Check(readFile(c, filepath.Join(s.root, "var/snap/hello-snap/43/foo/data")), Equals, "system data\n")
+}
+
+func (s *snapshotSuite) TestRestoreCleanup(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, nil)
+	c.Assert(err, IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+
+	rs, err := shr.Restore(snap.R(42), nil, c.Logf)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(s.root, "var/snap/hello-snap/42"+backend.OldSuffix)), Equals, true)
+
+	rs.Cleanup()
+	c.Check(osutil.FileExists(filepath.Join(s.root, "var/snap/hello-snap/42"+backend.OldSuffix)), Equals, false)
+	c.Check(readFile(c, filepath.Join(s.root, "var/snap/hello-snap/42/foo/data")), Equals, "system data\n")
+}
+
+func (s *snapshotSuite) TestRestoreHashMismatchReverts(c *C) {
+	shw, err := backend.Save(12, s.info(42), nil, nil)
+	c.Assert(err, IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw))
+	c.Assert(err, IsNil)
+	defer shr.Close()
+
+	shr.SHA3_384["user/user2.tgz"] = "deadbeef"
+	c.Assert(ioutil.WriteFile(filepath.Join(s.root, "var/snap/hello-snap/42/foo/data"), []byte("newer data\n"), 0644), IsNil)
+
+	_, err = shr.Restore(snap.R(42), nil, c.Logf)
+	c.Assert(err, ErrorMatches, `cannot restore snapshot entry "user/user2.tgz": expected hash \(deadbee…\) does not match actual \(.*\)`)
+
+	// what was restored before the failure was reverted
+	c.Check(readFile(c, filepath.Join(s.root, "var/snap/hello-snap/42/foo/data")), Equals, "newer data\n")
+	c.Check(osutil.FileExists(filepath.Join(s.root, "var/snap/hello-snap/42"+backend.OldSuffix)), Equals, false)
+}
@@ -0,0 +1,272 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var geteuid = os.Geteuid
+
+// allHomes returns the home directories of the users of the system,
+// keyed by username; only home directories under /home (and root's) are
+// considered.
+func allHomes() (map[string]string, error) {
+	homes := make(map[string]string)
+
+	matches, err := filepath.Glob(filepath.Dir(dirs.SnapDataHomeGlob))
+	if err != nil {
+		return nil, err
+	}
+	for _, home := range matches {
+		if osutil.IsDirectory(home) {
+			homes[filepath.Base(home)] = home
+		}
+	}
+
+	rootHome := filepath.Join(dirs.GlobalRootDir, "root")
+	if osutil.IsDirectory(rootHome) {
+		homes["root"] = rootHome
+	}
+
+	return homes, nil
+}
+
+// usersHomes returns the home directories of the given users, or of all
+// users if none are given.
+func usersHomes(usernames []string) (map[string]string, error) {
+	homes, err := allHomes()
+	if err != nil {
+		return nil, err
+	}
+	if len(usernames) == 0 {
+		return homes, nil
+	}
+
+	wanted := make(map[string]string, len(usernames))
+	for _, username := range usernames {
+		home, ok := homes[username]
+		if !ok {
+			return nil, fmt.Errorf("cannot find home directory of user %q", username)
+		}
+		wanted[username] = home
+	}
+	return wanted, nil
+}
+
+// writeTarball writes to w a gzip-compressed tarball of the given
+// subdirectories of parent, with paths relative to parent.
+func writeTarball(w io.Writer, parent string, subdirs []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, subdir := range subdirs {
+		err := filepath.Walk(filepath.Join(parent, subdir), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			return addToTarball(tw, parent, path, info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addToTarball(tw *tar.Writer, parent, path string, info os.FileInfo) error {
+	var link string
+	switch mode := info.Mode(); {
+	case mode.IsDir(), mode.IsRegular():
+		// ok
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		link = target
+	default:
+		// sockets, fifos, devices: nothing we can meaningfully restore
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	name, err := filepath.Rel(parent, path)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractTarball extracts the gzip-compressed tarball read from r into
+// dest, which must exist.
+func extractTarball(r io.Reader, dest string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	chown := geteuid() == 0
+	// directory permissions are applied at the end, so that read-only
+	// directories can be filled in first
+	dirModes := make(map[string]os.FileMode)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid entry %q in archive", hdr.Name)
+		}
+		target := filepath.Join(dest, name)
+		mode := hdr.FileInfo().Mode()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			dirModes[target] = mode.Perm()
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chmod(target, mode.Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+
+		if chown {
+			if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return err
+			}
+		}
+	}
+
+	// deepest first
+	modeDirs := make([]string, 0, len(dirModes))
+	for dir := range dirModes {
+		modeDirs = append(modeDirs, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(modeDirs)))
+	for _, dir := range modeDirs {
+		if err := os.Chmod(dir, dirModes[dir]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mkdirAllOwnedAs creates dir and any missing parents, owning the ones
+// it creates by the owner of ownerOf when running as root. It returns the
+// directories it created, outermost first.
+func mkdirAllOwnedAs(dir, ownerOf string) ([]string, error) {
+	var missing []string
+	for d := dir; !osutil.FileExists(d); d = filepath.Dir(d) {
+		missing = append([]string{d}, missing...)
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if geteuid() == 0 && ownerOf != "" {
+		fi, err := os.Stat(ownerOf)
+		if err != nil {
+			return nil, err
+		}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			for _, d := range missing {
+				if err := os.Chown(d, int(st.Uid), int(st.Gid)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return missing, nil
+}
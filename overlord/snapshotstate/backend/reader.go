@@ -0,0 +1,388 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+// maxMetadataSize is the largest metadata file we're willing to read.
+const maxMetadataSize = 64 * 1024
+
+// oldSuffix is appended to the data directories that a restore replaces,
+// until the restore is either reverted or cleaned up.
+const oldSuffix = ".~snapshot-old"
+
+// A Reader is a snapshot that's been opened for reading.
+type Reader struct {
+	*os.File
+	client.Snapshot
+}
+
+// Open a Snapshot given its full filename.
+//
+// If the returned error is nil, the caller must close the reader (or
+// its file) when done with it.
+//
+// If the returned error is non-nil but the reader is not, the snapshot
+// could be opened but not read; the reader's Broken field says why, and
+// it must still be closed.
+func Open(fn string) (reader *Reader, e error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	reader = &Reader{File: f}
+	defer func() {
+		if e != nil {
+			reader.Broken = e.Error()
+			// at least say which set and snap this was meant to be
+			reader.SetID, reader.Snap = setAndSnapFromFilename(fn)
+		}
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return reader, err
+	}
+	z, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return reader, err
+	}
+
+	var metadata, metaHash []byte
+	for _, zf := range z.File {
+		switch zf.Name {
+		case metadataName:
+			metadata, err = readZipEntry(zf)
+		case metaHashName:
+			metaHash, err = readZipEntry(zf)
+		}
+		if err != nil {
+			return reader, fmt.Errorf("cannot read %q: %v", zf.Name, err)
+		}
+	}
+	if metadata == nil || metaHash == nil {
+		return reader, errors.New("snapshot has no metadata")
+	}
+
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(metadata)
+	if expected, actual := string(bytes.TrimSpace(metaHash)), hex.EncodeToString(hasher.Sum(nil)); expected != actual {
+		return reader, fmt.Errorf("metadata hash (%.7s…) does not match expected (%.7s…)", actual, expected)
+	}
+
+	if err := json.Unmarshal(metadata, &reader.Snapshot); err != nil {
+		return reader, fmt.Errorf("cannot decode metadata: %v", err)
+	}
+	if !reader.IsValid() {
+		return reader, errors.New("invalid snapshot")
+	}
+
+	return reader, nil
+}
+
+func readZipEntry(zf *zip.File) ([]byte, error) {
+	if zf.UncompressedSize64 > maxMetadataSize {
+		return nil, errors.New("too big")
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(io.LimitReader(rc, maxMetadataSize))
+}
+
+func setAndSnapFromFilename(fn string) (uint64, string) {
+	parts := strings.SplitN(filepath.Base(fn), "_", 3)
+	if len(parts) < 2 {
+		return 0, ""
+	}
+	setID, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, ""
+	}
+	return setID, parts[1]
+}
+
+// zipEntries returns the archives in the snapshot, keyed by name.
+func (r *Reader) zipEntries() (map[string]*zip.File, error) {
+	fi, err := r.Stat()
+	if err != nil {
+		return nil, err
+	}
+	z, err := zip.NewReader(r.File, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*zip.File, len(z.File))
+	for _, zf := range z.File {
+		if zf.Name == metadataName || zf.Name == metaHashName {
+			continue
+		}
+		if _, ok := r.SHA3_384[zf.Name]; !ok {
+			return nil, fmt.Errorf("snapshot entry %q has no hash", zf.Name)
+		}
+		entries[zf.Name] = zf
+	}
+	for name := range r.SHA3_384 {
+		if _, ok := entries[name]; !ok {
+			return nil, fmt.Errorf("snapshot entry %q is missing", name)
+		}
+	}
+
+	return entries, nil
+}
+
+// wanted returns whether the given archive is for one of the given users
+// (or for the system, or for anybody if no users are given).
+func wanted(entry string, usernames []string) bool {
+	username, isUser := isUserArchive(entry)
+	return !isUser || len(usernames) == 0 || listContains(usernames, username)
+}
+
+func checkHash(r io.Reader, entry, expected string) error {
+	hasher := crypto.SHA3_384.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		return fmt.Errorf("snapshot entry %q expected hash (%.7s…) does not match actual (%.7s…)", entry, expected, actual)
+	}
+	return nil
+}
+
+// Check that the data in the snapshot, limited to the given users (if
+// non-empty), matches the checksums recorded for it.
+func (r *Reader) Check(usernames []string) error {
+	entries, err := r.zipEntries()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedEntryNames(entries) {
+		if !wanted(name, usernames) {
+			continue
+		}
+		rc, err := entries[name].Open()
+		if err != nil {
+			return err
+		}
+		err = checkHash(rc, name, r.SHA3_384[name])
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedEntryNames(entries map[string]*zip.File) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Logf is the type of the function used to report progress.
+type Logf func(format string, args ...interface{})
+
+// RestoreState records what a restore did, so it can be either cleaned
+// up or reverted.
+type RestoreState struct {
+	// Done lists the data directories put in place by the restore
+	Done []string `json:"done,omitempty"`
+	// Moved lists the data directories that had a previous copy moved
+	// aside, which Revert puts back and Cleanup removes
+	Moved []string `json:"moved,omitempty"`
+	// Created lists the parent directories created by the restore
+	Created []string `json:"created,omitempty"`
+}
+
+// Cleanup removes the data the restore replaced.
+func (rs *RestoreState) Cleanup() {
+	for _, dir := range rs.Moved {
+		if err := os.RemoveAll(dir + oldSuffix); err != nil {
+			logger.Noticef("Cannot remove %q: %v", dir+oldSuffix, err)
+		}
+	}
+	rs.Moved = nil
+}
+
+// Revert undoes the restore, putting the replaced data back in place.
+func (rs *RestoreState) Revert() {
+	for i := len(rs.Done) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(rs.Done[i]); err != nil {
+			logger.Noticef("Cannot remove %q: %v", rs.Done[i], err)
+		}
+	}
+	for _, dir := range rs.Moved {
+		if err := os.Rename(dir+oldSuffix, dir); err != nil {
+			logger.Noticef("Cannot restore %q: %v", dir, err)
+		}
+	}
+	for i := len(rs.Created) - 1; i >= 0; i-- {
+		// only removes it if empty, which is what we want
+		os.Remove(rs.Created[i])
+	}
+	rs.Done, rs.Moved, rs.Created = nil, nil, nil
+}
+
+// Restore the data from the snapshot, limited to the given users (if
+// non-empty). The data for the snapshotted revision is restored as that
+// of the current revision of the snap instance the snapshot is of.
+//
+// If successful this will replace the existing data (for the current
+// revision and the common one) with that in the snapshot; the returned
+// RestoreState can be used to revert that, or to clean up the replaced
+// data once it's no longer needed. If it fails, whatever it did is
+// reverted.
+func (r *Reader) Restore(current snap.Revision, usernames []string, logf Logf) (rs *RestoreState, e error) {
+	entries, err := r.zipEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	rs = &RestoreState{}
+	defer func() {
+		if e != nil {
+			rs.Revert()
+		}
+	}()
+
+	var homes map[string]string
+	for _, name := range sortedEntryNames(entries) {
+		if !wanted(name, usernames) {
+			continue
+		}
+
+		var parent, home string
+		if username, isUser := isUserArchive(name); isUser {
+			if homes == nil {
+				homes, err = allHomes()
+				if err != nil {
+					return rs, err
+				}
+			}
+			home = homes[username]
+			if home == "" {
+				logf("Skipping restore of data of user %q: no home directory.", username)
+				continue
+			}
+			parent = filepath.Join(home, "snap", r.Snap)
+		} else {
+			parent = filepath.Join(dirs.SnapDataDir, r.Snap)
+		}
+
+		if err := r.restoreEntry(entries[name], parent, home, current, rs); err != nil {
+			return rs, fmt.Errorf("cannot restore snapshot entry %q: %v", name, err)
+		}
+	}
+
+	return rs, nil
+}
+
+func (r *Reader) restoreEntry(zf *zip.File, parent, home string, current snap.Revision, rs *RestoreState) error {
+	created, err := mkdirAllOwnedAs(parent, home)
+	if err != nil {
+		return err
+	}
+	rs.Created = append(rs.Created, created...)
+
+	tempdir, err := ioutil.TempDir(parent, ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	hasher := crypto.SHA3_384.New()
+	tr := io.TeeReader(rc, hasher)
+	if err := extractTarball(tr, tempdir); err != nil {
+		return err
+	}
+	// make sure everything was read, so the hash is of the whole thing
+	if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+		return err
+	}
+	name := zf.Name
+	if expected, actual := r.SHA3_384[name], hex.EncodeToString(hasher.Sum(nil)); expected != actual {
+		return fmt.Errorf("expected hash (%.7s…) does not match actual (%.7s…)", expected, actual)
+	}
+
+	extracted, err := ioutil.ReadDir(tempdir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range extracted {
+		targetName := fi.Name()
+		if targetName == r.Revision.String() {
+			targetName = current.String()
+		}
+		target := filepath.Join(parent, targetName)
+
+		if osutil.FileExists(target) {
+			// leftovers from an earlier restore that wasn't cleaned up
+			if err := os.RemoveAll(target + oldSuffix); err != nil {
+				return err
+			}
+			if err := os.Rename(target, target+oldSuffix); err != nil {
+				return err
+			}
+			rs.Moved = append(rs.Moved, target)
+		}
+		if err := os.Rename(filepath.Join(tempdir, fi.Name()), target); err != nil {
+			return err
+		}
+		rs.Done = append(rs.Done, target)
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package snapshotstate implements the manager and state aspects
+// responsible for saving, checking, restoring and forgetting snapshots
+// of the data of snaps.
+package snapshotstate
+
+import (
+	"os"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	backendSave = backend.Save
+	backendOpen = backend.Open
+	osRemove    = os.Remove
+)
+
+// SnapshotManager is responsible for the snapshots of snap data.
+type SnapshotManager struct {
+	state  *state.State
+	runner *state.TaskRunner
+}
+
+// Manager returns a new snapshot manager.
+func Manager(st *state.State) (*SnapshotManager, error) {
+	runner := state.NewTaskRunner(st)
+
+	runner.AddHandler("save-snapshot", doSave, doForget)
+	runner.AddHandler("forget-snapshot", doForget, nil)
+	runner.AddHandler("check-snapshot", doCheck, nil)
+	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
+	runner.AddCleanup("restore-snapshot", cleanupRestore)
+
+	return &SnapshotManager{state: st, runner: runner}, nil
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *SnapshotManager) Ensure() error {
+	m.runner.Ensure()
+	return nil
+}
+
+// Wait implements StateManager.Wait.
+func (m *SnapshotManager) Wait() {
+	m.runner.Wait()
+}
+
+// Stop implements StateManager.Stop.
+func (m *SnapshotManager) Stop() {
+	m.runner.Stop()
+}
+
+func taskSetup(task *state.Task) (*snapshotSetup, error) {
+	var snapshot snapshotSetup
+
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+func doSave(task *state.Task, _ *tomb.Tomb) error {
+	snapshot, err := taskSetup(task)
+	if err != nil {
+		return err
+	}
+
+	st := task.State()
+	st.Lock()
+	info, err := snapstate.CurrentInfo(st, snapshot.Snap)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	saved, err := backendSave(snapshot.SetID, info, snapshot.Users, &backend.Flags{Auto: snapshot.Auto})
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	snapshot.Filename = backend.Filename(saved)
+	task.Set("snapshot-setup", snapshot)
+
+	return nil
+}
+
+func doForget(task *state.Task, _ *tomb.Tomb) error {
+	snapshot, err := taskSetup(task)
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Filename == "" {
+		// nothing was saved
+		return nil
+	}
+
+	if err := osRemove(snapshot.Filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func doCheck(task *state.Task, _ *tomb.Tomb) error {
+	snapshot, err := taskSetup(task)
+	if err != nil {
+		return err
+	}
+
+	reader, err := backendOpen(snapshot.Filename)
+	if reader != nil {
+		defer reader.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	return reader.Check(snapshot.Users)
+}
+
+func doRestore(task *state.Task, _ *tomb.Tomb) error {
+	snapshot, err := taskSetup(task)
+	if err != nil {
+		return err
+	}
+
+	reader, err := backendOpen(snapshot.Filename)
+	if reader != nil {
+		defer reader.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	st := task.State()
+	logf := func(format string, args ...interface{}) {
+		st.Lock()
+		defer st.Unlock()
+		task.Logf(format, args...)
+	}
+
+	rs, err := reader.Restore(snapshot.Current, snapshot.Users, logf)
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	task.Set("restore-state", rs)
+
+	return nil
+}
+
+func restoreState(task *state.Task) (*backend.RestoreState, error) {
+	var rs backend.RestoreState
+
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+	if err := task.Get("restore-state", &rs); err != nil {
+		return nil, err
+	}
+
+	return &rs, nil
+}
+
+func undoRestore(task *state.Task, _ *tomb.Tomb) error {
+	rs, err := restoreState(task)
+	if err != nil {
+		return err
+	}
+
+	rs.Revert()
+
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+	task.Set("restore-state", rs)
+
+	return nil
+}
+
+func cleanupRestore(task *state.Task, _ *tomb.Tomb) error {
+	rs, err := restoreState(task)
+	if err == state.ErrNoState {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rs.Cleanup()
+
+	return nil
+}
+
+func init() {
+	snapstate.AutomaticSnapshot = AutomaticSnapshot
+}
@@ -0,0 +1,310 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type snapshotSuite struct {
+	root  string
+	state *state.State
+	mgr   *snapshotstate.SnapshotManager
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	s.root = c.MkDir()
+	dirs.SetRootDir(s.root)
+
+	s.state = state.New(nil)
+	mgr, err := snapshotstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.mgr = mgr
+	s.mgr.AddErrorTrigger()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.installSnap(c, "foo", 7, true)
+	s.installSnap(c, "bar", 3, true)
+	s.installSnap(c, "baz", 1, false)
+}
+
+func (s *snapshotSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *snapshotSuite) installSnap(c *C, name string, rev int, active bool) {
+	si := &snap.SideInfo{RealName: name, Revision: snap.R(rev)}
+	snaptest.MockSnap(c, "name: "+name+"\nversion: 1.0\n", "", si)
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Active:   active,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	dataDir := filepath.Join(dirs.SnapDataDir, name, snap.R(rev).String())
+	c.Assert(os.MkdirAll(dataDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "data"), []byte(name+" data\n"), 0644), IsNil)
+}
+
+func (s *snapshotSuite) settle() {
+	for i := 0; i < 50; i++ {
+		s.mgr.Ensure()
+		s.mgr.Wait()
+	}
+}
+
+func (s *snapshotSuite) run(c *C, ts *state.TaskSet) *state.Change {
+	chg := s.state.NewChange("snapshot", "...")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	return chg
+}
+
+func (s *snapshotSuite) dataFile(name string, rev int) string {
+	return filepath.Join(dirs.SnapDataDir, name, snap.R(rev).String(), "data")
+}
+
+func readFile(c *C, fn string) string {
+	content, err := ioutil.ReadFile(fn)
+	c.Assert(err, IsNil)
+	return string(content)
+}
+
+func (s *snapshotSuite) TestHookIsSet(c *C) {
+	c.Check(snapstate.AutomaticSnapshot, NotNil)
+}
+
+func (s *snapshotSuite) TestSaveTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, saved, ts, err := snapshotstate.Save(s.state, nil, []string{"user1"})
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(1))
+	// only active snaps
+	c.Check(saved, DeepEquals, []string{"bar", "foo"})
+	c.Assert(ts.Tasks(), HasLen, 2)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "save-snapshot")
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Save data of snap "bar" in snapshot set #1`)
+	c.Check(ts.Tasks()[1].Summary(), Equals, `Save data of snap "foo" in snapshot set #1`)
+
+	setID, saved, _, err = snapshotstate.Save(s.state, []string{"baz"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(2))
+	c.Check(saved, DeepEquals, []string{"baz"})
+}
+
+func (s *snapshotSuite) TestSaveSetIDFollowsDisk(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapshotsDir, "41_foo_1.0_7.zip"), nil, 0600), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, _, _, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(setID, Equals, uint64(42))
+}
+
+func (s *snapshotSuite) TestSaveErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, _, err := snapshotstate.Save(s.state, []string{"foo", "nope"}, nil)
+	c.Check(err, ErrorMatches, `snap "nope" is not installed`)
+
+	chg := s.state.NewChange("install", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "foo"}})
+	chg.AddTask(t)
+
+	_, _, _, err = snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Check(err, ErrorMatches, `snap "foo" has changes in progress`)
+}
+
+func (s *snapshotSuite) TestSaveCheckRestoreForget(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, _, ts, err := snapshotstate.Save(s.state, []string{"foo", "bar"}, nil)
+	c.Assert(err, IsNil)
+	chg := s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	sets, err := snapshotstate.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].ID, Equals, setID)
+	c.Assert(sets[0].Snapshots, HasLen, 2)
+	c.Check(sets[0].Snapshots[0].Snap, Equals, "bar")
+	c.Check(sets[0].Snapshots[1].Snap, Equals, "foo")
+	c.Check(sets[0].Snapshots[1].Revision, Equals, snap.R(7))
+	c.Check(sets[0].Snapshots[1].Auto, Equals, false)
+
+	checked, ts, err := snapshotstate.Check(s.state, setID, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(checked, DeepEquals, []string{"bar", "foo"})
+	chg = s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	c.Assert(ioutil.WriteFile(s.dataFile("foo", 7), []byte("changed\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(s.dataFile("bar", 3), []byte("changed\n"), 0644), IsNil)
+
+	restored, ts, err := snapshotstate.Restore(s.state, setID, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(restored, DeepEquals, []string{"foo"})
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Restore data of snap "foo" from snapshot set #1`)
+	chg = s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	c.Check(readFile(c, s.dataFile("foo", 7)), Equals, "foo data\n")
+	c.Check(readFile(c, s.dataFile("bar", 3)), Equals, "changed\n")
+	// the replaced data was cleaned up
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "foo", "7.~snapshot-old")), Equals, false)
+
+	forgotten, ts, err := snapshotstate.Forget(s.state, setID, nil)
+	c.Assert(err, IsNil)
+	c.Check(forgotten, DeepEquals, []string{"bar", "foo"})
+	chg = s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	sets, err = snapshotstate.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+}
+
+func (s *snapshotSuite) TestSaveUndoForgets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	errTask := s.state.NewTask("error-trigger", "...")
+	errTask.WaitAll(ts)
+	ts.AddTask(errTask)
+	chg := s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	sets, err := snapshotstate.List(0, nil)
+	c.Assert(err, IsNil)
+	c.Check(sets, HasLen, 0)
+}
+
+func (s *snapshotSuite) TestRestoreUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	chg := s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	c.Assert(ioutil.WriteFile(s.dataFile("foo", 7), []byte("changed\n"), 0644), IsNil)
+
+	_, ts, err = snapshotstate.Restore(s.state, setID, nil, nil)
+	c.Assert(err, IsNil)
+	errTask := s.state.NewTask("error-trigger", "...")
+	errTask.WaitAll(ts)
+	ts.AddTask(errTask)
+	chg = s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	c.Check(readFile(c, s.dataFile("foo", 7)), Equals, "changed\n")
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "foo", "7.~snapshot-old")), Equals, false)
+}
+
+func (s *snapshotSuite) TestSetErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	s.run(c, ts)
+
+	_, _, err = snapshotstate.Check(s.state, 42, nil, nil)
+	c.Check(err, ErrorMatches, `snapshot set #42 not found`)
+	_, _, err = snapshotstate.Restore(s.state, setID, []string{"bar"}, nil)
+	c.Check(err, ErrorMatches, `snapshot set #1 has no snapshot of snap "bar"`)
+	_, _, err = snapshotstate.Forget(s.state, 42, nil)
+	c.Check(err, ErrorMatches, `snapshot set #42 not found`)
+}
+
+func (s *snapshotSuite) TestForgetConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	setID, _, ts, err := snapshotstate.Save(s.state, []string{"foo"}, nil)
+	c.Assert(err, IsNil)
+	s.run(c, ts)
+
+	_, ts, err = snapshotstate.Check(s.state, setID, nil, nil)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("check-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Forget(s.state, setID, nil)
+	c.Check(err, ErrorMatches, `cannot operate on snapshot set #1 while change "\d+" is in progress`)
+	c.Check(err, DeepEquals, &state.ChangeConflictError{
+		Message:    fmt.Sprintf("cannot operate on snapshot set #1 while change %q is in progress", chg.ID()),
+		ChangeKind: "check-snapshot",
+		ChangeID:   chg.ID(),
+	})
+}
+
+func (s *snapshotSuite) TestAutomaticSnapshot(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapshotstate.AutomaticSnapshot(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Save data of snap "foo" in automatic snapshot set #1`)
+
+	chg := s.run(c, ts)
+	c.Assert(chg.Status(), Equals, state.DoneStatus, Commentf("%v", chg.Err()))
+
+	sets, err := snapshotstate.List(1, []string{"foo"})
+	c.Assert(err, IsNil)
+	c.Assert(sets, HasLen, 1)
+	c.Check(sets[0].Snapshots[0].Auto, Equals, true)
+}
@@ -0,0 +1,365 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var (
+	backendIter = backend.Iter
+	backendList = backend.List
+)
+
+// snapshotSetup is what the snapshot tasks carry out, for a single snap.
+type snapshotSetup struct {
+	SetID    uint64        `json:"set-id"`
+	Snap     string        `json:"snap"`
+	Users    []string      `json:"users,omitempty"`
+	Filename string        `json:"filename,omitempty"`
+	Current  snap.Revision `json:"current,omitempty"`
+	Auto     bool          `json:"auto,omitempty"`
+}
+
+// newSnapshotSetID allocates the ID of a new snapshot set.
+func newSnapshotSetID(st *state.State) (uint64, error) {
+	var lastSetID uint64
+	err := st.Get("last-snapshot-set-id", &lastSetID)
+	if err != nil && err != state.ErrNoState {
+		return 0, err
+	}
+
+	// there might be sets on disk the state doesn't know about,
+	// e.g. if the state was reset
+	err = backendIter(func(r *backend.Reader) error {
+		if r.SetID > lastSetID {
+			lastSetID = r.SetID
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	lastSetID++
+	st.Set("last-snapshot-set-id", lastSetID)
+
+	return lastSetID, nil
+}
+
+func allActiveSnapNames(st *state.State) ([]string, error) {
+	all, err := snapstate.All(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(all))
+	for name, snapst := range all {
+		if snapst.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// snapshotSetEntity is the conflict entity of the given snapshot set,
+// affected by the tasks reading or removing it; snapshotForgetEntity is
+// affected only by the tasks removing it.
+func snapshotSetEntity(setID uint64) string {
+	return fmt.Sprintf("snapshot-set:%d", setID)
+}
+
+func snapshotForgetEntity(setID uint64) string {
+	return fmt.Sprintf("snapshot-set:%d:forget", setID)
+}
+
+func snapshotAffected(task *state.Task) ([]string, error) {
+	var snapshot snapshotSetup
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return nil, fmt.Errorf("internal error: task %s (%s) is missing snapshot information", task.ID(), task.Kind())
+	}
+	affected := []string{snapshotSetEntity(snapshot.SetID)}
+	if task.Kind() == "forget-snapshot" {
+		affected = append(affected, snapshotForgetEntity(snapshot.SetID))
+	}
+	return affected, nil
+}
+
+func init() {
+	state.RegisterAffectedFunc("check-snapshot", snapshotAffected)
+	state.RegisterAffectedFunc("restore-snapshot", snapshotAffected)
+	state.RegisterAffectedFunc("forget-snapshot", snapshotAffected)
+}
+
+// checkSnapshotConflict checks for in-progress tasks affecting the given
+// conflict entity of the snapshot set.
+func checkSnapshotConflict(st *state.State, setID uint64, entity string) error {
+	task, err := st.ConflictingTask(entity)
+	if err != nil {
+		return err
+	}
+	if task != nil {
+		chg := task.Change()
+		return &state.ChangeConflictError{
+			Message:    fmt.Sprintf("cannot operate on snapshot set #%d while change %q is in progress", setID, chg.ID()),
+			ChangeKind: chg.Kind(),
+			ChangeID:   chg.ID(),
+		}
+	}
+	return nil
+}
+
+// snapshotFilenames returns the filenames of the snapshots in the given
+// set, keyed by snap name, limited to the given snaps (if non-empty).
+// Broken snapshots are an error unless allowBroken is set.
+func snapshotFilenames(setID uint64, snapNames []string, allowBroken bool) (map[string]string, error) {
+	filenames := make(map[string]string)
+	found := false
+	err := backendIter(func(r *backend.Reader) error {
+		if r.SetID != setID {
+			return nil
+		}
+		found = true
+		if len(snapNames) > 0 && !listContains(snapNames, r.Snap) {
+			return nil
+		}
+		if r.Broken != "" && !allowBroken {
+			return fmt.Errorf("cannot use snapshot of snap %q in set #%d: %s", r.Snap, setID, r.Broken)
+		}
+		filenames[r.Snap] = r.Name()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("snapshot set #%d not found", setID)
+	}
+	for _, name := range snapNames {
+		if _, ok := filenames[name]; !ok {
+			return nil, fmt.Errorf("snapshot set #%d has no snapshot of snap %q", setID, name)
+		}
+	}
+
+	return filenames, nil
+}
+
+func listContains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedSnapNames(filenames map[string]string) []string {
+	names := make([]string, 0, len(filenames))
+	for name := range filenames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List valid snapshots sets, optionally limited to the given set and
+// snaps.
+func List(setID uint64, snapNames []string) ([]client.SnapshotSet, error) {
+	return backendList(setID, snapNames)
+}
+
+// Save creates a taskset for taking snapshots of the data of the given
+// snaps (or of all active snaps, if none are given), for the given users
+// (or all users, if none are given).
+// Note that the state must be locked by the caller.
+func Save(st *state.State, snapNames []string, users []string) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if len(snapNames) == 0 {
+		snapNames, err = allActiveSnapNames(st)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	} else {
+		for _, name := range snapNames {
+			var snapst snapstate.SnapState
+			if err := snapstate.Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+				return 0, nil, nil, err
+			}
+			if !snapst.HasCurrent() {
+				return 0, nil, nil, fmt.Errorf("snap %q is not installed", name)
+			}
+		}
+	}
+
+	for _, name := range snapNames {
+		if err := snapstate.CheckChangeConflict(st, name, nil); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	setID, err = newSnapshotSetID(st)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	ts = state.NewTaskSet()
+	for _, name := range snapNames {
+		desc := fmt.Sprintf(i18n.G("Save data of snap %q in snapshot set #%d"), name, setID)
+		task := st.NewTask("save-snapshot", desc)
+		task.Set("snapshot-setup", &snapshotSetup{
+			SetID: setID,
+			Snap:  name,
+			Users: users,
+		})
+		ts.AddTask(task)
+	}
+
+	return setID, snapNames, ts, nil
+}
+
+// AutomaticSnapshot creates a taskset for taking a snapshot of the data
+// of the given snap before it's removed.
+// Note that the state must be locked by the caller.
+func AutomaticSnapshot(st *state.State, snapName string) (*state.TaskSet, error) {
+	setID, err := newSnapshotSetID(st)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf(i18n.G("Save data of snap %q in automatic snapshot set #%d"), snapName, setID)
+	task := st.NewTask("save-snapshot", desc)
+	task.Set("snapshot-setup", &snapshotSetup{
+		SetID: setID,
+		Snap:  snapName,
+		Auto:  true,
+	})
+
+	return state.NewTaskSet(task), nil
+}
+
+// Restore creates a taskset for restoring the data in the given snapshot
+// set, limited to the given snaps and users (if non-empty), into the
+// current revision of the snaps.
+// Note that the state must be locked by the caller.
+func Restore(st *state.State, setID uint64, snapNames []string, users []string) (snapsRestored []string, ts *state.TaskSet, err error) {
+	if err := checkSnapshotConflict(st, setID, snapshotForgetEntity(setID)); err != nil {
+		return nil, nil, err
+	}
+
+	filenames, err := snapshotFilenames(setID, snapNames, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapsRestored = sortedSnapNames(filenames)
+	ts = state.NewTaskSet()
+	for _, name := range snapsRestored {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil && err != state.ErrNoState {
+			return nil, nil, err
+		}
+		if !snapst.HasCurrent() {
+			return nil, nil, fmt.Errorf("cannot restore snapshot of snap %q: snap is not installed", name)
+		}
+		if err := snapstate.CheckChangeConflict(st, name, nil); err != nil {
+			return nil, nil, err
+		}
+
+		desc := fmt.Sprintf(i18n.G("Restore data of snap %q from snapshot set #%d"), name, setID)
+		task := st.NewTask("restore-snapshot", desc)
+		task.Set("snapshot-setup", &snapshotSetup{
+			SetID:    setID,
+			Snap:     name,
+			Users:    users,
+			Filename: filenames[name],
+			Current:  snapst.Current,
+		})
+		ts.AddTask(task)
+	}
+
+	return snapsRestored, ts, nil
+}
+
+// Check creates a taskset for verifying the checksums of the snapshots
+// in the given set, limited to the given snaps and users (if non-empty).
+// Note that the state must be locked by the caller.
+func Check(st *state.State, setID uint64, snapNames []string, users []string) (snapsChecked []string, ts *state.TaskSet, err error) {
+	if err := checkSnapshotConflict(st, setID, snapshotForgetEntity(setID)); err != nil {
+		return nil, nil, err
+	}
+
+	filenames, err := snapshotFilenames(setID, snapNames, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapsChecked = sortedSnapNames(filenames)
+	ts = state.NewTaskSet()
+	for _, name := range snapsChecked {
+		desc := fmt.Sprintf(i18n.G("Check data of snap %q in snapshot set #%d"), name, setID)
+		task := st.NewTask("check-snapshot", desc)
+		task.Set("snapshot-setup", &snapshotSetup{
+			SetID:    setID,
+			Snap:     name,
+			Users:    users,
+			Filename: filenames[name],
+		})
+		ts.AddTask(task)
+	}
+
+	return snapsChecked, ts, nil
+}
+
+// Forget creates a taskset for permanently removing the snapshots in the
+// given set, limited to the given snaps (if non-empty).
+// Note that the state must be locked by the caller.
+func Forget(st *state.State, setID uint64, snapNames []string) (snapsForgotten []string, ts *state.TaskSet, err error) {
+	if err := checkSnapshotConflict(st, setID, snapshotSetEntity(setID)); err != nil {
+		return nil, nil, err
+	}
+
+	filenames, err := snapshotFilenames(setID, snapNames, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapsForgotten = sortedSnapNames(filenames)
+	ts = state.NewTaskSet()
+	for _, name := range snapsForgotten {
+		desc := fmt.Sprintf(i18n.G("Drop data of snap %q from snapshot set #%d"), name, setID)
+		task := st.NewTask("forget-snapshot", desc)
+		task.Set("snapshot-setup", &snapshotSetup{
+			SetID:    setID,
+			Snap:     name,
+			Filename: filenames[name],
+		})
+		ts.AddTask(task)
+	}
+
+	return snapsForgotten, ts, nil
+}
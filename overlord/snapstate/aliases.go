@@ -0,0 +1,425 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// AliasTarget describes the app of a snap an alias points to.
+type AliasTarget struct {
+	App string `json:"app"`
+	// Auto is set for aliases declared in snap.yaml and granted
+	// through the snap-declaration, as opposed to manual ones.
+	Auto bool `json:"auto,omitempty"`
+	// Disabled is set for automatic aliases that were explicitly
+	// removed, so that they are not enabled again on refresh.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// AutoAliases allows to hook support for retrieving the aliases of a
+// snap that should be enabled automatically.
+var AutoAliases func(st *state.State, info *snap.Info) ([]string, error)
+
+// aliasTarget returns the name of the wrapper of the given app, which
+// is what the alias symlinks point to.
+func aliasTarget(snapName, appName string) string {
+	if appName == snapName {
+		return snapName
+	}
+	return snapName + "." + appName
+}
+
+// backendAliases returns the enabled aliases for the backend, sorted by name.
+func backendAliases(snapName string, aliases map[string]*AliasTarget) []*backend.Alias {
+	var res []*backend.Alias
+	for alias, target := range aliases {
+		if target.Disabled {
+			continue
+		}
+		res = append(res, &backend.Alias{Name: alias, Target: aliasTarget(snapName, target.App)})
+	}
+	sort.Sort(byAliasName(res))
+	return res
+}
+
+type byAliasName []*backend.Alias
+
+func (ba byAliasName) Len() int           { return len(ba) }
+func (ba byAliasName) Swap(i, j int)      { ba[i], ba[j] = ba[j], ba[i] }
+func (ba byAliasName) Less(i, j int) bool { return ba[i].Name < ba[j].Name }
+
+// inCommandNamespace returns whether name is the command of the given
+// snap or one of its apps.
+func inCommandNamespace(name, snapName string) bool {
+	return name == snapName || strings.HasPrefix(name, snapName+".")
+}
+
+// checkAliasConflict checks that alias can be enabled for the given
+// snap, that is, that it doesn't clash with the command namespace of
+// an installed snap nor with an alias of another snap.
+func checkAliasConflict(st *state.State, snapName, alias string) error {
+	snapStates, err := All(st)
+	if err != nil {
+		return err
+	}
+	for name, snapst := range snapStates {
+		if inCommandNamespace(alias, name) {
+			return fmt.Errorf("cannot enable alias %q for %q, it conflicts with the command namespace of installed snap %q", alias, snapName, name)
+		}
+		if name == snapName {
+			continue
+		}
+		if target := snapst.Aliases[alias]; target != nil && !target.Disabled {
+			return fmt.Errorf("cannot enable alias %q for %q, already enabled for %q", alias, snapName, name)
+		}
+	}
+	return nil
+}
+
+// checkSnapAliasConflict checks that the command namespace of the given
+// snap doesn't clash with the aliases of other installed snaps.
+func checkSnapAliasConflict(st *state.State, snapName string) error {
+	snapStates, err := All(st)
+	if err != nil {
+		return err
+	}
+	for name, snapst := range snapStates {
+		if name == snapName {
+			continue
+		}
+		for alias, target := range snapst.Aliases {
+			if !target.Disabled && inCommandNamespace(alias, snapName) {
+				return fmt.Errorf("snap %q command namespace conflicts with alias %q for %q snap", snapName, alias, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Alias enables the given alias for the app of the snap.
+// Note that the state must be locked by the caller.
+func Alias(st *state.State, snapName, appName, alias string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, snapName, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot find snap %q", snapName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := snap.ValidateAlias(alias); err != nil {
+		return nil, err
+	}
+	if err := CheckChangeConflict(st, snapName, nil); err != nil {
+		return nil, err
+	}
+
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	app := info.Apps[appName]
+	if app == nil {
+		return nil, fmt.Errorf("cannot find app %q in %q", appName, snapName)
+	}
+	if app.Daemon != "" {
+		return nil, fmt.Errorf("cannot enable alias %q for daemon %q of %q", alias, appName, snapName)
+	}
+	if err := checkAliasConflict(st, snapName, alias); err != nil {
+		return nil, err
+	}
+
+	snapsup := &SnapSetup{
+		SideInfo:    &snap.SideInfo{RealName: snap.InstanceSnap(snapName)},
+		InstanceKey: snapst.InstanceKey,
+	}
+	aliasTask := st.NewTask("alias", fmt.Sprintf(i18n.G("Enable alias %q for %q"), alias, aliasTarget(snapName, appName)))
+	aliasTask.Set("snap-setup", snapsup)
+	aliasTask.Set("alias", alias)
+	aliasTask.Set("app", appName)
+
+	return state.NewTaskSet(aliasTask), nil
+}
+
+// Unalias disables the given alias, whichever snap it belongs to.
+// Note that the state must be locked by the caller.
+func Unalias(st *state.State, alias string) (*state.TaskSet, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	for snapName, snapst := range snapStates {
+		target := snapst.Aliases[alias]
+		if target == nil || target.Disabled {
+			continue
+		}
+		if err := CheckChangeConflict(st, snapName, nil); err != nil {
+			return nil, err
+		}
+
+		snapsup := &SnapSetup{
+			SideInfo:    &snap.SideInfo{RealName: snap.InstanceSnap(snapName)},
+			InstanceKey: snapst.InstanceKey,
+		}
+		unaliasTask := st.NewTask("unalias", fmt.Sprintf(i18n.G("Disable alias %q for %q"), alias, aliasTarget(snapName, target.App)))
+		unaliasTask.Set("snap-setup", snapsup)
+		unaliasTask.Set("alias", alias)
+
+		return state.NewTaskSet(unaliasTask), nil
+	}
+	return nil, fmt.Errorf("cannot find enabled alias %q", alias)
+}
+
+// updateAliases calls the backend with the state unlocked.
+func (m *SnapManager) updateAliases(st *state.State, add, remove []*backend.Alias) error {
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	st.Unlock()
+	defer st.Lock()
+	return m.backend.UpdateAliases(add, remove)
+}
+
+func (m *SnapManager) doSetupAliases(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	snapName := snapsup.InstanceName()
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+
+	aliases := make(map[string]*AliasTarget)
+	for alias, target := range snapst.Aliases {
+		if target.Auto && !target.Disabled {
+			// recomputed below
+			continue
+		}
+		if info.Apps[target.App] == nil {
+			t.Logf("Dropping alias %q, app %q is no longer in snap %q", alias, target.App, snapName)
+			continue
+		}
+		aliases[alias] = target
+	}
+
+	if AutoAliases != nil {
+		autoAliases, err := AutoAliases(st, info)
+		if err != nil {
+			return err
+		}
+		for _, alias := range autoAliases {
+			app := info.Aliases[alias]
+			if app == nil || aliases[alias] != nil {
+				continue
+			}
+			if err := checkAliasConflict(st, snapName, alias); err != nil {
+				t.Logf("Skipping automatic alias: %v", err)
+				continue
+			}
+			aliases[alias] = &AliasTarget{App: app.Name, Auto: true}
+		}
+	}
+	if len(aliases) == 0 {
+		aliases = nil
+	}
+
+	if err := m.updateAliases(st, backendAliases(snapName, aliases), nil); err != nil {
+		return err
+	}
+
+	t.Set("old-aliases", snapst.Aliases)
+	snapst.Aliases = aliases
+	Set(st, snapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) undoSetupAliases(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var oldAliases map[string]*AliasTarget
+	if err := t.Get("old-aliases", &oldAliases); err != nil {
+		return err
+	}
+
+	if err := m.updateAliases(st, nil, backendAliases(snapsup.InstanceName(), snapst.Aliases)); err != nil {
+		return err
+	}
+
+	snapst.Aliases = oldAliases
+	Set(st, snapsup.InstanceName(), snapst)
+	return nil
+}
+
+func (m *SnapManager) doRemoveAliases(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	return m.updateAliases(st, nil, backendAliases(snapsup.InstanceName(), snapst.Aliases))
+}
+
+func (m *SnapManager) undoRemoveAliases(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	return m.updateAliases(st, backendAliases(snapsup.InstanceName(), snapst.Aliases), nil)
+}
+
+// setAlias sets the target of the alias for the snap, or unsets it if
+// target is nil, updating the alias symlink if the snap is active.
+func (m *SnapManager) setAlias(st *state.State, snapName string, snapst *SnapState, alias string, target *AliasTarget) error {
+	var add, remove []*backend.Alias
+	if old := snapst.Aliases[alias]; old != nil && !old.Disabled {
+		remove = append(remove, &backend.Alias{Name: alias, Target: aliasTarget(snapName, old.App)})
+	}
+	if target != nil && !target.Disabled {
+		add = append(add, &backend.Alias{Name: alias, Target: aliasTarget(snapName, target.App)})
+	}
+
+	if target == nil {
+		delete(snapst.Aliases, alias)
+		if len(snapst.Aliases) == 0 {
+			snapst.Aliases = nil
+		}
+	} else {
+		if snapst.Aliases == nil {
+			snapst.Aliases = make(map[string]*AliasTarget)
+		}
+		snapst.Aliases[alias] = target
+	}
+
+	if snapst.Active {
+		if err := m.updateAliases(st, add, remove); err != nil {
+			return err
+		}
+	}
+
+	Set(st, snapName, snapst)
+	return nil
+}
+
+func (m *SnapManager) doAlias(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var alias, app string
+	if err := t.Get("alias", &alias); err != nil {
+		return err
+	}
+	if err := t.Get("app", &app); err != nil {
+		return err
+	}
+
+	// things might have changed since the task was created
+	if err := checkAliasConflict(st, snapsup.InstanceName(), alias); err != nil {
+		return err
+	}
+
+	t.Set("old-target", snapst.Aliases[alias])
+	return m.setAlias(st, snapsup.InstanceName(), snapst, alias, &AliasTarget{App: app})
+}
+
+func (m *SnapManager) doUnalias(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var alias string
+	if err := t.Get("alias", &alias); err != nil {
+		return err
+	}
+
+	old := snapst.Aliases[alias]
+	t.Set("old-target", old)
+	if old == nil {
+		return nil
+	}
+
+	var target *AliasTarget
+	if old.Auto {
+		// remember the choice, for refreshes
+		target = &AliasTarget{App: old.App, Auto: true, Disabled: true}
+	}
+	return m.setAlias(st, snapsup.InstanceName(), snapst, alias, target)
+}
+
+// undoAlias restores the alias target from before an alias or unalias task.
+func (m *SnapManager) undoAlias(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	var alias string
+	if err := t.Get("alias", &alias); err != nil {
+		return err
+	}
+	var oldTarget *AliasTarget
+	if err := t.Get("old-target", &oldTarget); err != nil {
+		return err
+	}
+
+	return m.setAlias(st, snapsup.InstanceName(), snapst, alias, oldTarget)
+}
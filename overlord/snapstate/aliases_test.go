@@ -0,0 +1,398 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) setAliasSnap(aliases map[string]*snapstate.AliasTarget) {
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		Active:   true,
+		SnapType: "app",
+		Aliases:  aliases,
+	})
+}
+
+func (s *snapmgrTestSuite) aliasSnapAliases(c *C) map[string]*snapstate.AliasTarget {
+	var snapst snapstate.SnapState
+	err := snapstate.Get(s.state, "alias-snap", &snapst)
+	c.Assert(err, IsNil)
+	return snapst.Aliases
+}
+
+func (s *snapmgrTestSuite) settleUnlocked() {
+	s.state.Unlock()
+	defer s.state.Lock()
+	s.settle()
+}
+
+func (s *snapmgrTestSuite) TestAliasTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAliasSnap(nil)
+
+	ts, err := snapstate.Alias(s.state, "alias-snap", "cmd1", "cmd")
+	c.Assert(err, IsNil)
+
+	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{"alias"})
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Enable alias "cmd" for "alias-snap.cmd1"`)
+}
+
+func (s *snapmgrTestSuite) TestAliasRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.setAliasSnap(nil)
+
+	chg := s.state.NewChange("alias", "enable an alias")
+	ts, err := snapstate.Alias(s.state, "alias-snap", "cmd1", "cmd")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settleUnlocked()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:      "update-aliases",
+			aliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+		},
+	})
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"cmd": {App: "cmd1"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestAliasUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.setAliasSnap(map[string]*snapstate.AliasTarget{
+		"cmd": {App: "cmd2"},
+	})
+
+	chg := s.state.NewChange("alias", "enable an alias")
+	ts, err := snapstate.Alias(s.state, "alias-snap", "cmd1", "cmd")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	chg.AddTask(terr)
+
+	s.settleUnlocked()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:        "update-aliases",
+			aliases:   []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+			rmAliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd2"}},
+		},
+		{
+			op:        "update-aliases",
+			aliases:   []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd2"}},
+			rmAliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+		},
+	})
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"cmd": {App: "cmd2"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestAliasErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAliasSnap(nil)
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "other-snap", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		Active:   true,
+		SnapType: "app",
+		Aliases: map[string]*snapstate.AliasTarget{
+			"taken":    {App: "other"},
+			"disabled": {App: "other", Auto: true, Disabled: true},
+		},
+	})
+
+	for _, t := range []struct {
+		snap, app, alias string
+		err              string
+	}{
+		{"no-snap", "cmd1", "cmd", `cannot find snap "no-snap"`},
+		{"alias-snap", "no-app", "cmd", `cannot find app "no-app" in "alias-snap"`},
+		{"alias-snap", "svc", "cmd", `cannot enable alias "cmd" for daemon "svc" of "alias-snap"`},
+		{"alias-snap", "cmd1", "-cmd", `invalid alias name: "-cmd"`},
+		{"alias-snap", "cmd1", "other-snap", `cannot enable alias "other-snap" for "alias-snap", it conflicts with the command namespace of installed snap "other-snap"`},
+		{"alias-snap", "cmd1", "other-snap.foo", `cannot enable alias "other-snap.foo" for "alias-snap", it conflicts with the command namespace of installed snap "other-snap"`},
+		{"alias-snap", "cmd1", "alias-snap.cmd2", `cannot enable alias "alias-snap.cmd2" for "alias-snap", it conflicts with the command namespace of installed snap "alias-snap"`},
+		{"alias-snap", "cmd1", "taken", `cannot enable alias "taken" for "alias-snap", already enabled for "other-snap"`},
+	} {
+		_, err := snapstate.Alias(s.state, t.snap, t.app, t.alias)
+		c.Check(err, ErrorMatches, t.err)
+	}
+
+	_, err := snapstate.Alias(s.state, "alias-snap", "cmd1", "disabled")
+	c.Check(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestAliasConflict(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAliasSnap(nil)
+
+	ts, err := snapstate.Alias(s.state, "alias-snap", "cmd1", "cmd")
+	c.Assert(err, IsNil)
+	// need a change to make the tasks visible
+	s.state.NewChange("alias", "...").AddAll(ts)
+
+	_, err = snapstate.Alias(s.state, "alias-snap", "cmd2", "other")
+	c.Check(err, ErrorMatches, `snap "alias-snap" has changes in progress`)
+}
+
+func (s *snapmgrTestSuite) TestUnaliasRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.setAliasSnap(map[string]*snapstate.AliasTarget{
+		"cmd":    {App: "cmd1"},
+		"alias2": {App: "cmd2", Auto: true},
+	})
+
+	chg := s.state.NewChange("unalias", "disable aliases")
+	ts1, err := snapstate.Unalias(s.state, "cmd")
+	c.Assert(err, IsNil)
+	c.Check(ts1.Tasks()[0].Summary(), Equals, `Disable alias "cmd" for "alias-snap.cmd1"`)
+	chg.AddAll(ts1)
+
+	s.settleUnlocked()
+	c.Assert(chg.Err(), IsNil)
+
+	chg = s.state.NewChange("unalias", "disable aliases")
+	ts2, err := snapstate.Unalias(s.state, "alias2")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts2)
+
+	s.settleUnlocked()
+	c.Assert(chg.Err(), IsNil)
+
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:        "update-aliases",
+			rmAliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+		},
+		{
+			op:        "update-aliases",
+			rmAliases: []*backend.Alias{{Name: "alias2", Target: "alias-snap.cmd2"}},
+		},
+	})
+	// automatic aliases are remembered as disabled
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias2": {App: "cmd2", Auto: true, Disabled: true},
+	})
+
+	_, err = snapstate.Unalias(s.state, "alias2")
+	c.Check(err, ErrorMatches, `cannot find enabled alias "alias2"`)
+}
+
+func (s *snapmgrTestSuite) TestUnaliasUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.setAliasSnap(map[string]*snapstate.AliasTarget{
+		"cmd": {App: "cmd1"},
+	})
+
+	chg := s.state.NewChange("unalias", "disable an alias")
+	ts, err := snapstate.Unalias(s.state, "cmd")
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	chg.AddTask(terr)
+
+	s.settleUnlocked()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{
+			op:        "update-aliases",
+			rmAliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+		},
+		{
+			op:      "update-aliases",
+			aliases: []*backend.Alias{{Name: "cmd", Target: "alias-snap.cmd1"}},
+		},
+	})
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"cmd": {App: "cmd1"},
+	})
+}
+
+func (s *snapmgrTestSuite) TestInstallSetsUpAutoAliases(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	snapstate.AutoAliases = func(st *state.State, info *snap.Info) ([]string, error) {
+		c.Check(info.Name(), Equals, "alias-snap")
+		// alias3 is not declared in snap.yaml
+		return []string{"alias1", "alias2", "alias3"}, nil
+	}
+	// alias2 is taken already
+	snapstate.Set(s.state, "other-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "other-snap", Revision: snap.R(2)},
+		},
+		Current:  snap.R(2),
+		Active:   true,
+		SnapType: "app",
+		Aliases: map[string]*snapstate.AliasTarget{
+			"alias2": {App: "other"},
+		},
+	})
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "alias-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settleUnlocked()
+	c.Assert(chg.Err(), IsNil)
+
+	var aliasOps fakeOps
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "update-aliases" {
+			aliasOps = append(aliasOps, op)
+		}
+	}
+	c.Check(aliasOps, DeepEquals, fakeOps{
+		{
+			op:      "update-aliases",
+			aliases: []*backend.Alias{{Name: "alias1", Target: "alias-snap.cmd1"}},
+		},
+	})
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"alias1": {App: "cmd1", Auto: true},
+	})
+}
+
+func (s *snapmgrTestSuite) TestSetupAliasesKeepsManualAndDisabled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	snapstate.AutoAliases = func(st *state.State, info *snap.Info) ([]string, error) {
+		return []string{"alias1", "alias2"}, nil
+	}
+	snapstate.Set(s.state, "alias-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "alias-snap", Revision: snap.R(11)},
+		},
+		Current:  snap.R(11),
+		Active:   false,
+		SnapType: "app",
+		Aliases: map[string]*snapstate.AliasTarget{
+			"cmd":    {App: "cmd1"},
+			"gone":   {App: "gone-app"},
+			"alias1": {App: "cmd1", Auto: true, Disabled: true},
+		},
+	})
+
+	chg := s.state.NewChange("enable", "enable a snap")
+	ts, err := snapstate.Enable(s.state, "alias-snap", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settleUnlocked()
+	c.Assert(chg.Err(), IsNil)
+
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"candidate", "link-snap", "update-aliases", "start-snap-services"})
+	c.Check(s.fakeBackend.ops[2], DeepEquals, fakeOp{
+		op: "update-aliases",
+		aliases: []*backend.Alias{
+			{Name: "alias2", Target: "alias-snap.cmd2"},
+			{Name: "cmd", Target: "alias-snap.cmd1"},
+		},
+	})
+	c.Check(s.aliasSnapAliases(c), DeepEquals, map[string]*snapstate.AliasTarget{
+		"cmd":    {App: "cmd1"},
+		"alias1": {App: "cmd1", Auto: true, Disabled: true},
+		"alias2": {App: "cmd2", Auto: true},
+	})
+}
+
+func (s *snapmgrTestSuite) TestDisableRemovesAliases(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	s.setAliasSnap(map[string]*snapstate.AliasTarget{
+		"cmd":    {App: "cmd1"},
+		"alias1": {App: "cmd1", Auto: true, Disabled: true},
+	})
+
+	chg := s.state.NewChange("disable", "disable a snap")
+	ts, err := snapstate.Disable(s.state, "alias-snap", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.settleUnlocked()
+	c.Assert(chg.Err(), IsNil)
+
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{"stop-snap-services", "update-aliases", "unlink-snap"})
+	c.Check(s.fakeBackend.ops[1].rmAliases, DeepEquals, []*backend.Alias{
+		{Name: "cmd", Target: "alias-snap.cmd1"},
+	})
+	// aliases are kept for when the snap is enabled again
+	c.Check(s.aliasSnapAliases(c), HasLen, 2)
+}
+
+func (s *snapmgrTestSuite) TestInstallConflictsWithAlias(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAliasSnap(map[string]*snapstate.AliasTarget{
+		"some-snap.cmd": {App: "cmd1"},
+	})
+
+	_, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `snap "some-snap" command namespace conflicts with alias "some-snap.cmd" for "alias-snap" snap`)
+}
@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// DefaultRefreshSchedule is used when no valid refresh.schedule is set
+// on the core snap: four windows a day, so that devices spread their
+// store requests.
+const DefaultRefreshSchedule = "00:00-04:59,05:00-10:59,11:00-16:59,17:00-23:59"
+
+// Config is the view on the snap configuration snapstate needs.
+type Config interface {
+	// GetMaybe unmarshals into result the value of the given snap's
+	// configuration key, leaving result untouched if it's not set.
+	GetMaybe(snapName, key string, result interface{}) error
+}
+
+// NewConfig returns a view on the current snap configuration; it's set
+// by configstate to avoid an import cycle.
+// The state must be locked by the caller.
+var NewConfig = func(st *state.State) Config {
+	panic("internal error: snapstate.NewConfig is unset")
+}
+
+// CanAutoRefresh allows to hook in a check for whether the system is
+// ready for auto-refreshes, e.g. whether it is seeded. If unset, no
+// auto-refreshes happen.
+var CanAutoRefresh func(st *state.State) (bool, error)
+
+// RefreshSnapDeclarations allows to hook re-fetching the snap
+// declarations of the installed snaps, and their prerequisites, before
+// auto-refreshes.
+var RefreshSnapDeclarations func(st *state.State, userID int) error
+
+// autoRefresh will ensure that snaps are refreshed automatically
+// according to the refresh schedule.
+type autoRefresh struct {
+	state *state.State
+
+	lastRefreshSchedule string
+	nextRefresh         time.Time
+}
+
+func newAutoRefresh(st *state.State) *autoRefresh {
+	return &autoRefresh{state: st}
+}
+
+// refreshScheduleWithDefaultsFallback returns the current refresh
+// schedule, falling back to the default if the configured one is unset
+// or invalid.
+func refreshScheduleWithDefaultsFallback(st *state.State) (*timeutil.Schedule, string, error) {
+	var scheduleStr string
+	if err := NewConfig(st).GetMaybe("core", "refresh.schedule", &scheduleStr); err != nil {
+		return nil, "", err
+	}
+	if scheduleStr != "" {
+		sched, err := timeutil.ParseSchedule(scheduleStr)
+		if err == nil {
+			return sched, scheduleStr, nil
+		}
+		logger.Noticef("cannot use refresh.schedule configuration: %v", err)
+	}
+
+	sched, err := timeutil.ParseSchedule(DefaultRefreshSchedule)
+	if err != nil {
+		panic(fmt.Sprintf("internal error: cannot parse default refresh schedule: %v", err))
+	}
+	return sched, DefaultRefreshSchedule, nil
+}
+
+// LastRefresh returns the time of the last auto-refresh attempt, or the
+// zero time if none happened yet.
+// Note that the state must be locked by the caller.
+func LastRefresh(st *state.State) (time.Time, error) {
+	var lastRefresh time.Time
+	err := st.Get("last-refresh", &lastRefresh)
+	if err != nil && err != state.ErrNoState {
+		return time.Time{}, err
+	}
+	return lastRefresh, nil
+}
+
+// NextRefresh returns the time the next auto-refresh is planned for,
+// or the zero time if none is planned yet.
+// Note that the state must be locked by the caller.
+func NextRefresh(st *state.State) (time.Time, error) {
+	var nextRefresh time.Time
+	err := st.Get("next-refresh", &nextRefresh)
+	if err != nil && err != state.ErrNoState {
+		return time.Time{}, err
+	}
+	return nextRefresh, nil
+}
+
+// RefreshSchedule returns the refresh schedule currently in effect.
+// Note that the state must be locked by the caller.
+func RefreshSchedule(st *state.State) (string, error) {
+	_, scheduleStr, err := refreshScheduleWithDefaultsFallback(st)
+	return scheduleStr, err
+}
+
+func autoRefreshInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == "auto-refresh" && !chg.Status().Ready() {
+			return true
+		}
+	}
+	return false
+}
+
+// Ensure ensures that we refresh all installed snaps periodically.
+func (m *autoRefresh) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	// see if it even makes sense to try to refresh
+	if CanAutoRefresh == nil {
+		return nil
+	}
+	if ok, err := CanAutoRefresh(m.state); err != nil || !ok {
+		return err
+	}
+
+	sched, scheduleStr, err := refreshScheduleWithDefaultsFallback(m.state)
+	if err != nil {
+		return err
+	}
+
+	// a new schedule invalidates the planned refresh
+	if scheduleStr != m.lastRefreshSchedule {
+		m.lastRefreshSchedule = scheduleStr
+		m.nextRefresh = time.Time{}
+	}
+
+	// ensure nothing is in flight already
+	if autoRefreshInFlight(m.state) {
+		return nil
+	}
+
+	if m.nextRefresh.IsZero() {
+		lastRefresh, err := LastRefresh(m.state)
+		if err != nil {
+			return err
+		}
+		m.nextRefresh = time.Now().Add(sched.Next(lastRefresh))
+		m.state.Set("next-refresh", m.nextRefresh)
+		logger.Debugf("Next refresh scheduled for %s.", m.nextRefresh)
+	}
+
+	if m.nextRefresh.After(time.Now()) {
+		return nil
+	}
+
+	// record the attempt even if it fails, so that a failing store
+	// isn't hammered until the next window
+	m.state.Set("last-refresh", time.Now())
+	m.nextRefresh = time.Time{}
+
+	return m.launchAutoRefresh()
+}
+
+// launchAutoRefresh creates the auto-refresh change.
+// Note that the state must be locked by the caller.
+func (m *autoRefresh) launchAutoRefresh() error {
+	if RefreshSnapDeclarations != nil {
+		// proceed even if it fails, what is known is still in force
+		if err := RefreshSnapDeclarations(m.state, 0); err != nil {
+			logger.Noticef("Cannot refresh snap declarations: %v", err)
+		}
+	}
+
+	updated, tasksets, err := UpdateMany(m.state, nil, 0, Flags{})
+	if err != nil {
+		logger.Noticef("Cannot prepare auto-refresh change: %s", err)
+		return err
+	}
+
+	var msg string
+	switch len(updated) {
+	case 0:
+		logger.Noticef("auto-refresh: all snaps are up-to-date")
+		return nil
+	case 1:
+		msg = fmt.Sprintf(i18n.G("Auto-refresh snap %q"), updated[0])
+	case 2:
+		msg = fmt.Sprintf(i18n.G("Auto-refresh snaps %q and %q"), updated[0], updated[1])
+	default:
+		msg = fmt.Sprintf(i18n.G("Auto-refresh %d snaps"), len(updated))
+	}
+
+	chg := m.state.NewChange("auto-refresh", msg)
+	for _, ts := range tasksets {
+		chg.AddAll(ts)
+	}
+	chg.Set("snap-names", updated)
+
+	return nil
+}
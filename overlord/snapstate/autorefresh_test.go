@@ -0,0 +1,237 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapmgrTestSuite) mockCanAutoRefresh(c *C, ok bool) {
+	snapstate.CanAutoRefresh = func(st *state.State) (bool, error) {
+		return ok, nil
+	}
+}
+
+func (s *snapmgrTestSuite) setRefreshSchedule(c *C, schedule string) {
+	tr := configstate.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.schedule", schedule), IsNil)
+	tr.Commit()
+}
+
+func (s *snapmgrTestSuite) setupSomeSnap() {
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshNotReady(c *C) {
+	s.mockCanAutoRefresh(c, false)
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	s.state.Unlock()
+
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Hour))
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	var t time.Time
+	c.Check(s.state.Get("last-refresh", &t), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshPlansNextRefresh(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	// only tomorrow, so the refresh cannot be due yet
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	schedule := tomorrow.Weekday().String()[:3]
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	s.setRefreshSchedule(c, schedule)
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+
+	next, err := snapstate.NextRefresh(s.state)
+	c.Assert(err, IsNil)
+	y, m, d := tomorrow.Date()
+	c.Check(next.After(time.Date(y, m, d, 0, 0, 0, 0, time.Local)), Equals, true)
+
+	sched, err := snapstate.RefreshSchedule(s.state)
+	c.Assert(err, IsNil)
+	c.Check(sched, Equals, schedule)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshCreatesChange(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	s.state.Unlock()
+
+	snapstate.MockLastRefreshSchedule(s.snapmgr, snapstate.DefaultRefreshSchedule)
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Minute))
+	before := time.Now()
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	last, err := snapstate.LastRefresh(s.state)
+	c.Assert(err, IsNil)
+	c.Check(last.Before(before), Equals, false)
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), Equals, "auto-refresh")
+	c.Check(chg.Summary(), Equals, `Auto-refresh snap "some-snap"`)
+	var names []string
+	c.Assert(chg.Get("snap-names", &names), IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshRefreshesSnapDeclarations(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	s.state.Unlock()
+
+	refreshed := 0
+	snapstate.RefreshSnapDeclarations = func(st *state.State, userID int) error {
+		refreshed++
+		c.Check(userID, Equals, 0)
+		c.Check(st.Changes(), HasLen, 0)
+		return errors.New("store unreachable")
+	}
+
+	snapstate.MockLastRefreshSchedule(s.snapmgr, snapstate.DefaultRefreshSchedule)
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Minute))
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(refreshed, Equals, 1)
+	// the failure does not stop the auto-refresh
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	c.Check(chgs[0].Kind(), Equals, "auto-refresh")
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshNothingToRefresh(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	snapstate.MockLastRefreshSchedule(s.snapmgr, snapstate.DefaultRefreshSchedule)
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Minute))
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	last, err := snapstate.LastRefresh(s.state)
+	c.Assert(err, IsNil)
+	c.Check(last.IsZero(), Equals, false)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshInFlight(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	chg := s.state.NewChange("auto-refresh", "...")
+	chg.AddTask(s.state.NewTask("nop", "..."))
+	s.state.Unlock()
+
+	snapstate.MockLastRefreshSchedule(s.snapmgr, snapstate.DefaultRefreshSchedule)
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Minute))
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	var t time.Time
+	c.Check(s.state.Get("last-refresh", &t), Equals, state.ErrNoState)
+}
+
+func (s *snapmgrTestSuite) TestAutoRefreshScheduleChangeReplans(c *C) {
+	s.mockCanAutoRefresh(c, true)
+
+	s.state.Lock()
+	s.setupSomeSnap()
+	s.state.Unlock()
+
+	// a due refresh planned under the old schedule is dropped
+	snapstate.MockLastRefreshSchedule(s.snapmgr, "00:00-23:59")
+	snapstate.MockNextRefresh(s.snapmgr, time.Now().Add(-time.Minute))
+
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	s.state.Lock()
+	s.setRefreshSchedule(c, tomorrow.Weekday().String()[:3])
+	s.state.Unlock()
+
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	next, err := snapstate.NextRefresh(s.state)
+	c.Assert(err, IsNil)
+	c.Check(next.After(time.Now()), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestRefreshScheduleInvalidFallsBackToDefault(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// bypass the configure hook validation
+	s.setRefreshSchedule(c, "invalid")
+
+	sched, err := snapstate.RefreshSchedule(s.state)
+	c.Assert(err, IsNil)
+	c.Check(sched, Equals, snapstate.DefaultRefreshSchedule)
+}
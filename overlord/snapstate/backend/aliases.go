@@ -0,0 +1,47 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"github.com/snapcore/snapd/wrappers"
+)
+
+// Alias represents a command alias of a snap app: its name and the
+// name of the app wrapper it points to.
+type Alias struct {
+	Name   string
+	Target string
+}
+
+func wrappersAliases(aliases []*Alias) []*wrappers.Alias {
+	res := make([]*wrappers.Alias, len(aliases))
+	for i, alias := range aliases {
+		res[i] = &wrappers.Alias{Name: alias.Name, Target: alias.Target}
+	}
+	return res
+}
+
+// UpdateAliases removes and then adds the given aliases.
+func (b Backend) UpdateAliases(add []*Alias, remove []*Alias) error {
+	if err := wrappers.RemoveSnapAliases(wrappersAliases(remove)); err != nil {
+		return err
+	}
+	return wrappers.AddSnapAliases(wrappersAliases(add))
+}
@@ -0,0 +1,69 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+)
+
+type aliasesSuite struct {
+	be backend.Backend
+}
+
+var _ = Suite(&aliasesSuite{})
+
+func (s *aliasesSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *aliasesSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *aliasesSuite) TestUpdateAliases(c *C) {
+	err := s.be.UpdateAliases([]*backend.Alias{
+		{Name: "bar", Target: "foo.bar"},
+		{Name: "baz", Target: "foo.baz"},
+	}, nil)
+	c.Assert(err, IsNil)
+
+	err = s.be.UpdateAliases([]*backend.Alias{
+		{Name: "qux", Target: "foo"},
+	}, []*backend.Alias{
+		{Name: "baz", Target: "foo.baz"},
+	})
+	c.Assert(err, IsNil)
+
+	target, err := os.Readlink(filepath.Join(dirs.SnapBinariesDir, "bar"))
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "foo.bar")
+	target, err = os.Readlink(filepath.Join(dirs.SnapBinariesDir, "qux"))
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "foo")
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, "baz")), Equals, false)
+}
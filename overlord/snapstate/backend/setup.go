@@ -29,14 +29,15 @@ import (
 	"github.com/snapcore/snapd/snap"
 )
 
-// SetupSnap does prepare and mount the snap for further processing.
-func (b Backend) SetupSnap(snapFilePath string, sideInfo *snap.SideInfo, meter progress.Meter) error {
+// SetupSnap does prepare and mount the snap instance with the given name for further processing.
+func (b Backend) SetupSnap(snapFilePath, instanceName string, sideInfo *snap.SideInfo, meter progress.Meter) error {
 	// This assumes that the snap was already verified or --dangerous was used.
 
 	s, snapf, err := OpenSnapFile(snapFilePath, sideInfo)
 	if err != nil {
 		return err
 	}
+	_, s.InstanceKey = snap.SplitInstanceName(instanceName)
 	instdir := s.MountDir()
 
 	if err := os.MkdirAll(instdir, 0755); err != nil {
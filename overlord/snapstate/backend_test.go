@@ -27,6 +27,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/snap"
@@ -42,6 +43,9 @@ type fakeOp struct {
 	stype snap.Type
 	cand  store.RefreshCandidate
 
+	aliases   []*backend.Alias
+	rmAliases []*backend.Alias
+
 	old string
 }
 
@@ -87,6 +91,11 @@ type fakeStore struct {
 	fakeCurrentProgress int
 	fakeTotalProgress   int
 	state               *state.State
+	// downloadErrors are returned by the next downloads
+	downloadErrors []error
+	// snapHook is called, without the state lock held, when the
+	// snap with the given name is looked up
+	snapHook func(name string)
 }
 
 func (f *fakeStore) pokeStateLock() {
@@ -96,9 +105,23 @@ func (f *fakeStore) pokeStateLock() {
 	f.state.Unlock()
 }
 
+// fakeDefaultProviders maps the snaps with a content plug to the
+// default provider of the plug.
+var fakeDefaultProviders = map[string]string{
+	"snap-content-plug":          "snap-content-slot:shared-content-slot",
+	"snap-content-plug-missing":  "no-such-provider",
+	"snap-content-cycle-a":       "snap-content-cycle-b",
+	"snap-content-cycle-b":       "snap-content-cycle-a",
+	"snap-with-base-and-content": "snap-content-slot",
+}
+
 func (f *fakeStore) Snap(name, channel string, devmode bool, revision snap.Revision, user *auth.UserState) (*snap.Info, error) {
 	f.pokeStateLock()
 
+	if f.snapHook != nil {
+		f.snapHook(name)
+	}
+
 	if revision.Unset() {
 		revision = snap.R(11)
 		if channel == "channel-for-7" {
@@ -106,6 +129,10 @@ func (f *fakeStore) Snap(name, channel string, devmode bool, revision snap.Revis
 		}
 	}
 
+	if name == "no-such-provider" {
+		return nil, store.ErrSnapNotFound
+	}
+
 	info := &snap.Info{
 		SideInfo: snap.SideInfo{
 			RealName: strings.Split(name, ".")[0],
@@ -118,6 +145,19 @@ func (f *fakeStore) Snap(name, channel string, devmode bool, revision snap.Revis
 			DownloadURL: "https://some-server.com/some/path.snap",
 		},
 	}
+	if name == "some-snap-with-base" || name == "snap-with-base-and-content" {
+		info.Base = "some-base"
+	}
+	if provider := fakeDefaultProviders[name]; provider != "" {
+		info.Plugs = map[string]*snap.PlugInfo{
+			"shared-content-plug": {
+				Snap:      info,
+				Name:      "shared-content-plug",
+				Interface: "content",
+				Attrs:     map[string]interface{}{"default-provider": provider},
+			},
+		}
+	}
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-snap", name: name, revno: revision})
 
 	return info, nil
@@ -130,14 +170,21 @@ func (f *fakeStore) Find(search *store.Search, user *auth.UserState) ([]*snap.In
 func (f *fakeStore) ListRefresh(cands []*store.RefreshCandidate, _ *auth.UserState) ([]*snap.Info, error) {
 	f.pokeStateLock()
 
-	if len(cands) == 0 {
-		return nil, nil
-	}
-	if len(cands) != 1 {
-		panic("ListRefresh unexpectedly called with more than one candidate")
+	var res []*snap.Info
+	for _, cand := range cands {
+		info, err := f.lookupRefresh(cand)
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			res = append(res, info)
+		}
 	}
-	cand := cands[0]
 
+	return res, nil
+}
+
+func (f *fakeStore) lookupRefresh(cand *store.RefreshCandidate) (*snap.Info, error) {
 	snapID := cand.SnapID
 
 	if snapID == "" {
@@ -167,7 +214,8 @@ func (f *fakeStore) ListRefresh(cands []*store.RefreshCandidate, _ *auth.UserSta
 			SnapID:   cand.SnapID,
 			Revision: revno,
 		},
-		Version: name,
+		InstanceKey: cand.InstanceKey,
+		Version:     name,
 		DownloadInfo: snap.DownloadInfo{
 			DownloadURL: "https://some-server.com/some/path.snap",
 		},
@@ -190,7 +238,7 @@ func (f *fakeStore) ListRefresh(cands []*store.RefreshCandidate, _ *auth.UserSta
 		return nil, nil
 	}
 
-	return []*snap.Info{info}, nil
+	return info, nil
 }
 
 func (f *fakeStore) SuggestedCurrency() string {
@@ -212,6 +260,12 @@ func (f *fakeStore) Download(name, targetFn string, snapInfo *snap.DownloadInfo,
 	})
 	f.fakeBackend.ops = append(f.fakeBackend.ops, fakeOp{op: "storesvc-download", name: name})
 
+	if len(f.downloadErrors) > 0 {
+		err := f.downloadErrors[0]
+		f.downloadErrors = f.downloadErrors[1:]
+		return err
+	}
+
 	pb.SetTotal(float64(f.fakeTotalProgress))
 	pb.Set(float64(f.fakeCurrentProgress))
 
@@ -230,11 +284,16 @@ func (f *fakeStore) Assertion(*asserts.AssertionType, []string, *auth.UserState)
 	panic("Never expected fakeStore.Assertion to be called")
 }
 
+func (f *fakeStore) SeqFormingAssertion(*asserts.AssertionType, []string, int, *auth.UserState) (asserts.Assertion, error) {
+	panic("Never expected fakeStore.SeqFormingAssertion to be called")
+}
+
 type fakeSnappyBackend struct {
 	ops fakeOps
 
 	linkSnapFailTrigger     string
 	copySnapDataFailTrigger string
+	runHookFailTrigger      string
 }
 
 func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
@@ -251,7 +310,7 @@ func (f *fakeSnappyBackend) OpenSnapFile(snapFilePath string, si *snap.SideInfo)
 	return &snap.Info{Architectures: []string{"all"}}, nil, nil
 }
 
-func (f *fakeSnappyBackend) SetupSnap(snapFilePath string, si *snap.SideInfo, p progress.Meter) error {
+func (f *fakeSnappyBackend) SetupSnap(snapFilePath, instanceName string, si *snap.SideInfo, p progress.Meter) error {
 	p.Notify("setup-snap")
 	revno := snap.R(0)
 	if si != nil {
@@ -265,12 +324,20 @@ func (f *fakeSnappyBackend) SetupSnap(snapFilePath string, si *snap.SideInfo, p
 	return nil
 }
 
+func (f *fakeSnappyBackend) RunHook(snapName, hook string) error {
+	if snapName+":"+hook == f.runHookFailTrigger {
+		return fmt.Errorf("%s hook of snap %q failed", hook, snapName)
+	}
+	return nil
+}
+
 func (f *fakeSnappyBackend) ReadInfo(name string, si *snap.SideInfo) (*snap.Info, error) {
 	if name == "borken" {
 		return nil, errors.New(`cannot read info for "borken" snap`)
 	}
 	// naive emulation for now, always works
 	info := &snap.Info{SuggestedName: name, SideInfo: *si}
+	_, info.InstanceKey = snap.SplitInstanceName(name)
 	info.Type = snap.TypeApp
 	if name == "gadget" {
 		info.Type = snap.TypeGadget
@@ -278,6 +345,23 @@ func (f *fakeSnappyBackend) ReadInfo(name string, si *snap.SideInfo) (*snap.Info
 	if name == "core" {
 		info.Type = snap.TypeOS
 	}
+	if name == "some-base" {
+		info.Type = snap.TypeBase
+	}
+	if name == "some-snap-with-base" {
+		info.Base = "some-base"
+	}
+	if name == "alias-snap" {
+		info.Apps = map[string]*snap.AppInfo{
+			"cmd1": {Snap: info, Name: "cmd1"},
+			"cmd2": {Snap: info, Name: "cmd2"},
+			"svc":  {Snap: info, Name: "svc", Daemon: "simple"},
+		}
+		info.Aliases = map[string]*snap.AppInfo{
+			"alias1": info.Apps["cmd1"],
+			"alias2": info.Apps["cmd2"],
+		}
+	}
 	return info, nil
 }
 
@@ -351,6 +435,15 @@ func (f *fakeSnappyBackend) StopSnapServices(info *snap.Info, meter progress.Met
 	return nil
 }
 
+func (f *fakeSnappyBackend) UpdateAliases(add []*backend.Alias, remove []*backend.Alias) error {
+	f.ops = append(f.ops, fakeOp{
+		op:        "update-aliases",
+		aliases:   add,
+		rmAliases: remove,
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, p progress.Meter) error {
 	p.Notify("setup-snap")
 	f.ops = append(f.ops, fakeOp{
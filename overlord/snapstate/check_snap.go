@@ -229,7 +229,23 @@ func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags Fl
 	return nil
 }
 
+func checkBase(st *state.State, snapInfo, curInfo *snap.Info, flags Flags) error {
+	if snapInfo.Base == "" {
+		return nil
+	}
+	var snapst SnapState
+	err := Get(st, snapInfo.Base, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !snapst.HasCurrent() {
+		return fmt.Errorf("cannot find required base %q", snapInfo.Base)
+	}
+	return nil
+}
+
 func init() {
 	AddCheckSnapCallback(checkCoreName)
 	AddCheckSnapCallback(checkGadgetOrKernel)
+	AddCheckSnapCallback(checkBase)
 }
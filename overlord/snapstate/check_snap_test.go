@@ -592,3 +592,33 @@ version: 2
 	st.Lock()
 	c.Check(err, ErrorMatches, "cannot replace kernel snap with a different one")
 }
+
+func (s *checkSnapSuite) TestCheckSnapBase(c *C) {
+	const yaml = `name: requires-base
+version: 1
+base: some-base
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+
+	var openSnapFile = func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		return info, nil, nil
+	}
+	restore := snapstate.MockOpenSnapFile(openSnapFile)
+	defer restore()
+
+	err = snapstate.CheckSnap(s.st, "snap-path", nil, nil, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot find required base "some-base"`)
+
+	s.st.Lock()
+	snapstate.Set(s.st, "some-base", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-base", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "base",
+	})
+	s.st.Unlock()
+
+	err = snapstate.CheckSnap(s.st, "snap-path", nil, nil, snapstate.Flags{})
+	c.Check(err, IsNil)
+}
@@ -20,11 +20,18 @@
 package snapstate_test
 
 import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
 )
 
 type downloadSnapSuite struct {
@@ -196,3 +203,93 @@ func (s *downloadSnapSuite) TestDoUndoDownloadSnap(c *C) {
 	c.Assert(err, Equals, state.ErrNoState)
 
 }
+
+func (s *downloadSnapSuite) TestDoDownloadSnapRetriesTransientErrors(c *C) {
+	now := time.Now()
+	restore := state.MockTime(now)
+	defer restore()
+
+	s.state.Lock()
+	s.fakeStore.downloadErrors = []error{&store.ErrDownload{Code: 503, URL: &url.URL{Path: "/snap"}}}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(t.Retries(), Equals, 1)
+	c.Check(t.LastError(), Matches, `received an unexpected http response code \(503\) .*`)
+	c.Check(t.AtTime().Equal(now.Add(10*time.Second)), Equals, true)
+	s.state.Unlock()
+
+	state.MockTime(now.Add(10 * time.Second))
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.fakeBackend.ops, DeepEquals, fakeOps{
+		{op: "storesvc-download", name: "foo"},
+		{op: "storesvc-download", name: "foo"},
+	})
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+}
+
+func (s *downloadSnapSuite) TestDoDownloadSnapPermanentError(c *C) {
+	s.state.Lock()
+	s.fakeStore.downloadErrors = []error{&store.ErrDownload{Code: 404, URL: &url.URL{Path: "/snap"}}}
+	t := s.state.NewTask("download-snap", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(11),
+		},
+		DownloadInfo: &snap.DownloadInfo{
+			DownloadURL: "http://some-url.com/snap",
+		},
+	})
+	s.state.NewChange("dummy", "...").AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Retries(), Equals, 0)
+}
+
+func (s *downloadSnapSuite) TestIsTransientError(c *C) {
+	for _, t := range []struct {
+		err       error
+		transient bool
+	}{
+		{&store.ErrDownload{Code: 500}, true},
+		{&store.ErrDownload{Code: 503}, true},
+		{&store.ErrDownload{Code: 429}, true},
+		{&store.ErrDownload{Code: 404}, false},
+		{&store.ErrDownload{Code: 403}, false},
+		{&url.Error{Op: "Get", URL: "http://foo", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, true},
+		{&url.Error{Op: "Get", URL: "http://foo", Err: &net.DNSError{Err: "no such host", Name: "foo"}}, true},
+		{&url.Error{Op: "Get", URL: "http://foo", Err: errors.New("stopped after 10 redirects")}, false},
+		{io.ErrUnexpectedEOF, true},
+		{errors.New("sha3-384 mismatch"), false},
+	} {
+		c.Check(snapstate.IsTransientError(t.err), Equals, t.transient, Commentf("%v", t.err))
+	}
+}
@@ -21,6 +21,7 @@ package snapstate
 
 import (
 	"errors"
+	"time"
 
 	"gopkg.in/tomb.v2"
 
@@ -30,6 +31,8 @@ import (
 
 type ManagerBackend managerBackend
 
+var IsTransientError = isTransientError
+
 func SetSnapManagerBackend(s *SnapManager, b ManagerBackend) {
 	s.backend = b
 }
@@ -67,8 +70,31 @@ func (m *SnapManager) AddForeignTaskHandlers(tracker ForeignTaskTracker) {
 	m.runner.AddHandler("error-trigger", erroringHandler, nil)
 
 	m.runner.AddHandler("run-hook", func(task *state.Task, _ *tomb.Tomb) error {
+		var hooksup struct {
+			Snap string `json:"snap"`
+			Hook string `json:"hook"`
+		}
+		task.State().Lock()
+		err := task.Get("hook-setup", &hooksup)
+		task.State().Unlock()
+		if err != nil {
+			return err
+		}
+		if hookRunner, ok := tracker.(interface {
+			RunHook(snapName, hook string) error
+		}); ok {
+			return hookRunner.RunHook(hooksup.Snap, hooksup.Hook)
+		}
 		return nil
-	}, nil)
+	}, func(task *state.Task, _ *tomb.Tomb) error {
+		return nil
+	})
+}
+
+func MockPrerequisitesRetryTimeout(d time.Duration) (restore func()) {
+	old := prerequisitesRetryTimeout
+	prerequisitesRetryTimeout = d
+	return func() { prerequisitesRetryTimeout = old }
 }
 
 func MockReadInfo(mock func(name string, si *snap.SideInfo) (*snap.Info, error)) (restore func()) {
@@ -94,3 +120,11 @@ var (
 func PreviousSideInfo(snapst *SnapState) *snap.SideInfo {
 	return snapst.previousSideInfo()
 }
+
+func MockNextRefresh(m *SnapManager, when time.Time) {
+	m.autoRefresh.nextRefresh = when
+}
+
+func MockLastRefreshSchedule(m *SnapManager, schedule string) {
+	m.autoRefresh.lastRefreshSchedule = schedule
+}
@@ -38,6 +38,10 @@ type Flags struct {
 	// IgnoreValidation is set when the user requested as one-off
 	// to ignore refresh control validation.
 	IgnoreValidation bool `json:"ignore-validation,omitempty"`
+
+	// IgnoreConflictsWith is the ID of a change the operation will
+	// wait for, whose tasks therefore don't conflict with it.
+	IgnoreConflictsWith string `json:"-"`
 }
 
 // DevModeAllowed returns whether a snap can be installed with devmode confinement (either set or overridden)
@@ -48,5 +52,6 @@ func (f Flags) DevModeAllowed() bool {
 // ForSnapSetup returns a copy of the Flags with the flags that we don't need in SnapSetup set to false (so they're not serialized)
 func (f Flags) ForSnapSetup() Flags {
 	f.IgnoreValidation = false
+	f.IgnoreConflictsWith = ""
 	return f
 }
@@ -23,6 +23,10 @@ package snapstate
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"time"
 
@@ -44,6 +48,8 @@ type SnapManager struct {
 	backend managerBackend
 
 	runner *state.TaskRunner
+
+	autoRefresh *autoRefresh
 }
 
 // SnapSetup holds the necessary snap details to perform most snap manager tasks.
@@ -58,6 +64,16 @@ type SnapSetup struct {
 
 	DownloadInfo *snap.DownloadInfo `json:"download-info,omitempty"`
 	SideInfo     *snap.SideInfo     `json:"side-info,omitempty"`
+
+	// InstanceKey is set for parallel installations of the snap.
+	InstanceKey string `json:"instance-key,omitempty"`
+
+	// Base is the base snap the snap runs on, it is installed as a
+	// prerequisite if missing.
+	Base string `json:"base,omitempty"`
+	// Prereq lists the default providers of the content plugs of the
+	// snap, installed as prerequisites if missing.
+	Prereq []string `json:"prereq,omitempty"`
 }
 
 func (snapsup *SnapSetup) Name() string {
@@ -67,20 +83,25 @@ func (snapsup *SnapSetup) Name() string {
 	return snapsup.SideInfo.RealName
 }
 
+// InstanceName returns the name of the snap instance the setup is for.
+func (snapsup *SnapSetup) InstanceName() string {
+	return snap.InstanceName(snapsup.Name(), snapsup.InstanceKey)
+}
+
 func (snapsup *SnapSetup) Revision() snap.Revision {
 	return snapsup.SideInfo.Revision
 }
 
 func (snapsup *SnapSetup) placeInfo() snap.PlaceInfo {
-	return snap.MinimalPlaceInfo(snapsup.Name(), snapsup.Revision())
+	return snap.MinimalPlaceInfo(snapsup.InstanceName(), snapsup.Revision())
 }
 
 func (snapsup *SnapSetup) MountDir() string {
-	return snap.MountDir(snapsup.Name(), snapsup.Revision())
+	return snap.MountDir(snapsup.InstanceName(), snapsup.Revision())
 }
 
 func (snapsup *SnapSetup) MountFile() string {
-	return snap.MountFile(snapsup.Name(), snapsup.Revision())
+	return snap.MountFile(snapsup.InstanceName(), snapsup.Revision())
 }
 
 // SnapState holds the state for a snap installed in the system.
@@ -94,6 +115,10 @@ type SnapState struct {
 	Current snap.Revision `json:"current"`
 	Channel string        `json:"channel,omitempty"`
 	Flags
+	// Aliases maps the aliases of the snap to the apps they point to.
+	Aliases map[string]*AliasTarget `json:"aliases,omitempty"`
+	// InstanceKey is set for parallel installations of the snap.
+	InstanceKey string `json:"instance-key,omitempty"`
 }
 
 // Type returns the type of the snap or an error.
@@ -195,8 +220,10 @@ func readInfoAnyway(name string, si *snap.SideInfo) (*snap.Info, error) {
 	info, err := snap.ReadInfo(name, si)
 	if _, ok := err.(*snap.NotFoundError); ok {
 		reason := fmt.Sprintf("cannot read snap %q: %s", name, err)
+		snapName, instanceKey := snap.SplitInstanceName(name)
 		info := &snap.Info{
-			SuggestedName: name,
+			SuggestedName: snapName,
+			InstanceKey:   instanceKey,
 			Broken:        reason,
 		}
 		info.Apps = snap.GuessAppsForBroken(info)
@@ -214,7 +241,7 @@ func (snapst *SnapState) CurrentInfo() (*snap.Info, error) {
 	if cur == nil {
 		return nil, ErrNoCurrent
 	}
-	return readInfo(cur.RealName, cur)
+	return readInfo(snap.InstanceName(cur.RealName, snapst.InstanceKey), cur)
 }
 
 func userFromUserID(st *state.State, userID int) (*auth.UserState, error) {
@@ -278,9 +305,10 @@ func Manager(st *state.State) (*SnapManager, error) {
 	runner := state.NewTaskRunner(st)
 
 	m := &SnapManager{
-		state:   st,
-		backend: backend.Backend{},
-		runner:  runner,
+		state:       st,
+		backend:     backend.Backend{},
+		runner:      runner,
+		autoRefresh: newAutoRefresh(st),
 	}
 
 	// this handler does nothing
@@ -289,32 +317,38 @@ func Manager(st *state.State) (*SnapManager, error) {
 	}, nil)
 
 	// install/update related
+	runner.AddHandler("prerequisites", m.doPrerequisites, nil)
 	runner.AddHandler("prepare-snap", m.doPrepareSnap, m.undoPrepareSnap)
 	runner.AddHandler("download-snap", m.doDownloadSnap, m.undoPrepareSnap)
+	runner.SetRetryPolicy("download-snap", downloadRetryPolicy)
 	runner.AddHandler("mount-snap", m.doMountSnap, m.undoMountSnap)
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
 	runner.AddCleanup("copy-snap-data", m.cleanupCopySnapData)
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.stopSnapServices)
+	runner.AddHandler("setup-aliases", m.doSetupAliases, m.undoSetupAliases)
 
 	// FIXME: drop the task entirely after a while
 	// (having this wart here avoids yet-another-patch)
 	runner.AddHandler("cleanup", func(*state.Task, *tomb.Tomb) error { return nil }, nil)
 
-	// FIXME: port to native tasks and rename
-	//runner.AddHandler("garbage-collect", m.doGarbageCollect, nil)
-
 	// TODO: refresh-all needs logic at this level, to properly
 	// handle the logic for that mode (e.g. skip snaps installed
 	// with --devmode, set jailmode from snapstate).
 
 	// remove related
 	runner.AddHandler("stop-snap-services", m.stopSnapServices, m.startSnapServices)
+	runner.AddHandler("remove-aliases", m.doRemoveAliases, m.undoRemoveAliases)
 	runner.AddHandler("unlink-snap", m.doUnlinkSnap, nil)
 	runner.AddHandler("clear-snap", m.doClearSnapData, nil)
 	runner.AddHandler("discard-snap", m.doDiscardSnap, nil)
 
+	// alias related
+	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, m.undoSwitchSnapChannel)
+	runner.AddHandler("alias", m.doAlias, m.undoAlias)
+	runner.AddHandler("unalias", m.doUnalias, m.undoAlias)
+
 	// test handlers
 	runner.AddHandler("fake-install-snap", func(t *state.Task, _ *tomb.Tomb) error {
 		return nil
@@ -361,6 +395,206 @@ func revisionInSequence(snapst *SnapState, needle snap.Revision) bool {
 	return false
 }
 
+// prerequisitesRetryTimeout is how long to wait before checking again
+// on a base that is being installed by another change.
+var prerequisitesRetryTimeout = 30 * time.Second
+
+func (m *SnapManager) doPrerequisites(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := TaskSnapSetup(t)
+	if err != nil {
+		return err
+	}
+
+	// the snaps whose prerequisites led to this one being installed
+	var chain []string
+	if err := t.Get("prereq-chain", &chain); err != nil && err != state.ErrNoState {
+		return err
+	}
+	chain = append(chain, snapsup.InstanceName())
+
+	type prereq struct {
+		name, kind string
+		info       *snap.Info
+	}
+	var prereqs []*prereq
+	if snapsup.Base != "" {
+		prereqs = append(prereqs, &prereq{name: snapsup.Base, kind: "base"})
+	}
+	for _, provider := range snapsup.Prereq {
+		prereqs = append(prereqs, &prereq{name: provider, kind: "content provider"})
+	}
+
+	// look the prerequisites up in the store first, the state lock
+	// is released while doing so
+	var toInstall []*prereq
+	seen := make(map[string]bool, len(prereqs))
+	for _, p := range prereqs {
+		if seen[p.name] {
+			continue
+		}
+		seen[p.name] = true
+		if inChain(chain, p.name) {
+			// the prerequisite is already being installed further
+			// up the chain and is waiting for us
+			t.Logf("Skipping %s %q: it depends on %q in turn", p.kind, p.name, snapsup.InstanceName())
+			continue
+		}
+		needed, err := prereqNeeded(t, p.name)
+		if err != nil {
+			return err
+		}
+		if !needed {
+			continue
+		}
+		p.info, err = prereqInfo(st, p.name, snapsup.UserID)
+		if err != nil {
+			return fmt.Errorf("cannot install %s %q for snap %q: %v", p.kind, p.name, snapsup.InstanceName(), err)
+		}
+		toInstall = append(toInstall, p)
+	}
+
+	// from here on the state stays locked until the tasks are
+	// injected; check all the prerequisites again, the state could
+	// have changed while talking to the store, and for conflicts
+	// before injecting anything, so that retrying never finds tasks
+	// injected by an earlier attempt
+	var ready []*prereq
+	for _, p := range toInstall {
+		needed, err := prereqNeeded(t, p.name)
+		if err != nil {
+			return err
+		}
+		if !needed {
+			continue
+		}
+		if err := CheckChangeConflictIgnoringChange(st, p.name, nil, t.Change().ID()); err != nil {
+			if _, ok := err.(*state.ChangeConflictError); ok {
+				// the prerequisite is being handled by something
+				// else, wait for it
+				return &state.Retry{After: prerequisitesRetryTimeout}
+			}
+			return err
+		}
+		ready = append(ready, p)
+	}
+
+	for _, p := range ready {
+		ts, err := installPrereq(st, p.info, chain, snapsup.UserID)
+		if err != nil {
+			return fmt.Errorf("cannot install %s %q for snap %q: %v", p.kind, p.name, snapsup.InstanceName(), err)
+		}
+		injectTasks(t, ts)
+		t.Logf("Installing %s %q", p.kind, p.name)
+	}
+
+	return nil
+}
+
+func inChain(chain []string, name string) bool {
+	for _, snapName := range chain {
+		if snapName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// prereqNeeded returns whether the named prerequisite still needs
+// installing: it is not installed yet and the change of t is not
+// installing it already.
+func prereqNeeded(t *state.Task, name string) (bool, error) {
+	var snapst SnapState
+	err := Get(t.State(), name, &snapst)
+	if err != nil && err != state.ErrNoState {
+		return false, err
+	}
+	if snapst.HasCurrent() {
+		// nothing to do, the prerequisite is already installed
+		return false, nil
+	}
+
+	for _, other := range t.Change().Tasks() {
+		if other == t || other.Status().Ready() {
+			continue
+		}
+		snapsup, err := TaskSnapSetup(other)
+		if err != nil {
+			continue
+		}
+		if snapsup.InstanceName() == name {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// prereqChannel is the channel prerequisites are installed from. The
+// channel of the snap requiring them says nothing about the tracks
+// and risks another snap, likely from another publisher, is
+// published to, so they get the same channel installing them on
+// their own would pick.
+const prereqChannel = "stable"
+
+// prereqInfo looks up in the store the named prerequisite, as allowed
+// by the enforced validation sets.
+func prereqInfo(st *state.State, name string, userID int) (*snap.Info, error) {
+	revision, err := revisionForValidationSets(st, name, snap.R(0), "install")
+	if err != nil {
+		return nil, err
+	}
+	return snapInfo(st, name, prereqChannel, revision, userID, Flags{})
+}
+
+// installPrereq returns the tasks to install the prerequisite with
+// the given info. It doesn't release the state lock.
+func installPrereq(st *state.State, info *snap.Info, chain []string, userID int) (*state.TaskSet, error) {
+	var snapst SnapState
+	if err := Get(st, info.InstanceName(), &snapst); err != nil && err != state.ErrNoState {
+		return nil, err
+	}
+	snapsup := &SnapSetup{
+		Channel:      prereqChannel,
+		UserID:       userID,
+		DownloadInfo: &info.DownloadInfo,
+		SideInfo:     &info.SideInfo,
+		Base:         info.Base,
+		Prereq:       defaultContentPlugProviders(st, info),
+	}
+	ts, err := doInstall(st, &snapst, snapsup, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, prereqTask := range ts.Tasks() {
+		if prereqTask.Kind() == "prerequisites" {
+			prereqTask.Set("prereq-chain", chain)
+		}
+	}
+	return ts, nil
+}
+
+// injectTasks adds the tasks of ts to the change of mainTask, making
+// them run after mainTask and before anything else that waits for it.
+func injectTasks(mainTask *state.Task, ts *state.TaskSet) {
+	for _, t := range mainTask.HaltTasks() {
+		t.WaitAll(ts)
+	}
+	for _, t := range ts.Tasks() {
+		t.WaitFor(mainTask)
+	}
+	for _, lane := range mainTask.Lanes() {
+		if lane != 0 {
+			ts.JoinLane(lane)
+		}
+	}
+	mainTask.Change().AddAll(ts)
+	mainTask.State().EnsureBefore(0)
+}
+
 func (m *SnapManager) doPrepareSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -395,6 +629,32 @@ func (m *SnapManager) undoPrepareSnap(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+// downloadRetryPolicy retries downloads failing for reasons likely to
+// go away by themselves, instead of undoing the whole change.
+var downloadRetryPolicy = &state.RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     10 * time.Second,
+	MaxBackoff:  5 * time.Minute,
+	Retryable:   isTransientError,
+}
+
+// isTransientError returns whether err is about the network or the
+// store not being up to it right now.
+func isTransientError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	switch e := err.(type) {
+	case *store.ErrDownload:
+		return e.Code >= 500 || e.Code == http.StatusTooManyRequests || e.Code == http.StatusRequestTimeout
+	case *net.OpError, *net.DNSError:
+		return true
+	case net.Error:
+		return e.Timeout() || e.Temporary()
+	}
+	return err == io.ErrUnexpectedEOF
+}
+
 func (m *SnapManager) doDownloadSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -455,7 +715,7 @@ func (m *SnapManager) doUnlinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	info, err := Info(t.State(), snapsup.Name(), snapsup.Revision())
+	info, err := Info(t.State(), snapsup.InstanceName(), snapsup.Revision())
 	if err != nil {
 		return err
 	}
@@ -470,7 +730,43 @@ func (m *SnapManager) doUnlinkSnap(t *state.Task, _ *tomb.Tomb) error {
 
 	// mark as inactive
 	snapst.Active = false
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
+	return nil
+}
+
+func (m *SnapManager) doSwitchSnapChannel(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	t.Set("old-channel", snapst.Channel)
+	snapst.Channel = snapsup.Channel
+	Set(st, snapsup.InstanceName(), snapst)
+	return nil
+}
+
+func (m *SnapManager) undoSwitchSnapChannel(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+
+	var oldChannel string
+	if err := t.Get("old-channel", &oldChannel); err != nil {
+		return err
+	}
+
+	snapst.Channel = oldChannel
+	Set(st, snapsup.InstanceName(), snapst)
 	return nil
 }
 
@@ -483,7 +779,7 @@ func (m *SnapManager) doClearSnapData(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	t.State().Lock()
-	info, err := Info(t.State(), snapsup.Name(), snapsup.Revision())
+	info, err := Info(t.State(), snapsup.InstanceName(), snapsup.Revision())
 	t.State().Unlock()
 	if err != nil {
 		return err
@@ -514,7 +810,7 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	if snapst.Current == snapsup.Revision() && snapst.Active {
-		return fmt.Errorf("internal error: cannot discard snap %q: still active", snapsup.Name())
+		return fmt.Errorf("internal error: cannot discard snap %q: still active", snapsup.InstanceName())
 	}
 
 	if len(snapst.Sequence) == 1 {
@@ -543,29 +839,33 @@ func (m *SnapManager) doDiscardSnap(t *state.Task, _ *tomb.Tomb) error {
 	err = m.backend.RemoveSnapFiles(snapsup.placeInfo(), typ, pb)
 	if err != nil {
 		st.Lock()
-		t.Errorf("cannot remove snap file %q, will retry in 3 mins: %s", snapsup.Name(), err)
+		t.Errorf("cannot remove snap file %q, will retry in 3 mins: %s", snapsup.InstanceName(), err)
 		st.Unlock()
 		return &state.Retry{After: 3 * time.Minute}
 	}
 	if len(snapst.Sequence) == 0 {
-		err = m.backend.DiscardSnapNamespace(snapsup.Name())
+		err = m.backend.DiscardSnapNamespace(snapsup.InstanceName())
 		if err != nil {
 			st.Lock()
-			t.Errorf("cannot discard snap namespace %q, will retry in 3 mins: %s", snapsup.Name(), err)
+			t.Errorf("cannot discard snap namespace %q, will retry in 3 mins: %s", snapsup.InstanceName(), err)
 			st.Unlock()
 			return &state.Retry{After: 3 * time.Minute}
 		}
 	}
 	st.Lock()
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
 	st.Unlock()
 	return nil
 }
 
 // Ensure implements StateManager.Ensure.
 func (m *SnapManager) Ensure() error {
+	// do not exit right away on error
+	err := m.autoRefresh.Ensure()
+
 	m.runner.Ensure()
-	return nil
+
+	return err
 }
 
 // Wait implements StateManager.Wait.
@@ -609,7 +909,7 @@ func snapSetupAndState(t *state.Task) (*SnapSetup, *SnapState, error) {
 		return nil, nil, err
 	}
 	var snapst SnapState
-	err = Get(t.State(), snapsup.Name(), &snapst)
+	err = Get(t.State(), snapsup.InstanceName(), &snapst)
 	if err != nil && err != state.ErrNoState {
 		return nil, nil, err
 	}
@@ -660,12 +960,12 @@ func (m *SnapManager) doMountSnap(t *state.Task, _ *tomb.Tomb) error {
 	pb := &TaskProgressAdapter{task: t}
 	// TODO Use snapsup.Revision() to obtain the right info to mount
 	//      instead of assuming the candidate is the right one.
-	if err := m.backend.SetupSnap(snapsup.SnapPath, snapsup.SideInfo, pb); err != nil {
+	if err := m.backend.SetupSnap(snapsup.SnapPath, snapsup.InstanceName(), snapsup.SideInfo, pb); err != nil {
 		return err
 	}
 
 	// set snapst type for undoMountSnap
-	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
+	newInfo, err := readInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
 		return err
 	}
@@ -707,7 +1007,7 @@ func (m *SnapManager) undoUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	// mark as active again
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
 
 	return nil
 
@@ -740,7 +1040,7 @@ func (m *SnapManager) doUnlinkCurrentSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	// mark as inactive
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
 	return nil
 }
 
@@ -752,7 +1052,7 @@ func (m *SnapManager) undoCopySnapData(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
+	newInfo, err := readInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
 		return err
 	}
@@ -774,7 +1074,7 @@ func (m *SnapManager) doCopySnapData(t *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
+	newInfo, err := readInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
 		return err
 	}
@@ -815,6 +1115,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	oldCurrent := snapst.Current
 	snapst.Current = cand.Revision
 	snapst.Active = true
+	snapst.InstanceKey = snapsup.InstanceKey
 	oldChannel := snapst.Channel
 	if snapsup.Channel != "" {
 		snapst.Channel = snapsup.Channel
@@ -826,7 +1127,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	oldJailMode := snapst.JailMode
 	snapst.JailMode = snapsup.JailMode
 
-	newInfo, err := readInfo(snapsup.Name(), cand)
+	newInfo, err := readInfo(snapsup.InstanceName(), cand)
 	if err != nil {
 		return err
 	}
@@ -842,7 +1143,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 		err := m.backend.UnlinkSnap(newInfo, pb)
 		if err != nil {
 			st.Lock()
-			t.Errorf("cannot cleanup failed attempt at making snap %q available to the system: %v", snapsup.Name(), err)
+			t.Errorf("cannot cleanup failed attempt at making snap %q available to the system: %v", snapsup.InstanceName(), err)
 			st.Unlock()
 		}
 	}
@@ -859,7 +1160,7 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	t.Set("old-current", oldCurrent)
 	t.Set("old-candidate-index", oldCandidateIndex)
 	// Do at the end so we only preserve the new state if it worked.
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
 	// Make sure if state commits and snapst is mutated we won't be rerun
 	t.SetStatus(state.DoneStatus)
 
@@ -944,7 +1245,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	snapst.DevMode = oldDevMode
 	snapst.JailMode = oldJailMode
 
-	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
+	newInfo, err := readInfo(snapsup.InstanceName(), snapsup.SideInfo)
 	if err != nil {
 		return err
 	}
@@ -958,7 +1259,7 @@ func (m *SnapManager) undoLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	}
 
 	// mark as inactive
-	Set(st, snapsup.Name(), snapst)
+	Set(st, snapsup.InstanceName(), snapst)
 	// Make sure if state commits and snapst is mutated we won't be rerun
 	t.SetStatus(state.UndoneStatus)
 	return nil
@@ -25,6 +25,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -39,7 +41,7 @@ import (
 	"github.com/snapcore/snapd/store"
 
 	// So it registers Configure.
-	_ "github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate"
 )
 
 func TestSnapManager(t *testing.T) { TestingT(t) }
@@ -100,6 +102,10 @@ func (s *snapmgrTestSuite) SetUpTest(c *C) {
 
 func (s *snapmgrTestSuite) TearDownTest(c *C) {
 	snapstate.ValidateRefreshes = nil
+	snapstate.EnforcedValidationSets = nil
+	snapstate.RefreshSnapDeclarations = nil
+	snapstate.CanAutoRefresh = nil
+	snapstate.AutoAliases = nil
 	s.reset()
 }
 
@@ -132,6 +138,15 @@ func taskKinds(tasks []*state.Task) []string {
 
 func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *state.State) {
 	kinds := taskKinds(ts.Tasks())
+	for i, t := range ts.Tasks() {
+		if t.Kind() == "run-hook" {
+			var hooksup struct {
+				Hook string `json:"hook"`
+			}
+			c.Assert(t.Get("hook-setup", &hooksup), IsNil)
+			kinds[i] = fmt.Sprintf("run-hook[%s]", hooksup.Hook)
+		}
+	}
 
 	expected := []string{
 		"download-snap",
@@ -140,7 +155,9 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 	}
 	if opts&unlinkBefore != 0 {
 		expected = append(expected,
+			"run-hook[pre-refresh]",
 			"stop-snap-services",
+			"remove-aliases",
 			"unlink-current-snap",
 		)
 	}
@@ -148,6 +165,14 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 		"copy-snap-data",
 		"setup-profiles",
 		"link-snap",
+		"setup-aliases",
+	)
+	if opts&unlinkBefore != 0 {
+		expected = append(expected, "run-hook[post-refresh]")
+	} else {
+		expected = append(expected, "run-hook[install]")
+	}
+	expected = append(expected,
 		"start-snap-services",
 	)
 	for i := 0; i < discards; i++ {
@@ -162,7 +187,7 @@ func verifyInstallUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *s
 		)
 	}
 	expected = append(expected,
-		"run-hook",
+		"run-hook[configure]",
 	)
 
 	c.Assert(kinds, DeepEquals, expected)
@@ -209,9 +234,11 @@ func (s *snapmgrTestSuite) TestRevertTasks(c *C) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"prepare-snap",
 		"stop-snap-services",
+		"remove-aliases",
 		"unlink-current-snap",
 		"setup-profiles",
 		"link-snap",
+		"setup-aliases",
 		"start-snap-services",
 		"run-hook",
 	})
@@ -279,7 +306,7 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 		SnapType: "app",
 	})
 
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 1)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
@@ -289,6 +316,56 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyParallelInstances(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		Channel:  "stable",
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "some-snap_instance", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:     snap.R(1),
+		Channel:     "channel-for-7",
+		SnapType:    "app",
+		InstanceKey: "instance",
+	})
+
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Assert(tts, HasLen, 2)
+	sort.Strings(updates)
+	c.Check(updates, DeepEquals, []string{"some-snap", "some-snap_instance"})
+
+	// each instance got the update for its own channel
+	expected := map[string]struct {
+		channel string
+		rev     snap.Revision
+	}{
+		"some-snap":          {"stable", snap.R(11)},
+		"some-snap_instance": {"channel-for-7", snap.R(7)},
+	}
+	for _, ts := range tts {
+		snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+		c.Assert(err, IsNil)
+		exp, ok := expected[snapsup.InstanceName()]
+		c.Assert(ok, Equals, true)
+		c.Check(snapsup.Channel, Equals, exp.channel)
+		c.Check(snapsup.Revision(), Equals, exp.rev)
+		delete(expected, snapsup.InstanceName())
+	}
+	c.Check(expected, HasLen, 0)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyDevMode(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -303,7 +380,7 @@ func (s *snapmgrTestSuite) TestUpdateManyDevMode(c *C) {
 		SnapType: "app",
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0)
+	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Check(updates, HasLen, 1)
 }
@@ -322,7 +399,7 @@ func (s *snapmgrTestSuite) TestUpdateAllDevMode(c *C) {
 		SnapType: "app",
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, _, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Check(updates, HasLen, 0)
 }
@@ -351,7 +428,7 @@ func (s *snapmgrTestSuite) TestUpdateManyValidateRefreshes(c *C) {
 	// hook it up
 	snapstate.ValidateRefreshes = validateRefreshes
 
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 1)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
@@ -383,13 +460,13 @@ func (s *snapmgrTestSuite) TestUpdateManyValidateRefreshesUnhappy(c *C) {
 	snapstate.ValidateRefreshes = validateRefreshes
 
 	// refresh all => no error
-	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0)
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Check(tts, HasLen, 0)
 	c.Check(updates, HasLen, 0)
 
 	// refresh some-snap => report error
-	updates, tts, err = snapstate.UpdateMany(s.state, []string{"some-snap"}, 0)
+	updates, tts, err = snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, snapstate.Flags{})
 	c.Assert(err, Equals, validateErr)
 	c.Check(tts, HasLen, 0)
 	c.Check(updates, HasLen, 0)
@@ -419,9 +496,11 @@ func (s *snapmgrTestSuite) TestRevertCreatesNoGCTasks(c *C) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"prepare-snap",
 		"stop-snap-services",
+		"remove-aliases",
 		"unlink-current-snap",
 		"setup-profiles",
 		"link-snap",
+		"setup-aliases",
 		"start-snap-services",
 		"run-hook",
 	})
@@ -439,16 +518,18 @@ func (s *snapmgrTestSuite) TestEnableTasks(c *C) {
 		Active:  false,
 	})
 
-	ts, err := snapstate.Enable(s.state, "some-snap")
+	ts, err := snapstate.Enable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 
 	i := 0
-	c.Assert(ts.Tasks(), HasLen, 3)
-	c.Assert(s.state.TaskCount(), Equals, 3)
+	c.Assert(ts.Tasks(), HasLen, 4)
+	c.Assert(s.state.TaskCount(), Equals, 4)
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "prepare-snap")
 	i++
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "link-snap")
 	i++
+	c.Assert(ts.Tasks()[i].Kind(), Equals, "setup-aliases")
+	i++
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "start-snap-services")
 }
 
@@ -464,14 +545,16 @@ func (s *snapmgrTestSuite) TestDisableTasks(c *C) {
 		Active:  true,
 	})
 
-	ts, err := snapstate.Disable(s.state, "some-snap")
+	ts, err := snapstate.Disable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 
 	i := 0
-	c.Assert(ts.Tasks(), HasLen, 2)
-	c.Assert(s.state.TaskCount(), Equals, 2)
+	c.Assert(ts.Tasks(), HasLen, 3)
+	c.Assert(s.state.TaskCount(), Equals, 3)
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "stop-snap-services")
 	i++
+	c.Assert(ts.Tasks()[i].Kind(), Equals, "remove-aliases")
+	i++
 	c.Assert(ts.Tasks()[i].Kind(), Equals, "unlink-snap")
 }
 
@@ -487,13 +570,24 @@ func (s *snapmgrTestSuite) TestEnableConflict(c *C) {
 		Active:  false,
 	})
 
-	ts, err := snapstate.Enable(s.state, "some-snap")
+	ts, err := snapstate.Enable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 	// need a change to make the tasks visible
-	s.state.NewChange("enable", "...").AddAll(ts)
+	chg := s.state.NewChange("enable", "...")
+	chg.AddAll(ts)
 
-	_, err = snapstate.Enable(s.state, "some-snap")
+	_, err = snapstate.Enable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" has changes in progress`)
+	c.Check(err, DeepEquals, &state.ChangeConflictError{
+		Message:    `snap "some-snap" has changes in progress`,
+		ChangeKind: "enable",
+		ChangeID:   chg.ID(),
+	})
+
+	// unless that change is ignored, as when building a change
+	// that will wait for it
+	_, err = snapstate.Enable(s.state, "some-snap", snapstate.Flags{IgnoreConflictsWith: chg.ID()})
+	c.Check(err, IsNil)
 }
 
 func (s *snapmgrTestSuite) TestDisableConflict(c *C) {
@@ -508,15 +602,153 @@ func (s *snapmgrTestSuite) TestDisableConflict(c *C) {
 		Active:  true,
 	})
 
-	ts, err := snapstate.Disable(s.state, "some-snap")
+	ts, err := snapstate.Disable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 	// need a change to make the tasks visible
 	s.state.NewChange("install", "...").AddAll(ts)
 
-	_, err = snapstate.Disable(s.state, "some-snap")
+	_, err = snapstate.Disable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" has changes in progress`)
 }
 
+func (s *snapmgrTestSuite) TestSwitchTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  false,
+		Channel: "stable",
+	})
+
+	ts, err := snapstate.Switch(s.state, "some-snap", "1.0/beta", snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	c.Assert(ts.Tasks(), HasLen, 1)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "switch-snap-channel")
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Switch snap "some-snap" from channel "stable" to "1.0/beta"`)
+}
+
+func (s *snapmgrTestSuite) TestSwitchErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Switch(s.state, "some-snap", "edge", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot find snap "some-snap"`)
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(11)},
+		},
+		Current: snap.R(11),
+		Active:  true,
+	})
+
+	_, err = snapstate.Switch(s.state, "some-snap", "1.0/potato", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `invalid risk in channel name: "1.0/potato"`)
+
+	ts, err := snapstate.Switch(s.state, "some-snap", "edge", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	// need a change to make the tasks visible
+	s.state.NewChange("switch-snap", "...").AddAll(ts)
+
+	_, err = snapstate.Switch(s.state, "some-snap", "beta", snapstate.Flags{})
+	c.Check(err, ErrorMatches, `snap "some-snap" has changes in progress`)
+}
+
+func (s *snapmgrTestSuite) TestSwitchRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+		Channel:  "stable",
+	})
+
+	chg := s.state.NewChange("switch-snap", "switch the channel of a snap")
+	ts, err := snapstate.Switch(s.state, "some-snap", "1.0/edge", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.DoneStatus)
+	// nothing was refreshed
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Channel, Equals, "1.0/edge")
+	c.Check(snapst.Current, Equals, snap.R(7))
+
+	// a later refresh follows the new channel
+	_, err = snapstate.Update(s.state, "some-snap", "", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Assert(s.fakeBackend.ops, HasLen, 1)
+	c.Check(s.fakeBackend.ops[0].op, Equals, "storesvc-list-refresh")
+	c.Check(s.fakeBackend.ops[0].cand.Channel, Equals, "1.0/edge")
+}
+
+func (s *snapmgrTestSuite) TestSwitchUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	defer s.snapmgr.Stop()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+		Channel:  "stable",
+	})
+
+	chg := s.state.NewChange("switch-snap", "switch the channel of a snap")
+	ts, err := snapstate.Switch(s.state, "some-snap", "beta", snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Status(), Equals, state.ErrorStatus)
+
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Channel, Equals, "stable")
+}
+
+func (s *snapmgrTestSuite) TestInstallUpdateInvalidChannel(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Install(s.state, "some-snap", "stable/", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `invalid branch in channel name: "stable/"`)
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	_, err = snapstate.Update(s.state, "some-snap", "1.0/potato", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `invalid risk in channel name: "1.0/potato"`)
+}
+
 func (s *snapmgrTestSuite) TestDoInstallChannelDefault(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -750,12 +982,14 @@ func (s *snapmgrTestSuite) TestRemoveTasks(c *C) {
 		Current: snap.R(11),
 	})
 
-	ts, err := snapstate.Remove(s.state, "foo", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "foo", snap.R(0), snapstate.Flags{})
 	c.Assert(err, IsNil)
 
 	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+		"run-hook",
 		"stop-snap-services",
+		"remove-aliases",
 		"unlink-snap",
 		"remove-profiles",
 		"clear-snap",
@@ -774,12 +1008,12 @@ func (s *snapmgrTestSuite) TestRemoveConflict(c *C) {
 		Current:  snap.R(11),
 	})
 
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
 	c.Assert(err, IsNil)
 	// need a change to make the tasks visible
 	s.state.NewChange("remove", "...").AddAll(ts)
 
-	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0))
+	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" has changes in progress`)
 }
 
@@ -881,8 +1115,12 @@ func (s *snapmgrTestSuite) TestInstallRunThrough(c *C) {
 	c.Check(task.Summary(), Equals, `Download snap "some-snap" (42) from channel "some-channel"`)
 
 	// check link/start snap summary
-	linkTask := ta[len(ta)-3]
+	linkTask := ta[len(ta)-5]
 	c.Check(linkTask.Summary(), Equals, `Make snap "some-snap" (42) available to the system`)
+	aliasesTask := ta[len(ta)-4]
+	c.Check(aliasesTask.Summary(), Equals, `Setup snap "some-snap" (42) aliases`)
+	installHookTask := ta[len(ta)-3]
+	c.Check(installHookTask.Summary(), Equals, `Run install hook of "some-snap" snap if present`)
 	startTask := ta[len(ta)-2]
 	c.Check(startTask.Summary(), Equals, `Start snap "some-snap" (42) services`)
 
@@ -922,6 +1160,476 @@ func (s *snapmgrTestSuite) TestInstallRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestInstallParallelInstanceRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap_instance", "some-channel", snap.R(42), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+	// the store is asked about the snap, not the instance
+	c.Check(s.fakeStore.downloads, DeepEquals, []fakeDownload{{
+		macaroon: s.user.StoreMacaroon,
+		name:     "some-snap",
+	}})
+	c.Check(s.fakeBackend.ops.First("storesvc-snap"), DeepEquals, &fakeOp{
+		op:    "storesvc-snap",
+		name:  "some-snap",
+		revno: snap.R(42),
+	})
+	c.Check(s.fakeBackend.ops.First("setup-snap"), DeepEquals, &fakeOp{
+		op:    "setup-snap",
+		name:  "/var/lib/snapd/snaps/some-snap_instance_42.snap",
+		revno: snap.R(42),
+	})
+	c.Check(s.fakeBackend.ops.First("copy-data"), DeepEquals, &fakeOp{
+		op:   "copy-data",
+		name: "/snap/some-snap_instance/42",
+		old:  "<no-old>",
+	})
+	c.Check(s.fakeBackend.ops.First("link-snap"), DeepEquals, &fakeOp{
+		op:   "link-snap",
+		name: "/snap/some-snap_instance/42",
+	})
+
+	task := ts.Tasks()[0]
+	c.Check(task.Summary(), Equals, `Download snap "some-snap_instance" (42) from channel "some-channel"`)
+	var snapsup snapstate.SnapSetup
+	err = task.Get("snap-setup", &snapsup)
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Name(), Equals, "some-snap")
+	c.Check(snapsup.InstanceKey, Equals, "instance")
+	c.Check(snapsup.InstanceName(), Equals, "some-snap_instance")
+
+	// the instance is tracked separately from the main snap
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap_instance", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.InstanceKey, Equals, "instance")
+	c.Check(snapst.Current, Equals, snap.R(42))
+	info, err := snapst.CurrentInfo()
+	c.Assert(err, IsNil)
+	c.Check(info.InstanceName(), Equals, "some-snap_instance")
+	c.Check(info.MountDir(), Equals, "/snap/some-snap_instance/42")
+
+	var mainSnapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &mainSnapst)
+	c.Assert(err, IsNil)
+	c.Check(mainSnapst.InstanceKey, Equals, "")
+	c.Check(mainSnapst.Current, Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestInstallParallelInstanceInvalidKey(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.Install(s.state, "some-snap_Instance", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `invalid instance key: "Instance"`)
+}
+
+func (s *snapmgrTestSuite) TestRemoveParallelInstanceTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap_instance", &snapstate.SnapState{
+		Active:      true,
+		Sequence:    []*snap.SideInfo{{RealName: "some-snap", Revision: snap.R(11)}},
+		Current:     snap.R(11),
+		SnapType:    "app",
+		InstanceKey: "instance",
+	})
+
+	ts, err := snapstate.Remove(s.state, "some-snap_instance", snap.R(0), snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("remove", "remove a snap")
+	chg.AddAll(ts)
+
+	for _, t := range ts.Tasks() {
+		if t.Kind() == "run-hook" {
+			continue
+		}
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		c.Check(snapsup.Name(), Equals, "some-snap")
+		c.Check(snapsup.InstanceName(), Equals, "some-snap_instance")
+	}
+}
+
+func (s *snapmgrTestSuite) TestInstallWithBaseTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	tasks := ts.Tasks()
+	c.Assert(len(tasks) > 2, Equals, true)
+	c.Check(tasks[0].Kind(), Equals, "prerequisites")
+	c.Check(tasks[0].Summary(), Equals, `Ensure prerequisites for "some-snap-with-base" are available`)
+	c.Check(tasks[1].Kind(), Equals, "download-snap")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{tasks[0]})
+
+	snapsup, err := snapstate.TaskSnapSetup(tasks[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Base, Equals, "some-base")
+}
+
+func (s *snapmgrTestSuite) TestInstallWithBaseRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	// the base got linked before the snap using it
+	var linked []string
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "link-snap" {
+			linked = append(linked, op.name)
+		}
+	}
+	c.Check(linked, DeepEquals, []string{
+		"/snap/some-base/11",
+		"/snap/some-snap-with-base/11",
+	})
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-base", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestInstallWithBaseAlreadyInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-base", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-base", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "base",
+	})
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	// no extra tasks were added for the base
+	c.Check(chg.Tasks(), HasLen, len(ts.Tasks()))
+	c.Check(s.fakeBackend.ops.First("storesvc-snap"), DeepEquals, &fakeOp{
+		op:    "storesvc-snap",
+		name:  "some-snap-with-base",
+		revno: snap.R(11),
+	})
+}
+
+func (s *snapmgrTestSuite) TestRemoveBaseInUse(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-base", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-base", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "base",
+	})
+	snapstate.Set(s.state, "some-snap-with-base", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "some-snap-with-base", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	_, err := snapstate.Remove(s.state, "some-base", snap.R(0), snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `snap "some-base" is not removable: it is the base of "some-snap-with-base"`)
+
+	snapstate.Set(s.state, "some-snap-with-base", nil)
+	_, err = snapstate.Remove(s.state, "some-base", snap.R(0), snapstate.Flags{})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestInstallWithDefaultProviderTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	ts, err := snapstate.Install(s.state, "snap-content-plug", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	tasks := ts.Tasks()
+	c.Assert(len(tasks) > 2, Equals, true)
+	c.Check(tasks[0].Kind(), Equals, "prerequisites")
+
+	snapsup, err := snapstate.TaskSnapSetup(tasks[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Prereq, DeepEquals, []string{"snap-content-slot"})
+}
+
+func (s *snapmgrTestSuite) TestInstallWithDefaultProviderInstalledNoPrereq(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "snap-content-slot", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{{RealName: "snap-content-slot", Revision: snap.R(1)}},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	ts, err := snapstate.Install(s.state, "snap-content-plug", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(ts.Tasks()[0].Kind(), Equals, "download-snap")
+}
+
+func (s *snapmgrTestSuite) TestInstallWithDefaultProviderRunThrough(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "snap-content-plug", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	// the provider got linked before the consumer
+	var linked []string
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "link-snap" {
+			linked = append(linked, op.name)
+		}
+	}
+	c.Check(linked, DeepEquals, []string{
+		"/snap/snap-content-slot/11",
+		"/snap/snap-content-plug/11",
+	})
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "snap-content-slot", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestInstallWithDefaultProviderCycle(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "snap-content-cycle-a", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	var linked []string
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "link-snap" {
+			linked = append(linked, op.name)
+		}
+	}
+	c.Check(linked, DeepEquals, []string{
+		"/snap/snap-content-cycle-b/11",
+		"/snap/snap-content-cycle-a/11",
+	})
+
+	var cycleLogged bool
+	for _, t := range chg.Tasks() {
+		if t.Kind() != "prerequisites" {
+			continue
+		}
+		for _, l := range t.Log() {
+			if strings.Contains(l, `Skipping content provider "snap-content-cycle-a"`) {
+				cycleLogged = true
+			}
+		}
+	}
+	c.Check(cycleLogged, Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestInstallPrereqsRetryInjectsNothing(c *C) {
+	restore := snapstate.MockPrerequisitesRetryTimeout(0)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the content provider is being installed by another change
+	otherChg := s.state.NewChange("install", "install the provider")
+	ts, err := snapstate.Install(s.state, "snap-content-slot", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	otherChg.AddAll(ts)
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err = snapstate.Install(s.state, "snap-with-base-and-content", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	prereqTask := ts.Tasks()[0]
+	c.Assert(prereqTask.Kind(), Equals, "prerequisites")
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+	s.state.Lock()
+
+	// the conflict over the provider was found before injecting the base
+	c.Check(prereqTask.Status(), Equals, state.DoingStatus)
+	c.Check(chg.Tasks(), HasLen, len(ts.Tasks()))
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(otherChg.Err(), IsNil)
+	c.Assert(chg.Err(), IsNil)
+	c.Assert(chg.IsReady(), Equals, true)
+
+	var linked []string
+	for _, op := range s.fakeBackend.ops {
+		if op.op == "link-snap" {
+			linked = append(linked, op.name)
+		}
+	}
+	// each prerequisite got installed once, before the snap itself
+	c.Assert(linked, HasLen, 3)
+	c.Check(linked[2], Equals, "/snap/snap-with-base-and-content/11")
+	prereqs := linked[:2]
+	sort.Strings(prereqs)
+	c.Check(prereqs, DeepEquals, []string{"/snap/snap-content-slot/11", "/snap/some-base/11"})
+}
+
+func (s *snapmgrTestSuite) TestInstallPrereqsConflictDuringStoreLookup(c *C) {
+	restore := snapstate.MockPrerequisitesRetryTimeout(0)
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	prereqTask := ts.Tasks()[0]
+	c.Assert(prereqTask.Kind(), Equals, "prerequisites")
+
+	// another change starts operating on the base while it is
+	// being looked up in the store
+	var otherChg *state.Change
+	s.fakeStore.snapHook = func(name string) {
+		if name != "some-base" || otherChg != nil {
+			return
+		}
+		s.state.Lock()
+		defer s.state.Unlock()
+		otherChg = s.state.NewChange("other", "...")
+		t := s.state.NewTask("link-snap", "...")
+		t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "some-base"}})
+		otherChg.AddTask(t)
+	}
+
+	s.state.Unlock()
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+	s.state.Lock()
+
+	// the conflict was caught before injecting the base, the task
+	// waits for the other change instead of failing
+	c.Assert(otherChg, NotNil)
+	c.Check(prereqTask.Status(), Equals, state.DoingStatus)
+	c.Check(chg.Err(), IsNil)
+	c.Check(chg.Tasks(), HasLen, len(ts.Tasks()))
+}
+
+func (s *snapmgrTestSuite) TestInstallPrereqFromStableChannel(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "some-snap-with-base", "edge", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+
+	// the snap comes from the channel asked for, its base from
+	// the default channel
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap-with-base", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Channel, Equals, "edge")
+	err = snapstate.Get(s.state, "some-base", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Channel, Equals, "stable")
+}
+
+func (s *snapmgrTestSuite) TestInstallWithDefaultProviderNotFound(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.state.NewChange("install", "install a snap")
+	ts, err := snapstate.Install(s.state, "snap-content-plug-missing", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), ErrorMatches, `(?s).*cannot install content provider "no-such-provider" for snap "snap-content-plug-missing": snap not found.*`)
+	c.Check(s.fakeBackend.ops.First("link-snap"), IsNil)
+}
+
 func (s *snapmgrTestSuite) TestUpdateRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -1239,6 +1947,59 @@ func (s *snapmgrTestSuite) TestUpdateUndoRunThrough(c *C) {
 	})
 }
 
+func (s *snapmgrTestSuite) TestUpdatePreRefreshHookFailureUndo(c *C) {
+	si := snap.SideInfo{
+		RealName: "some-snap",
+		SnapID:   "some-snap-id",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("refresh", "refresh a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.fakeBackend.runHookFailTrigger = "some-snap:pre-refresh"
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*pre-refresh hook of snap "some-snap" failed.*`)
+
+	// the refresh stopped before touching the current revision and
+	// the new one got unmounted again
+	ops := s.fakeBackend.ops.Ops()
+	c.Check(ops, DeepEquals, []string{
+		"storesvc-list-refresh",
+		"storesvc-download",
+		"validate-snap:Doing",
+		"current",
+		"open-snap-file",
+		"setup-snap",
+		"undo-setup-snap",
+	})
+
+	var snapst snapstate.SnapState
+	err = snapstate.Get(s.state, "some-snap", &snapst)
+	c.Assert(err, IsNil)
+	c.Check(snapst.Active, Equals, true)
+	c.Check(snapst.Current, Equals, snap.R(7))
+	c.Check(snapst.Sequence, HasLen, 1)
+}
+
 func (s *snapmgrTestSuite) TestUpdateTotalUndoRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -1554,7 +2315,7 @@ func (s *snapmgrTestSuite) TestMultiUpdateBlockedRevision(c *C) {
 		Current:  si7.Revision,
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID)
+	updates, _, err := snapstate.UpdateMany(s.state, []string{"some-snap"}, s.user.ID, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Check(updates, DeepEquals, []string{"some-snap"})
 
@@ -1593,7 +2354,7 @@ func (s *snapmgrTestSuite) TestAllUpdateBlockedRevision(c *C) {
 		Current:  si7.Revision,
 	})
 
-	updates, _, err := snapstate.UpdateMany(s.state, nil, s.user.ID)
+	updates, _, err := snapstate.UpdateMany(s.state, nil, s.user.ID, snapstate.Flags{})
 	c.Check(err, IsNil)
 	c.Check(updates, HasLen, 0)
 
@@ -1952,7 +2713,7 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -2003,6 +2764,9 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	// verify snapSetup info
 	tasks := ts.Tasks()
 	for _, t := range tasks {
+		if t.Kind() == "run-hook" {
+			continue
+		}
 		snapsup, err := snapstate.TaskSnapSetup(t)
 		c.Assert(err, IsNil)
 
@@ -2057,7 +2821,7 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -2127,6 +2891,9 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	revnos := []snap.Revision{{N: 7}, {N: 3}, {N: 5}}
 	whichRevno := 0
 	for _, t := range tasks {
+		if t.Kind() == "run-hook" {
+			continue
+		}
 		snapsup, err := snapstate.TaskSnapSetup(t)
 		c.Assert(err, IsNil)
 
@@ -2186,7 +2953,7 @@ func (s *snapmgrTestSuite) TestRemoveOneRevisionRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(3), snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -2249,7 +3016,7 @@ func (s *snapmgrTestSuite) TestRemoveLastRevisionRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("remove", "remove a snap")
-	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	ts, err := snapstate.Remove(s.state, "some-snap", snap.R(2), snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -2324,7 +3091,7 @@ func (s *snapmgrTestSuite) TestRemoveCurrentActiveRevisionRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2), snapstate.Flags{})
 
 	c.Check(err, ErrorMatches, `cannot remove active revision 2 of snap "some-snap"`)
 }
@@ -2345,7 +3112,7 @@ func (s *snapmgrTestSuite) TestRemoveCurrentRevisionOfSeveralRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(2), snapstate.Flags{})
 	c.Assert(err, NotNil)
 	c.Check(err.Error(), Equals, `cannot remove active revision 2 of snap "some-snap" (revert first?)`)
 }
@@ -2366,7 +3133,7 @@ func (s *snapmgrTestSuite) TestRemoveMissingRevisionRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "some-snap", snap.R(1))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(1), snapstate.Flags{})
 
 	c.Check(err, ErrorMatches, `revision 1 of snap "some-snap" is not installed`)
 }
@@ -2387,7 +3154,7 @@ func (s *snapmgrTestSuite) TestRemoveRefused(c *C) {
 		SnapType: "app",
 	})
 
-	_, err := snapstate.Remove(s.state, "gadget", snap.R(0))
+	_, err := snapstate.Remove(s.state, "gadget", snap.R(0), snapstate.Flags{})
 
 	c.Check(err, ErrorMatches, `snap "gadget" is not removable`)
 }
@@ -2454,6 +3221,62 @@ func (s *snapmgrTestSuite) TestUpdateDoesGC(c *C) {
 
 }
 
+func (s *snapmgrTestSuite) testUpdateGCRetain(c *C, retain interface{}, expected []snap.Revision) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	if retain != nil {
+		tr := configstate.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "refresh.retain", retain), IsNil)
+		tr.Commit()
+	}
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(3)},
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(4)},
+		},
+		Current:  snap.R(4),
+		SnapType: "app",
+	})
+
+	chg := s.state.NewChange("update", "update a snap")
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	var discarded []snap.Revision
+	for _, t := range ts.Tasks() {
+		if t.Kind() != "discard-snap" {
+			continue
+		}
+		snapsup, err := snapstate.TaskSnapSetup(t)
+		c.Assert(err, IsNil)
+		discarded = append(discarded, snapsup.Revision())
+	}
+	c.Check(discarded, DeepEquals, expected)
+}
+
+func (s *snapmgrTestSuite) TestUpdateGCRetainDefault(c *C) {
+	s.testUpdateGCRetain(c, nil, []snap.Revision{snap.R(1), snap.R(2)})
+}
+
+func (s *snapmgrTestSuite) TestUpdateGCRetainConfigured(c *C) {
+	s.testUpdateGCRetain(c, 2, []snap.Revision{snap.R(1), snap.R(2), snap.R(3)})
+}
+
+func (s *snapmgrTestSuite) TestUpdateGCRetainAll(c *C) {
+	s.testUpdateGCRetain(c, 5, nil)
+}
+
+func (s *snapmgrTestSuite) TestUpdateGCRetainKeepsPrevious(c *C) {
+	// even if misconfigured, the current revision is kept for revert
+	s.testUpdateGCRetain(c, 1, []snap.Revision{snap.R(1), snap.R(2), snap.R(3)})
+}
+
 func (s *snapmgrTestSuite) TestRevertNoRevertAgain(c *C) {
 	siNew := snap.SideInfo{
 		RealName: "some-snap",
@@ -2948,7 +3771,7 @@ func (s *snapmgrTestSuite) TestEnableDoesNotEnableAgain(c *C) {
 		Active:   true,
 	})
 
-	ts, err := snapstate.Enable(s.state, "some-snap")
+	ts, err := snapstate.Enable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" already enabled`)
 	c.Assert(ts, IsNil)
 }
@@ -2972,7 +3795,7 @@ func (s *snapmgrTestSuite) TestEnableRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("enable", "enable a snap")
-	ts, err := snapstate.Enable(s.state, "some-snap")
+	ts, err := snapstate.Enable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -3024,7 +3847,7 @@ func (s *snapmgrTestSuite) TestDisableRunThrough(c *C) {
 	})
 
 	chg := s.state.NewChange("disable", "disable a snap")
-	ts, err := snapstate.Disable(s.state, "some-snap")
+	ts, err := snapstate.Disable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, IsNil)
 	chg.AddAll(ts)
 
@@ -3067,7 +3890,7 @@ func (s *snapmgrTestSuite) TestDisableDoesNotEnableAgain(c *C) {
 		Active:   false,
 	})
 
-	ts, err := snapstate.Disable(s.state, "some-snap")
+	ts, err := snapstate.Disable(s.state, "some-snap", snapstate.Flags{})
 	c.Assert(err, ErrorMatches, `snap "some-snap" already disabled`)
 	c.Assert(ts, IsNil)
 }
@@ -4015,7 +4838,7 @@ func (s *snapmgrTestSuite) TestInstallMany(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
 
-	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, 0)
+	installed, tts, err := snapstate.InstallMany(s.state, []string{"one", "two"}, 0, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 2)
 	c.Check(installed, DeepEquals, []string{"one", "two"})
@@ -4044,15 +4867,17 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 		Current: snap.R(1),
 	})
 
-	removed, tts, err := snapstate.RemoveMany(s.state, []string{"one", "two"})
+	removed, tts, err := snapstate.RemoveMany(s.state, []string{"one", "two"}, snapstate.Flags{})
 	c.Assert(err, IsNil)
 	c.Assert(tts, HasLen, 2)
 	c.Check(removed, DeepEquals, []string{"one", "two"})
 
-	c.Assert(s.state.TaskCount(), Equals, 6*2)
+	c.Assert(s.state.TaskCount(), Equals, 8*2)
 	for _, ts := range tts {
 		c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
+			"run-hook",
 			"stop-snap-services",
+			"remove-aliases",
 			"unlink-snap",
 			"remove-profiles",
 			"clear-snap",
@@ -4062,9 +4887,16 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	}
 }
 
-func taskWithKind(ts *state.TaskSet, kind string) *state.Task {
+func hookTaskWithName(c *C, ts *state.TaskSet, hook string) *state.Task {
 	for _, task := range ts.Tasks() {
-		if task.Kind() == kind {
+		if task.Kind() != "run-hook" {
+			continue
+		}
+		var hooksup struct {
+			Hook string `json:"hook"`
+		}
+		c.Assert(task.Get("hook-setup", &hooksup), IsNil)
+		if hooksup.Hook == hook {
 			return task
 		}
 	}
@@ -4111,8 +4943,8 @@ func (s *snapmgrTestSuite) TestGadgetDefaults(c *C) {
 	c.Assert(err, IsNil)
 
 	var m map[string]interface{}
-	runHook := taskWithKind(ts, "run-hook")
-	c.Assert(runHook.Kind(), Equals, "run-hook")
+	runHook := hookTaskWithName(c, ts, "configure")
+	c.Assert(runHook, NotNil)
 	err = runHook.Get("hook-context", &m)
 	c.Assert(err, IsNil)
 	c.Assert(m["patch"], DeepEquals, map[string]interface{}{"key": "value"})
@@ -4140,8 +4972,8 @@ func (s *snapmgrTestSuite) TestGadgetDefaultsInstalled(c *C) {
 	c.Assert(err, IsNil)
 
 	var m map[string]interface{}
-	runHook := taskWithKind(ts, "run-hook")
-	c.Assert(runHook.Kind(), Equals, "run-hook")
+	runHook := hookTaskWithName(c, ts, "configure")
+	c.Assert(runHook, NotNil)
 	err = runHook.Get("hook-context", &m)
 	c.Assert(err, Equals, state.ErrNoState)
 }
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
@@ -34,8 +35,8 @@ import (
 	"github.com/snapcore/snapd/store"
 )
 
-func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.TaskSet, error) {
-	if err := checkChangeConflict(st, snapsup.Name(), snapst); err != nil {
+func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, ignoreChangeID string) (*state.TaskSet, error) {
+	if err := CheckChangeConflictIgnoringChange(st, snapsup.InstanceName(), snapst, ignoreChangeID); err != nil {
 		return nil, err
 	}
 
@@ -45,6 +46,12 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 		revisionStr = fmt.Sprintf(" (%s)", targetRevision)
 	}
 
+	if !snapst.HasCurrent() {
+		if err := checkSnapAliasConflict(st, snapsup.InstanceName()); err != nil {
+			return nil, err
+		}
+	}
+
 	// check if we already have the revision locally (alters tasks)
 	revisionIsLocal := snapst.LastIndex(targetRevision) >= 0
 
@@ -55,11 +62,19 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 		prepare = st.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q%s"), snapsup.SnapPath, revisionStr))
 	} else {
 		fromStore = true
-		prepare = st.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q%s from channel %q"), snapsup.Name(), revisionStr, snapsup.Channel))
+		prepare = st.NewTask("download-snap", fmt.Sprintf(i18n.G("Download snap %q%s from channel %q"), snapsup.InstanceName(), revisionStr, snapsup.Channel))
 	}
 	prepare.Set("snap-setup", snapsup)
 
 	tasks := []*state.Task{prepare}
+	if (snapsup.Base != "" || len(snapsup.Prereq) > 0) && !revisionIsLocal {
+		// make sure the base and the content providers are
+		// installed before the snap
+		prereq := st.NewTask("prerequisites", fmt.Sprintf(i18n.G("Ensure prerequisites for %q are available"), snapsup.InstanceName()))
+		prereq.Set("snap-setup", snapsup)
+		prepare.WaitFor(prereq)
+		tasks = []*state.Task{prereq, prepare}
+	}
 	addTask := func(t *state.Task) {
 		t.Set("snap-setup-task", prepare.ID())
 		t.WaitFor(prev)
@@ -69,48 +84,79 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 
 	if fromStore {
 		// fetch and check assertions
-		checkAsserts := st.NewTask("validate-snap", fmt.Sprintf(i18n.G("Fetch and check assertions for snap %q%s"), snapsup.Name(), revisionStr))
+		checkAsserts := st.NewTask("validate-snap", fmt.Sprintf(i18n.G("Fetch and check assertions for snap %q%s"), snapsup.InstanceName(), revisionStr))
 		addTask(checkAsserts)
 		prev = checkAsserts
 	}
 
 	// mount
 	if !revisionIsLocal {
-		mount := st.NewTask("mount-snap", fmt.Sprintf(i18n.G("Mount snap %q%s"), snapsup.Name(), revisionStr))
+		mount := st.NewTask("mount-snap", fmt.Sprintf(i18n.G("Mount snap %q%s"), snapsup.InstanceName(), revisionStr))
 		addTask(mount)
 		prev = mount
 	}
 
+	// run the refresh hooks when updating an installed snap,
+	// otherwise the install hook further down
+	runRefreshHooks := snapst.HasCurrent() && !snapsup.Flags.Revert
+	if runRefreshHooks {
+		preRefreshHook := SetupPreRefreshHook(st, snapsup.InstanceName())
+		addTask(preRefreshHook)
+		prev = preRefreshHook
+	}
+
 	if snapst.Active {
 		// unlink-current-snap (will stop services for copy-data)
-		stop := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), snapsup.Name()))
+		stop := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), snapsup.InstanceName()))
 		addTask(stop)
 		prev = stop
 
-		unlink := st.NewTask("unlink-current-snap", fmt.Sprintf(i18n.G("Make current revision for snap %q unavailable"), snapsup.Name()))
+		removeAliases := st.NewTask("remove-aliases", fmt.Sprintf(i18n.G("Remove aliases for snap %q"), snapsup.InstanceName()))
+		addTask(removeAliases)
+		prev = removeAliases
+
+		unlink := st.NewTask("unlink-current-snap", fmt.Sprintf(i18n.G("Make current revision for snap %q unavailable"), snapsup.InstanceName()))
 		addTask(unlink)
 		prev = unlink
 	}
 
 	// copy-data (needs stopped services by unlink)
 	if !snapsup.Flags.Revert {
-		copyData := st.NewTask("copy-snap-data", fmt.Sprintf(i18n.G("Copy snap %q data"), snapsup.Name()))
+		copyData := st.NewTask("copy-snap-data", fmt.Sprintf(i18n.G("Copy snap %q data"), snapsup.InstanceName()))
 		addTask(copyData)
 		prev = copyData
 	}
 
 	// security
-	setupSecurity := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q%s security profiles"), snapsup.Name(), revisionStr))
+	setupSecurity := st.NewTask("setup-profiles", fmt.Sprintf(i18n.G("Setup snap %q%s security profiles"), snapsup.InstanceName(), revisionStr))
 	addTask(setupSecurity)
 	prev = setupSecurity
 
 	// finalize (wrappers+current symlink)
-	linkSnap := st.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q%s available to the system"), snapsup.Name(), revisionStr))
+	linkSnap := st.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q%s available to the system"), snapsup.InstanceName(), revisionStr))
 	addTask(linkSnap)
 	prev = linkSnap
 
+	// aliases
+	setupAliases := st.NewTask("setup-aliases", fmt.Sprintf(i18n.G("Setup snap %q%s aliases"), snapsup.InstanceName(), revisionStr))
+	addTask(setupAliases)
+	prev = setupAliases
+
+	if runRefreshHooks {
+		postRefreshHook := SetupPostRefreshHook(st, snapsup.InstanceName())
+		addTask(postRefreshHook)
+		prev = postRefreshHook
+	}
+
+	// only run the install hook when installing the snap for the first time
+	if !snapst.HasCurrent() {
+		installHook := SetupInstallHook(st, snapsup.InstanceName())
+		addTask(installHook)
+		prev = installHook
+	}
+
 	// run new serices
-	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q%s services"), snapsup.Name(), revisionStr))
+	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q%s services"), snapsup.InstanceName(), revisionStr))
 	addTask(startSnapServices)
 	prev = startSnapServices
 
@@ -127,7 +173,7 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 				// but don't discard this one; its' the thing we're switching to!
 				continue
 			}
-			ts := removeInactiveRevision(st, snapsup.Name(), si.Revision)
+			ts := removeInactiveRevision(st, snapsup.InstanceName(), si.Revision)
 			ts.WaitFor(prev)
 			tasks = append(tasks, ts.Tasks()...)
 			prev = tasks[len(tasks)-1]
@@ -146,16 +192,22 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 			}
 		}
 
-		// normal garbage collect
-		for i := 0; i <= currentIndex-2; i++ {
+		// normal garbage collect: keep the revision being
+		// installed plus the most recent retain-1 ones, which always
+		// includes the current one so that it can be reverted to
+		retain, err := refreshRetain(st)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i <= currentIndex-(retain-1); i++ {
 			si := seq[i]
-			ts := removeInactiveRevision(st, snapsup.Name(), si.Revision)
+			ts := removeInactiveRevision(st, snapsup.InstanceName(), si.Revision)
 			ts.WaitFor(prev)
 			tasks = append(tasks, ts.Tasks()...)
 			prev = tasks[len(tasks)-1]
 		}
 
-		addTask(st.NewTask("cleanup", fmt.Sprintf("Clean up %q%s install", snapsup.Name(), revisionStr)))
+		addTask(st.NewTask("cleanup", fmt.Sprintf("Clean up %q%s install", snapsup.InstanceName(), revisionStr)))
 	}
 
 	var defaults map[string]interface{}
@@ -175,29 +227,99 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup) (*state.T
 	}
 
 	installSet := state.NewTaskSet(tasks...)
-	configSet := Configure(st, snapsup.Name(), defaults)
+	configSet := Configure(st, snapsup.InstanceName(), defaults)
 	configSet.WaitAll(installSet)
 	installSet.AddAll(configSet)
 
 	return installSet, nil
 }
 
+// DefaultRefreshRetain is the number of revisions of a snap kept on
+// disk, including the current one, when refresh.retain is not set.
+const DefaultRefreshRetain = 3
+
+// minRefreshRetain keeps the previous revision around for "snap revert".
+const minRefreshRetain = 2
+
+// refreshRetain returns how many revisions of a snap should be kept
+// according to the core snap's refresh.retain configuration.
+func refreshRetain(st *state.State) (int, error) {
+	retain := DefaultRefreshRetain
+	if err := NewConfig(st).GetMaybe("core", "refresh.retain", &retain); err != nil {
+		return 0, err
+	}
+	if retain < minRefreshRetain {
+		logger.Noticef("cannot use refresh.retain configuration of %d, using %d", retain, minRefreshRetain)
+		retain = minRefreshRetain
+	}
+	return retain, nil
+}
+
 var Configure = func(st *state.State, snapName string, patch map[string]interface{}) *state.TaskSet {
 	panic("internal error: snapstate.Configure is unset")
 }
 
-func checkChangeConflict(st *state.State, snapName string, snapst *SnapState) error {
-	for _, task := range st.Tasks() {
-		k := task.Kind()
+var SetupInstallHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupInstallHook is unset")
+}
+
+var SetupPreRefreshHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupPreRefreshHook is unset")
+}
+
+var SetupPostRefreshHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupPostRefreshHook is unset")
+}
+
+var SetupRemoveHook = func(st *state.State, snapName string) *state.Task {
+	panic("internal error: snapstate.SetupRemoveHook is unset")
+}
+
+// conflictingKinds are the kinds of tasks that alter a snap, operating
+// on a snap while any of them is in progress for it is a conflict.
+var conflictingKinds = []string{"link-snap", "unlink-snap", "alias", "unalias", "switch-snap-channel"}
+
+func init() {
+	for _, kind := range conflictingKinds {
+		state.RegisterAffectedFunc(kind, affectedSnaps)
+	}
+}
+
+// affectedSnaps returns the name of the snap a task operates on.
+func affectedSnaps(task *state.Task) ([]string, error) {
+	snapsup, err := TaskSnapSetup(task)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: cannot obtain snap setup from task: %s", task.Summary())
+	}
+	return []string{snapsup.InstanceName()}, nil
+}
+
+// CheckChangeConflict ensures that for the given snapName no other
+// changes that alter the snap (like remove, install, refresh) are in
+// progress. It also ensures that snapst (if not nil) did not get
+// modified. If a conflict is detected an error is returned, a
+// *state.ChangeConflictError when it's due to a change in progress.
+//
+// It's exported so other managers can check for conflicts before
+// operating on a snap. Note that the state must be locked by the caller.
+func CheckChangeConflict(st *state.State, snapName string, snapst *SnapState) error {
+	return CheckChangeConflictIgnoringChange(st, snapName, snapst, "")
+}
+
+// CheckChangeConflictIgnoringChange is like CheckChangeConflict but
+// does not consider the change with the given ID as conflicting, for
+// adding tasks to it.
+func CheckChangeConflictIgnoringChange(st *state.State, snapName string, snapst *SnapState, ignoreChangeID string) error {
+	task, err := st.ConflictingTask(snapName, ignoreChangeID)
+	if err != nil {
+		return err
+	}
+	if task != nil {
 		chg := task.Change()
-		if (k == "link-snap" || k == "unlink-snap") && (chg == nil || !chg.Status().Ready()) {
-			snapsup, err := TaskSnapSetup(task)
-			if err != nil {
-				return fmt.Errorf("internal error: cannot obtain snap setup from task: %s", task.Summary())
-			}
-			if snapsup.Name() == snapName {
-				return fmt.Errorf("snap %q has changes in progress", snapName)
-			}
+		return &state.ChangeConflictError{
+			Message:    fmt.Sprintf("snap %q has changes in progress", snapName),
+			ChangeKind: chg.Kind(),
+			ChangeID:   chg.ID(),
 		}
 	}
 
@@ -245,6 +367,14 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, channel string, flags
 		}
 	}
 
+	rev, err := revisionForValidationSets(st, name, si.Revision, "install")
+	if err != nil {
+		return nil, err
+	}
+	if rev != si.Revision {
+		return nil, fmt.Errorf("cannot install snap %q from a file: validation sets require revision %s", name, rev)
+	}
+
 	snapsup := &SnapSetup{
 		SideInfo: si,
 		SnapPath: path,
@@ -252,7 +382,7 @@ func InstallPath(st *state.State, si *snap.SideInfo, path, channel string, flags
 		Flags:    flags.ForSnapSetup(),
 	}
 
-	return doInstall(st, &snapst, snapsup)
+	return doInstall(st, &snapst, snapsup, flags.IgnoreConflictsWith)
 }
 
 // TryPath returns a set of tasks for trying a snap from a file path.
@@ -266,9 +396,18 @@ func TryPath(st *state.State, name, path string, flags Flags) (*state.TaskSet, e
 // Install returns a set of tasks for installing snap.
 // Note that the state must be locked by the caller.
 func Install(st *state.State, name, channel string, revision snap.Revision, userID int, flags Flags) (*state.TaskSet, error) {
+	snapName, instanceKey := snap.SplitInstanceName(name)
+	if instanceKey != "" {
+		if err := snap.ValidateInstanceName(name); err != nil {
+			return nil, err
+		}
+	}
 	if channel == "" {
 		channel = "stable"
 	}
+	if err := validateChannel(channel); err != nil {
+		return nil, err
+	}
 
 	var snapst SnapState
 	err := Get(st, name, &snapst)
@@ -279,7 +418,12 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 		return nil, fmt.Errorf("snap %q already installed", name)
 	}
 
-	snapInfo, err := snapInfo(st, name, channel, revision, userID, flags)
+	revision, err = revisionForValidationSets(st, name, revision, "install")
+	if err != nil {
+		return nil, err
+	}
+
+	snapInfo, err := snapInfo(st, snapName, channel, revision, userID, flags)
 	if err != nil {
 		return nil, err
 	}
@@ -290,9 +434,42 @@ func Install(st *state.State, name, channel string, revision snap.Revision, user
 		Flags:        flags.ForSnapSetup(),
 		DownloadInfo: &snapInfo.DownloadInfo,
 		SideInfo:     &snapInfo.SideInfo,
+		InstanceKey:  instanceKey,
+		Base:         snapInfo.Base,
+		Prereq:       defaultContentPlugProviders(st, snapInfo),
 	}
 
-	return doInstall(st, &snapst, snapsup)
+	return doInstall(st, &snapst, snapsup, flags.IgnoreConflictsWith)
+}
+
+// defaultContentPlugProviders returns the sorted names of the default
+// providers of the content plugs of the given snap that are not
+// installed yet.
+func defaultContentPlugProviders(st *state.State, info *snap.Info) []string {
+	seen := make(map[string]bool)
+	var providers []string
+	for _, plug := range info.Plugs {
+		if plug.Interface != "content" {
+			continue
+		}
+		dprovider, ok := plug.Attrs["default-provider"].(string)
+		if !ok || dprovider == "" {
+			continue
+		}
+		// the provider can be given as "<snap>:<slot>"
+		name := strings.SplitN(dprovider, ":", 2)[0]
+		if seen[name] || name == info.InstanceName() {
+			continue
+		}
+		seen[name] = true
+		var snapst SnapState
+		if err := Get(st, name, &snapst); err == nil && snapst.HasCurrent() {
+			continue
+		}
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+	return providers
 }
 
 // contains determines whether the given string is contained in the
@@ -313,6 +490,9 @@ func RefreshCandidates(st *state.State, user *auth.UserState) ([]*snap.Info, err
 	return updates, err
 }
 
+// refreshCandidates returns the store refreshes for the installed snaps,
+// optionally restricted to the given instance names, together with the
+// states of the refreshed snaps keyed by instance name.
 func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([]*snap.Info, map[string]*SnapState, error) {
 	snapStates, err := All(st)
 	if err != nil {
@@ -321,7 +501,7 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 
 	sort.Strings(names)
 
-	stateByID := make(map[string]*SnapState, len(snapStates))
+	stateByInstanceName := make(map[string]*SnapState, len(snapStates))
 	candidatesInfo := make([]*store.RefreshCandidate, 0, len(snapStates))
 	for _, snapst := range snapStates {
 		if len(names) == 0 && (snapst.TryMode || snapst.DevMode) {
@@ -346,11 +526,11 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 			continue
 		}
 
-		if len(names) > 0 && !contains(names, snapInfo.Name()) {
+		if len(names) > 0 && !contains(names, snapInfo.InstanceName()) {
 			continue
 		}
 
-		stateByID[snapInfo.SnapID] = snapst
+		stateByInstanceName[snapInfo.InstanceName()] = snapst
 
 		// get confinement preference from the snapstate
 		candidateInfo := &store.RefreshCandidate{
@@ -358,9 +538,10 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 			Channel: snapst.Channel,
 			DevMode: snapst.DevModeAllowed(),
 
-			SnapID:   snapInfo.SnapID,
-			Revision: snapInfo.Revision,
-			Epoch:    snapInfo.Epoch,
+			SnapID:      snapInfo.SnapID,
+			Revision:    snapInfo.Revision,
+			Epoch:       snapInfo.Epoch,
+			InstanceKey: snapInfo.InstanceKey,
 		}
 
 		if len(names) == 0 {
@@ -379,7 +560,7 @@ func refreshCandidates(st *state.State, names []string, user *auth.UserState) ([
 		return nil, nil, err
 	}
 
-	return updates, stateByID, nil
+	return updates, stateByInstanceName, nil
 }
 
 // ValidateRefreshes allows to hook validation into the handling of refresh candidates.
@@ -388,13 +569,13 @@ var ValidateRefreshes func(st *state.State, refreshes []*snap.Info, userID int)
 // UpdateMany updates everything from the given list of names that the
 // store says is updateable. If the list is empty, update everything.
 // Note that the state must be locked by the caller.
-func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+func UpdateMany(st *state.State, names []string, userID int, flags Flags) ([]string, []*state.TaskSet, error) {
 	user, err := userFromUserID(st, userID)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	updates, stateByID, err := refreshCandidates(st, names, user)
+	updates, stateByInstanceName, err := refreshCandidates(st, names, user)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -411,31 +592,46 @@ func UpdateMany(st *state.State, names []string, userID int) ([]string, []*state
 		}
 	}
 
+	if len(updates) != 0 {
+		updates, err = filterUpdatesForValidationSets(st, updates)
+		if err != nil {
+			if len(names) != 0 {
+				return nil, nil, err
+			}
+			logger.Noticef("%v", err)
+		}
+	}
+
 	updated := make([]string, 0, len(updates))
 	tasksets := make([]*state.TaskSet, 0, len(updates))
 	for _, update := range updates {
-		snapst := stateByID[update.SnapID]
-
+		snapst := stateByInstanceName[update.InstanceName()]
+		if snapst == nil {
+			return nil, nil, fmt.Errorf("internal error: store returned an update for snap %q that was not asked about", update.InstanceName())
+		}
 		snapsup := &SnapSetup{
 			Channel:      snapst.Channel,
 			UserID:       userID,
 			Flags:        snapst.Flags.ForSnapSetup(),
 			DownloadInfo: &update.DownloadInfo,
 			SideInfo:     &update.SideInfo,
+			InstanceKey:  snapst.InstanceKey,
+			Base:         update.Base,
+			Prereq:       defaultContentPlugProviders(st, update),
 		}
 
-		ts, err := doInstall(st, snapst, snapsup)
+		ts, err := doInstall(st, snapst, snapsup, flags.IgnoreConflictsWith)
 		if err != nil {
 			if len(names) == 0 {
 				// doing "refresh all", just skip this snap
-				logger.Noticef("cannot refresh snap %q: %v", update.Name(), err)
+				logger.Noticef("cannot refresh snap %q: %v", snapsup.InstanceName(), err)
 				continue
 			}
 			return nil, nil, err
 		}
 		ts.JoinLane(st.NewLane())
 
-		updated = append(updated, update.Name())
+		updated = append(updated, snapsup.InstanceName())
 		tasksets = append(tasksets, ts)
 	}
 
@@ -463,6 +659,18 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 	if channel == "" {
 		channel = snapst.Channel
 	}
+	if err := validateChannel(channel); err != nil {
+		return nil, err
+	}
+
+	requested := revision
+	revision, err = revisionForValidationSets(st, name, revision, "refresh")
+	if err != nil {
+		return nil, err
+	}
+	if requested.Unset() && revision == snapst.Current {
+		return nil, fmt.Errorf("snap %q has no updates available: it is pinned at revision %s by validation sets", name, revision)
+	}
 
 	info, err := infoForUpdate(st, &snapst, name, channel, revision, userID, flags)
 	if err != nil {
@@ -475,9 +683,12 @@ func Update(st *state.State, name, channel string, revision snap.Revision, userI
 		Flags:        flags.ForSnapSetup(),
 		DownloadInfo: &info.DownloadInfo,
 		SideInfo:     &info.SideInfo,
+		InstanceKey:  snapst.InstanceKey,
+		Base:         info.Base,
+		Prereq:       defaultContentPlugProviders(st, info),
 	}
 
-	return doInstall(st, &snapst, snapsup)
+	return doInstall(st, &snapst, snapsup, flags.IgnoreConflictsWith)
 }
 
 func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, revision snap.Revision, userID int, flags Flags) (*snap.Info, error) {
@@ -504,7 +715,7 @@ func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, rev
 	}
 	if sideInfo == nil {
 		// refresh from given revision from store
-		return snapInfo(st, name, channel, revision, userID, flags)
+		return snapInfo(st, snap.InstanceSnap(name), channel, revision, userID, flags)
 	}
 
 	// refresh-to-local
@@ -512,7 +723,7 @@ func infoForUpdate(st *state.State, snapst *SnapState, name, channel string, rev
 }
 
 // Enable sets a snap to the active state
-func Enable(st *state.State, name string) (*state.TaskSet, error) {
+func Enable(st *state.State, name string, flags Flags) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err == state.ErrNoState {
@@ -526,30 +737,81 @@ func Enable(st *state.State, name string) (*state.TaskSet, error) {
 		return nil, fmt.Errorf("snap %q already enabled", name)
 	}
 
-	if err := checkChangeConflict(st, name, nil); err != nil {
+	if err := CheckChangeConflictIgnoringChange(st, name, nil, flags.IgnoreConflictsWith); err != nil {
 		return nil, err
 	}
 
 	snapsup := &SnapSetup{
-		SideInfo: snapst.CurrentSideInfo(),
+		SideInfo:    snapst.CurrentSideInfo(),
+		InstanceKey: snapst.InstanceKey,
 	}
 
-	prepareSnap := st.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q (%s)"), snapsup.Name(), snapst.Current))
+	prepareSnap := st.NewTask("prepare-snap", fmt.Sprintf(i18n.G("Prepare snap %q (%s)"), snapsup.InstanceName(), snapst.Current))
 	prepareSnap.Set("snap-setup", &snapsup)
 
-	linkSnap := st.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q (%s) available to the system"), snapsup.Name(), snapst.Current))
+	linkSnap := st.NewTask("link-snap", fmt.Sprintf(i18n.G("Make snap %q (%s) available to the system"), snapsup.InstanceName(), snapst.Current))
 	linkSnap.Set("snap-setup", &snapsup)
 	linkSnap.WaitFor(prepareSnap)
 
-	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q (%s) services"), snapsup.Name(), snapst.Current))
+	setupAliases := st.NewTask("setup-aliases", fmt.Sprintf(i18n.G("Setup snap %q (%s) aliases"), snapsup.InstanceName(), snapst.Current))
+	setupAliases.Set("snap-setup", &snapsup)
+	setupAliases.WaitFor(linkSnap)
+
+	startSnapServices := st.NewTask("start-snap-services", fmt.Sprintf(i18n.G("Start snap %q (%s) services"), snapsup.InstanceName(), snapst.Current))
 	startSnapServices.Set("snap-setup", &snapsup)
-	startSnapServices.WaitFor(linkSnap)
+	startSnapServices.WaitFor(setupAliases)
+
+	return state.NewTaskSet(prepareSnap, linkSnap, setupAliases, startSnapServices), nil
+}
+
+// Switch switches the snap to track the given channel, without
+// refreshing it. The next refresh will follow the new channel.
+// Note that the state must be locked by the caller.
+func Switch(st *state.State, name, channel string, flags Flags) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, name, &snapst)
+	if err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot find snap %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !snapst.HasCurrent() {
+		return nil, fmt.Errorf("cannot find snap %q", name)
+	}
+
+	if err := validateChannel(channel); err != nil {
+		return nil, err
+	}
+
+	if err := CheckChangeConflictIgnoringChange(st, name, nil, flags.IgnoreConflictsWith); err != nil {
+		return nil, err
+	}
+
+	snapsup := &SnapSetup{
+		SideInfo:    snapst.CurrentSideInfo(),
+		Channel:     channel,
+		InstanceKey: snapst.InstanceKey,
+	}
+
+	switchSnap := st.NewTask("switch-snap-channel", fmt.Sprintf(i18n.G("Switch snap %q from channel %q to %q"), name, snapst.Channel, channel))
+	switchSnap.Set("snap-setup", &snapsup)
+
+	return state.NewTaskSet(switchSnap), nil
+}
 
-	return state.NewTaskSet(prepareSnap, linkSnap, startSnapServices), nil
+// validateChannel checks that the given channel, if set, is a valid
+// channel name.
+func validateChannel(channel string) error {
+	if channel == "" {
+		return nil
+	}
+	_, err := snap.ParseChannel(channel)
+	return err
 }
 
 // Disable sets a snap to the inactive state
-func Disable(st *state.State, name string) (*state.TaskSet, error) {
+func Disable(st *state.State, name string, flags Flags) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err == state.ErrNoState {
@@ -570,32 +832,38 @@ func Disable(st *state.State, name string) (*state.TaskSet, error) {
 		return nil, fmt.Errorf("snap %q cannot be disabled", name)
 	}
 
-	if err := checkChangeConflict(st, name, nil); err != nil {
+	if err := CheckChangeConflictIgnoringChange(st, name, nil, flags.IgnoreConflictsWith); err != nil {
 		return nil, err
 	}
 
 	snapsup := &SnapSetup{
 		SideInfo: &snap.SideInfo{
-			RealName: name,
+			RealName: snap.InstanceSnap(name),
 			Revision: snapst.Current,
 		},
+		InstanceKey: snapst.InstanceKey,
 	}
 
-	stopSnapServices := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q (%s) services"), snapsup.Name(), snapst.Current))
+	stopSnapServices := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q (%s) services"), snapsup.InstanceName(), snapst.Current))
 	stopSnapServices.Set("snap-setup", &snapsup)
-	unlinkSnap := st.NewTask("unlink-snap", fmt.Sprintf(i18n.G("Make snap %q (%s) unavailable to the system"), snapsup.Name(), snapst.Current))
+	removeAliases := st.NewTask("remove-aliases", fmt.Sprintf(i18n.G("Remove aliases for snap %q"), snapsup.InstanceName()))
+	removeAliases.Set("snap-setup-task", stopSnapServices.ID())
+	removeAliases.WaitFor(stopSnapServices)
+	unlinkSnap := st.NewTask("unlink-snap", fmt.Sprintf(i18n.G("Make snap %q (%s) unavailable to the system"), snapsup.InstanceName(), snapst.Current))
 	unlinkSnap.Set("snap-setup-task", stopSnapServices.ID())
-	unlinkSnap.WaitFor(stopSnapServices)
+	unlinkSnap.WaitFor(removeAliases)
 
-	return state.NewTaskSet(stopSnapServices, unlinkSnap), nil
+	return state.NewTaskSet(stopSnapServices, removeAliases, unlinkSnap), nil
 }
 
 func removeInactiveRevision(st *state.State, name string, revision snap.Revision) *state.TaskSet {
+	snapName, instanceKey := snap.SplitInstanceName(name)
 	snapsup := SnapSetup{
 		SideInfo: &snap.SideInfo{
-			RealName: name,
+			RealName: snapName,
 			Revision: revision,
 		},
+		InstanceKey: instanceKey,
 	}
 
 	clearData := st.NewTask("clear-snap", fmt.Sprintf(i18n.G("Remove data for snap %q (%s)"), name, revision))
@@ -637,9 +905,13 @@ func canDisable(st *snap.Info) bool {
 	return true
 }
 
+// AutomaticSnapshot allows to hook in taking a snapshot of the data of a
+// snap before it's removed; it's set by snapshotstate.
+var AutomaticSnapshot func(st *state.State, snapName string) (*state.TaskSet, error)
+
 // Remove returns a set of tasks for removing snap.
 // Note that the state must be locked by the caller.
-func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSet, error) {
+func Remove(st *state.State, name string, revision snap.Revision, flags Flags) (*state.TaskSet, error) {
 	var snapst SnapState
 	err := Get(st, name, &snapst)
 	if err != nil && err != state.ErrNoState {
@@ -650,13 +922,16 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		return nil, fmt.Errorf("cannot find snap %q", name)
 	}
 
-	if err := checkChangeConflict(st, name, nil); err != nil {
+	if err := CheckChangeConflictIgnoringChange(st, name, nil, flags.IgnoreConflictsWith); err != nil {
 		return nil, err
 	}
 
 	active := snapst.Active
 	var removeAll bool
 	if revision.Unset() {
+		if err := checkRemoveValidationSets(st, name); err != nil {
+			return nil, err
+		}
 		removeAll = true
 		revision = snapst.Current
 	} else {
@@ -687,13 +962,23 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 	if !canRemove(info, active) {
 		return nil, fmt.Errorf("snap %q is not removable", name)
 	}
+	if info.Type == snap.TypeBase && (removeAll || len(snapst.Sequence) == 1) {
+		users, err := baseUsers(st, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			return nil, fmt.Errorf("snap %q is not removable: it is the base of %s", name, strings.Join(users, ", "))
+		}
+	}
 
 	// main/current SnapSetup
 	snapsup := SnapSetup{
 		SideInfo: &snap.SideInfo{
-			RealName: name,
+			RealName: snap.InstanceSnap(name),
 			Revision: revision,
 		},
+		InstanceKey: snapst.InstanceKey,
 	}
 
 	// trigger remove
@@ -709,22 +994,41 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		chain = ts
 	}
 
+	if active && (removeAll || len(snapst.Sequence) == 1) {
+		// let the snap clean up while it can still run
+		removeHook := SetupRemoveHook(st, name)
+		addNext(state.NewTaskSet(removeHook))
+	}
+
 	if active { // unlink
 		stopSnapServices := st.NewTask("stop-snap-services", fmt.Sprintf(i18n.G("Stop snap %q services"), name))
 		stopSnapServices.Set("snap-setup", snapsup)
 
+		removeAliases := st.NewTask("remove-aliases", fmt.Sprintf(i18n.G("Remove aliases for snap %q"), name))
+		removeAliases.Set("snap-setup-task", stopSnapServices.ID())
+		removeAliases.WaitFor(stopSnapServices)
+
 		unlink := st.NewTask("unlink-snap", fmt.Sprintf(i18n.G("Make snap %q unavailable to the system"), name))
 		unlink.Set("snap-setup-task", stopSnapServices.ID())
-		unlink.WaitFor(stopSnapServices)
+		unlink.WaitFor(removeAliases)
 
 		removeSecurity := st.NewTask("remove-profiles", fmt.Sprintf(i18n.G("Remove security profile for snap %q (%s)"), name, revision))
 		removeSecurity.WaitFor(unlink)
 		removeSecurity.Set("snap-setup-task", stopSnapServices.ID())
 
-		addNext(state.NewTaskSet(stopSnapServices, unlink, removeSecurity))
+		addNext(state.NewTaskSet(stopSnapServices, removeAliases, unlink, removeSecurity))
 	}
 
 	if removeAll || len(snapst.Sequence) == 1 {
+		// keep a copy of the data before it's gone
+		if AutomaticSnapshot != nil {
+			ts, err := AutomaticSnapshot(st, name)
+			if err != nil {
+				return nil, err
+			}
+			addNext(ts)
+		}
+
 		seq := snapst.Sequence
 		for i := len(seq) - 1; i >= 0; i-- {
 			si := seq[i]
@@ -734,8 +1038,9 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 		discardConns := st.NewTask("discard-conns", fmt.Sprintf(i18n.G("Discard interface connections for snap %q (%s)"), name, revision))
 		discardConns.Set("snap-setup", &SnapSetup{
 			SideInfo: &snap.SideInfo{
-				RealName: name,
+				RealName: snap.InstanceSnap(name),
 			},
+			InstanceKey: snapst.InstanceKey,
 		})
 		addNext(state.NewTaskSet(discardConns))
 
@@ -746,6 +1051,27 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 	return full, nil
 }
 
+// baseUsers returns the sorted names of the installed snaps that use
+// the given base.
+func baseUsers(st *state.State, base string) ([]string, error) {
+	snapStates, err := All(st)
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for name, snapst := range snapStates {
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			continue
+		}
+		if info.Base == base {
+			users = append(users, fmt.Sprintf("%q", name))
+		}
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
 // Revert returns a set of tasks for reverting to the previous version of the snap.
 // Note that the state must be locked by the caller.
 func Revert(st *state.State, name string, flags Flags) (*state.TaskSet, error) {
@@ -781,12 +1107,16 @@ func RevertToRevision(st *state.State, name string, rev snap.Revision, flags Fla
 	if i < 0 {
 		return nil, fmt.Errorf("cannot find revision %s for snap %q", rev, name)
 	}
+	if _, err := revisionForValidationSets(st, name, rev, "revert"); err != nil {
+		return nil, err
+	}
 	flags.Revert = true
 	snapsup := &SnapSetup{
-		SideInfo: snapst.Sequence[i],
-		Flags:    flags.ForSnapSetup(),
+		SideInfo:    snapst.Sequence[i],
+		Flags:       flags.ForSnapSetup(),
+		InstanceKey: snapst.InstanceKey,
 	}
-	return doInstall(st, &snapst, snapsup)
+	return doInstall(st, &snapst, snapsup, flags.IgnoreConflictsWith)
 }
 
 // Info returns the information about the snap with given name and revision.
@@ -942,11 +1272,11 @@ func KernelInfo(st *state.State) (*snap.Info, error) {
 
 // InstallMany installs everything from the given list of names.
 // Note that the state must be locked by the caller.
-func InstallMany(st *state.State, names []string, userID int) ([]string, []*state.TaskSet, error) {
+func InstallMany(st *state.State, names []string, userID int, flags Flags) ([]string, []*state.TaskSet, error) {
 	installed := make([]string, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
 	for i, name := range names {
-		ts, err := Install(st, name, "", snap.R(0), userID, Flags{})
+		ts, err := Install(st, name, "", snap.R(0), userID, flags)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -959,11 +1289,11 @@ func InstallMany(st *state.State, names []string, userID int) ([]string, []*stat
 
 // RemoveMany removes everything from the given list of names.
 // Note that the state must be locked by the caller.
-func RemoveMany(st *state.State, names []string) ([]string, []*state.TaskSet, error) {
+func RemoveMany(st *state.State, names []string, flags Flags) ([]string, []*state.TaskSet, error) {
 	removed := make([]string, len(names))
 	tasksets := make([]*state.TaskSet, 0, len(names))
 	for i, name := range names {
-		ts, err := Remove(st, name, snap.R(0))
+		ts, err := Remove(st, name, snap.R(0), flags)
 		if err != nil {
 			return nil, nil, err
 		}
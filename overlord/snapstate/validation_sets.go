@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// EnforcedValidationSets allows to hook getting the validation sets
+// whose constraints on snaps must not be broken.
+var EnforcedValidationSets func(st *state.State) (*snapasserts.ValidationSets, error)
+
+// enforcedConstraints returns what the enforced validation sets
+// require from the given snap, or nil if they don't mention it. The
+// sets constrain snaps, the constraints apply to all of their
+// instances alike.
+func enforcedConstraints(st *state.State, instanceName string) (*snapasserts.SnapConstraints, error) {
+	if EnforcedValidationSets == nil {
+		return nil, nil
+	}
+	sets, err := EnforcedValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	snapName, _ := snap.SplitInstanceName(instanceName)
+	return sets.Constraints(snapName), nil
+}
+
+// revisionForValidationSets checks that the enforced validation sets
+// allow the snap to be installed, refreshed or reverted, at the given
+// revision.
+// If revision is unset the revision they require, if any, is returned.
+func revisionForValidationSets(st *state.State, name string, revision snap.Revision, action string) (snap.Revision, error) {
+	cstrs, err := enforcedConstraints(st, name)
+	if err != nil || cstrs == nil {
+		return revision, err
+	}
+	sets := strings.Join(cstrs.Sets, ", ")
+	if cstrs.Presence == asserts.PresenceInvalid {
+		return revision, fmt.Errorf("cannot %s snap %q: it is invalid according to validation sets %s", action, name, sets)
+	}
+	if cstrs.Revision.Unset() {
+		return revision, nil
+	}
+	if revision.Unset() {
+		return cstrs.Revision, nil
+	}
+	if revision != cstrs.Revision {
+		return revision, fmt.Errorf("cannot %s snap %q at revision %s: validation sets %s require revision %s", action, name, revision, sets, cstrs.Revision)
+	}
+	return revision, nil
+}
+
+// checkRemoveValidationSets checks that the enforced validation sets
+// allow the snap to be removed. A required snap can be removed as
+// long as another instance of it stays installed.
+func checkRemoveValidationSets(st *state.State, name string) error {
+	cstrs, err := enforcedConstraints(st, name)
+	if err != nil || cstrs == nil {
+		return err
+	}
+	if cstrs.Presence != asserts.PresenceRequired {
+		return nil
+	}
+	snapStates, err := All(st)
+	if err != nil {
+		return err
+	}
+	snapName, _ := snap.SplitInstanceName(name)
+	for instanceName := range snapStates {
+		if otherName, _ := snap.SplitInstanceName(instanceName); instanceName != name && otherName == snapName {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot remove snap %q: it is required by validation sets %s", name, strings.Join(cstrs.Sets, ", "))
+}
+
+// filterUpdatesForValidationSets drops the updates to revisions other
+// than the ones required by the enforced validation sets.
+func filterUpdatesForValidationSets(st *state.State, updates []*snap.Info) (filtered []*snap.Info, err error) {
+	if EnforcedValidationSets == nil {
+		return updates, nil
+	}
+	sets, err := EnforcedValidationSets(st)
+	if err != nil {
+		return nil, err
+	}
+	filtered = make([]*snap.Info, 0, len(updates))
+	var errs []string
+	for _, update := range updates {
+		cstrs := sets.Constraints(update.Name())
+		if cstrs != nil && !cstrs.Revision.Unset() && update.Revision != cstrs.Revision {
+			errs = append(errs, fmt.Sprintf("snap %q is pinned at revision %s by validation sets %s", update.InstanceName(), cstrs.Revision, strings.Join(cstrs.Sets, ", ")))
+			continue
+		}
+		filtered = append(filtered, update)
+	}
+	if len(errs) != 0 {
+		err = fmt.Errorf("cannot refresh: %s", strings.Join(errs, "; "))
+	}
+	return filtered, err
+}
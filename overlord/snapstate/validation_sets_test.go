@@ -0,0 +1,245 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var validationSetsSigning *assertstest.StoreStack
+
+func (s *snapmgrTestSuite) mockEnforcedValidationSets(c *C, snaps ...interface{}) {
+	if validationSetsSigning == nil {
+		rootPrivKey, _ := assertstest.GenerateKey(1024)
+		storePrivKey, _ := assertstest.GenerateKey(752)
+		validationSetsSigning = assertstest.NewStoreStack("can0nical", rootPrivKey, storePrivKey)
+	}
+	a, err := validationSetsSigning.Sign(asserts.ValidationSetType, map[string]interface{}{
+		"authority-id": "can0nical",
+		"account-id":   "can0nical",
+		"series":       "16",
+		"name":         "base-set",
+		"sequence":     "1",
+		"snaps":        snaps,
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+
+	sets := snapasserts.NewValidationSets()
+	sets.Add(a.(*asserts.ValidationSet))
+	snapstate.EnforcedValidationSets = func(st *state.State) (*snapasserts.ValidationSets, error) {
+		return sets, nil
+	}
+}
+
+func validationSetSnap(name, presence, revision string) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":     name,
+		"id":       name + "-id",
+		"presence": presence,
+	}
+	if revision != "" {
+		m["revision"] = revision
+	}
+	return m
+}
+
+func (s *snapmgrTestSuite) TestInstallValidationSetsRequiredRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "7"))
+
+	ts, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.Revision(), Equals, snap.R(7))
+}
+
+func (s *snapmgrTestSuite) TestInstallValidationSetsWrongRevision(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "7"))
+
+	_, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(11), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot install snap "some-snap" at revision 11: validation sets can0nical/base-set=1 require revision 7`)
+}
+
+func (s *snapmgrTestSuite) TestInstallValidationSetsInvalid(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "invalid", ""))
+
+	_, err := snapstate.Install(s.state, "some-snap", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot install snap "some-snap": it is invalid according to validation sets can0nical/base-set=1`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateValidationSetsPinned(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "7"))
+
+	_, err := snapstate.Update(s.state, "some-snap", "", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `snap "some-snap" has no updates available: it is pinned at revision 7 by validation sets`)
+
+	_, err = snapstate.Update(s.state, "some-snap", "", snap.R(11), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot refresh snap "some-snap" at revision 11: validation sets can0nical/base-set=1 require revision 7`)
+}
+
+func (s *snapmgrTestSuite) TestUpdateManyValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		},
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "1"))
+
+	// refresh all => the pinned snap is skipped
+	updates, tts, err := snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(tts, HasLen, 0)
+	c.Check(updates, HasLen, 0)
+
+	// refresh some-snap => report error
+	_, _, err = snapstate.UpdateMany(s.state, []string{"some-snap"}, 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot refresh: snap "some-snap" is pinned at revision 1 by validation sets can0nical/base-set=1`)
+
+	// a matching update goes through
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "11"))
+	updates, tts, err = snapstate.UpdateMany(s.state, nil, 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	c.Check(tts, HasLen, 1)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+}
+
+func (s *snapmgrTestSuite) TestRemoveValidationSetsRequired(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", ""))
+	_, err := snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot remove snap "some-snap": it is required by validation sets can0nical/base-set=1`)
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "optional", ""))
+	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRevertValidationSets(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: []*snap.SideInfo{
+			{RealName: "some-snap", Revision: snap.R(2)},
+			{RealName: "some-snap", Revision: snap.R(7)},
+		},
+		Current:  snap.R(7),
+		SnapType: "app",
+	})
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "7"))
+	_, err := snapstate.Revert(s.state, "some-snap", snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot revert snap "some-snap" at revision 2: validation sets can0nical/base-set=1 require revision 7`)
+	_, err = snapstate.RevertToRevision(s.state, "some-snap", snap.R(2), snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot revert snap "some-snap" at revision 2: validation sets can0nical/base-set=1 require revision 7`)
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "2"))
+	_, err = snapstate.Revert(s.state, "some-snap", snapstate.Flags{})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestValidationSetsParallelInstances(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockEnforcedValidationSets(c, validationSetSnap("some-snap", "required", "7"))
+
+	// the constraints on the snap apply to its instances
+	ts, err := snapstate.Install(s.state, "some-snap_instance", "some-channel", snap.R(0), 0, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapsup, err := snapstate.TaskSnapSetup(ts.Tasks()[0])
+	c.Assert(err, IsNil)
+	c.Check(snapsup.InstanceName(), Equals, "some-snap_instance")
+	c.Check(snapsup.Revision(), Equals, snap.R(7))
+
+	_, err = snapstate.Install(s.state, "some-snap_other", "some-channel", snap.R(11), 0, snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot install snap "some-snap_other" at revision 11: validation sets can0nical/base-set=1 require revision 7`)
+
+	for _, name := range []string{"some-snap", "some-snap_instance"} {
+		_, instanceKey := snap.SplitInstanceName(name)
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: []*snap.SideInfo{
+				{RealName: "some-snap", Revision: snap.R(7)},
+			},
+			Current:     snap.R(7),
+			SnapType:    "app",
+			InstanceKey: instanceKey,
+		})
+	}
+
+	// a required snap can lose an instance, but not the last one
+	_, err = snapstate.Remove(s.state, "some-snap_instance", snap.R(0), snapstate.Flags{})
+	c.Assert(err, IsNil)
+	snapstate.Set(s.state, "some-snap_instance", nil)
+	_, err = snapstate.Remove(s.state, "some-snap", snap.R(0), snapstate.Flags{})
+	c.Assert(err, ErrorMatches, `cannot remove snap "some-snap": it is required by validation sets can0nical/base-set=1`)
+}
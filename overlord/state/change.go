@@ -126,8 +126,11 @@ type Change struct {
 	lanes   int
 	ready   chan struct{}
 
+	waitChangeIDs []string
+
 	spawnTime time.Time
 	readyTime time.Time
+	atTime    time.Time
 }
 
 func newChange(state *State, id, kind, summary string) *Change {
@@ -153,8 +156,11 @@ type marshalledChange struct {
 	TaskIDs []string                    `json:"task-ids,omitempty"`
 	Lanes   int                         `json:"lanes,omitempty"`
 
+	WaitChangeIDs []string `json:"wait-change-ids,omitempty"`
+
 	SpawnTime time.Time  `json:"spawn-time"`
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
+	AtTime    *time.Time `json:"at-time,omitempty"`
 }
 
 // MarshalJSON makes Change a json.Marshaller
@@ -164,6 +170,10 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 	if !c.readyTime.IsZero() {
 		readyTime = &c.readyTime
 	}
+	var atTime *time.Time
+	if !c.atTime.IsZero() {
+		atTime = &c.atTime
+	}
 	return json.Marshal(marshalledChange{
 		ID:      c.id,
 		Kind:    c.kind,
@@ -174,8 +184,11 @@ func (c *Change) MarshalJSON() ([]byte, error) {
 		TaskIDs: c.taskIDs,
 		Lanes:   c.lanes,
 
+		WaitChangeIDs: c.waitChangeIDs,
+
 		SpawnTime: c.spawnTime,
 		ReadyTime: readyTime,
+		AtTime:    atTime,
 	})
 }
 
@@ -201,11 +214,15 @@ func (c *Change) UnmarshalJSON(data []byte) error {
 	c.data = custData
 	c.taskIDs = unmarshalled.TaskIDs
 	c.lanes = unmarshalled.Lanes
+	c.waitChangeIDs = unmarshalled.WaitChangeIDs
 	c.ready = make(chan struct{})
 	c.spawnTime = unmarshalled.SpawnTime
 	if unmarshalled.ReadyTime != nil {
 		c.readyTime = *unmarshalled.ReadyTime
 	}
+	if unmarshalled.AtTime != nil {
+		c.atTime = *unmarshalled.AtTime
+	}
 	return nil
 }
 
@@ -234,7 +251,7 @@ func (c *Change) Summary() string {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (c *Change) Set(key string, value interface{}) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.data.set(key, value)
 }
 
@@ -294,11 +311,30 @@ func (c *Change) Status() Status {
 
 // SetStatus sets the change status, overriding the default behavior (see Status method).
 func (c *Change) SetStatus(s Status) {
-	c.state.writing()
+	c.state.writingChange(c.id)
+	var old Status
+	if len(c.state.changeStatusHandlers) > 0 {
+		old = c.Status()
+	}
 	c.status = s
 	if s.Ready() {
 		c.markReady()
 	}
+	if len(c.state.changeStatusHandlers) > 0 {
+		c.notifyStatus(old)
+	}
+}
+
+// notifyStatus calls the change status handlers if the status of the
+// change is no longer old.
+func (c *Change) notifyStatus(old Status) {
+	new := c.Status()
+	if new == old {
+		return
+	}
+	for _, f := range c.state.changeStatusHandlers {
+		f(c, old, new)
+	}
 }
 
 func (c *Change) markReady() {
@@ -310,6 +346,18 @@ func (c *Change) markReady() {
 	if c.readyTime.IsZero() {
 		c.readyTime = timeNow()
 	}
+	// changes waiting for this one may start now
+	for _, other := range c.state.changes {
+		if other.IsReady() {
+			continue
+		}
+		for _, id := range other.waitChangeIDs {
+			if id == c.id {
+				c.state.EnsureBefore(0)
+				return
+			}
+		}
+	}
 }
 
 // Ready returns a channel that is closed the first time the change becomes ready.
@@ -323,6 +371,7 @@ func (c *Change) taskStatusChanged(t *Task, old, new Status) {
 	if old.Ready() == new.Ready() {
 		return
 	}
+	c.state.writingChange(c.id)
 	for _, tid := range c.taskIDs {
 		task := c.state.tasks[tid]
 		if task != t && !task.status.Ready() {
@@ -373,6 +422,7 @@ func (c *Change) taskCleanChanged() {
 			return
 		}
 	}
+	c.state.writingChange(c.id)
 	c.clean = true
 }
 
@@ -388,6 +438,64 @@ func (c *Change) ReadyTime() time.Time {
 	return c.readyTime
 }
 
+// AtTime returns the time at which the change is scheduled to start. A zero time means no special schedule.
+func (c *Change) AtTime() time.Time {
+	c.state.reading()
+	return c.atTime
+}
+
+// At schedules the change, if it's not ready, to start no earlier than when: none of its tasks will run before then. If when is the zero time any previous special scheduling is suppressed.
+//
+// A scheduled change is in progress as far as conflicts are concerned from the moment it is created, see ConflictingTask: until it has run, any other change operating on the same entities, like the same snaps, is refused with a ChangeConflictError.
+func (c *Change) At(when time.Time) {
+	c.state.writingChange(c.id)
+	iszero := when.IsZero()
+	if c.Status().Ready() && !iszero {
+		return
+	}
+	c.atTime = when
+	if !iszero {
+		d := when.Sub(timeNow())
+		if d < 0 {
+			d = 0
+		}
+		c.state.EnsureBefore(d)
+	}
+}
+
+// IsScheduled returns whether the change is waiting for the time it was scheduled at, with none of its tasks having started yet.
+func (c *Change) IsScheduled() bool {
+	c.state.reading()
+	if c.atTime.IsZero() || !timeNow().Before(c.atTime) {
+		return false
+	}
+	return c.Status() == DoStatus
+}
+
+// WaitFor registers another change as a requirement for this one to
+// start: none of its tasks will run before the other change is ready,
+// and the change fails if the other one does not end up done.
+func (c *Change) WaitFor(another *Change) {
+	c.state.writingChange(c.id)
+	if another == c {
+		return
+	}
+	c.waitChangeIDs = addOnce(c.waitChangeIDs, another.id)
+}
+
+// WaitChanges returns the changes this change waits for. Changes that
+// were pruned from the state are not included.
+func (c *Change) WaitChanges() []*Change {
+	c.state.reading()
+	var changes []*Change
+	for _, id := range c.waitChangeIDs {
+		if chg := c.state.changes[id]; chg != nil {
+			changes = append(changes, chg)
+		}
+	}
+	return changes
+}
+
 // changeError holds a set of task errors.
 type changeError struct {
 	errors []taskError
@@ -448,10 +556,11 @@ func (c *Change) State() *State {
 // AddTask registers a task as required for the state change to
 // be accomplished.
 func (c *Change) AddTask(t *Task) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	if t.change != "" {
 		panic(fmt.Sprintf("internal error: cannot add one %q task to multiple changes", t.Kind()))
 	}
+	c.state.writingTask(t.id)
 	t.change = c.id
 	c.taskIDs = addOnce(c.taskIDs, t.ID())
 }
@@ -459,7 +568,7 @@ func (c *Change) AddTask(t *Task) {
 // AddAll registers all tasks in the set as required for the state
 // change to be accomplished.
 func (c *Change) AddAll(ts *TaskSet) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	for _, t := range ts.tasks {
 		c.AddTask(t)
 	}
@@ -474,7 +583,7 @@ func (c *Change) Tasks() []*Task {
 // Abort flags the change for cancellation, whether in progress or not.
 // Cancellation will proceed at the next ensure pass.
 func (c *Change) Abort() {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	tasks := make([]*Task, len(c.taskIDs))
 	for i, tid := range c.taskIDs {
 		tasks[i] = c.state.tasks[tid]
@@ -486,7 +595,7 @@ func (c *Change) Abort() {
 // except for tasks that are also in a healthy lane (not aborted, and not waiting
 // on aborted).
 func (c *Change) AbortLanes(lanes []int) {
-	c.state.writing()
+	c.state.writingChange(c.id)
 	c.abortLanes(lanes, make(map[int]bool))
 }
 
@@ -20,6 +20,9 @@
 package state_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
@@ -186,6 +189,33 @@ func (cs *changeSuite) TestStatusDerivedFromTasks(c *C) {
 	}
 }
 
+func (cs *changeSuite) TestStatusHandlers(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	var seen []string
+	st.AddChangeStatusHandler(func(chg *state.Change, old, new state.Status) {
+		seen = append(seen, fmt.Sprintf("%s:%s->%s", chg.ID(), old, new))
+	})
+
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+
+	t1.SetStatus(state.DoingStatus)
+	t1.SetStatus(state.DoneStatus)
+	// still Do as far as the change goes
+	c.Check(seen, DeepEquals, []string{"1:Do->Doing", "1:Doing->Do"})
+
+	t2.SetStatus(state.DoneStatus)
+	chg.SetStatus(state.ErrorStatus)
+	chg.SetStatus(state.ErrorStatus)
+	c.Check(seen, DeepEquals, []string{"1:Do->Doing", "1:Doing->Do", "1:Do->Done", "1:Done->Error"})
+}
+
 func (cs *changeSuite) TestCloseReadyOnExplicitStatus(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -323,6 +353,7 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 		func() { chg.AddTask(nil) },
 		func() { chg.AddAll(nil) },
 		func() { chg.UnmarshalJSON(nil) },
+		func() { chg.At(time.Now()) },
 	}
 
 	reads := []func(){
@@ -334,6 +365,8 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 		func() { chg.MarshalJSON() },
 		func() { chg.SpawnTime() },
 		func() { chg.ReadyTime() },
+		func() { chg.AtTime() },
+		func() { chg.IsScheduled() },
 	}
 
 	for i, f := range reads {
@@ -353,6 +386,65 @@ func (cs *changeSuite) TestMethodEntrance(c *C) {
 	}
 }
 
+func (cs *changeSuite) TestAt(c *C) {
+	b := new(fakeStateBackend)
+	b.ensureBefore = time.Hour
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("download", "1..."))
+
+	now := time.Now()
+	restore := state.MockTime(now)
+	defer restore()
+	when := now.Add(10 * time.Second)
+	chg.At(when)
+
+	c.Check(chg.AtTime().Equal(when), Equals, true)
+	c.Check(b.ensureBefore, Equals, 10*time.Second)
+	c.Check(chg.IsScheduled(), Equals, true)
+
+	state.MockTime(when)
+	c.Check(chg.IsScheduled(), Equals, false)
+
+	chg.At(time.Time{})
+	c.Check(chg.AtTime().IsZero(), Equals, true)
+}
+
+func (cs *changeSuite) TestAtReadyNop(c *C) {
+	b := new(fakeStateBackend)
+	b.ensureBefore = time.Hour
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	chg.SetStatus(state.DoneStatus)
+
+	chg.At(time.Now().Add(10 * time.Second))
+
+	c.Check(chg.AtTime().IsZero(), Equals, true)
+	c.Check(b.ensureBefore, Equals, time.Hour)
+}
+
+func (cs *changeSuite) TestIsScheduledOnlyBeforeStarting(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "1...")
+	chg.AddTask(t)
+	chg.At(time.Now().Add(time.Hour))
+	c.Check(chg.IsScheduled(), Equals, true)
+
+	chg.Abort()
+	c.Check(chg.Status(), Equals, state.HoldStatus)
+	c.Check(chg.IsScheduled(), Equals, false)
+}
+
 func (cs *changeSuite) TestAbort(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -589,3 +681,66 @@ func (ts *taskRunnerSuite) TestAbortLanes(c *C) {
 		c.Assert(strings.Join(obtained, " "), Equals, strings.Join(expected, " "), Commentf("setup: %s", test.setup))
 	}
 }
+
+func (cs *changeSuite) TestWaitFor(c *C) {
+	b := new(fakeStateBackend)
+	b.ensureBefore = time.Hour
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+
+	chg1 := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "1...")
+	chg1.AddTask(t1)
+	chg2 := st.NewChange("connect", "...")
+	chg2.AddTask(st.NewTask("connect", "2..."))
+
+	chg2.WaitFor(chg1)
+	chg2.WaitFor(chg1)
+	chg2.WaitFor(chg2)
+	c.Check(chg2.WaitChanges(), DeepEquals, []*state.Change{chg1})
+	c.Check(chg1.WaitChanges(), HasLen, 0)
+
+	// the waiting change gets a chance to run once the other is ready
+	t1.SetStatus(state.DoneStatus)
+	c.Check(b.ensureBefore, Equals, time.Duration(0))
+}
+
+func (cs *changeSuite) TestWaitForSerialization(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	chg1 := st.NewChange("install", "...")
+	chg1.AddTask(st.NewTask("download", "1..."))
+	chg2 := st.NewChange("connect", "...")
+	chg2.AddTask(st.NewTask("connect", "2..."))
+	chg2.WaitFor(chg1)
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st2.Lock()
+	defer st2.Unlock()
+
+	waits := st2.Change(chg2.ID()).WaitChanges()
+	c.Assert(waits, HasLen, 1)
+	c.Check(waits[0].ID(), Equals, chg1.ID())
+}
+
+func (cs *changeSuite) TestWaitChangesSkipsPruned(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg1 := st.NewChange("install", "...")
+	chg1.SetStatus(state.DoneStatus)
+	state.MockChangeTimes(chg1, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	chg2 := st.NewChange("connect", "...")
+	chg2.AddTask(st.NewTask("connect", "2..."))
+	chg2.WaitFor(chg1)
+
+	st.Prune(time.Hour, time.Hour)
+	c.Assert(st.Change(chg1.ID()), IsNil)
+	c.Check(chg2.WaitChanges(), HasLen, 0)
+}
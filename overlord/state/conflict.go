@@ -0,0 +1,92 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"fmt"
+)
+
+// AffectedFunc returns the entities, for example snap names, that a
+// task affects.
+type AffectedFunc func(t *Task) ([]string, error)
+
+var affectedFuncs = make(map[string]AffectedFunc)
+
+// RegisterAffectedFunc registers f to report the entities affected by
+// tasks of the given kind. While their change is in progress such
+// tasks conflict with any other attempt at operating on the same
+// entities, see ConflictingTask.
+//
+// It's meant to be called from the init function of the package
+// owning the task kind.
+func RegisterAffectedFunc(kind string, f AffectedFunc) {
+	if _, ok := affectedFuncs[kind]; ok {
+		panic(fmt.Sprintf("internal error: affected entities of task kind %q already registered", kind))
+	}
+	affectedFuncs[kind] = f
+}
+
+// ChangeConflictError is returned when an operation cannot be
+// performed because a change in progress affects the same entities.
+type ChangeConflictError struct {
+	Message    string
+	ChangeKind string
+	ChangeID   string
+}
+
+func (e *ChangeConflictError) Error() string {
+	return e.Message
+}
+
+// ConflictingTask returns a task of a registered kind affecting the
+// given entity whose change is not ready yet, or nil if there is no
+// such task. Changes scheduled for later, see Change.At, count from
+// the moment they are created. Tasks in the changes with the given IDs are not
+// considered, which is how a change being built to wait for another
+// one, see Change.WaitFor, doesn't conflict with it.
+func (s *State) ConflictingTask(entity string, ignoreChangeIDs ...string) (*Task, error) {
+	s.reading()
+NextTask:
+	for _, t := range s.Tasks() {
+		affected := affectedFuncs[t.Kind()]
+		if affected == nil {
+			continue
+		}
+		chg := t.Change()
+		if chg == nil || chg.Status().Ready() {
+			continue
+		}
+		for _, id := range ignoreChangeIDs {
+			if chg.ID() == id {
+				continue NextTask
+			}
+		}
+		entities, err := affected(t)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entities {
+			if e == entity {
+				return t, nil
+			}
+		}
+	}
+	return nil, nil
+}
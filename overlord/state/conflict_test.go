@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type conflictSuite struct {
+	restore func()
+}
+
+var _ = Suite(&conflictSuite{})
+
+func (cs *conflictSuite) SetUpTest(c *C) {
+	cs.restore = state.MockAffectedFuncs(map[string]state.AffectedFunc{})
+	state.RegisterAffectedFunc("link", func(t *state.Task) ([]string, error) {
+		var name string
+		if err := t.Get("name", &name); err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	})
+}
+
+func (cs *conflictSuite) TearDownTest(c *C) {
+	cs.restore()
+}
+
+func (cs *conflictSuite) TestRegisterTwicePanics(c *C) {
+	f := func(*state.Task) ([]string, error) { return nil, nil }
+	c.Check(func() { state.RegisterAffectedFunc("link", f) }, PanicMatches, `internal error: affected entities of task kind "link" already registered`)
+}
+
+func (cs *conflictSuite) TestConflictingTask(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("link", "...")
+	t.Set("name", "foo")
+	chg.AddTask(t)
+	other := st.NewTask("unregistered", "...")
+	other.Set("name", "bar")
+	chg.AddTask(other)
+
+	conflicting, err := st.ConflictingTask("foo")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, Equals, t)
+
+	conflicting, err = st.ConflictingTask("bar")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, IsNil)
+
+	// tasks not linked to a change don't conflict
+	unlinked := st.NewTask("link", "...")
+	unlinked.Set("name", "baz")
+	conflicting, err = st.ConflictingTask("baz")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, IsNil)
+
+	conflicting, err = st.ConflictingTask("foo", chg.ID())
+	c.Assert(err, IsNil)
+	c.Check(conflicting, IsNil)
+
+	chg.SetStatus(state.DoneStatus)
+	conflicting, err = st.ConflictingTask("foo")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, IsNil)
+}
+
+func (cs *conflictSuite) TestConflictingTaskError(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("install", "...")
+	chg.AddTask(st.NewTask("link", "..."))
+
+	_, err := st.ConflictingTask("foo")
+	c.Check(err, Equals, state.ErrNoState)
+}
+
+func (cs *conflictSuite) TestConflictingTaskIgnoringChanges(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg1 := st.NewChange("install", "...")
+	t1 := st.NewTask("link", "...")
+	t1.Set("name", "foo")
+	chg1.AddTask(t1)
+	chg2 := st.NewChange("refresh", "...")
+	t2 := st.NewTask("link", "...")
+	t2.Set("name", "foo")
+	chg2.AddTask(t2)
+
+	conflicting, err := st.ConflictingTask("foo", chg1.ID())
+	c.Assert(err, IsNil)
+	c.Check(conflicting, Equals, t2)
+
+	conflicting, err = st.ConflictingTask("foo", chg1.ID(), chg2.ID())
+	c.Assert(err, IsNil)
+	c.Check(conflicting, IsNil)
+
+	// an empty ID ignores nothing
+	conflicting, err = st.ConflictingTask("foo", "")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, NotNil)
+}
+
+func (cs *conflictSuite) TestConflictingTaskScheduledChange(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	chg := st.NewChange("refresh", "...")
+	t := st.NewTask("link", "...")
+	t.Set("name", "foo")
+	chg.AddTask(t)
+	chg.At(time.Now().Add(time.Hour))
+	c.Assert(chg.IsScheduled(), Equals, true)
+
+	// a change scheduled for later conflicts already
+	conflicting, err := st.ConflictingTask("foo")
+	c.Assert(err, IsNil)
+	c.Check(conflicting, Equals, t)
+}
+
+func (cs *conflictSuite) TestChangeConflictError(c *C) {
+	var err error = &state.ChangeConflictError{Message: "snap \"foo\" has changes in progress", ChangeKind: "install", ChangeID: "1"}
+	c.Check(err, ErrorMatches, `snap "foo" has changes in progress`)
+}
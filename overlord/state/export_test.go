@@ -44,3 +44,12 @@ func MockTaskTimes(t *Task, spawnTime, readyTime time.Time) {
 	t.spawnTime = spawnTime
 	t.readyTime = readyTime
 }
+
+// MockAffectedFuncs replaces the registered affected entities functions.
+func MockAffectedFuncs(funcs map[string]AffectedFunc) (restore func()) {
+	old := affectedFuncs
+	affectedFuncs = funcs
+	return func() {
+		affectedFuncs = old
+	}
+}
@@ -42,6 +42,53 @@ type Backend interface {
 	RequestRestart(t RestartType)
 }
 
+// An IncrementalBackend is a Backend that can also checkpoint only the
+// entries of the state that were modified since the last checkpoint.
+type IncrementalBackend interface {
+	Backend
+	// StartIncremental has the backend checkpoint the state
+	// incrementally from now on, on top of data, which it
+	// checkpoints in full first.
+	StartIncremental(data []byte) error
+	// CheckpointDelta persists all of the delta or none of it. It
+	// can return ErrFullCheckpoint to have the whole state
+	// checkpointed with Checkpoint instead.
+	CheckpointDelta(delta *Delta) error
+}
+
+// ErrFullCheckpoint is returned by IncrementalBackend.CheckpointDelta
+// when it wants the whole state to be checkpointed instead.
+var ErrFullCheckpoint = errors.New("full checkpoint required")
+
+// A Delta holds the JSON encoding of the entries of the state modified
+// since the last checkpoint, with the entries removed since then
+// encoded as null. It is laid out like the whole state is.
+type Delta struct {
+	Data    map[string]json.RawMessage `json:"data,omitempty"`
+	Changes map[string]json.RawMessage `json:"changes,omitempty"`
+	Tasks   map[string]json.RawMessage `json:"tasks,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+}
+
+// modifiedEntries tracks which entries of the state were modified since
+// the last checkpoint.
+type modifiedEntries struct {
+	all     bool
+	data    map[string]bool
+	changes map[string]bool
+	tasks   map[string]bool
+}
+
+func (m *modifiedEntries) reset(all bool) {
+	m.all = all
+	m.data = make(map[string]bool)
+	m.changes = make(map[string]bool)
+	m.tasks = make(map[string]bool)
+}
+
 type customData map[string]*json.RawMessage
 
 func (data customData) get(key string, value interface{}) error {
@@ -95,13 +142,18 @@ type State struct {
 	tasks   map[string]*Task
 
 	modified bool
+	dirty    modifiedEntries
 
 	cache map[interface{}]interface{}
+
+	changeStatusHandlers []func(chg *Change, old, new Status)
+	taskStatusHandlers   []func(t *Task, old, new Status)
+	taskProgressHandlers []func(t *Task)
 }
 
 // New returns a new empty state.
 func New(backend Backend) *State {
-	return &State{
+	s := &State{
 		backend:  backend,
 		data:     make(customData),
 		changes:  make(map[string]*Change),
@@ -109,6 +161,8 @@ func New(backend Backend) *State {
 		modified: true,
 		cache:    make(map[interface{}]interface{}),
 	}
+	s.dirty.reset(true)
+	return s
 }
 
 // Modified returns whether the state was modified since the last checkpoint.
@@ -135,6 +189,21 @@ func (s *State) writing() {
 	}
 }
 
+func (s *State) writingData(key string) {
+	s.writing()
+	s.dirty.data[key] = true
+}
+
+func (s *State) writingChange(id string) {
+	s.writing()
+	s.dirty.changes[id] = true
+}
+
+func (s *State) writingTask(id string) {
+	s.writing()
+	s.dirty.tasks[id] = true
+}
+
 func (s *State) unlock() {
 	atomic.AddInt32(&s.muC, -1)
 	s.mu.Unlock()
@@ -186,6 +255,7 @@ func (s *State) UnmarshalJSON(data []byte) error {
 		chg.state = s
 		chg.finishUnmarshal()
 	}
+	s.dirty.reset(true)
 	return nil
 }
 
@@ -198,6 +268,76 @@ func (s *State) checkpointData() []byte {
 	return data
 }
 
+func (s *State) checkpointDelta() *Delta {
+	delta := &Delta{
+		Data:    make(map[string]json.RawMessage, len(s.dirty.data)),
+		Changes: make(map[string]json.RawMessage, len(s.dirty.changes)),
+		Tasks:   make(map[string]json.RawMessage, len(s.dirty.tasks)),
+
+		LastChangeId: s.lastChangeId,
+		LastTaskId:   s.lastTaskId,
+		LastLaneId:   s.lastLaneId,
+	}
+	for key := range s.dirty.data {
+		if entryJSON := s.data[key]; entryJSON != nil {
+			delta.Data[key] = *entryJSON
+		} else {
+			delta.Data[key] = nil
+		}
+	}
+	marshal := func(entry json.Marshaler) json.RawMessage {
+		data, err := entry.MarshalJSON()
+		if err != nil {
+			logger.Panicf("internal error: could not marshal state entry for checkpointing: %v", err)
+		}
+		return data
+	}
+	for id := range s.dirty.changes {
+		if chg := s.changes[id]; chg != nil {
+			delta.Changes[id] = marshal(chg)
+		} else {
+			delta.Changes[id] = nil
+		}
+	}
+	for id := range s.dirty.tasks {
+		if t := s.tasks[id]; t != nil {
+			delta.Tasks[id] = marshal(t)
+		} else {
+			delta.Tasks[id] = nil
+		}
+	}
+	return delta
+}
+
+// checkpoint checkpoints the state using the backend, only the modified
+// entries of it if the backend can do that.
+func (s *State) checkpoint() error {
+	if ib, ok := s.backend.(IncrementalBackend); ok && !s.dirty.all {
+		err := ib.CheckpointDelta(s.checkpointDelta())
+		if err != ErrFullCheckpoint {
+			return err
+		}
+	}
+	return s.backend.Checkpoint(s.checkpointData())
+}
+
+// StartIncrementalCheckpoints moves the state to being checkpointed
+// incrementally if its backend can do that, checkpointing it in full
+// first for the increments to apply on top of.
+func (s *State) StartIncrementalCheckpoints() error {
+	s.reading()
+	ib, ok := s.backend.(IncrementalBackend)
+	if !ok {
+		return nil
+	}
+	if err := ib.StartIncremental(s.checkpointData()); err != nil {
+		return err
+	}
+	s.modified = false
+	s.dirty.reset(false)
+	return nil
+}
+
 // unlock checkpoint retry parameters (5 mins of retries by default)
 var (
 	unlockCheckpointRetryMaxTime  = 5 * time.Minute
@@ -214,12 +354,12 @@ func (s *State) Unlock() {
 		return
 	}
 
-	data := s.checkpointData()
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
-		if err = s.backend.Checkpoint(data); err == nil {
+		if err = s.checkpoint(); err == nil {
 			s.modified = false
+			s.dirty.reset(false)
 			return
 		}
 		time.Sleep(unlockCheckpointRetryInterval)
@@ -255,7 +395,7 @@ func (s *State) Get(key string, value interface{}) error {
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (s *State) Set(key string, value interface{}) {
-	s.writing()
+	s.writingData(key)
 	s.data.set(key, value)
 }
 
@@ -266,6 +406,27 @@ func (s *State) Cached(key interface{}) interface{} {
 	return s.cache[key]
 }
 
+// AddChangeStatusHandler adds f to the functions called whenever the
+// status of a change changes. They are called with the state locked
+// and must not block.
+func (s *State) AddChangeStatusHandler(f func(chg *Change, old, new Status)) {
+	s.changeStatusHandlers = append(s.changeStatusHandlers, f)
+}
+
+// AddTaskStatusHandler adds f to the functions called whenever the
+// status of a task changes. They are called with the state locked and
+// must not block.
+func (s *State) AddTaskStatusHandler(f func(t *Task, old, new Status)) {
+	s.taskStatusHandlers = append(s.taskStatusHandlers, f)
+}
+
+// AddTaskProgressHandler adds f to the functions called whenever the
+// progress of a task is set. They are called with the state locked and
+// must not block.
+func (s *State) AddTaskProgressHandler(f func(t *Task)) {
+	s.taskProgressHandlers = append(s.taskProgressHandlers, f)
+}
+
 // Cache associates value with key for future consulting by managers.
 // The cached value is not persisted.
 func (s *State) Cache(key, value interface{}) {
@@ -279,9 +440,9 @@ func (s *State) Cache(key, value interface{}) {
 
 // NewChange adds a new change to the state.
 func (s *State) NewChange(kind, summary string) *Change {
-	s.writing()
 	s.lastChangeId++
 	id := strconv.Itoa(s.lastChangeId)
+	s.writingChange(id)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
 	return chg
@@ -314,9 +475,9 @@ func (s *State) Change(id string) *Change {
 // It usually will be registered with a Change using AddTask or
 // through a TaskSet.
 func (s *State) NewTask(kind, summary string) *Task {
-	s.writing()
 	s.lastTaskId++
 	id := strconv.Itoa(s.lastTaskId)
+	s.writingTask(id)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
 	return t
@@ -371,8 +532,13 @@ func (s *State) Prune(pruneWait, abortWait time.Duration) {
 		spawnTime := chg.SpawnTime()
 		readyTime := chg.ReadyTime()
 		if readyTime.IsZero() {
+			// changes scheduled for later only start aging then
+			if atTime := chg.AtTime(); atTime.After(spawnTime) {
+				spawnTime = atTime
+			}
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
+				s.writingChange(chg.ID())
 				delete(s.changes, chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				chg.Abort()
@@ -380,17 +546,18 @@ func (s *State) Prune(pruneWait, abortWait time.Duration) {
 			continue
 		}
 		if readyTime.Before(pruneLimit) {
-			s.writing()
 			for _, t := range chg.Tasks() {
+				s.writingTask(t.ID())
 				delete(s.tasks, t.ID())
 			}
+			s.writingChange(chg.ID())
 			delete(s.changes, chg.ID())
 		}
 	}
 	for tid, t := range s.tasks {
 		// TODO: this could be done more aggressively
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
-			s.writing()
+			s.writingTask(tid)
 			delete(s.tasks, tid)
 		}
 	}
@@ -408,6 +575,7 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	}
 	s.backend = backend
 	s.modified = false
+	s.dirty.reset(false)
 	s.cache = make(map[interface{}]interface{})
 	return s, err
 }
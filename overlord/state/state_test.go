@@ -21,6 +21,7 @@ package state_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -262,6 +263,167 @@ func (ss *stateSuite) TestImplicitCheckpointModifiedOnly(c *C) {
 	c.Assert(b.checkpoints, HasLen, 2)
 }
 
+type fakeIncrementalBackend struct {
+	fakeStateBackend
+	deltas    []*state.Delta
+	wantsFull bool
+	started   []byte
+}
+
+func (b *fakeIncrementalBackend) StartIncremental(data []byte) error {
+	b.started = data
+	return nil
+}
+
+func (b *fakeIncrementalBackend) CheckpointDelta(delta *state.Delta) error {
+	if b.wantsFull {
+		return state.ErrFullCheckpoint
+	}
+	b.deltas = append(b.deltas, delta)
+	return nil
+}
+
+func (ss *stateSuite) TestIncrementalCheckpoint(c *C) {
+	b := &fakeIncrementalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("baz", 1)
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+
+	// a new state is checkpointed as a whole
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.deltas, HasLen, 0)
+
+	st.Lock()
+	st.Set("baz", 2)
+	t2.SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.deltas, HasLen, 1)
+	delta := b.deltas[0]
+	c.Check(delta.Data, DeepEquals, map[string]json.RawMessage{"baz": json.RawMessage("2")})
+	c.Check(delta.Changes, HasLen, 0)
+	c.Assert(delta.Tasks, HasLen, 1)
+	c.Check(string(delta.Tasks[t2.ID()]), Matches, `.*"status":3.*`)
+	c.Check(delta.LastChangeId, Equals, 1)
+	c.Check(delta.LastTaskId, Equals, 2)
+
+	// the change's ready time changes along with the last task
+	st.Lock()
+	t1.SetStatus(state.DoneStatus)
+	t2.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	c.Assert(b.deltas, HasLen, 2)
+	delta = b.deltas[1]
+	c.Check(delta.Data, HasLen, 0)
+	c.Check(delta.Tasks, HasLen, 2)
+	c.Assert(delta.Changes, HasLen, 1)
+	c.Check(string(delta.Changes[chg.ID()]), Matches, `.*"ready-time":.*`)
+}
+
+func (ss *stateSuite) TestIncrementalCheckpointRemovals(c *C) {
+	b := &fakeIncrementalBackend{}
+	st := state.New(b)
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	st.Lock()
+	st.Prune(0, time.Hour)
+	st.Unlock()
+
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Changes, DeepEquals, map[string]json.RawMessage{chg.ID(): nil})
+	c.Check(b.deltas[0].Tasks, DeepEquals, map[string]json.RawMessage{t.ID(): nil})
+}
+
+func (ss *stateSuite) TestIncrementalCheckpointFullRequired(c *C) {
+	b := &fakeIncrementalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	b.wantsFull = true
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	c.Check(b.deltas, HasLen, 0)
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"foo":"bar".*`)
+
+	// and back to deltas
+	b.wantsFull = false
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Data, DeepEquals, map[string]json.RawMessage{"foo": json.RawMessage(`"baz"`)})
+}
+
+func (ss *stateSuite) TestStartIncrementalCheckpoints(c *C) {
+	b := &fakeIncrementalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	c.Assert(st.StartIncrementalCheckpoints(), IsNil)
+	st.Unlock()
+
+	// the backend started on top of the whole state, which needs no
+	// further checkpoint
+	c.Check(string(b.started), Matches, `.*"foo":"bar".*`)
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Check(b.deltas, HasLen, 0)
+
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Data, DeepEquals, map[string]json.RawMessage{"foo": json.RawMessage(`"baz"`)})
+}
+
+func (ss *stateSuite) TestStartIncrementalCheckpointsNotIncremental(c *C) {
+	b := &fakeStateBackend{}
+	st := state.New(b)
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.StartIncrementalCheckpoints(), IsNil)
+}
+
+func (ss *stateSuite) TestIncrementalCheckpointAfterRead(c *C) {
+	b := &fakeIncrementalBackend{}
+	st := state.New(nil)
+	st.Lock()
+	st.Set("foo", "bar")
+	data, err := json.Marshal(st)
+	st.Unlock()
+	c.Assert(err, IsNil)
+
+	st, err = state.ReadState(b, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	st.Lock()
+	st.Set("other", 1)
+	st.Unlock()
+
+	c.Check(b.checkpoints, HasLen, 0)
+	c.Assert(b.deltas, HasLen, 1)
+	c.Check(b.deltas[0].Data, DeepEquals, map[string]json.RawMessage{"other": json.RawMessage("1")})
+}
+
 func (ss *stateSuite) TestNewChangeAndChanges(c *C) {
 	st := state.New(nil)
 	st.Lock()
@@ -401,6 +563,8 @@ func (ss *stateSuite) TestNewTaskAndCheckpoint(c *C) {
 	t2.WaitFor(t1)
 	schedule := time.Now().Add(time.Hour)
 	t2.At(schedule)
+	chgSchedule := time.Now().Add(2 * time.Hour)
+	chg.At(chgSchedule)
 
 	// implicit checkpoint
 	st.Unlock()
@@ -457,6 +621,7 @@ func (ss *stateSuite) TestNewTaskAndCheckpoint(c *C) {
 
 	c.Check(task0_1.AtTime().IsZero(), Equals, true)
 	c.Check(task0_2.AtTime().Equal(schedule), Equals, true)
+	c.Check(chg0.AtTime().Equal(chgSchedule), Equals, true)
 }
 
 func (ss *stateSuite) TestEmptyStateDataAndCheckpointReadAndSet(c *C) {
@@ -750,6 +915,28 @@ func (ss *stateSuite) TestPrune(c *C) {
 	c.Check(st.TaskCount(), Equals, 3)
 }
 
+func (ss *stateSuite) TestPruneScheduledChange(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+	abortWait := 3 * time.Hour
+
+	chg := st.NewChange("scheduled", "...")
+	t := st.NewTask("foo", "...")
+	chg.AddTask(t)
+	state.MockChangeTimes(chg, now.Add(-abortWait), time.Time{})
+	chg.At(now.Add(time.Hour))
+
+	st.Prune(pruneWait, abortWait)
+
+	// it only starts aging once due
+	c.Assert(st.Change(chg.ID()), Equals, chg)
+	c.Check(chg.Status(), Equals, state.DoStatus)
+}
+
 func (ss *stateSuite) TestPruneEmptyChange(c *C) {
 	// Empty changes are a bit special because they start out on Hold
 	// which is a Ready status, but the change itself is not considered Ready
@@ -56,6 +56,9 @@ type Task struct {
 	readyTime time.Time
 
 	atTime time.Time
+
+	retries   int
+	lastError string
 }
 
 func newTask(state *State, id, kind, summary string) *Task {
@@ -88,6 +91,9 @@ type marshalledTask struct {
 	ReadyTime *time.Time `json:"ready-time,omitempty"`
 
 	AtTime *time.Time `json:"at-time,omitempty"`
+
+	Retries   int    `json:"retries,omitempty"`
+	LastError string `json:"last-error,omitempty"`
 }
 
 // MarshalJSON makes Task a json.Marshaller
@@ -119,6 +125,9 @@ func (t *Task) MarshalJSON() ([]byte, error) {
 		ReadyTime: readyTime,
 
 		AtTime: atTime,
+
+		Retries:   t.retries,
+		LastError: t.lastError,
 	})
 }
 
@@ -155,6 +164,8 @@ func (t *Task) UnmarshalJSON(data []byte) error {
 	if unmarshalled.AtTime != nil {
 		t.atTime = *unmarshalled.AtTime
 	}
+	t.retries = unmarshalled.Retries
+	t.lastError = unmarshalled.LastError
 	return nil
 }
 
@@ -184,16 +195,29 @@ func (t *Task) Status() Status {
 
 // SetStatus sets the task status, overriding the default behavior (see Status method).
 func (t *Task) SetStatus(new Status) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	old := t.status
+	oldObserved := t.Status()
+	chg := t.Change()
+	var oldChg Status
+	if chg != nil && len(t.state.changeStatusHandlers) > 0 {
+		oldChg = chg.Status()
+	}
 	t.status = new
 	if !old.Ready() && new.Ready() {
 		t.readyTime = timeNow()
 	}
-	chg := t.Change()
 	if chg != nil {
 		chg.taskStatusChanged(t, old, new)
 	}
+	if newObserved := t.Status(); newObserved != oldObserved {
+		for _, f := range t.state.taskStatusHandlers {
+			f(t, oldObserved, newObserved)
+		}
+	}
+	if chg != nil && len(t.state.changeStatusHandlers) > 0 {
+		chg.notifyStatus(oldChg)
+	}
 }
 
 // IsClean returns whether the task has been cleaned. See SetClean.
@@ -206,7 +230,7 @@ func (t *Task) IsClean() bool {
 //
 // Cleaning a task must only be done after the change is ready.
 func (t *Task) SetClean() {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	if t.clean {
 		return
 	}
@@ -246,7 +270,7 @@ func (t *Task) Progress() (label string, done, total int) {
 func (t *Task) SetProgress(label string, done, total int) {
 	// Only mark state for checkpointing if progress is final.
 	if total > 0 && done == total {
-		t.state.writing()
+		t.state.writingTask(t.id)
 	} else {
 		t.state.reading()
 	}
@@ -256,6 +280,29 @@ func (t *Task) SetProgress(label string, done, total int) {
 	} else {
 		t.progress = &progress{Label: label, Done: done, Total: total}
 	}
+	for _, f := range t.state.taskProgressHandlers {
+		f(t)
+	}
+}
+
+// Retries returns how many times the task was retried after failing, as
+// allowed by the retry policy for its kind. See TaskRunner.SetRetryPolicy.
+func (t *Task) Retries() int {
+	t.state.reading()
+	return t.retries
+}
+
+// LastError returns the error the last attempt at the task that was
+// retried failed with.
+func (t *Task) LastError() string {
+	t.state.reading()
+	return t.lastError
+}
+
+func (t *Task) addRetry(err error) {
+	t.state.writingTask(t.id)
+	t.retries++
+	t.lastError = err.Error()
 }
 
 // SpawnTime returns the time when the change was created.
@@ -321,20 +368,20 @@ func (t *Task) Log() []string {
 
 // Logf logs information about the progress of the task.
 func (t *Task) Logf(format string, args ...interface{}) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.addLog(LogInfo, format, args)
 }
 
 // Errorf logs error information about the progress of the task.
 func (t *Task) Errorf(format string, args ...interface{}) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.addLog(LogError, format, args)
 }
 
 // Set associates value with key for future consulting by managers.
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (t *Task) Set(key string, value interface{}) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.data.set(key, value)
 }
 
@@ -347,7 +394,7 @@ func (t *Task) Get(key string, value interface{}) error {
 
 // Clear disassociates the value from key.
 func (t *Task) Clear(key string) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	delete(t.data, key)
 }
 
@@ -362,7 +409,8 @@ func addOnce(set []string, s string) []string {
 
 // WaitFor registers another task as a requirement for t to make progress.
 func (t *Task) WaitFor(another *Task) {
-	t.state.writing()
+	t.state.writingTask(t.id)
+	t.state.writingTask(another.id)
 	t.waitTasks = addOnce(t.waitTasks, another.id)
 	another.haltTasks = addOnce(another.haltTasks, t.id)
 }
@@ -399,13 +447,13 @@ func (t *Task) Lanes() []int {
 // JoinLane registers the task in the provided lane. Tasks in different lanes
 // abort independently on errors. See Change.AbortLane for details.
 func (t *Task) JoinLane(lane int) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	t.lanes = append(t.lanes, lane)
 }
 
 // At schedules the task, if it's not ready, to happen no earlier than when, if when is the zero time any previous special scheduling is suppressed.
 func (t *Task) At(when time.Time) {
-	t.state.writing()
+	t.state.writingTask(t.id)
 	iszero := when.IsZero()
 	if t.Status().Ready() && !iszero {
 		return
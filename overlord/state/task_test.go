@@ -182,6 +182,29 @@ func (ts *taskSuite) TestProgressAndSetProgress(c *C) {
 	c.Check(tot, Equals, 42)
 }
 
+func (ts *taskSuite) TestStatusAndProgressHandlers(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	var seen []string
+	st.AddTaskStatusHandler(func(t *state.Task, old, new state.Status) {
+		seen = append(seen, fmt.Sprintf("%s:%s->%s", t.ID(), old, new))
+	})
+	st.AddTaskProgressHandler(func(t *state.Task) {
+		label, done, total := t.Progress()
+		seen = append(seen, fmt.Sprintf("%s:%s %d/%d", t.ID(), label, done, total))
+	})
+
+	t := st.NewTask("download", "1...")
+	t.SetStatus(state.DoingStatus)
+	t.SetStatus(state.DoingStatus)
+	t.SetProgress("snap", 2, 99)
+	t.SetStatus(state.DoneStatus)
+
+	c.Check(seen, DeepEquals, []string{"1:Do->Doing", "1:snap 2/99", "1:Doing->Done"})
+}
+
 func (ts *taskSuite) TestProgressDefaults(c *C) {
 	st := state.New(nil)
 	st.Lock()
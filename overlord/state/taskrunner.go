@@ -20,6 +20,7 @@
 package state
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -44,6 +45,36 @@ func (r *Retry) Error() string {
 	return "task should be retried"
 }
 
+// RetryPolicy describes how the tasks of a kind are retried when their
+// do handler fails, rather than failing the change.
+type RetryPolicy struct {
+	// MaxAttempts is how many times the handler is run at most,
+	// the first time included.
+	MaxAttempts int
+	// Backoff is how long to wait before the first retry, doubling
+	// for each further retry up to MaxBackoff, if that is set.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable decides whether an error is worth retrying after,
+	// nil meaning they all are.
+	Retryable func(err error) bool
+}
+
+// backoff returns how long to wait before the given retry.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.Backoff
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
 // TaskRunner controls the running of goroutines to execute known task kinds.
 type TaskRunner struct {
 	state *State
@@ -52,6 +83,7 @@ type TaskRunner struct {
 	mu       sync.Mutex
 	handlers map[string]handlerPair
 	cleanups map[string]HandlerFunc
+	policies map[string]*RetryPolicy
 	stopped  bool
 
 	blocked     func(t *Task, running []*Task) bool
@@ -71,6 +103,7 @@ func NewTaskRunner(s *State) *TaskRunner {
 		state:    s,
 		handlers: make(map[string]handlerPair),
 		cleanups: make(map[string]HandlerFunc),
+		policies: make(map[string]*RetryPolicy),
 		tombs:    make(map[string]*tomb.Tomb),
 	}
 }
@@ -105,6 +138,44 @@ func (r *TaskRunner) AddCleanup(kind string, cleanup HandlerFunc) {
 	r.cleanups[kind] = cleanup
 }
 
+// SetRetryPolicy sets the policy for retrying the tasks of the given
+// kind when their do handler fails with an error other than Retry.
+// Once the policy doesn't allow for more retries, the task fails as
+// usual.
+//
+// The handler for tasks of the provided kind must have been previously
+// registered before SetRetryPolicy is called for it.
+func (r *TaskRunner) SetRetryPolicy(kind string, policy *RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[kind]; !ok {
+		panic("internal error: attempted to set retry policy for unknown task kind")
+	}
+	r.policies[kind] = policy
+}
+
+// retry retries the task after its handler failed with err, if its
+// retry policy allows for it.
+func (r *TaskRunner) retry(t *Task, err error) bool {
+	policy := r.policies[t.Kind()]
+	if policy == nil || t.Status() != DoingStatus {
+		return false
+	}
+	if policy.Retryable != nil && !policy.Retryable(err) {
+		return false
+	}
+	attempt := t.Retries() + 1
+	if attempt >= policy.MaxAttempts {
+		t.Logf("Attempt %d of %d failed, giving up.", attempt, policy.MaxAttempts)
+		return false
+	}
+	t.addRetry(err)
+	backoff := policy.backoff(attempt)
+	t.Logf("Attempt %d of %d failed, retrying in %v: %v", attempt, policy.MaxAttempts, backoff, err)
+	t.At(timeNow().Add(backoff))
+	return true
+}
+
 // SetBlocked sets a predicate function to decide whether to block a task from running based on the current running tasks. It can be used to control task serialisation.
 func (r *TaskRunner) SetBlocked(pred func(t *Task, running []*Task) bool) {
 	r.mu.Lock()
@@ -191,6 +262,9 @@ func (r *TaskRunner) run(t *Task) {
 				r.state.EnsureBefore(0)
 			}
 		default:
+			if r.retry(t, err) {
+				break
+			}
 			r.abortLanes(t.Change(), t.Lanes())
 			t.SetStatus(ErrorStatus)
 			t.Errorf("%s", err)
@@ -336,8 +410,26 @@ func (r *TaskRunner) Ensure() {
 			continue
 		}
 
-		// skip tasks scheduled for later and also track the earliest one
+		if status == DoStatus {
+			wait, err := mustWaitChanges(t)
+			if err != nil {
+				// A required change failed, so must this one.
+				t.Errorf("%s", err)
+				t.SetStatus(ErrorStatus)
+				t.Change().Abort()
+				continue
+			}
+			if wait {
+				continue
+			}
+		}
+
+		// skip tasks scheduled for later, directly or through their
+		// change, and also track the earliest one
 		tWhen := t.AtTime()
+		if chg := t.Change(); chg != nil && chg.AtTime().After(tWhen) {
+			tWhen = chg.AtTime()
+		}
 		if !tWhen.IsZero() && ensureTime.Before(tWhen) {
 			if nextTaskTime.IsZero() || nextTaskTime.After(tWhen) {
 				nextTaskTime = tWhen
@@ -381,6 +473,26 @@ func mustWait(t *Task) bool {
 	return false
 }
 
+// mustWaitChanges returns whether the change of t waits for other
+// changes that are not ready yet. It returns an error if any of those
+// changes is ready but did not end up done.
+func mustWaitChanges(t *Task) (bool, error) {
+	chg := t.Change()
+	if chg == nil {
+		return false, nil
+	}
+	for _, wchg := range chg.WaitChanges() {
+		status := wchg.Status()
+		if !status.Ready() {
+			return true, nil
+		}
+		if status != DoneStatus {
+			return false, fmt.Errorf("required change %s (%q) did not complete: %s", wchg.ID(), wchg.Summary(), status)
+		}
+	}
+	return false, nil
+}
+
 // wait expects to be called with th r.mu lock held
 func (r *TaskRunner) wait() {
 	for len(r.tombs) > 0 {
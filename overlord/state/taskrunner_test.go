@@ -505,6 +505,281 @@ func (ts *taskRunnerSuite) TestRetryAfterDuration(c *C) {
 	c.Check(t.AtTime().IsZero(), Equals, true)
 }
 
+func (ts *taskRunnerSuite) TestRetryPolicy(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	ran := 0
+	r.AddHandler("download", func(t *state.Task, _ *tomb.Tomb) error {
+		ran++
+		if ran < 3 {
+			return fmt.Errorf("timeout %d", ran)
+		}
+		return nil
+	}, nil)
+	r.SetRetryPolicy("download", &state.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Minute,
+		MaxBackoff:  90 * time.Second,
+	})
+
+	now := time.Now()
+	restore := state.MockTime(now)
+	defer restore()
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	c.Check(ran, Equals, 1)
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(t.Retries(), Equals, 1)
+	c.Check(t.LastError(), Equals, "timeout 1")
+	c.Check(t.AtTime().Equal(now.Add(time.Minute)), Equals, true)
+	c.Check(sb.ensureBefore, Equals, time.Minute)
+	c.Check(t.Log(), HasLen, 1)
+	c.Check(t.Log()[0], Matches, `\S+ INFO Attempt 1 of 3 failed, retrying in 1m0s: timeout 1`)
+	st.Unlock()
+
+	// too soon
+	r.Ensure()
+	r.Wait()
+	c.Check(ran, Equals, 1)
+
+	now = now.Add(time.Minute)
+	state.MockTime(now)
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	c.Check(ran, Equals, 2)
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(t.Retries(), Equals, 2)
+	c.Check(t.LastError(), Equals, "timeout 2")
+	// backing off twice as long, but no longer than the max
+	c.Check(t.AtTime().Equal(now.Add(90*time.Second)), Equals, true)
+	st.Unlock()
+
+	state.MockTime(now.Add(90 * time.Second))
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, Equals, 3)
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(t.Retries(), Equals, 2)
+}
+
+func (ts *taskRunnerSuite) TestRetryPolicyGivesUp(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	ran := 0
+	r.AddHandler("download", func(t *state.Task, _ *tomb.Tomb) error {
+		ran++
+		return fmt.Errorf("timeout %d", ran)
+	}, nil)
+	r.SetRetryPolicy("download", &state.RetryPolicy{MaxAttempts: 2})
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	for i := 0; i < 3; i++ {
+		r.Ensure()
+		r.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, Equals, 2)
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Retries(), Equals, 1)
+	c.Check(t.LastError(), Equals, "timeout 1")
+	c.Check(strings.Join(t.Log(), "\n"), Matches, `(?s).* INFO Attempt 1 of 2 failed, retrying in 0s: timeout 1
+.* INFO Attempt 2 of 2 failed, giving up.
+.* ERROR timeout 2`)
+	c.Check(chg.Err(), ErrorMatches, `(?s).*timeout 2.*`)
+}
+
+func (ts *taskRunnerSuite) TestRetryPolicyNotRetryable(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	errPermanent := errors.New("permanent")
+	ran := 0
+	r.AddHandler("download", func(t *state.Task, _ *tomb.Tomb) error {
+		ran++
+		return errPermanent
+	}, nil)
+	r.SetRetryPolicy("download", &state.RetryPolicy{
+		MaxAttempts: 5,
+		Retryable: func(err error) bool {
+			return err != errPermanent
+		},
+	})
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t := st.NewTask("download", "...")
+	chg.AddTask(t)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, Equals, 1)
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Retries(), Equals, 0)
+}
+
+func (ts *taskRunnerSuite) TestRetryPolicyUnknownKind(c *C) {
+	r := state.NewTaskRunner(state.New(nil))
+	c.Check(func() { r.SetRetryPolicy("foo", &state.RetryPolicy{}) }, PanicMatches, "internal error: attempted to set retry policy for unknown task kind")
+}
+
+func (ts *taskRunnerSuite) TestScheduledChange(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	ran := 0
+	r.AddHandler("do", func(t *state.Task, _ *tomb.Tomb) error {
+		ran++
+		return nil
+	}, nil)
+
+	now := time.Now()
+	restore := state.MockTime(now)
+	defer restore()
+
+	st.Lock()
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("do", "1...")
+	t2 := st.NewTask("do", "2...")
+	t2.WaitFor(t1)
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	chg.At(now.Add(time.Minute))
+	st.Unlock()
+
+	sb.ensureBefore = time.Hour
+	r.Ensure() // too soon
+	r.Wait()
+
+	st.Lock()
+	c.Check(ran, Equals, 0)
+	c.Check(chg.IsScheduled(), Equals, true)
+	c.Check(sb.ensureBefore, Equals, time.Minute)
+	st.Unlock()
+
+	state.MockTime(now.Add(time.Minute))
+	ensureChange(c, r, sb, chg)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, Equals, 2)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+}
+
+func (ts *taskRunnerSuite) TestChangeWaitsForChange(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	var ran []string
+	r.AddHandler("do", func(t *state.Task, _ *tomb.Tomb) error {
+		st.Lock()
+		defer st.Unlock()
+		ran = append(ran, t.Summary())
+		return nil
+	}, nil)
+
+	st.Lock()
+	chg1 := st.NewChange("install", "...")
+	t1 := st.NewTask("do", "1")
+	t1.SetStatus(state.HoldStatus)
+	chg1.AddTask(t1)
+	chg1.AddTask(st.NewTask("do", "2"))
+	chg2 := st.NewChange("connect", "...")
+	chg2.AddTask(st.NewTask("do", "3"))
+	chg2.WaitFor(chg1)
+	chg1.Tasks()[1].WaitFor(t1)
+	st.Unlock()
+
+	r.Ensure()
+	r.Wait()
+
+	st.Lock()
+	c.Check(ran, HasLen, 0)
+	t1.SetStatus(state.DoStatus)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg2)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, DeepEquals, []string{"1", "2", "3"})
+	c.Check(chg2.Status(), Equals, state.DoneStatus)
+}
+
+func (ts *taskRunnerSuite) TestChangeWaitsForFailedChange(c *C) {
+	sb := &stateBackend{ensureBefore: time.Hour}
+	st := state.New(sb)
+	r := state.NewTaskRunner(st)
+	defer r.Stop()
+
+	ran := 0
+	r.AddHandler("do", func(t *state.Task, _ *tomb.Tomb) error {
+		ran++
+		return nil
+	}, nil)
+	r.AddHandler("fail", func(t *state.Task, _ *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+
+	st.Lock()
+	chg1 := st.NewChange("install", "install a")
+	chg1.AddTask(st.NewTask("fail", "1"))
+	chg2 := st.NewChange("connect", "...")
+	t2 := st.NewTask("do", "2")
+	t3 := st.NewTask("do", "3")
+	t3.WaitFor(t2)
+	chg2.AddTask(t2)
+	chg2.AddTask(t3)
+	chg2.WaitFor(chg1)
+	st.Unlock()
+
+	ensureChange(c, r, sb, chg2)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(ran, Equals, 0)
+	c.Check(chg1.Status(), Equals, state.ErrorStatus)
+	c.Check(chg2.Status(), Equals, state.ErrorStatus)
+	c.Check(chg2.Err(), ErrorMatches, `(?s).*required change 1 \("install a"\) did not complete: Error.*`)
+}
+
 func (ts *taskRunnerSuite) TestTaskSerialization(c *C) {
 	ensureBeforeTick := make(chan bool, 1)
 	sb := &stateBackend{
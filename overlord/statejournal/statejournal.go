@@ -0,0 +1,300 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package statejournal implements the stores that let the state be
+// checkpointed incrementally: the entries of the state modified since
+// its last full checkpoint are committed to a store, which replays them
+// on top of that checkpoint when loading the state.
+package statejournal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// minLimit is the size the journal can always grow to before a full
+// checkpoint is required, however small the state is.
+var minLimit int64 = 64 * 1024
+
+// header is the first line of a journal, identifying the full
+// checkpoint the entries of the journal apply on top of.
+type header struct {
+	Snapshot string `json:"snapshot"`
+}
+
+func snapshotID(snapshot []byte) string {
+	h := sha256.Sum256(snapshot)
+	return hex.EncodeToString(h[:])
+}
+
+// A Store keeps the deltas to the last full checkpoint of the state.
+type Store interface {
+	// Replay returns the state resulting from applying the deltas in
+	// the store on top of snapshot, the last full checkpoint of the
+	// state. Deltas that don't apply to snapshot are ignored.
+	Replay(snapshot []byte) ([]byte, error)
+	// Open readies the store for committing deltas on top of
+	// snapshot, carrying on after the deltas already in the store if
+	// they apply to it.
+	Open(snapshot []byte) error
+	// Reset drops the deltas in the store, to commit new ones on top
+	// of snapshot, the new full checkpoint of the state.
+	Reset(snapshot []byte) error
+	// Commit commits delta to the store, all of it or none. It
+	// returns state.ErrFullCheckpoint once the deltas are due to be
+	// folded into a full checkpoint of the state.
+	Commit(delta *state.Delta) error
+}
+
+// Journal is a Store appending the deltas to a file, one per line.
+type Journal struct {
+	path  string
+	size  int64
+	limit int64
+	// broken is set when a failed append might have left a partial
+	// entry behind, which only a reset can get rid of
+	broken bool
+}
+
+var _ Store = (*Journal)(nil)
+
+// New returns the journal at path.
+func New(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Open opens the journal to carry on appending to it if it applies on
+// top of snapshot, the last full checkpoint of the state, or starts it
+// afresh otherwise.
+func (j *Journal) Open(snapshot []byte) error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return j.Reset(snapshot)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var hdr header
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &hdr) != nil || hdr.Snapshot != snapshotID(snapshot) {
+		return j.Reset(snapshot)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	j.size = fi.Size()
+	j.setLimit(snapshot)
+	j.broken = false
+	// anything partial at the end is dropped on replay anyway, but
+	// it must not get glued to the next entry
+	return j.truncateToLastEntry(f)
+}
+
+func (j *Journal) setLimit(snapshot []byte) {
+	j.limit = int64(len(snapshot))
+	if j.limit < minLimit {
+		j.limit = minLimit
+	}
+}
+
+func (j *Journal) truncateToLastEntry(f *os.File) error {
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	end := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if end == j.size {
+		return nil
+	}
+	if err := os.Truncate(j.path, end); err != nil {
+		return err
+	}
+	j.size = end
+	return nil
+}
+
+// Reset starts the journal afresh on top of snapshot, the new full
+// checkpoint of the state.
+func (j *Journal) Reset(snapshot []byte) error {
+	data, err := json.Marshal(header{Snapshot: snapshotID(snapshot)})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if err := osutil.AtomicWriteFile(j.path, data, 0600, 0); err != nil {
+		return fmt.Errorf("cannot reset state journal: %v", err)
+	}
+	j.size = int64(len(data))
+	j.setLimit(snapshot)
+	j.broken = false
+	return nil
+}
+
+// Commit appends the delta to the journal, all of it or none. It
+// returns state.ErrFullCheckpoint once the journal is due to be folded
+// into a full checkpoint of the state and reset.
+func (j *Journal) Commit(delta *state.Delta) error {
+	if j.broken || j.size > j.limit {
+		return state.ErrFullCheckpoint
+	}
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("cannot open state journal: %v", err)
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		if f.Truncate(j.size) != nil {
+			j.broken = true
+		}
+		return fmt.Errorf("cannot append to state journal: %v", err)
+	}
+	j.size += int64(len(data))
+	return nil
+}
+
+func isNull(entry json.RawMessage) bool {
+	return entry == nil || string(entry) == "null"
+}
+
+// applyData applies data entries, which can legitimately hold null.
+func applyData(section map[string]json.RawMessage, entries map[string]json.RawMessage) {
+	for key, entry := range entries {
+		if entry == nil {
+			delete(section, key)
+		} else {
+			section[key] = entry
+		}
+	}
+}
+
+func applyEntries(section map[string]json.RawMessage, entries map[string]json.RawMessage) {
+	for key, entry := range entries {
+		if isNull(entry) {
+			delete(section, key)
+		} else {
+			section[key] = entry
+		}
+	}
+}
+
+// Replay returns the state resulting from applying the entries in the
+// journal on top of snapshot, the last full checkpoint of the state. A
+// journal that doesn't apply to snapshot is ignored, as is an entry cut
+// short at its end.
+func (j *Journal) Replay(snapshot []byte) ([]byte, error) {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return snapshot, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var hdr header
+	line, err := r.ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &hdr) != nil || hdr.Snapshot != snapshotID(snapshot) {
+		logger.Noticef("Ignoring state journal not matching the state.")
+		return snapshot, nil
+	}
+
+	var whole map[string]json.RawMessage
+	if err := json.Unmarshal(snapshot, &whole); err != nil {
+		return nil, err
+	}
+	sections := make(map[string]map[string]json.RawMessage)
+	for _, name := range []string{"data", "changes", "tasks"} {
+		var section map[string]json.RawMessage
+		if raw, ok := whole[name]; ok {
+			if err := json.Unmarshal(raw, &section); err != nil {
+				return nil, err
+			}
+		}
+		if section == nil {
+			section = make(map[string]json.RawMessage)
+		}
+		sections[name] = section
+	}
+
+	n := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				logger.Noticef("Ignoring partial entry at the end of the state journal.")
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		n++
+		var delta state.Delta
+		if err := json.Unmarshal(line, &delta); err != nil {
+			return nil, fmt.Errorf("cannot replay entry %d of the state journal: %v", n, err)
+		}
+		applyData(sections["data"], delta.Data)
+		applyEntries(sections["changes"], delta.Changes)
+		applyEntries(sections["tasks"], delta.Tasks)
+		for key, id := range map[string]int{
+			"last-change-id": delta.LastChangeId,
+			"last-task-id":   delta.LastTaskId,
+			"last-lane-id":   delta.LastLaneId,
+		} {
+			whole[key] = json.RawMessage(fmt.Sprint(id))
+		}
+	}
+
+	for name, section := range sections {
+		raw, err := json.Marshal(section)
+		if err != nil {
+			return nil, err
+		}
+		whole[name] = raw
+	}
+	return json.Marshal(whole)
+}
@@ -0,0 +1,285 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package statejournal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/statejournal"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type journalSuite struct {
+	dir         string
+	journalPath string
+	statePath   string
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.journalPath = filepath.Join(s.dir, "state.journal")
+	s.statePath = filepath.Join(s.dir, "state.json")
+}
+
+// backend checkpoints like the overlord does
+type backend struct {
+	statePath string
+	journal   *statejournal.Journal
+	full      int
+	deltas    int
+}
+
+func (b *backend) Checkpoint(data []byte) error {
+	b.full++
+	if err := osutil.AtomicWriteFile(b.statePath, data, 0600, 0); err != nil {
+		return err
+	}
+	return b.journal.Reset(data)
+}
+
+func (b *backend) StartIncremental(data []byte) error {
+	return b.Checkpoint(data)
+}
+
+func (b *backend) CheckpointDelta(delta *state.Delta) error {
+	err := b.journal.Commit(delta)
+	if err == nil {
+		b.deltas++
+	}
+	return err
+}
+
+func (b *backend) EnsureBefore(d time.Duration)       {}
+func (b *backend) RequestRestart(t state.RestartType) {}
+
+func (s *journalSuite) newState(c *C) (*state.State, *backend) {
+	j := statejournal.New(s.journalPath)
+	c.Assert(j.Open(nil), IsNil)
+	b := &backend{statePath: s.statePath, journal: j}
+	return state.New(b), b
+}
+
+func (s *journalSuite) load(c *C) *state.State {
+	snapshot, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	data, err := statejournal.New(s.journalPath).Replay(snapshot)
+	c.Assert(err, IsNil)
+	st, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	return st
+}
+
+func marshal(c *C, st *state.State) map[string]interface{} {
+	st.Lock()
+	defer st.Unlock()
+	data, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	var m map[string]interface{}
+	c.Assert(json.Unmarshal(data, &m), IsNil)
+	return m
+}
+
+func (s *journalSuite) TestReplay(c *C) {
+	st, b := s.newState(c)
+
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("gone", 1)
+	chg := st.NewChange("install", "...")
+	t1 := st.NewTask("download", "...")
+	t2 := st.NewTask("link", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Unlock()
+	c.Check(b.full, Equals, 1)
+
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Set("gone", nil)
+	t1.SetStatus(state.DoneStatus)
+	t1.Logf("some log")
+	chg2 := st.NewChange("remove", "...")
+	t3 := st.NewTask("unlink", "...")
+	chg2.AddTask(t3)
+	t3.SetStatus(state.DoneStatus)
+	st.NewLane()
+	st.Unlock()
+
+	st.Lock()
+	// prunes chg2 and t3
+	st.Prune(0, time.Hour)
+	t2.SetStatus(state.DoingStatus)
+	st.Unlock()
+
+	c.Check(b.full, Equals, 1)
+	c.Check(b.deltas, Equals, 2)
+
+	loaded := s.load(c)
+	c.Check(marshal(c, loaded), DeepEquals, marshal(c, st))
+
+	loaded.Lock()
+	defer loaded.Unlock()
+	c.Check(loaded.Change(chg2.ID()), IsNil)
+	c.Check(loaded.Task(t2.ID()).Status(), Equals, state.DoingStatus)
+	var foo string
+	c.Assert(loaded.Get("foo", &foo), IsNil)
+	c.Check(foo, Equals, "baz")
+}
+
+func (s *journalSuite) TestReplayNoJournal(c *C) {
+	data, err := statejournal.New(s.journalPath).Replay([]byte(`{"data":{}}`))
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, `{"data":{}}`)
+}
+
+func (s *journalSuite) TestReplayIgnoresJournalForOtherSnapshot(c *C) {
+	st, _ := s.newState(c)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Unlock()
+
+	// a full checkpoint that didn't get to reset the journal
+	other := []byte(`{"data":{"foo":"other"}}`)
+	data, err := statejournal.New(s.journalPath).Replay(other)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, string(other))
+}
+
+func (s *journalSuite) TestReplayIgnoresPartialEntry(c *C) {
+	st, _ := s.newState(c)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Unlock()
+
+	f, err := os.OpenFile(s.journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte(`{"data":{"foo":"cut`))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	loaded := s.load(c)
+	loaded.Lock()
+	var foo string
+	c.Assert(loaded.Get("foo", &foo), IsNil)
+	loaded.Unlock()
+	c.Check(foo, Equals, "baz")
+
+	// opening drops the partial entry so that appending carries on
+	snapshot, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	j := statejournal.New(s.journalPath)
+	c.Assert(j.Open(snapshot), IsNil)
+	c.Assert(j.Commit(&state.Delta{Data: map[string]json.RawMessage{"foo": json.RawMessage(`"more"`)}}), IsNil)
+
+	loaded = s.load(c)
+	loaded.Lock()
+	c.Assert(loaded.Get("foo", &foo), IsNil)
+	loaded.Unlock()
+	c.Check(foo, Equals, "more")
+}
+
+func (s *journalSuite) TestReplayBrokenEntry(c *C) {
+	st, _ := s.newState(c)
+	st.Lock()
+	st.Unlock()
+
+	f, err := os.OpenFile(s.journalPath, os.O_WRONLY|os.O_APPEND, 0600)
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("garbage\n"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	snapshot, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	_, err = statejournal.New(s.journalPath).Replay(snapshot)
+	c.Check(err, ErrorMatches, "cannot replay entry 1 of the state journal: .*")
+}
+
+func (s *journalSuite) TestOpenCarriesOn(c *C) {
+	st, _ := s.newState(c)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Unlock()
+
+	snapshot, err := ioutil.ReadFile(s.statePath)
+	c.Assert(err, IsNil)
+	before, err := ioutil.ReadFile(s.journalPath)
+	c.Assert(err, IsNil)
+
+	err = statejournal.New(s.journalPath).Open(snapshot)
+	c.Assert(err, IsNil)
+	after, err := ioutil.ReadFile(s.journalPath)
+	c.Assert(err, IsNil)
+	c.Check(string(after), Equals, string(before))
+
+	// but not for another snapshot
+	err = statejournal.New(s.journalPath).Open([]byte(`{}`))
+	c.Assert(err, IsNil)
+	after, err = ioutil.ReadFile(s.journalPath)
+	c.Assert(err, IsNil)
+	c.Check(bytes.Count(after, []byte("\n")), Equals, 1)
+}
+
+func (s *journalSuite) TestFullCheckpointOnceBig(c *C) {
+	restore := statejournal.MockMinLimit(0)
+	defer restore()
+
+	st, b := s.newState(c)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+	c.Assert(b.full, Equals, 1)
+
+	for i := 0; i < 10; i++ {
+		st.Lock()
+		st.Set("foo", fmt.Sprintf("bar%d", i))
+		st.Unlock()
+	}
+	// the journal is folded into the state file whenever it gets
+	// bigger than it
+	c.Check(b.full > 1, Equals, true)
+	c.Check(b.deltas > 0, Equals, true)
+
+	loaded := s.load(c)
+	c.Check(marshal(c, loaded), DeepEquals, marshal(c, st))
+}
@@ -0,0 +1,115 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Channel identifies a store channel: the risk level within a track of
+// the snap, optionally narrowed down to a branch.
+type Channel struct {
+	Track  string `json:"track"`
+	Risk   string `json:"risk"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// DefaultTrack is the track a channel without explicit track refers to.
+const DefaultTrack = "latest"
+
+// channelRisks are the valid risk levels, from the most to the least stable.
+var channelRisks = []string{"stable", "candidate", "beta", "edge"}
+
+var validTrackOrBranch = regexp.MustCompile("^[a-zA-Z0-9](?:[-_.a-zA-Z0-9]*[a-zA-Z0-9])?$")
+
+func isRisk(s string) bool {
+	for _, risk := range channelRisks {
+		if s == risk {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseChannel parses a channel given as "<risk>", "<track>",
+// "<track>/<risk>", "<risk>/<branch>" or "<track>/<risk>/<branch>".
+// A track on its own refers to its stable risk level.
+func ParseChannel(s string) (Channel, error) {
+	if s == "" {
+		return Channel{}, fmt.Errorf("channel name cannot be empty")
+	}
+
+	c := Channel{Track: DefaultTrack}
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 1:
+		if isRisk(parts[0]) {
+			c.Risk = parts[0]
+		} else {
+			c.Track, c.Risk = parts[0], "stable"
+		}
+	case 2:
+		if isRisk(parts[0]) {
+			c.Risk, c.Branch = parts[0], parts[1]
+		} else {
+			c.Track, c.Risk = parts[0], parts[1]
+		}
+	case 3:
+		c.Track, c.Risk, c.Branch = parts[0], parts[1], parts[2]
+	default:
+		return Channel{}, fmt.Errorf("channel name has too many components: %q", s)
+	}
+
+	if !isRisk(c.Risk) {
+		return Channel{}, fmt.Errorf("invalid risk in channel name: %q", s)
+	}
+	if !validTrackOrBranch.MatchString(c.Track) || isRisk(c.Track) {
+		return Channel{}, fmt.Errorf("invalid track in channel name: %q", s)
+	}
+	if strings.HasSuffix(s, "/") || (c.Branch != "" && !validTrackOrBranch.MatchString(c.Branch)) {
+		return Channel{}, fmt.Errorf("invalid branch in channel name: %q", s)
+	}
+
+	return c, nil
+}
+
+// String returns the canonical name of the channel, which leaves out
+// the default track.
+func (c Channel) String() string {
+	name := c.Risk
+	if c.Track != DefaultTrack {
+		name = c.Track + "/" + name
+	}
+	if c.Branch != "" {
+		name += "/" + c.Branch
+	}
+	return name
+}
+
+// Full returns the name of the channel including its track.
+func (c Channel) Full() string {
+	name := c.Track + "/" + c.Risk
+	if c.Branch != "" {
+		name += "/" + c.Branch
+	}
+	return name
+}
@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+type channelSuite struct{}
+
+var _ = Suite(&channelSuite{})
+
+func (s channelSuite) TestParseChannel(c *C) {
+	for _, t := range []struct {
+		in   string
+		ch   snap.Channel
+		name string
+		full string
+	}{
+		{"stable", snap.Channel{Track: "latest", Risk: "stable"}, "stable", "latest/stable"},
+		{"edge", snap.Channel{Track: "latest", Risk: "edge"}, "edge", "latest/edge"},
+		{"latest/beta", snap.Channel{Track: "latest", Risk: "beta"}, "beta", "latest/beta"},
+		{"1.0", snap.Channel{Track: "1.0", Risk: "stable"}, "1.0/stable", "1.0/stable"},
+		{"1.0/candidate", snap.Channel{Track: "1.0", Risk: "candidate"}, "1.0/candidate", "1.0/candidate"},
+		{"edge/fix-123", snap.Channel{Track: "latest", Risk: "edge", Branch: "fix-123"}, "edge/fix-123", "latest/edge/fix-123"},
+		{"1.0/edge/fix-123", snap.Channel{Track: "1.0", Risk: "edge", Branch: "fix-123"}, "1.0/edge/fix-123", "1.0/edge/fix-123"},
+	} {
+		ch, err := snap.ParseChannel(t.in)
+		c.Assert(err, IsNil, Commentf(t.in))
+		c.Check(ch, Equals, t.ch, Commentf(t.in))
+		c.Check(ch.String(), Equals, t.name, Commentf(t.in))
+		c.Check(ch.Full(), Equals, t.full, Commentf(t.in))
+	}
+}
+
+func (s channelSuite) TestParseChannelErrors(c *C) {
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"", `channel name cannot be empty`},
+		{"1.0/stable/a/b", `channel name has too many components: "1.0/stable/a/b"`},
+		{"1.0/potato", `invalid risk in channel name: "1.0/potato"`},
+		{"1.0/", `invalid risk in channel name: "1.0/"`},
+		{"/stable", `invalid track in channel name: "/stable"`},
+		{"edge/beta/fix", `invalid track in channel name: "edge/beta/fix"`},
+		{"stable/", `invalid branch in channel name: "stable/"`},
+		{"1.0/edge/-fix", `invalid branch in channel name: "1.0/edge/-fix"`},
+		{"a b", `invalid track in channel name: "a b"`},
+	} {
+		_, err := snap.ParseChannel(t.in)
+		c.Check(err, ErrorMatches, t.err, Commentf(t.in))
+	}
+}
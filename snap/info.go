@@ -36,6 +36,10 @@ type PlaceInfo interface {
 	// Name returns the name of the snap.
 	Name() string
 
+	// InstanceName returns the name of the snap instance, that is the
+	// name of the snap followed by the instance key if there is one.
+	InstanceName() string
+
 	// MountDir returns the base directory of the snap.
 	MountDir() string
 
@@ -61,9 +65,36 @@ type PlaceInfo interface {
 	XdgRuntimeDirs() string
 }
 
-// MinimalPlaceInfo returns a PlaceInfo with just the location information for a snap of the given name and revision.
-func MinimalPlaceInfo(name string, revision Revision) PlaceInfo {
-	return &Info{SideInfo: SideInfo{RealName: name, Revision: revision}}
+// MinimalPlaceInfo returns a PlaceInfo with just the location information for a snap instance of the given name and revision.
+func MinimalPlaceInfo(instanceName string, revision Revision) PlaceInfo {
+	snapName, instanceKey := SplitInstanceName(instanceName)
+	return &Info{SideInfo: SideInfo{RealName: snapName, Revision: revision}, InstanceKey: instanceKey}
+}
+
+// InstanceName returns the name of the snap instance for the given
+// snap name and instance key, as "<snap>_<key>", or just the snap
+// name if the key is empty.
+func InstanceName(snapName, instanceKey string) string {
+	if instanceKey == "" {
+		return snapName
+	}
+	return fmt.Sprintf("%s_%s", snapName, instanceKey)
+}
+
+// SplitInstanceName splits an instance name into the snap name and
+// the instance key, which is empty if there is none.
+func SplitInstanceName(instanceName string) (snapName, instanceKey string) {
+	l := strings.SplitN(instanceName, "_", 2)
+	if len(l) < 2 {
+		return l[0], ""
+	}
+	return l[0], l[1]
+}
+
+// InstanceSnap returns the name of the snap of the given instance.
+func InstanceSnap(instanceName string) string {
+	snapName, _ := SplitInstanceName(instanceName)
+	return snapName
 }
 
 // MountDir returns the base directory where it gets mounted of the snap with the given name and revision.
@@ -131,6 +162,7 @@ type Info struct {
 	SuggestedName string
 	Version       string
 	Type          Type
+	Base          string
 	Architectures []string
 	Assumes       []string
 
@@ -148,6 +180,13 @@ type Info struct {
 	Plugs            map[string]*PlugInfo
 	Slots            map[string]*SlotInfo
 
+	// Aliases maps the aliases declared in snap.yaml to their apps.
+	Aliases map[string]*AppInfo
+
+	// InstanceKey distinguishes parallel installations of the same
+	// snap, it is empty for the main one.
+	InstanceKey string
+
 	// The information in all the remaining fields is not sourced from the snap blob itself.
 	SideInfo
 
@@ -161,7 +200,29 @@ type Info struct {
 	Prices  map[string]float64 `yaml:"prices,omitempty" json:"prices,omitempty"`
 	MustBuy bool
 
+	Publisher string
+	License   string
+	Contact   string
+
 	Screenshots []ScreenshotInfo
+
+	// Channels maps the channels the snap is published in, as
+	// "<risk>" for the default track or "<track>/<risk>" otherwise,
+	// to what the store holds in each of them.
+	Channels map[string]*ChannelSnapInfo
+	// Tracks lists the tracks of the snap in the store's order.
+	Tracks []string
+}
+
+// ChannelSnapInfo is the minimum information that can be used to clearly
+// distinguish different revisions of the same snap.
+type ChannelSnapInfo struct {
+	Revision    Revision        `json:"revision"`
+	Version     string          `json:"version"`
+	Channel     string          `json:"channel"`
+	Epoch       string          `json:"epoch"`
+	Confinement ConfinementType `json:"confinement"`
+	Size        int64           `json:"size"`
 }
 
 // Name returns the blessed name for the snap.
@@ -172,6 +233,12 @@ func (s *Info) Name() string {
 	return s.SuggestedName
 }
 
+// InstanceName returns the name of the snap instance, that is the
+// blessed name followed by "_" and the instance key if there is one.
+func (s *Info) InstanceName() string {
+	return InstanceName(s.Name(), s.InstanceKey)
+}
+
 // Summary returns the blessed summary for the snap.
 func (s *Info) Summary() string {
 	if s.EditedSummary != "" {
@@ -190,12 +257,12 @@ func (s *Info) Description() string {
 
 // MountDir returns the base directory of the snap where it gets mounted.
 func (s *Info) MountDir() string {
-	return MountDir(s.Name(), s.Revision)
+	return MountDir(s.InstanceName(), s.Revision)
 }
 
 // MountFile returns the path where the snap file that is mounted is installed.
 func (s *Info) MountFile() string {
-	return MountFile(s.Name(), s.Revision)
+	return MountFile(s.InstanceName(), s.Revision)
 }
 
 // HooksDir returns the directory containing the snap's hooks.
@@ -205,42 +272,42 @@ func (s *Info) HooksDir() string {
 
 // DataDir returns the data directory of the snap.
 func (s *Info) DataDir() string {
-	return filepath.Join(dirs.SnapDataDir, s.Name(), s.Revision.String())
+	return filepath.Join(dirs.SnapDataDir, s.InstanceName(), s.Revision.String())
 }
 
 // UserDataDir returns the user-specific data directory of the snap.
 func (s *Info) UserDataDir(home string) string {
-	return filepath.Join(home, "snap", s.Name(), s.Revision.String())
+	return filepath.Join(home, "snap", s.InstanceName(), s.Revision.String())
 }
 
 // UserCommonDataDir returns the user-specific data directory common across revision of the snap.
 func (s *Info) UserCommonDataDir(home string) string {
-	return filepath.Join(home, "snap", s.Name(), "common")
+	return filepath.Join(home, "snap", s.InstanceName(), "common")
 }
 
 // CommonDataDir returns the data directory common across revisions of the snap.
 func (s *Info) CommonDataDir() string {
-	return filepath.Join(dirs.SnapDataDir, s.Name(), "common")
+	return filepath.Join(dirs.SnapDataDir, s.InstanceName(), "common")
 }
 
 // DataHomeDir returns the per user data directory of the snap.
 func (s *Info) DataHomeDir() string {
-	return filepath.Join(dirs.SnapDataHomeGlob, s.Name(), s.Revision.String())
+	return filepath.Join(dirs.SnapDataHomeGlob, s.InstanceName(), s.Revision.String())
 }
 
 // CommonDataHomeDir returns the per user data directory common across revisions of the snap.
 func (s *Info) CommonDataHomeDir() string {
-	return filepath.Join(dirs.SnapDataHomeGlob, s.Name(), "common")
+	return filepath.Join(dirs.SnapDataHomeGlob, s.InstanceName(), "common")
 }
 
 // UserXdgRuntimeDir returns the XDG_RUNTIME_DIR directory of the snap for a particular user.
 func (s *Info) UserXdgRuntimeDir(euid int) string {
-	return filepath.Join("/run/user", fmt.Sprintf("%d/snap.%s", euid, s.Name()))
+	return filepath.Join("/run/user", fmt.Sprintf("%d/snap.%s", euid, s.InstanceName()))
 }
 
 // XdgRuntimeDirs returns the XDG_RUNTIME_DIR directories for all users of the snap.
 func (s *Info) XdgRuntimeDirs() string {
-	return filepath.Join(dirs.XdgRuntimeDirGlob, fmt.Sprintf("snap.%s", s.Name()))
+	return filepath.Join(dirs.XdgRuntimeDirGlob, fmt.Sprintf("snap.%s", s.InstanceName()))
 }
 
 // NeedsDevMode retursn whether the snap needs devmode.
@@ -343,6 +410,10 @@ type HookInfo struct {
 
 	Name  string
 	Plugs map[string]*PlugInfo
+
+	// Timeout is how long the hook may run before being stopped, zero
+	// means the system default.
+	Timeout timeout.Timeout
 }
 
 // SecurityTag returns application-specific security tag.
@@ -350,16 +421,16 @@ type HookInfo struct {
 // Security tags are used by various security subsystems as "profile names" and
 // sometimes also as a part of the file name.
 func (app *AppInfo) SecurityTag() string {
-	return AppSecurityTag(app.Snap.Name(), app.Name)
+	return AppSecurityTag(app.Snap.InstanceName(), app.Name)
 }
 
 // WrapperPath returns the path to wrapper invoking the app binary.
 func (app *AppInfo) WrapperPath() string {
 	var binName string
 	if app.Name == app.Snap.Name() {
-		binName = filepath.Base(app.Name)
+		binName = app.Snap.InstanceName()
 	} else {
-		binName = fmt.Sprintf("%s.%s", app.Snap.Name(), filepath.Base(app.Name))
+		binName = fmt.Sprintf("%s.%s", app.Snap.InstanceName(), filepath.Base(app.Name))
 	}
 
 	return filepath.Join(dirs.SnapBinariesDir, binName)
@@ -370,9 +441,9 @@ func (app *AppInfo) launcherCommand(command string) string {
 		command = " " + command
 	}
 	if app.Name == app.Snap.Name() {
-		return fmt.Sprintf("/usr/bin/snap run%s %s", command, app.Name)
+		return fmt.Sprintf("/usr/bin/snap run%s %s", command, app.Snap.InstanceName())
 	}
-	return fmt.Sprintf("/usr/bin/snap run%s %s.%s", command, app.Snap.Name(), filepath.Base(app.Name))
+	return fmt.Sprintf("/usr/bin/snap run%s %s.%s", command, app.Snap.InstanceName(), filepath.Base(app.Name))
 }
 
 // LauncherCommand returns the launcher command line to use when invoking the app binary.
@@ -427,7 +498,7 @@ func (app *AppInfo) Env() []string {
 // Security tags are used by various security subsystems as "profile names" and
 // sometimes also as a part of the file name.
 func (hook *HookInfo) SecurityTag() string {
-	return HookSecurityTag(hook.Snap.Name(), hook.Name)
+	return HookSecurityTag(hook.Snap.InstanceName(), hook.Name)
 }
 
 // Env returns the hook-specific environment overrides
@@ -462,7 +533,7 @@ func (e NotFoundError) Error() string {
 	return fmt.Sprintf("cannot find installed snap %q at revision %s", e.Snap, e.Revision)
 }
 
-// ReadInfo reads the snap information for the installed snap with the given name and given side-info.
+// ReadInfo reads the snap information for the installed snap instance with the given name and given side-info.
 func ReadInfo(name string, si *SideInfo) (*Info, error) {
 	snapYamlFn := filepath.Join(MountDir(name, si.Revision), "meta", "snap.yaml")
 	meta, err := ioutil.ReadFile(snapYamlFn)
@@ -477,6 +548,7 @@ func ReadInfo(name string, si *SideInfo) (*Info, error) {
 	if err != nil {
 		return nil, err
 	}
+	_, info.InstanceKey = SplitInstanceName(name)
 
 	st, err := os.Stat(MountFile(name, si.Revision))
 	if err != nil {
@@ -525,11 +597,12 @@ func ReadInfoFromSnapFile(snapf Container, si *SideInfo) (*Info, error) {
 
 // SplitSnapApp will split a string of the form `snap.app` into
 // the `snap` and the `app` part. It also deals with the special
-// case of snapName == appName.
+// case of snapName == appName, where for a snap instance like
+// `snap_key` the app is named after the snap.
 func SplitSnapApp(snapApp string) (snap, app string) {
 	l := strings.SplitN(snapApp, ".", 2)
 	if len(l) < 2 {
-		return l[0], l[0]
+		return l[0], InstanceSnap(l[0])
 	}
 	return l[0], l[1]
 }
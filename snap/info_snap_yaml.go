@@ -34,6 +34,7 @@ type snapYaml struct {
 	Name             string                 `yaml:"name"`
 	Version          string                 `yaml:"version"`
 	Type             Type                   `yaml:"type"`
+	Base             string                 `yaml:"base,omitempty"`
 	Architectures    []string               `yaml:"architectures,omitempty"`
 	Assumes          []string               `yaml:"assumes"`
 	Description      string                 `yaml:"description"`
@@ -66,13 +67,16 @@ type appYaml struct {
 
 	Environment map[string]string `yaml:"environment,omitempty"`
 
+	Aliases []string `yaml:"aliases,omitempty"`
+
 	Socket       bool   `yaml:"socket,omitempty"`
 	ListenStream string `yaml:"listen-stream,omitempty"`
 	SocketMode   string `yaml:"socket-mode,omitempty"`
 }
 
 type hookYaml struct {
-	PlugNames []string `yaml:"plugs,omitempty"`
+	PlugNames []string        `yaml:"plugs,omitempty"`
+	Timeout   timeout.Timeout `yaml:"timeout,omitempty"`
 }
 
 // InfoFromSnapYaml creates a new info based on the given snap.yaml data
@@ -110,6 +114,9 @@ func InfoFromSnapYaml(yamlData []byte) (*Info, error) {
 	// Collect all apps and hooks
 	setAppsFromSnapYaml(y, snap)
 	setHooksFromSnapYaml(y, snap)
+	if err := setAliasesFromSnapYaml(y, snap); err != nil {
+		return nil, err
+	}
 
 	// Bind unbound plugs to all apps and hooks
 	bindUnboundPlugs(globalPlugNames, snap)
@@ -147,6 +154,7 @@ func infoSkeletonFromSnapYaml(y snapYaml) *Info {
 		SuggestedName:       y.Name,
 		Version:             y.Version,
 		Type:                typ,
+		Base:                y.Base,
 		Architectures:       architectures,
 		Assumes:             y.Assumes,
 		OriginalDescription: y.Description,
@@ -218,6 +226,21 @@ func setSlotsFromSnapYaml(y snapYaml, snap *Info) error {
 	return nil
 }
 
+func setAliasesFromSnapYaml(y snapYaml, snap *Info) error {
+	for appName, yApp := range y.Apps {
+		for _, alias := range yApp.Aliases {
+			if other, ok := snap.Aliases[alias]; ok {
+				return fmt.Errorf("cannot set %q as alias for both %q and %q", alias, other.Name, appName)
+			}
+			if snap.Aliases == nil {
+				snap.Aliases = make(map[string]*AppInfo)
+			}
+			snap.Aliases[alias] = snap.Apps[appName]
+		}
+	}
+	return nil
+}
+
 func setAppsFromSnapYaml(y snapYaml, snap *Info) {
 	for appName, yApp := range y.Apps {
 		// Collect all apps
@@ -284,8 +307,9 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info) {
 
 		// Collect all hooks
 		hook := &HookInfo{
-			Snap: snap,
-			Name: hookName,
+			Snap:    snap,
+			Name:    hookName,
+			Timeout: yHook.Timeout,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
 			hook.Plugs = make(map[string]*PlugInfo)
@@ -817,6 +817,35 @@ hooks:
 	})
 }
 
+func (s *YamlSuite) TestUnmarshalHookWithTimeout(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	info, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    test-hook:
+        timeout: 30s
+`))
+	c.Assert(err, IsNil)
+	c.Assert(info.Hooks, HasLen, 1)
+
+	c.Check(info.Hooks["test-hook"], DeepEquals, &snap.HookInfo{
+		Snap:    info,
+		Name:    "test-hook",
+		Timeout: timeout.Timeout(30 * time.Second),
+	})
+}
+
+func (s *YamlSuite) TestUnmarshalHookWithBadTimeout(c *C) {
+	// NOTE: yaml content cannot use tabs, indent the section with spaces.
+	_, err := snap.InfoFromSnapYaml([]byte(`
+name: snap
+hooks:
+    test-hook:
+        timeout: forever
+`))
+	c.Check(err, ErrorMatches, `info failed to parse: time: invalid duration "?forever"?`)
+}
+
 func (s *YamlSuite) TestUnmarshalUnsupportedHook(c *C) {
 	s.restore()
 	hookType := snap.NewHookType(regexp.MustCompile("not-test-hook"))
@@ -1218,6 +1247,24 @@ version: 1.0
 	c.Assert(info.Type, Equals, snap.TypeApp)
 }
 
+func (s *YamlSuite) TestSnapYamlBase(c *C) {
+	y := []byte(`name: binary
+version: 1.0
+base: some-base
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Base, Equals, "some-base")
+
+	info, err = snap.InfoFromSnapYaml([]byte(`name: some-base
+version: 1.0
+type: base
+`))
+	c.Assert(err, IsNil)
+	c.Check(info.Type, Equals, snap.TypeBase)
+	c.Check(info.Base, Equals, "")
+}
+
 func (s *YamlSuite) TestSnapYamlEpochDefault(c *C) {
 	y := []byte(`name: binary
 version: 1.0
@@ -1389,6 +1436,39 @@ apps:
 	})
 }
 
+func (s *YamlSuite) TestSnapYamlAliases(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ foo:
+  aliases: [foo, bar]
+ baz:
+  aliases: [baz-qux]
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Assert(info.Aliases, DeepEquals, map[string]*snap.AppInfo{
+		"foo":     info.Apps["foo"],
+		"bar":     info.Apps["foo"],
+		"baz-qux": info.Apps["baz"],
+	})
+}
+
+func (s *YamlSuite) TestSnapYamlAliasesConflict(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+ foo:
+  aliases: [bar]
+ baz:
+  aliases: [bar]
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, ErrorMatches, `cannot set "bar" as alias for both ("foo" and "baz"|"baz" and "foo")`)
+}
+
 // classic confinement
 func (s *YamlSuite) TestClassicConfinement(c *C) {
 	y := []byte(`
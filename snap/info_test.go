@@ -91,6 +91,12 @@ apps:
 
 	c.Check(info.Apps["bar"].WrapperPath(), Equals, filepath.Join(dirs.SnapBinariesDir, "foo.bar"))
 	c.Check(info.Apps["foo"].WrapperPath(), Equals, filepath.Join(dirs.SnapBinariesDir, "foo"))
+
+	info.InstanceKey = "blue"
+	c.Check(info.Apps["bar"].WrapperPath(), Equals, filepath.Join(dirs.SnapBinariesDir, "foo_blue.bar"))
+	c.Check(info.Apps["foo"].WrapperPath(), Equals, filepath.Join(dirs.SnapBinariesDir, "foo_blue"))
+	c.Check(info.Apps["bar"].SecurityTag(), Equals, "snap.foo_blue.bar")
+	c.Check(info.Apps["foo"].LauncherCommand(), Equals, "/usr/bin/snap run foo_blue")
 }
 
 func (s *infoSuite) TestAppInfoLauncherCommand(c *C) {
@@ -136,6 +142,21 @@ func (s *infoSuite) TestReadInfo(c *C) {
 	c.Check(snapInfo2, DeepEquals, snapInfo1)
 }
 
+func (s *infoSuite) TestReadInfoParallelInstall(c *C) {
+	si := &snap.SideInfo{RealName: "sample", Revision: snap.R(42)}
+
+	info := snaptest.MockSnap(c, sampleYaml, sampleContents, si)
+	c.Assert(os.Rename(filepath.Dir(info.MountDir()), filepath.Join(dirs.SnapMountDir, "sample_blue")), IsNil)
+	c.Assert(os.Rename(info.MountFile(), filepath.Join(dirs.SnapBlobDir, "sample_blue_42.snap")), IsNil)
+
+	info, err := snap.ReadInfo("sample_blue", si)
+	c.Assert(err, IsNil)
+	c.Check(info.Name(), Equals, "sample")
+	c.Check(info.InstanceKey, Equals, "blue")
+	c.Check(info.InstanceName(), Equals, "sample_blue")
+	c.Check(info.MountDir(), Equals, filepath.Join(dirs.SnapMountDir, "sample_blue", "42"))
+}
+
 // makeTestSnap here can also be used to produce broken snaps (differently from snaptest.MakeTestSnapWithFiles)!
 func makeTestSnap(c *C, yaml string) string {
 	tmp := c.MkDir()
@@ -318,12 +339,33 @@ func (s *infoSuite) TestSplitSnapApp(c *C) {
 		{"foo.bar.baz", []string{"foo", "bar.baz"}},
 		// special case, snapName == appName
 		{"foo", []string{"foo", "foo"}},
+		// snap instances
+		{"foo_blue.bar", []string{"foo_blue", "bar"}},
+		{"foo_blue", []string{"foo_blue", "foo"}},
 	} {
 		snap, app := snap.SplitSnapApp(t.in)
 		c.Check([]string{snap, app}, DeepEquals, t.out)
 	}
 }
 
+func (s *infoSuite) TestInstanceNames(c *C) {
+	c.Check(snap.InstanceName("foo", ""), Equals, "foo")
+	c.Check(snap.InstanceName("foo", "blue"), Equals, "foo_blue")
+
+	for _, t := range []struct {
+		in  string
+		out []string
+	}{
+		{"foo", []string{"foo", ""}},
+		{"foo_blue", []string{"foo", "blue"}},
+		{"foo-bar_1", []string{"foo-bar", "1"}},
+	} {
+		snapName, instanceKey := snap.SplitInstanceName(t.in)
+		c.Check([]string{snapName, instanceKey}, DeepEquals, t.out)
+		c.Check(snap.InstanceSnap(t.in), Equals, t.out[0])
+	}
+}
+
 func ExampleSpltiSnapApp() {
 	fmt.Println(snap.SplitSnapApp("hello-world.env"))
 	// Output: hello-world env
@@ -483,3 +525,27 @@ func (s *infoSuite) TestDirAndFileMethods(c *C) {
 	c.Check(info.CommonDataHomeDir(), Equals, "/home/*/snap/name/common")
 	c.Check(info.XdgRuntimeDirs(), Equals, "/run/user/*/snap.name")
 }
+
+func (s *infoSuite) TestDirAndFileMethodsParallelInstall(c *C) {
+	dirs.SetRootDir("")
+	info := &snap.Info{SuggestedName: "name", InstanceKey: "blue", SideInfo: snap.SideInfo{Revision: snap.R(1)}}
+	c.Check(info.InstanceName(), Equals, "name_blue")
+	c.Check(info.MountDir(), Equals, fmt.Sprintf("%s/name_blue/1", dirs.SnapMountDir))
+	c.Check(info.MountFile(), Equals, "/var/lib/snapd/snaps/name_blue_1.snap")
+	c.Check(info.HooksDir(), Equals, fmt.Sprintf("%s/name_blue/1/meta/hooks", dirs.SnapMountDir))
+	c.Check(info.DataDir(), Equals, "/var/snap/name_blue/1")
+	c.Check(info.UserDataDir("/home/bob"), Equals, "/home/bob/snap/name_blue/1")
+	c.Check(info.UserCommonDataDir("/home/bob"), Equals, "/home/bob/snap/name_blue/common")
+	c.Check(info.CommonDataDir(), Equals, "/var/snap/name_blue/common")
+	c.Check(info.UserXdgRuntimeDir(12345), Equals, "/run/user/12345/snap.name_blue")
+	c.Check(info.DataHomeDir(), Equals, "/home/*/snap/name_blue/1")
+	c.Check(info.CommonDataHomeDir(), Equals, "/home/*/snap/name_blue/common")
+	c.Check(info.XdgRuntimeDirs(), Equals, "/run/user/*/snap.name_blue")
+}
+
+func (s *infoSuite) TestMinimalPlaceInfoParallelInstall(c *C) {
+	place := snap.MinimalPlaceInfo("name_blue", snap.R(1))
+	c.Check(place.Name(), Equals, "name")
+	c.Check(place.InstanceName(), Equals, "name_blue")
+	c.Check(place.MountDir(), Equals, fmt.Sprintf("%s/name_blue/1", dirs.SnapMountDir))
+}
@@ -59,19 +59,34 @@ func (ts *HTestSuite) TestBasic(c *C) {
 	env := basicEnv(mockSnapInfo)
 
 	c.Assert(env, DeepEquals, map[string]string{
-		"SNAP":              fmt.Sprintf("%s/foo/17", dirs.SnapMountDir),
-		"SNAP_ARCH":         arch.UbuntuArchitecture(),
-		"SNAP_COMMON":       "/var/snap/foo/common",
-		"SNAP_DATA":         "/var/snap/foo/17",
-		"SNAP_LIBRARY_PATH": "/var/lib/snapd/lib/gl:",
-		"SNAP_NAME":         "foo",
-		"SNAP_REEXEC":       "",
-		"SNAP_REVISION":     "17",
-		"SNAP_VERSION":      "1.0",
+		"SNAP":               fmt.Sprintf("%s/foo/17", dirs.SnapMountDir),
+		"SNAP_ARCH":          arch.UbuntuArchitecture(),
+		"SNAP_COMMON":        "/var/snap/foo/common",
+		"SNAP_DATA":          "/var/snap/foo/17",
+		"SNAP_LIBRARY_PATH":  "/var/lib/snapd/lib/gl:",
+		"SNAP_NAME":          "foo",
+		"SNAP_INSTANCE_NAME": "foo",
+		"SNAP_INSTANCE_KEY":  "",
+		"SNAP_REEXEC":        "",
+		"SNAP_REVISION":      "17",
+		"SNAP_VERSION":       "1.0",
 	})
 
 }
 
+func (ts *HTestSuite) TestBasicParallelInstall(c *C) {
+	info := *mockSnapInfo
+	info.InstanceKey = "blue"
+	env := basicEnv(&info)
+
+	c.Check(env["SNAP"], Equals, fmt.Sprintf("%s/foo_blue/17", dirs.SnapMountDir))
+	c.Check(env["SNAP_COMMON"], Equals, "/var/snap/foo_blue/common")
+	c.Check(env["SNAP_DATA"], Equals, "/var/snap/foo_blue/17")
+	c.Check(env["SNAP_NAME"], Equals, "foo")
+	c.Check(env["SNAP_INSTANCE_NAME"], Equals, "foo_blue")
+	c.Check(env["SNAP_INSTANCE_KEY"], Equals, "blue")
+}
+
 func (ts *HTestSuite) TestUser(c *C) {
 	env := userEnv(mockSnapInfo, "/root")
 
@@ -101,19 +116,21 @@ func (s *HTestSuite) TestSnapRunSnapExecEnv(c *C) {
 
 		env := snapEnv(info)
 		c.Check(env, DeepEquals, map[string]string{
-			"HOME":              fmt.Sprintf("%s/snap/snapname/42", usr.HomeDir),
-			"SNAP":              fmt.Sprintf("%s/snapname/42", dirs.SnapMountDir),
-			"SNAP_ARCH":         arch.UbuntuArchitecture(),
-			"SNAP_COMMON":       "/var/snap/snapname/common",
-			"SNAP_DATA":         "/var/snap/snapname/42",
-			"SNAP_LIBRARY_PATH": "/var/lib/snapd/lib/gl:",
-			"SNAP_NAME":         "snapname",
-			"SNAP_REEXEC":       "",
-			"SNAP_REVISION":     "42",
-			"SNAP_USER_COMMON":  fmt.Sprintf("%s/snap/snapname/common", usr.HomeDir),
-			"SNAP_USER_DATA":    fmt.Sprintf("%s/snap/snapname/42", usr.HomeDir),
-			"SNAP_VERSION":      "1.0",
-			"XDG_RUNTIME_DIR":   fmt.Sprintf("/run/user/%d/snap.snapname", os.Geteuid()),
+			"HOME":               fmt.Sprintf("%s/snap/snapname/42", usr.HomeDir),
+			"SNAP":               fmt.Sprintf("%s/snapname/42", dirs.SnapMountDir),
+			"SNAP_ARCH":          arch.UbuntuArchitecture(),
+			"SNAP_COMMON":        "/var/snap/snapname/common",
+			"SNAP_DATA":          "/var/snap/snapname/42",
+			"SNAP_LIBRARY_PATH":  "/var/lib/snapd/lib/gl:",
+			"SNAP_NAME":          "snapname",
+			"SNAP_INSTANCE_NAME": "snapname",
+			"SNAP_INSTANCE_KEY":  "",
+			"SNAP_REEXEC":        "",
+			"SNAP_REVISION":      "42",
+			"SNAP_USER_COMMON":   fmt.Sprintf("%s/snap/snapname/common", usr.HomeDir),
+			"SNAP_USER_DATA":     fmt.Sprintf("%s/snap/snapname/42", usr.HomeDir),
+			"SNAP_VERSION":       "1.0",
+			"XDG_RUNTIME_DIR":    fmt.Sprintf("/run/user/%d/snap.snapname", os.Geteuid()),
 		})
 	}
 }
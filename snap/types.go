@@ -24,7 +24,7 @@ import (
 	"fmt"
 )
 
-// Type represents the kind of snap (app, core, gadget, os, kernel)
+// Type represents the kind of snap (app, core, gadget, os, kernel, base)
 type Type string
 
 // The various types of snap parts we support
@@ -33,6 +33,7 @@ const (
 	TypeGadget Type = "gadget"
 	TypeOS     Type = "os"
 	TypeKernel Type = "kernel"
+	TypeBase   Type = "base"
 )
 
 // UnmarshalJSON sets *m to a copy of data.
@@ -65,7 +66,7 @@ func (m *Type) fromString(str string) error {
 		t = TypeApp
 	}
 
-	if t != TypeApp && t != TypeGadget && t != TypeOS && t != TypeKernel {
+	if t != TypeApp && t != TypeGadget && t != TypeOS && t != TypeKernel && t != TypeBase {
 		return fmt.Errorf("invalid snap type: %q", str)
 	}
 
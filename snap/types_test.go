@@ -128,6 +128,10 @@ func (s *typeSuite) TestYamlUnmarshalTypes(c *C) {
 	err = yaml.Unmarshal([]byte("kernel"), &st)
 	c.Assert(err, IsNil)
 	c.Check(st, Equals, TypeKernel)
+
+	err = yaml.Unmarshal([]byte("base"), &st)
+	c.Assert(err, IsNil)
+	c.Check(st, Equals, TypeBase)
 }
 
 func (s *typeSuite) TestYamlUnmarshalInvalidTypes(c *C) {
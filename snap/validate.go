@@ -22,12 +22,15 @@ package snap
 import (
 	"fmt"
 	"regexp"
+	"strings"
 )
 
 // Regular expression describing correct identifiers.
 var validSnapName = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
 var validEpoch = regexp.MustCompile("^(?:0|[1-9][0-9]*[*]?)$")
 var validHookName = regexp.MustCompile("^[a-z](?:-?[a-z0-9])*$")
+var validAlias = regexp.MustCompile("^[a-zA-Z0-9][-_.a-zA-Z0-9]*$")
+var validInstanceKey = regexp.MustCompile("^[a-z0-9]{1,10}$")
 
 // ValidateName checks if a string can be used as a snap name.
 func ValidateName(name string) error {
@@ -38,6 +41,20 @@ func ValidateName(name string) error {
 	return nil
 }
 
+// ValidateInstanceName checks if a string can be used as a snap
+// instance name, that is a snap name optionally followed by an
+// underscore and an instance key.
+func ValidateInstanceName(instanceName string) error {
+	snapName, instanceKey := SplitInstanceName(instanceName)
+	if err := ValidateName(snapName); err != nil {
+		return err
+	}
+	if strings.Contains(instanceName, "_") && !validInstanceKey.MatchString(instanceKey) {
+		return fmt.Errorf("invalid instance key: %q", instanceKey)
+	}
+	return nil
+}
+
 // ValidateEpoch checks if a string can be used as a snap epoch.
 func ValidateEpoch(epoch string) error {
 	valid := validEpoch.MatchString(epoch)
@@ -47,6 +64,15 @@ func ValidateEpoch(epoch string) error {
 	return nil
 }
 
+// ValidateAlias checks if a string can be used as an alias name.
+func ValidateAlias(alias string) error {
+	valid := validAlias.MatchString(alias)
+	if !valid {
+		return fmt.Errorf("invalid alias name: %q", alias)
+	}
+	return nil
+}
+
 // ValidateHook validates the content of the given HookInfo
 func ValidateHook(hook *HookInfo) error {
 	valid := validHookName.MatchString(hook.Name)
@@ -67,6 +93,15 @@ func Validate(info *Info) error {
 		return err
 	}
 
+	if info.Base != "" {
+		if info.Type == TypeBase || info.Type == TypeOS || info.Type == TypeKernel {
+			return fmt.Errorf("cannot have %q base on a snap of type %q", info.Base, info.Type)
+		}
+		if err := ValidateName(info.Base); err != nil {
+			return fmt.Errorf("invalid base name: %s", err)
+		}
+	}
+
 	epoch := info.Epoch
 	if epoch == "" {
 		return fmt.Errorf("snap epoch cannot be empty")
@@ -92,6 +127,13 @@ func Validate(info *Info) error {
 		}
 	}
 
+	// validate alias entries
+	for alias := range info.Aliases {
+		if err := ValidateAlias(alias); err != nil {
+			return err
+		}
+	}
+
 	// ensure that plug and slot have unique names
 	if err := plugsSlotsUniqueNames(info); err != nil {
 		return err
@@ -64,6 +64,17 @@ func (s *ValidateSuite) TestValidateName(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateInstanceName(c *C) {
+	for _, name := range []string{"foo", "foo_blue", "foo-bar_1", "foo_0123456789"} {
+		c.Check(ValidateInstanceName(name), IsNil)
+	}
+	c.Check(ValidateInstanceName("foo--bar_blue"), ErrorMatches, `invalid snap name: "foo--bar"`)
+	c.Check(ValidateInstanceName("_blue"), ErrorMatches, `invalid snap name: ""`)
+	for _, name := range []string{"foo_", "foo_Blue", "foo_blue_2", "foo_01234567890", "foo_b-e"} {
+		c.Check(ValidateInstanceName(name), ErrorMatches, `invalid instance key: ".*"`)
+	}
+}
+
 func (s *ValidateSuite) TestValidateEpoch(c *C) {
 	validEpochs := []string{
 		"0", "1*", "1", "400*", "1234",
@@ -111,6 +122,36 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 	}
 }
 
+func (s *ValidateSuite) TestValidateAlias(c *C) {
+	validAliases := []string{
+		"a", "aa", "aaa", "aaaa", "a-a", "a.a", "a_a", "Aa", "1a", "a1", "1-a", "a.b-c_d",
+	}
+	for _, alias := range validAliases {
+		err := ValidateAlias(alias)
+		c.Assert(err, IsNil)
+	}
+	invalidAliases := []string{
+		"", "-a", ".a", "_a", "a a", "a/b", "a:b", "日本語",
+	}
+	for _, alias := range invalidAliases {
+		err := ValidateAlias(alias)
+		c.Assert(err, ErrorMatches, `invalid alias name: ".*"`)
+	}
+}
+
+func (s *ValidateSuite) TestIllegalAlias(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+apps:
+ foo:
+  aliases: [foo/bar]
+`))
+	c.Assert(err, IsNil)
+
+	err = Validate(info)
+	c.Check(err, ErrorMatches, `invalid alias name: "foo/bar"`)
+}
+
 // ValidateApp
 
 func (s *ValidateSuite) TestValidateAppName(c *C) {
@@ -208,6 +249,26 @@ version: 1.0
 	c.Check(err, ErrorMatches, `snap name cannot be empty`)
 }
 
+func (s *ValidateSuite) TestValidateBase(c *C) {
+	info, err := InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+base: some-base
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), IsNil)
+
+	info.Base = "some.base"
+	c.Check(Validate(info), ErrorMatches, `invalid base name: invalid snap name: "some.base"`)
+
+	info, err = InfoFromSnapYaml([]byte(`name: foo
+version: 1.0
+type: base
+base: other-base
+`))
+	c.Assert(err, IsNil)
+	c.Check(Validate(info), ErrorMatches, `cannot have "other-base" base on a snap of type "base"`)
+}
+
 func (s *ValidateSuite) TestIllegalSnapEpoch(c *C) {
 	info, err := InfoFromSnapYaml([]byte(`name: foo
 version: 1.0
@@ -27,7 +27,9 @@ import (
 type snapDetails struct {
 	AnonDownloadURL  string             `json:"anon_download_url,omitempty"`
 	Architectures    []string           `json:"architecture"`
+	Base             string             `json:"base,omitempty"`
 	Channel          string             `json:"channel,omitempty"`
+	ChannelMapList   []channelMap       `json:"channel_maps_list,omitempty"`
 	DownloadSha3_384 string             `json:"download_sha3_384,omitempty"`
 	Summary          string             `json:"summary,omitempty"`
 	Description      string             `json:"description,omitempty"`
@@ -36,6 +38,7 @@ type snapDetails struct {
 	DownloadURL      string             `json:"download_url,omitempty"`
 	IconURL          string             `json:"icon_url"`
 	LastUpdated      string             `json:"last_updated,omitempty"`
+	License          string             `json:"license,omitempty"`
 	Name             string             `json:"package_name"`
 	Prices           map[string]float64 `json:"prices,omitempty"`
 	Publisher        string             `json:"publisher,omitempty"`
@@ -43,6 +46,7 @@ type snapDetails struct {
 	Revision         int                `json:"revision"` // store revisions are ints starting at 1
 	ScreenshotURLs   []string           `json:"screenshot_urls,omitempty"`
 	SnapID           string             `json:"snap_id"`
+	SnapYAML         string             `json:"snap_yaml_raw,omitempty"`
 	SupportURL       string             `json:"support_url"`
 	Title            string             `json:"title"`
 	Type             snap.Type          `json:"content,omitempty"`
@@ -67,3 +71,20 @@ type snapDeltaDetail struct {
 	Size            int64  `json:"binary_filesize,omitempty"`
 	Sha3_384        string `json:"download_sha3_384,omitempty"`
 }
+
+// channelMap holds the details of the snap in each channel of a track.
+type channelMap struct {
+	Track       string               `json:"track"`
+	SnapDetails []channelSnapDetails `json:"map"`
+}
+
+// channelSnapDetails holds the details of the snap in a single channel;
+// a closed channel has no revision.
+type channelSnapDetails struct {
+	Channel      string `json:"channel"`
+	Revision     int    `json:"revision"`
+	Version      string `json:"version"`
+	Epoch        string `json:"epoch"`
+	Confinement  string `json:"confinement"`
+	DownloadSize int64  `json:"binary_filesize"`
+}
@@ -90,6 +90,7 @@ func infoFromRemote(d snapDetails) *snap.Info {
 	info := &snap.Info{}
 	info.Architectures = d.Architectures
 	info.Type = d.Type
+	info.Base = d.Base
 	info.Version = d.Version
 	info.Epoch = "0"
 	info.RealName = d.Name
@@ -108,6 +109,51 @@ func infoFromRemote(d snapDetails) *snap.Info {
 	info.Prices = d.Prices
 	info.Private = d.Private
 	info.Confinement = snap.ConfinementType(d.Confinement)
+	info.Publisher = d.Publisher
+	info.License = d.License
+	info.Contact = d.SupportURL
+
+	// the plugs and slots are only known from the snap.yaml passed on by the store
+	if d.SnapYAML != "" {
+		yamlInfo, err := snap.InfoFromSnapYaml([]byte(d.SnapYAML))
+		if err != nil {
+			logger.Noticef("cannot parse snap.yaml of snap %q from the store: %v", d.Name, err)
+		} else {
+			info.Plugs = yamlInfo.Plugs
+			for _, plug := range info.Plugs {
+				plug.Snap = info
+			}
+			info.Slots = yamlInfo.Slots
+			for _, slot := range info.Slots {
+				slot.Snap = info
+			}
+		}
+	}
+
+	if len(d.ChannelMapList) > 0 {
+		info.Channels = make(map[string]*snap.ChannelSnapInfo)
+		for _, cm := range d.ChannelMapList {
+			info.Tracks = append(info.Tracks, cm.Track)
+			for _, ch := range cm.SnapDetails {
+				if ch.Revision == 0 {
+					// closed channel
+					continue
+				}
+				name := ch.Channel
+				if cm.Track != "latest" {
+					name = cm.Track + "/" + ch.Channel
+				}
+				info.Channels[name] = &snap.ChannelSnapInfo{
+					Revision:    snap.R(ch.Revision),
+					Version:     ch.Version,
+					Channel:     name,
+					Epoch:       ch.Epoch,
+					Confinement: snap.ConfinementType(ch.Confinement),
+					Size:        ch.DownloadSize,
+				}
+			}
+		}
+	}
 
 	deltas := make([]snap.DeltaInfo, len(d.Deltas))
 	for i, d := range d.Deltas {
@@ -994,6 +1040,10 @@ type RefreshCandidate struct {
 
 	// the desired channel
 	Channel string
+
+	// InstanceKey identifies the parallel instance of the snap
+	// the candidate is for, it's set on the returned update
+	InstanceKey string
 }
 
 // the exact bits that we need to send to the store
@@ -1016,8 +1066,39 @@ type metadataWrapper struct {
 }
 
 // ListRefresh returns the available updates for a list of snap identified by fullname with channel.
+//
+// Parallel instances of a snap share its snap id, the store is asked
+// about each of them in a separate query so that every instance gets
+// the update for its own channel and revision.
 func (s *Store) ListRefresh(installed []*RefreshCandidate, user *auth.UserState) (snaps []*snap.Info, err error) {
+	// split the candidates into rounds with at most one
+	// candidate per snap id each
+	var rounds [][]*RefreshCandidate
+	seen := make(map[string]int)
+	for _, cs := range installed {
+		n := seen[cs.SnapID]
+		seen[cs.SnapID] = n + 1
+		if n == len(rounds) {
+			rounds = append(rounds, nil)
+		}
+		rounds[n] = append(rounds[n], cs)
+	}
+	if len(rounds) == 0 {
+		// still ask the store, for the currency
+		rounds = append(rounds, nil)
+	}
 
+	for _, round := range rounds {
+		res, err := s.listRefresh(round, user)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, res...)
+	}
+	return snaps, nil
+}
+
+func (s *Store) listRefresh(installed []*RefreshCandidate, user *auth.UserState) ([]*snap.Info, error) {
 	candidateMap := map[string]*RefreshCandidate{}
 	currentSnaps := make([]currentSnapJson, 0, len(installed))
 	for _, cs := range installed {
@@ -1109,7 +1190,9 @@ func (s *Store) ListRefresh(installed []*RefreshCandidate, user *auth.UserState)
 			if findRev(rrev, cand.Block) {
 				continue
 			}
-			res = append(res, infoFromRemote(rsnap))
+			info := infoFromRemote(rsnap)
+			info.InstanceKey = cand.InstanceKey
+			res = append(res, info)
 		}
 
 		s.extractSuggestedCurrency(resp)
@@ -1246,13 +1329,16 @@ var download = func(name, sha3_384, downloadURL string, user *auth.UserState, s
 	mw := io.MultiWriter(w, h, pbar)
 	_, err = io.Copy(mw, resp.Body)
 	pbar.Finished()
+	if err != nil {
+		return err
+	}
 
 	actualSha3 := fmt.Sprintf("%x", h.Sum(nil))
 	if sha3_384 != "" && sha3_384 != actualSha3 {
 		return fmt.Errorf("sha3-384 mismatch downloading %s: got %s but expected %s", name, actualSha3, sha3_384)
 	}
 
-	return err
+	return nil
 }
 
 // downloadDelta downloads the delta for the preferred format, returning the path.
@@ -1361,11 +1447,30 @@ type assertionSvcError struct {
 
 // Assertion retrivies the assertion for the given type and primary key.
 func (s *Store) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	return s.assertion(assertType, primaryKey, nil, user)
+}
+
+// SeqFormingAssertion retrieves the sequence-forming assertion for the
+// given type, sequence key (the primary key without the sequence) and
+// sequence, or the one with the latest sequence if sequence is 0.
+func (s *Store) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	v := url.Values{}
+	if sequence > 0 {
+		v.Set("sequence", strconv.Itoa(sequence))
+	} else {
+		v.Set("sequence", "latest")
+	}
+	return s.assertion(assertType, sequenceKey, v, user)
+}
+
+func (s *Store) assertion(assertType *asserts.AssertionType, primaryKey []string, v url.Values, user *auth.UserState) (asserts.Assertion, error) {
 	u, err := s.assertionsURI.Parse(path.Join(assertType.Name, path.Join(primaryKey...)))
 	if err != nil {
 		return nil, err
 	}
-	v := url.Values{}
+	if v == nil {
+		v = url.Values{}
+	}
 	v.Set("max-format", strconv.Itoa(assertType.MaxSupportedFormat()))
 	u.RawQuery = v.Encode()
 
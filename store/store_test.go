@@ -1156,6 +1156,7 @@ http --pretty=format --print b https://search.apps.ubuntu.com/api/v1/snaps/detai
 on 2016-07-03. Then, by hand:
  * set prices to {"EUR": 0.99, "USD": 1.23}.
  * Screenshot URLS set manually.
+ * channel_maps_list and license added manually.
 
 On Ubuntu, apt install httpie xsel (although you could get http from
 the http snap instead).
@@ -1180,6 +1181,26 @@ const MockDetailsJSON = `{
     ],
     "binary_filesize": 20480,
     "channel": "edge",
+    "channel_maps_list": [
+        {
+            "track": "latest",
+            "map": [
+                {"channel": "stable", "revision": 26, "version": "6.1", "epoch": "0", "confinement": "strict", "binary_filesize": 20000},
+                {"channel": "candidate", "revision": 26, "version": "6.1", "epoch": "0", "confinement": "strict", "binary_filesize": 20000},
+                {"channel": "beta", "info": null},
+                {"channel": "edge", "revision": 27, "version": "6.3", "epoch": "0", "confinement": "strict", "binary_filesize": 20480}
+            ]
+        },
+        {
+            "track": "7.0",
+            "map": [
+                {"channel": "stable", "info": null},
+                {"channel": "candidate", "info": null},
+                {"channel": "beta", "info": null},
+                {"channel": "edge", "revision": 28, "version": "7.0~pre1", "epoch": "0", "confinement": "devmode", "binary_filesize": 20500}
+            ]
+        }
+    ],
     "confinement": "strict",
     "content": "application",
     "description": "This is a simple hello world example.",
@@ -1188,6 +1209,7 @@ const MockDetailsJSON = `{
     "download_url": "https://public.apps.ubuntu.com/download-snap/buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ_27.snap",
     "icon_url": "https://myapps.developer.ubuntu.com/site_media/appmedia/2015/03/hello.svg_NZLfWbh.png",
     "last_updated": "2016-07-12T16:37:23.960632Z",
+    "license": "GPL-3.0",
     "origin": "canonical",
     "package_name": "hello-world",
     "prices": {"EUR": 0.99, "USD": 1.23},
@@ -1246,6 +1268,35 @@ const mockSingleOrderJSON = `{
   ]
 }`
 
+func (t *remoteRepoTestSuite) TestInfoFromRemotePlugsAndSlots(c *C) {
+	info := infoFromRemote(snapDetails{
+		Name: "content-consumer",
+		SnapYAML: `name: content-consumer
+version: 1.0
+plugs:
+ shared:
+  interface: content
+  target: import
+  default-provider: content-provider
+slots:
+ data:
+  interface: content
+  read: [$SNAP/data]
+`,
+	})
+	c.Assert(info.Plugs, HasLen, 1)
+	plug := info.Plugs["shared"]
+	c.Check(plug.Interface, Equals, "content")
+	c.Check(plug.Attrs["default-provider"], Equals, "content-provider")
+	c.Check(plug.Snap, Equals, info)
+	c.Assert(info.Slots, HasLen, 1)
+	c.Check(info.Slots["data"].Snap, Equals, info)
+
+	// a broken snap.yaml is ignored
+	info = infoFromRemote(snapDetails{Name: "foo", SnapYAML: "\t:"})
+	c.Check(info.Plugs, HasLen, 0)
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetails(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.UserAgent(), Equals, userAgent)
@@ -1306,6 +1357,16 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryDetails(c *C) {
 		},
 	})
 	c.Check(result.MustBuy, Equals, true)
+	c.Check(result.Publisher, Equals, "Canonical")
+	c.Check(result.License, Equals, "GPL-3.0")
+	c.Check(result.Contact, Equals, "mailto:snappy-devel@lists.ubuntu.com")
+	c.Check(result.Tracks, DeepEquals, []string{"latest", "7.0"})
+	c.Check(result.Channels, DeepEquals, map[string]*snap.ChannelSnapInfo{
+		"stable":    {Revision: snap.R(26), Version: "6.1", Channel: "stable", Epoch: "0", Confinement: snap.StrictConfinement, Size: 20000},
+		"candidate": {Revision: snap.R(26), Version: "6.1", Channel: "candidate", Epoch: "0", Confinement: snap.StrictConfinement, Size: 20000},
+		"edge":      {Revision: snap.R(27), Version: "6.3", Channel: "edge", Epoch: "0", Confinement: snap.StrictConfinement, Size: 20480},
+		"7.0/edge":  {Revision: snap.R(28), Version: "7.0~pre1", Channel: "7.0/edge", Epoch: "0", Confinement: snap.DevmodeConfinement, Size: 20500},
+	})
 
 	// Make sure the epoch (currently not sent by the store) defaults to "0"
 	c.Check(result.Epoch, Equals, "0")
@@ -2057,6 +2118,66 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefresh(c *C) {
 	c.Assert(results[0].Deltas, HasLen, 0)
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshParallelInstances(c *C) {
+	var channels []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonReq, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		var resp struct {
+			Snaps []map[string]interface{} `json:"snaps"`
+		}
+
+		err = json.Unmarshal(jsonReq, &resp)
+		c.Assert(err, IsNil)
+
+		// instances sharing a snap id are never sent together
+		c.Assert(resp.Snaps, HasLen, 1)
+		c.Assert(resp.Snaps[0]["snap_id"], Equals, helloWorldSnapID)
+		channel := resp.Snaps[0]["channel"].(string)
+		channels = append(channels, channel)
+
+		if channel == "edge" {
+			io.WriteString(w, strings.Replace(MockUpdatesJSON, `"revision": 26`, `"revision": 27`, 1))
+			return
+		}
+		io.WriteString(w, MockUpdatesJSON)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	bulkURI, err := url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+	cfg := Config{
+		BulkURI: bulkURI,
+	}
+	authContext := &testAuthContext{c: c, device: t.device}
+	repo := New(&cfg, authContext)
+	c.Assert(repo, NotNil)
+
+	results, err := repo.ListRefresh([]*RefreshCandidate{
+		{
+			SnapID:   helloWorldSnapID,
+			Channel:  "stable",
+			Revision: snap.R(1),
+			Epoch:    "0",
+		}, {
+			SnapID:      helloWorldSnapID,
+			Channel:     "edge",
+			Revision:    snap.R(1),
+			Epoch:       "0",
+			InstanceKey: "foo",
+		},
+	}, nil)
+	c.Assert(err, IsNil)
+	c.Check(channels, DeepEquals, []string{"stable", "edge"})
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].InstanceName(), Equals, "hello-world")
+	c.Check(results[0].Revision, Equals, snap.R(26))
+	c.Check(results[1].InstanceName(), Equals, "hello-world_foo")
+	c.Check(results[1].Revision, Equals, snap.R(27))
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryListRefreshUnauthorised(c *C) {
 	n := 0
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -2625,6 +2746,41 @@ func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryAssertion(c *C) {
 	c.Check(a.Type(), Equals, asserts.SnapDeclarationType)
 }
 
+func (t *remoteRepoTestSuite) TestUbuntuStoreRepositorySeqFormingAssertion(c *C) {
+	var sequences []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Accept"), Equals, "application/x.ubuntu.assertion")
+		c.Check(r.URL.Path, Equals, "/assertions/validation-set/16/account-id/set-name")
+		c.Check(r.URL.Query().Get("max-format"), Equals, "0")
+		sequences = append(sequences, r.URL.Query().Get("sequence"))
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(404)
+		io.WriteString(w, `{"status": 404,"title": "not found"}`)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	assertionsURI, err := url.Parse(mockServer.URL + "/assertions/")
+	c.Assert(err, IsNil)
+	cfg := Config{
+		AssertionsURI: assertionsURI,
+	}
+	repo := New(&cfg, nil)
+
+	sequenceKey := []string{"16", "account-id", "set-name"}
+	_, err = repo.SeqFormingAssertion(asserts.ValidationSetType, sequenceKey, 0, nil)
+	c.Check(err, DeepEquals, &AssertionNotFoundError{
+		Ref: &asserts.Ref{
+			Type:       asserts.ValidationSetType,
+			PrimaryKey: sequenceKey,
+		},
+	})
+	_, err = repo.SeqFormingAssertion(asserts.ValidationSetType, sequenceKey, 3, nil)
+	c.Check(err, FitsTypeOf, &AssertionNotFoundError{})
+	c.Check(sequences, DeepEquals, []string{"latest", "3"})
+}
+
 func (t *remoteRepoTestSuite) TestUbuntuStoreRepositoryAssertionNotFound(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.Header.Get("Accept"), Equals, "application/x.ubuntu.assertion")
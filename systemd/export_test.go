@@ -26,6 +26,8 @@ import (
 var (
 	SystemdRun = run // NOTE: plain Run clashes with check.v1
 	Jctl       = jctl
+
+	StartJournalReader = startJournalReader
 )
 
 func MockStopDelays(checkDelay, notifyDelay time.Duration) func() {
@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os/exec"
 	"path/filepath"
 	"regexp"
@@ -62,24 +63,73 @@ func run(args ...string) ([]byte, error) {
 // systemctl. It's exported so it can be overridden by testing.
 var SystemctlCmd = run
 
-// jctl calls journalctl to get the JSON logs of the given services, wrapping the error if any.
-func jctl(svcs []string) ([]byte, error) {
-	cmd := []string{"journalctl", "-o", "json"}
+// jctl calls journalctl to get the JSON logs of the given services.
+//
+// If n is not empty it limits the number of entries shown (journalctl
+// also accepts "all"), and if follow is true the returned reader keeps
+// on streaming new entries until it is closed.
+func jctl(svcs []string, n string, follow bool) (io.ReadCloser, error) {
+	cmd := []string{"journalctl", "-o", "json", "--no-pager"}
+	if n != "" {
+		cmd = append(cmd, "-n", n)
+	}
+	if follow {
+		cmd = append(cmd, "-f")
+	}
 
 	for i := range svcs {
 		cmd = append(cmd, "-u", svcs[i])
 	}
 
-	bs, err := exec.Command(cmd[0], cmd[1:]...).Output() // journalctl can be messy with its stderr
+	return startJournalReader(cmd, follow)
+}
+
+func startJournalReader(cmd []string, follow bool) (io.ReadCloser, error) {
+	c := exec.Command(cmd[0], cmd[1:]...)
+	// journalctl can be messy with its stderr, keep it only for errors
+	stderr := &bytes.Buffer{}
+	c.Stderr = stderr
+	stdout, err := c.StdoutPipe()
 	if err != nil {
-		exitCode, _ := osutil.ExitCode(err)
-		return nil, &Error{cmd: cmd, exitCode: exitCode, msg: bs}
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
 	}
 
-	return bs, nil
+	return &journalReader{ReadCloser: stdout, cmd: c, args: cmd, stderr: stderr, follow: follow}, nil
+}
+
+// journalReader reads the output of a running journalctl, reaping
+// the process when closed.
+type journalReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	args   []string
+	stderr *bytes.Buffer
+	follow bool
 }
 
-// JournalctlCmd is called from Logs to run journalctl; exported for testing.
+// Close reaps journalctl, returning an *Error if it failed.
+func (r *journalReader) Close() error {
+	if r.follow {
+		// when following, journalctl never exits on its own
+		r.cmd.Process.Kill()
+	}
+	r.ReadCloser.Close()
+	err := r.cmd.Wait()
+	if err == nil || r.follow {
+		// being killed by us is not a failure
+		return nil
+	}
+	exitCode, e := osutil.ExitCode(err)
+	if e != nil {
+		return err
+	}
+	return &Error{cmd: r.args, exitCode: exitCode, msg: r.stderr.Bytes()}
+}
+
+// JournalctlCmd is called from Logs and LogReader to run journalctl; exported for testing.
 var JournalctlCmd = jctl
 
 // Systemd exposes a minimal interface to manage systemd via the systemctl command.
@@ -96,6 +146,7 @@ type Systemd interface {
 	Status(service string) (string, error)
 	ServiceStatus(service string) (*ServiceStatus, error)
 	Logs(services []string) ([]Log, error)
+	LogReader(services []string, n string, follow bool) (io.ReadCloser, error)
 	WriteMountUnitFile(name, what, where, fstype string) (string, error)
 }
 
@@ -212,7 +263,15 @@ func (*systemd) Start(serviceName string) error {
 
 // Logs for the given service
 func (*systemd) Logs(serviceNames []string) ([]Log, error) {
-	bs, err := JournalctlCmd(serviceNames)
+	r, err := JournalctlCmd(serviceNames, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := ioutil.ReadAll(r)
+	if cerr := r.Close(); cerr != nil {
+		return nil, cerr
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -242,6 +301,14 @@ func (*systemd) Logs(serviceNames []string) ([]Log, error) {
 	return logs, nil
 }
 
+// LogReader returns a reader of the JSON-encoded journal entries of the
+// given services, limited to the last n entries (if n is not empty) and
+// following new entries as they come in if follow is true. The caller
+// is responsible for closing the returned reader.
+func (*systemd) LogReader(serviceNames []string, n string, follow bool) (io.ReadCloser, error) {
+	return JournalctlCmd(serviceNames, n, follow)
+}
+
 var statusregex = regexp.MustCompile(`(?m)^(?:(.*?)=(.*))?$`)
 
 func (s *systemd) Status(serviceName string) (string, error) {
@@ -416,6 +483,18 @@ func (l Log) SID() string {
 	return "-"
 }
 
+// PID is the pid of the process that logged the entry, if any; otherwise, "-".
+func (l Log) PID() string {
+	if pid, ok := l["_PID"].(string); ok {
+		return pid
+	}
+	if pid, ok := l["SYSLOG_PID"].(string); ok {
+		return pid
+	}
+
+	return "-"
+}
+
 func (l Log) String() string {
 	return fmt.Sprintf("%s %s %s", l.Timestamp(), l.SID(), l.Message())
 }
@@ -20,7 +20,10 @@
 package systemd_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -55,10 +58,12 @@ type SystemdTestSuite struct {
 	errors []error
 	outs   [][]byte
 
-	j     int
-	jsvcs [][]string
-	jouts [][]byte
-	jerrs []error
+	j        int
+	jsvcs    [][]string
+	jns      []string
+	jfollows []bool
+	jouts    [][]byte
+	jerrs    []error
 
 	rep *testreporter
 }
@@ -82,6 +87,8 @@ func (s *SystemdTestSuite) SetUpTest(c *C) {
 	JournalctlCmd = s.myJctl
 	s.j = 0
 	s.jsvcs = nil
+	s.jns = nil
+	s.jfollows = nil
 	s.jouts = nil
 	s.jerrs = nil
 
@@ -105,8 +112,13 @@ func (s *SystemdTestSuite) myRun(args ...string) (out []byte, err error) {
 	return out, err
 }
 
-func (s *SystemdTestSuite) myJctl(svcs []string) (out []byte, err error) {
+func (s *SystemdTestSuite) myJctl(svcs []string, n string, follow bool) (io.ReadCloser, error) {
+	var out []byte
+	var err error
+
 	s.jsvcs = append(s.jsvcs, svcs)
+	s.jns = append(s.jns, n)
+	s.jfollows = append(s.jfollows, follow)
 
 	if s.j < len(s.jouts) {
 		out = s.jouts[s.j]
@@ -116,7 +128,11 @@ func (s *SystemdTestSuite) myJctl(svcs []string) (out []byte, err error) {
 	}
 	s.j++
 
-	return out, err
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(out)), nil
 }
 
 func (s *SystemdTestSuite) TestDaemonReload(c *C) {
@@ -256,6 +272,41 @@ func (s *SystemdTestSuite) TestLogErrJSON(c *C) {
 	c.Check(s.j, Equals, 1)
 }
 
+type failingCloser struct {
+	io.Reader
+}
+
+func (failingCloser) Close() error {
+	return errors.New("journalctl failed")
+}
+
+func (s *SystemdTestSuite) TestLogErrJctlClose(c *C) {
+	JournalctlCmd = func(svcs []string, n string, follow bool) (io.ReadCloser, error) {
+		return failingCloser{bytes.NewReader([]byte(`{"a": 1}`))}, nil
+	}
+
+	logs, err := New("", s.rep).Logs([]string{"foo"})
+	c.Check(err, ErrorMatches, "journalctl failed")
+	c.Check(logs, IsNil)
+}
+
+func (s *SystemdTestSuite) TestJournalReaderCloseExitCode(c *C) {
+	r, err := StartJournalReader([]string{"sh", "-c", "echo oops >&2; exit 3"}, false)
+	c.Assert(err, IsNil)
+	_, err = ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+
+	err = r.Close()
+	c.Assert(err, FitsTypeOf, &Error{})
+	c.Check(err, ErrorMatches, `\[sh -c .*\] failed with exit status 3: oops\n`)
+}
+
+func (s *SystemdTestSuite) TestJournalReaderCloseFollow(c *C) {
+	r, err := StartJournalReader([]string{"sleep", "60"}, true)
+	c.Assert(err, IsNil)
+	c.Check(r.Close(), IsNil)
+}
+
 func (s *SystemdTestSuite) TestLogs(c *C) {
 	s.jouts = [][]byte{[]byte(`{"a": 1}
 {"a": 2}
@@ -268,6 +319,23 @@ func (s *SystemdTestSuite) TestLogs(c *C) {
 	c.Check(s.j, Equals, 1)
 }
 
+func (s *SystemdTestSuite) TestLogReader(c *C) {
+	s.jouts = [][]byte{[]byte(`{"a": 1}
+`)}
+
+	r, err := New("", s.rep).LogReader([]string{"foo", "bar"}, "10", true)
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	bs, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(string(bs), Equals, `{"a": 1}
+`)
+	c.Check(s.jsvcs, DeepEquals, [][]string{{"foo", "bar"}})
+	c.Check(s.jns, DeepEquals, []string{"10"})
+	c.Check(s.jfollows, DeepEquals, []bool{true})
+}
+
 func (s *SystemdTestSuite) TestLogString(c *C) {
 	c.Check(Log{}.String(), Equals, "-(no timestamp!)- - -")
 	c.Check(Log{
@@ -288,6 +356,15 @@ func (s *SystemdTestSuite) TestLogString(c *C) {
 
 }
 
+func (s *SystemdTestSuite) TestLogPID(c *C) {
+	c.Check(Log{}.PID(), Equals, "-")
+	c.Check(Log{"_PID": "99"}.PID(), Equals, "99")
+	c.Check(Log{"SYSLOG_PID": "99"}.PID(), Equals, "99")
+	// things starting with underscore are "trusted", so we trust
+	// them more than the user-settable ones:
+	c.Check(Log{"_PID": "42", "SYSLOG_PID": "99"}.PID(), Equals, "42")
+}
+
 func (s *SystemdTestSuite) TestMountUnitPath(c *C) {
 	c.Assert(MountUnitPath("/apps/hello/1.1"), Equals, filepath.Join(dirs.SnapServicesDir, "apps-hello-1.1.mount"))
 }
@@ -0,0 +1,33 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// fakeextkeymgr is a reference external keypair manager helper backed
+// by a directory of unencrypted PKCS#8 PEM keys, named by
+// SNAP_TEST_EXT_KEYMGR_KEYS, for use with SNAP_EXT_KEYMGR.
+package main
+
+import (
+	"os"
+
+	"github.com/snapcore/snapd/asserts/assertstest"
+)
+
+func main() {
+	os.Exit(assertstest.ExtKeypairManagerHelperMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,248 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package timeutil implements helpers to deal with recurring schedules.
+package timeutil
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeNow = time.Now
+
+// Clock is a time of the day, with minute precision.
+type Clock struct {
+	Hour   int
+	Minute int
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", c.Hour, c.Minute)
+}
+
+func (c Clock) offset() time.Duration {
+	return time.Duration(c.Hour)*time.Hour + time.Duration(c.Minute)*time.Minute
+}
+
+var validClock = regexp.MustCompile(`^([0-9]|0[0-9]|1[0-9]|2[0-4]):([0-5][0-9])$`)
+
+// ParseClock parses a string of the form "hh:mm" into a Clock.
+func ParseClock(s string) (Clock, error) {
+	m := validClock.FindStringSubmatch(s)
+	if m == nil {
+		return Clock{}, fmt.Errorf("cannot parse %q: not a valid time", s)
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	if hour == 24 && minute != 0 {
+		return Clock{}, fmt.Errorf("cannot parse %q: not a valid time", s)
+	}
+	return Clock{Hour: hour, Minute: minute}, nil
+}
+
+// Window is a span of time within a day. If End is not after Start the
+// window extends past midnight into the following day.
+type Window struct {
+	Start Clock
+	End   Clock
+}
+
+func (w Window) String() string {
+	return w.Start.String() + "-" + w.End.String()
+}
+
+// WeekdaySpan is a range of days of the week, both ends included. If End
+// comes before Start in the week the span wraps around Sunday.
+type WeekdaySpan struct {
+	Start time.Weekday
+	End   time.Weekday
+}
+
+func (s WeekdaySpan) String() string {
+	if s.Start == s.End {
+		return weekdayNames[s.Start]
+	}
+	return weekdayNames[s.Start] + "-" + weekdayNames[s.End]
+}
+
+func (s WeekdaySpan) includes(day time.Weekday) bool {
+	if s.Start <= s.End {
+		return s.Start <= day && day <= s.End
+	}
+	return day >= s.Start || day <= s.End
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	for i, name := range weekdayNames {
+		if s == name {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// Schedule is a recurring set of time windows, optionally restricted to
+// some days of the week.
+type Schedule struct {
+	Weekdays []WeekdaySpan
+	Windows  []Window
+}
+
+func (sched *Schedule) String() string {
+	elems := make([]string, 0, len(sched.Weekdays)+len(sched.Windows))
+	for _, span := range sched.Weekdays {
+		elems = append(elems, span.String())
+	}
+	for _, w := range sched.Windows {
+		elems = append(elems, w.String())
+	}
+	return strings.Join(elems, ",")
+}
+
+// ParseSchedule parses a schedule of the form
+//
+//	[<weekday>[-<weekday>],...][<hh:mm>[-<hh:mm>],...]
+//
+// for example "mon-fri,02:00-04:00" or "sat,sun,10:00-11:00,22:00-23:00".
+// Weekdays are given by their three letter english abbreviation. A schedule
+// without weekdays applies to every day, and one without time windows
+// spans the whole day. A single time is a window of one minute.
+func ParseSchedule(s string) (*Schedule, error) {
+	sched := &Schedule{}
+	for _, elem := range strings.Split(s, ",") {
+		elem = strings.ToLower(strings.TrimSpace(elem))
+		if elem == "" {
+			return nil, fmt.Errorf("cannot parse %q: empty element", s)
+		}
+		start, end := elem, elem
+		if idx := strings.IndexRune(elem, '-'); idx >= 0 {
+			start, end = elem[:idx], elem[idx+1:]
+		}
+
+		if startDay, ok := parseWeekday(start); ok {
+			endDay, ok := parseWeekday(end)
+			if !ok {
+				return nil, fmt.Errorf("cannot parse %q: %q is not a valid weekday", s, end)
+			}
+			if len(sched.Windows) > 0 {
+				return nil, fmt.Errorf("cannot parse %q: weekdays must come before times", s)
+			}
+			sched.Weekdays = append(sched.Weekdays, WeekdaySpan{Start: startDay, End: endDay})
+			continue
+		}
+
+		startClock, err := ParseClock(start)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %q is neither a weekday nor a time", s, start)
+		}
+		if startClock.Hour == 24 {
+			return nil, fmt.Errorf("cannot parse %q: a window cannot start at 24:00", s)
+		}
+		var endClock Clock
+		if start == end {
+			endClock = Clock{Hour: startClock.Hour, Minute: startClock.Minute + 1}
+			if endClock.Minute == 60 {
+				endClock = Clock{Hour: startClock.Hour + 1}
+			}
+		} else {
+			endClock, err = ParseClock(end)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %q: %q is not a valid time", s, end)
+			}
+		}
+		sched.Windows = append(sched.Windows, Window{Start: startClock, End: endClock})
+	}
+
+	sort.Sort(byStart(sched.Windows))
+
+	return sched, nil
+}
+
+type byStart []Window
+
+func (ws byStart) Len() int           { return len(ws) }
+func (ws byStart) Swap(i, j int)      { ws[i], ws[j] = ws[j], ws[i] }
+func (ws byStart) Less(i, j int) bool { return ws[i].Start.offset() < ws[j].Start.offset() }
+
+func (sched *Schedule) onWeekday(day time.Weekday) bool {
+	if len(sched.Weekdays) == 0 {
+		return true
+	}
+	for _, span := range sched.Weekdays {
+		if span.includes(day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (sched *Schedule) windows() []Window {
+	if len(sched.Windows) == 0 {
+		return []Window{{Start: Clock{Hour: 0, Minute: 0}, End: Clock{Hour: 24}}}
+	}
+	return sched.Windows
+}
+
+var randDuration = func(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// Next returns how long to wait from now until a randomly chosen moment
+// in the next window of the schedule that does not include last. The
+// randomness spreads out the load on whatever is being scheduled.
+func (sched *Schedule) Next(last time.Time) time.Duration {
+	now := timeNow()
+	windows := sched.windows()
+
+	// start one day back to catch windows that extend past midnight
+	for n := -1; n <= 8; n++ {
+		day := time.Date(now.Year(), now.Month(), now.Day()+n, 0, 0, 0, 0, now.Location())
+		if !sched.onWeekday(day.Weekday()) {
+			continue
+		}
+		for _, w := range windows {
+			start := day.Add(w.Start.offset())
+			end := day.Add(w.End.offset())
+			if !end.After(start) {
+				end = end.Add(24 * time.Hour)
+			}
+			if !end.After(now) || !last.Before(start) {
+				continue
+			}
+			if start.Before(now) {
+				start = now
+			}
+			return start.Sub(now) + randDuration(end.Sub(start))
+		}
+	}
+
+	// not reached for a schedule from ParseSchedule
+	return 24 * time.Hour
+}
@@ -0,0 +1,147 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil_test
+
+import (
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/timeutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type scheduleSuite struct{}
+
+var _ = Suite(&scheduleSuite{})
+
+func (ts *scheduleSuite) TestParseClock(c *C) {
+	for _, t := range []struct {
+		in    string
+		clock timeutil.Clock
+		err   string
+	}{
+		{"00:00", timeutil.Clock{Hour: 0, Minute: 0}, ""},
+		{"9:05", timeutil.Clock{Hour: 9, Minute: 5}, ""},
+		{"23:59", timeutil.Clock{Hour: 23, Minute: 59}, ""},
+		{"24:00", timeutil.Clock{Hour: 24, Minute: 0}, ""},
+		{"24:01", timeutil.Clock{}, `cannot parse "24:01": not a valid time`},
+		{"12:60", timeutil.Clock{}, `cannot parse "12:60": not a valid time`},
+		{"noon", timeutil.Clock{}, `cannot parse "noon": not a valid time`},
+	} {
+		clock, err := timeutil.ParseClock(t.in)
+		if t.err == "" {
+			c.Check(err, IsNil)
+			c.Check(clock, Equals, t.clock)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (ts *scheduleSuite) TestParseSchedule(c *C) {
+	for _, t := range []struct {
+		in  string
+		out string
+		err string
+	}{
+		{"mon-fri,02:00-04:00", "mon-fri,02:00-04:00", ""},
+		{"Sat,sun,22:00-23:00,10:00-11:00", "sat,sun,10:00-11:00,22:00-23:00", ""},
+		{"fri-mon", "fri-mon", ""},
+		{"23:00-01:00", "23:00-01:00", ""},
+		{"12:59", "12:59-13:00", ""},
+		{"00:00-24:00", "00:00-24:00", ""},
+		{"", "", `cannot parse "": empty element`},
+		{"mon,,tue", "", `cannot parse "mon,,tue": empty element`},
+		{"mon-potato", "", `cannot parse "mon-potato": "potato" is not a valid weekday`},
+		{"10:00,mon", "", `cannot parse "10:00,mon": weekdays must come before times`},
+		{"potato", "", `cannot parse "potato": "potato" is neither a weekday nor a time`},
+		{"10:00-potato", "", `cannot parse "10:00-potato": "potato" is not a valid time`},
+		{"24:00-01:00", "", `cannot parse "24:00-01:00": a window cannot start at 24:00`},
+	} {
+		sched, err := timeutil.ParseSchedule(t.in)
+		if t.err == "" {
+			c.Assert(err, IsNil, Commentf(t.in))
+			c.Check(sched.String(), Equals, t.out)
+		} else {
+			c.Check(err, ErrorMatches, t.err)
+		}
+	}
+}
+
+func (ts *scheduleSuite) TestNext(c *C) {
+	// no jitter, so Next returns the start of the next suitable window
+	restore := timeutil.MockRandDuration(func(time.Duration) time.Duration { return 0 })
+	defer restore()
+
+	// a Wednesday
+	now := time.Date(2016, 11, 2, 12, 30, 0, 0, time.UTC)
+	restore = timeutil.MockTimeNow(now)
+	defer restore()
+
+	for _, t := range []struct {
+		sched string
+		last  time.Time
+		next  time.Time
+	}{
+		// within the window, never ran: right away
+		{"12:00-13:00", time.Time{}, now},
+		// within the window, already ran in it: tomorrow's window
+		{"12:00-13:00", now.Add(-10 * time.Minute), time.Date(2016, 11, 3, 12, 0, 0, 0, time.UTC)},
+		// ran in yesterday's window: today's window
+		{"12:00-13:00", now.Add(-24 * time.Hour), now},
+		// window later today
+		{"14:00-15:00,02:00-03:00", now, time.Date(2016, 11, 2, 14, 0, 0, 0, time.UTC)},
+		// window earlier today is done: tomorrow
+		{"02:00-03:00", now.Add(-12 * time.Hour), time.Date(2016, 11, 3, 2, 0, 0, 0, time.UTC)},
+		// weekdays only: skip to friday
+		{"fri,02:00-04:00", now, time.Date(2016, 11, 4, 2, 0, 0, 0, time.UTC)},
+		// weekend, wrapping around the week
+		{"sat-sun", now, time.Date(2016, 11, 5, 0, 0, 0, 0, time.UTC)},
+		{"mon-fri,02:00-04:00", now, time.Date(2016, 11, 3, 2, 0, 0, 0, time.UTC)},
+		// window past midnight, started yesterday
+		{"22:00-13:00", now.Add(-20 * time.Hour), now},
+		{"22:00-13:00", now.Add(-10 * time.Hour), time.Date(2016, 11, 2, 22, 0, 0, 0, time.UTC)},
+	} {
+		sched, err := timeutil.ParseSchedule(t.sched)
+		c.Assert(err, IsNil)
+		c.Check(now.Add(sched.Next(t.last)), Equals, t.next, Commentf("%s from %s", t.sched, t.last))
+	}
+}
+
+func (ts *scheduleSuite) TestNextJitter(c *C) {
+	now := time.Date(2016, 11, 2, 12, 30, 0, 0, time.UTC)
+	restore := timeutil.MockTimeNow(now)
+	defer restore()
+
+	var spans []time.Duration
+	restore = timeutil.MockRandDuration(func(d time.Duration) time.Duration {
+		spans = append(spans, d)
+		return d / 2
+	})
+	defer restore()
+
+	sched, err := timeutil.ParseSchedule("14:00-16:00")
+	c.Assert(err, IsNil)
+	c.Check(sched.Next(now), Equals, 2*time.Hour+30*time.Minute)
+	c.Check(spans, DeepEquals, []time.Duration{2 * time.Hour})
+}
@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// Alias represents a command alias with a name in its place and the
+// name of the wrapper of the snap app it points to as target.
+type Alias struct {
+	Name   string
+	Target string
+}
+
+// AddSnapAliases creates the symlinks in the binaries directory for
+// the given aliases, replacing existing aliases of the same names.
+func AddSnapAliases(aliases []*Alias) error {
+	if err := os.MkdirAll(dirs.SnapBinariesDir, 0755); err != nil {
+		return err
+	}
+
+	for _, alias := range aliases {
+		aliasPath := filepath.Join(dirs.SnapBinariesDir, alias.Name)
+		if err := os.Remove(aliasPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Symlink(alias.Target, aliasPath); err != nil {
+			return fmt.Errorf("cannot create alias symlink: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveSnapAliases removes the symlinks in the binaries directory for
+// the given aliases, leaving alone files that are not such symlinks.
+func RemoveSnapAliases(aliases []*Alias) error {
+	for _, alias := range aliases {
+		aliasPath := filepath.Join(dirs.SnapBinariesDir, alias.Name)
+		if !osutil.IsSymlink(aliasPath) {
+			continue
+		}
+		if err := os.Remove(aliasPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
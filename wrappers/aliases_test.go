@@ -0,0 +1,91 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2017 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wrappers_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/wrappers"
+)
+
+type aliasesTestSuite struct {
+	tempdir string
+}
+
+var _ = Suite(&aliasesTestSuite{})
+
+func (s *aliasesTestSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+}
+
+func (s *aliasesTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("")
+}
+
+func (s *aliasesTestSuite) TestAddSnapAliasesAndRemove(c *C) {
+	aliases := []*wrappers.Alias{
+		{Name: "hello", Target: "hello-snap.hello"},
+		{Name: "hi.there", Target: "hello-snap"},
+	}
+
+	err := wrappers.AddSnapAliases(aliases)
+	c.Assert(err, IsNil)
+
+	for _, alias := range aliases {
+		target, err := os.Readlink(filepath.Join(dirs.SnapBinariesDir, alias.Name))
+		c.Assert(err, IsNil)
+		c.Check(target, Equals, alias.Target)
+	}
+
+	err = wrappers.RemoveSnapAliases(aliases)
+	c.Assert(err, IsNil)
+
+	for _, alias := range aliases {
+		c.Check(osutil.FileExists(filepath.Join(dirs.SnapBinariesDir, alias.Name)), Equals, false)
+	}
+}
+
+func (s *aliasesTestSuite) TestAddSnapAliasesReplaces(c *C) {
+	err := wrappers.AddSnapAliases([]*wrappers.Alias{{Name: "hello", Target: "hello-snap.hello"}})
+	c.Assert(err, IsNil)
+	err = wrappers.AddSnapAliases([]*wrappers.Alias{{Name: "hello", Target: "other-snap.hello"}})
+	c.Assert(err, IsNil)
+
+	target, err := os.Readlink(filepath.Join(dirs.SnapBinariesDir, "hello"))
+	c.Assert(err, IsNil)
+	c.Check(target, Equals, "other-snap.hello")
+}
+
+func (s *aliasesTestSuite) TestRemoveSnapAliasesLeavesFiles(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapBinariesDir, 0755), IsNil)
+	fn := filepath.Join(dirs.SnapBinariesDir, "hello")
+	c.Assert(ioutil.WriteFile(fn, nil, 0755), IsNil)
+
+	err := wrappers.RemoveSnapAliases([]*wrappers.Alias{{Name: "hello", Target: "hello-snap.hello"}})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(fn), Equals, true)
+}
@@ -180,6 +180,16 @@ func updateDesktopDatabase(desktopFiles []string) error {
 	return nil
 }
 
+// desktopPrefix returns the prefix of the desktop files installed for the
+// snap. Snap instances use "<snap>+<key>" so that the files of one
+// instance never match the glob of another.
+func desktopPrefix(s *snap.Info) string {
+	if s.InstanceKey == "" {
+		return s.Name()
+	}
+	return fmt.Sprintf("%s+%s", s.Name(), s.InstanceKey)
+}
+
 // AddSnapDesktopFiles puts in place the desktop files for the applications from the snap.
 func AddSnapDesktopFiles(s *snap.Info) error {
 	if err := os.MkdirAll(dirs.SnapDesktopFilesDir, 0755); err != nil {
@@ -199,7 +209,7 @@ func AddSnapDesktopFiles(s *snap.Info) error {
 			return err
 		}
 
-		installedDesktopFileName := filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s", s.Name(), filepath.Base(df)))
+		installedDesktopFileName := filepath.Join(dirs.SnapDesktopFilesDir, fmt.Sprintf("%s_%s", desktopPrefix(s), filepath.Base(df)))
 		content = sanitizeDesktopFile(s, installedDesktopFileName, content)
 		if err := osutil.AtomicWriteFile(installedDesktopFileName, []byte(content), 0755, 0); err != nil {
 			return err
@@ -216,7 +226,7 @@ func AddSnapDesktopFiles(s *snap.Info) error {
 
 // RemoveSnapDesktopFiles removes the added desktop files for the applications in the snap.
 func RemoveSnapDesktopFiles(s *snap.Info) error {
-	glob := filepath.Join(dirs.SnapDesktopFilesDir, s.Name()+"_*.desktop")
+	glob := filepath.Join(dirs.SnapDesktopFilesDir, desktopPrefix(s)+"_*.desktop")
 	activeDesktopFiles, err := filepath.Glob(glob)
 	if err != nil {
 		return fmt.Errorf("cannot get desktop files for %v: %s", glob, err)
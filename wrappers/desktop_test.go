@@ -105,6 +105,26 @@ func (s *desktopSuite) TestRemovePackageDesktopFiles(c *C) {
 	})
 }
 
+func (s *desktopSuite) TestRemovePackageDesktopFilesParallelInstall(c *C) {
+	mockDesktopFilePath := filepath.Join(dirs.SnapDesktopFilesDir, "foo_foobar.desktop")
+	mockInstanceDesktopFilePath := filepath.Join(dirs.SnapDesktopFilesDir, "foo+blue_foobar.desktop")
+
+	err := os.MkdirAll(dirs.SnapDesktopFilesDir, 0755)
+	c.Assert(err, IsNil)
+	for _, fn := range []string{mockDesktopFilePath, mockInstanceDesktopFilePath} {
+		err = ioutil.WriteFile(fn, mockDesktopFile, 0644)
+		c.Assert(err, IsNil)
+	}
+	info, err := snap.InfoFromSnapYaml([]byte(desktopAppYaml))
+	c.Assert(err, IsNil)
+	info.InstanceKey = "blue"
+
+	err = wrappers.RemoveSnapDesktopFiles(info)
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(mockInstanceDesktopFilePath), Equals, false)
+	c.Check(osutil.FileExists(mockDesktopFilePath), Equals, true)
+}
+
 // sanitize
 
 type sanitizeDesktopFileSuite struct{}